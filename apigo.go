@@ -3,15 +3,23 @@ package apigo
 
 import (
 	"context"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
 	"reflect"
+	"strings"
 	_ "time/tzdata"
 
 	"github.com/dhaifley/apigo/db/migrations"
 	"github.com/dhaifley/apigo/internal/config"
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/logger"
 	"github.com/dhaifley/apigo/internal/metric"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sandbox"
+	"github.com/dhaifley/apigo/internal/secret"
 	"github.com/dhaifley/apigo/internal/server"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
@@ -26,25 +34,68 @@ import (
 
 // Service values are used to provide API services.
 type Service struct {
-	svr *server.Server
-	mp  *sdkmetric.MeterProvider
-	tp  *sdktrace.TracerProvider
-	cfg *config.Config
-	log logger.Logger
+	svr     *server.Server
+	mp      *sdkmetric.MeterProvider
+	tp      *sdktrace.TracerProvider
+	cfg     *config.Config
+	file    string
+	log     logger.Logger
+	lvl     *slog.LevelVar
+	fwd     *logger.Forwarder
+	cancel  context.CancelFunc
+	sandbox bool
 }
 
 // New initializes a new service.
 func New() *Service {
-	svc := &Service{cfg: config.New("api")}
+	svc := &Service{cfg: config.New("api"), lvl: &slog.LevelVar{}}
 
 	svc.cfg.Load(nil)
 
-	svc.log = logger.New(svc.cfg.LogOut(), svc.cfg.LogFormat(),
-		svc.cfg.LogLevel())
+	svc.log, svc.fwd = newLogger(svc.cfg, svc.lvl)
 
 	return svc
 }
 
+// LoadConfig loads the service configuration from a file, if one is provided,
+// or named by the environment, and applies the overrides, of the form
+// key=value, which take precedence over the file and environment variables.
+// It must be called before the service is started.
+func (s *Service) LoadConfig(file string, overrides []string) error {
+	if file == "" {
+		file = config.ConfigFile()
+	}
+
+	ov, err := config.ParseOverrides(overrides)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrInvalidParameter,
+			"invalid configuration overrides")
+	}
+
+	s.cfg.SetOverrides(ov)
+
+	if file == "" {
+		s.cfg.Load(nil)
+	} else if err := s.cfg.LoadFile(file); err != nil {
+		return errors.Wrap(err, errors.ErrInvalidParameter,
+			"unable to load configuration file")
+	}
+
+	s.file = file
+
+	if s.fwd != nil {
+		if err := s.fwd.Close(); err != nil {
+			s.log.Log(context.Background(), logger.LvlError,
+				"unable to close log forwarder",
+				"error", err)
+		}
+	}
+
+	s.log, s.fwd = newLogger(s.cfg, s.lvl)
+
+	return nil
+}
+
 // Handler returns the http handler function for the service.
 func (s *Service) Version() string {
 	return server.Version
@@ -59,6 +110,13 @@ func (s *Service) Handler() http.HandlerFunc {
 	return s.svr.Mux
 }
 
+// SetSandbox sets whether the service runs in sandbox mode. In sandbox mode,
+// the service does not connect to a database and serves deterministic
+// in-memory fixtures, authenticated using the sandbox token.
+func (s *Service) SetSandbox(enabled bool) {
+	s.sandbox = enabled
+}
+
 // Start begins service operations.
 func (s *Service) Start(ctx context.Context) error {
 	var (
@@ -76,6 +134,10 @@ func (s *Service) Start(ctx context.Context) error {
 		}
 
 		mr = metric.NewRecorder(s.cfg, s.mp)
+
+		if s.fwd != nil && mr != nil {
+			s.fwd.SetCounter(mr)
+		}
 	}
 
 	if s.cfg.TraceAddress() != "" {
@@ -89,11 +151,42 @@ func (s *Service) Start(ctx context.Context) error {
 		}
 	}
 
+	if r := secret.NewRefresher(s.cfg, s.log, mr,
+		httpclient.NewClient(s.cfg, s.log, mr, tr)); r != nil {
+		if err := r.Refresh(ctx); err != nil {
+			return errors.Wrap(err, errors.ErrServer,
+				"unable to load secrets",
+				"provider", s.cfg.SecretProvider())
+		}
+
+		var rCtx context.Context
+
+		rCtx, s.cancel = context.WithCancel(ctx)
+
+		// Load secrets again periodically, to use rotated values.
+		go r.Run(rCtx)
+	}
+
 	s.svr, err = server.NewServer(s.cfg, s.log, mr, tr)
 	if err != nil {
 		return err
 	}
 
+	if s.cfg.ServerReplayDir() != "" {
+		return s.svr.Serve()
+	}
+
+	if s.sandbox {
+		s.svr.SetDB(sandbox.NewDB())
+		s.svr.SetAuthService(sandbox.NewAuthService())
+		s.svr.SetResourceService(sandbox.NewResourceService(s.cfg))
+
+		s.log.Log(ctx, logger.LvlWarn,
+			"serving sandbox fixtures, no data will be persisted")
+
+		return s.svr.Serve()
+	}
+
 	go func(ctx context.Context, svr *server.Server) {
 		// Start emitting metrics.
 		if err := svr.UpdateMetrics(ctx); err != nil {
@@ -107,6 +200,9 @@ func (s *Service) Start(ctx context.Context) error {
 
 		// Get and update authentication configuration data.
 		svr.UpdateAuthConfig()
+
+		// Receive cache invalidations from other service instances.
+		svr.ListenCache()
 	}(ctx, s.svr)
 
 	return s.svr.Serve()
@@ -114,6 +210,10 @@ func (s *Service) Start(ctx context.Context) error {
 
 // Close shuts down service operations.
 func (s *Service) Close(ctx context.Context) {
+	if s.cancel != nil {
+		s.cancel()
+	}
+
 	s.svr.Shutdown(ctx)
 
 	if s.mp != nil {
@@ -131,6 +231,45 @@ func (s *Service) Close(ctx context.Context) {
 				"error", err)
 		}
 	}
+
+	if s.fwd != nil {
+		if err := s.fwd.Close(); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to close log forwarder",
+				"error", err)
+		}
+	}
+}
+
+// Reload reloads service configuration which can be changed without a restart,
+// such as the log level, import interval and rate limits from the
+// configuration file, and the server TLS certificates.
+func (s *Service) Reload(ctx context.Context) error {
+	if s.file != "" {
+		changed, err := s.cfg.Reload(s.file)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrInvalidParameter,
+				"unable to reload configuration file")
+		}
+
+		s.lvl.Set(s.cfg.LogLevel())
+
+		s.log.Log(ctx, logger.LvlInfo,
+			"configuration reloaded",
+			"file", s.file,
+			"changed", changed)
+	}
+
+	if s.svr == nil {
+		return nil
+	}
+
+	if err := s.svr.ReloadTLS(); err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to reload server TLS configuration")
+	}
+
+	return nil
 }
 
 // Migrate will apply database migrations.
@@ -146,6 +285,26 @@ func (s *Service) Migrate(ctx context.Context) error {
 	return nil
 }
 
+// RunMigration will run a database migration command, such as applying or
+// reverting migrations up, or down, to a schema version, or reporting the
+// current schema version.
+func (s *Service) RunMigration(ctx context.Context,
+	opts *migrations.Options,
+) (*migrations.Status, error) {
+	res, err := migrations.Run(s.cfg, s.log, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to run database migration")
+	}
+
+	s.log.Log(ctx, logger.LvlInfo,
+		"database migration complete",
+		"version", res.Version,
+		"dirty", res.Dirty)
+
+	return res, nil
+}
+
 type otlpErrorHandler struct {
 	log logger.Logger
 }
@@ -179,10 +338,19 @@ func newTracerProvider(ctx context.Context,
 			"unable to create tracing resource for service")
 	}
 
-	client := otlptracehttp.NewClient(
-		otlptracehttp.WithEndpoint(cfg.TraceAddress()),
-		otlptracehttp.WithInsecure(),
-	)
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithHeaders(cfg.TraceHeaders()),
+	}
+
+	// Addresses containing a scheme are used as the full collector URL.
+	if addr := cfg.TraceAddress(); strings.Contains(addr, "://") {
+		opts = append(opts, otlptracehttp.WithEndpointURL(addr))
+	} else {
+		opts = append(opts, otlptracehttp.WithEndpoint(addr),
+			otlptracehttp.WithInsecure())
+	}
+
+	client := otlptracehttp.NewClient(opts...)
 
 	var exp sdktrace.SpanExporter
 
@@ -195,13 +363,57 @@ func newTracerProvider(ctx context.Context,
 	tp := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exp),
 		sdktrace.WithResource(r),
+		sdktrace.WithSampler(sdktrace.ParentBased(
+			sdktrace.TraceIDRatioBased(cfg.TraceSample()))),
 	)
 
 	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(request.Propagator)
 
 	return tp, nil
 }
 
+// newLogger initializes the logger for the service, using the configured
+// logging library. If log forwarding is configured, entries are also written
+// to the returned forwarder. If the forwarder cannot be created, the error is
+// logged and entries are only written to the log output. The minimum level is
+// read from lvl, which is set to the configured level, so that it can be
+// changed when the configuration is reloaded.
+func newLogger(cfg *config.Config,
+	lvl *slog.LevelVar,
+) (logger.Logger, *logger.Forwarder) {
+	var out io.Writer = os.Stderr
+
+	lvl.Set(cfg.LogLevel())
+
+	if cfg.LogOut() == config.LogOutStdout {
+		out = os.Stdout
+	}
+
+	if cfg.LogForward() == "" {
+		return logger.NewWriter(out, cfg.LogBackend(), cfg.LogFormat(),
+			lvl), nil
+	}
+
+	fwd, err := logger.NewForwarder(cfg.LogForward(), cfg.LogForwardAddress(),
+		cfg.ServiceName(), cfg.LogForwardBuffer())
+	if err != nil {
+		log := logger.NewWriter(out, cfg.LogBackend(), cfg.LogFormat(),
+			lvl)
+
+		log.Log(context.Background(), logger.LvlError,
+			"unable to create log forwarder",
+			"error", err,
+			"sink", cfg.LogForward(),
+			"address", cfg.LogForwardAddress())
+
+		return log, nil
+	}
+
+	return logger.NewWriter(io.MultiWriter(out, fwd), cfg.LogBackend(),
+		cfg.LogFormat(), lvl), fwd
+}
+
 // newMeterProvider initializes the meter provider for the service.
 func newMeterProvider(ctx context.Context,
 	cfg *config.Config,
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NounProfile is the subcommand noun used to manage stored profiles.
+const NounProfile = "profile"
+
+// Subcommand input kinds.
+const (
+	InputNone = iota
+	InputBody
+	InputForm
+)
+
+// Subcommand values describe a subcommand, which performs a common API
+// request, so that its method and path need not be known.
+type Subcommand struct {
+	Method string
+	Path   func(params []string) (string, error)
+	Input  int
+}
+
+// Subcommands contains the supported subcommands, by noun and verb.
+var Subcommands = map[string]map[string]*Subcommand{
+	"resource": {
+		"list": {Method: http.MethodGet, Path: fixedPath("resources")},
+		"get":  {Method: http.MethodGet, Path: idPath("resources")},
+		"create": {Method: http.MethodPost, Path: fixedPath("resources"),
+			Input: InputBody},
+		"import": {Method: http.MethodPost, Path: importPath},
+	},
+	"token": {
+		"create": {Method: http.MethodPost, Path: fixedPath("login/token"),
+			Input: InputForm},
+	},
+	"account": {
+		"show": {Method: http.MethodGet, Path: fixedPath("account")},
+	},
+}
+
+// isNoun determines whether an argument is a subcommand noun.
+func isNoun(v string) bool {
+	if v == NounProfile {
+		return true
+	}
+
+	_, ok := Subcommands[v]
+
+	return ok
+}
+
+// fixedPath returns a path function for subcommands without parameters.
+func fixedPath(p string) func([]string) (string, error) {
+	return func(params []string) (string, error) {
+		if len(params) > 0 {
+			return "", fmt.Errorf("unexpected parameters: %s",
+				strings.Join(params, " "))
+		}
+
+		return p, nil
+	}
+}
+
+// idPath returns a path function for subcommands requiring a single ID.
+func idPath(p string) func([]string) (string, error) {
+	return func(params []string) (string, error) {
+		if len(params) != 1 {
+			return "", errors.New("expected a single ID parameter")
+		}
+
+		return path.Join(p, params[0]), nil
+	}
+}
+
+// importPath returns the path used to import all resources, or a single
+// resource if an ID is provided.
+func importPath(params []string) (string, error) {
+	switch len(params) {
+	case 0:
+		return "resources/import", nil
+	case 1:
+		return path.Join("resources", params[0], "import"), nil
+	default:
+		return "", errors.New("expected at most one ID parameter")
+	}
+}
+
+// Resolve sets the method and resource of the arguments from the subcommand,
+// returning the subcommand.
+func (a *Args) Resolve() (*Subcommand, error) {
+	verbs, ok := Subcommands[a.Noun]
+	if !ok {
+		return nil, fmt.Errorf("invalid command: %s", a.Noun)
+	}
+
+	sc, ok := verbs[a.Verb]
+	if !ok {
+		return nil, fmt.Errorf("invalid %s command: %s", a.Noun, a.Verb)
+	}
+
+	p, err := sc.Path(a.Params)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %s command: %w", a.Noun, a.Verb,
+			err)
+	}
+
+	a.Method = sc.Method
+	a.Resource = p
+
+	return sc, nil
+}
+
+// formBody returns the query parameters of the arguments as a form request
+// body, reading a password from the input if one is not provided, so that it
+// need not appear in the command line.
+func formBody(args *Args) ([]byte, error) {
+	v := url.Values{}
+
+	if args.Query != nil {
+		v = *args.Query
+	}
+
+	if v.Get("password") == "" {
+		s := bufio.NewScanner(os.Stdin)
+
+		if !s.Scan() {
+			if err := s.Err(); err != nil {
+				return nil, fmt.Errorf("unable to read password: %w", err)
+			}
+
+			return nil, errors.New("missing password")
+		}
+
+		v.Set("password", strings.TrimSpace(s.Text()))
+	}
+
+	args.Query = nil
+
+	return []byte(v.Encode()), nil
+}
+
+// output writes a value in the configured format.
+func output(cfg *Config, v any) error {
+	var b []byte
+
+	var err error
+
+	if cfg.Format == FmtYAML {
+		b, err = yaml.Marshal(v)
+	} else {
+		b, err = json.MarshalIndent(v, "", "  ")
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to format output: %w", err)
+	}
+
+	fmt.Println(strings.TrimSpace(string(b)))
+
+	return nil
+}
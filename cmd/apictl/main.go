@@ -12,7 +12,9 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -22,6 +24,7 @@ var Version = "0.1.1"
 
 // Usage details.
 const Usage = `Usage: apictl [<option>] <command> <resource> [<query>]
+       apictl [<option>] <noun> <verb> [<parameter>] [<query>]
 
 Options:
   --help = Display this usage message
@@ -30,6 +33,9 @@ Options:
   --config.format = (json|yaml) Format of the command input and output
   --config.headers = Optional, HTTP headers to include with the API request
   --config.tls = Optional, TLS options to use for the API request
+  --config.retries = Optional, number of times to retry retryable failures
+  --config.token = Optional, access token to send as a bearer token
+  --config.profile = Optional, name of the stored profile to use
   
 Commands:
   get
@@ -43,6 +49,17 @@ Resources:
   Any resource or ID provided by the API. Multiple parameters will be combined
 as path segments in the API request.
 
+Subcommands:
+  resource list = List resources
+  resource get <id> = Get a resource
+  resource create = Create a resource from the input
+  resource import [<id>] = Import all resources, or a single resource
+  token create = Create an access token, using the --username, --password and
+--scope parameters, reading the password from the input if it is not provided
+  account show = Show the current account
+  profile save [<name>] = Store the endpoint, token and format in a profile
+  profile show [<name>] = Show a stored profile
+
 Query Parameters:
   Any parameters beginning with -- will be sent as query parameters with the API
 request. For example, --param=value will be sent as ?param=value. Common query
@@ -72,11 +89,18 @@ const (
 	FmtYAML = "yaml"
 )
 
+// DefaultRetries is the number of times a request is retried, if it fails with
+// a retryable error, when no retries are configured.
+const DefaultRetries = 3
+
 // Args values are used to represent the arguments to the command.
 type Args struct {
 	Method   string      `json:"method"   yaml:"method"`
 	Resource string      `json:"resource" yaml:"resource"`
 	Query    *url.Values `json:"query"    yaml:"query"`
+	Noun     string      `json:"noun"     yaml:"noun"`
+	Verb     string      `json:"verb"     yaml:"verb"`
+	Params   []string    `json:"params"   yaml:"params"`
 }
 
 // Config values are used to configure the API requests.
@@ -85,6 +109,9 @@ type Config struct {
 	Headers  *http.Header `json:"headers"  yaml:"headers"`
 	TLS      *tls.Config  `json:"tls"      yaml:"tls"`
 	Format   string       `json:"format"   yaml:"format"`
+	Retries  *int         `json:"retries,string" yaml:"retries"`
+	Token    string       `json:"token"    yaml:"token"`
+	Profile  string       `json:"profile"  yaml:"profile"`
 }
 
 // LoadEnvironment loads missing configuration from the environment.
@@ -97,25 +124,168 @@ func (c *Config) LoadEnvironment() error {
 		c.Format = os.Getenv("APICTL_CONFIG_FORMAT")
 	}
 
-	if c.Headers == nil {
-		v := os.Getenv("APICTL_CONFIG_HEADERS")
+	if c.Token == "" {
+		c.Token = os.Getenv("APICTL_CONFIG_TOKEN")
+	}
+
+	if c.Profile == "" {
+		c.Profile = os.Getenv("APICTL_CONFIG_PROFILE")
+	}
 
+	if v := os.Getenv("APICTL_CONFIG_HEADERS"); c.Headers == nil && v != "" {
 		if err := json.Unmarshal([]byte(v), &c.Headers); err != nil {
 			return fmt.Errorf("unable to parse APICTL_CONFIG_HEADERS: %w", err)
 		}
 	}
 
-	if c.TLS == nil {
-		v := os.Getenv("APICTL_CONFIG_TLS")
-
+	if v := os.Getenv("APICTL_CONFIG_TLS"); c.TLS == nil && v != "" {
 		if err := json.Unmarshal([]byte(v), &c.TLS); err != nil {
 			return fmt.Errorf("unable to parse APICTL_CONFIG_TLS: %w", err)
 		}
 	}
 
+	if c.Retries == nil {
+		r := DefaultRetries
+
+		if v := os.Getenv("APICTL_CONFIG_RETRIES"); v != "" {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("unable to parse APICTL_CONFIG_RETRIES: %w",
+					err)
+			}
+
+			r = i
+		}
+
+		c.Retries = &r
+	}
+
 	return nil
 }
 
+// Validate checks the configuration, once it has been loaded, applying the
+// default format, and the access token as a bearer authorization header.
+func (c *Config) Validate() error {
+	if c.Endpoint == "" {
+		return fmt.Errorf("missing config.endpoint")
+	}
+
+	switch c.Format {
+	case FmtJSON, FmtYAML:
+	case "":
+		c.Format = FmtJSON
+	default:
+		return fmt.Errorf("invalid config.format: %s", c.Format)
+	}
+
+	if c.Token != "" {
+		if c.Headers == nil {
+			c.Headers = &http.Header{}
+		}
+
+		if c.Headers.Get("Authorization") == "" {
+			c.Headers.Set("Authorization", "Bearer "+c.Token)
+		}
+	}
+
+	return nil
+}
+
+// retryable determines whether a failed request may succeed if it is sent
+// again, either because of the response status, or because the error returned
+// by the API is marked as retryable.
+func retryable(status int, body []byte) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+
+	if status < http.StatusBadRequest {
+		return false
+	}
+
+	e := struct {
+		Retryable bool `json:"retryable"`
+	}{}
+
+	if err := json.Unmarshal(body, &e); err != nil {
+		return false
+	}
+
+	return e.Retryable
+}
+
+// retryDelay returns the time to wait before sending a request again, using the
+// Retry-After header of the response if one is present, or an exponential
+// backoff based on the number of the attempt otherwise.
+func retryDelay(res *http.Response, attempt int) time.Duration {
+	if v := res.Header.Get("Retry-After"); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i >= 0 {
+			return time.Duration(i) * time.Second
+		}
+
+		if t, err := http.ParseTime(v); err == nil {
+			return time.Until(t)
+		}
+	}
+
+	return (500 * time.Millisecond) << attempt
+}
+
+// Do performs the API request, sending it again, up to the configured number
+// of retries, while it fails with a retryable error. It returns the final
+// response, with the body already read.
+func Do(ctx context.Context, cli *http.Client, cfg *Config, method, u string,
+	body []byte,
+) (*http.Response, []byte, error) {
+	retries := DefaultRetries
+
+	if cfg.Retries != nil {
+		retries = *cfg.Retries
+	}
+
+	for attempt := 0; ; attempt++ {
+		var rb io.Reader
+
+		if body != nil {
+			rb = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, u, rb)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to create request: %w", err)
+		}
+
+		if cfg.Headers != nil {
+			req.Header = cfg.Headers.Clone()
+		}
+
+		res, err := cli.Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to perform request: %w", err)
+		}
+
+		b, err := io.ReadAll(res.Body)
+
+		res.Body.Close()
+
+		if err != nil {
+			return nil, nil,
+				fmt.Errorf("unable to read response body: %w", err)
+		}
+
+		if attempt >= retries || !retryable(res.StatusCode, b) {
+			return res, b, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return res, b, nil
+		case <-time.After(retryDelay(res, attempt)):
+		}
+	}
+}
+
 // ParseArgs is used to parse the arguments to the command into the required
 // data structures.
 func ParseArgs() (*Args, *Config, error) {
@@ -187,7 +357,23 @@ func ParseArgs() (*Args, *Config, error) {
 			continue
 		}
 
+		if args.Noun != "" {
+			if args.Verb == "" {
+				args.Verb = strings.TrimSpace(strings.ToLower(arg))
+			} else {
+				args.Params = append(args.Params, arg)
+			}
+
+			continue
+		}
+
 		if args.Method == "" {
+			if v := strings.TrimSpace(strings.ToLower(arg)); isNoun(v) {
+				args.Noun = v
+
+				continue
+			}
+
 			switch v := strings.TrimSpace(strings.ToUpper(arg)); v {
 			case CmdGet, CmdCreate, CmdPost, CmdUpdate, CmdPut, CmdPatch,
 				CmdDelete, CmdOptions, CmdHead:
@@ -219,16 +405,8 @@ func ParseArgs() (*Args, *Config, error) {
 		}
 	}
 
-	if cfg.Endpoint == "" {
-		return nil, nil, fmt.Errorf("missing config.endpoint")
-	}
-
-	switch cfg.Format {
-	case FmtJSON, FmtYAML:
-	case "":
-		cfg.Format = FmtJSON
-	default:
-		return nil, nil, fmt.Errorf("invalid config.format: %s", cfg.Format)
+	if args.Noun != "" && args.Verb == "" {
+		return nil, nil, fmt.Errorf("missing %s command", args.Noun)
 	}
 
 	switch args.Method {
@@ -256,6 +434,45 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := cfg.LoadProfile(); err != nil {
+		fmt.Println("ERROR: ", err.Error())
+
+		os.Exit(1)
+	}
+
+	if args.Noun == NounProfile {
+		if err := RunProfile(args, cfg); err != nil {
+			fmt.Println("ERROR: ", err.Error())
+
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	input := InputBody
+
+	if args.Noun != "" {
+		sc, err := args.Resolve()
+		if err != nil {
+			fmt.Println("ERROR: ", err.Error())
+
+			os.Exit(1)
+		}
+
+		input = sc.Input
+	} else if args.Method == "" {
+		fmt.Println("ERROR: missing command")
+
+		os.Exit(1)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Println("ERROR: ", err.Error())
+
+		os.Exit(1)
+	}
+
 	ctx := context.Background()
 
 	ur, err := url.Parse(cfg.Endpoint)
@@ -268,14 +485,32 @@ func main() {
 
 	ur.Path = path.Join(ur.Path, args.Resource)
 
+	var body []byte
+
+	if input == InputForm {
+		if body, err = formBody(args); err != nil {
+			fmt.Println("ERROR: ", err.Error())
+
+			os.Exit(1)
+		}
+
+		if cfg.Headers == nil {
+			cfg.Headers = &http.Header{}
+		}
+
+		cfg.Headers.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
 	if args.Query != nil {
 		ur.RawQuery = args.Query.Encode()
 	}
 
-	var buf *bytes.Buffer
-
 	switch args.Method {
 	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		if input != InputBody {
+			break
+		}
+
 		b, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			fmt.Println("ERROR: unable to read input: ", err.Error())
@@ -301,27 +536,7 @@ func main() {
 			}
 		}
 
-		buf = bytes.NewBuffer(b)
-	}
-
-	var req *http.Request
-
-	if buf != nil {
-		req, err = http.NewRequestWithContext(ctx, args.Method, ur.String(),
-			buf)
-	} else {
-		req, err = http.NewRequestWithContext(ctx, args.Method, ur.String(),
-			nil)
-	}
-
-	if err != nil || req == nil {
-		fmt.Println("ERROR: unable to create request: ", err.Error())
-
-		os.Exit(1)
-	}
-
-	if cfg.Headers != nil {
-		req.Header = *cfg.Headers
+		body = b
 	}
 
 	cli := &http.Client{}
@@ -330,15 +545,13 @@ func main() {
 		cli.Transport = &http.Transport{TLSClientConfig: cfg.TLS}
 	}
 
-	res, err := cli.Do(req)
+	res, b, err := Do(ctx, cli, cfg, args.Method, ur.String(), body)
 	if err != nil {
-		fmt.Println("ERROR: unable to perform request: ", err.Error())
+		fmt.Println("ERROR: ", err.Error())
 
 		os.Exit(1)
 	}
 
-	defer res.Body.Close()
-
 	if args.Method == CmdOptions || args.Method == CmdHead {
 		var b []byte
 
@@ -361,13 +574,6 @@ func main() {
 		os.Exit(0)
 	}
 
-	b, err := io.ReadAll(res.Body)
-	if err != nil {
-		fmt.Println("ERROR: unable to read response body: ", err.Error())
-
-		os.Exit(1)
-	}
-
 	ec := 0
 
 	switch {
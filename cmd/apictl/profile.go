@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultProfile is the name of the profile used when none is configured.
+const DefaultProfile = "default"
+
+// Profile values contain the stored configuration used to access an API, so
+// that it need not be provided with every command.
+type Profile struct {
+	Endpoint string `json:"endpoint"        yaml:"endpoint"`
+	Token    string `json:"token,omitempty"  yaml:"token,omitempty"`
+	Format   string `json:"format,omitempty" yaml:"format,omitempty"`
+}
+
+// ProfilesPath returns the path of the file containing the stored profiles.
+// It is APICTL_CONFIG_PROFILES, if set, or apictl/profiles.yaml in the user
+// configuration directory otherwise.
+func ProfilesPath() (string, error) {
+	if v := os.Getenv("APICTL_CONFIG_PROFILES"); v != "" {
+		return v, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to find user config directory: %w", err)
+	}
+
+	return filepath.Join(dir, "apictl", "profiles.yaml"), nil
+}
+
+// LoadProfiles reads the stored profiles, by name. If no profiles have been
+// stored, none are returned.
+func LoadProfiles() (map[string]*Profile, error) {
+	p, err := ProfilesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]*Profile{}
+
+	b, err := os.ReadFile(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return res, nil
+		}
+
+		return nil, fmt.Errorf("unable to read profiles: %w", err)
+	}
+
+	if err := yaml.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("unable to parse profiles: %s: %w", p, err)
+	}
+
+	return res, nil
+}
+
+// SaveProfiles stores the profiles, by name. The file is only readable by the
+// user, since profiles contain access tokens.
+func SaveProfiles(profiles map[string]*Profile) error {
+	p, err := ProfilesPath()
+	if err != nil {
+		return err
+	}
+
+	b, err := yaml.Marshal(profiles)
+	if err != nil {
+		return fmt.Errorf("unable to format profiles: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("unable to create profiles directory: %w", err)
+	}
+
+	if err := os.WriteFile(p, b, 0o600); err != nil {
+		return fmt.Errorf("unable to write profiles: %w", err)
+	}
+
+	return nil
+}
+
+// LoadProfile loads missing configuration from the configured profile. It is
+// not an error for the default profile to be missing.
+func (c *Config) LoadProfile() error {
+	name := c.Profile
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return err
+	}
+
+	p, ok := profiles[name]
+	if !ok || p == nil {
+		if c.Profile != "" && c.Profile != DefaultProfile {
+			return fmt.Errorf("unknown profile: %s", c.Profile)
+		}
+
+		return nil
+	}
+
+	if c.Endpoint == "" {
+		c.Endpoint = p.Endpoint
+	}
+
+	if c.Token == "" {
+		c.Token = p.Token
+	}
+
+	if c.Format == "" {
+		c.Format = p.Format
+	}
+
+	return nil
+}
+
+// RunProfile performs a profile command, which manages the stored profiles
+// rather than making an API request. The save command stores the current
+// configuration in a profile, and the show command displays a profile, without
+// its token.
+func RunProfile(args *Args, cfg *Config) error {
+	name := cfg.Profile
+	if len(args.Params) > 0 {
+		name = args.Params[0]
+	}
+
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return err
+	}
+
+	switch args.Verb {
+	case "save":
+		if cfg.Endpoint == "" {
+			return errors.New("missing config.endpoint")
+		}
+
+		profiles[name] = &Profile{
+			Endpoint: cfg.Endpoint,
+			Token:    cfg.Token,
+			Format:   cfg.Format,
+		}
+
+		if err := SaveProfiles(profiles); err != nil {
+			return err
+		}
+
+		fmt.Println("saved profile: " + name)
+	case "show":
+		p, ok := profiles[name]
+		if !ok || p == nil {
+			return fmt.Errorf("unknown profile: %s", name)
+		}
+
+		v := *p
+
+		if v.Token != "" {
+			v.Token = strings.Repeat("*", 8)
+		}
+
+		return output(cfg, &v)
+	default:
+		return fmt.Errorf("invalid profile command: %s", args.Verb)
+	}
+
+	return nil
+}
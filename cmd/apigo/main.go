@@ -3,29 +3,77 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	_ "time/tzdata" // Embed time zones for images without zoneinfo.
 
 	"github.com/dhaifley/apigo"
+	"github.com/dhaifley/apigo/db/migrations"
 )
 
-// Main service entry point.
+// overrides values are configuration overrides, of the form key=value,
+// provided by repeated command line flags.
+type overrides []string
+
+// String returns the overrides as a string.
+func (o *overrides) String() string {
+	return strings.Join(*o, ",")
+}
+
+// Set adds an override.
+func (o *overrides) Set(v string) error {
+	*o = append(*o, v)
+
+	return nil
+}
+
+// Main service entry point, parsed from the arguments:
+//
+//	apigo [--config file] [--set key=value]... [--sandbox] [command]
+//
+// Configuration is loaded from the file, if provided, or named by CONFIG_FILE,
+// then from environment variables, then from the --set flags, each taking
+// precedence over the last. Keys of the --set flags are the dot separated path
+// of the value in the configuration file, such as log.level.
 func main() {
 	ctx := context.Background()
 
 	svc := apigo.New()
 
-	if len(os.Args) > 1 && os.Args[1] == "version" {
+	fs := flag.NewFlagSet("apigo", flag.ExitOnError)
+
+	file := fs.String("config", "",
+		"configuration file, in the YAML, JSON or TOML format")
+	sandbox := fs.Bool("sandbox", false,
+		"serve in-memory fixtures without connecting to a database")
+
+	var sets overrides
+
+	fs.Var(&sets, "set", "configuration override, of the form key=value")
+
+	_ = fs.Parse(os.Args[1:])
+
+	args := fs.Args()
+
+	if len(args) > 0 && args[0] == "version" {
 		fmt.Println("apigo", svc.Version())
 
 		os.Exit(0)
 	}
 
-	if len(os.Args) > 1 && os.Args[1] == "migrate" {
-		if err := svc.Migrate(ctx); err != nil {
+	if err := svc.LoadConfig(*file, sets); err != nil {
+		slog.Error("configuration error", "error", err)
+
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrate(ctx, svc, args[1:]); err != nil {
 			slog.Error("migrate error", "error", err)
 
 			os.Exit(1)
@@ -34,6 +82,14 @@ func main() {
 		os.Exit(0)
 	}
 
+	if len(args) > 0 {
+		slog.Error("unknown command", "command", args[0])
+
+		os.Exit(1)
+	}
+
+	svc.SetSandbox(*sandbox)
+
 	errCh := make(chan error, 1)
 
 	go func(ctx context.Context, errCh chan error) {
@@ -51,12 +107,79 @@ func main() {
 		syscall.SIGQUIT,
 		os.Interrupt)
 
-	select {
-	case <-ch:
-		svc.Close(ctx)
-	case err := <-errCh:
-		slog.Error("server error", "error", err)
+	for {
+		select {
+		case sig := <-ch:
+			if sig == syscall.SIGHUP {
+				if err := svc.Reload(ctx); err != nil {
+					slog.Error("reload error", "error", err)
+				}
 
-		os.Exit(1)
+				continue
+			}
+
+			svc.Close(ctx)
+
+			return
+		case err := <-errCh:
+			slog.Error("server error", "error", err)
+
+			os.Exit(1)
+		}
+	}
+}
+
+// migrate runs a database migration command, parsed from the arguments:
+//
+//	apigo migrate [up|down|status] [--to version] [--wait] [--timeout duration]
+//	apigo migrate --check-compat [--to version] [--deployed version]
+//
+// Without a command, migrations are applied up to the current version. If
+// another instance is applying migrations, the command fails, unless --wait is
+// given, in which case it waits for the other instance to finish. With
+// --check-compat, no migrations are applied, the command fails if applying
+// them would break the deployed instances.
+func migrate(ctx context.Context, svc *apigo.Service, args []string) error {
+	opts := &migrations.Options{Command: migrations.CommandUp}
+
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		opts.Command = args[0]
+
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+
+	fs.IntVar(&opts.To, "to", -1, "schema version to migrate up, or down, to")
+	fs.BoolVar(&opts.Wait, "wait", false,
+		"wait for migrations run by another instance to finish")
+	fs.DurationVar(&opts.Timeout, "timeout", 0,
+		"maximum time to wait for another instance, if positive")
+	fs.IntVar(&opts.Deployed, "deployed", -1,
+		"schema version deployed instances are built for, checked against")
+
+	check := fs.Bool("check-compat", false,
+		"check migrations are compatible with deployed instances")
+
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
+
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected migrate arguments: %v", fs.Args())
+	}
+
+	if *check {
+		opts.Command = migrations.CommandCheck
+	}
+
+	res, err := svc.RunMigration(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("version: %d, dirty: %v, current: %d\n",
+		res.Version, res.Dirty, res.Current)
+
+	return nil
 }
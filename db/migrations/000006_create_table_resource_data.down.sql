@@ -0,0 +1,13 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS resource ADD COLUMN IF NOT EXISTS data JSONB;
+
+UPDATE resource SET data = (
+    SELECT JSONB_OBJECT_AGG(resource_data.data_key, resource_data.data)
+    FROM resource_data
+    WHERE resource_data.account_id = resource.account_id
+        AND resource_data.resource_key = resource.resource_key);
+
+DROP TABLE IF EXISTS resource_data;
+
+COMMIT;
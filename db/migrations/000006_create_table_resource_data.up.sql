@@ -0,0 +1,32 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS resource_data (
+    account_id TEXT NOT NULL DEFAULT current_setting('app.account_id')::TEXT,
+    resource_key BIGINT NOT NULL,
+    FOREIGN KEY (account_id, resource_key)
+        REFERENCES resource (account_id, resource_key) ON DELETE CASCADE,
+    data_key TEXT NOT NULL,
+    PRIMARY KEY (account_id, resource_key, data_key),
+    data JSONB NOT NULL,
+    ts TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS resource_data_resource_key_ts_idx
+    ON resource_data (resource_key, ts);
+
+ALTER TABLE IF EXISTS resource_data ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON resource_data
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+INSERT INTO resource_data (account_id, resource_key, data_key, data, ts)
+SELECT resource.account_id, resource.resource_key, item.key, item.value,
+    CASE WHEN item.value->>'ts' ~ '^[0-9]+(\.[0-9]+)?$'
+        THEN TO_TIMESTAMP((item.value->>'ts')::DOUBLE PRECISION)
+        ELSE CURRENT_TIMESTAMP END
+FROM resource, JSONB_EACH(resource.data) AS item
+WHERE JSONB_TYPEOF(resource.data) = 'object';
+
+ALTER TABLE IF EXISTS resource DROP COLUMN IF EXISTS data;
+
+COMMIT;
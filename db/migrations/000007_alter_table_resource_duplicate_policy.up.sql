@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS resource
+    ADD COLUMN IF NOT EXISTS duplicate_policy TEXT NOT NULL DEFAULT 'last';
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS resource DROP COLUMN IF EXISTS key_strategy;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS resource
+    ADD COLUMN IF NOT EXISTS key_strategy TEXT NOT NULL DEFAULT 'field';
+
+COMMIT;
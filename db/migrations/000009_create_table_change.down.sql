@@ -0,0 +1,13 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS resource_change_trigger ON resource;
+
+DROP TRIGGER IF EXISTS account_change_trigger ON account;
+
+DROP FUNCTION IF EXISTS record_change;
+
+DROP TABLE IF EXISTS change;
+
+DROP SEQUENCE IF EXISTS change_key_seq;
+
+COMMIT;
@@ -0,0 +1,55 @@
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS change_key_seq;
+
+CREATE TABLE IF NOT EXISTS change (
+    account_id TEXT NOT NULL DEFAULT current_setting('app.account_id')::TEXT,
+    change_key BIGINT NOT NULL DEFAULT nextval('change_key_seq') UNIQUE,
+    PRIMARY KEY (account_id, change_key),
+    txid XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    entity_type TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    operation TEXT NOT NULL,
+    ts TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS change_account_id_txid_change_key_idx
+    ON change (account_id, txid, change_key);
+
+ALTER TABLE IF EXISTS change ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON change
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+CREATE OR REPLACE FUNCTION record_change() RETURNS TRIGGER
+    LANGUAGE plpgsql SECURITY DEFINER SET search_path = public AS $$
+DECLARE
+    rec JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := to_jsonb(OLD);
+    ELSE
+        rec := to_jsonb(NEW);
+    END IF;
+
+    INSERT INTO change (account_id, entity_type, entity_id, operation)
+    VALUES (rec->>'account_id', TG_ARGV[0], rec->>TG_ARGV[1],
+        CASE TG_OP
+            WHEN 'INSERT' THEN 'create'
+            WHEN 'UPDATE' THEN 'update'
+            ELSE 'delete'
+        END);
+
+    RETURN NULL;
+END;
+$$;
+
+CREATE TRIGGER account_change_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON account
+    FOR EACH ROW EXECUTE FUNCTION record_change('account', 'account_id');
+
+CREATE TRIGGER resource_change_trigger
+    AFTER INSERT OR UPDATE OR DELETE ON resource
+    FOR EACH ROW EXECUTE FUNCTION record_change('resource', 'resource_id');
+
+COMMIT;
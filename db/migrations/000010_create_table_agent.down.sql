@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS agent;
+
+DROP SEQUENCE IF EXISTS agent_key_seq;
+
+COMMIT;
@@ -0,0 +1,35 @@
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS agent_key_seq;
+
+CREATE TABLE IF NOT EXISTS agent (
+    account_id TEXT NOT NULL DEFAULT current_setting('app.account_id')::TEXT,
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    agent_key BIGINT NOT NULL DEFAULT nextval('agent_key_seq') UNIQUE,
+    PRIMARY KEY (account_id, agent_key),
+    agent_id TEXT NOT NULL,
+    UNIQUE (account_id, agent_id),
+    name TEXT NOT NULL,
+    version TEXT,
+    status TEXT NOT NULL DEFAULT 'active',
+    status_data JSONB,
+    resources TEXT[] NOT NULL DEFAULT '{}',
+    data JSONB,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by BIGINT,
+    FOREIGN KEY (created_by) REFERENCES "user" (user_key) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by BIGINT,
+    FOREIGN KEY (updated_by) REFERENCES "user" (user_key) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS agent_resources_idx
+    ON agent USING GIN (resources);
+
+ALTER TABLE IF EXISTS agent ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON agent
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS agent
+    DROP COLUMN IF EXISTS config_version,
+    DROP COLUMN IF EXISTS config;
+
+COMMIT;
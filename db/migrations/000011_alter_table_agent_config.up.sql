@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS agent
+    ADD COLUMN IF NOT EXISTS config_version TEXT,
+    ADD COLUMN IF NOT EXISTS config JSONB;
+
+COMMIT;
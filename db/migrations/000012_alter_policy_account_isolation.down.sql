@@ -0,0 +1,45 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS agent
+    ALTER COLUMN account_id SET DEFAULT current_setting('app.account_id')::TEXT;
+
+ALTER TABLE IF EXISTS change
+    ALTER COLUMN account_id SET DEFAULT current_setting('app.account_id')::TEXT;
+
+ALTER TABLE IF EXISTS resource
+    ALTER COLUMN account_id SET DEFAULT current_setting('app.account_id')::TEXT;
+
+ALTER TABLE IF EXISTS resource_data
+    ALTER COLUMN account_id SET DEFAULT current_setting('app.account_id')::TEXT;
+
+ALTER TABLE IF EXISTS tag
+    ALTER COLUMN account_id SET DEFAULT current_setting('app.account_id')::TEXT;
+
+ALTER TABLE IF EXISTS tag_obj
+    ALTER COLUMN account_id SET DEFAULT current_setting('app.account_id')::TEXT;
+
+ALTER POLICY account_isolation_policy ON account
+    USING (current_setting('app.account_id')::TEXT = 'sys' OR
+        account_id = current_setting('app.account_id')::TEXT);
+
+ALTER POLICY account_isolation_policy ON agent
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+ALTER POLICY account_isolation_policy ON change
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+ALTER POLICY account_isolation_policy ON resource
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+ALTER POLICY account_isolation_policy ON resource_data
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+ALTER POLICY account_isolation_policy ON tag
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+ALTER POLICY account_isolation_policy ON tag_obj
+    USING (account_id = current_setting('app.account_id')::TEXT);
+
+DROP FUNCTION IF EXISTS app_account_id();
+
+COMMIT;
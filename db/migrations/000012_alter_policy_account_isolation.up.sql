@@ -0,0 +1,57 @@
+BEGIN;
+
+CREATE OR REPLACE FUNCTION app_account_id() RETURNS TEXT
+    LANGUAGE plpgsql STABLE
+    AS $$
+DECLARE
+    id TEXT := current_setting('app.account_id', true);
+BEGIN
+    IF id IS NULL OR id = '' THEN
+        RAISE EXCEPTION 'missing account, "app.account_id" is not set'
+            USING ERRCODE = 'insufficient_privilege';
+    END IF;
+
+    RETURN id;
+END;
+$$;
+
+ALTER POLICY account_isolation_policy ON account
+    USING (app_account_id() = 'sys' OR account_id = app_account_id());
+
+ALTER POLICY account_isolation_policy ON agent
+    USING (account_id = app_account_id());
+
+ALTER POLICY account_isolation_policy ON change
+    USING (account_id = app_account_id());
+
+ALTER POLICY account_isolation_policy ON resource
+    USING (account_id = app_account_id());
+
+ALTER POLICY account_isolation_policy ON resource_data
+    USING (account_id = app_account_id());
+
+ALTER POLICY account_isolation_policy ON tag
+    USING (account_id = app_account_id());
+
+ALTER POLICY account_isolation_policy ON tag_obj
+    USING (account_id = app_account_id());
+
+ALTER TABLE IF EXISTS agent
+    ALTER COLUMN account_id SET DEFAULT app_account_id();
+
+ALTER TABLE IF EXISTS change
+    ALTER COLUMN account_id SET DEFAULT app_account_id();
+
+ALTER TABLE IF EXISTS resource
+    ALTER COLUMN account_id SET DEFAULT app_account_id();
+
+ALTER TABLE IF EXISTS resource_data
+    ALTER COLUMN account_id SET DEFAULT app_account_id();
+
+ALTER TABLE IF EXISTS tag
+    ALTER COLUMN account_id SET DEFAULT app_account_id();
+
+ALTER TABLE IF EXISTS tag_obj
+    ALTER COLUMN account_id SET DEFAULT app_account_id();
+
+COMMIT;
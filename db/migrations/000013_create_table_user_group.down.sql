@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS user_group;
+
+DROP SEQUENCE IF EXISTS user_group_key_seq;
+
+COMMIT;
@@ -0,0 +1,34 @@
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS user_group_key_seq;
+
+CREATE TABLE IF NOT EXISTS user_group (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    group_key BIGINT NOT NULL DEFAULT nextval('user_group_key_seq') UNIQUE,
+    PRIMARY KEY (account_id, group_key),
+    group_id TEXT NOT NULL,
+    UNIQUE (account_id, group_id),
+    name TEXT NOT NULL,
+    description TEXT,
+    status TEXT NOT NULL DEFAULT 'active',
+    scopes TEXT NOT NULL DEFAULT '',
+    users TEXT[] NOT NULL DEFAULT '{}',
+    data JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by BIGINT,
+    FOREIGN KEY (created_by) REFERENCES "user" (user_key) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by BIGINT,
+    FOREIGN KEY (updated_by) REFERENCES "user" (user_key) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_group_users_idx
+    ON user_group USING GIN (users);
+
+ALTER TABLE IF EXISTS user_group ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON user_group
+    USING (account_id = app_account_id());
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS resource_acl;
+
+DROP SEQUENCE IF EXISTS resource_acl_key_seq;
+
+COMMIT;
@@ -0,0 +1,34 @@
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS resource_acl_key_seq;
+
+CREATE TABLE IF NOT EXISTS resource_acl (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    resource_acl_key BIGINT NOT NULL DEFAULT nextval('resource_acl_key_seq')
+        UNIQUE,
+    PRIMARY KEY (account_id, resource_acl_key),
+    resource_id UUID NOT NULL,
+    FOREIGN KEY (account_id, resource_id)
+        REFERENCES resource (account_id, resource_id) ON DELETE CASCADE,
+    principal_type TEXT NOT NULL,
+    principal_id TEXT NOT NULL,
+    CONSTRAINT resource_acl_principal_key
+        UNIQUE (account_id, resource_id, principal_type, principal_id),
+    permission TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by BIGINT,
+    FOREIGN KEY (created_by) REFERENCES "user" (user_key) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by BIGINT,
+    FOREIGN KEY (updated_by) REFERENCES "user" (user_key) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS resource_acl_principal_idx
+    ON resource_acl (principal_type, principal_id);
+
+ALTER TABLE IF EXISTS resource_acl ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON resource_acl
+    USING (account_id = app_account_id());
+
+COMMIT;
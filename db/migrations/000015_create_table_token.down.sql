@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS token;
+
+DROP SEQUENCE IF EXISTS token_key_seq;
+
+COMMIT;
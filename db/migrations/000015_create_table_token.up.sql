@@ -0,0 +1,28 @@
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS token_key_seq;
+
+CREATE TABLE IF NOT EXISTS token (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    token_key BIGINT NOT NULL DEFAULT nextval('token_key_seq') UNIQUE,
+    PRIMARY KEY (account_id, token_key),
+    token_id UUID NOT NULL,
+    UNIQUE (account_id, token_id),
+    user_id TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    restricted BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    use_count BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS token_user_id_idx ON token (user_id);
+
+ALTER TABLE IF EXISTS token ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON token
+    USING (account_id = app_account_id());
+
+COMMIT;
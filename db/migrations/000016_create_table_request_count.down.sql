@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS request_key;
+
+DROP TABLE IF EXISTS request_count;
+
+COMMIT;
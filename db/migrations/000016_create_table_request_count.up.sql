@@ -0,0 +1,36 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS request_count (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    operation TEXT NOT NULL,
+    PRIMARY KEY (account_id, day, operation),
+    requests BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE IF EXISTS request_count ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON request_count
+    USING (account_id = app_account_id());
+
+CREATE TABLE IF NOT EXISTS request_key (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    idempotency_key TEXT NOT NULL,
+    PRIMARY KEY (account_id, idempotency_key),
+    day DATE NOT NULL,
+    operation TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS request_key_created_at_idx
+    ON request_key (created_at);
+
+ALTER TABLE IF EXISTS request_key ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON request_key
+    USING (account_id = app_account_id());
+
+COMMIT;
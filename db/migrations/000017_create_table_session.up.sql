@@ -0,0 +1,24 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS session (
+    session_id TEXT NOT NULL PRIMARY KEY,
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    tenant TEXT NOT NULL DEFAULT '',
+    token TEXT NOT NULL,
+    token_expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    csrf_token TEXT NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS session_account_id_idx ON session (account_id);
+
+ALTER TABLE IF EXISTS session ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON session
+    USING (app_account_id() = 'sys' OR account_id = app_account_id());
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS "user"
+    DROP COLUMN IF EXISTS failed_logins,
+    DROP COLUMN IF EXISTS locked_until;
+
+COMMIT;
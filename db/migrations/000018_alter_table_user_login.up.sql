@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS "user"
+    ADD COLUMN IF NOT EXISTS failed_logins INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS locked_until TIMESTAMPTZ;
+
+COMMIT;
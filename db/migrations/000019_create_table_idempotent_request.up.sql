@@ -0,0 +1,25 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS idempotent_request (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    idempotency_key TEXT NOT NULL,
+    PRIMARY KEY (account_id, idempotency_key),
+    operation TEXT NOT NULL,
+    request_hash TEXT NOT NULL,
+    status INT NOT NULL DEFAULT 0,
+    headers JSONB NOT NULL DEFAULT '{}',
+    response BYTEA,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotent_request_expires_at_idx
+    ON idempotent_request (expires_at);
+
+ALTER TABLE IF EXISTS idempotent_request ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON idempotent_request
+    USING (account_id = app_account_id());
+
+COMMIT;
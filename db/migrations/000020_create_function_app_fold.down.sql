@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP FUNCTION IF EXISTS app_fold(TEXT);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE EXTENSION IF NOT EXISTS unaccent WITH SCHEMA public;
+
+CREATE OR REPLACE FUNCTION app_fold(value TEXT) RETURNS TEXT
+    LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE
+    AS $$
+    SELECT LOWER(public.unaccent('public.unaccent'::REGDICTIONARY, value));
+$$;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS account_key (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    key_id TEXT NOT NULL,
+    PRIMARY KEY (account_id, key_id),
+    secret TEXT NOT NULL DEFAULT gen_random_uuid(),
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS account_key_expires_at_idx
+    ON account_key (expires_at);
+
+ALTER TABLE IF EXISTS account_key ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON account_key
+    USING (app_account_id() = 'sys' OR account_id = app_account_id());
+
+COMMIT;
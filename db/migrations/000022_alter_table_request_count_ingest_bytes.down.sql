@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS request_count
+    DROP COLUMN IF EXISTS ingest_bytes;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS request_count
+    ADD COLUMN IF NOT EXISTS ingest_bytes BIGINT NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS resource_depends_on_idx;
+
+ALTER TABLE IF EXISTS resource
+    DROP COLUMN IF EXISTS depends_on;
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS resource
+    ADD COLUMN IF NOT EXISTS depends_on TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS resource_depends_on_idx
+    ON resource USING GIN (depends_on);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS resource_revision_trigger ON resource;
+
+DROP FUNCTION IF EXISTS record_resource_revision;
+
+DROP FUNCTION IF EXISTS resource_definition;
+
+DROP TABLE IF EXISTS resource_revision;
+
+COMMIT;
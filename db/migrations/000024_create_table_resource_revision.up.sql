@@ -0,0 +1,72 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS resource_revision (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    resource_id UUID NOT NULL,
+    revision BIGINT NOT NULL,
+    PRIMARY KEY (account_id, resource_id, revision),
+    FOREIGN KEY (account_id, resource_id)
+        REFERENCES resource (account_id, resource_id)
+        ON UPDATE CASCADE ON DELETE CASCADE,
+    definition JSONB NOT NULL,
+    version TEXT,
+    source TEXT,
+    commit_hash TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by BIGINT,
+    FOREIGN KEY (created_by) REFERENCES "user" (user_key) ON DELETE SET NULL
+);
+
+ALTER TABLE IF EXISTS resource_revision ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON resource_revision
+    USING (account_id = app_account_id());
+
+CREATE OR REPLACE FUNCTION resource_definition(r resource) RETURNS JSONB
+    LANGUAGE sql IMMUTABLE AS $$
+    SELECT JSONB_BUILD_OBJECT(
+        'name', r.name,
+        'description', r.description,
+        'key_field', r.key_field,
+        'key_regex', r.key_regex,
+        'clear_condition', r.clear_condition,
+        'clear_after', r.clear_after,
+        'clear_delay', r.clear_delay,
+        'duplicate_policy', r.duplicate_policy,
+        'key_strategy', r.key_strategy,
+        'depends_on', r.depends_on);
+$$;
+
+CREATE OR REPLACE FUNCTION record_resource_revision() RETURNS TRIGGER
+    LANGUAGE plpgsql SECURITY DEFINER SET search_path = public AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND
+        resource_definition(OLD) = resource_definition(NEW) THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO resource_revision (account_id, resource_id, revision,
+        definition, version, source, commit_hash, created_by)
+    VALUES (NEW.account_id, NEW.resource_id,
+        COALESCE((SELECT MAX(resource_revision.revision)
+            FROM resource_revision
+            WHERE resource_revision.account_id = NEW.account_id
+                AND resource_revision.resource_id = NEW.resource_id), 0) + 1,
+        resource_definition(NEW), NEW.version, NEW.source, NEW.commit_hash,
+        NEW.updated_by);
+
+    RETURN NULL;
+END;
+$$;
+
+INSERT INTO resource_revision (account_id, resource_id, revision, definition,
+    version, source, commit_hash, created_at, created_by)
+SELECT account_id, resource_id, 1, resource_definition(resource), version,
+    source, commit_hash, updated_at, updated_by
+FROM resource;
+
+CREATE TRIGGER resource_revision_trigger
+    AFTER INSERT OR UPDATE ON resource
+    FOR EACH ROW EXECUTE FUNCTION record_resource_revision();
+
+COMMIT;
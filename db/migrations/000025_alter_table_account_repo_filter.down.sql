@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS account
+    DROP COLUMN IF EXISTS repo_filter;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS account
+    ADD COLUMN IF NOT EXISTS repo_filter JSONB;
+
+COMMIT;
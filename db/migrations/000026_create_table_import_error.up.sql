@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS import_error (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    path TEXT NOT NULL,
+    PRIMARY KEY (account_id, path),
+    error TEXT NOT NULL,
+    commit_hash TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE IF EXISTS import_error ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON import_error
+    USING (account_id = app_account_id());
+
+COMMIT;
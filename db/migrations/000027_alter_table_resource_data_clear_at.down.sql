@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS resource_data_clear_at_idx;
+
+ALTER TABLE IF EXISTS resource_data
+    DROP COLUMN IF EXISTS clear_at;
+
+COMMIT;
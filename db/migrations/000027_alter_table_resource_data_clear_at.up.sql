@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE IF EXISTS resource_data
+    ADD COLUMN IF NOT EXISTS clear_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS resource_data_clear_at_idx
+    ON resource_data (clear_at) WHERE clear_at IS NOT NULL;
+
+COMMIT;
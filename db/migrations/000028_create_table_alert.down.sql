@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP TABLE IF EXISTS alert;
+
+DROP TABLE IF EXISTS alert_rule;
+
+DROP SEQUENCE IF EXISTS alert_rule_key_seq;
+
+COMMIT;
@@ -0,0 +1,56 @@
+BEGIN;
+
+CREATE SEQUENCE IF NOT EXISTS alert_rule_key_seq;
+
+CREATE TABLE IF NOT EXISTS alert_rule (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    FOREIGN KEY (account_id) REFERENCES account (account_id) ON DELETE CASCADE,
+    alert_rule_key BIGINT NOT NULL DEFAULT nextval('alert_rule_key_seq') UNIQUE,
+    PRIMARY KEY (account_id, alert_rule_key),
+    alert_rule_id UUID NOT NULL,
+    UNIQUE (account_id, alert_rule_id),
+    name TEXT NOT NULL,
+    description TEXT,
+    search TEXT NOT NULL,
+    duration BIGINT NOT NULL DEFAULT 0,
+    channels TEXT[] NOT NULL DEFAULT '{}',
+    status TEXT NOT NULL DEFAULT 'active',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by BIGINT,
+    FOREIGN KEY (created_by) REFERENCES "user" (user_key) ON DELETE SET NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by BIGINT,
+    FOREIGN KEY (updated_by) REFERENCES "user" (user_key) ON DELETE SET NULL
+);
+
+ALTER TABLE IF EXISTS alert_rule ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON alert_rule
+    USING (account_id = app_account_id());
+
+CREATE TABLE IF NOT EXISTS alert (
+    account_id TEXT NOT NULL DEFAULT app_account_id(),
+    alert_rule_id UUID NOT NULL,
+    FOREIGN KEY (account_id, alert_rule_id)
+        REFERENCES alert_rule (account_id, alert_rule_id)
+        ON UPDATE CASCADE ON DELETE CASCADE,
+    resource_id UUID NOT NULL,
+    FOREIGN KEY (account_id, resource_id)
+        REFERENCES resource (account_id, resource_id)
+        ON UPDATE CASCADE ON DELETE CASCADE,
+    PRIMARY KEY (account_id, alert_rule_id, resource_id),
+    state TEXT NOT NULL DEFAULT 'pending',
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    fired_at TIMESTAMP WITH TIME ZONE,
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS alert_resource_id_idx
+    ON alert (account_id, resource_id);
+
+ALTER TABLE IF EXISTS alert ENABLE ROW LEVEL SECURITY;
+
+CREATE POLICY account_isolation_policy ON alert
+    USING (account_id = app_account_id());
+
+COMMIT;
@@ -2,6 +2,7 @@
 package migrations
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"database/sql"
@@ -9,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -27,9 +29,57 @@ import (
 
 // Database schema version.
 const (
-	CurrentVersion = 5
+	CurrentVersion = 28
 )
 
+// Migration commands.
+const (
+	CommandUp     = "up"
+	CommandDown   = "down"
+	CommandStatus = "status"
+	CommandCheck  = "check"
+)
+
+// compatPrefix begins the comment, at the start of an up migration, giving the
+// earliest schema version for which service instances remain compatible with
+// the database once the migration is applied. Migrations without it expand the
+// schema, and are compatible with all instances. Migrations contracting the
+// schema, such as by dropping a column used by earlier releases, must declare
+// the version from which releases no longer use what is removed.
+const compatPrefix = "-- compatible_from:"
+
+// lockKey is the key of the advisory lock held while migrations are applied,
+// so that concurrent migrators, such as several service instances starting at
+// once, apply them one at a time.
+const lockKey = int64(0x617069676f)
+
+// lockInterval is the interval at which a migrator waiting for the migration
+// lock attempts to acquire it.
+const lockInterval = time.Second
+
+// Options values contain the options of a migration command. To is the schema
+// version to migrate to. If it is negative, up migrates to the CurrentVersion,
+// and down migrates down a single version. If Wait is true, the command waits
+// for any other migrator holding the migration lock to finish, for up to
+// Timeout, if it is positive, otherwise the command fails immediately. Deployed
+// is the schema version which the deployed service instances were built for,
+// used by the check command. If it is negative, the schema version of the
+// database is used.
+type Options struct {
+	Command  string        `json:"command"`
+	To       int           `json:"to"`
+	Wait     bool          `json:"wait"`
+	Timeout  time.Duration `json:"timeout"`
+	Deployed int           `json:"deployed"`
+}
+
+// Status values report the schema version of the database.
+type Status struct {
+	Version uint `json:"version"`
+	Dirty   bool `json:"dirty"`
+	Current uint `json:"current"`
+}
+
 // mfs is a file system containing the database migrations.
 //
 //go:embed *.sql
@@ -37,10 +87,41 @@ var mfs embed.FS
 
 // Migrate executes the required database migrations.
 func Migrate(cfg *config.Config, log logger.Logger) error {
+	_, err := Run(cfg, log, &Options{Command: CommandUp, To: -1, Wait: true})
+
+	return err
+}
+
+// Run executes a migration command, and returns the resulting schema version
+// of the database.
+func Run(cfg *config.Config,
+	log logger.Logger,
+	opts *Options,
+) (*Status, error) {
 	ctx := context.Background()
 
+	if opts == nil {
+		opts = &Options{Command: CommandUp, To: -1, Wait: true}
+	}
+
+	switch opts.Command {
+	case CommandUp, CommandDown, CommandStatus, CommandCheck:
+	default:
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid migration command",
+			"command", opts.Command)
+	}
+
+	if opts.To > CurrentVersion {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid migration version",
+			"to", opts.To,
+			"current_version", CurrentVersion)
+	}
+
 	log.Log(ctx, logger.LvlInfo,
-		"applying database migrations...")
+		"running database migrations...",
+		"command", opts.Command)
 
 	defer func() {
 		if quit := os.Getenv("DB_SIDECAR_QUIT"); quit != "" {
@@ -58,10 +139,12 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 	sc.SetMode(config.DBModeMigrate)
 
 	if err := sc.Connect(ctx); err != nil {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to connect to SQL database")
 	}
 
+	defer sc.Close()
+
 	log.Log(ctx, logger.LvlInfo,
 		"checking database connection...")
 
@@ -77,7 +160,7 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 			isc.SetMode(config.DBModeInit)
 
 			if err := isc.Connect(ctx); err != nil {
-				return errors.Wrap(err, errors.ErrDatabase,
+				return nil, errors.Wrap(err, errors.ErrDatabase,
 					"unable to connect to SQL database for initialization")
 			}
 
@@ -88,7 +171,7 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 				`CREATE DATABASE "`+cfg.DBDatabase()+`" WITH OWNER="`+
 					cfg.DBMigrateUser()+`"`,
 			); err != nil {
-				return errors.Wrap(err, errors.ErrDatabase,
+				return nil, errors.Wrap(err, errors.ErrDatabase,
 					"unable to create database")
 			}
 
@@ -107,10 +190,19 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 	}
 
 	if err != nil {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to ping database")
 	}
 
+	if opts.Command != CommandStatus && opts.Command != CommandCheck {
+		unlock, err := lock(ctx, sc, opts.Wait, opts.Timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		defer unlock()
+	}
+
 	mp := cfg.DBMigrations()
 
 	var source source.Driver
@@ -125,7 +217,7 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 
 		source, err = gh.Open(mp)
 		if err != nil {
-			return errors.Wrap(err, errors.ErrServer,
+			return nil, errors.Wrap(err, errors.ErrServer,
 				"unable to initialize migrations github source")
 		}
 
@@ -138,7 +230,7 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 
 		source, err = bb.Open(mp)
 		if err != nil {
-			return errors.Wrap(err, errors.ErrServer,
+			return nil, errors.Wrap(err, errors.ErrServer,
 				"unable to initialize migrations bitbucket source")
 		}
 
@@ -149,7 +241,7 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 
 		source, err = iofs.New(mfs, ".")
 		if err != nil {
-			return errors.Wrap(err, errors.ErrServer,
+			return nil, errors.Wrap(err, errors.ErrServer,
 				"unable to initialize migrations file source")
 		}
 	}
@@ -160,14 +252,14 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 	driver, err := pgx.WithInstance(sql.OpenDB(
 		stdlib.GetPoolConnector(sc.Pool())), &pgx.Config{})
 	if err != nil {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to initialize database migration driver")
 	}
 
 	m, err := migrate.NewWithInstance(sourceName, source,
 		"postgres", driver)
 	if err != nil {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to create database migration")
 	}
 
@@ -175,13 +267,75 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 
 	ver, dirty, err := m.Version()
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to get database schema version")
 	}
 
+	if opts.Command == CommandStatus {
+		return &Status{Version: ver, Dirty: dirty, Current: CurrentVersion},
+			nil
+	}
+
+	if opts.Command == CommandCheck {
+		to := uint(CurrentVersion)
+
+		if opts.To >= 0 {
+			to = uint(opts.To)
+		}
+
+		deployed := ver
+
+		if opts.Deployed >= 0 {
+			deployed = uint(opts.Deployed)
+		}
+
+		if err := checkCompat(source, ver, to, deployed); err != nil {
+			return nil, err
+		}
+
+		return &Status{Version: ver, Dirty: dirty, Current: CurrentVersion},
+			nil
+	}
+
 	if dirty {
-		return errors.Wrap(err, errors.ErrDatabase,
-			"unable to migrate database after failed migration")
+		return nil, errors.New(errors.ErrDatabase,
+			"unable to migrate database after failed migration",
+			"version", ver)
+	}
+
+	if opts.Command == CommandDown {
+		if err := down(m, ver, opts.To); err != nil {
+			return nil, err
+		}
+
+		return status(m)
+	}
+
+	// Schema versions only move forward when migrating up. A database already
+	// migrated beyond the current version, by a newer release, such as during
+	// a rolling deploy, is left unchanged.
+	if opts.To < 0 && ver > CurrentVersion {
+		log.Log(ctx, logger.LvlWarn,
+			"database schema version is newer than the current version, "+
+				"skipping migration",
+			"version", ver,
+			"current_version", CurrentVersion)
+
+		return status(m)
+	}
+
+	to := uint(CurrentVersion)
+
+	if opts.To >= 0 {
+		to = uint(opts.To)
+	}
+
+	if to < ver {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"migration version is below the database schema version, "+
+				"use down to revert migrations",
+			"to", to,
+			"version", ver)
 	}
 
 	if ver <= 1 || err != nil {
@@ -191,7 +345,7 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 		if _, err := sc.ExecNoTx(ctx,
 			`CREATE USER "`+cfg.DBUser()+`" WITH PASSWORD NULL`,
 		); err != nil {
-			return errors.Wrap(err, errors.ErrDatabase,
+			return nil, errors.Wrap(err, errors.ErrDatabase,
 				"unable to create database user")
 		}
 
@@ -199,7 +353,7 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 			if _, err := sc.ExecNoTx(ctx,
 				`ALTER USER "`+cfg.DBUser()+`" WITH PASSWORD '`+
 					password+`'`); err != nil {
-				return errors.Wrap(err, errors.ErrDatabase,
+				return nil, errors.Wrap(err, errors.ErrDatabase,
 					"unable to set database user password")
 			}
 		}
@@ -207,14 +361,14 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 		if _, err := sc.ExecNoTx(ctx,
 			`GRANT CONNECT ON DATABASE "`+cfg.DBDatabase()+
 				`" TO "`+cfg.DBUser()+`"`); err != nil {
-			return errors.Wrap(err, errors.ErrDatabase,
+			return nil, errors.Wrap(err, errors.ErrDatabase,
 				"unable to grant permissions to database user")
 		}
 	}
 
-	if err := m.Migrate(CurrentVersion); err != nil &&
+	if err := m.Migrate(to); err != nil &&
 		!errors.Is(err, migrate.ErrNoChange) {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to complete database migration")
 	}
 
@@ -224,20 +378,232 @@ func Migrate(cfg *config.Config, log logger.Logger) error {
 	if _, err := sc.ExecNoTx(ctx,
 		`GRANT ALL PRIVILEGES ON ALL SEQUENCES IN SCHEMA "public" TO "`+
 			cfg.DBUser()+`"`); err != nil {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to grant database user sequence privileges")
 	}
 
 	if _, err := sc.ExecNoTx(ctx,
 		`GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA "public" TO "`+
 			cfg.DBUser()+`"`); err != nil {
-		return errors.Wrap(err, errors.ErrDatabase,
+		return nil, errors.Wrap(err, errors.ErrDatabase,
 			"unable to grant database user table privileges")
 	}
 
+	return status(m)
+}
+
+// lock acquires the migration advisory lock on a dedicated connection. If the
+// lock is held by another migrator, and wait is true, it waits for the lock to
+// be released, for up to timeout, if it is positive, otherwise it fails with a
+// conflict error. It returns a function releasing the lock.
+func lock(ctx context.Context,
+	sc *sqldb.SQLConn,
+	wait bool,
+	timeout time.Duration,
+) (func(), error) {
+	conn, err := sc.Pool().Acquire(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to acquire migration lock connection")
+	}
+
+	sc.LogInfof(ctx, "Migrate", "acquiring migration lock...")
+
+	lockCtx := ctx
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+
+		lockCtx, cancel = context.WithTimeout(ctx, timeout)
+
+		defer cancel()
+	}
+
+	for {
+		locked := false
+
+		if err := conn.QueryRow(lockCtx, "SELECT pg_try_advisory_lock($1)",
+			lockKey).Scan(&locked); err != nil {
+			conn.Release()
+
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to acquire migration lock")
+		}
+
+		if locked {
+			break
+		}
+
+		if !wait {
+			conn.Release()
+
+			return nil, errors.New(errors.ErrConflict,
+				"database migration lock is held by another migrator")
+		}
+
+		sc.LogInfof(ctx, "Migrate",
+			"waiting for migration lock held by another migrator...")
+
+		select {
+		case <-lockCtx.Done():
+			conn.Release()
+
+			return nil, errors.New(errors.ErrContextTimeout,
+				"timed out waiting for database migration lock",
+				"timeout", timeout.String())
+		case <-time.After(lockInterval):
+		}
+	}
+
+	return func() {
+		if _, err := conn.Exec(context.Background(),
+			"SELECT pg_advisory_unlock($1)", lockKey); err != nil {
+			sc.LogErrorf(context.Background(), "Migrate", err,
+				"unable to release migration lock")
+		}
+
+		conn.Release()
+	}, nil
+}
+
+// checkCompat verifies that applying the migrations above a schema version, up
+// to the target version, will not break service instances built for the
+// deployed schema version. Following the expand and contract pattern, a
+// contracting migration may only be applied once all deployed instances are
+// built for a version at, or after, the version it declares compatibility from.
+func checkCompat(src source.Driver, ver, to, deployed uint) error {
+	if to < ver {
+		return errors.New(errors.ErrInvalidRequest,
+			"migration version is below the database schema version",
+			"to", to,
+			"version", ver)
+	}
+
+	v, err := src.First()
+
+	for ; err == nil && v <= to; v, err = src.Next(v) {
+		if v <= ver {
+			continue
+		}
+
+		from, err := compatibleFrom(src, v)
+		if err != nil {
+			return err
+		}
+
+		if deployed < from {
+			return errors.New(errors.ErrConflict,
+				"migration would break deployed service instances",
+				"version", v,
+				"compatible_from", from,
+				"deployed", deployed)
+		}
+	}
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to read database migrations")
+	}
+
 	return nil
 }
 
+// compatibleFrom returns the earliest schema version for which service
+// instances remain compatible once a migration is applied, as declared in the
+// comments at the start of its up migration, or zero if it is not declared.
+func compatibleFrom(src source.Driver, v uint) (uint, error) {
+	r, _, err := src.ReadUp(v)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+
+		return 0, errors.Wrap(err, errors.ErrDatabase,
+			"unable to read database migration",
+			"version", v)
+	}
+
+	defer r.Close()
+
+	sc := bufio.NewScanner(r)
+
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		if !strings.HasPrefix(line, compatPrefix) {
+			continue
+		}
+
+		from, err := strconv.ParseUint(strings.TrimSpace(
+			strings.TrimPrefix(line, compatPrefix)), 10, 64)
+		if err != nil {
+			return 0, errors.Wrap(err, errors.ErrDatabase,
+				"invalid database migration compatibility version",
+				"version", v,
+				"line", line)
+		}
+
+		return uint(from), nil
+	}
+
+	if err := sc.Err(); err != nil {
+		return 0, errors.Wrap(err, errors.ErrDatabase,
+			"unable to read database migration",
+			"version", v)
+	}
+
+	return 0, nil
+}
+
+// down reverts migrations down to a schema version. If the version is negative,
+// only the most recently applied migration is reverted.
+func down(m *migrate.Migrate, ver uint, to int) error {
+	var err error
+
+	switch {
+	case to < 0:
+		err = m.Steps(-1)
+	case uint(to) > ver:
+		return errors.New(errors.ErrInvalidRequest,
+			"migration version is above the database schema version, "+
+				"use up to apply migrations",
+			"to", to,
+			"version", ver)
+	case to == 0:
+		err = m.Down()
+	default:
+		err = m.Migrate(uint(to))
+	}
+
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) &&
+		!errors.Is(err, migrate.ErrNilVersion) &&
+		!errors.Is(err, os.ErrNotExist) {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to revert database migration")
+	}
+
+	return nil
+}
+
+// status returns the schema version of the database.
+func status(m *migrate.Migrate) (*Status, error) {
+	ver, dirty, err := m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to get database schema version")
+	}
+
+	return &Status{Version: ver, Dirty: dirty, Current: CurrentVersion}, nil
+}
+
 // migrationLog values allow the service logger to be used with migrations.
 type migrationLog struct {
 	log logger.Logger
@@ -17,6 +17,87 @@ SET xmloption = content;
 SET client_min_messages = warning;
 SET row_security = off;
 
+--
+-- Name: unaccent; Type: EXTENSION; Schema: -; Owner: -
+--
+
+CREATE EXTENSION IF NOT EXISTS unaccent WITH SCHEMA public;
+
+
+--
+-- Name: EXTENSION unaccent; Type: COMMENT; Schema: -; Owner: 
+--
+
+COMMENT ON EXTENSION unaccent IS 'text search dictionary that removes accents';
+
+
+--
+-- Name: app_account_id(); Type: FUNCTION; Schema: public; Owner: postgres
+--
+
+CREATE FUNCTION public.app_account_id() RETURNS text
+    LANGUAGE plpgsql STABLE
+    AS $$
+DECLARE
+    id TEXT := current_setting('app.account_id', true);
+BEGIN
+    IF id IS NULL OR id = '' THEN
+        RAISE EXCEPTION 'missing account, "app.account_id" is not set'
+            USING ERRCODE = 'insufficient_privilege';
+    END IF;
+
+    RETURN id;
+END;
+$$;
+
+
+ALTER FUNCTION public.app_account_id() OWNER TO postgres;
+
+--
+-- Name: app_fold(text); Type: FUNCTION; Schema: public; Owner: postgres
+--
+
+CREATE FUNCTION public.app_fold(value text) RETURNS text
+    LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE
+    AS $$
+    SELECT LOWER(public.unaccent('public.unaccent'::REGDICTIONARY, value));
+$$;
+
+
+ALTER FUNCTION public.app_fold(value text) OWNER TO postgres;
+
+--
+-- Name: record_change(); Type: FUNCTION; Schema: public; Owner: postgres
+--
+
+CREATE FUNCTION public.record_change() RETURNS trigger
+    LANGUAGE plpgsql SECURITY DEFINER
+    SET search_path TO 'public'
+    AS $$
+DECLARE
+    rec JSONB;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        rec := to_jsonb(OLD);
+    ELSE
+        rec := to_jsonb(NEW);
+    END IF;
+
+    INSERT INTO change (account_id, entity_type, entity_id, operation)
+    VALUES (rec->>'account_id', TG_ARGV[0], rec->>TG_ARGV[1],
+        CASE TG_OP
+            WHEN 'INSERT' THEN 'create'
+            WHEN 'UPDATE' THEN 'update'
+            ELSE 'delete'
+        END);
+
+    RETURN NULL;
+END;
+$$;
+
+
+ALTER FUNCTION public.record_change() OWNER TO postgres;
+
 SET default_tablespace = '';
 
 SET default_table_access_method = heap;
@@ -33,6 +114,7 @@ CREATE TABLE public.account (
     repo text,
     repo_status text DEFAULT 'inactive'::text NOT NULL,
     repo_status_data jsonb,
+    repo_filter jsonb,
     secret text DEFAULT gen_random_uuid() NOT NULL,
     data jsonb,
     resource_commit_hash text,
@@ -43,6 +125,211 @@ CREATE TABLE public.account (
 
 ALTER TABLE public.account OWNER TO postgres;
 
+--
+-- Name: account_key; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.account_key (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    key_id text NOT NULL,
+    secret text DEFAULT gen_random_uuid() NOT NULL,
+    expires_at timestamp with time zone,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.account_key OWNER TO postgres;
+
+--
+-- Name: agent_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.agent_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.agent_key_seq OWNER TO postgres;
+
+--
+-- Name: agent; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.agent (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    agent_key bigint DEFAULT nextval('public.agent_key_seq'::regclass) NOT NULL,
+    agent_id text NOT NULL,
+    name text NOT NULL,
+    version text,
+    status text DEFAULT 'active'::text NOT NULL,
+    status_data jsonb,
+    resources text[] DEFAULT '{}'::text[] NOT NULL,
+    data jsonb,
+    last_seen_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_by bigint,
+    config_version text,
+    config jsonb
+);
+
+
+ALTER TABLE public.agent OWNER TO postgres;
+
+--
+-- Name: alert; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.alert (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    alert_rule_id uuid NOT NULL,
+    resource_id uuid NOT NULL,
+    state text DEFAULT 'pending'::text NOT NULL,
+    started_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fired_at timestamp with time zone,
+    resolved_at timestamp with time zone
+);
+
+
+ALTER TABLE public.alert OWNER TO postgres;
+
+--
+-- Name: alert_rule_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.alert_rule_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.alert_rule_key_seq OWNER TO postgres;
+
+--
+-- Name: alert_rule; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.alert_rule (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    alert_rule_key bigint DEFAULT nextval('public.alert_rule_key_seq'::regclass) NOT NULL,
+    alert_rule_id uuid NOT NULL,
+    name text NOT NULL,
+    description text,
+    search text NOT NULL,
+    duration bigint DEFAULT 0 NOT NULL,
+    channels text[] DEFAULT '{}'::text[] NOT NULL,
+    status text DEFAULT 'active'::text NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_by bigint
+);
+
+
+ALTER TABLE public.alert_rule OWNER TO postgres;
+
+--
+-- Name: change_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.change_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.change_key_seq OWNER TO postgres;
+
+--
+-- Name: change; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.change (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    change_key bigint DEFAULT nextval('public.change_key_seq'::regclass) NOT NULL,
+    txid xid8 DEFAULT pg_current_xact_id() NOT NULL,
+    entity_type text NOT NULL,
+    entity_id text NOT NULL,
+    operation text NOT NULL,
+    ts timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.change OWNER TO postgres;
+
+--
+-- Name: idempotent_request; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.idempotent_request (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    idempotency_key text NOT NULL,
+    operation text NOT NULL,
+    request_hash text NOT NULL,
+    status integer DEFAULT 0 NOT NULL,
+    headers jsonb DEFAULT '{}'::jsonb NOT NULL,
+    response bytea,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    expires_at timestamp with time zone NOT NULL
+);
+
+
+ALTER TABLE public.idempotent_request OWNER TO postgres;
+
+--
+-- Name: import_error; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.import_error (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    path text NOT NULL,
+    error text NOT NULL,
+    commit_hash text,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.import_error OWNER TO postgres;
+
+--
+-- Name: request_count; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.request_count (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    day date NOT NULL,
+    operation text NOT NULL,
+    requests bigint DEFAULT 0 NOT NULL,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    ingest_bytes bigint DEFAULT 0 NOT NULL
+);
+
+
+ALTER TABLE public.request_count OWNER TO postgres;
+
+--
+-- Name: request_key; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.request_key (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    idempotency_key text NOT NULL,
+    day date NOT NULL,
+    operation text NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.request_key OWNER TO postgres;
+
 --
 -- Name: resource_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
 --
@@ -62,7 +349,7 @@ ALTER SEQUENCE public.resource_key_seq OWNER TO postgres;
 --
 
 CREATE TABLE public.resource (
-    account_id text DEFAULT current_setting('app.account_id'::text) NOT NULL,
+    account_id text DEFAULT public.app_account_id() NOT NULL,
     resource_key bigint DEFAULT nextval('public.resource_key_seq'::regclass) NOT NULL,
     resource_id uuid NOT NULL,
     name text NOT NULL,
@@ -75,347 +362,1356 @@ CREATE TABLE public.resource (
     clear_condition text,
     clear_after bigint DEFAULT (((60 * 60) * 24) * 30) NOT NULL,
     clear_delay bigint DEFAULT 0 NOT NULL,
-    data jsonb,
+    duplicate_policy text DEFAULT 'last'::text NOT NULL,
+    key_strategy text DEFAULT 'field'::text NOT NULL,
+    depends_on text[] DEFAULT '{}'::text[] NOT NULL,
+    source text,
+    commit_hash text,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_by bigint
+);
+
+
+ALTER TABLE public.resource OWNER TO postgres;
+
+--
+-- Name: resource_acl_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.resource_acl_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.resource_acl_key_seq OWNER TO postgres;
+
+--
+-- Name: resource_acl; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.resource_acl (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    resource_acl_key bigint DEFAULT nextval('public.resource_acl_key_seq'::regclass) NOT NULL,
+    resource_id uuid NOT NULL,
+    principal_type text NOT NULL,
+    principal_id text NOT NULL,
+    permission text NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_by bigint
+);
+
+
+ALTER TABLE public.resource_acl OWNER TO postgres;
+
+--
+-- Name: resource_data; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.resource_data (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    resource_key bigint NOT NULL,
+    data_key text NOT NULL,
+    data jsonb NOT NULL,
+    ts timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    clear_at timestamp with time zone
+);
+
+
+ALTER TABLE public.resource_data OWNER TO postgres;
+
+--
+-- Name: resource_revision; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.resource_revision (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    resource_id uuid NOT NULL,
+    revision bigint NOT NULL,
+    definition jsonb NOT NULL,
+    version text,
     source text,
     commit_hash text,
     created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint
+);
+
+
+ALTER TABLE public.resource_revision OWNER TO postgres;
+
+--
+-- Name: resource_definition(public.resource); Type: FUNCTION; Schema: public; Owner: postgres
+--
+
+CREATE FUNCTION public.resource_definition(r public.resource) RETURNS jsonb
+    LANGUAGE sql IMMUTABLE
+    AS $$
+    SELECT JSONB_BUILD_OBJECT(
+        'name', r.name,
+        'description', r.description,
+        'key_field', r.key_field,
+        'key_regex', r.key_regex,
+        'clear_condition', r.clear_condition,
+        'clear_after', r.clear_after,
+        'clear_delay', r.clear_delay,
+        'duplicate_policy', r.duplicate_policy,
+        'key_strategy', r.key_strategy,
+        'depends_on', r.depends_on);
+$$;
+
+
+ALTER FUNCTION public.resource_definition(r public.resource) OWNER TO postgres;
+
+--
+-- Name: record_resource_revision(); Type: FUNCTION; Schema: public; Owner: postgres
+--
+
+CREATE FUNCTION public.record_resource_revision() RETURNS trigger
+    LANGUAGE plpgsql SECURITY DEFINER
+    SET search_path TO 'public'
+    AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND
+        resource_definition(OLD) = resource_definition(NEW) THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO resource_revision (account_id, resource_id, revision,
+        definition, version, source, commit_hash, created_by)
+    VALUES (NEW.account_id, NEW.resource_id,
+        COALESCE((SELECT MAX(resource_revision.revision)
+            FROM resource_revision
+            WHERE resource_revision.account_id = NEW.account_id
+                AND resource_revision.resource_id = NEW.resource_id), 0) + 1,
+        resource_definition(NEW), NEW.version, NEW.source, NEW.commit_hash,
+        NEW.updated_by);
+
+    RETURN NULL;
+END;
+$$;
+
+
+ALTER FUNCTION public.record_resource_revision() OWNER TO postgres;
+
+--
+-- Name: schema_migrations; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.schema_migrations (
+    version bigint NOT NULL,
+    dirty boolean NOT NULL
+);
+
+
+ALTER TABLE public.schema_migrations OWNER TO postgres;
+
+--
+-- Name: session; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.session (
+    session_id text NOT NULL,
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    user_id text NOT NULL,
+    scopes text DEFAULT ''::text NOT NULL,
+    tenant text DEFAULT ''::text NOT NULL,
+    token text NOT NULL,
+    token_expires_at timestamp with time zone NOT NULL,
+    csrf_token text NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.session OWNER TO postgres;
+
+--
+-- Name: tag; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.tag (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    tag_key text NOT NULL,
+    tag_val text NOT NULL,
+    status text DEFAULT 'active'::text NOT NULL,
+    status_data jsonb,
+    data jsonb,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
     created_by bigint,
     updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
     updated_by bigint
 );
 
 
-ALTER TABLE public.resource OWNER TO postgres;
+ALTER TABLE public.tag OWNER TO postgres;
+
+--
+-- Name: tag_obj_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.tag_obj_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.tag_obj_key_seq OWNER TO postgres;
+
+--
+-- Name: tag_obj; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.tag_obj (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    tag_obj_key bigint DEFAULT nextval('public.tag_obj_key_seq'::regclass) NOT NULL,
+    tag_type text NOT NULL,
+    tag_obj_id text NOT NULL,
+    tag_key text NOT NULL,
+    tag_val text,
+    status text DEFAULT 'active'::text NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_by bigint
+);
+
+
+ALTER TABLE public.tag_obj OWNER TO postgres;
+
+--
+-- Name: token_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.token_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.token_key_seq OWNER TO postgres;
+
+--
+-- Name: token; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.token (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    token_key bigint DEFAULT nextval('public.token_key_seq'::regclass) NOT NULL,
+    token_id uuid NOT NULL,
+    user_id text NOT NULL,
+    scopes text DEFAULT ''::text NOT NULL,
+    restricted boolean DEFAULT false NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    last_used_at timestamp with time zone,
+    use_count bigint DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+ALTER TABLE public.token OWNER TO postgres;
+
+--
+-- Name: user_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.user_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.user_key_seq OWNER TO postgres;
+
+--
+-- Name: user; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public."user" (
+    user_key bigint DEFAULT nextval('public.user_key_seq'::regclass) NOT NULL,
+    user_id text NOT NULL,
+    password text,
+    email text,
+    last_name text,
+    first_name text,
+    status text DEFAULT 'active'::text NOT NULL,
+    scopes text DEFAULT ''::text NOT NULL,
+    data jsonb,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_by bigint,
+    failed_logins integer DEFAULT 0 NOT NULL,
+    locked_until timestamp with time zone
+);
+
+
+ALTER TABLE public."user" OWNER TO postgres;
+
+--
+-- Name: user_group_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+--
+
+CREATE SEQUENCE public.user_group_key_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+ALTER SEQUENCE public.user_group_key_seq OWNER TO postgres;
+
+--
+-- Name: user_group; Type: TABLE; Schema: public; Owner: postgres
+--
+
+CREATE TABLE public.user_group (
+    account_id text DEFAULT public.app_account_id() NOT NULL,
+    group_key bigint DEFAULT nextval('public.user_group_key_seq'::regclass) NOT NULL,
+    group_id text NOT NULL,
+    name text NOT NULL,
+    description text,
+    status text DEFAULT 'active'::text NOT NULL,
+    scopes text DEFAULT ''::text NOT NULL,
+    users text[] DEFAULT '{}'::text[] NOT NULL,
+    data jsonb,
+    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    created_by bigint,
+    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_by bigint
+);
+
+
+ALTER TABLE public.user_group OWNER TO postgres;
+
+--
+-- Name: account account_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.account
+    ADD CONSTRAINT account_pkey PRIMARY KEY (account_id);
+
+
+--
+-- Name: account_key account_key_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.account_key
+    ADD CONSTRAINT account_key_pkey PRIMARY KEY (account_id, key_id);
+
+
+--
+-- Name: agent agent_account_id_agent_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.agent
+    ADD CONSTRAINT agent_account_id_agent_id_key UNIQUE (account_id, agent_id);
+
+
+--
+-- Name: agent agent_agent_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.agent
+    ADD CONSTRAINT agent_agent_key_key UNIQUE (agent_key);
+
+
+--
+-- Name: agent agent_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.agent
+    ADD CONSTRAINT agent_pkey PRIMARY KEY (account_id, agent_key);
+
+
+--
+-- Name: alert alert_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert
+    ADD CONSTRAINT alert_pkey PRIMARY KEY (account_id, alert_rule_id, resource_id);
+
+
+--
+-- Name: alert_rule alert_rule_account_id_alert_rule_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert_rule
+    ADD CONSTRAINT alert_rule_account_id_alert_rule_id_key UNIQUE (account_id, alert_rule_id);
+
+
+--
+-- Name: alert_rule alert_rule_alert_rule_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert_rule
+    ADD CONSTRAINT alert_rule_alert_rule_key_key UNIQUE (alert_rule_key);
+
+
+--
+-- Name: alert_rule alert_rule_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert_rule
+    ADD CONSTRAINT alert_rule_pkey PRIMARY KEY (account_id, alert_rule_key);
+
+
+--
+-- Name: change change_change_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.change
+    ADD CONSTRAINT change_change_key_key UNIQUE (change_key);
+
+
+--
+-- Name: change change_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.change
+    ADD CONSTRAINT change_pkey PRIMARY KEY (account_id, change_key);
+
+
+--
+-- Name: idempotent_request idempotent_request_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.idempotent_request
+    ADD CONSTRAINT idempotent_request_pkey PRIMARY KEY (account_id, idempotency_key);
+
+
+--
+-- Name: import_error import_error_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.import_error
+    ADD CONSTRAINT import_error_pkey PRIMARY KEY (account_id, path);
+
+
+--
+-- Name: request_count request_count_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.request_count
+    ADD CONSTRAINT request_count_pkey PRIMARY KEY (account_id, day, operation);
+
+
+--
+-- Name: request_key request_key_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.request_key
+    ADD CONSTRAINT request_key_pkey PRIMARY KEY (account_id, idempotency_key);
+
+
+--
+-- Name: resource_acl resource_acl_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_acl
+    ADD CONSTRAINT resource_acl_pkey PRIMARY KEY (account_id, resource_acl_key);
+
+
+--
+-- Name: resource_acl resource_acl_principal_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_acl
+    ADD CONSTRAINT resource_acl_principal_key UNIQUE (account_id, resource_id, principal_type, principal_id);
+
+
+--
+-- Name: resource_acl resource_acl_resource_acl_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_acl
+    ADD CONSTRAINT resource_acl_resource_acl_key_key UNIQUE (resource_acl_key);
+
+
+--
+-- Name: resource_data resource_data_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_data
+    ADD CONSTRAINT resource_data_pkey PRIMARY KEY (account_id, resource_key, data_key);
+
+
+--
+-- Name: resource_revision resource_revision_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_revision
+    ADD CONSTRAINT resource_revision_pkey PRIMARY KEY (account_id, resource_id, revision);
+
+
+--
+-- Name: resource resource_account_id_resource_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource
+    ADD CONSTRAINT resource_account_id_resource_id_key UNIQUE (account_id, resource_id);
+
+
+--
+-- Name: resource resource_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource
+    ADD CONSTRAINT resource_pkey PRIMARY KEY (account_id, resource_key);
+
+
+--
+-- Name: resource resource_resource_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource
+    ADD CONSTRAINT resource_resource_key_key UNIQUE (resource_key);
+
+
+--
+-- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.schema_migrations
+    ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
+
+
+--
+-- Name: session session_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.session
+    ADD CONSTRAINT session_pkey PRIMARY KEY (session_id);
+
+
+--
+-- Name: tag_obj tag_obj_account_id_tag_type_tag_obj_id_tag_key_tag_val_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag_obj
+    ADD CONSTRAINT tag_obj_account_id_tag_type_tag_obj_id_tag_key_tag_val_key UNIQUE (account_id, tag_type, tag_obj_id, tag_key, tag_val);
+
+
+--
+-- Name: tag_obj tag_obj_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag_obj
+    ADD CONSTRAINT tag_obj_pkey PRIMARY KEY (account_id, tag_obj_key);
+
+
+--
+-- Name: tag_obj tag_obj_tag_obj_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag_obj
+    ADD CONSTRAINT tag_obj_tag_obj_key_key UNIQUE (tag_obj_key);
+
+
+--
+-- Name: tag tag_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag
+    ADD CONSTRAINT tag_pkey PRIMARY KEY (account_id, tag_key, tag_val);
+
+
+--
+-- Name: token token_account_id_token_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.token
+    ADD CONSTRAINT token_account_id_token_id_key UNIQUE (account_id, token_id);
+
+
+--
+-- Name: token token_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.token
+    ADD CONSTRAINT token_pkey PRIMARY KEY (account_id, token_key);
+
+
+--
+-- Name: token token_token_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.token
+    ADD CONSTRAINT token_token_key_key UNIQUE (token_key);
+
+
+--
+-- Name: user_group user_group_account_id_group_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_group
+    ADD CONSTRAINT user_group_account_id_group_id_key UNIQUE (account_id, group_id);
+
+
+--
+-- Name: user_group user_group_group_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_group
+    ADD CONSTRAINT user_group_group_key_key UNIQUE (group_key);
+
+
+--
+-- Name: user_group user_group_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_group
+    ADD CONSTRAINT user_group_pkey PRIMARY KEY (account_id, group_key);
+
+
+--
+-- Name: user user_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public."user"
+    ADD CONSTRAINT user_pkey PRIMARY KEY (user_key);
+
+
+--
+-- Name: user user_user_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public."user"
+    ADD CONSTRAINT user_user_id_key UNIQUE (user_id);
+
+
+--
+-- Name: account_key_expires_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX account_key_expires_at_idx ON public.account_key USING btree (expires_at);
+
+
+--
+-- Name: agent_resources_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX agent_resources_idx ON public.agent USING gin (resources);
+
+
+--
+-- Name: alert_resource_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX alert_resource_id_idx ON public.alert USING btree (account_id, resource_id);
+
+
+--
+-- Name: change_account_id_txid_change_key_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX change_account_id_txid_change_key_idx ON public.change USING btree (account_id, txid, change_key);
+
+
+--
+-- Name: idempotent_request_expires_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX idempotent_request_expires_at_idx ON public.idempotent_request USING btree (expires_at);
+
+
+--
+-- Name: request_key_created_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX request_key_created_at_idx ON public.request_key USING btree (created_at);
+
+
+--
+-- Name: resource_acl_principal_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX resource_acl_principal_idx ON public.resource_acl USING btree (principal_type, principal_id);
+
+
+--
+-- Name: resource_data_clear_at_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX resource_data_clear_at_idx ON public.resource_data USING btree (clear_at) WHERE (clear_at IS NOT NULL);
+
+
+--
+-- Name: resource_data_resource_key_ts_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX resource_data_resource_key_ts_idx ON public.resource_data USING btree (resource_key, ts);
+
+
+--
+-- Name: resource_depends_on_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX resource_depends_on_idx ON public.resource USING gin (depends_on);
+
+
+--
+-- Name: session_account_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX session_account_id_idx ON public.session USING btree (account_id);
+
+
+--
+-- Name: token_user_id_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX token_user_id_idx ON public.token USING btree (user_id);
+
+
+--
+-- Name: user_group_users_idx; Type: INDEX; Schema: public; Owner: postgres
+--
+
+CREATE INDEX user_group_users_idx ON public.user_group USING gin (users);
+
+
+--
+-- Name: account account_change_trigger; Type: TRIGGER; Schema: public; Owner: postgres
+--
+
+CREATE TRIGGER account_change_trigger AFTER INSERT OR DELETE OR UPDATE ON public.account FOR EACH ROW EXECUTE FUNCTION public.record_change('account', 'account_id');
+
+
+--
+-- Name: resource resource_change_trigger; Type: TRIGGER; Schema: public; Owner: postgres
+--
+
+CREATE TRIGGER resource_change_trigger AFTER INSERT OR DELETE OR UPDATE ON public.resource FOR EACH ROW EXECUTE FUNCTION public.record_change('resource', 'resource_id');
+
+
+--
+-- Name: resource resource_revision_trigger; Type: TRIGGER; Schema: public; Owner: postgres
+--
+
+CREATE TRIGGER resource_revision_trigger AFTER INSERT OR UPDATE ON public.resource FOR EACH ROW EXECUTE FUNCTION public.record_resource_revision();
+
+
+--
+-- Name: account_key account_key_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.account_key
+    ADD CONSTRAINT account_key_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: agent agent_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.agent
+    ADD CONSTRAINT agent_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: agent agent_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.agent
+    ADD CONSTRAINT agent_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: agent agent_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.agent
+    ADD CONSTRAINT agent_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: alert alert_account_id_alert_rule_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert
+    ADD CONSTRAINT alert_account_id_alert_rule_id_fkey FOREIGN KEY (account_id, alert_rule_id) REFERENCES public.alert_rule(account_id, alert_rule_id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: alert alert_account_id_resource_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert
+    ADD CONSTRAINT alert_account_id_resource_id_fkey FOREIGN KEY (account_id, resource_id) REFERENCES public.resource(account_id, resource_id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: alert_rule alert_rule_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert_rule
+    ADD CONSTRAINT alert_rule_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: alert_rule alert_rule_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert_rule
+    ADD CONSTRAINT alert_rule_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: alert_rule alert_rule_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.alert_rule
+    ADD CONSTRAINT alert_rule_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: idempotent_request idempotent_request_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.idempotent_request
+    ADD CONSTRAINT idempotent_request_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: import_error import_error_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.import_error
+    ADD CONSTRAINT import_error_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: request_count request_count_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.request_count
+    ADD CONSTRAINT request_count_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: request_key request_key_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.request_key
+    ADD CONSTRAINT request_key_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: resource resource_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource
+    ADD CONSTRAINT resource_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: resource resource_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource
+    ADD CONSTRAINT resource_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: resource_acl resource_acl_account_id_resource_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_acl
+    ADD CONSTRAINT resource_acl_account_id_resource_id_fkey FOREIGN KEY (account_id, resource_id) REFERENCES public.resource(account_id, resource_id) ON DELETE CASCADE;
+
+
+--
+-- Name: resource_acl resource_acl_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_acl
+    ADD CONSTRAINT resource_acl_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: resource_acl resource_acl_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_acl
+    ADD CONSTRAINT resource_acl_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: resource_data resource_data_account_id_resource_key_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_data
+    ADD CONSTRAINT resource_data_account_id_resource_key_fkey FOREIGN KEY (account_id, resource_key) REFERENCES public.resource(account_id, resource_key) ON DELETE CASCADE;
+
+
+--
+-- Name: resource_revision resource_revision_account_id_resource_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_revision
+    ADD CONSTRAINT resource_revision_account_id_resource_id_fkey FOREIGN KEY (account_id, resource_id) REFERENCES public.resource(account_id, resource_id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: resource_revision resource_revision_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource_revision
+    ADD CONSTRAINT resource_revision_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: resource resource_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.resource
+    ADD CONSTRAINT resource_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: session session_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.session
+    ADD CONSTRAINT session_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: tag tag_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag
+    ADD CONSTRAINT tag_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: tag tag_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag
+    ADD CONSTRAINT tag_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: tag_obj tag_obj_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag_obj
+    ADD CONSTRAINT tag_obj_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: tag_obj tag_obj_account_id_tag_key_tag_val_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag_obj
+    ADD CONSTRAINT tag_obj_account_id_tag_key_tag_val_fkey FOREIGN KEY (account_id, tag_key, tag_val) REFERENCES public.tag(account_id, tag_key, tag_val) ON DELETE CASCADE;
+
+
+--
+-- Name: tag_obj tag_obj_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag_obj
+    ADD CONSTRAINT tag_obj_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: tag_obj tag_obj_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag_obj
+    ADD CONSTRAINT tag_obj_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: tag tag_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.tag
+    ADD CONSTRAINT tag_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: token token_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.token
+    ADD CONSTRAINT token_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: user_group user_group_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_group
+    ADD CONSTRAINT user_group_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+
+
+--
+-- Name: user_group user_group_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_group
+    ADD CONSTRAINT user_group_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: user_group user_group_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+--
+
+ALTER TABLE ONLY public.user_group
+    ADD CONSTRAINT user_group_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+
+
+--
+-- Name: account; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.account ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: schema_migrations; Type: TABLE; Schema: public; Owner: postgres
+-- Name: account account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.schema_migrations (
-    version bigint NOT NULL,
-    dirty boolean NOT NULL
-);
+CREATE POLICY account_isolation_policy ON public.account USING (((public.app_account_id() = 'sys'::text) OR (account_id = public.app_account_id())));
 
 
-ALTER TABLE public.schema_migrations OWNER TO postgres;
+--
+-- Name: account_key; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.account_key ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag; Type: TABLE; Schema: public; Owner: postgres
+-- Name: account_key account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.tag (
-    account_id text DEFAULT current_setting('app.account_id'::text) NOT NULL,
-    tag_key text NOT NULL,
-    tag_val text NOT NULL,
-    status text DEFAULT 'active'::text NOT NULL,
-    status_data jsonb,
-    data jsonb,
-    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
-    created_by bigint,
-    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
-    updated_by bigint
-);
+CREATE POLICY account_isolation_policy ON public.account_key USING (((public.app_account_id() = 'sys'::text) OR (account_id = public.app_account_id())));
 
 
-ALTER TABLE public.tag OWNER TO postgres;
+--
+-- Name: agent; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.agent ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag_obj_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+-- Name: agent account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-CREATE SEQUENCE public.tag_obj_key_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1;
+CREATE POLICY account_isolation_policy ON public.agent USING ((account_id = public.app_account_id()));
 
 
-ALTER SEQUENCE public.tag_obj_key_seq OWNER TO postgres;
+--
+-- Name: alert; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.alert ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag_obj; Type: TABLE; Schema: public; Owner: postgres
+-- Name: alert account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public.tag_obj (
-    account_id text DEFAULT current_setting('app.account_id'::text) NOT NULL,
-    tag_obj_key bigint DEFAULT nextval('public.tag_obj_key_seq'::regclass) NOT NULL,
-    tag_type text NOT NULL,
-    tag_obj_id text NOT NULL,
-    tag_key text NOT NULL,
-    tag_val text,
-    status text DEFAULT 'active'::text NOT NULL,
-    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
-    created_by bigint,
-    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
-    updated_by bigint
-);
+CREATE POLICY account_isolation_policy ON public.alert USING ((account_id = public.app_account_id()));
 
 
-ALTER TABLE public.tag_obj OWNER TO postgres;
+--
+-- Name: alert_rule; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.alert_rule ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: user_key_seq; Type: SEQUENCE; Schema: public; Owner: postgres
+-- Name: alert_rule account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-CREATE SEQUENCE public.user_key_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1;
+CREATE POLICY account_isolation_policy ON public.alert_rule USING ((account_id = public.app_account_id()));
 
 
-ALTER SEQUENCE public.user_key_seq OWNER TO postgres;
+--
+-- Name: change; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.change ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: user; Type: TABLE; Schema: public; Owner: postgres
+-- Name: change account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-CREATE TABLE public."user" (
-    user_key bigint DEFAULT nextval('public.user_key_seq'::regclass) NOT NULL,
-    user_id text NOT NULL,
-    password text,
-    email text,
-    last_name text,
-    first_name text,
-    status text DEFAULT 'active'::text NOT NULL,
-    scopes text DEFAULT ''::text NOT NULL,
-    data jsonb,
-    created_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
-    created_by bigint,
-    updated_at timestamp with time zone DEFAULT CURRENT_TIMESTAMP NOT NULL,
-    updated_by bigint
-);
+CREATE POLICY account_isolation_policy ON public.change USING ((account_id = public.app_account_id()));
 
 
-ALTER TABLE public."user" OWNER TO postgres;
+--
+-- Name: idempotent_request account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
+--
+
+CREATE POLICY account_isolation_policy ON public.idempotent_request USING ((account_id = public.app_account_id()));
+
 
 --
--- Name: account account_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: import_error account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.account
-    ADD CONSTRAINT account_pkey PRIMARY KEY (account_id);
+CREATE POLICY account_isolation_policy ON public.import_error USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: resource resource_account_id_resource_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: request_count account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.resource
-    ADD CONSTRAINT resource_account_id_resource_id_key UNIQUE (account_id, resource_id);
+CREATE POLICY account_isolation_policy ON public.request_count USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: resource resource_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: request_key account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.resource
-    ADD CONSTRAINT resource_pkey PRIMARY KEY (account_id, resource_key);
+CREATE POLICY account_isolation_policy ON public.request_key USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: resource resource_resource_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: resource account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.resource
-    ADD CONSTRAINT resource_resource_key_key UNIQUE (resource_key);
+CREATE POLICY account_isolation_policy ON public.resource USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: resource_acl account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.schema_migrations
-    ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
+CREATE POLICY account_isolation_policy ON public.resource_acl USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: tag_obj tag_obj_account_id_tag_type_tag_obj_id_tag_key_tag_val_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: resource_data account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag_obj
-    ADD CONSTRAINT tag_obj_account_id_tag_type_tag_obj_id_tag_key_tag_val_key UNIQUE (account_id, tag_type, tag_obj_id, tag_key, tag_val);
+CREATE POLICY account_isolation_policy ON public.resource_data USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: tag_obj tag_obj_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: resource_revision account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag_obj
-    ADD CONSTRAINT tag_obj_pkey PRIMARY KEY (account_id, tag_obj_key);
+CREATE POLICY account_isolation_policy ON public.resource_revision USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: tag_obj tag_obj_tag_obj_key_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: session account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag_obj
-    ADD CONSTRAINT tag_obj_tag_obj_key_key UNIQUE (tag_obj_key);
+CREATE POLICY account_isolation_policy ON public.session USING (((public.app_account_id() = 'sys'::text) OR (account_id = public.app_account_id())));
 
 
 --
--- Name: tag tag_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: tag account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag
-    ADD CONSTRAINT tag_pkey PRIMARY KEY (account_id, tag_key, tag_val);
+CREATE POLICY account_isolation_policy ON public.tag USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: user user_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: tag_obj account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public."user"
-    ADD CONSTRAINT user_pkey PRIMARY KEY (user_key);
+CREATE POLICY account_isolation_policy ON public.tag_obj USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: user user_user_id_key; Type: CONSTRAINT; Schema: public; Owner: postgres
+-- Name: token account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public."user"
-    ADD CONSTRAINT user_user_id_key UNIQUE (user_id);
+CREATE POLICY account_isolation_policy ON public.token USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: resource resource_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: user_group account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.resource
-    ADD CONSTRAINT resource_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+CREATE POLICY account_isolation_policy ON public.user_group USING ((account_id = public.app_account_id()));
 
 
 --
--- Name: resource resource_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: idempotent_request; Type: ROW SECURITY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.resource
-    ADD CONSTRAINT resource_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+ALTER TABLE public.idempotent_request ENABLE ROW LEVEL SECURITY;
+
+--
+-- Name: import_error; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
 
+ALTER TABLE public.import_error ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: resource resource_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: request_count; Type: ROW SECURITY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.resource
-    ADD CONSTRAINT resource_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+ALTER TABLE public.request_count ENABLE ROW LEVEL SECURITY;
 
+--
+-- Name: request_key; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.request_key ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag tag_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: resource; Type: ROW SECURITY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag
-    ADD CONSTRAINT tag_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+ALTER TABLE public.resource ENABLE ROW LEVEL SECURITY;
 
+--
+-- Name: resource_acl; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.resource_acl ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag tag_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: resource_data; Type: ROW SECURITY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag
-    ADD CONSTRAINT tag_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+ALTER TABLE public.resource_data ENABLE ROW LEVEL SECURITY;
+
+--
+-- Name: resource_revision; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
 
+ALTER TABLE public.resource_revision ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag_obj tag_obj_account_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: session; Type: ROW SECURITY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag_obj
-    ADD CONSTRAINT tag_obj_account_id_fkey FOREIGN KEY (account_id) REFERENCES public.account(account_id) ON DELETE CASCADE;
+ALTER TABLE public.session ENABLE ROW LEVEL SECURITY;
 
+--
+-- Name: tag; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
+
+ALTER TABLE public.tag ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag_obj tag_obj_account_id_tag_key_tag_val_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: tag_obj; Type: ROW SECURITY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag_obj
-    ADD CONSTRAINT tag_obj_account_id_tag_key_tag_val_fkey FOREIGN KEY (account_id, tag_key, tag_val) REFERENCES public.tag(account_id, tag_key, tag_val) ON DELETE CASCADE;
+ALTER TABLE public.tag_obj ENABLE ROW LEVEL SECURITY;
+
+--
+-- Name: token; Type: ROW SECURITY; Schema: public; Owner: postgres
+--
 
+ALTER TABLE public.token ENABLE ROW LEVEL SECURITY;
 
 --
--- Name: tag_obj tag_obj_created_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: user_group; Type: ROW SECURITY; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag_obj
-    ADD CONSTRAINT tag_obj_created_by_fkey FOREIGN KEY (created_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+ALTER TABLE public.user_group ENABLE ROW LEVEL SECURITY;
+
+--
+-- Name: TABLE account; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.account TO "api-db-user";
 
 
 --
--- Name: tag_obj tag_obj_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: TABLE account_key; Type: ACL; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag_obj
-    ADD CONSTRAINT tag_obj_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+GRANT ALL ON TABLE public.account_key TO "api-db-user";
 
 
 --
--- Name: tag tag_updated_by_fkey; Type: FK CONSTRAINT; Schema: public; Owner: postgres
+-- Name: SEQUENCE agent_key_seq; Type: ACL; Schema: public; Owner: postgres
 --
 
-ALTER TABLE ONLY public.tag
-    ADD CONSTRAINT tag_updated_by_fkey FOREIGN KEY (updated_by) REFERENCES public."user"(user_key) ON DELETE SET NULL;
+GRANT ALL ON SEQUENCE public.agent_key_seq TO "api-db-user";
 
 
 --
--- Name: account; Type: ROW SECURITY; Schema: public; Owner: postgres
+-- Name: TABLE agent; Type: ACL; Schema: public; Owner: postgres
 --
 
-ALTER TABLE public.account ENABLE ROW LEVEL SECURITY;
+GRANT ALL ON TABLE public.agent TO "api-db-user";
+
 
 --
--- Name: account account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
+-- Name: TABLE alert; Type: ACL; Schema: public; Owner: postgres
 --
 
-CREATE POLICY account_isolation_policy ON public.account USING (((current_setting('app.account_id'::text) = 'sys'::text) OR (account_id = current_setting('app.account_id'::text))));
+GRANT ALL ON TABLE public.alert TO "api-db-user";
 
 
 --
--- Name: resource account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
+-- Name: SEQUENCE alert_rule_key_seq; Type: ACL; Schema: public; Owner: postgres
 --
 
-CREATE POLICY account_isolation_policy ON public.resource USING ((account_id = current_setting('app.account_id'::text)));
+GRANT ALL ON SEQUENCE public.alert_rule_key_seq TO "api-db-user";
 
 
 --
--- Name: tag account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
+-- Name: TABLE alert_rule; Type: ACL; Schema: public; Owner: postgres
 --
 
-CREATE POLICY account_isolation_policy ON public.tag USING ((account_id = current_setting('app.account_id'::text)));
+GRANT ALL ON TABLE public.alert_rule TO "api-db-user";
 
 
 --
--- Name: tag_obj account_isolation_policy; Type: POLICY; Schema: public; Owner: postgres
+-- Name: SEQUENCE change_key_seq; Type: ACL; Schema: public; Owner: postgres
 --
 
-CREATE POLICY account_isolation_policy ON public.tag_obj USING ((account_id = current_setting('app.account_id'::text)));
+GRANT ALL ON SEQUENCE public.change_key_seq TO "api-db-user";
 
 
 --
--- Name: resource; Type: ROW SECURITY; Schema: public; Owner: postgres
+-- Name: TABLE change; Type: ACL; Schema: public; Owner: postgres
 --
 
-ALTER TABLE public.resource ENABLE ROW LEVEL SECURITY;
+GRANT ALL ON TABLE public.change TO "api-db-user";
+
 
 --
--- Name: tag; Type: ROW SECURITY; Schema: public; Owner: postgres
+-- Name: TABLE idempotent_request; Type: ACL; Schema: public; Owner: postgres
 --
 
-ALTER TABLE public.tag ENABLE ROW LEVEL SECURITY;
+GRANT ALL ON TABLE public.idempotent_request TO "api-db-user";
+
 
 --
--- Name: tag_obj; Type: ROW SECURITY; Schema: public; Owner: postgres
+-- Name: TABLE import_error; Type: ACL; Schema: public; Owner: postgres
 --
 
-ALTER TABLE public.tag_obj ENABLE ROW LEVEL SECURITY;
+GRANT ALL ON TABLE public.import_error TO "api-db-user";
+
 
 --
--- Name: TABLE account; Type: ACL; Schema: public; Owner: postgres
+-- Name: TABLE request_count; Type: ACL; Schema: public; Owner: postgres
 --
 
-GRANT ALL ON TABLE public.account TO "api-db-user";
+GRANT ALL ON TABLE public.request_count TO "api-db-user";
+
+
+--
+-- Name: TABLE request_key; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.request_key TO "api-db-user";
 
 
 --
@@ -432,6 +1728,34 @@ GRANT ALL ON SEQUENCE public.resource_key_seq TO "api-db-user";
 GRANT ALL ON TABLE public.resource TO "api-db-user";
 
 
+--
+-- Name: SEQUENCE resource_acl_key_seq; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON SEQUENCE public.resource_acl_key_seq TO "api-db-user";
+
+
+--
+-- Name: TABLE resource_acl; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.resource_acl TO "api-db-user";
+
+
+--
+-- Name: TABLE resource_data; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.resource_data TO "api-db-user";
+
+
+--
+-- Name: TABLE resource_revision; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.resource_revision TO "api-db-user";
+
+
 --
 -- Name: TABLE schema_migrations; Type: ACL; Schema: public; Owner: postgres
 --
@@ -439,6 +1763,13 @@ GRANT ALL ON TABLE public.resource TO "api-db-user";
 GRANT ALL ON TABLE public.schema_migrations TO "api-db-user";
 
 
+--
+-- Name: TABLE session; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.session TO "api-db-user";
+
+
 --
 -- Name: TABLE tag; Type: ACL; Schema: public; Owner: postgres
 --
@@ -460,6 +1791,20 @@ GRANT ALL ON SEQUENCE public.tag_obj_key_seq TO "api-db-user";
 GRANT ALL ON TABLE public.tag_obj TO "api-db-user";
 
 
+--
+-- Name: SEQUENCE token_key_seq; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON SEQUENCE public.token_key_seq TO "api-db-user";
+
+
+--
+-- Name: TABLE token; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.token TO "api-db-user";
+
+
 --
 -- Name: SEQUENCE user_key_seq; Type: ACL; Schema: public; Owner: postgres
 --
@@ -474,6 +1819,20 @@ GRANT ALL ON SEQUENCE public.user_key_seq TO "api-db-user";
 GRANT ALL ON TABLE public."user" TO "api-db-user";
 
 
+--
+-- Name: SEQUENCE user_group_key_seq; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON SEQUENCE public.user_group_key_seq TO "api-db-user";
+
+
+--
+-- Name: TABLE user_group; Type: ACL; Schema: public; Owner: postgres
+--
+
+GRANT ALL ON TABLE public.user_group TO "api-db-user";
+
+
 --
 -- PostgreSQL database dump complete
 --
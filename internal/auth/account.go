@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"net/url"
+	"time"
 
 	"github.com/dhaifley/apigo/internal/cache"
 	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/repo"
 	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
 	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -18,17 +22,17 @@ const current = "current"
 
 // Account values represent service accounts.
 type Account struct {
-	AccountID      request.FieldString `json:"account_id"`
-	Name           request.FieldString `json:"name"`
-	Status         request.FieldString `json:"status"`
-	StatusData     request.FieldJSON   `json:"status_data"`
-	Repo           request.FieldString `json:"-"`
-	RepoStatus     request.FieldString `json:"repo_status"`
-	RepoStatusData request.FieldJSON   `json:"repo_status_data"`
-	Secret         request.FieldString `json:"-"`
-	Data           request.FieldJSON   `json:"data"`
-	CreatedAt      request.FieldTime   `json:"created_at"`
-	UpdatedAt      request.FieldTime   `json:"updated_at"`
+	AccountID      request.FieldString `json:"account_id"       yaml:"account_id"`
+	Name           request.FieldString `json:"name"             yaml:"name"`
+	Status         request.FieldString `json:"status"           yaml:"status"`
+	StatusData     request.FieldJSON   `json:"status_data"      yaml:"status_data"`
+	Repo           request.FieldString `json:"-"                yaml:"-"`
+	RepoStatus     request.FieldString `json:"repo_status"      yaml:"repo_status"`
+	RepoStatusData request.FieldJSON   `json:"repo_status_data" yaml:"repo_status_data"`
+	Secret         request.FieldString `json:"-"                yaml:"-"`
+	Data           request.FieldJSON   `json:"data"             yaml:"data"`
+	CreatedAt      request.FieldTime   `json:"created_at"       yaml:"created_at"`
+	UpdatedAt      request.FieldTime   `json:"updated_at"       yaml:"updated_at"`
 }
 
 // Validate checks that the value contains valid data.
@@ -69,7 +73,8 @@ func (a *Account) Validate() error {
 		}
 
 		switch a.Status.Value {
-		case request.StatusActive, request.StatusInactive:
+		case request.StatusActive, request.StatusInactive,
+			request.StatusMaintenance:
 		default:
 			return errors.New(errors.ErrInvalidRequest,
 				"invalid status",
@@ -94,7 +99,27 @@ func (a *Account) Validate() error {
 		}
 	}
 
-	return nil
+	if err := a.validateBranding(); err != nil {
+		return err
+	}
+
+	if err := a.validateRetention(); err != nil {
+		return err
+	}
+
+	if err := a.validateNotify(); err != nil {
+		return err
+	}
+
+	if err := a.validateTokenTrust(); err != nil {
+		return err
+	}
+
+	if err := a.validateQuotas(); err != nil {
+		return err
+	}
+
+	return a.validateTimeZone()
 }
 
 // ValidateCreate checks that the value contains valid data for creation.
@@ -200,6 +225,18 @@ func (s *Service) GetAccount(ctx context.Context,
 			"id", id)
 	}
 
+	// Accounts retrieved more than once during a request, such as by the
+	// authentication middleware and the handler, are only retrieved once.
+	return cache.Memoize(ctx, cache.KeyAccount(id),
+		func(ctx context.Context) (*Account, error) {
+			return s.getAccount(ctx, id)
+		})
+}
+
+// getAccount retrieves an account from the cache, or the database.
+func (s *Service) getAccount(ctx context.Context,
+	id string,
+) (*Account, error) {
 	var r *Account
 
 	if s.cache != nil {
@@ -226,66 +263,78 @@ func (s *Service) GetAccount(ctx context.Context,
 		}
 	}
 
-	if r == nil {
-		base := sqldb.SelectFields("account", accountFields, nil, nil) +
-			`WHERE account.account_id = $1`
+	if r != nil {
+		return r, nil
+	}
 
-		q := sqldb.NewQuery(&sqldb.QueryOptions{
-			DB:     s.db,
-			Type:   sqldb.QuerySelect,
-			Base:   base,
-			Fields: accountFields,
-			Params: []any{id},
+	// Concurrent loads of the same uncached account are coalesced, so that
+	// the account is only read from the database, and cached, once.
+	return cache.Load(ctx, &s.loads, cache.KeyAccount(id),
+		func(ctx context.Context) (*Account, error) {
+			return s.loadAccount(ctx, id)
 		})
+}
 
-		q.Limit = 1
+// loadAccount reads an account from the database, and caches it.
+func (s *Service) loadAccount(ctx context.Context,
+	id string,
+) (*Account, error) {
+	base := sqldb.SelectFields("account", accountFields, nil, nil) +
+		`WHERE account.account_id = $1`
 
-		row, err := q.QueryRow(ctx)
-		if err != nil {
-			return nil, errors.Wrap(err, errors.ErrDatabase, "",
-				"id", id)
-		}
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: accountFields,
+		Params: []any{id},
+	})
 
-		r = &Account{}
+	q.Limit = 1
 
-		if err := row.Scan(r.ScanDest()...); err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New(errors.ErrNotFound,
-					"account not found",
-					"id", id)
-			}
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id)
+	}
 
-			return nil, errors.Wrap(err, errors.ErrDatabase,
-				"unable to select account row",
+	r := &Account{}
+
+	if err := row.Scan(r.ScanDest()...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"account not found",
 				"id", id)
 		}
 
-		if s.cache != nil {
-			ck := cache.KeyAccount(r.AccountID.Value)
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select account row",
+			"id", id)
+	}
 
-			buf, err := json.Marshal(r)
-			if err != nil {
-				s.log.Log(ctx, logger.LvlError,
-					"unable to encode account cache value",
-					"error", err,
-					"cache_key", ck,
-					"cache_value", r,
-					"id", id)
-			} else if len(buf) < s.cfg.CacheMaxBytes() {
-				if err := s.cache.Set(ctx, &cache.Item{
-					Key:        ck,
-					Value:      buf,
-					Expiration: s.cfg.CacheExpiration(),
-				}); err != nil {
-					s.log.Log(ctx, logger.LvlError,
-						"unable to set account cache value",
-						"error", err,
-						"cache_key", ck,
-						"cache_value", string(buf),
-						"expiration", s.cfg.CacheExpiration(),
-						"id", id)
-				}
-			}
+	if s.cache != nil {
+		ck := cache.KeyAccount(r.AccountID.Value)
+
+		buf, err := json.Marshal(r)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to encode account cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", r,
+				"id", id)
+		} else if err := s.cache.Set(ctx, &cache.Item{
+			Key:        ck,
+			Value:      buf,
+			Expiration: s.cfg.CacheExpiration(),
+		}); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to set account cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", string(buf),
+				"expiration", s.cfg.CacheExpiration(),
+				"id", id)
 		}
 	}
 
@@ -384,20 +433,18 @@ func (s *Service) GetAccountByName(ctx context.Context,
 					"cache_key", ck,
 					"cache_value", r,
 					"name", name)
-			} else if len(buf) < s.cfg.CacheMaxBytes() {
-				if err := s.cache.Set(ctx, &cache.Item{
-					Key:        ck,
-					Value:      buf,
-					Expiration: s.cfg.CacheExpiration(),
-				}); err != nil {
-					s.log.Log(ctx, logger.LvlError,
-						"unable to set account name cache value",
-						"error", err,
-						"cache_key", ck,
-						"cache_value", string(buf),
-						"expiration", s.cfg.CacheExpiration(),
-						"name", name)
-				}
+			} else if err := s.cache.Set(ctx, &cache.Item{
+				Key:        ck,
+				Value:      buf,
+				Expiration: s.cfg.CacheExpiration(),
+			}); err != nil {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to set account name cache value",
+					"error", err,
+					"cache_key", ck,
+					"cache_value", string(buf),
+					"expiration", s.cfg.CacheExpiration(),
+					"name", name)
 			}
 		}
 	}
@@ -457,7 +504,24 @@ func (s *Service) CreateAccount(ctx context.Context,
 		return nil, err
 	}
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, v.AccountID.Value)
+	ctx = request.WithAccountID(ctx, v.AccountID.Value)
+
+	if accountID != "" {
+		// Only system administrators can place accounts into maintenance.
+		if v.Status.Value == request.StatusMaintenance {
+			return nil, errors.New(errors.ErrForbidden,
+				"unable to place account into maintenance",
+				"account", v)
+		}
+
+		if err := s.keepQuotas(ctx, v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.applyTemplate(ctx, v); err != nil {
+		return nil, err
+	}
 
 	base := `INSERT INTO account () VALUES ()
 		ON CONFLICT (account_id) DO UPDATE SET` +
@@ -504,19 +568,44 @@ func (s *Service) CreateAccount(ctx context.Context,
 			"account", v)
 	}
 
-	if s.cache != nil {
-		ck := cache.KeyAccount(r.AccountID.Value)
+	s.deleteAccountCache(ctx, r.AccountID.Value, r.Name.Value)
 
-		if err := s.cache.Delete(ctx, ck); err != nil &&
-			!errors.Has(err, errors.ErrNotFound) {
-			s.log.Log(ctx, logger.LvlError,
-				"unable to delete account cache key",
-				"error", err,
-				"cache_key", ck,
-				"account", v)
-		}
+	return r, nil
+}
+
+// deleteAccountCache removes any cached values for an account, by ID and by
+// each of the names given, and flushes the values cached for its users and
+// resources. The values retrieved earlier in the request are also forgotten.
+func (s *Service) deleteAccountCache(ctx context.Context,
+	id string,
+	names ...string,
+) {
+	cache.ForgetAll(ctx)
+
+	if s.cache == nil {
+		return
+	}
+
+	if err := cache.FlushAccount(ctx, s.cache, id); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to flush account cache",
+			"error", err,
+			"id", id)
+	}
+
+	ck := cache.KeyAccount(id)
+
+	if err := s.cache.Delete(ctx, ck); err != nil &&
+		!errors.Has(err, errors.ErrNotFound) {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to delete account cache key",
+			"error", err,
+			"cache_key", ck,
+			"id", id)
+	}
 
-		ck = cache.KeyAccountName(r.Name.Value)
+	for _, name := range names {
+		ck = cache.KeyAccountName(name)
 
 		if err := s.cache.Delete(ctx, ck); err != nil &&
 			!errors.Has(err, errors.ErrNotFound) {
@@ -524,18 +613,187 @@ func (s *Service) CreateAccount(ctx context.Context,
 				"unable to delete account name cache key",
 				"error", err,
 				"cache_key", ck,
+				"id", id,
+				"name", name)
+		}
+	}
+}
+
+// GetAccounts retrieves accounts, across all tenants, based on a search
+// query. Only system administrators can retrieve other accounts.
+func (s *Service) GetAccounts(ctx context.Context,
+	query *search.Query,
+) ([]*Account, error) {
+	if !request.ContextHasScope(ctx, request.ScopeSuperuser) {
+		return nil, errors.New(errors.ErrForbidden,
+			"unable to get accounts",
+			"search", query)
+	}
+
+	ctx = request.WithAccountID(ctx, request.SystemAccount)
+
+	query = query.NoSummary()
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   sqldb.SelectFields("account", accountFields, query, nil),
+		Search: query,
+		Fields: accountFields,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res := []*Account{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		a := &Account{}
+
+		if err := rows.Scan(a.ScanDest()...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select account row",
+				"search", query)
+		}
+
+		res = append(res, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select account rows",
+			"search", query)
+	}
+
+	return res, nil
+}
+
+// UpdateAccount updates any account. Only system administrators can update
+// other accounts. When an account is made inactive, its secret is replaced,
+// which invalidates all of the tokens issued for the account.
+func (s *Service) UpdateAccount(ctx context.Context,
+	v *Account,
+) (*Account, error) {
+	if !request.ContextHasScope(ctx, request.ScopeSuperuser) {
+		return nil, errors.New(errors.ErrForbidden,
+			"unable to update account",
+			"account", v)
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing account",
+			"account", v)
+	}
+
+	if !v.AccountID.Set || !v.AccountID.Valid {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing account_id",
+			"account", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	id := v.AccountID.Value
+
+	ctx = request.WithAccountID(ctx, request.SystemAccount)
+
+	old, err := s.loadAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	base := `UPDATE account SET
+		WHERE account.account_id = $1` +
+		sqldb.ReturningFields("account", accountFields, nil)
+
+	sets, params := []string{}, []any{id}
+
+	request.SetField("name", v.Name, &sets, &params)
+	request.SetField("status", v.Status, &sets, &params)
+	request.SetField("status_data", v.StatusData, &sets, &params)
+	request.SetField("repo_status", v.RepoStatus, &sets, &params)
+	request.SetField("repo_status_data", v.RepoStatusData, &sets, &params)
+	request.SetField("data", v.Data, &sets, &params)
+
+	if v.Status.Value == request.StatusInactive {
+		request.SetField("secret", request.FieldString{
+			Set: true, Valid: true, Value: uuid.NewString(),
+		}, &sets, &params)
+	}
+
+	request.SetField("updated_at", request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryUpdate,
+		Base:   base,
+		Fields: accountFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"account", v)
+	}
+
+	r := &Account{}
+
+	if err := row.Scan(r.ScanDest()...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"account not found",
 				"account", v)
 		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update account row",
+			"account", v)
 	}
 
+	s.deleteAccountCache(ctx, id, old.Name.Value, r.Name.Value)
+
 	return r, nil
 }
 
-// AccountRepo values represent an account import repository.
+// DeactivateAccount makes any account inactive, invalidating all of the
+// tokens issued for the account. Only system administrators can deactivate
+// accounts.
+func (s *Service) DeactivateAccount(ctx context.Context,
+	id string,
+) (*Account, error) {
+	return s.UpdateAccount(ctx, &Account{
+		AccountID: request.FieldString{Set: true, Valid: true, Value: id},
+		Status: request.FieldString{
+			Set: true, Valid: true, Value: request.StatusInactive,
+		},
+	})
+}
+
+// AccountRepo values represent an account import repository. The repository
+// filter, if set, limits the files imported from the repository.
 type AccountRepo struct {
-	Repo           request.FieldString `json:"repo"`
-	RepoStatus     request.FieldString `json:"repo_status"`
-	RepoStatusData request.FieldJSON   `json:"repo_status_data"`
+	Repo           request.FieldString `json:"repo"             yaml:"repo"`
+	RepoStatus     request.FieldString `json:"repo_status"      yaml:"repo_status"`
+	RepoStatusData request.FieldJSON   `json:"repo_status_data" yaml:"repo_status_data"`
+	RepoFilter     request.FieldJSON   `json:"repo_filter"      yaml:"repo_filter"`
 }
 
 // GetAccountRepo retrieves the account repository from the database.
@@ -550,7 +808,8 @@ func (s *Service) GetAccountRepo(ctx context.Context) (*AccountRepo, error) {
 	base := `SELECT
 		account.repo,
 		account.repo_status,
-		account.repo_status_data
+		account.repo_status_data,
+		account.repo_filter
 	FROM account
 	LIMIT 1`
 
@@ -569,7 +828,8 @@ func (s *Service) GetAccountRepo(ctx context.Context) (*AccountRepo, error) {
 
 	r := &AccountRepo{}
 
-	if err := row.Scan(&r.Repo, &r.RepoStatus, &r.RepoStatusData); err != nil {
+	if err := row.Scan(&r.Repo, &r.RepoStatus, &r.RepoStatusData,
+		&r.RepoFilter); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errors.New(errors.ErrNotFound,
 				"unable to find account repo")
@@ -637,15 +897,22 @@ func (s *Service) SetAccountRepo(ctx context.Context,
 		}
 	}
 
+	if v.RepoFilter.Set && v.RepoFilter.Valid {
+		if _, err := repo.NewFilter(v.RepoFilter.Value); err != nil {
+			return err
+		}
+	}
+
 	base := `UPDATE account SET
 	WHERE account_id = $1
-	RETURNING repo, repo_status, repo_status_data`
+	RETURNING repo, repo_status, repo_status_data, repo_filter`
 
 	sets, params := []string{}, []any{accountID}
 
 	request.SetField("repo", v.Repo, &sets, &params)
 	request.SetField("repo_status", v.RepoStatus, &sets, &params)
 	request.SetField("repo_status_data", v.RepoStatusData, &sets, &params)
+	request.SetField("repo_filter", v.RepoFilter, &sets, &params)
 
 	q := sqldb.NewQuery(&sqldb.QueryOptions{
 		DB:     s.db,
@@ -664,7 +931,8 @@ func (s *Service) SetAccountRepo(ctx context.Context,
 
 	r := &AccountRepo{}
 
-	if err := row.Scan(&r.Repo, &r.RepoStatus, &r.RepoStatusData); err != nil {
+	if err := row.Scan(&r.Repo, &r.RepoStatus, &r.RepoStatusData,
+		&r.RepoFilter); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return errors.New(errors.ErrNotFound,
 				"unable to find account to set repo data")
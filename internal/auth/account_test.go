@@ -1,12 +1,18 @@
 package auth_test
 
 import (
-	"context"
+	"encoding/json"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/dhaifley/apigo/internal/auth"
 	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/repo"
 	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
 	"github.com/dhaifley/apigo/internal/sqldb"
 	"github.com/pashagolub/pgxmock/v4"
 )
@@ -59,7 +65,8 @@ var TestAccount = auth.Account{
 func mockTransaction(mock pgxmock.PgxCommonIface) {
 	mock.ExpectBegin()
 
-	mock.ExpectExec("SET app.account_id").
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
 		WillReturnResult(pgxmock.NewResult("SET", 1))
 }
 
@@ -96,10 +103,12 @@ func mockAccountRepoRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
 		"repo",
 		"repo_status",
 		"repo_status_data",
+		"repo_filter",
 	}).AddRow(
 		TestAccount.Repo.Value,
 		TestAccount.RepoStatus.Value,
 		TestAccount.RepoStatusData.Value,
+		map[string]any{"path": "*.yaml"},
 	)
 }
 
@@ -220,7 +229,7 @@ func TestCreateAccount(t *testing.T) {
 
 	ctx := mockAuthContext()
 
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, request.ScopeSuperuser)
+	ctx = request.WithScopes(ctx, request.ScopeSuperuser)
 
 	mc := &cache.MockCache{}
 
@@ -267,6 +276,232 @@ func TestCreateAccount(t *testing.T) {
 	}
 }
 
+func TestCreateAccountMaintenance(t *testing.T) {
+	t.Parallel()
+
+	ctx := request.WithScopes(mockAuthContext(), request.ScopeAccountAdmin)
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, nil, nil, nil, nil)
+
+	v := TestAccount
+
+	v.Status = request.FieldString{
+		Set: true, Valid: true, Value: request.StatusMaintenance,
+	}
+
+	if _, err := svc.CreateAccount(ctx, &v); !errors.Has(err,
+		errors.ErrForbidden) {
+		t.Errorf("Expected forbidden error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestCreateAccountTemplate(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	ctx = request.WithScopes(ctx, request.ScopeSuperuser)
+
+	cfg := config.NewDefault()
+
+	cfg.SetService(&config.ServiceConfig{
+		AccountTemplateRepo: repo.StarterURL,
+	})
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, &cache.MockCache{}, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestAccount.AccountID.Value).
+		WillReturnRows(mock.NewRows([]string{"account_id"}))
+
+	mockTransaction(mock)
+
+	args := make([]any, 9)
+
+	for i := 0; i < 9; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("INSERT INTO account").
+		WithArgs(args...).WillReturnRows(mockAccountRows(mock))
+
+	v := TestAccount
+
+	if _, err := svc.CreateAccount(ctx, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if tz, ok := v.Data.Value["time_zone"]; !ok || tz != "UTC" {
+		t.Errorf("Expected template time_zone: UTC, got: %v", tz)
+	}
+
+	if d, ok := v.Data.Value["test"]; !ok || d != "test" {
+		t.Errorf("Expected account data to be kept: test, got: %v", d)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestGetAccounts(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	res, err := svc.GetAccounts(ctx, &search.Query{
+		Search: "and(name:*)",
+		Size:   10,
+		Skip:   0,
+		Sort:   "name",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) <= 0 {
+		t.Fatal("Expected length to be greater than 0")
+	}
+
+	if res[0].AccountID.Value != TestAccount.AccountID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestAccount.AccountID.Value, res[0].AccountID.Value)
+	}
+
+	ctx = request.WithScopes(ctx, request.ScopeAccountAdmin)
+
+	if _, err := svc.GetAccounts(ctx, &search.Query{}); err == nil {
+		t.Error("Expected error for account administrator")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestUpdateAccount(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	mockTransaction(mock)
+
+	args := make([]any, 8)
+
+	for i := 0; i < 8; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE account").
+		WithArgs(args...).WillReturnRows(mockAccountRows(mock))
+
+	res, err := svc.UpdateAccount(ctx, &TestAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.AccountID.Value != TestAccount.AccountID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestAccount.AccountID.Value, res.AccountID.Value)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestDeactivateAccount(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	mockTransaction(mock)
+
+	// The account ID, status, replaced secret and update time.
+	args := make([]any, 4)
+
+	for i := 0; i < 4; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE account").
+		WithArgs(args...).WillReturnRows(mockAccountRows(mock))
+
+	if _, err := svc.DeactivateAccount(ctx, TestID); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
 func TestGetAccountRepo(t *testing.T) {
 	t.Parallel()
 
@@ -327,7 +562,293 @@ func TestSetAccountRepo(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	if err := svc.SetAccountRepo(ctx, &auth.AccountRepo{
+		RepoFilter: request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{"path": "["},
+		},
+	}); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("Unmet database expectations: %v", err)
 	}
 }
+
+func TestAccountBranding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		branding any
+		valid    bool
+		exp      auth.Branding
+	}{{
+		name: "valid",
+		branding: map[string]any{
+			"logo_url":      "https://apigo.io/logo.png",
+			"product_name":  "Test Product",
+			"contact_email": "test@apigo.io",
+		},
+		valid: true,
+		exp: auth.Branding{
+			LogoURL:      "https://apigo.io/logo.png",
+			ProductName:  "Test Product",
+			ContactEmail: "test@apigo.io",
+		},
+	}, {
+		name:  "missing",
+		valid: true,
+	}, {
+		name:     "not object",
+		branding: "test",
+	}, {
+		name:     "invalid logo_url",
+		branding: map[string]any{"logo_url": "javascript:alert(1)"},
+	}, {
+		name:     "invalid contact_email",
+		branding: map[string]any{"contact_email": "test"},
+	}, {
+		name:     "invalid key",
+		branding: map[string]any{"test": "test"},
+	}, {
+		name:     "invalid value",
+		branding: map[string]any{"product_name": 1},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := map[string]any{}
+
+			if tt.branding != nil {
+				data["branding"] = tt.branding
+			}
+
+			a := &auth.Account{
+				Data: request.FieldJSON{Set: true, Valid: true, Value: data},
+			}
+
+			err := a.Validate()
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.valid {
+				if err == nil {
+					t.Fatal("Expected validation error")
+				}
+
+				return
+			}
+
+			if b := a.Branding(); *b != tt.exp {
+				t.Errorf("Expected branding: %+v, got: %+v", tt.exp, *b)
+			}
+		})
+	}
+}
+
+func TestAccountTimeZone(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		timeZone any
+		valid    bool
+		exp      string
+	}{{
+		name:     "valid",
+		timeZone: "America/New_York",
+		valid:    true,
+		exp:      "America/New_York",
+	}, {
+		name:  "missing",
+		valid: true,
+	}, {
+		name:     "not string",
+		timeZone: 1,
+	}, {
+		name:     "invalid",
+		timeZone: "Mars/Olympus_Mons",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := map[string]any{}
+
+			if tt.timeZone != nil {
+				data["time_zone"] = tt.timeZone
+			}
+
+			a := &auth.Account{
+				Data: request.FieldJSON{Set: true, Valid: true, Value: data},
+			}
+
+			err := a.Validate()
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.valid {
+				if err == nil {
+					t.Fatal("Expected validation error")
+				}
+
+				return
+			}
+
+			loc := a.TimeZone()
+
+			if tt.exp == "" && loc != nil {
+				t.Errorf("Expected no time zone, got: %v", loc)
+			}
+
+			if tt.exp != "" && (loc == nil || loc.String() != tt.exp) {
+				t.Errorf("Expected time zone: %v, got: %v", tt.exp, loc)
+			}
+		})
+	}
+}
+
+func TestAccountRetention(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		retention any
+		valid     bool
+		exp       time.Duration
+	}{{
+		name:      "valid",
+		retention: json.Number("86400"),
+		valid:     true,
+		exp:       time.Hour * 24,
+	}, {
+		name:      "float",
+		retention: float64(60),
+		valid:     true,
+		exp:       time.Minute,
+	}, {
+		name:      "zero",
+		retention: json.Number("0"),
+		valid:     true,
+	}, {
+		name:  "missing",
+		valid: true,
+		exp:   time.Hour,
+	}, {
+		name:      "negative",
+		retention: json.Number("-1"),
+	}, {
+		name:      "fractional",
+		retention: float64(1.5),
+	}, {
+		name:      "not number",
+		retention: "30d",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := map[string]any{}
+
+			if tt.retention != nil {
+				data["retention"] = tt.retention
+			}
+
+			a := &auth.Account{
+				Data: request.FieldJSON{Set: true, Valid: true, Value: data},
+			}
+
+			err := a.Validate()
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.valid {
+				if err == nil {
+					t.Fatal("Expected validation error")
+				}
+
+				return
+			}
+
+			if r := a.Retention(time.Hour); r != tt.exp {
+				t.Errorf("Expected retention: %v, got: %v", tt.exp, r)
+			}
+		})
+	}
+}
+
+func TestAccountNotifyEmails(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		notify any
+		valid  bool
+		exp    []string
+	}{{
+		name: "valid",
+		notify: map[string]any{
+			"emails": []any{"ops@example.com", "Test <test@example.com>"},
+		},
+		valid: true,
+		exp:   []string{"ops@example.com", "Test <test@example.com>"},
+	}, {
+		name:  "missing",
+		valid: true,
+		exp:   []string{},
+	}, {
+		name:   "not object",
+		notify: "ops@example.com",
+	}, {
+		name: "invalid key",
+		notify: map[string]any{
+			"phones": []any{"555-0100"},
+		},
+	}, {
+		name: "invalid email",
+		notify: map[string]any{
+			"emails": []any{"ops"},
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := map[string]any{}
+
+			if tt.notify != nil {
+				data["notify"] = tt.notify
+			}
+
+			a := &auth.Account{
+				Data: request.FieldJSON{Set: true, Valid: true, Value: data},
+			}
+
+			err := a.Validate()
+			if tt.valid && err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.valid {
+				if err == nil {
+					t.Fatal("Expected validation error")
+				}
+
+				return
+			}
+
+			if r := a.NotifyEmails(); !slices.Equal(r, tt.exp) {
+				t.Errorf("Expected emails: %v, got: %v", tt.exp, r)
+			}
+		})
+	}
+}
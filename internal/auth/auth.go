@@ -10,28 +10,67 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/dhaifley/apigo/internal/cache"
 	"github.com/dhaifley/apigo/internal/config"
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/logger"
 	"github.com/dhaifley/apigo/internal/metric"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/sqldb"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // Claims values contain token claims information.
 type Claims struct {
-	AccountID   string `json:"account_id"`
-	AccountName string `json:"account_name"`
-	UserID      string `json:"user_id"`
-	Scopes      string `json:"scopes"`
+	AccountID    string `json:"account_id"`
+	AccountName  string `json:"account_name"`
+	UserID       string `json:"user_id"`
+	Scopes       string `json:"scopes"`
+	KeyExpiresAt int64  `json:"key_expires_at,omitempty"`
+}
+
+// Token values contain an API access token, as returned by login requests.
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// TokenRequest values contain a password authentication request, as submitted
+// by login and session requests.
+type TokenRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Scope    string `json:"scope"`
+}
+
+// Validate checks that the value contains valid data. All missing and invalid
+// fields are reported in the returned error.
+func (t *TokenRequest) Validate() error {
+	f := errors.FieldErrors{}
+
+	switch {
+	case t.Username == "":
+		f.Add("username", errors.FieldRequired, "missing username")
+	case !request.ValidUserID(t.Username):
+		f.Add("username", errors.FieldInvalid, "invalid username")
+	}
+
+	if t.Password == "" {
+		f.Add("password", errors.FieldRequired, "missing password")
+	}
+
+	if t.Scope != "" && !request.ValidScopes(t.Scope) {
+		f.Add("scope", errors.FieldInvalid, "invalid scope")
+	}
+
+	return f.Err("username", t.Username)
 }
 
 // Service values are used to provide access to authentication services.
@@ -39,9 +78,11 @@ type Service struct {
 	cfg    *config.Config
 	db     sqldb.SQLDB
 	cache  cache.Accessor
+	client *httpclient.Client
 	log    logger.Logger
 	metric metric.Recorder
 	tracer trace.Tracer
+	loads  cache.Group
 }
 
 // NewService creates a new authentication service.
@@ -86,68 +127,49 @@ func NewService(
 		cfg:    cfg,
 		db:     db,
 		cache:  cache,
+		client: httpclient.NewClient(cfg, log, metric, tracer),
 		log:    log,
 		metric: metric,
 		tracer: tracer,
 	}
 }
 
-// getAccountSecret retrieves an encryption secret from the database by
-// account ID.
-func (s *Service) getAccountSecret(ctx context.Context, accountID string,
-) ([]byte, error) {
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, accountID)
-
-	base := `SELECT account.secret
-	FROM account
-	LIMIT 1`
-
-	q := sqldb.NewQuery(&sqldb.QueryOptions{
-		DB:   s.db,
-		Type: sqldb.QuerySelect,
-		Base: base,
-	})
-
-	q.Limit = 1
-
-	row, err := q.QueryRow(ctx)
-	if err != nil {
-		return nil, errors.Wrap(err, errors.ErrDatabase, "")
-	}
-
-	var r *string
-
-	if err := row.Scan(&r); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, errors.New(errors.ErrNotFound,
-				"unable to find account secret")
-		}
+// AuthJWT authenticates using a JWT token. Failed attempts are counted by
+// client address and account, and client addresses with too many failures are
+// locked out for a time.
+func (s *Service) AuthJWT(ctx context.Context,
+	token, tenant string,
+) (*Claims, error) {
+	remote := contextRemote(ctx)
 
-		return nil, errors.Wrap(err, errors.ErrDatabase,
-			"unable to select account secret row")
+	if err := s.checkAuthLockout(ctx, remote); err != nil {
+		return nil, err
 	}
 
-	if r == nil {
-		return nil, errors.New(errors.ErrNotFound,
-			"account secret not found")
+	res, err := s.authJWT(ctx, token, tenant)
+	if err != nil && token != "" && errors.Has(err, errors.ErrUnauthorized) {
+		s.recordAuthFailure(ctx, remote, tokenAccountID(token), err)
 	}
 
-	return []byte(*r), nil
+	return res, err
 }
 
-// AuthJWT authenticates using a JWT token.
-func (s *Service) AuthJWT(ctx context.Context,
+// authJWT verifies a JWT token, and retrieves its claims.
+func (s *Service) authJWT(ctx context.Context,
 	token, tenant string,
 ) (*Claims, error) {
 	res := &Claims{}
 
 	tenantID := ""
 
+	// Tokens for inactive accounts are also invalidated when the account
+	// secret is replaced, but tokens signed using the server token keys are
+	// only rejected here.
 	if tenant != "" {
-		aCtx := context.WithValue(ctx, request.CtxKeyAccountID, "sys")
+		aCtx := request.WithAccountID(ctx, request.SystemAccount)
 
 		a, err := s.GetAccountByName(aCtx, tenant)
-		if err != nil {
+		if err != nil || a.Status.Value == request.StatusInactive {
 			return nil, errors.New(errors.ErrUnauthorized,
 				"invalid tenant",
 				"token", token,
@@ -157,6 +179,8 @@ func (s *Service) AuthJWT(ctx context.Context,
 		tenantID = a.AccountID.Value
 	}
 
+	keyExpiresAt := int64(0)
+
 	tok, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
 		switch token.Method.(type) {
 		case *jwt.SigningMethodHMAC:
@@ -167,7 +191,14 @@ func (s *Service) AuthJWT(ctx context.Context,
 					"token", token)
 			}
 
-			return s.getAccountSecret(ctx, kid)
+			k, err := s.getAccountKey(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+
+			keyExpiresAt = k.expiresAt
+
+			return k.secret, nil
 		case *jwt.SigningMethodECDSA:
 			key, err := jwt.ParseECPublicKeyFromPEM(
 				s.cfg.AuthTokenPublicKey())
@@ -210,7 +241,8 @@ func (s *Service) AuthJWT(ctx context.Context,
 				"invalid authentication token signing method",
 				"token", token)
 		}
-	})
+	}, jwt.WithLeeway(s.cfg.AuthTokenLeeway()), jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt())
 	if err != nil {
 		s.log.Log(ctx, logger.LvlDebug,
 			"unable to parse authentication token",
@@ -235,14 +267,37 @@ func (s *Service) AuthJWT(ctx context.Context,
 			"token", token)
 	}
 
+	// Tokens signed using account keys are issued by the account of the key,
+	// and other tokens by the tenant, or service, account.
+	issuerID := tenantID
+
+	if _, ok := tok.Method.(*jwt.SigningMethodHMAC); ok {
+		kid, _ := tok.Header["kid"].(string)
+
+		issuerID, _ = parseKeyID(kid)
+	} else if issuerID == "" {
+		issuerID = s.cfg.ServiceName()
+	}
+
+	if err := s.verifyIssuer(ctx, claims, issuerID); err != nil {
+		s.log.Log(ctx, logger.LvlDebug,
+			"authentication token issuer not accepted",
+			"error", err,
+			"token", token,
+			"tenant", tenant,
+			"claims", claims)
+
+		return nil, errors.New(errors.ErrUnauthorized,
+			"invalid authentication token",
+			"token", token)
+	}
+
 	res.AccountID = s.cfg.ServiceName()
 	res.AccountName = s.cfg.ServiceName()
 
 	ca, err := request.ContextAccountID(ctx)
 	if err != nil || ca != request.SystemAccount {
-		ctx = context.WithValue(ctx, request.CtxKeyAccountID, res.AccountID)
-		ctx = context.WithValue(ctx, request.CtxKeyScopes,
-			request.ScopeSuperuser)
+		ctx = request.Elevate(ctx, res.AccountID)
 
 		oa, err := s.GetAccount(ctx, res.AccountID)
 		if err != nil && !errors.Has(err, errors.ErrNotFound) {
@@ -273,13 +328,44 @@ func (s *Service) AuthJWT(ctx context.Context,
 
 	res.Scopes, _ = claims["scopes"].(string)
 
+	// Tokens signed using a replaced signing key are accepted until the key
+	// expires, so that they can be issued again before then.
+	res.KeyExpiresAt = keyExpiresAt
+
+	// The scopes of restricted tokens are limited to those still held by their
+	// user, in the account which issued the token, and are not extended by
+	// the groups of the user.
+	restricted, _ := claims["restricted"].(bool)
+
+	if restricted {
+		kid, _ := tok.Header["kid"].(string)
+		sub, _ := claims["sub"].(string)
+
+		accountID, _ := parseKeyID(kid)
+
+		held, err := s.heldScopes(ctx, accountID, sub, res.Scopes)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlDebug,
+				"unable to get held scopes for restricted token",
+				"error", err,
+				"token", token,
+				"claims", claims)
+
+			return nil, errors.New(errors.ErrUnauthorized,
+				"invalid authentication token",
+				"token", token)
+		}
+
+		res.Scopes = held
+	}
+
 	sysAdmin := false
 
 	if strings.Contains(res.Scopes, request.ScopeSuperuser) {
 		sysAdmin = true
 
 		if aID, err := request.ContextAccountID(ctx); err == nil {
-			ctx = context.WithValue(ctx, request.CtxKeyAccountID, aID)
+			ctx = request.WithAccountID(ctx, aID)
 
 			res.AccountID = aID
 		}
@@ -290,7 +376,7 @@ func (s *Service) AuthJWT(ctx context.Context,
 		res.AccountID = tenantID
 	}
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, res.AccountID)
+	ctx = request.WithAccountID(ctx, res.AccountID)
 
 	uID, ok := claims["sub"].(string)
 	if !ok {
@@ -321,92 +407,64 @@ func (s *Service) AuthJWT(ctx context.Context,
 
 	res.UserID = uID
 
-	return res, nil
-}
-
-// AuthPassword authenticates using a user password.
-func (s *Service) AuthPassword(ctx context.Context,
-	userID, password, tenant string,
-) error {
-	if !request.ValidUserID(userID) {
-		return errors.New(errors.ErrInvalidParameter, "invalid user_id",
-			"user_id", userID)
-	}
-
-	aID := ""
-
-	if tenant != "" {
-		aCtx := context.WithValue(ctx, request.CtxKeyAccountID, "sys")
-
-		a, err := s.GetAccountByName(aCtx, tenant)
+	// Scopes granted by the groups of the user are added to those of the
+	// token. If they can not be retrieved, the request is authenticated with
+	// only the scopes of the token.
+	if !sysAdmin && !restricted {
+		gs, err := s.groupScopes(ctx, uID)
 		if err != nil {
-			return errors.New(errors.ErrUnauthorized,
-				"invalid tenant",
-				"tenant", tenant)
+			s.log.Log(ctx, logger.LvlError,
+				"unable to get group scopes",
+				"error", err,
+				"account_id", res.AccountID,
+				"user_id", uID)
 		}
 
-		aID = a.AccountID.Value
-	} else {
-		aID = s.cfg.ServiceName()
+		res.Scopes = mergeScopes(res.Scopes, gs)
 	}
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, aID)
-
-	base := `SELECT password FROM "user"
-		WHERE "user".user_id = $1`
+	// Uses of tokens issued by accounts are counted, to be recorded by the
+	// next token usage update.
+	if _, ok := tok.Method.(*jwt.SigningMethodHMAC); ok {
+		kid, _ := tok.Header["kid"].(string)
 
-	q := sqldb.NewQuery(&sqldb.QueryOptions{
-		DB:     s.db,
-		Type:   sqldb.QuerySelect,
-		Base:   base,
-		Fields: userFields,
-		Params: []any{userID},
-	})
+		accountID, _ := parseKeyID(kid)
 
-	q.Limit = 1
-
-	row, err := q.QueryRow(ctx)
-	if err != nil {
-		return errors.Wrap(err, errors.ErrDatabase, "",
-			"user_id", userID)
+		if jti, ok := claims["jti"].(string); ok && accountID != "" {
+			if _, err := uuid.Parse(jti); err == nil {
+				addTokenUses(accountID, jti, 1, time.Now())
+			}
+		}
 	}
 
-	hp := new(string)
-
-	if err := row.Scan(&hp); err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return errors.New(errors.ErrNotFound,
-				"user not found",
-				"user_id", userID)
-		}
+	return res, nil
+}
 
-		return errors.Wrap(err, errors.ErrDatabase,
-			"unable to select user password",
+// AuthPassword authenticates using a user password. Users are locked out for
+// a time after too many consecutive failed attempts.
+func (s *Service) AuthPassword(ctx context.Context,
+	userID, password, tenant string,
+) error {
+	if !request.ValidUserID(userID) {
+		return errors.New(errors.ErrInvalidParameter, "invalid user_id",
 			"user_id", userID)
 	}
 
-	if hp == nil || *hp == "" {
-		return errors.New(errors.ErrUnauthorized,
-			"user cannot login",
-			"user_id", userID)
+	aID, err := s.TokenAccountID(ctx, tenant)
+	if err != nil {
+		return err
 	}
 
-	if err := verifyPassword(*hp, password); err != nil {
-		return errors.New(errors.ErrUnauthorized,
-			"invalid user id or password",
-			"user_id", userID)
-	}
+	ctx = request.WithAccountID(ctx, aID)
 
-	return nil
+	return s.checkPassword(ctx, userID, password)
 }
 
 // Update periodically updates authentication data.
 func (s *Service) Update(ctx context.Context) context.CancelFunc {
 	ctx, cancel := context.WithCancel(ctx)
 
-	if tu, err := uuid.NewRandom(); err == nil {
-		ctx = context.WithValue(ctx, request.CtxKeyTraceID, tu.String())
-	}
+	ctx = request.WithNewTraceID(ctx)
 
 	go func(ctx context.Context) {
 		tick := time.NewTimer(0)
@@ -423,10 +481,7 @@ func (s *Service) Update(ctx context.Context) context.CancelFunc {
 				ctx, cancel := request.ContextReplaceTimeout(ctx,
 					s.cfg.AuthUpdateInterval())
 
-				if tu, err := uuid.NewRandom(); err == nil {
-					ctx = context.WithValue(ctx, request.CtxKeyTraceID,
-						tu.String())
-				}
+				ctx = request.WithNewTraceID(ctx)
 
 				aid := s.cfg.AuthIdentityDomain()
 				wkp := s.cfg.AuthTokenWellKnown()
@@ -456,9 +511,7 @@ func (s *Service) Update(ctx context.Context) context.CancelFunc {
 					break
 				}
 
-				cli := &http.Client{Timeout: time.Second * 10}
-
-				resp, err := cli.Do(r)
+				resp, err := s.client.Do(r)
 				if err != nil {
 					s.log.Log(ctx, logger.LvlError,
 						"unable to retrieve auth well known info",
@@ -513,7 +566,7 @@ func (s *Service) Update(ctx context.Context) context.CancelFunc {
 					break
 				}
 
-				resp, err = cli.Do(rk)
+				resp, err = s.client.Do(rk)
 				if err != nil {
 					s.log.Log(ctx, logger.LvlError,
 						"unable to retrieve auth JWKS",
@@ -630,27 +683,20 @@ func (s *Service) Update(ctx context.Context) context.CancelFunc {
 	return cancel
 }
 
-// CreateToken is used to create a JWT token that can be used for tokens.
+// CreateToken is used to create a JWT token that can be used for tokens. If
+// no scopes are requested, the token is granted the scopes of the user, which
+// are extended by the groups of the user when the token is used. Otherwise,
+// the token is restricted to the requested scopes, which must be held by the
+// user, and remain limited to those the user holds when the token is used.
+// Tokens are recorded in the account, so that their use can be reported.
 func (s *Service) CreateToken(ctx context.Context,
 	userID string,
 	expiration int64,
 	scopes, tenant string,
 ) (string, error) {
-	accountID := ""
-
-	if tenant != "" {
-		aCtx := context.WithValue(ctx, request.CtxKeyAccountID, "sys")
-
-		a, err := s.GetAccountByName(aCtx, tenant)
-		if err != nil {
-			return "", errors.New(errors.ErrUnauthorized,
-				"invalid tenant",
-				"tenant", tenant)
-		}
-
-		accountID = a.AccountID.Value
-	} else {
-		accountID = s.cfg.ServiceName()
+	accountID, err := s.TokenAccountID(ctx, tenant)
+	if err != nil {
+		return "", err
 	}
 
 	if !request.ValidUserID(userID) {
@@ -659,10 +705,35 @@ func (s *Service) CreateToken(ctx context.Context,
 			"user_id", userID)
 	}
 
-	if !request.ValidScopes(scopes) {
-		return "", errors.New(errors.ErrInvalidParameter,
-			"invalid scopes",
-			"scopes", scopes)
+	restricted := scopes != ""
+
+	if restricted {
+		if !request.ValidScopes(scopes) {
+			return "", errors.New(errors.ErrInvalidParameter,
+				"invalid scopes",
+				"scopes", scopes)
+		}
+
+		held, err := s.heldScopes(ctx, accountID, userID, scopes)
+		if err != nil {
+			return "", err
+		}
+
+		for _, scope := range strings.Fields(scopes) {
+			if !slices.Contains(strings.Fields(held), scope) {
+				return "", errors.New(errors.ErrForbidden,
+					"unable to create token: scope not held: "+scope,
+					"user_id", userID,
+					"scopes", scopes)
+			}
+		}
+	} else {
+		us, err := s.userScopes(ctx, accountID, userID)
+		if err != nil {
+			return "", err
+		}
+
+		scopes = us
 	}
 
 	now := time.Now()
@@ -673,6 +744,12 @@ func (s *Service) CreateToken(ctx context.Context,
 			"expiration", expiration)
 	}
 
+	tokenID, err := uuid.NewRandom()
+	if err != nil {
+		return "", errors.Wrap(err, errors.ErrServer,
+			"unable to create token ID")
+	}
+
 	claims := jwt.MapClaims{
 		"exp":    expiration,
 		"iat":    now.Unix(),
@@ -680,27 +757,137 @@ func (s *Service) CreateToken(ctx context.Context,
 		"iss":    s.cfg.AuthTokenIssuer(),
 		"sub":    userID,
 		"aud":    []string{s.cfg.ServiceName()},
+		"jti":    tokenID.String(),
 		"scopes": scopes,
 	}
 
+	if restricted {
+		claims["restricted"] = true
+	}
+
+	key, err := s.getActiveAccountKey(ctx, accountID)
+	if err != nil {
+		return "", err
+	}
+
 	tok := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
 
 	tok.Header = map[string]any{
 		"alg": "HS512",
 		"typ": "JWT",
-		"kid": accountID,
+		"kid": key.kid,
 	}
 
-	secret, err := s.getAccountSecret(ctx, accountID)
+	authToken, err := tok.SignedString(key.secret)
 	if err != nil {
+		return "", errors.New(errors.ErrServer,
+			"unable to create token secret")
+	}
+
+	if err := s.createIssuedToken(ctx, accountID, &IssuedToken{
+		TokenID: request.FieldString{
+			Set: true, Valid: true, Value: tokenID.String(),
+		},
+		UserID: request.FieldString{
+			Set: true, Valid: true, Value: userID,
+		},
+		Scopes: request.FieldString{
+			Set: true, Valid: true, Value: scopes,
+		},
+		Restricted: request.FieldBool{
+			Set: true, Valid: true, Value: restricted,
+		},
+		ExpiresAt: request.FieldTime{
+			Set: true, Valid: true, Value: expiration,
+		},
+	}); err != nil {
 		return "", err
 	}
 
-	authToken, err := tok.SignedString(secret)
+	return authToken, nil
+}
+
+// TokenAccountID returns the ID of the account issuing tokens for a tenant,
+// which is the service account if no tenant is specified.
+func (s *Service) TokenAccountID(ctx context.Context,
+	tenant string,
+) (string, error) {
+	if tenant == "" {
+		return s.cfg.ServiceName(), nil
+	}
+
+	aCtx := request.WithAccountID(ctx, request.SystemAccount)
+
+	a, err := s.GetAccountByName(aCtx, tenant)
+	if err != nil || a.Status.Value == request.StatusInactive {
+		return "", errors.New(errors.ErrUnauthorized,
+			"invalid tenant",
+			"tenant", tenant)
+	}
+
+	return a.AccountID.Value, nil
+}
+
+// userScopes returns the scopes of an active user of an account.
+func (s *Service) userScopes(ctx context.Context,
+	accountID, userID string,
+) (string, error) {
+	ctx = request.WithAccountID(ctx, accountID)
+	ctx = request.WithUserID(ctx, userID)
+
+	u, err := s.GetUser(ctx, userID, nil)
 	if err != nil {
-		return "", errors.New(errors.ErrServer,
-			"unable to create token secret")
+		return "", err
 	}
 
-	return authToken, nil
+	if u.Status.Value == request.StatusInactive {
+		return "", errors.New(errors.ErrUnauthorized,
+			"inactive user",
+			"user_id", userID)
+	}
+
+	return u.Scopes.Value, nil
+}
+
+// heldScopes returns the scopes, of those requested, which are held by an
+// active user of an account, either directly or through the groups of the
+// user.
+func (s *Service) heldScopes(ctx context.Context,
+	accountID, userID, scopes string,
+) (string, error) {
+	us, err := s.userScopes(ctx, accountID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	res := limitScopes(scopes, us)
+	if res == strings.Join(strings.Fields(scopes), " ") {
+		return res, nil
+	}
+
+	ctx = request.WithAccountID(ctx, accountID)
+
+	gs, err := s.groupScopes(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return limitScopes(scopes, mergeScopes(us, gs)), nil
+}
+
+// limitScopes returns the scopes which are included in the held scopes. All of
+// the scopes are held by holders of the superuser scope.
+func limitScopes(scopes, held string) string {
+	hs := strings.Fields(held)
+
+	res := []string{}
+
+	for _, scope := range strings.Fields(scopes) {
+		if slices.Contains(hs, scope) ||
+			slices.Contains(hs, request.ScopeSuperuser) {
+			res = append(res, scope)
+		}
+	}
+
+	return strings.Join(res, " ")
 }
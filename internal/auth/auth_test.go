@@ -26,8 +26,14 @@ const (
 func mockAccountSecretRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
 	return mock.NewRows([]string{
 		"secret",
+		"key_id",
+		"key_secret",
+		"expires_at",
 	}).AddRow(
 		&TestAccount.Secret.Value,
+		"",
+		"",
+		int64(0),
 	)
 }
 
@@ -50,21 +56,25 @@ func mockUserPasswordRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
 
 	return mock.NewRows([]string{
 		"password",
+		"failed_logins",
+		"locked_until",
 	}).AddRow(
 		&hp,
+		0,
+		int64(0),
 	)
 }
 
 func mockAuthContext() context.Context {
 	ctx := context.Background()
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, TestID)
+	ctx = request.WithAccountID(ctx, TestID)
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountName, TestName)
+	ctx = request.WithAccountName(ctx, TestName)
 
-	ctx = context.WithValue(ctx, request.CtxKeyUserID, TestUUID)
+	ctx = request.WithUserID(ctx, TestUUID)
 
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, request.ScopeSuperuser)
+	ctx = request.WithScopes(ctx, request.ScopeSuperuser)
 
 	return ctx
 }
@@ -117,6 +127,7 @@ func TestAuthJWT(t *testing.T) {
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID, "").
 		WillReturnRows(mockAccountSecretRows(mock))
 
 	mockTransaction(mock)
@@ -163,6 +174,13 @@ func TestAuthPassword(t *testing.T) {
 	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
 		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockUserPasswordRows(mock))
 
+	// The bcrypt password hash is replaced with an argon2id hash.
+	mockTransaction(mock)
+
+	mock.ExpectExec(`UPDATE "user" SET`).
+		WithArgs(TestName, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
 	if err := svc.AuthPassword(ctx, TestName, TestPassword,
 		TestID); err != nil {
 		t.Fatal(err)
@@ -192,9 +210,22 @@ func TestAuthCreateToken(t *testing.T) {
 
 	mockTransaction(mock)
 
+	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockUserRows(mock))
+
+	mockTransaction(mock)
+
 	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).
 		WillReturnRows(mockAccountSecretRows(mock))
 
+	mockTransaction(mock)
+
+	mock.ExpectExec("INSERT INTO token").
+		WithArgs(pgxmock.AnyArg(), TestName, pgxmock.AnyArg(), true,
+			pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
 	if _, err := svc.CreateToken(ctx, TestName,
 		now.AddDate(1, 0, 0).Unix(), "superuser", ""); err != nil {
 		t.Error(err)
@@ -204,3 +235,138 @@ func TestAuthCreateToken(t *testing.T) {
 		t.Errorf("Unmet database expectations: %v", err)
 	}
 }
+
+func mockUserScopesRows(mock pgxmock.PgxCommonIface,
+	scopes string,
+) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"user_id",
+		"email",
+		"last_name",
+		"first_name",
+		"status",
+		"scopes",
+		"data",
+	}).AddRow(
+		TestUser.UserID.Value,
+		TestUser.Email.Value,
+		TestUser.LastName.Value,
+		TestUser.FirstName.Value,
+		TestUser.Status.Value,
+		scopes,
+		TestUser.Data.Value,
+	)
+}
+
+func TestAuthCreateRestrictedToken(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	cfg := config.NewDefault()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockUserScopesRows(mock, request.ScopeUserRead))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT user_group.scopes, user_group.users").
+		WillReturnRows(mock.NewRows([]string{"scopes", "users"}).
+			AddRow(request.ScopeResourcesRead, []string{TestUUID}))
+
+	if _, err := svc.CreateToken(ctx, TestUUID,
+		time.Now().AddDate(1, 0, 0).Unix(),
+		request.ScopeResourcesWrite, ""); !errors.Has(err,
+		errors.ErrForbidden) {
+		t.Errorf("Expected forbidden error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestAuthJWTRestricted(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cfg := config.NewDefault()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	now := time.Now()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"exp":        now.Add(cfg.AuthTokenExpiresIn()).Unix(),
+		"iat":        now.Unix(),
+		"nbf":        now.Unix(),
+		"iss":        cfg.AuthTokenIssuer(),
+		"sub":        TestUser.UserID.Value,
+		"aud":        []string{cfg.ServiceName()},
+		"scopes":     request.ScopeUserRead + " " + request.ScopeResourcesWrite,
+		"restricted": true,
+	})
+
+	tok.Header = map[string]any{
+		"alg": "HS512",
+		"kid": TestID,
+	}
+
+	authToken, err := tok.SignedString([]byte(TestAccount.Secret.Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID, "").
+		WillReturnRows(mockAccountSecretRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockUserScopesRows(mock, request.ScopeUserRead))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT user_group.scopes, user_group.users").
+		WillReturnRows(mock.NewRows([]string{"scopes", "users"}).
+			AddRow(request.ScopeResourcesRead, []string{TestUUID}))
+
+	c, err := svc.AuthJWT(ctx, authToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Scopes != request.ScopeUserRead {
+		t.Errorf("Expected scopes: %v, got: %v",
+			request.ScopeUserRead, c.Scopes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"net/mail"
+	"net/url"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// Branding values contain the customizations used when rendering the
+// documentation and status pages for an account. They are stored in the
+// account data under the branding key.
+type Branding struct {
+	LogoURL      string `json:"logo_url,omitempty"`
+	ProductName  string `json:"product_name,omitempty"`
+	ContactEmail string `json:"contact_email,omitempty"`
+}
+
+// Branding retrieves the branding customizations from the account data. Any
+// missing or invalid values are left empty.
+func (a *Account) Branding() *Branding {
+	b := &Branding{}
+
+	if a == nil || !a.Data.Valid {
+		return b
+	}
+
+	m, ok := a.Data.Value["branding"].(map[string]any)
+	if !ok {
+		return b
+	}
+
+	b.LogoURL, _ = m["logo_url"].(string)
+	b.ProductName, _ = m["product_name"].(string)
+	b.ContactEmail, _ = m["contact_email"].(string)
+
+	return b
+}
+
+// validateBranding checks that any branding customizations in the account data
+// are valid.
+func (a *Account) validateBranding() error {
+	if !a.Data.Set || !a.Data.Valid {
+		return nil
+	}
+
+	v, ok := a.Data.Value["branding"]
+	if !ok || v == nil {
+		return nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return errors.New(errors.ErrInvalidRequest,
+			"branding must be an object",
+			"account", a)
+	}
+
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid branding value",
+				"key", k,
+				"account", a)
+		}
+
+		switch k {
+		case "logo_url":
+			u, err := url.Parse(s)
+			if err != nil || u.Host == "" ||
+				(u.Scheme != "https" && u.Scheme != "http") {
+				return errors.New(errors.ErrInvalidRequest,
+					"invalid branding logo_url",
+					"account", a)
+			}
+		case "product_name":
+			if len(s) > 256 {
+				return errors.New(errors.ErrInvalidRequest,
+					"invalid branding product_name",
+					"account", a)
+			}
+		case "contact_email":
+			if _, err := mail.ParseAddress(s); err != nil {
+				return errors.New(errors.ErrInvalidRequest,
+					"invalid branding contact_email",
+					"account", a)
+			}
+		default:
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid branding key",
+				"key", k,
+				"account", a)
+		}
+	}
+
+	return nil
+}
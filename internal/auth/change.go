@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// Change operations, recorded for each mutation of an account entity.
+const (
+	ChangeOperationCreate = "create"
+	ChangeOperationUpdate = "update"
+	ChangeOperationDelete = "delete"
+)
+
+// changeCursorSeparator separates the transaction ID and change key of a
+// change cursor.
+const changeCursorSeparator = "-"
+
+// Change values describe a single mutation of an account entity.
+type Change struct {
+	Cursor     string `json:"cursor"`
+	EntityType string `json:"entity_type"`
+	EntityID   string `json:"entity_id"`
+	Operation  string `json:"operation"`
+	Timestamp  int64  `json:"ts"`
+}
+
+// ChangeFeed values contain an ordered page of account changes, and the cursor
+// used to resume the feed following the last change.
+type ChangeFeed struct {
+	Changes []*Change `json:"changes"`
+	Cursor  string    `json:"cursor"`
+	More    bool      `json:"more"`
+}
+
+// parseChangeCursor parses a change cursor into the transaction ID and change
+// key that it represents. An empty cursor represents the start of the feed.
+func parseChangeCursor(cursor string) (string, int64, error) {
+	if cursor == "" {
+		return "0", 0, nil
+	}
+
+	txid, key, ok := strings.Cut(cursor, changeCursorSeparator)
+	if !ok {
+		return "", 0, errors.New(errors.ErrInvalidRequest,
+			"invalid change cursor",
+			"cursor", cursor)
+	}
+
+	if _, err := strconv.ParseUint(txid, 10, 64); err != nil {
+		return "", 0, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid change cursor",
+			"cursor", cursor)
+	}
+
+	k, err := strconv.ParseInt(key, 10, 64)
+	if err != nil || k < 0 {
+		return "", 0, errors.New(errors.ErrInvalidRequest,
+			"invalid change cursor",
+			"cursor", cursor)
+	}
+
+	return txid, k, nil
+}
+
+// GetChanges retrieves the changes made to the account following the specified
+// cursor, in the order they were committed. Changes are only returned once all
+// transactions which started before them have completed, so that no change is
+// skipped by a later request resuming from the returned cursor. Changes may be
+// returned more than once, and should be applied idempotently.
+func (s *Service) GetChanges(ctx context.Context,
+	since string,
+	size int64,
+) (*ChangeFeed, error) {
+	txid, key, err := parseChangeCursor(since)
+	if err != nil {
+		return nil, err
+	}
+
+	if size == 0 {
+		size = s.cfg.DBDefaultSize()
+	} else if size < 0 || size > s.cfg.DBMaxSize() {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			fmt.Sprintf("invalid change size value: %d "+
+				"(must be between 1 and %d)",
+				size, s.cfg.DBMaxSize()))
+	}
+
+	base := `SELECT
+		change.txid::TEXT,
+		change.change_key,
+		change.entity_type,
+		change.entity_id,
+		change.operation,
+		EXTRACT(epoch FROM change.ts)::BIGINT
+	FROM change
+	WHERE (change.txid, change.change_key) > ($1::TEXT::XID8, $2)
+		AND change.txid < pg_snapshot_xmin(pg_current_snapshot())
+	ORDER BY change.txid, change.change_key
+	LIMIT $3`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Params: []any{txid, key, size + 1},
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"since", since)
+	}
+
+	defer rows.Close()
+
+	res := &ChangeFeed{Changes: []*Change{}, Cursor: since}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		if int64(len(res.Changes)) == size {
+			res.More = true
+
+			break
+		}
+
+		c, tx, ck := &Change{}, "", int64(0)
+
+		if err := rows.Scan(&tx, &ck, &c.EntityType, &c.EntityID,
+			&c.Operation, &c.Timestamp); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select change row",
+				"since", since)
+		}
+
+		c.Cursor = tx + changeCursorSeparator + strconv.FormatInt(ck, 10)
+
+		res.Changes = append(res.Changes, c)
+		res.Cursor = c.Cursor
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select change rows",
+			"since", since)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func mockChangeRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"txid",
+		"change_key",
+		"entity_type",
+		"entity_id",
+		"operation",
+		"ts",
+	}).AddRow(
+		"100", int64(1), "account", TestAccount.AccountID.Value,
+		auth.ChangeOperationUpdate, int64(1),
+	).AddRow(
+		"101", int64(3), "resource", "11223344-5566-7788-9900-aabbccddeeff",
+		auth.ChangeOperationCreate, int64(2),
+	).AddRow(
+		"101", int64(4), "resource", "11223344-5566-7788-9900-aabbccddeeff",
+		auth.ChangeOperationDelete, int64(3),
+	)
+}
+
+func TestGetChanges(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM change").
+		WithArgs("99", int64(7), int64(3)).
+		WillReturnRows(mockChangeRows(mock))
+
+	res, err := svc.GetChanges(ctx, "99-7", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Changes) != 2 {
+		t.Fatalf("Expected changes length: 2, got: %v", len(res.Changes))
+	}
+
+	if res.Changes[0].EntityType != "account" {
+		t.Errorf("Expected entity type: account, got: %v",
+			res.Changes[0].EntityType)
+	}
+
+	if res.Cursor != "101-3" {
+		t.Errorf("Expected cursor: 101-3, got: %v", res.Cursor)
+	}
+
+	if !res.More {
+		t.Errorf("Expected more changes")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+
+	for _, since := range []string{"x", "1-", "-1", "1-x", "1-2-3"} {
+		if _, err := svc.GetChanges(ctx, since, 0); err == nil ||
+			!errors.Has(err, errors.ErrInvalidRequest) {
+			t.Errorf("Expected invalid request error for cursor %v, got: %v",
+				since, err)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxAuthFailures is the number of recent failed authentication attempts kept
+// by each service instance.
+const maxAuthFailures = 1000
+
+// maxFailureCounts is the number of client addresses and accounts for which
+// failed authentication attempts are counted in memory, when no cache is
+// available, before expired counts are removed.
+const maxFailureCounts = 10000
+
+// Kinds of source for which failed authentication attempts are counted.
+const (
+	failureRemote  = "remote"
+	failureAccount = "account"
+)
+
+// AuthFailure values describe a failed authentication attempt, and the time
+// until which the client address was locked out as a result, if it was.
+type AuthFailure struct {
+	Remote      string `json:"remote,omitempty"`
+	AccountID   string `json:"account_id,omitempty"`
+	Reason      string `json:"reason"`
+	Time        int64  `json:"time"`
+	LockedUntil int64  `json:"locked_until,omitempty"`
+}
+
+// failureCount values contain the failed authentication attempts counted for
+// a client address or account in the current window. Lockouts are counted
+// until the failures stop for a whole window, so that each lockout can be
+// longer than the last.
+type failureCount struct {
+	Start       int64 `json:"start"`
+	Failures    int   `json:"failures"`
+	Lockouts    int   `json:"lockouts"`
+	LockedUntil int64 `json:"locked_until,omitempty"`
+	Expires     int64 `json:"expires"`
+}
+
+// authFailures contains the recent failed authentication attempts seen by the
+// service instance, newest last, and the failure counts used when no cache is
+// available to share them between instances.
+var authFailures = struct {
+	sync.Mutex
+	recent []*AuthFailure
+	counts map[string]*failureCount
+}{counts: map[string]*failureCount{}}
+
+// addAuthFailure records a recent failed authentication attempt, discarding
+// the oldest once the maximum number are kept.
+func addAuthFailure(f *AuthFailure) {
+	authFailures.Lock()
+	defer authFailures.Unlock()
+
+	if len(authFailures.recent) >= maxAuthFailures {
+		authFailures.recent = authFailures.recent[1:]
+	}
+
+	authFailures.recent = append(authFailures.recent, f)
+}
+
+// contextRemote retrieves the client address of a request from the context,
+// without any port. The address is resolved by the server from the peer
+// address, or the X-Forwarded-For header of a trusted proxy, so that clients
+// can not choose the address for which their failures are counted.
+func contextRemote(ctx context.Context) string {
+	remote, err := request.ContextRemote(ctx)
+	if err != nil {
+		return ""
+	}
+
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	return remote
+}
+
+// tokenAccountID retrieves the account ID from the kid header of a token
+// issued by an account, without verifying the token.
+func tokenAccountID(token string) string {
+	tok, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	if err != nil {
+		return ""
+	}
+
+	kid, _ := tok.Header["kid"].(string)
+
+	accountID, _ := parseKeyID(kid)
+
+	if !request.ValidAccountID(accountID) {
+		return ""
+	}
+
+	return accountID
+}
+
+// getFailureCount retrieves the failed authentication attempts counted for a
+// client address or account, from the cache, or memory, if there is no cache.
+func (s *Service) getFailureCount(ctx context.Context,
+	key string,
+	now time.Time,
+) *failureCount {
+	var r *failureCount
+
+	if s.cache != nil {
+		ci, err := s.cache.Get(ctx, key)
+		if err != nil && !errors.Has(err, errors.ErrNotFound) {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to get authentication failures cache key",
+				"error", err,
+				"cache_key", key)
+		} else if ci != nil {
+			buf := bytes.NewBuffer(ci.Value)
+
+			if err := json.NewDecoder(buf).Decode(&r); err != nil {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to decode authentication failures cache value",
+					"error", err,
+					"cache_key", key,
+					"cache_value", string(ci.Value))
+			}
+		}
+	} else {
+		authFailures.Lock()
+
+		if c, ok := authFailures.counts[key]; ok {
+			v := *c
+
+			r = &v
+		}
+
+		authFailures.Unlock()
+	}
+
+	if r == nil || r.Expires <= now.Unix() {
+		return nil
+	}
+
+	return r
+}
+
+// setFailureCount stores the failed authentication attempts counted for a
+// client address or account, until they expire.
+func (s *Service) setFailureCount(ctx context.Context,
+	key string,
+	c *failureCount,
+	now time.Time,
+) {
+	if s.cache != nil {
+		buf, err := json.Marshal(c)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to encode authentication failures cache value",
+				"error", err,
+				"cache_key", key)
+
+			return
+		}
+
+		if err := s.cache.Set(ctx, &cache.Item{
+			Key:        key,
+			Value:      buf,
+			Expiration: time.Unix(c.Expires, 0).Sub(now),
+		}); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to set authentication failures cache key",
+				"error", err,
+				"cache_key", key)
+		}
+
+		return
+	}
+
+	authFailures.Lock()
+	defer authFailures.Unlock()
+
+	if len(authFailures.counts) >= maxFailureCounts {
+		for k, v := range authFailures.counts {
+			if v.Expires <= now.Unix() {
+				delete(authFailures.counts, k)
+			}
+		}
+	}
+
+	authFailures.counts[key] = c
+}
+
+// checkAuthLockout returns an error if authentication attempts from a client
+// address are locked out, after too many failures.
+func (s *Service) checkAuthLockout(ctx context.Context, remote string) error {
+	if remote == "" || s.cfg.AuthFailureThreshold() < 0 {
+		return nil
+	}
+
+	now := time.Now()
+
+	c := s.getFailureCount(ctx, cache.KeyAuthFailures(failureRemote, remote),
+		now)
+	if c == nil || c.LockedUntil <= now.Unix() {
+		return nil
+	}
+
+	return errors.New(errors.ErrorRateLimit,
+		"too many failed authentication attempts",
+		"remote", remote,
+		"locked_until", time.Unix(c.LockedUntil, 0).UTC(),
+		"retry_after", c.LockedUntil-now.Unix())
+}
+
+// recordAuthFailure records a failed authentication attempt, and counts it
+// for the client address and the account of the token, if they are known.
+func (s *Service) recordAuthFailure(ctx context.Context,
+	remote, accountID string,
+	err error,
+) {
+	now := time.Now()
+
+	f := &AuthFailure{
+		Remote:    remote,
+		AccountID: accountID,
+		Reason:    "invalid authentication token",
+		Time:      now.Unix(),
+	}
+
+	if e, ok := err.(*errors.Error); ok && e.Msg != "" {
+		f.Reason = e.Msg
+	}
+
+	if s.metric != nil {
+		s.metric.Increment(ctx, "auth_failures")
+	}
+
+	if s.cfg.AuthFailureThreshold() >= 0 {
+		if remote != "" {
+			if t := s.countAuthFailure(ctx, failureRemote, remote,
+				now); t > now.Unix() {
+				f.LockedUntil = t
+			}
+		}
+
+		if accountID != "" {
+			s.countAuthFailure(ctx, failureAccount, accountID, now)
+		}
+	}
+
+	addAuthFailure(f)
+}
+
+// countAuthFailure counts a failed authentication attempt for a client
+// address or account, and reports when the failures in the current window
+// reach the threshold. Client addresses are then locked out, first for the
+// configured lockout time, and for twice as long as the last each time after,
+// up to the maximum. Accounts are never locked out, since the account of a
+// token is known before the token is verified, and anyone could use it to
+// lock an account out. The time until which the source is locked out is
+// returned.
+func (s *Service) countAuthFailure(ctx context.Context,
+	kind, id string,
+	now time.Time,
+) int64 {
+	threshold, window := s.cfg.AuthFailureThreshold(), s.cfg.AuthFailureWindow()
+
+	key := cache.KeyAuthFailures(kind, id)
+
+	c := s.getFailureCount(ctx, key, now)
+	if c == nil {
+		c = &failureCount{Start: now.Unix()}
+	}
+
+	// Counts from different service instances may be lost when they fail at
+	// once, so the threshold is approximate.
+	if now.Sub(time.Unix(c.Start, 0)) >= window {
+		c.Start, c.Failures = now.Unix(), 0
+	}
+
+	c.Failures++
+
+	if c.Failures >= threshold {
+		c.Start, c.Failures = now.Unix(), 0
+
+		if s.metric != nil {
+			s.metric.Increment(ctx, "auth_failure_alerts", "kind:"+kind)
+		}
+
+		if kind == failureRemote {
+			lockout, maxLockout := s.cfg.AuthFailureLockout(),
+				s.cfg.AuthFailureMaxLockout()
+
+			for i := 0; i < c.Lockouts && lockout < maxLockout; i++ {
+				lockout *= 2
+			}
+
+			lockout = min(lockout, maxLockout)
+
+			c.Lockouts++
+			c.LockedUntil = now.Add(lockout).Unix()
+
+			s.log.Log(ctx, logger.LvlWarn,
+				"client address locked out after repeated "+
+					"authentication failures",
+				"remote", id,
+				"threshold", threshold,
+				"window", window,
+				"lockouts", c.Lockouts,
+				"locked_until", time.Unix(c.LockedUntil, 0).UTC())
+		} else {
+			s.log.Log(ctx, logger.LvlWarn,
+				"repeated authentication failures for account",
+				"account_id", id,
+				"threshold", threshold,
+				"window", window)
+		}
+	}
+
+	c.Expires = max(now.Add(window).Unix(), c.LockedUntil+
+		int64(window/time.Second))
+
+	s.setFailureCount(ctx, key, c, now)
+
+	return c.LockedUntil
+}
+
+// GetAuthFailures retrieves the recent failed authentication attempts seen by
+// the service instance, newest first. Only those using tokens of the account
+// are returned, unless the request is made by the system account.
+func (s *Service) GetAuthFailures(ctx context.Context,
+) ([]*AuthFailure, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, errors.New(errors.ErrForbidden,
+			"unable to retrieve account id")
+	}
+
+	authFailures.Lock()
+	defer authFailures.Unlock()
+
+	res := []*AuthFailure{}
+
+	for i := len(authFailures.recent) - 1; i >= 0; i-- {
+		f := authFailures.recent[i]
+
+		if accountID != request.SystemAccount && f.AccountID != accountID {
+			continue
+		}
+
+		v := *f
+
+		res = append(res, &v)
+	}
+
+	return res, nil
+}
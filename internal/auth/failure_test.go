@@ -0,0 +1,90 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+func TestAuthJWTLockout(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	cfg.SetAuth(&config.AuthConfig{
+		FailureThreshold: 2,
+		FailureWindow:    time.Minute,
+		FailureLockout:   time.Minute,
+	})
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	remote := "192.0.2.10"
+
+	ctx := context.WithValue(context.Background(), request.CtxKeyRemote,
+		remote+":1234")
+
+	for range 2 {
+		if _, err := svc.AuthJWT(ctx, "invalid",
+			""); !errors.Has(err, errors.ErrUnauthorized) {
+			t.Errorf("Expected unauthorized error, got: %v", err)
+		}
+	}
+
+	_, err = svc.AuthJWT(ctx, "invalid", "")
+	if !errors.Has(err, errors.ErrorRateLimit) {
+		t.Fatalf("Expected rate limit error, got: %v", err)
+	}
+
+	if e, ok := err.(*errors.Error); !ok || e.Data["retry_after"] == nil {
+		t.Errorf("Expected retry after in error data, got: %v", err)
+	}
+
+	res, err := svc.GetAuthFailures(request.WithAccountID(ctx,
+		request.SystemAccount))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, locked := 0, false
+
+	for _, f := range res {
+		if f.Remote == remote {
+			n++
+
+			locked = locked || f.LockedUntil > 0
+		}
+	}
+
+	if n != 2 || !locked {
+		t.Errorf("Expected 2 failures, and a lockout, for %v, got: %v, %v",
+			remote, n, locked)
+	}
+
+	res, err = svc.GetAuthFailures(mockAuthContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range res {
+		if f.AccountID != TestID {
+			t.Errorf("Expected only failures for account: %v, got: %+v",
+				TestID, f)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
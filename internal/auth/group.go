@@ -0,0 +1,807 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+// Group values represent groups of the users of an account. The scopes of a
+// group are granted to each of its users, in addition to the scopes of their
+// tokens, while the group is active.
+type Group struct {
+	GroupID     request.FieldString      `json:"group_id"    yaml:"group_id"`
+	Name        request.FieldString      `json:"name"        yaml:"name"`
+	Description request.FieldString      `json:"description" yaml:"description"`
+	Status      request.FieldString      `json:"status"      yaml:"status"`
+	Scopes      request.FieldString      `json:"scopes"      yaml:"scopes"`
+	Users       request.FieldStringArray `json:"users"       yaml:"users"`
+	Data        request.FieldJSON        `json:"data"        yaml:"data"`
+	CreatedAt   request.FieldTime        `json:"created_at"  yaml:"created_at"`
+	CreatedBy   request.FieldString      `json:"created_by"  yaml:"created_by"`
+	UpdatedAt   request.FieldTime        `json:"updated_at"  yaml:"updated_at"`
+	UpdatedBy   request.FieldString      `json:"updated_by"  yaml:"updated_by"`
+}
+
+// Validate checks that the value contains valid data.
+func (g *Group) Validate() error {
+	if g.GroupID.Set {
+		if !g.GroupID.Valid {
+			return errors.New(errors.ErrInvalidRequest,
+				"group_id must not be null",
+				"group", g)
+		}
+
+		if !request.ValidGroupID(g.GroupID.Value) {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid group_id",
+				"group", g)
+		}
+	}
+
+	if g.Name.Set && !g.Name.Valid {
+		return errors.New(errors.ErrInvalidRequest,
+			"name must not be null",
+			"group", g)
+	}
+
+	if g.Status.Set {
+		if !g.Status.Valid {
+			return errors.New(errors.ErrInvalidRequest,
+				"status must not be null",
+				"group", g)
+		}
+
+		switch g.Status.Value {
+		case request.StatusActive, request.StatusInactive:
+		default:
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid status",
+				"group", g)
+		}
+	}
+
+	if g.Scopes.Set {
+		if !g.Scopes.Valid {
+			return errors.New(errors.ErrInvalidRequest,
+				"scopes must not be null",
+				"group", g)
+		}
+
+		if g.Scopes.Value != "" && !request.ValidScopes(g.Scopes.Value) {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid scope",
+				"group", g)
+		}
+
+		// The superuser scope crosses account boundaries, so it is only
+		// granted to users directly.
+		if slices.Contains(strings.Fields(g.Scopes.Value),
+			request.ScopeSuperuser) {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid scope: superuser can not be granted by groups",
+				"group", g)
+		}
+	}
+
+	if g.Users.Set {
+		if !g.Users.Valid {
+			return errors.New(errors.ErrInvalidRequest,
+				"users must not be null",
+				"group", g)
+		}
+
+		for _, id := range g.Users.Value {
+			if !request.ValidUserID(id) {
+				return errors.New(errors.ErrInvalidRequest,
+					"invalid users: invalid user_id: "+id,
+					"group", g)
+			}
+		}
+
+		g.Users.Value = slices.Compact(slices.Sorted(
+			slices.Values(g.Users.Value)))
+	}
+
+	return nil
+}
+
+// ValidateCreate checks that the value contains valid data for creation.
+func (g *Group) ValidateCreate() error {
+	if !g.GroupID.Set {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing group_id",
+			"group", g)
+	}
+
+	if !g.Name.Set {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing name",
+			"group", g)
+	}
+
+	return g.Validate()
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (g *Group) ScanDest(options sqldb.FieldOptions) []any {
+	return sqldb.ScanFields("user_group", groupFields, options,
+		map[string]any{
+			"group_id":    &g.GroupID,
+			"name":        &g.Name,
+			"description": &g.Description,
+			"status":      &g.Status,
+			"scopes":      &g.Scopes,
+			"users":       &g.Users,
+			"data":        &g.Data,
+			"created_at":  &g.CreatedAt,
+			"created_by":  &g.CreatedBy,
+			"updated_at":  &g.UpdatedAt,
+			"updated_by":  &g.UpdatedBy,
+		})
+}
+
+// groupFields contain the search fields for groups.
+var groupFields = []*sqldb.Field{{
+	Name:   "group_key",
+	Type:   sqldb.FieldInt,
+	Table:  "user_group",
+	Hidden: true,
+}, {
+	Name:  "group_id",
+	Type:  sqldb.FieldString,
+	Table: "user_group",
+}, {
+	Name:    "name",
+	Type:    sqldb.FieldString,
+	Table:   "user_group",
+	Primary: true,
+}, {
+	Name:  "description",
+	Type:  sqldb.FieldString,
+	Table: "user_group",
+}, {
+	Name:  "status",
+	Type:  sqldb.FieldString,
+	Table: "user_group",
+}, {
+	Name:  "scopes",
+	Type:  sqldb.FieldString,
+	Table: "user_group",
+}, {
+	Name:  "users",
+	Type:  sqldb.FieldArray,
+	Table: "user_group",
+}, {
+	Name:  "data",
+	Type:  sqldb.FieldJSON,
+	Table: "user_group",
+}, {
+	Name:   "created_at",
+	Type:   sqldb.FieldTime,
+	Option: "user_details",
+	Table:  "user_group",
+}, {
+	Name:   "created_by",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "created_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "created_by",
+	Expr:   "created_by_user.user_id",
+}, {
+	Name:   "updated_at",
+	Type:   sqldb.FieldTime,
+	Option: "user_details",
+	Table:  "user_group",
+}, {
+	Name:   "updated_by",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "updated_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "updated_by",
+	Expr:   "updated_by_user.user_id",
+}}
+
+// GetGroups retrieves groups based on a search query.
+func (s *Service) GetGroups(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*Group, error) {
+	if err := options.ValidateFields(groupFields); err != nil {
+		return nil, err
+	}
+
+	query = query.NoSummary()
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   sqldb.SelectFields("user_group", groupFields, query, options),
+		Search: query,
+		Fields: groupFields,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res := []*Group{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		g := &Group{}
+
+		if err := rows.Scan(g.ScanDest(options)...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select group row",
+				"search", query)
+		}
+
+		res = append(res, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select group rows",
+			"search", query)
+	}
+
+	return res, nil
+}
+
+// GetGroup retrieves a single group by ID.
+func (s *Service) GetGroup(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*Group, error) {
+	if !request.ValidGroupID(id) {
+		return nil, errors.New(errors.ErrInvalidParameter, "invalid id",
+			"id", id)
+	}
+
+	if err := options.ValidateFields(groupFields); err != nil {
+		return nil, err
+	}
+
+	base := sqldb.SelectFields("user_group", groupFields, nil, options) +
+		`WHERE user_group.group_id = $1`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: groupFields,
+		Params: []any{id},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	g := &Group{}
+
+	if err := row.Scan(g.ScanDest(options)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"group not found",
+				"id", id)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select group row",
+			"id", id)
+	}
+
+	return g, nil
+}
+
+// CreateGroup inserts a new group in the database. Only scopes held by the
+// current user can be granted by the group.
+func (s *Service) CreateGroup(ctx context.Context,
+	v *Group,
+) (*Group, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing group",
+			"group", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	if err := checkGrant(ctx, v.Scopes.Value); err != nil {
+		return nil, err
+	}
+
+	base := `INSERT INTO user_group () VALUES ()` +
+		sqldb.ReturningFields("user_group", groupFields, nil)
+
+	sets, params := []string{}, []any{}
+
+	request.SetField("group_id", v.GroupID, &sets, &params)
+	request.SetField("name", v.Name, &sets, &params)
+	request.SetField("description", v.Description, &sets, &params)
+	request.SetField("status", v.Status, &sets, &params)
+	request.SetField("scopes", v.Scopes, &sets, &params)
+	request.SetField("users", v.Users, &sets, &params)
+	request.SetField("data", v.Data, &sets, &params)
+	request.SetField("created_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+	request.SetField("updated_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryInsert,
+		Base:   base,
+		Fields: groupFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "group", v)
+	}
+
+	g := &Group{}
+
+	if err := row.Scan(g.ScanDest(nil)...); err != nil {
+		if errors.ErrorHas(err, "user_group_account_id_group_id_key") {
+			return nil, errors.New(errors.ErrConflict,
+				"invalid group_id: in use by another group",
+				"group", v)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to insert group row",
+			"group", v)
+	}
+
+	s.deleteGroupCache(ctx)
+
+	return g, nil
+}
+
+// UpdateGroup updates a group in the database. Only groups granting scopes
+// held by the current user can be updated.
+func (s *Service) UpdateGroup(ctx context.Context,
+	v *Group,
+) (*Group, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing group",
+			"group", v)
+	}
+
+	if !v.GroupID.Set || !v.GroupID.Valid {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing group_id",
+			"group", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	scopes := v.Scopes.Value
+
+	if !v.Scopes.Set {
+		og, err := s.GetGroup(ctx, v.GroupID.Value, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		scopes = og.Scopes.Value
+	}
+
+	if err := checkGrant(ctx, scopes); err != nil {
+		return nil, err
+	}
+
+	base := `UPDATE user_group SET
+		WHERE user_group.group_id = $1` +
+		sqldb.ReturningFields("user_group", groupFields, nil)
+
+	sets, params := []string{}, []any{v.GroupID.Value}
+
+	request.SetField("name", v.Name, &sets, &params)
+	request.SetField("description", v.Description, &sets, &params)
+	request.SetField("status", v.Status, &sets, &params)
+	request.SetField("scopes", v.Scopes, &sets, &params)
+	request.SetField("users", v.Users, &sets, &params)
+	request.SetField("data", v.Data, &sets, &params)
+	request.SetField("updated_at", request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}, &sets, &params)
+	request.SetField("updated_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryUpdate,
+		Base:   base,
+		Fields: groupFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "group", v)
+	}
+
+	g := &Group{}
+
+	if err := row.Scan(g.ScanDest(nil)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"group not found",
+				"group", v)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update group row",
+			"group", v)
+	}
+
+	s.deleteGroupCache(ctx)
+
+	return g, nil
+}
+
+// DeleteGroup deletes a group from the database.
+func (s *Service) DeleteGroup(ctx context.Context,
+	id string,
+) error {
+	base := `DELETE FROM user_group
+		WHERE user_group.group_id = $1`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryDelete,
+		Base:   base,
+		Fields: groupFields,
+		Params: []any{id},
+	})
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	if n := res.RowsAffected(); n == 0 {
+		return errors.New(errors.ErrNotFound, "group not found",
+			"id", id)
+	}
+
+	s.deleteGroupCache(ctx)
+
+	return nil
+}
+
+// AddGroupUser adds a user to a group. Only users holding the scopes granted
+// by the group can add users to it.
+func (s *Service) AddGroupUser(ctx context.Context,
+	id, userID string,
+) (*Group, error) {
+	og, err := s.GetGroup(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkGrant(ctx, og.Scopes.Value); err != nil {
+		return nil, err
+	}
+
+	// The user is removed before being appended, so that it is never listed
+	// twice, even when added concurrently.
+	return s.updateGroupUsers(ctx, id, userID,
+		`ARRAY_APPEND(ARRAY_REMOVE(user_group.users, $2::TEXT), $2::TEXT)`)
+}
+
+// RemoveGroupUser removes a user from a group.
+func (s *Service) RemoveGroupUser(ctx context.Context,
+	id, userID string,
+) (*Group, error) {
+	return s.updateGroupUsers(ctx, id, userID,
+		`ARRAY_REMOVE(user_group.users, $2::TEXT)`)
+}
+
+// updateGroupUsers updates the users of a group, using an expression of the
+// user ID. The users are updated by a single statement, so that concurrent
+// membership changes are not lost.
+func (s *Service) updateGroupUsers(ctx context.Context,
+	id, userID, expr string,
+) (*Group, error) {
+	cID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !request.ValidUserID(userID) {
+		return nil, errors.New(errors.ErrInvalidParameter, "invalid user_id",
+			"id", id,
+			"user_id", userID)
+	}
+
+	base := `UPDATE user_group SET users = ` + expr + `,
+			updated_at = CURRENT_TIMESTAMP,
+			updated_by = (SELECT user_key FROM "user" WHERE user_id = $3)
+		WHERE user_group.group_id = $1` +
+		sqldb.ReturningFields("user_group", groupFields, nil)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryUpdate,
+		Base:   base,
+		Fields: groupFields,
+		Params: []any{id, userID, cID},
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id,
+			"user_id", userID)
+	}
+
+	g := &Group{}
+
+	if err := row.Scan(g.ScanDest(nil)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"group not found",
+				"id", id)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update group row",
+			"id", id,
+			"user_id", userID)
+	}
+
+	s.deleteGroupCache(ctx)
+
+	return g, nil
+}
+
+// checkGrant verifies that the current user holds each of the scopes granted by
+// a group, so that users are not able to grant themselves additional scopes.
+func checkGrant(ctx context.Context, scopes string) error {
+	for _, scope := range strings.Fields(scopes) {
+		if !request.ContextHasScope(ctx, scope) {
+			return errors.New(errors.ErrForbidden,
+				"unable to grant scope: "+scope,
+				"scopes", scopes)
+		}
+	}
+
+	return nil
+}
+
+// groupGrant values contain the scopes granted to the users of an active group.
+type groupGrant struct {
+	Scopes string   `json:"scopes"`
+	Users  []string `json:"users"`
+}
+
+// groupGrants values contain the grants of the active groups of an account.
+type groupGrants struct {
+	Grants []*groupGrant `json:"grants"`
+}
+
+// groupScopes returns the scopes granted to a user by the active groups of the
+// account of the context that the user belongs to. The grants of the account
+// are cached together, so that authenticating requests does not require a
+// database query for each of them.
+func (s *Service) groupScopes(ctx context.Context,
+	userID string,
+) (string, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	ck := cache.KeyGroupGrants(accountID)
+
+	var r *groupGrants
+
+	if s.cache != nil {
+		ci, err := s.cache.Get(ctx, ck)
+		if err != nil && !errors.Has(err, errors.ErrNotFound) {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to get group grants cache key",
+				"error", err,
+				"cache_key", ck)
+		} else if ci != nil {
+			buf := bytes.NewBuffer(ci.Value)
+
+			if err := json.NewDecoder(buf).Decode(&r); err != nil {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to decode group grants cache value",
+					"error", err,
+					"cache_key", ck,
+					"cache_value", string(ci.Value))
+
+				r = nil
+			}
+		}
+	}
+
+	if r == nil {
+		r, err = cache.Load(ctx, &s.loads, ck, s.loadGroupGrants)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	scopes := []string{}
+
+	for _, g := range r.Grants {
+		if slices.Contains(g.Users, userID) {
+			scopes = append(scopes, g.Scopes)
+		}
+	}
+
+	return mergeScopes(scopes...), nil
+}
+
+// loadGroupGrants reads the grants of the active groups of the account of the
+// context from the database, and caches them.
+func (s *Service) loadGroupGrants(ctx context.Context,
+) (*groupGrants, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	base := `SELECT user_group.scopes, user_group.users
+		FROM user_group
+		WHERE user_group.status = '` + request.StatusActive + `'
+			AND user_group.scopes <> ''
+			AND CARDINALITY(user_group.users) > 0
+		ORDER BY user_group.group_key`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: groupFields,
+	})
+
+	q.Limit = q.Config.DBMaxSize()
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"account_id", accountID)
+	}
+
+	defer rows.Close()
+
+	res := &groupGrants{Grants: []*groupGrant{}}
+
+	for rows.Next() {
+		g := &groupGrant{}
+
+		if err := rows.Scan(&g.Scopes, &g.Users); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select group grant row",
+				"account_id", accountID)
+		}
+
+		res.Grants = append(res.Grants, g)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select group grant rows",
+			"account_id", accountID)
+	}
+
+	if s.cache != nil {
+		ck := cache.KeyGroupGrants(accountID)
+
+		buf, err := json.Marshal(res)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to encode group grants cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", res)
+		} else if err := s.cache.Set(ctx, &cache.Item{
+			Key:        ck,
+			Value:      buf,
+			Expiration: s.cfg.CacheExpiration(),
+		}); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to set group grants cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", string(buf),
+				"expiration", s.cfg.CacheExpiration())
+		}
+	}
+
+	return res, nil
+}
+
+// deleteGroupCache removes the cached group grants of the account of the
+// context, so that changes to its groups apply to the next request.
+func (s *Service) deleteGroupCache(ctx context.Context) {
+	if s.cache == nil {
+		return
+	}
+
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return
+	}
+
+	ck := cache.KeyGroupGrants(accountID)
+
+	if err := s.cache.Delete(ctx, ck); err != nil &&
+		!errors.Has(err, errors.ErrNotFound) {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to delete group grants cache key",
+			"error", err,
+			"cache_key", ck)
+	}
+}
+
+// mergeScopes combines lists of space separated scopes, removing duplicates.
+func mergeScopes(scopes ...string) string {
+	res := []string{}
+
+	for _, s := range scopes {
+		for _, scope := range strings.Fields(s) {
+			if !slices.Contains(res, scope) {
+				res = append(res, scope)
+			}
+		}
+	}
+
+	return strings.Join(res, " ")
+}
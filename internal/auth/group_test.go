@@ -0,0 +1,428 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+var TestGroup = auth.Group{
+	GroupID: request.FieldString{
+		Set: true, Valid: true,
+		Value: "operations",
+	},
+	Name: request.FieldString{
+		Set: true, Valid: true,
+		Value: "Operations",
+	},
+	Description: request.FieldString{
+		Set: true, Valid: true,
+		Value: "Operations team",
+	},
+	Status: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.StatusActive,
+	},
+	Scopes: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.ScopeResourcesWrite,
+	},
+	Users: request.FieldStringArray{
+		Set: true, Valid: true,
+		Value: []string{TestUUID},
+	},
+	Data: request.FieldJSON{
+		Set: true, Valid: true,
+		Value: map[string]any{
+			"test": "test",
+		},
+	},
+}
+
+func mockGroupRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"group_id",
+		"name",
+		"description",
+		"status",
+		"scopes",
+		"users",
+		"data",
+	}).AddRow(
+		TestGroup.GroupID.Value,
+		TestGroup.Name.Value,
+		TestGroup.Description.Value,
+		TestGroup.Status.Value,
+		TestGroup.Scopes.Value,
+		nil,
+		TestGroup.Data.Value,
+	)
+}
+
+func TestGroupValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		group *auth.Group
+		err   bool
+	}{{
+		name:  "valid",
+		group: &auth.Group{Scopes: TestGroup.Scopes, Users: TestGroup.Users},
+	}, {
+		name: "invalid group_id",
+		group: &auth.Group{GroupID: request.FieldString{
+			Set: true, Valid: true, Value: "invalid id",
+		}},
+		err: true,
+	}, {
+		name: "invalid scope",
+		group: &auth.Group{Scopes: request.FieldString{
+			Set: true, Valid: true, Value: "invalid",
+		}},
+		err: true,
+	}, {
+		name: "superuser",
+		group: &auth.Group{Scopes: request.FieldString{
+			Set: true, Valid: true, Value: request.ScopeSuperuser,
+		}},
+		err: true,
+	}, {
+		name: "invalid users",
+		group: &auth.Group{Users: request.FieldStringArray{
+			Set: true, Valid: true, Value: []string{""},
+		}},
+		err: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.group.Validate(); (err != nil) != tt.err {
+				t.Errorf("Expected error: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestGetGroups(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM user_group").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockGroupRows(mock))
+
+	res, err := svc.GetGroups(ctx, &search.Query{
+		Search: "group_id:" + TestGroup.GroupID.Value,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || res[0].GroupID.Value != TestGroup.GroupID.Value {
+		t.Errorf("Expected group: %v, got: %v",
+			TestGroup.GroupID.Value, res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestGetGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM user_group").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockGroupRows(mock))
+
+	res, err := svc.GetGroup(ctx, TestGroup.GroupID.Value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.GroupID.Value != TestGroup.GroupID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestGroup.GroupID.Value, res.GroupID.Value)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestCreateGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	args := make([]any, 9)
+
+	for i := 0; i < 9; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("INSERT INTO user_group").
+		WithArgs(args...).WillReturnRows(mockGroupRows(mock))
+
+	res, err := svc.CreateGroup(ctx, &TestGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.GroupID.Value != TestGroup.GroupID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestGroup.GroupID.Value, res.GroupID.Value)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+
+	// Users can not grant scopes which they do not hold.
+	ctx = request.WithScopes(ctx, request.ScopeUserAdmin)
+
+	if _, err := svc.CreateGroup(ctx, &TestGroup); !errors.Has(err,
+		errors.ErrForbidden) {
+		t.Errorf("Expected error: %v, got: %v", errors.ErrForbidden, err)
+	}
+}
+
+func TestUpdateGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	args := make([]any, 9)
+
+	for i := 0; i < 9; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE user_group SET").
+		WithArgs(args...).WillReturnRows(mockGroupRows(mock))
+
+	res, err := svc.UpdateGroup(ctx, &TestGroup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.GroupID.Value != TestGroup.GroupID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestGroup.GroupID.Value, res.GroupID.Value)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestDeleteGroup(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM user_group").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := svc.DeleteGroup(ctx, TestGroup.GroupID.Value); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestGroupUsers(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM user_group").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockGroupRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`UPDATE user_group SET users = ARRAY_APPEND`).
+		WithArgs(TestGroup.GroupID.Value, TestUUID, TestUUID).
+		WillReturnRows(mockGroupRows(mock))
+
+	if _, err := svc.AddGroupUser(ctx, TestGroup.GroupID.Value,
+		TestUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`UPDATE user_group SET users = ARRAY_REMOVE`).
+		WithArgs(TestGroup.GroupID.Value, TestUUID, TestUUID).
+		WillReturnRows(mockGroupRows(mock))
+
+	if _, err := svc.RemoveGroupUser(ctx, TestGroup.GroupID.Value,
+		TestUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestAuthJWTGroupScopes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cfg := config.NewDefault()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	now := time.Now()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"exp":    now.Add(cfg.AuthTokenExpiresIn()).Unix(),
+		"iat":    now.Unix(),
+		"nbf":    now.Unix(),
+		"iss":    cfg.AuthTokenIssuer(),
+		"sub":    TestUser.UserID.Value,
+		"aud":    []string{cfg.ServiceName()},
+		"scopes": request.ScopeUserRead + " " + request.ScopeResourcesRead,
+	})
+
+	tok.Header = map[string]any{
+		"alg": "HS512",
+		"kid": TestID,
+	}
+
+	authToken, err := tok.SignedString([]byte(TestAccount.Secret.Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID, "").
+		WillReturnRows(mockAccountSecretRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT user_group.scopes, user_group.users").
+		WillReturnRows(mock.NewRows([]string{"scopes", "users"}).
+			AddRow(request.ScopeResourcesRead+" "+request.ScopeResourcesWrite,
+				[]string{TestUUID}).
+			AddRow(request.ScopeAccountAdmin, []string{"other"}))
+
+	c, err := svc.AuthJWT(ctx, authToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := request.ScopeUserRead + " " + request.ScopeResourcesRead + " " +
+		request.ScopeResourcesWrite
+
+	if c.Scopes != exp {
+		t.Errorf("Expected scopes: %v, got: %v", exp, c.Scopes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
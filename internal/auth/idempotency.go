@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+// IdempotentResponse values contain the response to a request made with an
+// idempotency key, retained so that it can be replayed when the request is
+// retried.
+type IdempotentResponse struct {
+	Status  int
+	Headers map[string]string
+	Body    []byte
+}
+
+// ClaimIdempotencyKey records that the account is processing a request made
+// with an idempotency key, identified by a hash of the request. If the key has
+// not been used, or its retained response has expired, it is claimed, and nil
+// is returned. If the key has been used for the same request, the retained
+// response is returned, so that it can be replayed. A conflict error is
+// returned if the earlier request is still being processed, and an invalid
+// request error if the key was used for a different request. Claims of
+// requests which are not completed expire after the server timeout.
+func (s *Service) ClaimIdempotencyKey(ctx context.Context,
+	key, operation, hash string,
+) (*IdempotentResponse, error) {
+	if key == "" || len(key) > MaxIdempotencyKey {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid idempotency key",
+			"idempotency_key", key)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryInsert,
+		Base: `INSERT INTO idempotent_request (idempotency_key, operation,
+				request_hash, expires_at)
+			VALUES ($1, $2, $3, TO_TIMESTAMP($4))
+			ON CONFLICT (account_id, idempotency_key) DO UPDATE SET
+				operation = EXCLUDED.operation,
+				request_hash = EXCLUDED.request_hash,
+				status = 0,
+				headers = '{}',
+				response = NULL,
+				created_at = CURRENT_TIMESTAMP,
+				expires_at = EXCLUDED.expires_at
+			WHERE idempotent_request.expires_at < CURRENT_TIMESTAMP
+			RETURNING idempotent_request.idempotency_key`,
+		Params: []any{key, operation, hash,
+			time.Now().Add(s.cfg.ServerTimeout()).Unix()},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"idempotency_key", key)
+	}
+
+	claimed := ""
+
+	if err := row.Scan(&claimed); err == nil {
+		return nil, nil
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to insert idempotent request row",
+			"idempotency_key", key)
+	}
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+			idempotent_request.request_hash,
+			idempotent_request.status,
+			idempotent_request.headers,
+			idempotent_request.response
+		FROM idempotent_request
+		WHERE idempotent_request.idempotency_key = $1
+		LIMIT 1`,
+		Params: []any{key},
+	})
+
+	q.Limit = 1
+
+	row, err = q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"idempotency_key", key)
+	}
+
+	res, rHash, headers := &IdempotentResponse{}, "", []byte{}
+
+	if err := row.Scan(&rHash, &res.Status, &headers,
+		&res.Body); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrConflict,
+				"idempotency key in use, retry the request",
+				"idempotency_key", key).Transient(true)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select idempotent request row",
+			"idempotency_key", key)
+	}
+
+	if rHash != hash {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"idempotency key already used for a different request",
+			"idempotency_key", key)
+	}
+
+	if res.Status == 0 {
+		return nil, errors.New(errors.ErrConflict,
+			"request with idempotency key in progress",
+			"idempotency_key", key).Transient(true)
+	}
+
+	if err := json.Unmarshal(headers, &res.Headers); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to decode idempotent response headers",
+			"idempotency_key", key)
+	}
+
+	return res, nil
+}
+
+// SaveIdempotentResponse retains the response to a request made with a claimed
+// idempotency key, for the configured idempotency window, so that it can be
+// replayed when the request is retried.
+func (s *Service) SaveIdempotentResponse(ctx context.Context,
+	key, hash string,
+	res *IdempotentResponse,
+) error {
+	headers, err := json.Marshal(res.Headers)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to encode idempotent response headers",
+			"idempotency_key", key)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryUpdate,
+		Base: `UPDATE idempotent_request SET
+				status = $3,
+				headers = $4,
+				response = $5,
+				expires_at = TO_TIMESTAMP($6)
+			WHERE idempotent_request.idempotency_key = $1
+				AND idempotent_request.request_hash = $2`,
+		Params: []any{key, hash, res.Status, headers, res.Body,
+			time.Now().Add(s.cfg.ServerIdempotency()).Unix()},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to update idempotent request row",
+			"idempotency_key", key)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey releases a claimed idempotency key without retaining a
+// response, such as when the request failed in a way which may succeed if it
+// is attempted again, so that retries of the request are processed.
+func (s *Service) ReleaseIdempotencyKey(ctx context.Context,
+	key, hash string,
+) error {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryDelete,
+		Base: `DELETE FROM idempotent_request
+			WHERE idempotent_request.idempotency_key = $1
+				AND idempotent_request.request_hash = $2
+				AND idempotent_request.status = 0`,
+		Params: []any{key, hash},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to delete idempotent request row",
+			"idempotency_key", key)
+	}
+
+	return nil
+}
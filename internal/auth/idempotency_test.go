@@ -0,0 +1,131 @@
+package auth_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("INSERT INTO idempotent_request").
+		WithArgs("key", "POST /resources", "hash", pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{"idempotency_key"}).
+			AddRow("key"))
+
+	res, err := svc.ClaimIdempotencyKey(ctx, "key", "POST /resources",
+		"hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res != nil {
+		t.Errorf("Expected claimed key, got response: %+v", res)
+	}
+
+	for _, hash := range []string{"hash", "other"} {
+		mockTransaction(mock)
+
+		mock.ExpectQuery("INSERT INTO idempotent_request").
+			WithArgs("key", "POST /resources", hash, pgxmock.AnyArg()).
+			WillReturnRows(mock.NewRows([]string{"idempotency_key"}))
+
+		mockTransaction(mock)
+
+		mock.ExpectQuery("SELECT (.+) FROM idempotent_request").
+			WithArgs("key").
+			WillReturnRows(mock.NewRows([]string{
+				"request_hash",
+				"status",
+				"headers",
+				"response",
+			}).AddRow("hash", http.StatusCreated,
+				[]byte(`{"Content-Type":"application/json"}`),
+				[]byte(`{"name":"test"}`)))
+	}
+
+	res, err = svc.ClaimIdempotencyKey(ctx, "key", "POST /resources",
+		"hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res == nil || res.Status != http.StatusCreated ||
+		res.Headers["Content-Type"] != "application/json" ||
+		string(res.Body) != `{"name":"test"}` {
+		t.Errorf("Expected retained response, got: %+v", res)
+	}
+
+	if _, err := svc.ClaimIdempotencyKey(ctx, "key", "POST /resources",
+		"other"); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if _, err := svc.ClaimIdempotencyKey(ctx, "", "POST /resources",
+		"hash"); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestSaveIdempotentResponse(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("UPDATE idempotent_request").
+		WithArgs("key", "hash", http.StatusCreated, pgxmock.AnyArg(),
+			[]byte(`{}`), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM idempotent_request").
+		WithArgs("key", "hash").
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	if err := svc.SaveIdempotentResponse(ctx, "key", "hash",
+		&auth.IdempotentResponse{
+			Status: http.StatusCreated,
+			Body:   []byte(`{}`),
+		}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.ReleaseIdempotencyKey(ctx, "key", "hash"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenIssuers retrieves the issuers accepted in the tokens of the account, in
+// addition to those configured for the service, stored in the account data
+// under the token_issuers key as a list of strings.
+func (a *Account) TokenIssuers() []string {
+	return a.dataStrings("token_issuers")
+}
+
+// TokenAudiences retrieves the audiences accepted in the tokens of the
+// account, in addition to those configured for the service, stored in the
+// account data under the token_audiences key as a list of strings.
+func (a *Account) TokenAudiences() []string {
+	return a.dataStrings("token_audiences")
+}
+
+// dataStrings retrieves a list of strings from the account data. Values which
+// are not strings are ignored.
+func (a *Account) dataStrings(key string) []string {
+	if a == nil || !a.Data.Valid {
+		return nil
+	}
+
+	l, ok := a.Data.Value[key].([]any)
+	if !ok {
+		return nil
+	}
+
+	res := []string{}
+
+	for _, v := range l {
+		if s, ok := v.(string); ok && s != "" {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
+
+// validateTokenTrust checks that any token issuers and audiences in the
+// account data are lists of strings.
+func (a *Account) validateTokenTrust() error {
+	if !a.Data.Set || !a.Data.Valid {
+		return nil
+	}
+
+	for _, key := range []string{"token_issuers", "token_audiences"} {
+		v, ok := a.Data.Value[key]
+		if !ok || v == nil {
+			continue
+		}
+
+		l, ok := v.([]any)
+		if !ok {
+			return errors.New(errors.ErrInvalidRequest,
+				key+" must be a list of strings",
+				"account", a)
+		}
+
+		for _, s := range l {
+			if s, ok := s.(string); !ok || s == "" {
+				return errors.New(errors.ErrInvalidRequest,
+					key+" must be a list of strings",
+					"account", a)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyIssuer checks that the iss claim of a token is one of the accepted
+// issuers, and that its aud claim contains one of the accepted audiences.
+// Those configured for the service are accepted for every account, and the
+// account issuing the token may accept others.
+func (s *Service) verifyIssuer(ctx context.Context,
+	claims jwt.MapClaims,
+	accountID string,
+) error {
+	iss, err := claims.GetIssuer()
+	if err != nil || iss == "" {
+		return errors.New(errors.ErrUnauthorized,
+			"missing token issuer")
+	}
+
+	aud, err := claims.GetAudience()
+	if err != nil || len(aud) == 0 {
+		return errors.New(errors.ErrUnauthorized,
+			"missing token audience")
+	}
+
+	issuers, audiences := s.cfg.AuthTokenIssuers(), s.cfg.AuthTokenAudiences()
+
+	accepted := func() bool {
+		return slices.Contains(issuers, iss) &&
+			slices.ContainsFunc(aud, func(a string) bool {
+				return slices.Contains(audiences, a)
+			})
+	}
+
+	if accepted() {
+		return nil
+	}
+
+	// The account is only retrieved when the token is not accepted for every
+	// account.
+	if accountID != "" {
+		a, err := s.GetAccount(request.WithAccountID(ctx,
+			request.SystemAccount), accountID)
+		if err != nil && !errors.Has(err, errors.ErrNotFound) {
+			return err
+		}
+
+		issuers = append(issuers, a.TokenIssuers()...)
+		audiences = append(audiences, a.TokenAudiences()...)
+
+		if accepted() {
+			return nil
+		}
+	}
+
+	return errors.New(errors.ErrUnauthorized,
+		"token issuer or audience not accepted",
+		"iss", iss,
+		"aud", aud,
+		"account_id", accountID)
+}
@@ -0,0 +1,149 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestAccountTokenIssuers(t *testing.T) {
+	t.Parallel()
+
+	a := &auth.Account{Data: request.FieldJSON{
+		Set: true, Valid: true,
+		Value: map[string]any{
+			"token_issuers":   []any{"https://issuer.test/"},
+			"token_audiences": []any{"test"},
+		},
+	}}
+
+	if err := a.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := a.TokenIssuers(); len(v) != 1 || v[0] != "https://issuer.test/" {
+		t.Errorf("Expected token issuers: [https://issuer.test/], got: %v", v)
+	}
+
+	if v := a.TokenAudiences(); len(v) != 1 || v[0] != "test" {
+		t.Errorf("Expected token audiences: [test], got: %v", v)
+	}
+
+	a.Data.Value["token_issuers"] = "https://issuer.test/"
+
+	if err := a.Validate(); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+}
+
+func TestAuthJWTIssuer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		lookup bool
+	}{{
+		name: "unknown issuer",
+		claims: map[string]any{
+			"iss": "https://issuer.test/",
+			"aud": []string{config.DefaultServiceName},
+		},
+		lookup: true,
+	}, {
+		name: "unknown audience",
+		claims: map[string]any{
+			"iss": config.DefaultAuthTokenIssuer,
+			"aud": []string{"test"},
+		},
+		lookup: true,
+	}, {
+		name: "missing audience",
+		claims: map[string]any{
+			"iss": config.DefaultAuthTokenIssuer,
+		},
+	}, {
+		name: "missing expiration",
+		claims: map[string]any{
+			"iss": config.DefaultAuthTokenIssuer,
+			"aud": []string{config.DefaultServiceName},
+			"exp": nil,
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc := auth.NewService(nil, md, nil, nil, nil, nil)
+
+			now := time.Now()
+
+			claims := jwt.MapClaims{
+				"exp":    now.Add(time.Hour).Unix(),
+				"iat":    now.Unix(),
+				"nbf":    now.Unix(),
+				"sub":    TestUser.UserID.Value,
+				"scopes": request.ScopeSuperuser,
+			}
+
+			for k, v := range tt.claims {
+				if v == nil {
+					delete(claims, k)
+
+					continue
+				}
+
+				claims[k] = v
+			}
+
+			tok := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+
+			tok.Header = map[string]any{
+				"alg": "HS512",
+				"kid": TestID,
+			}
+
+			authToken, err := tok.SignedString([]byte(TestAccount.Secret.Value))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			mockTransaction(mock)
+
+			mock.ExpectQuery("SELECT (.+) FROM account").
+				WithArgs(TestID, "").
+				WillReturnRows(mockAccountSecretRows(mock))
+
+			if tt.lookup {
+				mockTransaction(mock)
+
+				mock.ExpectQuery("SELECT (.+) FROM account").
+					WithArgs(pgxmock.AnyArg()).
+					WillReturnRows(mockAccountRows(mock))
+			}
+
+			if _, err := svc.AuthJWT(context.Background(), authToken,
+				""); !errors.Has(err, errors.ErrUnauthorized) {
+				t.Errorf("Expected unauthorized error, got: %v", err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet database expectations: %v", err)
+			}
+		})
+	}
+}
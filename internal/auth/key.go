@@ -0,0 +1,474 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// keyIDSeparator separates the account ID from the ID of the signing key, in
+// the kid header of the tokens issued by an account. It is not valid in
+// account IDs.
+const keyIDSeparator = "#"
+
+// keyRotationInterval is the frequency at which signing keys due to be rotated
+// are found, and expired keys deleted.
+const keyRotationInterval = time.Minute * 10
+
+// SigningKey values represent the keys used to sign the tokens issued by an
+// account. Only the active key signs new tokens. When the keys are rotated, the
+// replaced keys continue to validate the tokens they signed until they expire.
+type SigningKey struct {
+	KeyID     string `json:"key_id"`
+	Active    bool   `json:"active"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+}
+
+// accountKey values contain the secret used to sign tokens with a signing key
+// of an account, and when the key expires, which is zero for the active key.
+type accountKey struct {
+	kid       string
+	secret    []byte
+	expiresAt int64
+}
+
+// tokenKeyID returns the kid header of the tokens signed using a key of an
+// account. The original key of the account, derived from the account secret
+// alone, has an empty ID, and is identified by the account ID.
+func tokenKeyID(accountID, keyID string) string {
+	if keyID == "" {
+		return accountID
+	}
+
+	return accountID + keyIDSeparator + keyID
+}
+
+// parseKeyID splits the kid header of a token issued by an account into the
+// account ID and the ID of the signing key.
+func parseKeyID(kid string) (string, string) {
+	accountID, keyID, _ := strings.Cut(kid, keyIDSeparator)
+
+	return accountID, keyID
+}
+
+// signingSecret derives the secret used to sign tokens using a key of an
+// account. If an account secret pepper is configured, the account secret is
+// combined with it, so that the stored secrets alone cannot sign tokens. Since
+// the secrets of all keys are derived from the account secret, replacing it
+// invalidates the tokens signed by every key.
+func (s *Service) signingSecret(accountSecret, keySecret string) []byte {
+	secret := []byte(accountSecret)
+
+	if pepper := s.cfg.AuthAccountPepper(); len(pepper) > 0 {
+		h := hmac.New(sha512.New, pepper)
+
+		h.Write(secret)
+
+		secret = h.Sum(nil)
+	}
+
+	if keySecret == "" {
+		return secret
+	}
+
+	h := hmac.New(sha512.New, secret)
+
+	h.Write([]byte(keySecret))
+
+	return h.Sum(nil)
+}
+
+// getAccountKey retrieves a signing key of an account, by the kid header of a
+// token it signed, for verification of the token. The original key of the
+// account remains valid until the keys are first rotated, and for the grace
+// period after.
+func (s *Service) getAccountKey(ctx context.Context,
+	kid string,
+) (*accountKey, error) {
+	accountID, keyID := parseKeyID(kid)
+
+	res, err := s.queryAccountKey(ctx, accountID, `SELECT
+			account.secret,
+			COALESCE(account_key.key_id, ''),
+			COALESCE(account_key.secret, ''),
+			COALESCE(EXTRACT(epoch FROM account_key.expires_at)::BIGINT, 0)
+		FROM account
+		LEFT JOIN account_key
+			ON account_key.account_id = account.account_id
+			AND account_key.key_id = $2
+		WHERE account.account_id = $1
+		LIMIT 1`, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.kid != kid {
+		return nil, errors.New(errors.ErrUnauthorized,
+			"signing key not found",
+			"kid", kid)
+	}
+
+	if res.expiresAt != 0 && res.expiresAt <= time.Now().Unix() {
+		return nil, errors.New(errors.ErrUnauthorized,
+			"signing key expired",
+			"kid", kid)
+	}
+
+	return res, nil
+}
+
+// getActiveAccountKey retrieves the signing key of an account used to sign new
+// tokens, which is the original key of the account until the keys are first
+// rotated.
+func (s *Service) getActiveAccountKey(ctx context.Context,
+	accountID string,
+) (*accountKey, error) {
+	return s.queryAccountKey(ctx, accountID, `SELECT
+			account.secret,
+			COALESCE(account_key.key_id, ''),
+			COALESCE(account_key.secret, ''),
+			0
+		FROM account
+		LEFT JOIN account_key
+			ON account_key.account_id = account.account_id
+			AND account_key.expires_at IS NULL
+		WHERE account.account_id = $1
+		ORDER BY account_key.created_at DESC
+		LIMIT 1`)
+}
+
+// queryAccountKey retrieves a signing key of an account using a query
+// selecting the account secret, the ID and secret of the key, and when the key
+// expires.
+func (s *Service) queryAccountKey(ctx context.Context,
+	accountID, base string,
+	params ...any,
+) (*accountKey, error) {
+	ctx = request.WithAccountID(ctx, accountID)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Params: append([]any{accountID}, params...),
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	var (
+		accountSecret    *string
+		keyID, keySecret string
+		expiresAt        int64
+	)
+
+	if err := row.Scan(&accountSecret, &keyID, &keySecret,
+		&expiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"unable to find account secret")
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select account secret row")
+	}
+
+	if accountSecret == nil {
+		return nil, errors.New(errors.ErrNotFound,
+			"account secret not found")
+	}
+
+	if keyID == "" {
+		keySecret = ""
+	}
+
+	return &accountKey{
+		kid:       tokenKeyID(accountID, keyID),
+		secret:    s.signingSecret(*accountSecret, keySecret),
+		expiresAt: expiresAt,
+	}, nil
+}
+
+// GetSigningKeys retrieves the unexpired signing keys of the current account,
+// starting with the active key. Key IDs are the kid headers of the tokens
+// signed using the keys.
+func (s *Service) GetSigningKeys(ctx context.Context,
+) ([]*SigningKey, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+				account_key.key_id,
+				COALESCE(EXTRACT(epoch FROM account_key.expires_at)::BIGINT, 0),
+				EXTRACT(epoch FROM account_key.created_at)::BIGINT
+			FROM account_key
+			WHERE account_key.account_id = $1
+				AND (account_key.expires_at IS NULL
+					OR account_key.expires_at > CURRENT_TIMESTAMP)
+			ORDER BY account_key.expires_at DESC NULLS FIRST,
+				account_key.created_at DESC`,
+		Params: []any{accountID},
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	defer rows.Close()
+
+	res := []*SigningKey{}
+
+	for rows.Next() {
+		k, keyID := &SigningKey{}, ""
+
+		if err := rows.Scan(&keyID, &k.ExpiresAt,
+			&k.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select signing key row")
+		}
+
+		k.KeyID = tokenKeyID(accountID, keyID)
+		k.Active = k.ExpiresAt == 0 && len(res) == 0
+
+		res = append(res, k)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select signing key rows")
+	}
+
+	// Until the keys are first rotated, tokens are signed using the original
+	// key of the account, which has no row.
+	if len(res) == 0 {
+		res = append(res, &SigningKey{KeyID: accountID, Active: true})
+	}
+
+	return res, nil
+}
+
+// RotateSigningKey replaces the active signing key of the current account with
+// a new key, which signs the tokens issued from then on. Tokens signed using
+// the replaced key remain valid for the configured grace period, after which
+// they must be issued again.
+func (s *Service) RotateSigningKey(ctx context.Context,
+) (*SigningKey, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.rotateAccountKey(ctx, accountID)
+}
+
+// rotateAccountKey replaces the active signing key of an account.
+func (s *Service) rotateAccountKey(ctx context.Context,
+	accountID string,
+) (*SigningKey, error) {
+	ctx = request.WithAccountID(ctx, accountID)
+
+	now := time.Now()
+
+	expiresAt := now.Add(s.cfg.AuthTokenKeyGrace()).Unix()
+
+	res := &SigningKey{
+		KeyID:     uuid.NewString(),
+		Active:    true,
+		CreatedAt: now.Unix(),
+	}
+
+	if err := sqldb.RunTx(ctx, s.db, func(ctx context.Context) error {
+		// The original key of the account is recorded when it is first
+		// replaced, so that its expiration can be enforced.
+		q := sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   s.db,
+			Type: sqldb.QueryInsert,
+			Base: `INSERT INTO account_key (account_id, key_id, expires_at)
+				VALUES ($1, '', TO_TIMESTAMP($2))
+				ON CONFLICT (account_id, key_id) DO NOTHING`,
+			Params: []any{accountID, expiresAt},
+		})
+
+		if _, err := q.Exec(ctx); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to insert original signing key row",
+				"account_id", accountID)
+		}
+
+		q = sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   s.db,
+			Type: sqldb.QueryUpdate,
+			Base: `UPDATE account_key SET
+					expires_at = TO_TIMESTAMP($2)
+				WHERE account_key.account_id = $1
+					AND account_key.expires_at IS NULL`,
+			Params: []any{accountID, expiresAt},
+		})
+
+		if _, err := q.Exec(ctx); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to update signing key rows",
+				"account_id", accountID)
+		}
+
+		q = sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   s.db,
+			Type: sqldb.QueryInsert,
+			Base: `INSERT INTO account_key (account_id, key_id, created_at)
+				VALUES ($1, $2, TO_TIMESTAMP($3))`,
+			Params: []any{accountID, res.KeyID, res.CreatedAt},
+		})
+
+		if _, err := q.Exec(ctx); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to insert signing key row",
+				"account_id", accountID)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	res.KeyID = tokenKeyID(accountID, res.KeyID)
+
+	return res, nil
+}
+
+// RotateSigningKeys periodically rotates the signing keys of active accounts
+// which are older than the configured rotation age, if any, and deletes
+// expired keys. The rotation stops when the returned function is called.
+func (s *Service) RotateSigningKeys(ctx context.Context) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+
+	ctx = request.WithNewTraceID(ctx)
+
+	go func(ctx context.Context) {
+		tick := time.NewTimer(0)
+
+		for {
+			select {
+			case <-ctx.Done():
+				tick.Stop()
+
+				return
+			case <-tick.C:
+				s.rotateAccountKeys(ctx)
+			}
+
+			tick = time.NewTimer(keyRotationInterval)
+		}
+	}(ctx)
+
+	return cancel
+}
+
+// rotateAccountKeys rotates the signing keys of the active accounts which are
+// due to be rotated, and deletes the rotated keys which have expired. The row
+// recording the expiration of the original key of an account is not deleted,
+// since without it tokens signed by the account secret would be valid again.
+func (s *Service) rotateAccountKeys(ctx context.Context) {
+	ctx, cancel := request.ContextReplaceTimeout(ctx, s.cfg.ServerTimeout())
+	defer cancel()
+
+	ctx = request.WithAccountID(ctx, request.SystemAccount)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryDelete,
+		Base: `DELETE FROM account_key
+			WHERE account_key.key_id <> ''
+				AND account_key.expires_at < $1`,
+		Params: []any{time.Now()},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to delete expired signing keys",
+			"error", err)
+	}
+
+	rotation := s.cfg.AuthTokenKeyRotation()
+	if rotation <= 0 {
+		return
+	}
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT account.account_id
+			FROM account
+			WHERE account.status = $1
+				AND COALESCE((SELECT MAX(account_key.created_at)
+					FROM account_key
+					WHERE account_key.account_id = account.account_id
+						AND account_key.expires_at IS NULL),
+					account.created_at) < TO_TIMESTAMP($2)`,
+		Params: []any{request.StatusActive,
+			time.Now().Add(-rotation).Unix()},
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to select accounts for signing key rotation",
+			"error", err)
+
+		return
+	}
+
+	ids := []string{}
+
+	for rows.Next() {
+		id := ""
+
+		if err := rows.Scan(&id); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to select account row for signing key rotation",
+				"error", err)
+
+			break
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	for _, id := range ids {
+		k, err := s.rotateAccountKey(ctx, id)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to rotate signing key",
+				"error", err,
+				"account_id", id)
+
+			continue
+		}
+
+		s.log.Log(ctx, logger.LvlInfo,
+			"signing key rotated",
+			"account_id", id,
+			"key_id", k.KeyID)
+	}
+}
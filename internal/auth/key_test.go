@@ -0,0 +1,209 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestAuthJWTRotatedKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cfg := config.NewDefault()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	now := time.Now()
+
+	keyExpiresAt := now.Add(time.Hour).Unix()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"exp":    now.Add(cfg.AuthTokenExpiresIn()).Unix(),
+		"iat":    now.Unix(),
+		"nbf":    now.Unix(),
+		"iss":    cfg.AuthTokenIssuer(),
+		"sub":    TestUser.UserID.Value,
+		"aud":    []string{cfg.ServiceName()},
+		"scopes": request.ScopeSuperuser,
+	})
+
+	tok.Header = map[string]any{
+		"alg": "HS512",
+		"kid": TestID + "#" + TestUUID,
+	}
+
+	h := hmac.New(sha512.New, []byte(TestAccount.Secret.Value))
+
+	h.Write([]byte(TestName))
+
+	authToken, err := tok.SignedString(h.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID, TestUUID).
+		WillReturnRows(mock.NewRows([]string{
+			"secret",
+			"key_id",
+			"key_secret",
+			"expires_at",
+		}).AddRow(
+			&TestAccount.Secret.Value,
+			TestUUID,
+			TestName,
+			keyExpiresAt,
+		))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	c, err := svc.AuthJWT(ctx, authToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.KeyExpiresAt != keyExpiresAt {
+		t.Errorf("Expected key expiration: %v, got: %v",
+			keyExpiresAt, c.KeyExpiresAt)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestAuthJWTExpiredKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cfg := config.NewDefault()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	now := time.Now()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"exp":    now.Add(cfg.AuthTokenExpiresIn()).Unix(),
+		"iat":    now.Unix(),
+		"nbf":    now.Unix(),
+		"iss":    cfg.AuthTokenIssuer(),
+		"sub":    TestUser.UserID.Value,
+		"aud":    []string{cfg.ServiceName()},
+		"scopes": request.ScopeSuperuser,
+	})
+
+	tok.Header = map[string]any{
+		"alg": "HS512",
+		"kid": TestID,
+	}
+
+	authToken, err := tok.SignedString([]byte(TestAccount.Secret.Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID, "").
+		WillReturnRows(mock.NewRows([]string{
+			"secret",
+			"key_id",
+			"key_secret",
+			"expires_at",
+		}).AddRow(
+			&TestAccount.Secret.Value,
+			"",
+			"",
+			now.Add(-time.Minute).Unix(),
+		))
+
+	if _, err := svc.AuthJWT(ctx, authToken, ""); err == nil {
+		t.Error("Expected error for token signed by expired key, got: nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestGetSigningKeys(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account_key").
+		WithArgs(TestID).
+		WillReturnRows(mock.NewRows([]string{
+			"key_id",
+			"expires_at",
+			"created_at",
+		}).AddRow(
+			TestUUID,
+			int64(0),
+			int64(1704067200),
+		).AddRow(
+			"",
+			int64(1704153600),
+			int64(1704067200),
+		))
+
+	res, err := svc.GetSigningKeys(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 2 {
+		t.Fatalf("Expected keys: 2, got: %v", len(res))
+	}
+
+	if res[0].KeyID != TestID+"#"+TestUUID || !res[0].Active {
+		t.Errorf("Expected active key: %v, got: %+v",
+			TestID+"#"+TestUUID, res[0])
+	}
+
+	if res[1].KeyID != TestID || res[1].Active {
+		t.Errorf("Expected replaced original key: %v, got: %+v",
+			TestID, res[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
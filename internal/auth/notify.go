@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"net/mail"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// maxNotifyEmails is the maximum number of notification recipients of an
+// account.
+const maxNotifyEmails = 20
+
+// NotifyEmails retrieves the addresses of the recipients of the email
+// notifications of the account, stored in the account data under the notify
+// key, as a list of emails. They are sent notifications of failed resource
+// imports, and of alerts for rules with an email channel without addresses.
+// Any invalid addresses are skipped.
+func (a *Account) NotifyEmails() []string {
+	res := []string{}
+
+	if a == nil || !a.Data.Valid {
+		return res
+	}
+
+	m, ok := a.Data.Value["notify"].(map[string]any)
+	if !ok {
+		return res
+	}
+
+	l, ok := m["emails"].([]any)
+	if !ok {
+		return res
+	}
+
+	for _, v := range l {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		if _, err := mail.ParseAddress(s); err != nil {
+			continue
+		}
+
+		res = append(res, s)
+	}
+
+	return res
+}
+
+// validateNotify checks that any notification recipients in the account data
+// are valid email addresses.
+func (a *Account) validateNotify() error {
+	if !a.Data.Set || !a.Data.Valid {
+		return nil
+	}
+
+	v, ok := a.Data.Value["notify"]
+	if !ok || v == nil {
+		return nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return errors.New(errors.ErrInvalidRequest,
+			"notify must be an object",
+			"account", a)
+	}
+
+	for k, v := range m {
+		if k != "emails" {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid notify key",
+				"key", k,
+				"account", a)
+		}
+
+		l, ok := v.([]any)
+		if !ok || len(l) > maxNotifyEmails {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid notify emails",
+				"account", a)
+		}
+
+		for _, e := range l {
+			s, ok := e.(string)
+			if !ok {
+				return errors.New(errors.ErrInvalidRequest,
+					"invalid notify email",
+					"account", a)
+			}
+
+			if _, err := mail.ParseAddress(s); err != nil {
+				return errors.Wrap(err, errors.ErrInvalidRequest,
+					"invalid notify email",
+					"email", s,
+					"account", a)
+			}
+		}
+	}
+
+	return nil
+}
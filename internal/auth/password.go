@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// minPasswordLength is the minimum length of a password set by a user.
+const minPasswordLength = 8
+
+// PasswordChange values contain a request by a user to change their password.
+type PasswordChange struct {
+	CurrentPassword string `json:"current_password" yaml:"current_password"`
+	Password        string `json:"password"         yaml:"password"`
+}
+
+// Parameters of argon2id password hashes.
+const (
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// argon2Prefix is the prefix of argon2id password hashes. Passwords hashed
+// before argon2id was used are bcrypt hashes.
+const argon2Prefix = "$argon2id$"
+
+// hashPassword creates a hashed password, as an encoded argon2id hash.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+
+	if _, err := rand.Read(salt); err != nil {
+		return "", errors.Wrap(err, errors.ErrServer,
+			"unable to hash password")
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory,
+		argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix,
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// verifyPassword verifies if a password matches a hashed password, which may
+// be an argon2id or bcrypt hash.
+func verifyPassword(hashedPassword, password string) error {
+	if !strings.HasPrefix(hashedPassword, argon2Prefix) {
+		return bcrypt.CompareHashAndPassword([]byte(hashedPassword),
+			[]byte(password))
+	}
+
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		return errors.New(errors.ErrServer, "invalid password hash")
+	}
+
+	var (
+		version, memory uint32
+		iterations      uint32
+		threads         uint8
+	)
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil ||
+		version != argon2.Version {
+		return errors.New(errors.ErrServer, "invalid password hash version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d",
+		&memory, &iterations, &threads); err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"invalid password hash parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"invalid password hash salt")
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"invalid password hash")
+	}
+
+	if subtle.ConstantTimeCompare(key, argon2.IDKey([]byte(password), salt,
+		iterations, memory, threads, uint32(len(key)))) != 1 {
+		return errors.New(errors.ErrUnauthorized, "password mismatch")
+	}
+
+	return nil
+}
+
+// checkPassword verifies the password of a user of the account of the
+// context. Consecutive failed attempts are counted, and once there are too
+// many, the user is locked out for the lockout time. Passwords hashed with
+// bcrypt are replaced with argon2id hashes on success.
+func (s *Service) checkPassword(ctx context.Context,
+	userID, password string,
+) error {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+			"user".password,
+			"user".failed_logins,
+			COALESCE(EXTRACT(epoch FROM "user".locked_until)::BIGINT, 0)
+		FROM "user"
+		WHERE "user".user_id = $1`,
+		Fields: userFields,
+		Params: []any{userID},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "",
+			"user_id", userID)
+	}
+
+	hp, failures, lockedUntil := new(string), 0, int64(0)
+
+	if err := row.Scan(&hp, &failures, &lockedUntil); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return errors.New(errors.ErrNotFound,
+				"user not found",
+				"user_id", userID)
+		}
+
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to select user password",
+			"user_id", userID)
+	}
+
+	if hp == nil || *hp == "" {
+		return errors.New(errors.ErrUnauthorized,
+			"user cannot login",
+			"user_id", userID)
+	}
+
+	if lockedUntil > time.Now().Unix() {
+		return errors.New(errors.ErrorRateLimit,
+			"user locked out after too many failed login attempts",
+			"user_id", userID,
+			"locked_until", time.Unix(lockedUntil, 0).UTC())
+	}
+
+	if err := verifyPassword(*hp, password); err != nil {
+		s.failLogin(ctx, userID)
+
+		return errors.New(errors.ErrUnauthorized,
+			"invalid user id or password",
+			"user_id", userID)
+	}
+
+	var rehash *string
+
+	if !strings.HasPrefix(*hp, argon2Prefix) {
+		if v, err := hashPassword(password); err == nil {
+			rehash = &v
+		}
+	}
+
+	if failures == 0 && rehash == nil {
+		return nil
+	}
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryUpdate,
+		Base: `UPDATE "user" SET
+				password = COALESCE($2, "user".password),
+				failed_logins = 0,
+				locked_until = NULL
+			WHERE "user".user_id = $1`,
+		Params: []any{userID, rehash},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to reset failed logins",
+			"error", err,
+			"user_id", userID)
+	}
+
+	return nil
+}
+
+// failLogin counts a failed password authentication attempt by a user, and
+// locks the user out once there have been too many consecutive failed
+// attempts.
+func (s *Service) failLogin(ctx context.Context, userID string) {
+	maxAttempts := s.cfg.AuthLocalMaxAttempts()
+	if maxAttempts < 0 {
+		return
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryUpdate,
+		Base: `UPDATE "user" SET
+				failed_logins = CASE
+					WHEN "user".failed_logins + 1 >= $2 THEN 0
+					ELSE "user".failed_logins + 1 END,
+				locked_until = CASE
+					WHEN "user".failed_logins + 1 >= $2 THEN
+						CURRENT_TIMESTAMP + $3 * INTERVAL '1 millisecond'
+					ELSE "user".locked_until END
+			WHERE "user".user_id = $1`,
+		Params: []any{userID, maxAttempts,
+			s.cfg.AuthLocalLockout().Milliseconds()},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to record failed login",
+			"error", err,
+			"user_id", userID)
+	}
+}
+
+// ChangePassword changes the password of the user of the context, after
+// verifying the current password of the user.
+func (s *Service) ChangePassword(ctx context.Context,
+	current, password string,
+) error {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(password) < minPasswordLength {
+		return errors.New(errors.ErrInvalidRequest,
+			fmt.Sprintf("password must contain at least %d characters",
+				minPasswordLength),
+			"user_id", userID)
+	}
+
+	if err := s.checkPassword(ctx, userID, current); err != nil {
+		return err
+	}
+
+	hp, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryUpdate,
+		Base: `UPDATE "user" SET
+				password = $2,
+				failed_logins = 0,
+				locked_until = NULL
+			WHERE "user".user_id = $1`,
+		Params: []any{userID, hp},
+	})
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to update user password",
+			"user_id", userID)
+	}
+
+	if res.RowsAffected() == 0 {
+		return errors.New(errors.ErrNotFound,
+			"user not found",
+			"user_id", userID)
+	}
+
+	return nil
+}
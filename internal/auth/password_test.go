@@ -0,0 +1,117 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestAuthPasswordLockout(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	hp, err := hashPassword(TestPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
+		WithArgs(TestName).
+		WillReturnRows(mock.NewRows([]string{
+			"password",
+			"failed_logins",
+			"locked_until",
+		}).AddRow(&hp, 1, int64(0)))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec(`UPDATE "user" SET`).
+		WithArgs(TestName, config.DefaultAuthLocalMaxAttempts,
+			config.DefaultAuthLocalLockout.Milliseconds()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	err = svc.AuthPassword(ctx, TestName, "invalid", "")
+	if !errors.Has(err, errors.ErrUnauthorized) {
+		t.Errorf("Expected unauthorized error, got: %v", err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
+		WithArgs(TestName).
+		WillReturnRows(mock.NewRows([]string{
+			"password",
+			"failed_logins",
+			"locked_until",
+		}).AddRow(&hp, 0, time.Now().Add(time.Minute).Unix()))
+
+	err = svc.AuthPassword(ctx, TestName, TestPassword, "")
+	if !errors.Has(err, errors.ErrorRateLimit) {
+		t.Errorf("Expected locked out error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	t.Parallel()
+
+	ctx := request.WithUserID(mockAuthContext(), TestName)
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	err = svc.ChangePassword(ctx, TestPassword, "short")
+	if !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
+		WithArgs(TestName).WillReturnRows(mockUserPasswordRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec(`UPDATE "user" SET`).
+		WithArgs(TestName, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec(`UPDATE "user" SET`).
+		WithArgs(TestName, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	if err := svc.ChangePassword(ctx, TestPassword,
+		"new-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
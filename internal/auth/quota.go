@@ -0,0 +1,384 @@
+package auth
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// UsageQuota values contain the number of requests an account may make, and
+// the request body bytes it may send, on each UTC day and in each UTC month.
+// Quotas of zero are not enforced.
+type UsageQuota struct {
+	DailyRequests      int64 `json:"daily_requests"`
+	MonthlyRequests    int64 `json:"monthly_requests"`
+	DailyIngestBytes   int64 `json:"daily_ingest_bytes"`
+	MonthlyIngestBytes int64 `json:"monthly_ingest_bytes"`
+}
+
+// fields returns the quotas by their keys in the account data.
+func (q *UsageQuota) fields() map[string]*int64 {
+	return map[string]*int64{
+		"daily_requests":       &q.DailyRequests,
+		"monthly_requests":     &q.MonthlyRequests,
+		"daily_ingest_bytes":   &q.DailyIngestBytes,
+		"monthly_ingest_bytes": &q.MonthlyIngestBytes,
+	}
+}
+
+// QuotaUsage values contain the quotas of an account, and its usage counted
+// against them in the current UTC day and month.
+type QuotaUsage struct {
+	UsageQuota
+	Day              string `json:"day"`
+	Month            string `json:"month"`
+	DayRequests      int64  `json:"day_requests"`
+	DayIngestBytes   int64  `json:"day_ingest_bytes"`
+	MonthRequests    int64  `json:"month_requests"`
+	MonthIngestBytes int64  `json:"month_ingest_bytes"`
+}
+
+// quotaEntry values contain the quotas of an account, and its usage recorded
+// in the database, when they were last retrieved.
+type quotaEntry struct {
+	usage  QuotaUsage
+	loaded time.Time
+}
+
+// quotaUsages contains the quotas and recorded usage of the accounts making
+// requests to the service instance, so that they are retrieved at most once
+// each usage interval, rather than for every request.
+var quotaUsages = struct {
+	sync.Mutex
+	accounts map[string]*quotaEntry
+}{accounts: map[string]*quotaEntry{}}
+
+// Quota retrieves the quotas of the account, stored in the account data under
+// the quotas key, as an object containing any of daily_requests,
+// monthly_requests, daily_ingest_bytes and monthly_ingest_bytes. Quotas which
+// are missing or invalid are taken from the default quotas.
+func (a *Account) Quota(def UsageQuota) UsageQuota {
+	if a == nil || !a.Data.Valid {
+		return def
+	}
+
+	m, ok := a.Data.Value["quotas"].(map[string]any)
+	if !ok {
+		return def
+	}
+
+	res := def
+
+	for k, p := range res.fields() {
+		if n, ok := retentionSeconds(m[k]); ok && n >= 0 {
+			*p = n
+		}
+	}
+
+	return res
+}
+
+// validateQuotas checks that any quotas in the account data are non-negative
+// whole numbers.
+func (a *Account) validateQuotas() error {
+	if !a.Data.Set || !a.Data.Valid {
+		return nil
+	}
+
+	v, ok := a.Data.Value["quotas"]
+	if !ok || v == nil {
+		return nil
+	}
+
+	m, ok := v.(map[string]any)
+	if !ok {
+		return errors.New(errors.ErrInvalidRequest,
+			"quotas must be an object",
+			"account", a)
+	}
+
+	fields := (&UsageQuota{}).fields()
+
+	for k, v := range m {
+		if _, ok := fields[k]; !ok {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid quota: "+k,
+				"account", a)
+		}
+
+		if n, ok := retentionSeconds(v); !ok || n < 0 {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid quota: "+k,
+				"account", a)
+		}
+	}
+
+	return nil
+}
+
+// keepQuotas replaces any quotas in the data of an account being created, or
+// updated, by an account administrator with the quotas already stored for the
+// account, so that only system administrators can change them.
+func (s *Service) keepQuotas(ctx context.Context, v *Account) error {
+	if !v.Data.Set {
+		return nil
+	}
+
+	var quotas any
+
+	if old, err := s.getAccount(ctx, v.AccountID.Value); err == nil {
+		if old.Data.Valid {
+			quotas = old.Data.Value["quotas"]
+		}
+	} else if !errors.Has(err, errors.ErrNotFound) {
+		return err
+	}
+
+	if !v.Data.Valid || v.Data.Value == nil {
+		if quotas == nil {
+			return nil
+		}
+
+		v.Data = request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{},
+		}
+	}
+
+	if quotas == nil {
+		delete(v.Data.Value, "quotas")
+	} else {
+		v.Data.Value["quotas"] = quotas
+	}
+
+	return nil
+}
+
+// defaultQuota returns the quotas configured for accounts which do not set
+// their own.
+func (s *Service) defaultQuota() UsageQuota {
+	return UsageQuota{
+		DailyRequests:      s.cfg.QuotaDailyRequests(),
+		MonthlyRequests:    s.cfg.QuotaMonthlyRequests(),
+		DailyIngestBytes:   s.cfg.QuotaDailyIngest(),
+		MonthlyIngestBytes: s.cfg.QuotaMonthlyIngest(),
+	}
+}
+
+// loadQuotaUsage retrieves the quotas of an account, and its usage in the
+// current UTC day and month recorded in the database.
+func (s *Service) loadQuotaUsage(ctx context.Context,
+	accountID string,
+	now time.Time,
+) (*QuotaUsage, error) {
+	a, err := s.GetAccount(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	now = now.UTC()
+
+	res := &QuotaUsage{
+		UsageQuota: a.Quota(s.defaultQuota()),
+		Day:        now.Format(time.DateOnly),
+		Month:      now.Format("2006-01"),
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+			COALESCE(SUM(request_count.requests)
+				FILTER (WHERE request_count.day = $2::DATE), 0)::BIGINT,
+			COALESCE(SUM(request_count.ingest_bytes)
+				FILTER (WHERE request_count.day = $2::DATE), 0)::BIGINT,
+			COALESCE(SUM(request_count.requests), 0)::BIGINT,
+			COALESCE(SUM(request_count.ingest_bytes), 0)::BIGINT
+		FROM request_count
+		WHERE request_count.day BETWEEN $1::DATE AND $2::DATE`,
+		Params: []any{res.Month + "-01", res.Day},
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"account_id", accountID)
+	}
+
+	if err := row.Scan(&res.DayRequests, &res.DayIngestBytes,
+		&res.MonthRequests, &res.MonthIngestBytes); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select quota usage",
+			"account_id", accountID)
+	}
+
+	return res, nil
+}
+
+// addPendingUsage adds the requests made by an account in the day and month of
+// the quota usage, not yet recorded in the database, to the quota usage.
+func addPendingUsage(accountID string, u *QuotaUsage) {
+	counts := map[requestDay]requestTotal{}
+
+	pendingRequests(accountID, u.Month+"-01", u.Day, counts)
+
+	for d, n := range counts {
+		u.MonthRequests += n.requests
+		u.MonthIngestBytes += n.bytes
+
+		if d.day == u.Day {
+			u.DayRequests += n.requests
+			u.DayIngestBytes += n.bytes
+		}
+	}
+}
+
+// GetQuotaUsage retrieves the quotas of the account, and its usage counted
+// against them in the current UTC day and month.
+func (s *Service) GetQuotaUsage(ctx context.Context) (*QuotaUsage, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.loadQuotaUsage(ctx, accountID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	addPendingUsage(accountID, res)
+
+	return res, nil
+}
+
+// cachedQuotaUsage retrieves the quotas of an account, and its usage, using
+// the recorded usage retrieved by the service instance within the last usage
+// interval, if there is any.
+func (s *Service) cachedQuotaUsage(ctx context.Context,
+	accountID string,
+	now time.Time,
+) (*QuotaUsage, error) {
+	day := now.UTC().Format(time.DateOnly)
+
+	quotaUsages.Lock()
+
+	e, ok := quotaUsages.accounts[accountID]
+
+	quotaUsages.Unlock()
+
+	if !ok || e.usage.Day != day ||
+		now.Sub(e.loaded) >= s.cfg.UsageInterval() {
+		u, err := s.loadQuotaUsage(ctx, accountID, now)
+		if err != nil {
+			return nil, err
+		}
+
+		e = &quotaEntry{usage: *u, loaded: now}
+
+		quotaUsages.Lock()
+
+		quotaUsages.accounts[accountID] = e
+
+		quotaUsages.Unlock()
+	}
+
+	res := e.usage
+
+	addPendingUsage(accountID, &res)
+
+	return &res, nil
+}
+
+// CheckQuota returns an error if the account of the context has used any of
+// its daily or monthly quotas. Usage recorded by other service instances is
+// retrieved once each usage interval, so quotas may be exceeded by the requests
+// made in that time. Requests by the system account are not limited.
+func (s *Service) CheckQuota(ctx context.Context) error {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil || accountID == "" || accountID == request.SystemAccount {
+		return nil
+	}
+
+	now := time.Now()
+
+	u, err := s.cachedQuotaUsage(ctx, accountID, now)
+	if err != nil {
+		s.log.Log(ctx, logger.LvlWarn,
+			"unable to retrieve quota usage",
+			"error", err,
+			"account_id", accountID)
+
+		return nil
+	}
+
+	utc := now.UTC()
+
+	nextDay := time.Date(utc.Year(), utc.Month(), utc.Day()+1, 0, 0, 0, 0,
+		time.UTC)
+
+	nextMonth := time.Date(utc.Year(), utc.Month()+1, 1, 0, 0, 0, 0,
+		time.UTC)
+
+	for _, c := range []struct {
+		name      string
+		quota     int64
+		used      int64
+		resetTime time.Time
+	}{
+		{"monthly_requests", u.MonthlyRequests, u.MonthRequests, nextMonth},
+		{"monthly_ingest_bytes", u.MonthlyIngestBytes, u.MonthIngestBytes,
+			nextMonth},
+		{"daily_requests", u.DailyRequests, u.DayRequests, nextDay},
+		{"daily_ingest_bytes", u.DailyIngestBytes, u.DayIngestBytes,
+			nextDay},
+	} {
+		if c.quota <= 0 || c.used < c.quota {
+			continue
+		}
+
+		if s.metric != nil {
+			s.metric.Increment(ctx, "quota_exceeded", "quota:"+c.name)
+		}
+
+		return errors.New(errors.ErrorRateLimit,
+			"account quota exceeded: "+c.name,
+			"account_id", accountID,
+			"quota", c.quota,
+			"used", c.used,
+			"retry_after",
+			int64(math.Ceil(c.resetTime.Sub(now).Seconds())))
+	}
+
+	return nil
+}
+
+// storageBytes retrieves the size of the resource data stored by the account
+// of the context.
+func (s *Service) storageBytes(ctx context.Context) (int64, error) {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+			COALESCE(SUM(pg_column_size(resource_data.data)), 0)::BIGINT
+		FROM resource_data`,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	res := int64(0)
+
+	if err := row.Scan(&res); err != nil {
+		return 0, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select storage size")
+	}
+
+	return res, nil
+}
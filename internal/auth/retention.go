@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// Retention retrieves the data retention period of the account, stored in the
+// account data under the retention key as a number of seconds. Data older than
+// the retention period is purged, and a period of zero retains data
+// indefinitely. If the retention period is missing or invalid, the default
+// period is returned.
+func (a *Account) Retention(def time.Duration) time.Duration {
+	if a == nil || !a.Data.Valid {
+		return def
+	}
+
+	v, ok := a.Data.Value["retention"]
+	if !ok || v == nil {
+		return def
+	}
+
+	secs, ok := retentionSeconds(v)
+	if !ok || secs < 0 {
+		return def
+	}
+
+	return time.Duration(secs) * time.Second
+}
+
+// validateRetention checks that any data retention period in the account data
+// is a non-negative number of seconds.
+func (a *Account) validateRetention() error {
+	if !a.Data.Set || !a.Data.Valid {
+		return nil
+	}
+
+	v, ok := a.Data.Value["retention"]
+	if !ok || v == nil {
+		return nil
+	}
+
+	if secs, ok := retentionSeconds(v); !ok || secs < 0 {
+		return errors.New(errors.ErrInvalidRequest,
+			"invalid retention",
+			"account", a)
+	}
+
+	return nil
+}
+
+// retentionSeconds converts a retention period from the account data into a
+// whole number of seconds.
+func retentionSeconds(v any) (int64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		n, err := v.Int64()
+
+		return n, err == nil
+	case float64:
+		return int64(v), v == float64(int64(v))
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+// sessionRefreshMargin is the time before the access token of a session
+// expires at which it is replaced.
+const sessionRefreshMargin = time.Minute
+
+// Session values contain a browser session. The session ID is only known to
+// the client, which holds it in a cookie, and is stored as a hash. Requests
+// made using the session are authenticated with its access token, which is
+// kept on the server and replaced before it expires, until the session itself
+// expires.
+type Session struct {
+	SessionID string `json:"-"`
+	UserID    string `json:"user_id"`
+	Tenant    string `json:"-"`
+	Token     string `json:"-"`
+	CSRFToken string `json:"csrf_token"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// sessionSecret returns a new random value for a session ID or CSRF token.
+func sessionSecret() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, errors.ErrServer,
+			"unable to create session secret")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// sessionHash returns the hash of a session ID, as stored in the database.
+func sessionHash(id string) string {
+	h := sha256.Sum256([]byte(id))
+
+	return hex.EncodeToString(h[:])
+}
+
+// CreateSession creates a session for a user, which should already have been
+// authenticated. The session is valid for the refresh token expiration time,
+// and its access token is created with the requested scopes, as for
+// CreateToken.
+func (s *Service) CreateSession(ctx context.Context,
+	userID, scopes, tenant string,
+) (*Session, error) {
+	accountID, err := s.TokenAccountID(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	expiresAt := now.Add(s.cfg.AuthTokenRefreshExpiresIn()).Unix()
+
+	tokenExpiresAt := min(now.Add(s.cfg.AuthTokenExpiresIn()).Unix(),
+		expiresAt)
+
+	tok, err := s.CreateToken(ctx, userID, tokenExpiresAt, scopes, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := sessionSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	csrf, err := sessionSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = request.WithAccountID(ctx, accountID)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryInsert,
+		Base: `INSERT INTO session (session_id, user_id, scopes, tenant,
+				token, token_expires_at, csrf_token, expires_at)
+			VALUES ($1, $2, $3, $4, $5, TO_TIMESTAMP($6), $7,
+				TO_TIMESTAMP($8))`,
+		Params: []any{sessionHash(id), userID, scopes, tenant, tok,
+			tokenExpiresAt, csrf, expiresAt},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to insert session row",
+			"user_id", userID)
+	}
+
+	return &Session{
+		SessionID: id,
+		UserID:    userID,
+		Tenant:    tenant,
+		Token:     tok,
+		CSRFToken: csrf,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// AuthSession retrieves an unexpired session, for authentication of a request
+// using its access token. If the access token is about to expire, it is
+// replaced, with the scopes the user currently holds.
+func (s *Service) AuthSession(ctx context.Context,
+	id string,
+) (*Session, error) {
+	if id == "" {
+		return nil, errors.New(errors.ErrUnauthorized, "invalid session")
+	}
+
+	sCtx := request.WithAccountID(ctx, request.SystemAccount)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+			session.account_id,
+			session.user_id,
+			session.scopes,
+			session.tenant,
+			session.token,
+			EXTRACT(epoch FROM session.token_expires_at)::BIGINT,
+			session.csrf_token,
+			EXTRACT(epoch FROM session.expires_at)::BIGINT
+		FROM session
+		WHERE session.session_id = $1
+			AND session.expires_at > CURRENT_TIMESTAMP
+		LIMIT 1`,
+		Params: []any{sessionHash(id)},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(sCtx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	res, accountID, scopes, tokenExpiresAt := &Session{SessionID: id},
+		"", "", int64(0)
+
+	if err := row.Scan(&accountID, &res.UserID, &scopes, &res.Tenant,
+		&res.Token, &tokenExpiresAt, &res.CSRFToken,
+		&res.ExpiresAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrUnauthorized,
+				"invalid session")
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select session row")
+	}
+
+	now := time.Now()
+
+	if now.Add(sessionRefreshMargin).Unix() < tokenExpiresAt ||
+		now.Unix() >= res.ExpiresAt {
+		return res, nil
+	}
+
+	tokenExpiresAt = min(now.Add(s.cfg.AuthTokenExpiresIn()).Unix(),
+		res.ExpiresAt)
+
+	tok, err := s.CreateToken(ctx, res.UserID, tokenExpiresAt, scopes,
+		res.Tenant)
+	if err != nil {
+		s.log.Log(ctx, logger.LvlDebug,
+			"unable to refresh session token",
+			"error", err,
+			"account_id", accountID,
+			"user_id", res.UserID)
+
+		return nil, errors.New(errors.ErrUnauthorized, "invalid session")
+	}
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryUpdate,
+		Base: `UPDATE session SET
+				token = $2,
+				token_expires_at = TO_TIMESTAMP($3)
+			WHERE session.session_id = $1`,
+		Params: []any{sessionHash(id), tok, tokenExpiresAt},
+	})
+
+	if _, err := q.Exec(request.WithAccountID(ctx, accountID)); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update session row",
+			"user_id", res.UserID)
+	}
+
+	res.Token = tok
+
+	return res, nil
+}
+
+// DeleteSession deletes a session of the account, so that it can no longer be
+// used to authenticate requests.
+func (s *Service) DeleteSession(ctx context.Context, id string) error {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryDelete,
+		Base:   `DELETE FROM session WHERE session.session_id = $1`,
+		Params: []any{sessionHash(id)},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to delete session row")
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestCreateSession(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+) FROM "user"`).
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockUserRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAccountSecretRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("INSERT INTO token").
+		WithArgs(pgxmock.AnyArg(), TestName, pgxmock.AnyArg(), true,
+			pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("INSERT INTO session").
+		WithArgs(pgxmock.AnyArg(), TestName, "superuser", "",
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	res, err := svc.CreateSession(ctx, TestName, "superuser", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.SessionID == "" || res.CSRFToken == "" ||
+		res.SessionID == res.CSRFToken {
+		t.Errorf("Expected session ID and CSRF token, got: %+v", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestAuthSession(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	expiresAt := time.Now().Add(time.Hour).Unix()
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM session").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{
+			"account_id",
+			"user_id",
+			"scopes",
+			"tenant",
+			"token",
+			"token_expires_at",
+			"csrf_token",
+			"expires_at",
+		}).AddRow(TestID, TestName, "", "", "token", expiresAt, "csrf",
+			expiresAt))
+
+	res, err := svc.AuthSession(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Token != "token" || res.CSRFToken != "csrf" {
+		t.Errorf("Expected session token and CSRF token, got: %+v", res)
+	}
+
+	if _, err := svc.AuthSession(ctx, ""); !errors.Has(err,
+		errors.ErrUnauthorized) {
+		t.Errorf("Expected unauthorized error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
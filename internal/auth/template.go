@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/repo"
+	"github.com/dhaifley/apigo/internal/request"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateSettingsPath is the path, within the template repository, of the
+// file containing the settings copied into the data of new accounts.
+const TemplateSettingsPath = "account.yaml"
+
+// applyTemplate copies the settings of the configured template repository
+// into the data of an account being created. Values given in the data of the
+// account take precedence over the settings, and accounts which already exist
+// are left unchanged. Failures to read the template are logged, rather than
+// preventing the account from being created.
+func (s *Service) applyTemplate(ctx context.Context, v *Account) error {
+	repoURL := s.cfg.AccountTemplateRepo()
+	if repoURL == "" {
+		return nil
+	}
+
+	if _, err := s.getAccount(ctx, v.AccountID.Value); err == nil {
+		return nil
+	} else if !errors.Has(err, errors.ErrNotFound) {
+		return err
+	}
+
+	m, err := s.templateSettings(ctx, repoURL)
+	if err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to get account template settings",
+			"error", err,
+			"repo", repoURL,
+			"account_id", v.AccountID.Value)
+
+		return nil
+	}
+
+	if len(m) == 0 {
+		return nil
+	}
+
+	if v.Data.Valid {
+		for k, val := range v.Data.Value {
+			m[k] = val
+		}
+	}
+
+	a := *v
+
+	a.Data = request.FieldJSON{
+		Set: true, Valid: true, Value: m,
+	}
+
+	if err := a.Validate(); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"invalid account template settings",
+			"error", err,
+			"repo", repoURL,
+			"account_id", v.AccountID.Value)
+
+		return nil
+	}
+
+	v.Data = a.Data
+
+	return nil
+}
+
+// templateSettings retrieves the settings of a template repository. A
+// template without a settings file has no settings.
+func (s *Service) templateSettings(ctx context.Context,
+	repoURL string,
+) (map[string]any, error) {
+	cli, err := repo.NewClient(repoURL, s.client, s.metric, s.tracer)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"unable to create template repository client")
+	}
+
+	buf, err := cli.Get(ctx, TemplateSettingsPath)
+	if err != nil {
+		if errors.Has(err, errors.ErrNotFound) {
+			return nil, nil
+		}
+
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"unable to get template settings file",
+			"path", TemplateSettingsPath)
+	}
+
+	m := map[string]any{}
+
+	if err := yaml.Unmarshal(buf, &m); err != nil {
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"unable to parse template settings file",
+			"path", TemplateSettingsPath)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// TimeZone retrieves the default time zone of the account, stored in the
+// account data under the time_zone key as an IANA time zone name. It is used
+// to interpret time inputs of requests which do not specify a time zone. If
+// the time zone is missing or invalid, nil is returned.
+func (a *Account) TimeZone() *time.Location {
+	if a == nil || !a.Data.Valid {
+		return nil
+	}
+
+	name, ok := a.Data.Value["time_zone"].(string)
+	if !ok || name == "" {
+		return nil
+	}
+
+	loc, err := request.ParseTimeZone(name)
+	if err != nil {
+		return nil
+	}
+
+	return loc
+}
+
+// validateTimeZone checks that any default time zone in the account data is
+// valid.
+func (a *Account) validateTimeZone() error {
+	if !a.Data.Set || !a.Data.Valid {
+		return nil
+	}
+
+	v, ok := a.Data.Value["time_zone"]
+	if !ok || v == nil {
+		return nil
+	}
+
+	name, ok := v.(string)
+	if !ok {
+		return errors.New(errors.ErrInvalidRequest,
+			"time_zone must be a string",
+			"account", a)
+	}
+
+	if _, err := request.ParseTimeZone(name); err != nil {
+		return errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid time_zone",
+			"account", a)
+	}
+
+	return nil
+}
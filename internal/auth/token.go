@@ -0,0 +1,377 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// IssuedToken values represent the tokens issued to the users of an account,
+// along with when and how often they have been used. Tokens signed by the
+// server token keys, or by an identity provider, are not recorded.
+type IssuedToken struct {
+	TokenID    request.FieldString `json:"token_id"`
+	UserID     request.FieldString `json:"user_id"`
+	Scopes     request.FieldString `json:"scopes"`
+	Restricted request.FieldBool   `json:"restricted"`
+	ExpiresAt  request.FieldTime   `json:"expires_at"`
+	LastUsedAt request.FieldTime   `json:"last_used_at"`
+	UseCount   request.FieldInt64  `json:"use_count"`
+	CreatedAt  request.FieldTime   `json:"created_at"`
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (t *IssuedToken) ScanDest(options sqldb.FieldOptions) []any {
+	return sqldb.ScanFields("token", tokenFields, options,
+		map[string]any{
+			"token_id":     &t.TokenID,
+			"user_id":      &t.UserID,
+			"scopes":       &t.Scopes,
+			"restricted":   &t.Restricted,
+			"expires_at":   &t.ExpiresAt,
+			"last_used_at": &t.LastUsedAt,
+			"use_count":    &t.UseCount,
+			"created_at":   &t.CreatedAt,
+		})
+}
+
+// tokenFields contain the search fields for issued tokens.
+var tokenFields = []*sqldb.Field{{
+	Name:   "token_key",
+	Type:   sqldb.FieldInt,
+	Table:  "token",
+	Hidden: true,
+}, {
+	Name:  "token_id",
+	Type:  sqldb.FieldString,
+	Table: "token",
+}, {
+	Name:    "user_id",
+	Type:    sqldb.FieldString,
+	Table:   "token",
+	Primary: true,
+}, {
+	Name:  "scopes",
+	Type:  sqldb.FieldString,
+	Table: "token",
+}, {
+	Name:  "restricted",
+	Type:  sqldb.FieldBool,
+	Table: "token",
+}, {
+	Name:  "expires_at",
+	Type:  sqldb.FieldTime,
+	Table: "token",
+}, {
+	Name:  "last_used_at",
+	Type:  sqldb.FieldTime,
+	Table: "token",
+}, {
+	Name:  "use_count",
+	Type:  sqldb.FieldInt,
+	Table: "token",
+}, {
+	Name:  "created_at",
+	Type:  sqldb.FieldTime,
+	Table: "token",
+}}
+
+// GetTokens retrieves issued tokens based on a search query. Users holding the
+// user:admin scope retrieve the tokens of every user of the account, and other
+// users only their own. Uses of the tokens not yet recorded in the database
+// are included in the results.
+func (s *Service) GetTokens(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*IssuedToken, error) {
+	if err := options.ValidateFields(tokenFields); err != nil {
+		return nil, err
+	}
+
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.NoSummary()
+
+	base := sqldb.SelectFields("token", tokenFields, query, options)
+
+	params := []any{}
+
+	if !request.ContextHasScope(ctx, request.ScopeUserAdmin) {
+		userID, err := request.ContextUserID(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		base += `WHERE token.user_id = $1`
+
+		params = append(params, userID)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Search: query,
+		Fields: tokenFields,
+		Params: params,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res := []*IssuedToken{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		t := &IssuedToken{}
+
+		if err := rows.Scan(t.ScanDest(options)...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select token row",
+				"search", query)
+		}
+
+		pendingTokenUse(accountID, t)
+
+		res = append(res, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select token rows",
+			"search", query)
+	}
+
+	return res, nil
+}
+
+// createIssuedToken records a token issued by an account.
+func (s *Service) createIssuedToken(ctx context.Context,
+	accountID string,
+	v *IssuedToken,
+) error {
+	ctx = request.WithAccountID(ctx, accountID)
+
+	sets, params := []string{}, []any{}
+
+	request.SetField("token_id", v.TokenID, &sets, &params)
+	request.SetField("user_id", v.UserID, &sets, &params)
+	request.SetField("scopes", v.Scopes, &sets, &params)
+	request.SetField("restricted", v.Restricted, &sets, &params)
+	request.SetField("expires_at", v.ExpiresAt, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryInsert,
+		Base:   `INSERT INTO token () VALUES ()`,
+		Fields: tokenFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to insert token row",
+			"token", v)
+	}
+
+	return nil
+}
+
+// tokenUse values contain the uses of a token not yet recorded in the
+// database.
+type tokenUse struct {
+	uses     int64
+	lastUsed time.Time
+}
+
+// tokenUses contains the uses of tokens not yet recorded in the database, by
+// account and token ID. Uses are counted in memory and written periodically,
+// so that frequently used tokens do not cause a write to the same row for
+// every request.
+var tokenUses = struct {
+	sync.Mutex
+	accounts map[string]map[string]*tokenUse
+}{accounts: map[string]map[string]*tokenUse{}}
+
+// addTokenUses counts uses of a token issued by an account.
+func addTokenUses(accountID, tokenID string, n int64, ts time.Time) {
+	tokenUses.Lock()
+	defer tokenUses.Unlock()
+
+	uses, ok := tokenUses.accounts[accountID]
+	if !ok {
+		uses = map[string]*tokenUse{}
+
+		tokenUses.accounts[accountID] = uses
+	}
+
+	u, ok := uses[tokenID]
+	if !ok {
+		u = &tokenUse{}
+
+		uses[tokenID] = u
+	}
+
+	u.uses += n
+
+	if ts.After(u.lastUsed) {
+		u.lastUsed = ts
+	}
+}
+
+// pendingTokenUse adds the uses of a token not yet recorded in the database
+// to the token.
+func pendingTokenUse(accountID string, t *IssuedToken) {
+	if !t.TokenID.Valid {
+		return
+	}
+
+	tokenUses.Lock()
+
+	u, ok := tokenUses.accounts[accountID][t.TokenID.Value]
+	if ok {
+		u = &tokenUse{uses: u.uses, lastUsed: u.lastUsed}
+	}
+
+	tokenUses.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if t.UseCount.Set {
+		t.UseCount.Valid = true
+		t.UseCount.Value += u.uses
+	}
+
+	if t.LastUsedAt.Set && u.lastUsed.Unix() > t.LastUsedAt.Value {
+		t.LastUsedAt.Valid = true
+		t.LastUsedAt.Value = u.lastUsed.Unix()
+	}
+}
+
+// UpdateTokenUsage periodically records the uses of tokens in the database.
+// The uses counted since the last update are recorded when the returned
+// function is called.
+func (s *Service) UpdateTokenUsage(ctx context.Context) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+
+	ctx = request.WithNewTraceID(ctx)
+
+	done := make(chan struct{})
+
+	go func(ctx context.Context) {
+		defer close(done)
+
+		tick := time.NewTimer(s.cfg.AuthTokenUsageInterval())
+
+		for {
+			select {
+			case <-ctx.Done():
+				tick.Stop()
+
+				s.flushTokenUsage(context.WithoutCancel(ctx))
+
+				return
+			case <-tick.C:
+				s.flushTokenUsage(ctx)
+			}
+
+			tick = time.NewTimer(s.cfg.AuthTokenUsageInterval())
+		}
+	}(ctx)
+
+	return func() {
+		cancel()
+
+		<-done
+	}
+}
+
+// flushTokenUsage records the uses of tokens counted since the last update in
+// the database, with a single statement for each account. Uses which can not
+// be recorded are retained for the next update.
+func (s *Service) flushTokenUsage(ctx context.Context) {
+	tokenUses.Lock()
+
+	accounts := tokenUses.accounts
+
+	tokenUses.accounts = map[string]map[string]*tokenUse{}
+
+	tokenUses.Unlock()
+
+	for accountID, uses := range accounts {
+		actx, cancel := request.ContextReplaceTimeout(ctx,
+			s.cfg.ServerTimeout())
+
+		if err := s.updateTokenUsage(actx, accountID, uses); err != nil {
+			s.log.Log(actx, logger.LvlError,
+				"unable to update token usage",
+				"error", err,
+				"account_id", accountID,
+				"tokens", len(uses))
+
+			for id, u := range uses {
+				addTokenUses(accountID, id, u.uses, u.lastUsed)
+			}
+		}
+
+		cancel()
+	}
+}
+
+// updateTokenUsage adds uses of tokens issued by an account to the database.
+func (s *Service) updateTokenUsage(ctx context.Context,
+	accountID string,
+	uses map[string]*tokenUse,
+) error {
+	ctx = request.WithAccountID(ctx, accountID)
+
+	ids, counts, lastUsed := []string{}, []int64{}, []int64{}
+
+	for id, u := range uses {
+		ids = append(ids, id)
+		counts = append(counts, u.uses)
+		lastUsed = append(lastUsed, u.lastUsed.Unix())
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryUpdate,
+		Base: `UPDATE token SET
+				use_count = token.use_count + u.uses,
+				last_used_at = GREATEST(token.last_used_at,
+					TO_TIMESTAMP(u.last_used))
+			FROM UNNEST($1::UUID[], $2::BIGINT[], $3::BIGINT[])
+				AS u(token_id, uses, last_used)
+			WHERE token.token_id = u.token_id`,
+		Params: []any{ids, counts, lastUsed},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to update token rows")
+	}
+
+	return nil
+}
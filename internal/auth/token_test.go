@@ -0,0 +1,175 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+const TestTokenID = "99887766-5544-3322-1100-ffeeddccbbaa"
+
+func mockTokenRows(mock pgxmock.PgxCommonIface,
+	id string,
+	useCount int64,
+) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"token_id",
+		"user_id",
+		"scopes",
+		"restricted",
+		"expires_at",
+		"last_used_at",
+		"use_count",
+		"created_at",
+	}).AddRow(
+		id,
+		TestUser.UserID.Value,
+		request.ScopeSuperuser,
+		false,
+		time.Now().AddDate(1, 0, 0).Unix(),
+		time.Now().Add(-time.Hour).Unix(),
+		useCount,
+		time.Now().AddDate(0, -1, 0).Unix(),
+	)
+}
+
+func TestGetTokens(t *testing.T) {
+	t.Parallel()
+
+	ctx := request.WithScopes(mockAuthContext(), request.ScopeUserRead)
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+) FROM token (.+) token.user_id = \$1`).
+		WithArgs(TestUUID).WillReturnRows(mockTokenRows(mock, TestUUID, 3))
+
+	res, err := svc.GetTokens(ctx, &search.Query{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || res[0].TokenID.Value != TestUUID {
+		t.Fatalf("Expected token: %v, got: %v", TestUUID, res)
+	}
+
+	if res[0].UseCount.Value != 3 {
+		t.Errorf("Expected use_count: 3, got: %v", res[0].UseCount.Value)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestTokenUsage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cfg := config.NewDefault()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	now := time.Now()
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
+		"exp":    now.Add(cfg.AuthTokenExpiresIn()).Unix(),
+		"iat":    now.Unix(),
+		"nbf":    now.Unix(),
+		"iss":    cfg.AuthTokenIssuer(),
+		"sub":    TestUser.UserID.Value,
+		"aud":    []string{cfg.ServiceName()},
+		"jti":    TestTokenID,
+		"scopes": request.ScopeSuperuser,
+	})
+
+	tok.Header = map[string]any{
+		"alg": "HS512",
+		"kid": TestID,
+	}
+
+	authToken, err := tok.SignedString([]byte(TestAccount.Secret.Value))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID, "").
+		WillReturnRows(mockAccountSecretRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	if _, err := svc.AuthJWT(ctx, authToken, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM token").
+		WillReturnRows(mockTokenRows(mock, TestTokenID, 3))
+
+	res, err := svc.GetTokens(mockAuthContext(), &search.Query{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || res[0].UseCount.Value != 4 {
+		t.Errorf("Expected use_count with pending uses: 4, got: %v", res)
+	}
+
+	if len(res) == 1 && res[0].LastUsedAt.Value < now.Unix() {
+		t.Errorf("Expected last_used_at of pending use, got: %v",
+			res[0].LastUsedAt.Value)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("UPDATE token SET").
+		WithArgs([]string{TestTokenID}, []int64{1}, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	svc.UpdateTokenUsage(ctx)()
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM token").
+		WillReturnRows(mockTokenRows(mock, TestTokenID, 4))
+
+	if res, err = svc.GetTokens(mockAuthContext(), &search.Query{},
+		nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || res[0].UseCount.Value != 4 {
+		t.Errorf("Expected use_count after update: 4, got: %v", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,476 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// MaxIdempotencyKey is the maximum length of the idempotency key of a request.
+// Requests with longer keys are counted as if they had no key, and are
+// rejected by operations which replay the responses of retried requests.
+const MaxIdempotencyKey = 255
+
+// defaultUsageDays is the number of days for which request usage is retrieved
+// when no start date is specified.
+const defaultUsageDays = 30
+
+// RequestCount values contain the number of requests made by an account to an
+// API operation on a single day, and the request body bytes sent with them.
+type RequestCount struct {
+	Day         string `json:"day"`
+	Operation   string `json:"operation"`
+	Requests    int64  `json:"requests"`
+	IngestBytes int64  `json:"ingest_bytes"`
+}
+
+// RequestUsage values contain the requests made by an account over a range of
+// days, by day and operation, the size of the resource data stored by the
+// account, and the usage of the account counted against its quotas.
+type RequestUsage struct {
+	From         string          `json:"from"`
+	To           string          `json:"to"`
+	Requests     int64           `json:"requests"`
+	IngestBytes  int64           `json:"ingest_bytes"`
+	StorageBytes int64           `json:"storage_bytes"`
+	Counts       []*RequestCount `json:"counts"`
+	Quota        *QuotaUsage     `json:"quota,omitempty"`
+}
+
+// requestDay values identify the operation and UTC day of a request.
+type requestDay struct {
+	day       string
+	operation string
+}
+
+// requestTotal values contain the number of requests made to an operation on
+// a day, and the request body bytes sent with them.
+type requestTotal struct {
+	requests int64
+	bytes    int64
+}
+
+// requestUse values contain the requests made by an account not yet recorded
+// in the database. Requests made with an idempotency key are recorded by key,
+// so that retried requests are only counted once. The bytes sent with every
+// request are counted, including those of retried requests.
+type requestUse struct {
+	counts map[requestDay]requestTotal
+	keys   map[string]requestDay
+}
+
+// requestUses contains the requests not yet recorded in the database, by
+// account. Requests are counted in memory and written periodically, so that
+// accounting does not cause a write for every request.
+var requestUses = struct {
+	sync.Mutex
+	accounts map[string]*requestUse
+}{accounts: map[string]*requestUse{}}
+
+// addRequests counts requests made by an account. Requests with an
+// idempotency key already counted are ignored.
+func addRequests(accountID string, counts map[requestDay]requestTotal,
+	keys map[string]requestDay,
+) {
+	requestUses.Lock()
+	defer requestUses.Unlock()
+
+	u, ok := requestUses.accounts[accountID]
+	if !ok {
+		u = &requestUse{
+			counts: map[requestDay]requestTotal{},
+			keys:   map[string]requestDay{},
+		}
+
+		requestUses.accounts[accountID] = u
+	}
+
+	for d, n := range counts {
+		t := u.counts[d]
+
+		t.requests += n.requests
+		t.bytes += n.bytes
+
+		u.counts[d] = t
+	}
+
+	for k, d := range keys {
+		if _, ok := u.keys[k]; !ok {
+			u.keys[k] = d
+		}
+	}
+}
+
+// RecordRequest counts a request made by the account of the context to an API
+// operation, and the bytes of its body. Requests with the same idempotency key
+// are counted once, whether they are received by this or another service
+// instance. Requests are recorded in the database periodically, by
+// UpdateRequestUsage.
+func (s *Service) RecordRequest(ctx context.Context,
+	operation, key string,
+	bytes int64,
+) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil || accountID == "" {
+		return
+	}
+
+	d := requestDay{
+		day:       time.Now().UTC().Format(time.DateOnly),
+		operation: operation,
+	}
+
+	if key == "" || len(key) > MaxIdempotencyKey {
+		addRequests(accountID, map[requestDay]requestTotal{
+			d: {requests: 1, bytes: bytes},
+		}, nil)
+
+		return
+	}
+
+	var counts map[requestDay]requestTotal
+
+	if bytes > 0 {
+		counts = map[requestDay]requestTotal{d: {bytes: bytes}}
+	}
+
+	addRequests(accountID, counts, map[string]requestDay{key: d})
+}
+
+// pendingRequests adds the requests made by an account between two days, not
+// yet recorded in the database, to the request counts.
+func pendingRequests(accountID, from, to string,
+	counts map[requestDay]requestTotal,
+) {
+	requestUses.Lock()
+	defer requestUses.Unlock()
+
+	u, ok := requestUses.accounts[accountID]
+	if !ok {
+		return
+	}
+
+	for d, n := range u.counts {
+		if d.day >= from && d.day <= to {
+			t := counts[d]
+
+			t.requests += n.requests
+			t.bytes += n.bytes
+
+			counts[d] = t
+		}
+	}
+
+	for _, d := range u.keys {
+		if d.day >= from && d.day <= to {
+			t := counts[d]
+
+			t.requests++
+
+			counts[d] = t
+		}
+	}
+}
+
+// GetRequestUsage retrieves the requests made by the account between two UTC
+// days, formatted as YYYY-MM-DD. If no end day is specified, requests are
+// retrieved up to the current day, and if no start day is specified, for the
+// 30 days before the end day. Requests not yet recorded in the database by
+// this service instance are included in the results, as are the current size
+// of the resource data of the account, and its usage of its quotas.
+func (s *Service) GetRequestUsage(ctx context.Context,
+	from, to string,
+) (*RequestUsage, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	from, to, err = ParseUsageRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+			TO_CHAR(request_count.day, 'YYYY-MM-DD'),
+			request_count.operation,
+			request_count.requests,
+			request_count.ingest_bytes
+		FROM request_count
+		WHERE request_count.day BETWEEN $1::DATE AND $2::DATE`,
+		Params: []any{from, to},
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"from", from,
+			"to", to)
+	}
+
+	defer rows.Close()
+
+	counts := map[requestDay]requestTotal{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		d, n := requestDay{}, requestTotal{}
+
+		if err := rows.Scan(&d.day, &d.operation, &n.requests,
+			&n.bytes); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select request count row",
+				"from", from,
+				"to", to)
+		}
+
+		t := counts[d]
+
+		t.requests += n.requests
+		t.bytes += n.bytes
+
+		counts[d] = t
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select request count rows",
+			"from", from,
+			"to", to)
+	}
+
+	// The connection is released before the rest of the usage is retrieved.
+	rows.Close()
+
+	pendingRequests(accountID, from, to, counts)
+
+	res := newRequestUsage(from, to, counts)
+
+	if res.StorageBytes, err = s.storageBytes(ctx); err != nil {
+		return nil, err
+	}
+
+	if res.Quota, err = s.GetQuotaUsage(ctx); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ParseUsageRange validates the days of a request usage range, and applies the
+// default days when they are not specified.
+func ParseUsageRange(from, to string) (string, string, error) {
+	end := time.Now().UTC()
+
+	if to != "" {
+		t, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			return "", "", errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid usage end day",
+				"to", to)
+		}
+
+		end = t
+	}
+
+	start := end.AddDate(0, 0, -defaultUsageDays)
+
+	if from != "" {
+		t, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			return "", "", errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid usage start day",
+				"from", from)
+		}
+
+		start = t
+	}
+
+	if start.After(end) {
+		return "", "", errors.New(errors.ErrInvalidRequest,
+			fmt.Sprintf("invalid usage range: %s is after %s",
+				start.Format(time.DateOnly), end.Format(time.DateOnly)),
+			"from", from,
+			"to", to)
+	}
+
+	return start.Format(time.DateOnly), end.Format(time.DateOnly), nil
+}
+
+// newRequestUsage creates request usage for a range of days from request
+// counts by operation and day, ordered by day and operation.
+func newRequestUsage(from, to string,
+	counts map[requestDay]requestTotal,
+) *RequestUsage {
+	res := &RequestUsage{From: from, To: to, Counts: []*RequestCount{}}
+
+	for d, n := range counts {
+		res.Counts = append(res.Counts, &RequestCount{
+			Day:         d.day,
+			Operation:   d.operation,
+			Requests:    n.requests,
+			IngestBytes: n.bytes,
+		})
+
+		res.Requests += n.requests
+		res.IngestBytes += n.bytes
+	}
+
+	sort.Slice(res.Counts, func(i, j int) bool {
+		if res.Counts[i].Day != res.Counts[j].Day {
+			return res.Counts[i].Day < res.Counts[j].Day
+		}
+
+		return res.Counts[i].Operation < res.Counts[j].Operation
+	})
+
+	return res
+}
+
+// UpdateRequestUsage periodically records the requests counted by
+// RecordRequest in the database. The requests counted since the last update
+// are recorded when the returned function is called.
+func (s *Service) UpdateRequestUsage(ctx context.Context) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+
+	ctx = request.WithNewTraceID(ctx)
+
+	done := make(chan struct{})
+
+	go func(ctx context.Context) {
+		defer close(done)
+
+		tick := time.NewTimer(s.cfg.UsageInterval())
+
+		for {
+			select {
+			case <-ctx.Done():
+				tick.Stop()
+
+				s.flushRequestUsage(context.WithoutCancel(ctx))
+
+				return
+			case <-tick.C:
+				s.flushRequestUsage(ctx)
+			}
+
+			tick = time.NewTimer(s.cfg.UsageInterval())
+		}
+	}(ctx)
+
+	return func() {
+		cancel()
+
+		<-done
+	}
+}
+
+// flushRequestUsage records the requests counted since the last update in the
+// database, with a single statement for each account. Requests which can not
+// be recorded are retained for the next update.
+func (s *Service) flushRequestUsage(ctx context.Context) {
+	requestUses.Lock()
+
+	accounts := requestUses.accounts
+
+	requestUses.accounts = map[string]*requestUse{}
+
+	requestUses.Unlock()
+
+	for accountID, u := range accounts {
+		actx, cancel := request.ContextReplaceTimeout(ctx,
+			s.cfg.ServerTimeout())
+
+		if err := s.updateRequestUsage(actx, accountID, u); err != nil {
+			s.log.Log(actx, logger.LvlError,
+				"unable to update request usage",
+				"error", err,
+				"account_id", accountID,
+				"counts", len(u.counts),
+				"keys", len(u.keys))
+
+			addRequests(accountID, u.counts, u.keys)
+		}
+
+		cancel()
+	}
+}
+
+// updateRequestUsage adds requests made by an account to the database.
+// Requests with an idempotency key are only counted if the key has not been
+// recorded before, and the counts and keys are recorded atomically, so that a
+// failed update can be retried without counting requests twice.
+func (s *Service) updateRequestUsage(ctx context.Context,
+	accountID string,
+	u *requestUse,
+) error {
+	ctx = request.WithAccountID(ctx, accountID)
+
+	days, ops, counts, bytes := []string{}, []string{}, []int64{}, []int64{}
+
+	for d, n := range u.counts {
+		days = append(days, d.day)
+		ops = append(ops, d.operation)
+		counts = append(counts, n.requests)
+		bytes = append(bytes, n.bytes)
+	}
+
+	keys, keyDays, keyOps := []string{}, []string{}, []string{}
+
+	for k, d := range u.keys {
+		keys = append(keys, k)
+		keyDays = append(keyDays, d.day)
+		keyOps = append(keyOps, d.operation)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryInsert,
+		Base: `WITH k AS (
+				INSERT INTO request_key (idempotency_key, day, operation)
+				SELECT * FROM UNNEST($4::TEXT[], $5::DATE[], $6::TEXT[])
+				ON CONFLICT DO NOTHING
+				RETURNING request_key.day, request_key.operation
+			), c AS (
+				SELECT u.day, u.operation, u.requests, u.ingest_bytes
+				FROM UNNEST($1::DATE[], $2::TEXT[], $3::BIGINT[],
+					$7::BIGINT[])
+					AS u(day, operation, requests, ingest_bytes)
+				UNION ALL
+				SELECT k.day, k.operation, 1, 0 FROM k
+			)
+			INSERT INTO request_count (day, operation, requests,
+				ingest_bytes)
+			SELECT c.day, c.operation, SUM(c.requests), SUM(c.ingest_bytes)
+			FROM c
+			GROUP BY c.day, c.operation
+			ON CONFLICT (account_id, day, operation) DO UPDATE SET
+				requests = request_count.requests + EXCLUDED.requests,
+				ingest_bytes = request_count.ingest_bytes +
+					EXCLUDED.ingest_bytes,
+				updated_at = CURRENT_TIMESTAMP`,
+		Params: []any{days, ops, counts, keys, keyDays, keyOps, bytes},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to update request count rows")
+	}
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestRequestUsage(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	const op = "GET /resources"
+
+	svc.RecordRequest(ctx, op, "", 10)
+	svc.RecordRequest(ctx, op, "", 10)
+	svc.RecordRequest(ctx, op, "retried", 0)
+	svc.RecordRequest(ctx, op, "retried", 0)
+
+	today := time.Now().UTC().Format(time.DateOnly)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM request_count").
+		WithArgs(pgxmock.AnyArg(), today).
+		WillReturnRows(mock.NewRows([]string{
+			"day",
+			"operation",
+			"requests",
+			"ingest_bytes",
+		}).AddRow(today, op, int64(5), int64(100)))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource_data").
+		WillReturnRows(mock.NewRows([]string{"size"}).AddRow(int64(2048)))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM request_count").
+		WithArgs(today[:7]+"-01", today).
+		WillReturnRows(mock.NewRows([]string{
+			"day_requests",
+			"day_ingest_bytes",
+			"month_requests",
+			"month_ingest_bytes",
+		}).AddRow(int64(5), int64(100), int64(5), int64(100)))
+
+	res, err := svc.GetRequestUsage(ctx, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.To != today || len(res.Counts) != 1 || res.Requests != 8 ||
+		res.IngestBytes != 120 {
+		t.Errorf("Expected requests with pending requests: 8, and bytes: "+
+			"120, got: %+v", res)
+	}
+
+	if res.StorageBytes != 2048 {
+		t.Errorf("Expected storage bytes: 2048, got: %v", res.StorageBytes)
+	}
+
+	if res.Quota == nil || res.Quota.DayRequests != 8 ||
+		res.Quota.MonthIngestBytes != 120 {
+		t.Errorf("Expected quota usage with pending requests: 8, got: %+v",
+			res.Quota)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("INSERT INTO request_count").
+		WithArgs([]string{today}, []string{op}, []int64{2},
+			[]string{"retried"}, []string{today}, []string{op},
+			[]int64{20}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	svc.UpdateRequestUsage(context.Background())()
+
+	if _, err := svc.GetRequestUsage(ctx, today, "2000-01-01"); err == nil {
+		t.Error("Expected error for invalid usage range")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestAccountQuota(t *testing.T) {
+	t.Parallel()
+
+	def := auth.UsageQuota{DailyRequests: 100, MonthlyRequests: 1000}
+
+	a := &auth.Account{Data: request.FieldJSON{
+		Set: true, Valid: true,
+		Value: map[string]any{
+			"quotas": map[string]any{
+				"daily_requests":       float64(0),
+				"monthly_ingest_bytes": float64(1 << 20),
+			},
+		},
+	}}
+
+	if err := a.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := auth.UsageQuota{MonthlyRequests: 1000, MonthlyIngestBytes: 1 << 20}
+
+	if q := a.Quota(def); q != exp {
+		t.Errorf("Expected quota: %+v, got: %+v", exp, q)
+	}
+
+	a.Data.Value["quotas"] = map[string]any{"daily_requests": float64(-1)}
+
+	if err := a.Validate(); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	a.Data.Value["quotas"] = map[string]any{"unknown": float64(1)}
+
+	if err := a.Validate(); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	cfg.SetService(&config.ServiceConfig{
+		Name:               config.DefaultServiceName,
+		QuotaDailyRequests: 5,
+	})
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(cfg, md, nil, nil, nil, nil)
+
+	ctx := request.WithAccountID(context.Background(), TestUUID)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAccountRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM request_count").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{
+			"day_requests",
+			"day_ingest_bytes",
+			"month_requests",
+			"month_ingest_bytes",
+		}).AddRow(int64(5), int64(0), int64(5), int64(0)))
+
+	err = svc.CheckQuota(ctx)
+	if !errors.Has(err, errors.ErrorRateLimit) {
+		t.Fatalf("Expected rate limit error, got: %v", err)
+	}
+
+	if e, ok := err.(*errors.Error); !ok || e.Data["retry_after"] == nil {
+		t.Errorf("Expected retry after in error data, got: %v", err)
+	}
+
+	if err := svc.CheckQuota(request.WithAccountID(ctx,
+		request.SystemAccount)); err != nil {
+		t.Errorf("Expected no quota for the system account, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestCreateAccountQuotas(t *testing.T) {
+	t.Parallel()
+
+	ctx := request.WithScopes(mockAuthContext(), request.ScopeAccountAdmin)
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(config.NewDefault(), md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID).WillReturnRows(mockAccountRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("INSERT INTO account").
+		WithArgs(TestID, TestName, pgxmock.AnyArg()).
+		WillReturnRows(mockAccountRows(mock))
+
+	v := auth.Account{
+		Name: request.FieldString{Set: true, Valid: true, Value: TestName},
+		Data: request.FieldJSON{
+			Set: true, Valid: true,
+			Value: map[string]any{
+				"quotas": map[string]any{"daily_requests": float64(0)},
+			},
+		},
+	}
+
+	if _, err := svc.CreateAccount(ctx, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if q, ok := v.Data.Value["quotas"]; ok {
+		t.Errorf("Expected account admin quotas to be ignored, got: %v", q)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
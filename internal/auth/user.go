@@ -13,23 +13,23 @@ import (
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/sqldb"
 	"github.com/jackc/pgx/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // User values represent service users.
 type User struct {
-	UserID    request.FieldString `json:"user_id"`
-	Email     request.FieldString `json:"email"`
-	LastName  request.FieldString `json:"last_name"`
-	FirstName request.FieldString `json:"first_name"`
-	Status    request.FieldString `json:"status"`
-	Scopes    request.FieldString `json:"scopes"`
-	Data      request.FieldJSON   `json:"data"`
-	CreatedAt request.FieldTime   `json:"created_at"`
-	CreatedBy request.FieldString `json:"created_by"`
-	UpdatedAt request.FieldTime   `json:"updated_at"`
-	UpdatedBy request.FieldString `json:"updated_by"`
-	Password  *string             `json:"password,omitempty"`
+	UserID    request.FieldString `json:"user_id"            yaml:"user_id"`
+	Email     request.FieldString `json:"email"              yaml:"email"`
+	LastName  request.FieldString `json:"last_name"          yaml:"last_name"`
+	FirstName request.FieldString `json:"first_name"         yaml:"first_name"`
+	Status    request.FieldString `json:"status"             yaml:"status"`
+	Scopes    request.FieldString `json:"scopes"             yaml:"scopes"`
+	Data      request.FieldJSON   `json:"data"               yaml:"data"`
+	CreatedAt request.FieldTime   `json:"created_at"         yaml:"created_at"`
+	CreatedBy request.FieldString `json:"created_by"         yaml:"created_by"`
+	UpdatedAt request.FieldTime   `json:"updated_at"         yaml:"updated_at"`
+	UpdatedBy request.FieldString `json:"updated_by"         yaml:"updated_by"`
+	Grants    request.FieldInt64  `json:"grants"             yaml:"grants"`
+	Password  *string             `json:"password,omitempty" yaml:"password,omitempty"`
 }
 
 // Validate checks that the value contains valid data.
@@ -102,26 +102,20 @@ func (u *User) ValidateCreate() error {
 
 // ScanDest returns the destination fields for a SQL row scan.
 func (u *User) ScanDest(options sqldb.FieldOptions) []any {
-	dest := []any{
-		&u.UserID,
-		&u.Email,
-		&u.LastName,
-		&u.FirstName,
-		&u.Status,
-		&u.Scopes,
-		&u.Data,
-	}
-
-	if options != nil && options.Contains(sqldb.OptUserDetails) {
-		dest = append(dest,
-			&u.CreatedAt,
-			&u.CreatedBy,
-			&u.UpdatedAt,
-			&u.UpdatedBy,
-		)
-	}
-
-	return dest
+	return sqldb.ScanFields(`"user"`, userFields, options, map[string]any{
+		"user_id":    &u.UserID,
+		"email":      &u.Email,
+		"first_name": &u.FirstName,
+		"last_name":  &u.LastName,
+		"status":     &u.Status,
+		"scopes":     &u.Scopes,
+		"data":       &u.Data,
+		"created_at": &u.CreatedAt,
+		"created_by": &u.CreatedBy,
+		"updated_at": &u.UpdatedAt,
+		"updated_by": &u.UpdatedBy,
+		"grants":     &u.Grants,
+	})
 }
 
 // userFields contain the search fields for users.
@@ -184,6 +178,13 @@ var userFields = []*sqldb.Field{{
 	Type:   sqldb.FieldString,
 	Option: "user_details",
 	Table:  `"user"`,
+}, {
+	Name:   "grants",
+	Type:   sqldb.FieldInt,
+	Option: "grants",
+	Table:  `"user"`,
+	Expr: `CARDINALITY(ARRAY_REMOVE(
+		STRING_TO_ARRAY("user".scopes, ' '), ''))`,
 }}
 
 // GetUser retrieves a user from the database.
@@ -209,9 +210,36 @@ func (s *Service) GetUser(ctx context.Context,
 			"id", id)
 	}
 
+	if err := options.ValidateFields(userFields); err != nil {
+		return nil, err
+	}
+
+	key := cache.KeyUser(id)
+
+	for _, o := range options {
+		key += "::" + string(o)
+	}
+
+	// Users retrieved more than once during a request, with the same
+	// options, are only retrieved once.
+	return cache.Memoize(ctx, key,
+		func(ctx context.Context) (*User, error) {
+			return s.getUser(ctx, id, key, options)
+		})
+}
+
+// getUser retrieves a user from the cache, or the database. The key identifies
+// the user and the options used to retrieve it.
+func (s *Service) getUser(ctx context.Context,
+	id, key string,
+	options sqldb.FieldOptions,
+) (*User, error) {
 	var r *User
 
-	if s.cache != nil {
+	// Cached values do not contain any optional related objects.
+	useCache := s.cache != nil && len(options) == 0
+
+	if useCache {
 		ck := cache.KeyUser(id)
 
 		ci, err := s.cache.Get(ctx, ck)
@@ -235,66 +263,80 @@ func (s *Service) GetUser(ctx context.Context,
 		}
 	}
 
-	if r == nil {
-		base := sqldb.SelectFields(`"user"`, userFields, nil, options) +
-			`WHERE "user".user_id = $1`
+	if r != nil {
+		return r, nil
+	}
 
-		q := sqldb.NewQuery(&sqldb.QueryOptions{
-			DB:     s.db,
-			Type:   sqldb.QuerySelect,
-			Base:   base,
-			Fields: userFields,
-			Params: []any{id},
+	// Concurrent loads of the same uncached user are coalesced, so that the
+	// user is only read from the database, and cached, once.
+	return cache.Load(ctx, &s.loads, key,
+		func(ctx context.Context) (*User, error) {
+			return s.loadUser(ctx, id, options, useCache)
 		})
+}
 
-		q.Limit = 1
+// loadUser reads a user from the database, and caches it if requested.
+func (s *Service) loadUser(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+	useCache bool,
+) (*User, error) {
+	base := sqldb.SelectFields(`"user"`, userFields, nil, options) +
+		`WHERE "user".user_id = $1`
 
-		row, err := q.QueryRow(ctx)
-		if err != nil {
-			return nil, errors.Wrap(err, errors.ErrDatabase, "",
-				"id", id)
-		}
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: userFields,
+		Params: []any{id},
+	})
 
-		r = &User{}
+	q.Limit = 1
 
-		if err := row.Scan(r.ScanDest(options)...); err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New(errors.ErrNotFound,
-					"user not found",
-					"id", id)
-			}
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id)
+	}
 
-			return nil, errors.Wrap(err, errors.ErrDatabase,
-				"unable to select user row",
+	r := &User{}
+
+	if err := row.Scan(r.ScanDest(options)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"user not found",
 				"id", id)
 		}
 
-		if s.cache != nil {
-			ck := cache.KeyUser(r.UserID.Value)
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select user row",
+			"id", id)
+	}
+
+	if useCache {
+		ck := cache.KeyUser(r.UserID.Value)
 
-			buf, err := json.Marshal(r)
-			if err != nil {
-				s.log.Log(ctx, logger.LvlError,
-					"unable to encode user cache value",
-					"error", err,
-					"cache_key", ck,
-					"cache_value", r,
-					"id", id)
-			} else if len(buf) < s.cfg.CacheMaxBytes() {
-				if err := s.cache.Set(ctx, &cache.Item{
-					Key:        ck,
-					Value:      buf,
-					Expiration: s.cfg.CacheExpiration(),
-				}); err != nil {
-					s.log.Log(ctx, logger.LvlError,
-						"unable to set user cache value",
-						"error", err,
-						"cache_key", ck,
-						"cache_value", string(buf),
-						"expiration", s.cfg.CacheExpiration(),
-						"id", id)
-				}
-			}
+		buf, err := json.Marshal(r)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to encode user cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", r,
+				"id", id)
+		} else if err := s.cache.Set(ctx, &cache.Item{
+			Key:        ck,
+			Value:      buf,
+			Expiration: s.cfg.CacheExpiration(),
+		}); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to set user cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", string(buf),
+				"expiration", s.cfg.CacheExpiration(),
+				"id", id)
 		}
 	}
 
@@ -367,6 +409,8 @@ func (s *Service) CreateUser(ctx context.Context,
 			"user", v)
 	}
 
+	cache.Forget(ctx, cache.KeyUser(r.UserID.Value))
+
 	if s.cache != nil {
 		ck := cache.KeyUser(r.UserID.Value)
 
@@ -469,6 +513,8 @@ func (s *Service) UpdateUser(ctx context.Context,
 			"user", v)
 	}
 
+	cache.Forget(ctx, cache.KeyUser(r.UserID.Value))
+
 	if s.cache != nil {
 		ck := cache.KeyUser(r.UserID.Value)
 
@@ -489,6 +535,8 @@ func (s *Service) UpdateUser(ctx context.Context,
 func (s *Service) DeleteUser(ctx context.Context,
 	id string,
 ) error {
+	defer cache.Forget(ctx, cache.KeyUser(id))
+
 	if s.cache != nil {
 		defer func(ck string) {
 			if err := s.cache.Delete(ctx, ck); err != nil &&
@@ -527,20 +575,3 @@ func (s *Service) DeleteUser(ctx context.Context,
 
 	return nil
 }
-
-// hashPassword creates a hashed password.
-func hashPassword(password string) (string, error) {
-	hp, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", errors.Wrap(err, errors.ErrServer,
-			"unable to hash password")
-	}
-
-	return string(hp), nil
-}
-
-// verifyPassword verifies if a password matches a hashed password.
-func verifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword),
-		[]byte(password))
-}
@@ -127,6 +127,76 @@ func TestGetUser(t *testing.T) {
 	}
 }
 
+func TestGetUserGrants(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := auth.NewService(nil, md, mc, nil, nil, nil)
+
+	opts, err := sqldb.ParseFieldOptions(url.Values{
+		"include": []string{"grants"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(`SELECT (.+)CARDINALITY\(ARRAY_REMOVE\(\s+` +
+		`STRING_TO_ARRAY\("user".scopes, ' '\), ''\)\) AS grants\s+` +
+		`FROM "user"`).
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{
+			"user_id",
+			"email",
+			"last_name",
+			"first_name",
+			"status",
+			"scopes",
+			"data",
+			"grants",
+		}).AddRow(
+			TestUser.UserID.Value,
+			TestUser.Email.Value,
+			TestUser.LastName.Value,
+			TestUser.FirstName.Value,
+			TestUser.Status.Value,
+			TestUser.Scopes.Value,
+			TestUser.Data.Value,
+			int64(1),
+		))
+
+	res, err := svc.GetUser(ctx, "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Grants.Valid || res.Grants.Value != 1 {
+		t.Errorf("Expected grants: 1, got: %v", res.Grants)
+	}
+
+	if mc.WasMissed() || mc.WasSet() {
+		t.Error("expected cache not used")
+	}
+
+	if _, err := svc.GetUser(ctx, "",
+		sqldb.FieldOptions{sqldb.OptTags}); err == nil {
+		t.Error("Expected error for invalid include but got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
 func TestCreateUser(t *testing.T) {
 	t.Parallel()
 
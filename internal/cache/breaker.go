@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/google/gomemcache/memcache"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxPendingDeletes is the maximum number of keys deleted while the cache is
+// degraded which are retained, to be deleted from the cache servers once they
+// are available again.
+const maxPendingDeletes = 10000
+
+// breaker values implement a circuit breaker for the cache servers. After a
+// threshold of consecutive failed operations, the breaker opens and the cache
+// is degraded: operations skip the cache servers until the timeout has
+// elapsed. The breaker is then half-open, and a single trial operation is
+// allowed. If it succeeds the breaker closes, otherwise it opens again.
+type breaker struct {
+	sync.Mutex
+	threshold int
+	timeout   time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+	pending   map[string]struct{}
+}
+
+// newBreaker creates a new cache circuit breaker. If the threshold is not
+// positive, the breaker never opens.
+func newBreaker(threshold int, timeout time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		timeout:   timeout,
+		pending:   map[string]struct{}{},
+	}
+}
+
+// degraded determines whether the breaker is open or half-open, and returns
+// the time until which operations are skipped.
+func (b *breaker) degraded() (bool, time.Time) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return false, time.Time{}
+	}
+
+	return true, b.openUntil
+}
+
+// allow determines whether an operation on the cache servers is allowed. When
+// the breaker is half-open, only the first operation is allowed, until its
+// result is recorded.
+func (b *breaker) allow() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+
+	b.trial = true
+
+	return true
+}
+
+// record records the result of an operation on the cache servers. It returns
+// whether the result opened or closed the breaker.
+func (b *breaker) record(success bool) (opened, closed bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.trial = false
+
+	if b.threshold <= 0 {
+		return false, false
+	}
+
+	if success {
+		closed = b.failures >= b.threshold
+
+		b.failures = 0
+
+		return false, closed
+	}
+
+	b.failures++
+
+	if b.failures >= b.threshold {
+		opened = b.failures == b.threshold
+
+		b.openUntil = time.Now().Add(b.timeout)
+	}
+
+	return opened, false
+}
+
+// addPending retains a key deleted while the cache is degraded. It returns
+// false if too many keys are already retained.
+func (b *breaker) addPending(key string) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if _, ok := b.pending[key]; !ok && len(b.pending) >= maxPendingDeletes {
+		return false
+	}
+
+	b.pending[key] = struct{}{}
+
+	return true
+}
+
+// takePending removes and returns the keys deleted while the cache was
+// degraded.
+func (b *breaker) takePending() []string {
+	b.Lock()
+	defer b.Unlock()
+
+	res := make([]string, 0, len(b.pending))
+
+	for key := range b.pending {
+		res = append(res, key)
+	}
+
+	b.pending = map[string]struct{}{}
+
+	return res
+}
+
+// available determines whether the result of an operation indicates that the
+// cache servers are reachable. Missing keys, and errors caused by the item or
+// by the caller canceling the operation, do not count as failures.
+func available(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, memcache.ErrCacheMiss),
+		errors.Is(err, memcache.ErrNotStored),
+		errors.Is(err, memcache.ErrCASConflict),
+		errors.Is(err, memcache.ErrMalformedKey),
+		errors.Is(err, redis.Nil),
+		errors.Is(err, context.Canceled):
+		return true
+	}
+
+	return false
+}
+
+// Degraded determines whether the cache is degraded, because the cache servers
+// are unreachable, and returns the time until which operations skip the cache
+// servers, after which they are tried again.
+func (c *Client) Degraded() (bool, time.Time) {
+	c.RLock()
+
+	b := c.breaker
+
+	c.RUnlock()
+
+	if b == nil {
+		return false, time.Time{}
+	}
+
+	return b.degraded()
+}
+
+// allow determines whether an operation may use the cache servers. Operations
+// which are not allowed, because the cache is degraded, are counted.
+func (c *Client) allow(ctx context.Context, operation string) bool {
+	c.RLock()
+
+	b, mr := c.breaker, c.metric
+
+	c.RUnlock()
+
+	if b == nil || b.allow() {
+		return true
+	}
+
+	if mr != nil {
+		mr.Increment(ctx, "cache_degraded_skips", "operation:"+operation)
+	}
+
+	return false
+}
+
+// record records the result of an operation on the cache servers. When the
+// cache becomes degraded, or recovers, this is logged once, and when it
+// recovers the keys deleted while it was degraded are deleted from the cache
+// servers.
+func (c *Client) record(ctx context.Context, err error) {
+	c.RLock()
+
+	b, mr, log := c.breaker, c.metric, c.log
+
+	c.RUnlock()
+
+	if b == nil {
+		return
+	}
+
+	opened, closed := b.record(available(err))
+
+	if opened {
+		_, until := b.degraded()
+
+		if mr != nil {
+			mr.Increment(ctx, "cache_degraded")
+		}
+
+		log.Log(ctx, logger.LvlWarn,
+			"cache degraded, skipping cache servers",
+			"error", err,
+			"servers", c.servers,
+			"until", until)
+	}
+
+	if !closed {
+		return
+	}
+
+	keys := b.takePending()
+
+	log.Log(ctx, logger.LvlInfo,
+		"cache recovered",
+		"servers", c.servers,
+		"pending_deletes", len(keys))
+
+	for _, key := range keys {
+		if err := c.Delete(context.WithoutCancel(ctx), key); err != nil {
+			log.Log(ctx, logger.LvlError,
+				"unable to delete pending cache key",
+				"error", err,
+				"key", key)
+		}
+	}
+}
+
+// deletePending retains a key deleted while the cache is degraded, so that it
+// is deleted from the cache servers when they are available again, rather than
+// a stale value being retrieved.
+func (c *Client) deletePending(ctx context.Context, key string) {
+	c.RLock()
+
+	b, mr := c.breaker, c.metric
+
+	c.RUnlock()
+
+	if b == nil || b.addPending(key) {
+		return
+	}
+
+	if mr != nil {
+		mr.Increment(ctx, "cache_errors", "operation:delete_pending")
+	}
+}
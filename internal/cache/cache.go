@@ -13,6 +13,7 @@ import (
 	"github.com/dhaifley/apigo/internal/logger"
 	"github.com/dhaifley/apigo/internal/metric"
 	"github.com/google/gomemcache/memcache"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -48,23 +49,29 @@ type memcacheClient interface {
 	Delete(key string) error
 }
 
-// redisClient values are used to interact with redis clusters.
+// redisClient values are used to interact with redis servers and clusters.
 type redisClient interface {
-	Get(ctx context.Context, key string) *redis.StringCmd
-	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	Pipeline() redis.Pipeliner
 	Set(ctx context.Context, key string, value any,
 		expiration time.Duration) *redis.StatusCmd
 	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Publish(ctx context.Context, channel string, message any) *redis.IntCmd
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
 }
 
 // Client values are used for interacting with a group of cache servers.
 type Client struct {
 	sync.RWMutex
+	id        string
 	servers   []string
 	timeout   time.Duration
 	discovery bool
+	maxBytes  int
+	compress  int
 	mc        memcacheClient
 	rc        redisClient
+	local     *localCache
+	breaker   *breaker
 	log       logger.Logger
 	metric    metric.Recorder
 	tracer    trace.Tracer
@@ -92,12 +99,17 @@ func NewClient(cfg *config.Config,
 	}
 
 	c := &Client{
+		id:        uuid.NewString(),
 		servers:   cfg.CacheServers(),
 		timeout:   cfg.CacheTimeout(),
 		discovery: cfg.CacheDiscovery(),
+		maxBytes:  cfg.CacheMaxBytes(),
+		compress:  cfg.CacheCompressBytes(),
 		log:       log,
 		metric:    metric,
 		tracer:    tracer,
+		breaker: newBreaker(cfg.CacheBreakerThreshold(),
+			cfg.CacheBreakerTimeout()),
 	}
 
 	switch cfg.CacheType() {
@@ -106,21 +118,36 @@ func NewClient(cfg *config.Config,
 			return nil
 		}
 
-		opts := &redis.Options{
-			Addr:                  c.servers[0],
-			ContextTimeoutEnabled: true,
-			DialTimeout:           c.timeout,
-			ReadTimeout:           c.timeout,
-			WriteTimeout:          c.timeout,
-		}
+		if cfg.CacheCluster() {
+			opts := &redis.ClusterOptions{
+				Addrs:                 c.servers,
+				ContextTimeoutEnabled: true,
+				DialTimeout:           c.timeout,
+				ReadTimeout:           c.timeout,
+				WriteTimeout:          c.timeout,
+			}
 
-		if i := cfg.CachePoolSize(); i != 0 {
-			opts.PoolSize = i
-		}
+			if i := cfg.CachePoolSize(); i != 0 {
+				opts.PoolSize = i
+			}
 
-		rc := redis.NewClient(opts)
+			c.rc = redis.NewClusterClient(opts)
+		} else {
+			opts := &redis.Options{
+				Addr:                  c.servers[0],
+				ContextTimeoutEnabled: true,
+				DialTimeout:           c.timeout,
+				ReadTimeout:           c.timeout,
+				WriteTimeout:          c.timeout,
+			}
+
+			if i := cfg.CachePoolSize(); i != 0 {
+				opts.PoolSize = i
+			}
+
+			c.rc = redis.NewClient(opts)
+		}
 
-		c.rc = rc
 		c.mc = nil
 	case CacheTypeMemcache:
 		if c.discovery && len(c.servers) > 0 {
@@ -158,6 +185,10 @@ func NewClient(cfg *config.Config,
 		c.rc = nil
 	}
 
+	if exp := cfg.CacheLocalExpiration(); exp > 0 {
+		c.local = newLocalCache(exp, cfg.CacheLocalMaxItems())
+	}
+
 	return c
 }
 
@@ -181,7 +212,7 @@ func (c *Client) SetRedisClient(cli redisClient) {
 func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
 	c.RLock()
 
-	rc, mc, mr := c.rc, c.mc, c.metric
+	rc, mc, mr, local := c.rc, c.mc, c.metric, c.local
 
 	c.RUnlock()
 
@@ -196,27 +227,33 @@ func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
 	default:
 	}
 
+	if local != nil {
+		if item, ok := local.get(key); ok {
+			if mr != nil {
+				mr.Increment(ctx, "cache_local_hits")
+			}
+
+			return item, nil
+		}
+	}
+
+	if !c.allow(ctx, "get") {
+		return nil, errors.New(errors.ErrNotFound,
+			"key not retrieved from degraded cache")
+	}
+
 	res := &Item{}
 
 	ctx, finish := c.startCacheSpan(ctx, "get")
 
 	if rc != nil {
-		sc := rc.Get(ctx, key)
-
-		val, err := sc.Result()
+		items, err := redisGet(ctx, rc, key)
 
 		finish(err)
 
-		if err != nil {
-			if err == redis.Nil {
-				if mr != nil {
-					mr.Increment(ctx, "cache_misses", "operation:get")
-				}
-
-				return nil, errors.New(errors.ErrNotFound,
-					"key not found in cache")
-			}
+		c.record(ctx, err)
 
+		if err != nil {
 			if mr != nil {
 				mr.Increment(ctx, "cache_errors", "operation:get")
 			}
@@ -225,19 +262,30 @@ func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
 				"unable to get cache item")
 		}
 
+		item, ok := items[key]
+		if !ok {
+			if mr != nil {
+				mr.Increment(ctx, "cache_misses", "operation:get")
+			}
+
+			return nil, errors.New(errors.ErrNotFound,
+				"key not found in cache")
+		}
+
 		if mr != nil {
 			mr.Increment(ctx, "cache_hits")
 
-			mr.Add(ctx, "cache_hits_bytes", int64(len(val)))
+			mr.Add(ctx, "cache_hits_bytes", int64(len(item.Value)))
 		}
 
-		res.Key = key
-		res.Value = []byte(val)
+		res = item
 	} else {
 		item, err := mc.Get(key)
 
 		finish(err)
 
+		c.record(ctx, err)
+
 		if err != nil || item == nil {
 			if err == memcache.ErrCacheMiss {
 				if mr != nil {
@@ -267,6 +315,23 @@ func (c *Client) Get(ctx context.Context, key string) (*Item, error) {
 		res.Expiration = time.Duration(item.Expiration) * time.Second
 	}
 
+	v, err := decodeValue(res.Value)
+	if err != nil {
+		if mr != nil {
+			mr.Increment(ctx, "cache_errors", "operation:decode")
+		}
+
+		return nil, errors.Wrap(err, errors.ErrCache,
+			"unable to decode cache item",
+			"key", key)
+	}
+
+	res.Value = v
+
+	if local != nil {
+		local.set(res)
+	}
+
 	return res, nil
 }
 
@@ -276,7 +341,7 @@ func (c *Client) GetMulti(ctx context.Context,
 ) (map[string]*Item, error) {
 	c.RLock()
 
-	rc, mc, mr := c.rc, c.mc, c.metric
+	rc, mc, mr, local := c.rc, c.mc, c.metric, c.local
 
 	c.RUnlock()
 
@@ -293,25 +358,50 @@ func (c *Client) GetMulti(ctx context.Context,
 
 	res := map[string]*Item{}
 
+	if local != nil {
+		missing := make([]string, 0, len(keys))
+
+		for _, key := range keys {
+			item, ok := local.get(key)
+			if !ok {
+				missing = append(missing, key)
+
+				continue
+			}
+
+			if mr != nil {
+				mr.Increment(ctx, "cache_local_hits")
+			}
+
+			res[key] = item
+		}
+
+		if len(missing) == 0 {
+			return res, nil
+		}
+
+		keys = missing
+	}
+
+	if !c.allow(ctx, "get_multi") {
+		if len(res) > 0 {
+			return res, nil
+		}
+
+		return nil, errors.New(errors.ErrNotFound,
+			"keys not retrieved from degraded cache")
+	}
+
 	ctx, finish := c.startCacheSpan(ctx, "get_multi")
 
 	if rc != nil {
-		sc := rc.MGet(ctx, keys...)
-
-		vs, err := sc.Result()
+		items, err := redisGet(ctx, rc, keys...)
 
 		finish(err)
 
-		if err != nil || vs == nil {
-			if err == redis.Nil {
-				if mr != nil {
-					mr.Increment(ctx, "cache_misses", "operation:get_multi")
-				}
-
-				return nil, errors.New(errors.ErrNotFound,
-					"keys not found in cache")
-			}
+		c.record(ctx, err)
 
+		if err != nil {
 			if mr != nil {
 				mr.Increment(ctx, "cache_errors", "operation:get_multi")
 			}
@@ -320,13 +410,8 @@ func (c *Client) GetMulti(ctx context.Context,
 				"unable to get cache items")
 		}
 
-		if len(vs) != len(keys) {
-			return nil, errors.New(errors.ErrCache,
-				"invalid response received from cache")
-		}
-
-		for i, key := range keys {
-			vs, ok := vs[i].(string)
+		for _, key := range keys {
+			item, ok := items[key]
 			if !ok {
 				if mr != nil {
 					mr.Increment(ctx, "cache_misses", "operation:get_multi_key")
@@ -335,23 +420,21 @@ func (c *Client) GetMulti(ctx context.Context,
 				continue
 			}
 
-			val := []byte(vs)
-
 			if mr != nil {
 				mr.Increment(ctx, "cache_hits")
 
-				mr.Add(ctx, "cache_hits_bytes", int64(len(val)))
+				mr.Add(ctx, "cache_hits_bytes", int64(len(item.Value)))
 			}
 
-			res[key] = new(Item)
-			res[key].Key = key
-			res[key].Value = []byte(val)
+			res[key] = item
 		}
 	} else {
 		items, err := c.mc.GetMulti(keys)
 
 		finish(err)
 
+		c.record(ctx, err)
+
 		if err != nil {
 			if err == memcache.ErrCacheMiss {
 				if mr != nil {
@@ -398,6 +481,34 @@ func (c *Client) GetMulti(ctx context.Context,
 		}
 	}
 
+	for _, key := range keys {
+		item, ok := res[key]
+		if !ok {
+			continue
+		}
+
+		v, err := decodeValue(item.Value)
+		if err != nil {
+			if mr != nil {
+				mr.Increment(ctx, "cache_errors", "operation:decode")
+			}
+
+			delete(res, key)
+
+			continue
+		}
+
+		item.Value = v
+	}
+
+	if local != nil {
+		for _, key := range keys {
+			if item, ok := res[key]; ok {
+				local.set(item)
+			}
+		}
+	}
+
 	return res, nil
 }
 
@@ -410,7 +521,7 @@ func (c *Client) Set(ctx context.Context, item *Item) error {
 
 	c.RLock()
 
-	rc, mc, mr := c.rc, c.mc, c.metric
+	rc, mc, mr, local := c.rc, c.mc, c.metric, c.local
 
 	c.RUnlock()
 
@@ -425,18 +536,41 @@ func (c *Client) Set(ctx context.Context, item *Item) error {
 	default:
 	}
 
+	// Values are compressed above the compression threshold, so that larger
+	// values fit within the maximum item size. Values which are still too
+	// large are not cached.
+	value := encodeValue(item.Value, c.compress)
+
+	if c.maxBytes > 0 && len(value) >= c.maxBytes {
+		if mr != nil {
+			mr.Increment(ctx, "cache_sets_skipped", "reason:size")
+		}
+
+		return nil
+	}
+
+	// While the cache is degraded, items are only stored in the in-memory
+	// cache tier.
+	if !c.allow(ctx, "set") {
+		if local != nil {
+			local.set(item)
+		}
+
+		return nil
+	}
+
 	ctx, finish := c.startCacheSpan(ctx, "set")
 
 	var err error
 
 	if rc != nil {
-		sc := rc.Set(ctx, item.Key, string(item.Value), item.Expiration)
+		sc := rc.Set(ctx, item.Key, string(value), item.Expiration)
 
 		err = sc.Err()
 	} else {
 		req := memcache.Item{
 			Key:        item.Key,
-			Value:      item.Value,
+			Value:      value,
 			Expiration: int32(item.Expiration.Seconds()),
 		}
 
@@ -445,6 +579,8 @@ func (c *Client) Set(ctx context.Context, item *Item) error {
 
 	finish(err)
 
+	c.record(ctx, err)
+
 	if err != nil {
 		if mr != nil {
 			mr.Increment(ctx, "cache_errors", "operation:set")
@@ -457,7 +593,15 @@ func (c *Client) Set(ctx context.Context, item *Item) error {
 	if mr != nil {
 		mr.Increment(ctx, "cache_sets")
 
-		mr.Add(ctx, "cache_sets_bytes", int64(len(item.Value)))
+		mr.Add(ctx, "cache_sets_bytes", int64(len(value)))
+
+		if len(value) > 0 && value[0] == formatGzip {
+			mr.Increment(ctx, "cache_sets_compressed")
+		}
+	}
+
+	if local != nil {
+		local.set(item)
 	}
 
 	return nil
@@ -470,6 +614,7 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 	rc := c.rc
 	mc := c.mc
 	mr := c.metric
+	local := c.local
 
 	c.RUnlock()
 
@@ -484,6 +629,18 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 	default:
 	}
 
+	if local != nil {
+		local.delete(key)
+	}
+
+	// While the cache is degraded, deleted keys are retained, and deleted
+	// from the cache servers when the cache recovers.
+	if !c.allow(ctx, "delete") {
+		c.deletePending(ctx, key)
+
+		return nil
+	}
+
 	ctx, finish := c.startCacheSpan(ctx, "delete")
 
 	if rc != nil {
@@ -493,7 +650,13 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 
 		finish(err)
 
+		c.record(ctx, err)
+
 		if err != nil {
+			if degraded, _ := c.Degraded(); degraded {
+				c.deletePending(ctx, key)
+			}
+
 			if mr != nil {
 				mr.Increment(ctx, "cache_errors", "operation:delete")
 			}
@@ -505,17 +668,25 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 		if mr != nil {
 			mr.Increment(ctx, "cache_deletes")
 		}
+
+		c.publish(ctx, key)
 	} else {
 		err := mc.Delete(key)
 
 		finish(err)
 
+		c.record(ctx, err)
+
 		if err != nil {
 			if err == memcache.ErrCacheMiss {
 				// Do not record a cache miss for a missed delete.
 				return nil
 			}
 
+			if degraded, _ := c.Degraded(); degraded {
+				c.deletePending(ctx, key)
+			}
+
 			if mr != nil {
 				mr.Increment(ctx, "cache_errors", "operation:delete")
 			}
@@ -532,6 +703,55 @@ func (c *Client) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// redisGet retrieves the values and remaining expiration of the specified keys
+// using a single pipeline, which is split across the nodes of a cluster by the
+// client, as needed. Keys which are not found are omitted from the result.
+func redisGet(ctx context.Context,
+	rc redisClient,
+	keys ...string,
+) (map[string]*Item, error) {
+	pipe := rc.Pipeline()
+
+	gets := make([]*redis.StringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+
+	for i, key := range keys {
+		gets[i] = pipe.Get(ctx, key)
+		ttls[i] = pipe.PTTL(ctx, key)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	res := make(map[string]*Item, len(keys))
+
+	for i, key := range keys {
+		val, err := gets[i].Result()
+		if err != nil {
+			if err == redis.Nil {
+				continue
+			}
+
+			return nil, err
+		}
+
+		item := &Item{
+			Key:   key,
+			Value: []byte(val),
+		}
+
+		// Keys without an expiration return a negative TTL.
+		if ttl := ttls[i].Val(); ttl > 0 {
+			item.Expiration = ttl
+		}
+
+		res[key] = item
+	}
+
+	return res, nil
+}
+
 // startCacheSpan starts a cache tracing span. It returns an updated context,
 // and a closing function.
 func (c *Client) startCacheSpan(ctx context.Context, name string,
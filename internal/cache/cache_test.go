@@ -3,11 +3,14 @@ package cache_test
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/dhaifley/apigo/internal/cache"
 	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/google/gomemcache/memcache"
 	"github.com/redis/go-redis/v9"
 )
@@ -53,32 +56,88 @@ func (m *mockMemcacheClient) Delete(key string) error {
 	return nil
 }
 
-type mockRedisClient struct{}
+type mockRedisPipeline struct {
+	redis.Pipeliner
+	cmds []redis.Cmder
+}
 
-func (m *mockRedisClient) Get(ctx context.Context,
+func (m *mockRedisPipeline) Get(ctx context.Context,
 	key string,
 ) *redis.StringCmd {
+	var cmd *redis.StringCmd
+
 	switch key {
-	case "test":
-		return redis.NewStringResult("test", nil)
+	case "test", "test2":
+		cmd = redis.NewStringResult(key, nil)
 	default:
-		return redis.NewStringResult("", redis.Nil)
+		cmd = redis.NewStringResult("", redis.Nil)
 	}
+
+	m.cmds = append(m.cmds, cmd)
+
+	return cmd
 }
 
-func (m *mockRedisClient) MGet(ctx context.Context,
-	keys ...string,
-) *redis.SliceCmd {
-	if len(keys) < 1 {
-		return redis.NewSliceResult(nil, redis.Nil)
-	}
+func (m *mockRedisPipeline) PTTL(ctx context.Context,
+	key string,
+) *redis.DurationCmd {
+	var cmd *redis.DurationCmd
 
-	switch keys[0] {
+	switch key {
 	case "test":
-		return redis.NewSliceResult([]any{"test"}, nil)
+		cmd = redis.NewDurationResult(time.Minute, nil)
+	case "test2":
+		cmd = redis.NewDurationResult(-1, nil)
 	default:
-		return redis.NewSliceResult([]any{nil}, nil)
+		cmd = redis.NewDurationResult(-2, nil)
 	}
+
+	m.cmds = append(m.cmds, cmd)
+
+	return cmd
+}
+
+func (m *mockRedisPipeline) Exec(ctx context.Context) ([]redis.Cmder, error) {
+	for _, cmd := range m.cmds {
+		if err := cmd.Err(); err != nil {
+			return m.cmds, err
+		}
+	}
+
+	return m.cmds, nil
+}
+
+type mockRedisClient struct {
+	sync.Mutex
+	gets      int
+	published []string
+}
+
+func (m *mockRedisClient) Pipeline() redis.Pipeliner {
+	m.Lock()
+	defer m.Unlock()
+
+	m.gets++
+
+	return &mockRedisPipeline{}
+}
+
+func (m *mockRedisClient) Publish(ctx context.Context,
+	channel string,
+	message any,
+) *redis.IntCmd {
+	m.Lock()
+	defer m.Unlock()
+
+	m.published = append(m.published, fmt.Sprint(message))
+
+	return redis.NewIntResult(1, nil)
+}
+
+func (m *mockRedisClient) Subscribe(ctx context.Context,
+	channels ...string,
+) *redis.PubSub {
+	return nil
 }
 
 func (m *mockRedisClient) Set(ctx context.Context,
@@ -170,15 +229,29 @@ func TestClient(t *testing.T) {
 		t.Errorf("Expected value: test, got: %v", res.Value)
 	}
 
-	resM, err = mp.GetMulti(context.Background(), "test")
+	if res.Expiration != time.Minute {
+		t.Errorf("Expected expiration: %v, got: %v", time.Minute,
+			res.Expiration)
+	}
+
+	resM, err = mp.GetMulti(context.Background(), "test", "invalid", "test2")
 	if err != nil {
 		t.Errorf("Unexpected error from get: %v", err.Error())
 	}
 
+	if len(resM) != 2 {
+		t.Errorf("Expected multi length: 2, got: %v", len(resM))
+	}
+
 	if string(resM["test"].Value) != "test" {
 		t.Errorf("Expected multi value: test, got: %v", resM)
 	}
 
+	if resM["test2"].Expiration != 0 {
+		t.Errorf("Expected multi expiration: 0, got: %v",
+			resM["test2"].Expiration)
+	}
+
 	err = mp.Set(context.Background(),
 		&cache.Item{Key: "test", Value: []byte("test")})
 	if err != nil {
@@ -194,4 +267,228 @@ func TestClient(t *testing.T) {
 	if err != nil {
 		t.Errorf("Unexpected error from delete: %v", err.Error())
 	}
+
+	cfg.SetCache(&config.CacheConfig{
+		Type:       cache.CacheTypeRedis,
+		Servers:    []string{"localhost:1234", "localhost:1235"},
+		Cluster:    true,
+		Expiration: time.Second,
+	})
+
+	if mp = cache.NewClient(cfg, nil, nil, nil); mp == nil {
+		t.Fatal("Unable to initialize redis cluster client")
+	}
+}
+
+func TestClientLocal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cfg := &config.Config{}
+
+	cfg.SetCache(&config.CacheConfig{
+		Type:            cache.CacheTypeRedis,
+		Servers:         []string{"localhost:1234"},
+		LocalExpiration: time.Second * 30,
+		LocalMaxItems:   1,
+	})
+
+	mp := cache.NewClient(cfg, nil, nil, nil)
+	if mp == nil {
+		t.Fatal("Unable to initialize redis client")
+	}
+
+	rc := &mockRedisClient{}
+
+	mp.SetRedisClient(rc)
+
+	for i := 0; i < 2; i++ {
+		res, err := mp.Get(ctx, "test")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(res.Value) != "test" {
+			t.Errorf("Expected value: test, got: %v", res.Value)
+		}
+
+		if res.Expiration <= 0 || res.Expiration > time.Minute {
+			t.Errorf("Expected expiration within 1m, got: %v", res.Expiration)
+		}
+	}
+
+	if rc.gets != 1 {
+		t.Errorf("Expected shared cache gets: 1, got: %v", rc.gets)
+	}
+
+	// The local tier is full, so this item is not held locally.
+	if _, err := mp.GetMulti(ctx, "test", "test2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mp.GetMulti(ctx, "test2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rc.gets != 3 {
+		t.Errorf("Expected shared cache gets: 3, got: %v", rc.gets)
+	}
+
+	mp.Invalidate("test")
+
+	if _, err := mp.Get(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rc.gets != 4 {
+		t.Errorf("Expected shared cache gets: 4, got: %v", rc.gets)
+	}
+
+	if err := mp.Delete(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rc.published) != 1 || !strings.HasSuffix(rc.published[0], " test") {
+		t.Errorf("Expected published invalidation for: test, got: %v",
+			rc.published)
+	}
+
+	if _, err := mp.Get(ctx, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rc.gets != 5 {
+		t.Errorf("Expected shared cache gets: 5, got: %v", rc.gets)
+	}
+}
+
+type mockDownMemcacheClient struct {
+	sync.Mutex
+	down    bool
+	calls   int
+	deleted []string
+}
+
+func (m *mockDownMemcacheClient) call() error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.calls++
+
+	if m.down {
+		return errors.New(errors.ErrUnavailable, "connection refused")
+	}
+
+	return nil
+}
+
+func (m *mockDownMemcacheClient) Get(key string) (*memcache.Item, error) {
+	if err := m.call(); err != nil {
+		return nil, err
+	}
+
+	return nil, memcache.ErrCacheMiss
+}
+
+func (m *mockDownMemcacheClient) GetMulti(keys []string,
+) (map[string]*memcache.Item, error) {
+	if err := m.call(); err != nil {
+		return nil, err
+	}
+
+	return map[string]*memcache.Item{}, nil
+}
+
+func (m *mockDownMemcacheClient) Set(item *memcache.Item) error {
+	return m.call()
+}
+
+func (m *mockDownMemcacheClient) Delete(key string) error {
+	if err := m.call(); err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	m.deleted = append(m.deleted, key)
+
+	return nil
+}
+
+func TestClientDegraded(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cfg := &config.Config{}
+
+	cfg.SetCache(&config.CacheConfig{
+		Type:             cache.CacheTypeMemcache,
+		Servers:          []string{"localhost:11211"},
+		BreakerThreshold: 2,
+		BreakerTimeout:   time.Millisecond * 50,
+	})
+
+	mp := cache.NewClient(cfg, nil, nil, nil)
+	if mp == nil {
+		t.Fatal("Unable to initialize memcache client")
+	}
+
+	mc := &mockDownMemcacheClient{down: true}
+
+	mp.SetMemcacheClient(mc)
+
+	// A cache miss does not count as a failure.
+	for i := 0; i < 2; i++ {
+		if _, err := mp.Get(ctx, "test"); err == nil {
+			t.Error("Expected error from unavailable cache")
+		}
+	}
+
+	if degraded, until := mp.Degraded(); !degraded || until.IsZero() {
+		t.Fatalf("Expected degraded cache, got: %v, %v", degraded, until)
+	}
+
+	if mc.calls != 2 {
+		t.Errorf("Expected cache calls: 2, got: %v", mc.calls)
+	}
+
+	_, err := mp.Get(ctx, "test")
+	if !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected not found error from degraded cache, got: %v", err)
+	}
+
+	if err := mp.Set(ctx, &cache.Item{Key: "test"}); err != nil {
+		t.Errorf("Unexpected error from degraded set: %v", err)
+	}
+
+	if err := mp.Delete(ctx, "test"); err != nil {
+		t.Errorf("Unexpected error from degraded delete: %v", err)
+	}
+
+	if mc.calls != 2 {
+		t.Errorf("Expected cache calls while degraded: 2, got: %v", mc.calls)
+	}
+
+	time.Sleep(time.Millisecond * 60)
+
+	mc.Lock()
+
+	mc.down = false
+
+	mc.Unlock()
+
+	if _, err := mp.Get(ctx, "test"); !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected cache miss after recovery, got: %v", err)
+	}
+
+	if degraded, _ := mp.Degraded(); degraded {
+		t.Error("Expected recovered cache")
+	}
+
+	if len(mc.deleted) != 1 || mc.deleted[0] != "test" {
+		t.Errorf("Expected pending delete of: test, got: %v", mc.deleted)
+	}
 }
@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+)
+
+// Header bytes identifying the format of encoded cache item values. Values
+// which do not begin with a header byte, such as those cached before values
+// were encoded, are stored as they are.
+const (
+	formatRaw  byte = 0x00
+	formatGzip byte = 0x01
+)
+
+// encodeValue encodes a cache item value for storage. Values at least as large
+// as the threshold are compressed, if compression makes them smaller, and are
+// prefixed by the gzip header byte. Other values are stored as they are, unless
+// they begin with a header byte, in which case they are prefixed by the raw
+// header byte. If the threshold is not positive, values are not compressed.
+func encodeValue(v []byte, threshold int) []byte {
+	if threshold > 0 && len(v) >= threshold {
+		buf := bytes.NewBuffer(make([]byte, 0, len(v)/2))
+
+		buf.WriteByte(formatGzip)
+
+		zw, err := gzip.NewWriterLevel(buf, gzip.BestSpeed)
+		if err == nil {
+			if _, err = zw.Write(v); err == nil {
+				err = zw.Close()
+			}
+
+			if err == nil && buf.Len() < len(v) {
+				return buf.Bytes()
+			}
+		}
+	}
+
+	if len(v) > 0 && (v[0] == formatRaw || v[0] == formatGzip) {
+		return append([]byte{formatRaw}, v...)
+	}
+
+	return v
+}
+
+// decodeValue decodes a cache item value encoded for storage.
+func decodeValue(v []byte) ([]byte, error) {
+	if len(v) == 0 {
+		return v, nil
+	}
+
+	switch v[0] {
+	case formatRaw:
+		return v[1:], nil
+	case formatGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(v[1:]))
+		if err != nil {
+			return nil, err
+		}
+
+		defer zr.Close()
+
+		return io.ReadAll(zr)
+	default:
+		return v, nil
+	}
+}
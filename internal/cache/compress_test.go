@@ -0,0 +1,163 @@
+package cache_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/google/gomemcache/memcache"
+)
+
+type mockStoreMemcacheClient struct {
+	sync.Mutex
+	items map[string][]byte
+}
+
+func (m *mockStoreMemcacheClient) Get(key string) (*memcache.Item, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	v, ok := m.items[key]
+	if !ok {
+		return nil, memcache.ErrCacheMiss
+	}
+
+	return &memcache.Item{Key: key, Value: v}, nil
+}
+
+func (m *mockStoreMemcacheClient) GetMulti(keys []string,
+) (map[string]*memcache.Item, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	res := map[string]*memcache.Item{}
+
+	for _, key := range keys {
+		if v, ok := m.items[key]; ok {
+			res[key] = &memcache.Item{Key: key, Value: v}
+		}
+	}
+
+	return res, nil
+}
+
+func (m *mockStoreMemcacheClient) Set(item *memcache.Item) error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.items[item.Key] = item.Value
+
+	return nil
+}
+
+func (m *mockStoreMemcacheClient) Delete(key string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.items, key)
+
+	return nil
+}
+
+func TestClientCompress(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{}
+
+	cfg.SetCache(&config.CacheConfig{
+		Type:          cache.CacheTypeMemcache,
+		Servers:       []string{"localhost:11211"},
+		Expiration:    time.Second,
+		MaxBytes:      1024,
+		CompressBytes: 64,
+	})
+
+	mp := cache.NewClient(cfg, nil, nil, nil)
+	if mp == nil {
+		t.Fatal("Expected client")
+	}
+
+	mc := &mockStoreMemcacheClient{items: map[string][]byte{}}
+
+	mp.SetMemcacheClient(mc)
+
+	ctx := context.Background()
+
+	large := bytes.Repeat([]byte(`{"test":"test"}`), 256)
+
+	tests := []struct {
+		name   string
+		value  []byte
+		stored func(v []byte) bool
+	}{{
+		name:   "small",
+		value:  []byte(`{"test":"test"}`),
+		stored: func(v []byte) bool { return string(v) == `{"test":"test"}` },
+	}, {
+		name:   "large",
+		value:  large,
+		stored: func(v []byte) bool { return len(v) < 1024 && v[0] == 0x01 },
+	}, {
+		name:   "header",
+		value:  []byte{0x01, 't'},
+		stored: func(v []byte) bool { return bytes.Equal(v, []byte{0, 1, 't'}) },
+	}}
+
+	for _, tt := range tests {
+		if err := mp.Set(ctx, &cache.Item{
+			Key:   tt.name,
+			Value: tt.value,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if v, ok := mc.items[tt.name]; !ok || !tt.stored(v) {
+			t.Errorf("Unexpected stored value for %v: %v", tt.name, v)
+		}
+
+		res, err := mp.Get(ctx, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(res.Value, tt.value) {
+			t.Errorf("Expected value for %v: %v, got: %v", tt.name,
+				string(tt.value), string(res.Value))
+		}
+
+		resM, err := mp.GetMulti(ctx, tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(resM[tt.name].Value, tt.value) {
+			t.Errorf("Expected multi value for %v: %v, got: %v", tt.name,
+				string(tt.value), resM)
+		}
+	}
+
+	// Values too large to cache, even when compressed, are skipped.
+	random := make([]byte, 2048)
+
+	for i := range random {
+		random[i] = byte(i*7919%251) ^ byte(i>>3)
+	}
+
+	if err := mp.Set(ctx, &cache.Item{Key: "random", Value: random}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := mc.items["random"]; ok {
+		t.Error("Expected value too large to be skipped")
+	}
+
+	mc.items["corrupt"] = []byte{0x01, 'x'}
+
+	if _, err := mp.Get(ctx, "corrupt"); err == nil {
+		t.Error("Expected decode error, got: nil")
+	}
+}
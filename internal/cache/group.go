@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"golang.org/x/sync/singleflight"
+)
+
+// Group values coalesce concurrent loads of the same value, so that when many
+// requests miss the cache for the same key at once, only one of them loads the
+// value from the database and populates the cache. The zero value is ready to
+// use.
+type Group struct {
+	g singleflight.Group
+}
+
+// Load calls fn to load the value for a key, unless a load of the same key, in
+// the same account, is already in progress. In that case, it waits for the
+// load in progress to complete, and returns a copy of its result, so that
+// callers modifying their results do not affect each other. The load is not
+// canceled if the caller which started it is canceled, since other callers may
+// be waiting for it.
+func Load[T any](ctx context.Context,
+	g *Group,
+	key string,
+	fn func(ctx context.Context) (*T, error),
+) (*T, error) {
+	if accountID, err := request.ContextAccountID(ctx); err == nil {
+		key = accountID + "::" + key
+	}
+
+	ch := g.g.DoChan(key, func() (any, error) {
+		return fn(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, errors.Context(ctx)
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		v, _ := res.Val.(*T)
+
+		if !res.Shared || v == nil {
+			return v, nil
+		}
+
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrCache,
+				"unable to encode shared value",
+				"key", key)
+		}
+
+		r := new(T)
+
+		if err := json.Unmarshal(buf, r); err != nil {
+			return nil, errors.Wrap(err, errors.ErrCache,
+				"unable to decode shared value",
+				"key", key)
+		}
+
+		return r, nil
+	}
+}
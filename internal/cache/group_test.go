@@ -0,0 +1,103 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+type testValue struct {
+	Value string `json:"value"`
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	ctx := request.WithAccountID(context.Background(), "1")
+
+	g := &cache.Group{}
+
+	calls := atomic.Int64{}
+
+	start := make(chan struct{})
+
+	load := func(ctx context.Context) (*testValue, error) {
+		calls.Add(1)
+
+		<-start
+
+		return &testValue{Value: "test"}, nil
+	}
+
+	n := 100
+
+	res := make([]*testValue, n)
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			v, err := cache.Load(ctx, g, "test", load)
+			if err != nil {
+				t.Error(err)
+
+				return
+			}
+
+			res[i] = v
+		}(i)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	close(start)
+
+	wg.Wait()
+
+	if c := calls.Load(); c != 1 {
+		t.Errorf("Expected calls: 1, got: %v", c)
+	}
+
+	for i, v := range res {
+		if v == nil || v.Value != "test" {
+			t.Fatalf("Expected value: test, got: %v", v)
+		}
+
+		if i > 0 && v == res[0] {
+			t.Error("Expected copies of shared values")
+		}
+	}
+
+	// Loads in different accounts are not coalesced.
+	actx := request.WithAccountID(ctx, "2")
+
+	if _, err := cache.Load(actx, g, "test", load); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := calls.Load(); c != 2 {
+		t.Errorf("Expected calls: 2, got: %v", c)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+
+	cancel()
+
+	if _, err := cache.Load(cctx, g, "canceled",
+		func(ctx context.Context) (*testValue, error) {
+			return &testValue{}, nil
+		}); err != nil && !errors.Has(err, errors.ErrContext) &&
+		!errors.Has(err, errors.ErrContextCanceled) {
+		t.Errorf("Expected context error, got: %v", err)
+	}
+}
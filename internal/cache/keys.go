@@ -34,3 +34,21 @@ func KeyToken(token string) string {
 func KeyResource(id string) string {
 	return "Resource::" + id
 }
+
+// KeyAccountGeneration returns a cache key to be used for the generation of
+// the values cached for an account.
+func KeyAccountGeneration(accountID string) string {
+	return "Account::Generation::" + accountID
+}
+
+// KeyGroupGrants returns a cache key to be used for the scopes granted by the
+// groups of an account.
+func KeyGroupGrants(accountID string) string {
+	return "Group::Grants::" + accountID
+}
+
+// KeyAuthFailures returns a cache key to be used for the failed authentication
+// attempts counted for a client address or account.
+func KeyAuthFailures(kind, id string) string {
+	return "Auth::Failures::" + kind + "::" + id
+}
@@ -21,6 +21,10 @@ func TestCacheKeys(t *testing.T) {
 			exp: "Account::Name::test",
 			run: func() string { return cache.KeyAccountName("test") },
 		},
+		{
+			exp: "Account::Generation::test",
+			run: func() string { return cache.KeyAccountGeneration("test") },
+		},
 		{
 			exp: "User::test",
 			run: func() string { return cache.KeyUser("test") },
@@ -41,6 +45,14 @@ func TestCacheKeys(t *testing.T) {
 			exp: "Resource::test",
 			run: func() string { return cache.KeyResource("test") },
 		},
+		{
+			exp: "Group::Grants::test",
+			run: func() string { return cache.KeyGroupGrants("test") },
+		},
+		{
+			exp: "Auth::Failures::remote::test",
+			run: func() string { return cache.KeyAuthFailures("remote", "test") },
+		},
 	}
 
 	for _, tt := range tests {
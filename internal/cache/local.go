@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+// InvalidationChannel is the redis channel on which deleted cache keys are
+// published, so that they are dropped from the in-memory cache tier of every
+// service instance.
+const InvalidationChannel = "apigo:cache:invalidate"
+
+// localItem values are items held in an in-memory cache tier.
+type localItem struct {
+	value   []byte
+	expires time.Time
+}
+
+// localCache values are in-memory cache tiers, which hold recently retrieved
+// items for a short time in front of the shared cache servers.
+type localCache struct {
+	sync.Mutex
+	items      map[string]*localItem
+	expiration time.Duration
+	maxItems   int
+}
+
+// newLocalCache creates a new in-memory cache tier.
+func newLocalCache(expiration time.Duration, maxItems int) *localCache {
+	return &localCache{
+		items:      map[string]*localItem{},
+		expiration: expiration,
+		maxItems:   maxItems,
+	}
+}
+
+// get retrieves an unexpired item from the in-memory cache tier.
+func (l *localCache) get(key string) (*Item, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	li, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	ttl := time.Until(li.expires)
+	if ttl <= 0 {
+		delete(l.items, key)
+
+		return nil, false
+	}
+
+	return &Item{Key: key, Value: li.value, Expiration: ttl}, true
+}
+
+// set stores an item in the in-memory cache tier, until the sooner of the tier
+// expiration and the item expiration. If the tier is full, expired items are
+// removed, and if it is still full, the item is not stored.
+func (l *localCache) set(item *Item) {
+	exp := l.expiration
+	if item.Expiration > 0 && item.Expiration < exp {
+		exp = item.Expiration
+	}
+
+	now := time.Now()
+
+	l.Lock()
+	defer l.Unlock()
+
+	if _, ok := l.items[item.Key]; !ok && len(l.items) >= l.maxItems {
+		for k, li := range l.items {
+			if !now.Before(li.expires) {
+				delete(l.items, k)
+			}
+		}
+
+		if len(l.items) >= l.maxItems {
+			return
+		}
+	}
+
+	l.items[item.Key] = &localItem{value: item.Value, expires: now.Add(exp)}
+}
+
+// delete removes an item from the in-memory cache tier.
+func (l *localCache) delete(key string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.items, key)
+}
+
+// Invalidate removes a key from the in-memory cache tier of the client, if it
+// has one. It is called for each key deleted by another service instance.
+func (c *Client) Invalidate(key string) {
+	c.RLock()
+
+	local := c.local
+
+	c.RUnlock()
+
+	if local != nil {
+		local.delete(key)
+	}
+}
+
+// publish broadcasts a deleted key to the other service instances, so that it
+// is dropped from their in-memory cache tiers. Since items in the in-memory
+// tiers expire quickly regardless, failures are logged but not returned.
+func (c *Client) publish(ctx context.Context, key string) {
+	c.RLock()
+
+	rc, id, log := c.rc, c.id, c.log
+
+	c.RUnlock()
+
+	if rc == nil {
+		return
+	}
+
+	if err := rc.Publish(ctx, InvalidationChannel,
+		id+" "+key).Err(); err != nil {
+		log.Log(ctx, logger.LvlWarn,
+			"unable to publish cache invalidation",
+			"error", err,
+			"key", key)
+	}
+}
+
+// Listen begins receiving the keys deleted by other service instances, and
+// dropping them from the in-memory cache tier of the client. Invalidations are
+// only received using redis, and only if the in-memory tier is enabled. The
+// returned function stops listening.
+func (c *Client) Listen(ctx context.Context) context.CancelFunc {
+	c.RLock()
+
+	rc, local, id, log := c.rc, c.local, c.id, c.log
+
+	c.RUnlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	if rc == nil || local == nil {
+		return cancel
+	}
+
+	ps := rc.Subscribe(ctx, InvalidationChannel)
+
+	go func() {
+		defer func() {
+			if err := ps.Close(); err != nil {
+				log.Log(ctx, logger.LvlError,
+					"unable to close cache invalidation subscription",
+					"error", err)
+			}
+		}()
+
+		ch := ps.Channel()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				from, key, ok := strings.Cut(msg.Payload, " ")
+				if !ok || from == id {
+					continue
+				}
+
+				local.delete(key)
+			}
+		}
+	}()
+
+	return cancel
+}
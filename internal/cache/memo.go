@@ -0,0 +1,128 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// Memo values hold the values retrieved during a single request, so that a
+// value needed more than once while handling the request, such as the account
+// by the authentication middleware and the handler, is only retrieved once.
+type Memo struct {
+	sync.Mutex
+	values map[string][]byte
+}
+
+// WithMemo returns a copy of a context carrying a new, empty, request memo.
+func WithMemo(ctx context.Context) context.Context {
+	return context.WithValue(ctx, request.CtxKeyMemo, &Memo{
+		values: map[string][]byte{},
+	})
+}
+
+// contextMemo returns the request memo carried by a context, or nil if the
+// context does not carry one.
+func contextMemo(ctx context.Context) *Memo {
+	m, _ := ctx.Value(request.CtxKeyMemo).(*Memo)
+
+	return m
+}
+
+// memoKey returns the key of a value in the request memo, which, as with
+// loads, is specific to the account of the context.
+func memoKey(ctx context.Context, key string) string {
+	if accountID, err := request.ContextAccountID(ctx); err == nil {
+		key = accountID + "::" + key
+	}
+
+	return key
+}
+
+// Memoize returns a copy of the value for a key held in the request memo of
+// the context, if any. Otherwise, it calls fn to retrieve the value, and holds
+// it in the request memo for the rest of the request. Errors are not held, and
+// fn is always called if the context does not carry a request memo.
+func Memoize[T any](ctx context.Context,
+	key string,
+	fn func(ctx context.Context) (*T, error),
+) (*T, error) {
+	m := contextMemo(ctx)
+	if m == nil {
+		return fn(ctx)
+	}
+
+	key = memoKey(ctx, key)
+
+	m.Lock()
+	buf, ok := m.values[key]
+	m.Unlock()
+
+	if ok {
+		r := new(T)
+
+		if err := json.Unmarshal(buf, r); err != nil {
+			return nil, errors.Wrap(err, errors.ErrCache,
+				"unable to decode memo value",
+				"key", key)
+		}
+
+		return r, nil
+	}
+
+	v, err := fn(ctx)
+	if err != nil || v == nil {
+		return v, err
+	}
+
+	buf, err = json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrCache,
+			"unable to encode memo value",
+			"key", key)
+	}
+
+	m.Lock()
+	m.values[key] = buf
+	m.Unlock()
+
+	return v, nil
+}
+
+// Forget removes the value for a key, and any values for keys beginning with
+// the key followed by "::", from the request memo of the context. It is used
+// when a value is changed during the request.
+func Forget(ctx context.Context, key string) {
+	m := contextMemo(ctx)
+	if m == nil {
+		return
+	}
+
+	key = memoKey(ctx, key)
+
+	m.Lock()
+	defer m.Unlock()
+
+	for k := range m.values {
+		if k == key || strings.HasPrefix(k, key+"::") {
+			delete(m.values, k)
+		}
+	}
+}
+
+// ForgetAll removes all values from the request memo of the context.
+func ForgetAll(ctx context.Context) {
+	m := contextMemo(ctx)
+	if m == nil {
+		return
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	clear(m.values)
+}
@@ -0,0 +1,129 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+func TestMemoize(t *testing.T) {
+	t.Parallel()
+
+	ctx := request.WithAccountID(context.Background(), "1")
+
+	calls := 0
+
+	load := func(ctx context.Context) (*testValue, error) {
+		calls++
+
+		return &testValue{Value: "test"}, nil
+	}
+
+	if _, err := cache.Memoize(ctx, "test", load); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.Memoize(ctx, "test", load); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected calls without memo: 2, got: %v", calls)
+	}
+
+	ctx = cache.WithMemo(ctx)
+
+	calls = 0
+
+	v, err := cache.Memoize(ctx, "test", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v.Value = "changed"
+
+	v, err = cache.Memoize(ctx, "test", load)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected calls: 1, got: %v", calls)
+	}
+
+	if v.Value != "test" {
+		t.Errorf("Expected value: test, got: %v", v.Value)
+	}
+
+	aCtx := request.WithAccountID(ctx, "2")
+
+	if _, err := cache.Memoize(aCtx, "test", load); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected calls for another account: 2, got: %v", calls)
+	}
+
+	if _, err := cache.Memoize(ctx, "fail",
+		func(ctx context.Context) (*testValue, error) {
+			calls++
+
+			return nil, errors.New(errors.ErrNotFound, "not found")
+		}); !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	if _, err := cache.Memoize(ctx, "fail", load); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected errors not to be held, calls: %v", calls)
+	}
+}
+
+func TestForget(t *testing.T) {
+	t.Parallel()
+
+	ctx := cache.WithMemo(request.WithAccountID(context.Background(), "1"))
+
+	calls := 0
+
+	load := func(ctx context.Context) (*testValue, error) {
+		calls++
+
+		return &testValue{Value: "test"}, nil
+	}
+
+	for _, key := range []string{"test", "test::details", "other"} {
+		if _, err := cache.Memoize(ctx, key, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cache.Forget(ctx, "test")
+
+	for _, key := range []string{"test", "test::details", "other"} {
+		if _, err := cache.Memoize(ctx, key, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 5 {
+		t.Errorf("Expected calls after forget: 5, got: %v", calls)
+	}
+
+	cache.ForgetAll(ctx)
+
+	if _, err := cache.Memoize(ctx, "other", load); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 6 {
+		t.Errorf("Expected calls after forget all: 6, got: %v", calls)
+	}
+}
@@ -0,0 +1,189 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/google/uuid"
+)
+
+// accountKeyPrefix is the prefix of the keys of account values, which are not
+// namespaced, since accounts are retrieved before the account of a request is
+// known. Namespaced keys also begin with it, so are never namespaced twice.
+const accountKeyPrefix = "Account::"
+
+// Namespace values are accessors which namespace the keys of the values cached
+// for the account of the request context, using a generation stored in the
+// cache for the account. Flushing the account replaces its generation, so that
+// all of the values cached for the account are invalidated at once. Keys used
+// without an account in the context, and account keys, are not namespaced.
+type Namespace struct {
+	c Accessor
+}
+
+// NewNamespace creates a new accessor namespacing the keys of the values
+// cached by an accessor. If the accessor is nil, nil is returned.
+func NewNamespace(c Accessor) Accessor {
+	if c == nil || (reflect.ValueOf(c).Kind() == reflect.Ptr &&
+		reflect.ValueOf(c).IsNil()) {
+		return nil
+	}
+
+	if n, ok := c.(*Namespace); ok {
+		return n
+	}
+
+	return &Namespace{c: c}
+}
+
+// FlushAccount invalidates all of the namespaced values cached for an account,
+// by removing its generation. A new generation is created when the account
+// next uses the cache.
+func FlushAccount(ctx context.Context, c Accessor, accountID string) error {
+	if c == nil {
+		return nil
+	}
+
+	if err := c.Delete(ctx, KeyAccountGeneration(accountID)); err != nil &&
+		!errors.Has(err, errors.ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// generation retrieves the generation of the values cached for an account,
+// creating a new generation if it is missing.
+func (n *Namespace) generation(ctx context.Context,
+	accountID string,
+) (string, error) {
+	ck := KeyAccountGeneration(accountID)
+
+	item, err := n.c.Get(ctx, ck)
+	if err != nil && !errors.Has(err, errors.ErrNotFound) {
+		return "", err
+	}
+
+	if item != nil && len(item.Value) > 0 {
+		return string(item.Value), nil
+	}
+
+	// Generations are random, rather than counted, so that values cached for
+	// a flushed generation are never used again, even if the generation is
+	// evicted from the cache.
+	gen := uuid.NewString()
+
+	if err := n.c.Set(ctx, &Item{Key: ck, Value: []byte(gen)}); err != nil {
+		return "", err
+	}
+
+	return gen, nil
+}
+
+// key returns the namespaced key for a key.
+func (n *Namespace) key(ctx context.Context, key string) (string, error) {
+	if strings.HasPrefix(key, accountKeyPrefix) {
+		return key, nil
+	}
+
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil || accountID == "" {
+		return key, nil
+	}
+
+	gen, err := n.generation(ctx, accountID)
+	if err != nil {
+		return "", errors.Wrap(err, errors.ErrCache,
+			"unable to get account cache generation",
+			"account_id", accountID)
+	}
+
+	return accountKeyPrefix + accountID + "::" + gen + "::" + key, nil
+}
+
+// Get attempts to retrieve the value of the specified key.
+func (n *Namespace) Get(ctx context.Context, key string) (*Item, error) {
+	ck, err := n.key(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := n.c.Get(ctx, ck)
+	if err != nil || item == nil {
+		return item, err
+	}
+
+	return &Item{Key: key, Value: item.Value, Expiration: item.Expiration}, nil
+}
+
+// GetMulti attempts to retrieve a map of the values of the specified keys.
+func (n *Namespace) GetMulti(ctx context.Context,
+	keys ...string,
+) (map[string]*Item, error) {
+	cks := make([]string, len(keys))
+
+	index := make(map[string]string, len(keys))
+
+	for i, key := range keys {
+		ck, err := n.key(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+
+		cks[i], index[ck] = ck, key
+	}
+
+	items, err := n.c.GetMulti(ctx, cks...)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]*Item, len(items))
+
+	for ck, item := range items {
+		key, ok := index[ck]
+		if !ok || item == nil {
+			continue
+		}
+
+		res[key] = &Item{
+			Key:        key,
+			Value:      item.Value,
+			Expiration: item.Expiration,
+		}
+	}
+
+	return res, nil
+}
+
+// Set attempts to set the value of the specified key.
+func (n *Namespace) Set(ctx context.Context, item *Item) error {
+	if item == nil {
+		return errors.New(errors.ErrCache,
+			"unable to cache null item")
+	}
+
+	ck, err := n.key(ctx, item.Key)
+	if err != nil {
+		return err
+	}
+
+	return n.c.Set(ctx, &Item{
+		Key:        ck,
+		Value:      item.Value,
+		Expiration: item.Expiration,
+	})
+}
+
+// Delete attempts to remove the value of the specified key.
+func (n *Namespace) Delete(ctx context.Context, key string) error {
+	ck, err := n.key(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return n.c.Delete(ctx, ck)
+}
@@ -0,0 +1,106 @@
+package cache_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+func TestNamespace(t *testing.T) {
+	t.Parallel()
+
+	mc := &cache.MockCache{}
+
+	c := cache.NewNamespace(mc)
+
+	ctx := request.WithAccountID(context.Background(), "test")
+
+	ck := cache.KeyResource("test")
+
+	if err := c.Set(ctx, &cache.Item{
+		Key:   ck,
+		Value: []byte("test"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := mc.Items()[ck]; ok {
+		t.Errorf("Expected namespaced key, got: %v", ck)
+	}
+
+	namespaced := ""
+
+	for k := range mc.Items() {
+		if strings.HasPrefix(k, "Account::test::") &&
+			strings.HasSuffix(k, "::"+ck) {
+			namespaced = k
+		}
+	}
+
+	if namespaced == "" {
+		t.Fatalf("Expected namespaced key for: %v, got: %v", ck, mc.Items())
+	}
+
+	item, err := c.Get(ctx, ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if item.Key != ck || string(item.Value) != "test" {
+		t.Errorf("Expected item: %v test, got: %v %v", ck, item.Key,
+			string(item.Value))
+	}
+
+	items, err := c.GetMulti(ctx, ck)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := items[ck]; !ok {
+		t.Errorf("Expected item for key: %v, got: %v", ck, items)
+	}
+
+	if _, err := c.Get(request.WithAccountID(ctx, "other"),
+		ck); !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected error: %v, got: %v", errors.ErrNotFound, err)
+	}
+
+	if err := cache.FlushAccount(ctx, c, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Get(ctx, ck); !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected error: %v, got: %v", errors.ErrNotFound, err)
+	}
+
+	if err := c.Set(ctx, &cache.Item{
+		Key:   cache.KeyAccount("test"),
+		Value: []byte("test"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := mc.Items()[cache.KeyAccount("test")]; !ok {
+		t.Errorf("Expected account key not namespaced: %v", mc.Items())
+	}
+
+	if err := c.Set(context.Background(), &cache.Item{
+		Key:   "test",
+		Value: []byte("test"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := mc.Items()["test"]; !ok {
+		t.Errorf("Expected key without account not namespaced: %v",
+			mc.Items())
+	}
+
+	if cache.NewNamespace(nil) != nil {
+		t.Error("Expected nil accessor")
+	}
+}
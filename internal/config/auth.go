@@ -6,6 +6,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,6 +23,21 @@ const (
 	KeyAuthTokenIssuer           = "auth/token/issuer"
 	KeyAuthUpdateInterval        = "auth/update_interval"
 	KeyAuthIdentityDomain        = "auth/identity_domain"
+	KeyAuthTokenUsageInterval    = "auth/token/usage_interval"
+	KeyAuthLocalEnabled          = "auth/local/enabled"
+	KeyAuthLocalMaxAttempts      = "auth/local/max_attempts"
+	KeyAuthLocalLockout          = "auth/local/lockout"
+	KeyAuthLocalRateLimit        = "auth/local/rate_limit"
+	KeyAuthAccountPepper         = "auth/account_pepper"
+	KeyAuthTokenKeyGrace         = "auth/token/key_grace"
+	KeyAuthTokenKeyRotation      = "auth/token/key_rotation"
+	KeyAuthTokenIssuers          = "auth/token/issuers"
+	KeyAuthTokenAudiences        = "auth/token/audiences"
+	KeyAuthTokenLeeway           = "auth/token/leeway"
+	KeyAuthFailureThreshold      = "auth/failure/threshold"
+	KeyAuthFailureWindow         = "auth/failure/window"
+	KeyAuthFailureLockout        = "auth/failure/lockout"
+	KeyAuthFailureMaxLockout     = "auth/failure/max_lockout"
 
 	DefaultAuthTokenJWKS             = "{}"
 	DefaultAuthTokenWellKnown        = ""
@@ -29,6 +46,20 @@ const (
 	DefaultAuthTokenIssuer           = "api"
 	DefaultAuthUpdateInterval        = time.Second * 30
 	DefaultAuthIdentityDomain        = ""
+	DefaultAuthTokenUsageInterval    = time.Minute
+	DefaultAuthLocalEnabled          = false
+	DefaultAuthLocalMaxAttempts      = 5
+	DefaultAuthLocalLockout          = time.Minute * 15
+	DefaultAuthLocalRateLimit        = 10
+	DefaultAuthTokenKeyGrace         = time.Hour * 24
+	DefaultAuthTokenKeyRotation      = time.Duration(0)
+	DefaultAuthTokenIssuers          = ""
+	DefaultAuthTokenAudiences        = ""
+	DefaultAuthTokenLeeway           = time.Second * 30
+	DefaultAuthFailureThreshold      = 20
+	DefaultAuthFailureWindow         = time.Minute * 10
+	DefaultAuthFailureLockout        = time.Minute
+	DefaultAuthFailureMaxLockout     = time.Hour
 )
 
 // AuthConfig values represent authentication configuration data.
@@ -43,6 +74,21 @@ type AuthConfig struct {
 	TokenIssuer           string        `json:"token_issuer,omitempty"             yaml:"token_issuer,omitempty"`
 	UpdateInterval        time.Duration `json:"update_interval,omitempty"          yaml:"update_interval,omitempty"`
 	IdentityDomain        string        `json:"identity_domain,omitempty"          yaml:"identity_domain,omitempty"`
+	TokenUsageInterval    time.Duration `json:"token_usage_interval,omitempty"     yaml:"token_usage_interval,omitempty"`
+	LocalEnabled          bool          `json:"local_enabled,omitempty"            yaml:"local_enabled,omitempty"`
+	LocalMaxAttempts      int           `json:"local_max_attempts,omitempty"       yaml:"local_max_attempts,omitempty"`
+	LocalLockout          time.Duration `json:"local_lockout,omitempty"            yaml:"local_lockout,omitempty"`
+	LocalRateLimit        int           `json:"local_rate_limit,omitempty"         yaml:"local_rate_limit,omitempty"`
+	AccountPepper         []byte        `json:"account_pepper,omitempty"           yaml:"account_pepper,omitempty"`
+	TokenKeyGrace         time.Duration `json:"token_key_grace,omitempty"          yaml:"token_key_grace,omitempty"`
+	TokenKeyRotation      time.Duration `json:"token_key_rotation,omitempty"       yaml:"token_key_rotation,omitempty"`
+	TokenIssuers          string        `json:"token_issuers,omitempty"            yaml:"token_issuers,omitempty"`
+	TokenAudiences        string        `json:"token_audiences,omitempty"          yaml:"token_audiences,omitempty"`
+	TokenLeeway           time.Duration `json:"token_leeway,omitempty"             yaml:"token_leeway,omitempty"`
+	FailureThreshold      int           `json:"failure_threshold,omitempty"        yaml:"failure_threshold,omitempty"`
+	FailureWindow         time.Duration `json:"failure_window,omitempty"           yaml:"failure_window,omitempty"`
+	FailureLockout        time.Duration `json:"failure_lockout,omitempty"          yaml:"failure_lockout,omitempty"`
+	FailureMaxLockout     time.Duration `json:"failure_max_lockout,omitempty"      yaml:"failure_max_lockout,omitempty"`
 }
 
 // Load reads configuration data from environment variables and applies defaults
@@ -144,6 +190,166 @@ func (c *AuthConfig) Load() {
 	if c.IdentityDomain == "" {
 		c.IdentityDomain = DefaultAuthIdentityDomain
 	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthTokenUsageInterval)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthTokenUsageInterval
+		}
+
+		c.TokenUsageInterval = v
+	}
+
+	if c.TokenUsageInterval <= 0 {
+		c.TokenUsageInterval = DefaultAuthTokenUsageInterval
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthLocalEnabled)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultAuthLocalEnabled
+		}
+
+		c.LocalEnabled = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthLocalMaxAttempts)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultAuthLocalMaxAttempts
+		}
+
+		c.LocalMaxAttempts = int(v)
+	}
+
+	if c.LocalMaxAttempts == 0 {
+		c.LocalMaxAttempts = DefaultAuthLocalMaxAttempts
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthLocalLockout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthLocalLockout
+		}
+
+		c.LocalLockout = v
+	}
+
+	if c.LocalLockout <= 0 {
+		c.LocalLockout = DefaultAuthLocalLockout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthLocalRateLimit)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultAuthLocalRateLimit
+		}
+
+		c.LocalRateLimit = int(v)
+	}
+
+	if c.LocalRateLimit == 0 {
+		c.LocalRateLimit = DefaultAuthLocalRateLimit
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthAccountPepper)); v != "" {
+		c.AccountPepper = []byte(v)
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthTokenKeyGrace)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthTokenKeyGrace
+		}
+
+		c.TokenKeyGrace = v
+	}
+
+	if c.TokenKeyGrace <= 0 {
+		c.TokenKeyGrace = DefaultAuthTokenKeyGrace
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthTokenKeyRotation)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthTokenKeyRotation
+		}
+
+		c.TokenKeyRotation = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthTokenIssuers)); v != "" {
+		c.TokenIssuers = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthTokenAudiences)); v != "" {
+		c.TokenAudiences = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthTokenLeeway)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthTokenLeeway
+		}
+
+		c.TokenLeeway = v
+	}
+
+	if c.TokenLeeway == 0 {
+		c.TokenLeeway = DefaultAuthTokenLeeway
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthFailureThreshold)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultAuthFailureThreshold
+		}
+
+		c.FailureThreshold = int(v)
+	}
+
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = DefaultAuthFailureThreshold
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthFailureWindow)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthFailureWindow
+		}
+
+		c.FailureWindow = v
+	}
+
+	if c.FailureWindow <= 0 {
+		c.FailureWindow = DefaultAuthFailureWindow
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthFailureLockout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthFailureLockout
+		}
+
+		c.FailureLockout = v
+	}
+
+	if c.FailureLockout <= 0 {
+		c.FailureLockout = DefaultAuthFailureLockout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAuthFailureMaxLockout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAuthFailureMaxLockout
+		}
+
+		c.FailureMaxLockout = v
+	}
+
+	if c.FailureMaxLockout <= 0 {
+		c.FailureMaxLockout = DefaultAuthFailureMaxLockout
+	}
 }
 
 // AuthTokenHMACKey returns the HMAC key used for token encryption.
@@ -314,3 +520,240 @@ func (c *Config) SetAuthTokenJWKS(jwks map[string]*rsa.PublicKey) {
 
 	c.auth.TokenJWKS = buf.String()
 }
+
+// AuthTokenUsageInterval returns the frequency at which the uses of tokens,
+// recorded by the service instance, are written to the database.
+func (c *Config) AuthTokenUsageInterval() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.TokenUsageInterval <= 0 {
+		return DefaultAuthTokenUsageInterval
+	}
+
+	return c.auth.TokenUsageInterval
+}
+
+// AuthLocalEnabled returns whether local authentication, using the passwords
+// of users stored by the service, is enabled.
+func (c *Config) AuthLocalEnabled() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil {
+		return DefaultAuthLocalEnabled
+	}
+
+	return c.auth.LocalEnabled
+}
+
+// AuthLocalMaxAttempts returns the number of consecutive failed password
+// authentication attempts after which a user is locked out. If it is
+// negative, users are never locked out.
+func (c *Config) AuthLocalMaxAttempts() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.LocalMaxAttempts == 0 {
+		return DefaultAuthLocalMaxAttempts
+	}
+
+	return c.auth.LocalMaxAttempts
+}
+
+// AuthLocalLockout returns how long a user is locked out for, after too many
+// failed password authentication attempts.
+func (c *Config) AuthLocalLockout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.LocalLockout <= 0 {
+		return DefaultAuthLocalLockout
+	}
+
+	return c.auth.LocalLockout
+}
+
+// AuthLocalRateLimit returns the maximum number of password authentication
+// requests accepted from a single client address each minute. If it is
+// negative, requests are not limited.
+func (c *Config) AuthLocalRateLimit() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.LocalRateLimit == 0 {
+		return DefaultAuthLocalRateLimit
+	}
+
+	return c.auth.LocalRateLimit
+}
+
+// AuthAccountPepper returns the pepper combined with each account secret to
+// derive the key used to sign the tokens of the account, or none, if account
+// secrets are used as the key.
+func (c *Config) AuthAccountPepper() []byte {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil {
+		return nil
+	}
+
+	return c.auth.AccountPepper
+}
+
+// AuthTokenKeyGrace returns how long tokens signed using a replaced account
+// signing key remain valid after the key is rotated.
+func (c *Config) AuthTokenKeyGrace() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.TokenKeyGrace <= 0 {
+		return DefaultAuthTokenKeyGrace
+	}
+
+	return c.auth.TokenKeyGrace
+}
+
+// AuthTokenKeyRotation returns the age at which account signing keys are
+// automatically rotated. If it is zero, or negative, keys are only rotated on
+// request.
+func (c *Config) AuthTokenKeyRotation() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil {
+		return DefaultAuthTokenKeyRotation
+	}
+
+	return c.auth.TokenKeyRotation
+}
+
+// AuthTokenIssuers returns the issuers accepted in the iss claim of tokens.
+// These are the issuer of the tokens created by the service, the identity
+// provider, if one is configured, and any other configured issuers.
+func (c *Config) AuthTokenIssuers() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	res := []string{DefaultAuthTokenIssuer}
+
+	if c.auth == nil {
+		return res
+	}
+
+	if c.auth.TokenIssuer != "" {
+		res[0] = c.auth.TokenIssuer
+	}
+
+	if c.auth.IdentityDomain != "" {
+		res = append(res, "https://"+c.auth.IdentityDomain+"/")
+	}
+
+	for _, v := range strings.Split(c.auth.TokenIssuers, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
+
+// AuthTokenAudiences returns the audiences accepted in the aud claim of
+// tokens, one of which must be present. If none are configured, only the
+// service name, which is the audience of the tokens created by the service, is
+// accepted.
+func (c *Config) AuthTokenAudiences() []string {
+	c.RLock()
+
+	v := DefaultAuthTokenAudiences
+
+	if c.auth != nil {
+		v = c.auth.TokenAudiences
+	}
+
+	c.RUnlock()
+
+	res := []string{}
+
+	for _, a := range strings.Split(v, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			res = append(res, a)
+		}
+	}
+
+	if len(res) == 0 {
+		res = append(res, c.ServiceName())
+	}
+
+	return res
+}
+
+// AuthTokenLeeway returns the clock skew tolerated when validating the times
+// in the exp, nbf and iat claims of tokens. If it is negative, no clock skew is
+// tolerated.
+func (c *Config) AuthTokenLeeway() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.TokenLeeway == 0 {
+		return DefaultAuthTokenLeeway
+	}
+
+	return max(c.auth.TokenLeeway, 0)
+}
+
+// AuthFailureThreshold returns the number of failed authentication attempts,
+// from a client address or for an account, within the failure window, after
+// which further attempts are locked out. If it is negative, failed attempts
+// are never locked out.
+func (c *Config) AuthFailureThreshold() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.FailureThreshold == 0 {
+		return DefaultAuthFailureThreshold
+	}
+
+	return c.auth.FailureThreshold
+}
+
+// AuthFailureWindow returns the period over which failed authentication
+// attempts are counted.
+func (c *Config) AuthFailureWindow() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.FailureWindow <= 0 {
+		return DefaultAuthFailureWindow
+	}
+
+	return c.auth.FailureWindow
+}
+
+// AuthFailureLockout returns how long authentication attempts are first locked
+// out for, after too many failures. Each further lockout, before the failures
+// have expired, is twice as long as the last.
+func (c *Config) AuthFailureLockout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.FailureLockout <= 0 {
+		return DefaultAuthFailureLockout
+	}
+
+	return c.auth.FailureLockout
+}
+
+// AuthFailureMaxLockout returns the longest time for which authentication
+// attempts are locked out.
+func (c *Config) AuthFailureMaxLockout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.auth == nil || c.auth.FailureMaxLockout <= 0 {
+		return DefaultAuthFailureMaxLockout
+	}
+
+	return c.auth.FailureMaxLockout
+}
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"crypto/rsa"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,6 +27,18 @@ func TestAuthConfig(t *testing.T) {
 		TokenIssuer:           exp,
 		UpdateInterval:        time.Second,
 		IdentityDomain:        exp,
+		TokenUsageInterval:    time.Second * 5,
+		LocalEnabled:          true,
+		LocalMaxAttempts:      3,
+		LocalLockout:          time.Minute,
+		LocalRateLimit:        -1,
+		TokenKeyGrace:         time.Hour,
+		TokenKeyRotation:      time.Hour * 24,
+		TokenIssuers:          "https://issuer.test/, other",
+		TokenLeeway:           -1,
+		FailureThreshold:      -1,
+		FailureWindow:         time.Minute,
+		FailureLockout:        time.Second * 10,
 	})
 
 	cfg.SetAuthTokenJWKS(map[string]*rsa.PublicKey{})
@@ -70,4 +83,73 @@ func TestAuthConfig(t *testing.T) {
 		t.Errorf("Expected identity domain: %v, got: %v",
 			exp, cfg.AuthIdentityDomain())
 	}
+
+	if cfg.AuthTokenUsageInterval() != 5*time.Second {
+		t.Errorf("Expected token usage interval: 5s, got: %v",
+			cfg.AuthTokenUsageInterval())
+	}
+
+	if !cfg.AuthLocalEnabled() {
+		t.Error("Expected local authentication enabled")
+	}
+
+	if cfg.AuthLocalMaxAttempts() != 3 {
+		t.Errorf("Expected local max attempts: 3, got: %v",
+			cfg.AuthLocalMaxAttempts())
+	}
+
+	if cfg.AuthLocalLockout() != time.Minute {
+		t.Errorf("Expected local lockout: 1m, got: %v",
+			cfg.AuthLocalLockout())
+	}
+
+	if cfg.AuthLocalRateLimit() != -1 {
+		t.Errorf("Expected local rate limit: -1, got: %v",
+			cfg.AuthLocalRateLimit())
+	}
+
+	if cfg.AuthTokenKeyGrace() != time.Hour {
+		t.Errorf("Expected token key grace: 1h, got: %v",
+			cfg.AuthTokenKeyGrace())
+	}
+
+	if v := cfg.AuthTokenIssuers(); strings.Join(v, " ") !=
+		exp+" https://"+exp+"/ https://issuer.test/ other" {
+		t.Errorf("Expected token issuers: %v, got: %v", exp, v)
+	}
+
+	if v := cfg.AuthTokenAudiences(); len(v) != 1 ||
+		v[0] != cfg.ServiceName() {
+		t.Errorf("Expected token audiences: %v, got: %v",
+			cfg.ServiceName(), v)
+	}
+
+	if cfg.AuthTokenLeeway() != 0 {
+		t.Errorf("Expected token leeway: 0, got: %v", cfg.AuthTokenLeeway())
+	}
+
+	if cfg.AuthTokenKeyRotation() != 24*time.Hour {
+		t.Errorf("Expected token key rotation: 24h, got: %v",
+			cfg.AuthTokenKeyRotation())
+	}
+
+	if cfg.AuthFailureThreshold() != -1 {
+		t.Errorf("Expected failure threshold: -1, got: %v",
+			cfg.AuthFailureThreshold())
+	}
+
+	if cfg.AuthFailureWindow() != time.Minute {
+		t.Errorf("Expected failure window: 1m, got: %v",
+			cfg.AuthFailureWindow())
+	}
+
+	if cfg.AuthFailureLockout() != 10*time.Second {
+		t.Errorf("Expected failure lockout: 10s, got: %v",
+			cfg.AuthFailureLockout())
+	}
+
+	if cfg.AuthFailureMaxLockout() != config.DefaultAuthFailureMaxLockout {
+		t.Errorf("Expected failure max lockout: %v, got: %v",
+			config.DefaultAuthFailureMaxLockout, cfg.AuthFailureMaxLockout())
+	}
 }
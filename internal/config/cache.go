@@ -8,31 +8,49 @@ import (
 )
 
 const (
-	KeyCacheType       = "cache/type"
-	KeyCacheServers    = "cache/servers"
-	KeyCacheDiscovery  = "cache/discovery"
-	KeyCacheTimeout    = "cache/timeout"
-	KeyCacheExpiration = "cache/expiration"
-	KeyCacheMaxBytes   = "cache/max_bytes"
-	KeyCachePoolSize   = "cache/pool_size"
-
-	DefaultCacheType       = "redis"
-	DefaultCacheDiscovery  = false
-	DefaultCacheTimeout    = time.Second
-	DefaultCacheExpiration = time.Minute * 5
-	DefaultCacheMaxBytes   = 1048576
-	DefaultCachePoolSize   = 10
+	KeyCacheType             = "cache/type"
+	KeyCacheServers          = "cache/servers"
+	KeyCacheDiscovery        = "cache/discovery"
+	KeyCacheCluster          = "cache/cluster"
+	KeyCacheTimeout          = "cache/timeout"
+	KeyCacheExpiration       = "cache/expiration"
+	KeyCacheMaxBytes         = "cache/max_bytes"
+	KeyCacheCompressBytes    = "cache/compress_bytes"
+	KeyCachePoolSize         = "cache/pool_size"
+	KeyCacheLocalExpiration  = "cache/local_expiration"
+	KeyCacheLocalMaxItems    = "cache/local_max_items"
+	KeyCacheBreakerThreshold = "cache/breaker_threshold"
+	KeyCacheBreakerTimeout   = "cache/breaker_timeout"
+
+	DefaultCacheType             = "redis"
+	DefaultCacheDiscovery        = false
+	DefaultCacheCluster          = false
+	DefaultCacheTimeout          = time.Second
+	DefaultCacheExpiration       = time.Minute * 5
+	DefaultCacheMaxBytes         = 1048576
+	DefaultCacheCompressBytes    = 4096
+	DefaultCachePoolSize         = 10
+	DefaultCacheLocalExpiration  = time.Duration(0)
+	DefaultCacheLocalMaxItems    = 10000
+	DefaultCacheBreakerThreshold = 5
+	DefaultCacheBreakerTimeout   = time.Second * 30
 )
 
 // CacheConfig values represent cache configuration data.
 type CacheConfig struct {
-	Type       string        `json:"type,omitempty"       yaml:"type,omitempty"`
-	Servers    []string      `json:"servers,omitempty"    yaml:"servers,omitempty"`
-	Discovery  bool          `json:"discovery,omitempty"  yaml:"discovery,omitempty"`
-	Timeout    time.Duration `json:"timeout,omitempty"    yaml:"timeout,omitempty"`
-	Expiration time.Duration `json:"expiration,omitempty" yaml:"expiration,omitempty"`
-	MaxBytes   int           `json:"max_bytes,omitempty"  yaml:"max_bytes,omitempty"`
-	PoolSize   int           `json:"pool_size,omitempty"  yaml:"pool_size,omitempty"`
+	Type             string        `json:"type,omitempty"              yaml:"type,omitempty"`
+	Servers          []string      `json:"servers,omitempty"           yaml:"servers,omitempty"`
+	Discovery        bool          `json:"discovery,omitempty"         yaml:"discovery,omitempty"`
+	Cluster          bool          `json:"cluster,omitempty"           yaml:"cluster,omitempty"`
+	Timeout          time.Duration `json:"timeout,omitempty"           yaml:"timeout,omitempty"`
+	Expiration       time.Duration `json:"expiration,omitempty"        yaml:"expiration,omitempty"`
+	MaxBytes         int           `json:"max_bytes,omitempty"         yaml:"max_bytes,omitempty"`
+	CompressBytes    int           `json:"compress_bytes,omitempty"    yaml:"compress_bytes,omitempty"`
+	PoolSize         int           `json:"pool_size,omitempty"         yaml:"pool_size,omitempty"`
+	LocalExpiration  time.Duration `json:"local_expiration,omitempty"  yaml:"local_expiration,omitempty"`
+	LocalMaxItems    int           `json:"local_max_items,omitempty"   yaml:"local_max_items,omitempty"`
+	BreakerThreshold int           `json:"breaker_threshold,omitempty" yaml:"breaker_threshold,omitempty"`
+	BreakerTimeout   time.Duration `json:"breaker_timeout,omitempty"   yaml:"breaker_timeout,omitempty"`
 }
 
 // Load reads configuration data from environment variables and applies defaults
@@ -63,6 +81,15 @@ func (c *CacheConfig) Load() {
 		c.Discovery = v
 	}
 
+	if v := os.Getenv(ReplaceEnv(KeyCacheCluster)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultCacheCluster
+		}
+
+		c.Cluster = v
+	}
+
 	if v := os.Getenv(ReplaceEnv(KeyCacheTimeout)); v != "" {
 		v, err := time.ParseDuration(v)
 		if err != nil {
@@ -102,6 +129,19 @@ func (c *CacheConfig) Load() {
 		c.MaxBytes = DefaultCacheMaxBytes
 	}
 
+	if v := os.Getenv(ReplaceEnv(KeyCacheCompressBytes)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultCacheCompressBytes
+		}
+
+		c.CompressBytes = int(v)
+	}
+
+	if c.CompressBytes == 0 {
+		c.CompressBytes = DefaultCacheCompressBytes
+	}
+
 	if v := os.Getenv(ReplaceEnv(KeyCachePoolSize)); v != "" {
 		v, err := strconv.ParseInt(v, 10, 32)
 		if err != nil {
@@ -114,6 +154,54 @@ func (c *CacheConfig) Load() {
 	if c.PoolSize == 0 {
 		c.PoolSize = DefaultCachePoolSize
 	}
+
+	if v := os.Getenv(ReplaceEnv(KeyCacheLocalExpiration)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil || v < 0 {
+			v = DefaultCacheLocalExpiration
+		}
+
+		c.LocalExpiration = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyCacheLocalMaxItems)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultCacheLocalMaxItems
+		}
+
+		c.LocalMaxItems = int(v)
+	}
+
+	if c.LocalMaxItems <= 0 {
+		c.LocalMaxItems = DefaultCacheLocalMaxItems
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyCacheBreakerThreshold)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultCacheBreakerThreshold
+		}
+
+		c.BreakerThreshold = int(v)
+	}
+
+	if c.BreakerThreshold == 0 {
+		c.BreakerThreshold = DefaultCacheBreakerThreshold
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyCacheBreakerTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil || v <= 0 {
+			v = DefaultCacheBreakerTimeout
+		}
+
+		c.BreakerTimeout = v
+	}
+
+	if c.BreakerTimeout <= 0 {
+		c.BreakerTimeout = DefaultCacheBreakerTimeout
+	}
 }
 
 // CacheType returns the type of cache service used.
@@ -152,6 +240,19 @@ func (c *Config) CacheDiscovery() bool {
 	return c.cache.Discovery
 }
 
+// CacheCluster returns whether the cache servers are the seed addresses of a
+// redis cluster.
+func (c *Config) CacheCluster() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.cache == nil {
+		return DefaultCacheCluster
+	}
+
+	return c.cache.Cluster
+}
+
 // CacheTimeout returns the timeout duration used for cache requests.
 func (c *Config) CacheTimeout() time.Duration {
 	c.RLock()
@@ -188,6 +289,19 @@ func (c *Config) CacheMaxBytes() int {
 	return c.cache.MaxBytes
 }
 
+// CacheCompressBytes returns the size, in bytes, at and above which cache item
+// values are compressed. If negative, values are not compressed.
+func (c *Config) CacheCompressBytes() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.cache == nil {
+		return DefaultCacheCompressBytes
+	}
+
+	return c.cache.CompressBytes
+}
+
 // CachePoolSize returns the maximum pool size for cache connections.
 func (c *Config) CachePoolSize() int {
 	c.RLock()
@@ -199,3 +313,57 @@ func (c *Config) CachePoolSize() int {
 
 	return c.cache.PoolSize
 }
+
+// CacheLocalExpiration returns the expiration of items held in the in-memory
+// cache tier of each service instance. If zero, the in-memory tier is
+// disabled.
+func (c *Config) CacheLocalExpiration() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.cache == nil {
+		return DefaultCacheLocalExpiration
+	}
+
+	return c.cache.LocalExpiration
+}
+
+// CacheLocalMaxItems returns the maximum number of items held in the in-memory
+// cache tier of each service instance.
+func (c *Config) CacheLocalMaxItems() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.cache == nil || c.cache.LocalMaxItems <= 0 {
+		return DefaultCacheLocalMaxItems
+	}
+
+	return c.cache.LocalMaxItems
+}
+
+// CacheBreakerThreshold returns the number of consecutive failed cache
+// operations after which the cache is not used until the breaker timeout has
+// elapsed. If it is negative, the cache is always used.
+func (c *Config) CacheBreakerThreshold() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.cache == nil || c.cache.BreakerThreshold == 0 {
+		return DefaultCacheBreakerThreshold
+	}
+
+	return c.cache.BreakerThreshold
+}
+
+// CacheBreakerTimeout returns how long the cache is not used, once its circuit
+// breaker has opened.
+func (c *Config) CacheBreakerTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.cache == nil || c.cache.BreakerTimeout <= 0 {
+		return DefaultCacheBreakerTimeout
+	}
+
+	return c.cache.BreakerTimeout
+}
@@ -15,13 +15,19 @@ func TestCacheConfig(t *testing.T) {
 	cfg.Load(nil)
 
 	cfg.SetCache(&config.CacheConfig{
-		Type:       "memcache",
-		Servers:    []string{"test", "test2"},
-		Discovery:  true,
-		Timeout:    time.Second * 5,
-		Expiration: time.Second * 10,
-		MaxBytes:   1024,
-		PoolSize:   1,
+		Type:             "memcache",
+		Servers:          []string{"test", "test2"},
+		Discovery:        true,
+		Cluster:          true,
+		Timeout:          time.Second * 5,
+		Expiration:       time.Second * 10,
+		MaxBytes:         1024,
+		CompressBytes:    -1,
+		PoolSize:         1,
+		LocalExpiration:  time.Second,
+		LocalMaxItems:    5,
+		BreakerThreshold: 3,
+		BreakerTimeout:   time.Minute,
 	})
 
 	if cfg.CacheType() != "memcache" {
@@ -36,6 +42,10 @@ func TestCacheConfig(t *testing.T) {
 		t.Errorf("Expected cache discovery, got: %v", cfg.CacheDiscovery())
 	}
 
+	if !cfg.CacheCluster() {
+		t.Errorf("Expected cache cluster, got: %v", cfg.CacheCluster())
+	}
+
 	if cfg.CacheTimeout() != (time.Second * 5) {
 		t.Errorf("Expected cache timeout: 5s, got: %v", cfg.CacheTimeout())
 	}
@@ -49,7 +59,32 @@ func TestCacheConfig(t *testing.T) {
 		t.Errorf("Expected cache max bytes: 1024, got: %v", cfg.CacheMaxBytes())
 	}
 
+	if cfg.CacheCompressBytes() != -1 {
+		t.Errorf("Expected cache compress bytes: -1, got: %v",
+			cfg.CacheCompressBytes())
+	}
+
 	if cfg.CachePoolSize() != 1 {
 		t.Errorf("Expected cache pool size: 1, got: %v", cfg.CachePoolSize())
 	}
+
+	if cfg.CacheLocalExpiration() != time.Second {
+		t.Errorf("Expected cache local expiration: 1s, got: %v",
+			cfg.CacheLocalExpiration())
+	}
+
+	if cfg.CacheLocalMaxItems() != 5 {
+		t.Errorf("Expected cache local max items: 5, got: %v",
+			cfg.CacheLocalMaxItems())
+	}
+
+	if cfg.CacheBreakerThreshold() != 3 {
+		t.Errorf("Expected cache breaker threshold: 3, got: %v",
+			cfg.CacheBreakerThreshold())
+	}
+
+	if cfg.CacheBreakerTimeout() != time.Minute {
+		t.Errorf("Expected cache breaker timeout: 1m, got: %v",
+			cfg.CacheBreakerTimeout())
+	}
 }
@@ -0,0 +1,460 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	KeyClientTimeout          = "client/timeout"
+	KeyClientMaxRetries       = "client/max_retries"
+	KeyClientRetryWait        = "client/retry_wait"
+	KeyClientMaxIdleConns     = "client/max_idle_conns"
+	KeyClientIdleConnTimeout  = "client/idle_conn_timeout"
+	KeyClientBreakerThreshold = "client/breaker_threshold"
+	KeyClientBreakerTimeout   = "client/breaker_timeout"
+	KeyClientProxy            = "client/proxy"
+	KeyClientProxies          = "client/proxies"
+	KeyClientNoProxy          = "client/no_proxy"
+	KeyClientCAFiles          = "client/ca_files"
+	KeyClientDNSTTL           = "client/dns_ttl"
+	KeyClientDialTimeout      = "client/dial_timeout"
+	KeyClientDialNetwork      = "client/dial_network"
+	KeyClientFallbackDelay    = "client/fallback_delay"
+	KeyClientAllowedNetworks  = "client/allowed_networks"
+
+	DefaultClientTimeout          = time.Second * 10
+	DefaultClientMaxRetries       = 3
+	DefaultClientRetryWait        = time.Millisecond * 250
+	DefaultClientMaxIdleConns     = 100
+	DefaultClientIdleConnTimeout  = time.Second * 90
+	DefaultClientBreakerThreshold = 5
+	DefaultClientBreakerTimeout   = time.Second * 30
+	DefaultClientDNSTTL           = time.Minute
+	DefaultClientDialTimeout      = time.Second * 30
+	DefaultClientDialNetwork      = "tcp"
+	DefaultClientFallbackDelay    = time.Millisecond * 300
+)
+
+// ClientConfig values represent outgoing HTTP client configuration data.
+type ClientConfig struct {
+	Timeout          time.Duration     `json:"timeout,omitempty"           yaml:"timeout,omitempty"`
+	MaxRetries       int               `json:"max_retries,omitempty"       yaml:"max_retries,omitempty"`
+	RetryWait        time.Duration     `json:"retry_wait,omitempty"        yaml:"retry_wait,omitempty"`
+	MaxIdleConns     int               `json:"max_idle_conns,omitempty"    yaml:"max_idle_conns,omitempty"`
+	IdleConnTimeout  time.Duration     `json:"idle_conn_timeout,omitempty" yaml:"idle_conn_timeout,omitempty"`
+	BreakerThreshold int               `json:"breaker_threshold,omitempty" yaml:"breaker_threshold,omitempty"`
+	BreakerTimeout   time.Duration     `json:"breaker_timeout,omitempty"   yaml:"breaker_timeout,omitempty"`
+	Proxy            string            `json:"proxy,omitempty"             yaml:"proxy,omitempty"`
+	Proxies          map[string]string `json:"proxies,omitempty"           yaml:"proxies,omitempty"`
+	NoProxy          []string          `json:"no_proxy,omitempty"          yaml:"no_proxy,omitempty"`
+	CAFiles          []string          `json:"ca_files,omitempty"          yaml:"ca_files,omitempty"`
+	DNSTTL           time.Duration     `json:"dns_ttl,omitempty"           yaml:"dns_ttl,omitempty"`
+	DialTimeout      time.Duration     `json:"dial_timeout,omitempty"      yaml:"dial_timeout,omitempty"`
+	DialNetwork      string            `json:"dial_network,omitempty"      yaml:"dial_network,omitempty"`
+	FallbackDelay    time.Duration     `json:"fallback_delay,omitempty"    yaml:"fallback_delay,omitempty"`
+	AllowedNetworks  []string          `json:"allowed_networks,omitempty"  yaml:"allowed_networks,omitempty"`
+}
+
+// Load reads configuration data from environment variables and applies defaults
+// for any missing or invalid configuration data.
+func (c *ClientConfig) Load() {
+	if v := os.Getenv(ReplaceEnv(KeyClientTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClientTimeout
+		}
+
+		c.Timeout = v
+	}
+
+	if c.Timeout == 0 {
+		c.Timeout = DefaultClientTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientMaxRetries)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultClientMaxRetries
+		}
+
+		c.MaxRetries = int(v)
+	}
+
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultClientMaxRetries
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientRetryWait)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClientRetryWait
+		}
+
+		c.RetryWait = v
+	}
+
+	if c.RetryWait == 0 {
+		c.RetryWait = DefaultClientRetryWait
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientMaxIdleConns)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultClientMaxIdleConns
+		}
+
+		c.MaxIdleConns = int(v)
+	}
+
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = DefaultClientMaxIdleConns
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientIdleConnTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClientIdleConnTimeout
+		}
+
+		c.IdleConnTimeout = v
+	}
+
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = DefaultClientIdleConnTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientBreakerThreshold)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultClientBreakerThreshold
+		}
+
+		c.BreakerThreshold = int(v)
+	}
+
+	if c.BreakerThreshold == 0 {
+		c.BreakerThreshold = DefaultClientBreakerThreshold
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientBreakerTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClientBreakerTimeout
+		}
+
+		c.BreakerTimeout = v
+	}
+
+	if c.BreakerTimeout == 0 {
+		c.BreakerTimeout = DefaultClientBreakerTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientProxy)); v != "" {
+		c.Proxy = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientProxies)); v != "" {
+		c.Proxies = map[string]string{}
+
+		for _, p := range strings.Fields(v) {
+			if host, proxy, ok := strings.Cut(p, "="); ok && host != "" {
+				c.Proxies[host] = proxy
+			}
+		}
+	}
+
+	if c.Proxies == nil {
+		c.Proxies = map[string]string{}
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientNoProxy)); v != "" {
+		c.NoProxy = strings.Fields(v)
+	}
+
+	if c.NoProxy == nil {
+		c.NoProxy = []string{}
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientCAFiles)); v != "" {
+		c.CAFiles = strings.Fields(v)
+	}
+
+	if c.CAFiles == nil {
+		c.CAFiles = []string{}
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientDNSTTL)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClientDNSTTL
+		}
+
+		c.DNSTTL = v
+	}
+
+	if c.DNSTTL == 0 {
+		c.DNSTTL = DefaultClientDNSTTL
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientDialTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClientDialTimeout
+		}
+
+		c.DialTimeout = v
+	}
+
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DefaultClientDialTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientDialNetwork)); v != "" {
+		c.DialNetwork = v
+	}
+
+	switch c.DialNetwork {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		c.DialNetwork = DefaultClientDialNetwork
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientFallbackDelay)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClientFallbackDelay
+		}
+
+		c.FallbackDelay = v
+	}
+
+	if c.FallbackDelay == 0 {
+		c.FallbackDelay = DefaultClientFallbackDelay
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClientAllowedNetworks)); v != "" {
+		c.AllowedNetworks = strings.Fields(v)
+	}
+
+	if c.AllowedNetworks == nil {
+		c.AllowedNetworks = []string{}
+	}
+}
+
+// ClientTimeout returns the timeout used for each outgoing HTTP request
+// attempt.
+func (c *Config) ClientTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientTimeout
+	}
+
+	return c.client.Timeout
+}
+
+// ClientMaxRetries returns the maximum number of times a failed outgoing HTTP
+// request is retried.
+func (c *Config) ClientMaxRetries() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientMaxRetries
+	}
+
+	return c.client.MaxRetries
+}
+
+// ClientRetryWait returns the initial wait between outgoing HTTP request
+// retries. The wait is doubled for each subsequent retry.
+func (c *Config) ClientRetryWait() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientRetryWait
+	}
+
+	return c.client.RetryWait
+}
+
+// ClientMaxIdleConns returns the maximum number of idle connections kept in
+// the outgoing HTTP connection pool.
+func (c *Config) ClientMaxIdleConns() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientMaxIdleConns
+	}
+
+	return c.client.MaxIdleConns
+}
+
+// ClientIdleConnTimeout returns how long idle outgoing HTTP connections are
+// kept in the pool.
+func (c *Config) ClientIdleConnTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientIdleConnTimeout
+	}
+
+	return c.client.IdleConnTimeout
+}
+
+// ClientBreakerThreshold returns the number of consecutive failures to a host
+// after which outgoing HTTP requests to that host are rejected.
+func (c *Config) ClientBreakerThreshold() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientBreakerThreshold
+	}
+
+	return c.client.BreakerThreshold
+}
+
+// ClientBreakerTimeout returns how long outgoing HTTP requests to a host are
+// rejected, once its circuit breaker has opened.
+func (c *Config) ClientBreakerTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientBreakerTimeout
+	}
+
+	return c.client.BreakerTimeout
+}
+
+// ClientProxy returns the URL of the proxy used for outgoing HTTP requests. If
+// empty, the proxy is determined by the standard proxy environment variables.
+func (c *Config) ClientProxy() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return ""
+	}
+
+	return c.client.Proxy
+}
+
+// ClientProxies returns the proxies used for outgoing HTTP requests to
+// specific destinations, keyed by host. Keys beginning with a "." match any
+// subdomain. A value of "direct" indicates no proxy should be used.
+func (c *Config) ClientProxies() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return map[string]string{}
+	}
+
+	return maps.Clone(c.client.Proxies)
+}
+
+// ClientNoProxy returns the hosts to which outgoing HTTP requests are made
+// without using the configured proxy.
+func (c *Config) ClientNoProxy() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return []string{}
+	}
+
+	return c.client.NoProxy
+}
+
+// ClientCAFiles returns the paths of PEM encoded certificate authority bundle
+// files which are trusted, in addition to the system certificate pool, for
+// outgoing HTTPS requests.
+func (c *Config) ClientCAFiles() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return []string{}
+	}
+
+	return c.client.CAFiles
+}
+
+// ClientDNSTTL returns how long resolved host addresses are cached for outgoing
+// HTTP connections. A negative value disables caching.
+func (c *Config) ClientDNSTTL() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientDNSTTL
+	}
+
+	return c.client.DNSTTL
+}
+
+// ClientDialTimeout returns the timeout for opening each outgoing HTTP
+// connection.
+func (c *Config) ClientDialTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientDialTimeout
+	}
+
+	return c.client.DialTimeout
+}
+
+// ClientDialNetwork returns the network used for outgoing HTTP connections.
+// The value "tcp" uses both IPv4 and IPv6 addresses, while "tcp4" and "tcp6"
+// restrict connections to a single address family.
+func (c *Config) ClientDialNetwork() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil || c.client.DialNetwork == "" {
+		return DefaultClientDialNetwork
+	}
+
+	return c.client.DialNetwork
+}
+
+// ClientFallbackDelay returns how long to wait for a connection to a host
+// using the preferred address family, before also attempting a connection
+// using the other family. A negative value disables the fallback.
+func (c *Config) ClientFallbackDelay() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return DefaultClientFallbackDelay
+	}
+
+	return c.client.FallbackDelay
+}
+
+// ClientAllowedNetworks returns the networks, in CIDR notation, to which
+// outgoing HTTP requests to user supplied URLs, such as alert webhooks, are
+// allowed to connect, even though they are not public. Such requests are
+// otherwise only made to public addresses.
+func (c *Config) ClientAllowedNetworks() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.client == nil {
+		return []string{}
+	}
+
+	return c.client.AllowedNetworks
+}
@@ -0,0 +1,113 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+)
+
+func TestClientConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.New("")
+
+	cfg.Load(nil)
+
+	cfg.SetClient(&config.ClientConfig{
+		Timeout:          time.Second * 5,
+		MaxRetries:       2,
+		RetryWait:        time.Second,
+		MaxIdleConns:     10,
+		IdleConnTimeout:  time.Minute,
+		BreakerThreshold: 3,
+		BreakerTimeout:   time.Second * 20,
+		Proxy:            "http://proxy:3128",
+		Proxies:          map[string]string{"github.com": "direct"},
+		NoProxy:          []string{".internal"},
+		CAFiles:          []string{"/etc/ca.pem"},
+		DNSTTL:           time.Second * 30,
+		DialTimeout:      time.Second * 2,
+		DialNetwork:      "tcp4",
+		FallbackDelay:    time.Millisecond * 100,
+		AllowedNetworks:  []string{"10.0.0.0/8"},
+	})
+
+	if cfg.ClientTimeout() != time.Second*5 {
+		t.Errorf("Expected client timeout: 5s, got: %v", cfg.ClientTimeout())
+	}
+
+	if cfg.ClientMaxRetries() != 2 {
+		t.Errorf("Expected client max retries: 2, got: %v",
+			cfg.ClientMaxRetries())
+	}
+
+	if cfg.ClientRetryWait() != time.Second {
+		t.Errorf("Expected client retry wait: 1s, got: %v",
+			cfg.ClientRetryWait())
+	}
+
+	if cfg.ClientMaxIdleConns() != 10 {
+		t.Errorf("Expected client max idle conns: 10, got: %v",
+			cfg.ClientMaxIdleConns())
+	}
+
+	if cfg.ClientIdleConnTimeout() != time.Minute {
+		t.Errorf("Expected client idle conn timeout: 1m, got: %v",
+			cfg.ClientIdleConnTimeout())
+	}
+
+	if cfg.ClientBreakerThreshold() != 3 {
+		t.Errorf("Expected client breaker threshold: 3, got: %v",
+			cfg.ClientBreakerThreshold())
+	}
+
+	if cfg.ClientBreakerTimeout() != time.Second*20 {
+		t.Errorf("Expected client breaker timeout: 20s, got: %v",
+			cfg.ClientBreakerTimeout())
+	}
+
+	if cfg.ClientProxy() != "http://proxy:3128" {
+		t.Errorf("Expected client proxy: http://proxy:3128, got: %v",
+			cfg.ClientProxy())
+	}
+
+	if cfg.ClientProxies()["github.com"] != "direct" {
+		t.Errorf("Expected client proxies: map[github.com:direct], got: %v",
+			cfg.ClientProxies())
+	}
+
+	if cfg.ClientNoProxy()[0] != ".internal" {
+		t.Errorf("Expected client no proxy: .internal, got: %v",
+			cfg.ClientNoProxy()[0])
+	}
+
+	if cfg.ClientCAFiles()[0] != "/etc/ca.pem" {
+		t.Errorf("Expected client CA files: /etc/ca.pem, got: %v",
+			cfg.ClientCAFiles()[0])
+	}
+
+	if cfg.ClientDNSTTL() != time.Second*30 {
+		t.Errorf("Expected client DNS TTL: 30s, got: %v", cfg.ClientDNSTTL())
+	}
+
+	if cfg.ClientDialTimeout() != time.Second*2 {
+		t.Errorf("Expected client dial timeout: 2s, got: %v",
+			cfg.ClientDialTimeout())
+	}
+
+	if cfg.ClientDialNetwork() != "tcp4" {
+		t.Errorf("Expected client dial network: tcp4, got: %v",
+			cfg.ClientDialNetwork())
+	}
+
+	if cfg.ClientFallbackDelay() != time.Millisecond*100 {
+		t.Errorf("Expected client fallback delay: 100ms, got: %v",
+			cfg.ClientFallbackDelay())
+	}
+
+	if cfg.ClientAllowedNetworks()[0] != "10.0.0.0/8" {
+		t.Errorf("Expected client allowed networks: 10.0.0.0/8, got: %v",
+			cfg.ClientAllowedNetworks()[0])
+	}
+}
@@ -20,21 +20,30 @@ type Config struct {
 	sync.RWMutex
 	auth      *AuthConfig
 	cache     *CacheConfig
+	client    *ClientConfig
 	db        *DBConfig
 	log       *LogConfig
 	telemetry *TelemetryConfig
 	server    *ServerConfig
 	service   *ServiceConfig
+	secret    *SecretConfig
+	repo      *RepoConfig
+	notify    *NotifyConfig
+	overrides []byte
 }
 
 type configFile struct {
 	Auth      *AuthConfig      `json:"auth,omitempty"      yaml:"auth,omitempty"`
 	Cache     *CacheConfig     `json:"cache,omitempty"     yaml:"cache,omitempty"`
+	Client    *ClientConfig    `json:"client,omitempty"    yaml:"client,omitempty"`
 	DB        *DBConfig        `json:"db,omitempty"        yaml:"db,omitempty"`
 	Log       *LogConfig       `json:"log,omitempty"       yaml:"log,omitempty"`
 	Telemetry *TelemetryConfig `json:"telemetry,omitempty" yaml:"telemetry,omitempty"`
 	Server    *ServerConfig    `json:"server,omitempty"    yaml:"server,omitempty"`
 	Service   *ServiceConfig   `json:"service,omitempty"   yaml:"service,omitempty"`
+	Secret    *SecretConfig    `json:"secret,omitempty"    yaml:"secret,omitempty"`
+	Repo      *RepoConfig      `json:"repo,omitempty"      yaml:"repo,omitempty"`
+	Notify    *NotifyConfig    `json:"notify,omitempty"    yaml:"notify,omitempty"`
 }
 
 // New creates a new configuration value.
@@ -71,6 +80,15 @@ func (c *Config) SetCache(cache *CacheConfig) {
 	c.cache = cache
 }
 
+// SetClient applies outgoing HTTP client configuration data to the
+// configuration.
+func (c *Config) SetClient(client *ClientConfig) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.client = client
+}
+
 // SetDB applies database configuration data to the configuration.
 func (c *Config) SetDB(db *DBConfig) {
 	c.Lock()
@@ -103,6 +121,33 @@ func (c *Config) SetServer(server *ServerConfig) {
 	c.server = server
 }
 
+// SetSecret applies external secret provider configuration data to the
+// configuration.
+func (c *Config) SetSecret(secret *SecretConfig) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.secret = secret
+}
+
+// SetRepo applies git repository access configuration data to the
+// configuration.
+func (c *Config) SetRepo(repo *RepoConfig) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.repo = repo
+}
+
+// SetNotify applies email notification configuration data to the
+// configuration.
+func (c *Config) SetNotify(notify *NotifyConfig) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.notify = notify
+}
+
 // SetService applies service configuration data to the configuration.
 func (c *Config) SetService(service *ServiceConfig) {
 	c.Lock()
@@ -138,6 +183,12 @@ func (c *Config) Load(b []byte) {
 
 	c.cache.Load()
 
+	if c.client == nil {
+		c.client = &ClientConfig{}
+	}
+
+	c.client.Load()
+
 	if c.db == nil {
 		c.db = &DBConfig{}
 	}
@@ -167,6 +218,26 @@ func (c *Config) Load(b []byte) {
 	}
 
 	c.service.Load()
+
+	if c.secret == nil {
+		c.secret = &SecretConfig{}
+	}
+
+	c.secret.Load()
+
+	if c.repo == nil {
+		c.repo = &RepoConfig{}
+	}
+
+	c.repo.Load()
+
+	if c.notify == nil {
+		c.notify = &NotifyConfig{}
+	}
+
+	c.notify.Load()
+
+	c.applyOverrides()
 }
 
 // LoadFiles attempts to load any available configuration files.
@@ -194,11 +265,15 @@ func (c *Config) UnmarshalJSON(b []byte) error {
 
 	c.auth = cf.Auth
 	c.cache = cf.Cache
+	c.client = cf.Client
 	c.db = cf.DB
 	c.log = cf.Log
 	c.telemetry = cf.Telemetry
 	c.server = cf.Server
 	c.service = cf.Service
+	c.secret = cf.Secret
+	c.repo = cf.Repo
+	c.notify = cf.Notify
 
 	return nil
 }
@@ -208,11 +283,15 @@ func (c *Config) MarshalJSON() ([]byte, error) {
 	cf := configFile{
 		Auth:      c.auth,
 		Cache:     c.cache,
+		Client:    c.client,
 		DB:        c.db,
 		Log:       c.log,
 		Telemetry: c.telemetry,
 		Server:    c.server,
 		Service:   c.service,
+		Secret:    c.secret,
+		Repo:      c.repo,
+		Notify:    c.notify,
 	}
 
 	buf := &bytes.Buffer{}
@@ -234,11 +313,15 @@ func (c *Config) UnmarshalYAML(value *yaml.Node) error {
 
 	c.auth = cf.Auth
 	c.cache = cf.Cache
+	c.client = cf.Client
 	c.db = cf.DB
 	c.log = cf.Log
 	c.telemetry = cf.Telemetry
 	c.server = cf.Server
 	c.service = cf.Service
+	c.secret = cf.Secret
+	c.repo = cf.Repo
+	c.notify = cf.Notify
 
 	return nil
 }
@@ -252,11 +335,15 @@ func (c *Config) MarshalYAML() (any, error) {
 	cf := &configFile{
 		Auth:      c.auth,
 		Cache:     c.cache,
+		Client:    c.client,
 		DB:        c.db,
 		Log:       c.log,
 		Telemetry: c.telemetry,
 		Server:    c.server,
 		Service:   c.service,
+		Secret:    c.secret,
+		Repo:      c.repo,
+		Notify:    c.notify,
 	}
 
 	return cf, nil
@@ -5,47 +5,77 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 const (
-	KeyDBConn            = "db/connection"
-	KeyDBUser            = "db/user"
-	KeyDBPassword        = "db/password"
-	KeyDBDatabase        = "db/database"
-	KeyDBMigrateUser     = "postgres/user"
-	KeyDBMigratePassword = "postgres/password"
-	KeyDBMigrateDatabase = "postgres/database"
-	KeyDBInstance        = "db/instance"
-	KeyDBPrivateIP       = "db/private_ip"
-	KeyDBHost            = "postgres/host"
-	KeyDBPort            = "postgres/port"
-	KeyDBMaxConns        = "db/max_connections"
-	KeyDBType            = "db/type"
-	KeyDBSSLMode         = "db/ssl_mode"
-	KeyDBMonitor         = "db/monitor"
-	KeyDBDefaultSize     = "db/default_size"
-	KeyDBMaxSize         = "db/max_size"
-	KeyDBMigrations      = "db/migrations"
-
-	DefaultDBConn            = ""
-	DefaultDBUser            = "api-db-user"
-	DefaultDBPassword        = "api"
-	DefaultDBDatabase        = "api-db"
-	DefaultDBMigrateUser     = "postgres"
-	DefaultDBMigratePassword = "postgres"
-	DefaultDBMigrateDatabase = "postgres"
-	DefaultDBInstance        = ""
-	DefaultDBPrivateIP       = ""
-	DefaultDBHost            = "localhost"
-	DefaultDBPort            = "5432"
-	DefaultDBMaxConns        = 20
-	DefaultDBType            = "postgres"
-	DefaultDBSSLMode         = "disable"
-	DefaultDBMonitor         = time.Second * 30
-	DefaultDBDefaultSize     = 100
-	DefaultDBMaxSize         = 10000
-	DefaultDBMigrations      = ""
+	KeyDBConn             = "db/connection"
+	KeyDBUser             = "db/user"
+	KeyDBPassword         = "db/password"
+	KeyDBDatabase         = "db/database"
+	KeyDBMigrateUser      = "postgres/user"
+	KeyDBMigratePassword  = "postgres/password"
+	KeyDBMigrateDatabase  = "postgres/database"
+	KeyDBInstance         = "db/instance"
+	KeyDBPrivateIP        = "db/private_ip"
+	KeyDBHost             = "postgres/host"
+	KeyDBPort             = "postgres/port"
+	KeyDBMaxConns         = "db/max_connections"
+	KeyDBType             = "db/type"
+	KeyDBSSLMode          = "db/ssl_mode"
+	KeyDBMonitor          = "db/monitor"
+	KeyDBDefaultSize      = "db/default_size"
+	KeyDBMaxSize          = "db/max_size"
+	KeyDBMigrations       = "db/migrations"
+	KeyDBStatementCache   = "db/statement_cache"
+	KeyDBQueryExecMode    = "db/query_exec_mode"
+	KeyDBReplicas         = "db/replicas"
+	KeyDBStatementTimeout = "db/statement_timeout"
+	KeyDBSlowQuery        = "db/slow_query"
+	KeyDBExplainSlow      = "db/explain_slow"
+	KeyDBRetries          = "db/retries"
+	KeyDBRetryBackoff     = "db/retry_backoff"
+	KeyDBBreakerFailures  = "db/breaker_failures"
+	KeyDBBreakerTimeout   = "db/breaker_timeout"
+
+	DefaultDBConn             = ""
+	DefaultDBUser             = "api-db-user"
+	DefaultDBPassword         = "api"
+	DefaultDBDatabase         = "api-db"
+	DefaultDBMigrateUser      = "postgres"
+	DefaultDBMigratePassword  = "postgres"
+	DefaultDBMigrateDatabase  = "postgres"
+	DefaultDBInstance         = ""
+	DefaultDBPrivateIP        = ""
+	DefaultDBHost             = "localhost"
+	DefaultDBPort             = "5432"
+	DefaultDBMaxConns         = 20
+	DefaultDBType             = "postgres"
+	DefaultDBSSLMode          = "disable"
+	DefaultDBMonitor          = time.Second * 30
+	DefaultDBDefaultSize      = 100
+	DefaultDBMaxSize          = 10000
+	DefaultDBMigrations       = ""
+	DefaultDBStatementCache   = 512
+	DefaultDBQueryExecMode    = DBQueryExecModeCacheStatement
+	DefaultDBStatementTimeout = time.Duration(0)
+	DefaultDBSlowQuery        = time.Duration(0)
+	DefaultDBExplainSlow      = false
+	DefaultDBRetries          = 3
+	DefaultDBRetryBackoff     = time.Millisecond * 50
+	DefaultDBBreakerFailures  = 5
+	DefaultDBBreakerTimeout   = time.Second * 30
+)
+
+// Database query execution modes, which determine whether the statements
+// executed by the service are prepared, and cached, on each connection.
+const (
+	DBQueryExecModeCacheStatement = "cache_statement"
+	DBQueryExecModeCacheDescribe  = "cache_describe"
+	DBQueryExecModeDescribeExec   = "describe_exec"
+	DBQueryExecModeExec           = "exec"
+	DBQueryExecModeSimpleProtocol = "simple_protocol"
 )
 
 const (
@@ -56,24 +86,34 @@ const (
 
 // DBConfig values represent database configuration data.
 type DBConfig struct {
-	Conn            string        `json:"connection,omitempty"       yaml:"connection,omitempty"`
-	User            string        `json:"user,omitempty"             yaml:"user,omitempty"`
-	Password        string        `json:"password,omitempty"         yaml:"password,omitempty"`
-	Database        string        `json:"database,omitempty"         yaml:"database,omitempty"`
-	MigrateUser     string        `json:"migrate_user,omitempty"     yaml:"migrate_user,omitempty"`
-	MigratePassword string        `json:"migrate_password,omitempty" yaml:"migrate_password,omitempty"`
-	MigrateDatabase string        `json:"migrate_database,omitempty" yaml:"migrate_database,omitempty"`
-	Instance        string        `json:"instance,omitempty"         yaml:"instance,omitempty"`
-	PrivateIP       string        `json:"private_ip,omitempty"       yaml:"private_ip,omitempty"`
-	Host            string        `json:"host,omitempty"             yaml:"host,omitempty"`
-	Port            string        `json:"port,omitempty"             yaml:"port,omitempty"`
-	MaxConns        int64         `json:"max_connections,omitempty"  yaml:"max_connections,omitempty"`
-	Type            string        `json:"type,omitempty"             yaml:"type,omitempty"`
-	SSLMode         string        `json:"ssl_mode,omitempty"         yaml:"ssl_mode,omitempty"`
-	Monitor         time.Duration `json:"monitor,omitempty"          yaml:"monitor,omitempty"`
-	DefaultSize     int64         `json:"default_size,omitempty"     yaml:"default_size,omitempty"`
-	MaxSize         int64         `json:"max_size,omitempty"         yaml:"max_size,omitempty"`
-	Migrations      string        `json:"migrations,omitempty"       yaml:"migrations,omitempty"`
+	Conn             string        `json:"connection,omitempty"        yaml:"connection,omitempty"`
+	User             string        `json:"user,omitempty"              yaml:"user,omitempty"`
+	Password         string        `json:"password,omitempty"          yaml:"password,omitempty"`
+	Database         string        `json:"database,omitempty"          yaml:"database,omitempty"`
+	MigrateUser      string        `json:"migrate_user,omitempty"      yaml:"migrate_user,omitempty"`
+	MigratePassword  string        `json:"migrate_password,omitempty"  yaml:"migrate_password,omitempty"`
+	MigrateDatabase  string        `json:"migrate_database,omitempty"  yaml:"migrate_database,omitempty"`
+	Instance         string        `json:"instance,omitempty"          yaml:"instance,omitempty"`
+	PrivateIP        string        `json:"private_ip,omitempty"        yaml:"private_ip,omitempty"`
+	Host             string        `json:"host,omitempty"              yaml:"host,omitempty"`
+	Port             string        `json:"port,omitempty"              yaml:"port,omitempty"`
+	MaxConns         int64         `json:"max_connections,omitempty"   yaml:"max_connections,omitempty"`
+	Type             string        `json:"type,omitempty"              yaml:"type,omitempty"`
+	SSLMode          string        `json:"ssl_mode,omitempty"          yaml:"ssl_mode,omitempty"`
+	Monitor          time.Duration `json:"monitor,omitempty"           yaml:"monitor,omitempty"`
+	DefaultSize      int64         `json:"default_size,omitempty"      yaml:"default_size,omitempty"`
+	MaxSize          int64         `json:"max_size,omitempty"          yaml:"max_size,omitempty"`
+	Migrations       string        `json:"migrations,omitempty"        yaml:"migrations,omitempty"`
+	StatementCache   int64         `json:"statement_cache,omitempty"   yaml:"statement_cache,omitempty"`
+	QueryExecMode    string        `json:"query_exec_mode,omitempty"   yaml:"query_exec_mode,omitempty"`
+	Replicas         []string      `json:"replicas,omitempty"          yaml:"replicas,omitempty"`
+	StatementTimeout time.Duration `json:"statement_timeout,omitempty" yaml:"statement_timeout,omitempty"`
+	SlowQuery        time.Duration `json:"slow_query,omitempty"        yaml:"slow_query,omitempty"`
+	ExplainSlow      bool          `json:"explain_slow,omitempty"      yaml:"explain_slow,omitempty"`
+	Retries          int64         `json:"retries,omitempty"           yaml:"retries,omitempty"`
+	RetryBackoff     time.Duration `json:"retry_backoff,omitempty"     yaml:"retry_backoff,omitempty"`
+	BreakerFailures  int64         `json:"breaker_failures,omitempty"  yaml:"breaker_failures,omitempty"`
+	BreakerTimeout   time.Duration `json:"breaker_timeout,omitempty"   yaml:"breaker_timeout,omitempty"`
 }
 
 // Load reads configuration data from environment variables and applies defaults
@@ -242,6 +282,126 @@ func (c *DBConfig) Load() {
 	if c.Migrations == "" {
 		c.Migrations = DefaultDBMigrations
 	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBStatementCache)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			v = DefaultDBStatementCache
+		}
+
+		c.StatementCache = v
+	}
+
+	if c.StatementCache <= 0 {
+		c.StatementCache = DefaultDBStatementCache
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBQueryExecMode)); v != "" {
+		c.QueryExecMode = v
+	}
+
+	switch c.QueryExecMode {
+	case DBQueryExecModeCacheStatement, DBQueryExecModeCacheDescribe,
+		DBQueryExecModeDescribeExec, DBQueryExecModeExec,
+		DBQueryExecModeSimpleProtocol:
+	default:
+		c.QueryExecMode = DefaultDBQueryExecMode
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBReplicas)); v != "" {
+		c.Replicas = strings.Fields(v)
+	}
+
+	if c.Replicas == nil {
+		c.Replicas = []string{}
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBStatementTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultDBStatementTimeout
+		}
+
+		c.StatementTimeout = v
+	}
+
+	if c.StatementTimeout < 0 {
+		c.StatementTimeout = DefaultDBStatementTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBSlowQuery)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultDBSlowQuery
+		}
+
+		c.SlowQuery = v
+	}
+
+	if c.SlowQuery < 0 {
+		c.SlowQuery = DefaultDBSlowQuery
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBExplainSlow)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultDBExplainSlow
+		}
+
+		c.ExplainSlow = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBRetries)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			v = DefaultDBRetries
+		}
+
+		c.Retries = v
+	}
+
+	if c.Retries == 0 {
+		c.Retries = DefaultDBRetries
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBRetryBackoff)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultDBRetryBackoff
+		}
+
+		c.RetryBackoff = v
+	}
+
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = DefaultDBRetryBackoff
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBBreakerFailures)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			v = DefaultDBBreakerFailures
+		}
+
+		c.BreakerFailures = v
+	}
+
+	if c.BreakerFailures == 0 {
+		c.BreakerFailures = DefaultDBBreakerFailures
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyDBBreakerTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultDBBreakerTimeout
+		}
+
+		c.BreakerTimeout = v
+	}
+
+	if c.BreakerTimeout <= 0 {
+		c.BreakerTimeout = DefaultDBBreakerTimeout
+	}
 }
 
 // DBConn returns the connection string used by the primary database
@@ -515,3 +675,139 @@ func (c *Config) DBMigrations() string {
 
 	return c.db.Migrations
 }
+
+// DBStatementCache returns the number of prepared statements, or statement
+// descriptions, cached on each database connection.
+func (c *Config) DBStatementCache() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBStatementCache
+	}
+
+	return c.db.StatementCache
+}
+
+// DBQueryExecMode returns the mode used to execute database queries.
+func (c *Config) DBQueryExecMode() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBQueryExecMode
+	}
+
+	return c.db.QueryExecMode
+}
+
+// DBReplicas returns the connection strings of the read only replica databases
+// used to perform select queries.
+func (c *Config) DBReplicas() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return nil
+	}
+
+	return c.db.Replicas
+}
+
+// DBStatementTimeout returns the maximum duration of database statements. When
+// it is not zero, the statement timeout of each transaction is set to this
+// duration, or to the time remaining before the deadline of the context used
+// to start the transaction, if that is sooner.
+func (c *Config) DBStatementTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBStatementTimeout
+	}
+
+	return c.db.StatementTimeout
+}
+
+// DBSlowQuery returns the duration after which database statements are logged,
+// with their parameters, as slow queries, and counted in the db_slow_queries
+// metric. If it is zero, slow queries are not logged.
+func (c *Config) DBSlowQuery() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBSlowQuery
+	}
+
+	return c.db.SlowQuery
+}
+
+// DBExplainSlow returns whether slow queries are planned, using EXPLAIN without
+// ANALYZE, so that their plans are logged with them.
+func (c *Config) DBExplainSlow() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBExplainSlow
+	}
+
+	return c.db.ExplainSlow
+}
+
+// DBRetries returns the number of times a database operation, which failed
+// with a transient error, such as a lost connection or a serialization failure,
+// is attempted again. Negative values disable retries.
+func (c *Config) DBRetries() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBRetries
+	}
+
+	return c.db.Retries
+}
+
+// DBRetryBackoff returns the time waited before the first retry of a failed
+// database operation. The wait is doubled for each later retry.
+func (c *Config) DBRetryBackoff() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBRetryBackoff
+	}
+
+	return c.db.RetryBackoff
+}
+
+// DBBreakerFailures returns the number of consecutive database connection
+// failures after which the circuit breaker trips, and database operations fail
+// immediately, until the breaker timeout has passed. Negative values disable
+// the circuit breaker.
+func (c *Config) DBBreakerFailures() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBBreakerFailures
+	}
+
+	return c.db.BreakerFailures
+}
+
+// DBBreakerTimeout returns the time for which database operations fail
+// immediately after the circuit breaker trips, before a single operation is
+// allowed to test whether the database is available again.
+func (c *Config) DBBreakerTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.db == nil {
+		return DefaultDBBreakerTimeout
+	}
+
+	return c.db.BreakerTimeout
+}
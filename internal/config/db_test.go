@@ -17,25 +17,67 @@ func TestDatabaseConfig(t *testing.T) {
 	cfg.Load(nil)
 
 	cfg.SetDB(&config.DBConfig{
-		User:            exp,
-		Password:        "te:st",
-		Database:        "api-db",
-		MigrateUser:     "migrate",
-		MigratePassword: "te:st",
-		MigrateDatabase: "postgres",
-		Instance:        exp,
-		PrivateIP:       "1.1.1.1",
-		Port:            "5432",
-		Host:            "test-host",
-		MaxConns:        10,
-		Monitor:         time.Second * 10,
-		Type:            exp,
-		SSLMode:         "enable",
-		DefaultSize:     10,
-		MaxSize:         100,
-		Migrations:      exp,
+		User:             exp,
+		Password:         "te:st",
+		Database:         "api-db",
+		MigrateUser:      "migrate",
+		MigratePassword:  "te:st",
+		MigrateDatabase:  "postgres",
+		Instance:         exp,
+		PrivateIP:        "1.1.1.1",
+		Port:             "5432",
+		Host:             "test-host",
+		MaxConns:         10,
+		Monitor:          time.Second * 10,
+		Type:             exp,
+		SSLMode:          "enable",
+		DefaultSize:      10,
+		MaxSize:          100,
+		Migrations:       exp,
+		StatementCache:   100,
+		QueryExecMode:    config.DBQueryExecModeExec,
+		Replicas:         []string{"test-replica"},
+		StatementTimeout: time.Second * 5,
+		SlowQuery:        time.Second,
+		ExplainSlow:      true,
+		Retries:          -1,
+		RetryBackoff:     time.Millisecond,
+		BreakerFailures:  2,
+		BreakerTimeout:   time.Second,
 	})
 
+	if d := cfg.DBStatementTimeout(); d != time.Second*5 {
+		t.Errorf("Expected statement timeout: 5s, got: %v", d)
+	}
+
+	if d := cfg.DBSlowQuery(); d != time.Second {
+		t.Errorf("Expected slow query: 1s, got: %v", d)
+	}
+
+	if !cfg.DBExplainSlow() {
+		t.Errorf("Expected explain slow: true, got: %v", cfg.DBExplainSlow())
+	}
+
+	if n := cfg.DBRetries(); n != -1 {
+		t.Errorf("Expected retries: -1, got: %v", n)
+	}
+
+	if d := cfg.DBRetryBackoff(); d != time.Millisecond {
+		t.Errorf("Expected retry backoff: 1ms, got: %v", d)
+	}
+
+	if n := cfg.DBBreakerFailures(); n != 2 {
+		t.Errorf("Expected breaker failures: 2, got: %v", n)
+	}
+
+	if d := cfg.DBBreakerTimeout(); d != time.Second {
+		t.Errorf("Expected breaker timeout: 1s, got: %v", d)
+	}
+
+	if r := cfg.DBReplicas(); len(r) != 1 || r[0] != "test-replica" {
+		t.Errorf("Expected replicas: [test-replica], got: %v", r)
+	}
+
 	if cfg.DBInstance() != exp {
 		t.Errorf("Expected instance: %v, got: %v", exp, cfg.DBInstance())
 	}
@@ -140,6 +182,30 @@ func TestDatabaseConfig(t *testing.T) {
 		t.Errorf("Expected migrations: %v, got: %v", exp, cfg.DBMigrations())
 	}
 
+	if cfg.DBStatementCache() != 100 {
+		t.Errorf("Expected statement cache: 100, got: %v",
+			cfg.DBStatementCache())
+	}
+
+	if cfg.DBQueryExecMode() != config.DBQueryExecModeExec {
+		t.Errorf("Expected query exec mode: %v, got: %v",
+			config.DBQueryExecModeExec, cfg.DBQueryExecMode())
+	}
+
+	dc := &config.DBConfig{QueryExecMode: "invalid"}
+
+	dc.Load()
+
+	if dc.QueryExecMode != config.DefaultDBQueryExecMode {
+		t.Errorf("Expected query exec mode: %v, got: %v",
+			config.DefaultDBQueryExecMode, dc.QueryExecMode)
+	}
+
+	if dc.StatementCache != config.DefaultDBStatementCache {
+		t.Errorf("Expected statement cache: %v, got: %v",
+			config.DefaultDBStatementCache, dc.StatementCache)
+	}
+
 	cfg.SetDB(&config.DBConfig{Conn: exp})
 
 	if cfg.DBConn(config.DBModeNormal) != exp {
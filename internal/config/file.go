@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	KeyConfigFile = "config/file"
+
+	DefaultConfigFile = ""
+)
+
+// ConfigFile returns the configuration file named by the environment, if any.
+func ConfigFile() string {
+	if v := os.Getenv(ReplaceEnv(KeyConfigFile)); v != "" {
+		return v
+	}
+
+	return DefaultConfigFile
+}
+
+// Validate checks configuration data, in the YAML or JSON format, against the
+// configuration schema. Unknown keys, and values of the wrong type, are
+// rejected.
+func Validate(b []byte) error {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+
+	dec.KnownFields(true)
+
+	if err := dec.Decode(&configFile{}); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	return nil
+}
+
+// ReadFile reads and validates a configuration file. Files with a .toml
+// extension are read in the TOML format, and converted to YAML, others are
+// read in the YAML, or JSON, format.
+func ReadFile(name string) ([]byte, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %s: %w", name, err)
+	}
+
+	if strings.ToLower(filepath.Ext(name)) == ".toml" {
+		m := map[string]any{}
+
+		if err := toml.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("unable to parse config file: %s: %w",
+				name, err)
+		}
+
+		if b, err = yaml.Marshal(m); err != nil {
+			return nil, fmt.Errorf("unable to convert config file: %s: %w",
+				name, err)
+		}
+	}
+
+	if err := Validate(b); err != nil {
+		return nil, fmt.Errorf("invalid config file: %s: %w", name, err)
+	}
+
+	return b, nil
+}
+
+// LoadFile applies the configuration data in a file, then populates missing
+// configuration from environment variables and default values. If the file
+// cannot be read, or is invalid, the configuration is not changed.
+func (c *Config) LoadFile(name string) error {
+	b, err := ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	c.Load(b)
+
+	return nil
+}
+
+// ParseOverrides converts a list of key=value configuration overrides into
+// configuration data. Keys are the dot separated path of the value in the
+// configuration file, such as log.level=debug.
+func ParseOverrides(values []string) ([]byte, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, v := range values {
+		k, val, ok := strings.Cut(v, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid config override: %s", v)
+		}
+
+		n := root
+
+		ks := strings.Split(k, ".")
+
+		for i, p := range ks {
+			var next *yaml.Node
+
+			for j := 0; j+1 < len(n.Content); j += 2 {
+				if n.Content[j].Value == p {
+					next = n.Content[j+1]
+
+					break
+				}
+			}
+
+			if next == nil {
+				next = &yaml.Node{Kind: yaml.MappingNode}
+
+				n.Content = append(n.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: p}, next)
+			}
+
+			if i == len(ks)-1 {
+				*next = yaml.Node{Kind: yaml.ScalarNode, Value: val}
+			} else if next.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("invalid config override: %s", v)
+			}
+
+			n = next
+		}
+	}
+
+	b, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("unable to format config overrides: %w", err)
+	}
+
+	if err := Validate(b); err != nil {
+		return nil, fmt.Errorf("invalid config overrides: %w", err)
+	}
+
+	return b, nil
+}
+
+// SetOverrides sets configuration data which is applied after environment
+// variables, each time the configuration is loaded, so that it takes
+// precedence over them.
+func (c *Config) SetOverrides(b []byte) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.overrides = b
+}
+
+// applyOverrides applies the configuration overrides to the loaded
+// configuration. It must be called with the lock held.
+func (c *Config) applyOverrides() {
+	if len(c.overrides) == 0 {
+		return
+	}
+
+	cf := &configFile{
+		Auth:      c.auth,
+		Cache:     c.cache,
+		Client:    c.client,
+		DB:        c.db,
+		Log:       c.log,
+		Telemetry: c.telemetry,
+		Server:    c.server,
+		Service:   c.service,
+		Secret:    c.secret,
+		Repo:      c.repo,
+		Notify:    c.notify,
+	}
+
+	if err := yaml.Unmarshal(c.overrides, cf); err != nil {
+		os.Stderr.WriteString("unable to apply config overrides: " +
+			err.Error() + "\n")
+	}
+}
+
+// Reload reads the configuration file, and applies the values which can be
+// changed without a restart, the log level, import interval and password
+// authentication rate limit, to the configuration. Environment variables and
+// overrides still take precedence over the file. The keys of the values
+// changed are returned. If the file cannot be read, or is invalid, the
+// configuration is not changed.
+func (c *Config) Reload(name string) ([]string, error) {
+	b, err := ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c.RLock()
+	nc := &Config{overrides: c.overrides}
+	c.RUnlock()
+
+	nc.Load(b)
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.log == nil || c.service == nil || c.auth == nil {
+		return nil, errors.New("unable to reload config before it is loaded")
+	}
+
+	changed := []string{}
+
+	if c.log.Level != nc.log.Level {
+		c.log.Level = nc.log.Level
+
+		changed = append(changed, KeyLogLevel)
+	}
+
+	if c.service.ImportInterval != nc.service.ImportInterval {
+		c.service.ImportInterval = nc.service.ImportInterval
+
+		changed = append(changed, KeyImportInterval)
+	}
+
+	if c.auth.LocalRateLimit != nc.auth.LocalRateLimit {
+		c.auth.LocalRateLimit = nc.auth.LocalRateLimit
+
+		changed = append(changed, KeyAuthLocalRateLimit)
+	}
+
+	return changed, nil
+}
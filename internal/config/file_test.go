@@ -0,0 +1,126 @@
+package config_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+)
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	yf := filepath.Join(dir, "api.yaml")
+
+	if err := os.WriteFile(yf, []byte("log:\n  level: debug\n"+
+		"service:\n  import_interval: 10m\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tf := filepath.Join(dir, "api.toml")
+
+	if err := os.WriteFile(tf, []byte("[log]\nlevel = \"warn\"\n\n"+
+		"[auth]\nlocal_rate_limit = 5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	bf := filepath.Join(dir, "bad.yaml")
+
+	if err := os.WriteFile(bf, []byte("log:\n  levle: debug\n"),
+		0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(config.ReplaceEnv(config.KeyLogLevel), "")
+	t.Setenv(config.ReplaceEnv(config.KeyImportInterval), "")
+	t.Setenv(config.ReplaceEnv(config.KeyAuthLocalRateLimit), "")
+	t.Setenv(config.ReplaceEnv(config.KeyServerAddress), "")
+
+	cfg := config.New("")
+
+	if err := cfg.LoadFile(bf); err == nil {
+		t.Error("Expected error for unknown key, got: nil")
+	}
+
+	if err := cfg.LoadFile(yf); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.LogLevel(); v != slog.LevelDebug {
+		t.Errorf("Expected log level: debug, got: %v", v)
+	}
+
+	if v := cfg.ImportInterval(); v != 10*time.Minute {
+		t.Errorf("Expected import interval: 10m, got: %v", v)
+	}
+
+	if err := cfg.LoadFile(tf); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.AuthLocalRateLimit(); v != 5 {
+		t.Errorf("Expected rate limit: 5, got: %v", v)
+	}
+
+	t.Setenv(config.ReplaceEnv(config.KeyLogLevel), config.LogLvlError)
+	t.Setenv(config.ReplaceEnv(config.KeyServerAddress), ":9000")
+
+	_, err := config.ParseOverrides([]string{"log.levle=info"})
+	if err == nil {
+		t.Error("Expected error for unknown override, got: nil")
+	}
+
+	_, err = config.ParseOverrides([]string{"service.import_interval=x"})
+	if err == nil {
+		t.Error("Expected error for invalid override, got: nil")
+	}
+
+	ov, err := config.ParseOverrides([]string{"server.address=:9001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.SetOverrides(ov)
+
+	if err := cfg.LoadFile(yf); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.LogLevel(); v != slog.LevelError {
+		t.Errorf("Expected log level: error, got: %v", v)
+	}
+
+	if v := cfg.ServerAddress(); v != ":9001" {
+		t.Errorf("Expected server address: :9001, got: %v", v)
+	}
+
+	t.Setenv(config.ReplaceEnv(config.KeyLogLevel), "")
+
+	changed, err := cfg.Reload(tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []string{config.KeyLogLevel, config.KeyImportInterval,
+		config.KeyAuthLocalRateLimit}
+
+	if !slices.Equal(changed, exp) {
+		t.Errorf("Expected changed: %v, got: %v", exp, changed)
+	}
+
+	if v := cfg.LogLevel(); v != slog.LevelWarn {
+		t.Errorf("Expected log level: warn, got: %v", v)
+	}
+
+	if _, err := cfg.Reload(bf); err == nil {
+		t.Error("Expected error for invalid reload, got: nil")
+	}
+
+	if v := cfg.AuthLocalRateLimit(); v != 5 {
+		t.Errorf("Expected rate limit: 5, got: %v", v)
+	}
+}
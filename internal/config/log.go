@@ -3,6 +3,7 @@ package config
 import (
 	"log/slog"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -24,20 +25,72 @@ const (
 )
 
 const (
-	KeyLogLevel  = "log/level"
-	KeyLogOut    = "log/out"
-	KeyLogFormat = "log/format"
+	LogBackendSlog    = "slog"
+	LogBackendZap     = "zap"
+	LogBackendZerolog = "zerolog"
+)
+
+const (
+	LogForwardSyslog = "syslog"
+	LogForwardHTTP   = "http"
+	LogForwardKafka  = "kafka"
+)
+
+const (
+	LogAccessStdout = "stdout"
+	LogAccessStderr = "stderr"
+	LogAccessFile   = "file"
+	LogAccessSyslog = "syslog"
+)
 
-	DefaultLogLevel  = LogLvlInfo
-	DefaultLogOut    = LogOutStderr
-	DefaultLogFormat = LogFmtJSON
+const (
+	KeyLogLevel          = "log/level"
+	KeyLogOut            = "log/out"
+	KeyLogFormat         = "log/format"
+	KeyLogBackend        = "log/backend"
+	KeyLogForward        = "log/forward"
+	KeyLogForwardAddress = "log/forward_address"
+	KeyLogForwardBuffer  = "log/forward_buffer"
+	KeyLogAccess         = "log/access"
+	KeyLogAccessFile     = "log/access_file"
+	KeyLogAccessMaxSize  = "log/access_max_size"
+	KeyLogAccessMaxFiles = "log/access_max_files"
+	KeyLogAccessSyslog   = "log/access_syslog"
+	KeyLogAccessFields   = "log/access_fields"
+	KeyLogAccessSample   = "log/access_sample"
+
+	DefaultLogLevel          = LogLvlInfo
+	DefaultLogOut            = LogOutStderr
+	DefaultLogFormat         = LogFmtJSON
+	DefaultLogBackend        = LogBackendSlog
+	DefaultLogForward        = ""
+	DefaultLogForwardAddress = ""
+	DefaultLogForwardBuffer  = 10000
+	DefaultLogAccess         = ""
+	DefaultLogAccessFile     = "access.log"
+	DefaultLogAccessMaxSize  = 100
+	DefaultLogAccessMaxFiles = 5
+	DefaultLogAccessSyslog   = ""
+	DefaultLogAccessFields   = ""
+	DefaultLogAccessSample   = 1.0
 )
 
 // LogConfig values represent log configuration data.
 type LogConfig struct {
-	Level  string `json:"level,omitempty"  yaml:"level,omitempty"`
-	Out    string `json:"out,omitempty"    yaml:"out,omitempty"`
-	Format string `json:"format,omitempty" yaml:"format,omitempty"`
+	Level          string  `json:"level,omitempty"         yaml:"level,omitempty"`
+	Out            string  `json:"out,omitempty"           yaml:"out,omitempty"`
+	Format         string  `json:"format,omitempty"        yaml:"format,omitempty"`
+	Backend        string  `json:"backend,omitempty"       yaml:"backend,omitempty"`
+	Forward        string  `json:"forward,omitempty"       yaml:"forward,omitempty"`
+	ForwardAddress string  `json:"forward_address,omitempty" yaml:"forward_address,omitempty"`
+	ForwardBuffer  int     `json:"forward_buffer,omitempty" yaml:"forward_buffer,omitempty"`
+	Access         string  `json:"access,omitempty"        yaml:"access,omitempty"`
+	AccessFile     string  `json:"access_file,omitempty"   yaml:"access_file,omitempty"`
+	AccessMaxSize  int     `json:"access_max_size,omitempty" yaml:"access_max_size,omitempty"`
+	AccessMaxFiles int     `json:"access_max_files,omitempty" yaml:"access_max_files,omitempty"`
+	AccessSyslog   string  `json:"access_syslog,omitempty" yaml:"access_syslog,omitempty"`
+	AccessFields   string  `json:"access_fields,omitempty" yaml:"access_fields,omitempty"`
+	AccessSample   float64 `json:"access_sample,omitempty" yaml:"access_sample,omitempty"`
 }
 
 // Load reads configuration data from environment variables and applies defaults
@@ -70,7 +123,121 @@ func (c *LogConfig) Load() {
 	switch c.Format {
 	case LogFmtJSON, LogFmtText:
 	default:
-		c.Out = DefaultLogFormat
+		c.Format = DefaultLogFormat
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogBackend)); v != "" {
+		c.Backend = v
+	}
+
+	switch c.Backend {
+	case LogBackendSlog, LogBackendZap, LogBackendZerolog:
+	default:
+		c.Backend = DefaultLogBackend
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogForward)); v != "" {
+		c.Forward = v
+	}
+
+	switch c.Forward {
+	case LogForwardSyslog, LogForwardHTTP, LogForwardKafka:
+	default:
+		c.Forward = DefaultLogForward
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogForwardAddress)); v != "" {
+		c.ForwardAddress = v
+	}
+
+	if c.ForwardAddress == "" {
+		c.ForwardAddress = DefaultLogForwardAddress
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogForwardBuffer)); v != "" {
+		v, err := strconv.Atoi(v)
+		if err != nil {
+			v = DefaultLogForwardBuffer
+		}
+
+		c.ForwardBuffer = v
+	}
+
+	if c.ForwardBuffer <= 0 {
+		c.ForwardBuffer = DefaultLogForwardBuffer
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogAccess)); v != "" {
+		c.Access = v
+	}
+
+	switch c.Access {
+	case LogAccessStdout, LogAccessStderr, LogAccessFile, LogAccessSyslog:
+	default:
+		c.Access = DefaultLogAccess
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogAccessFile)); v != "" {
+		c.AccessFile = v
+	}
+
+	if c.AccessFile == "" {
+		c.AccessFile = DefaultLogAccessFile
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogAccessMaxSize)); v != "" {
+		v, err := strconv.Atoi(v)
+		if err != nil {
+			v = DefaultLogAccessMaxSize
+		}
+
+		c.AccessMaxSize = v
+	}
+
+	if c.AccessMaxSize <= 0 {
+		c.AccessMaxSize = DefaultLogAccessMaxSize
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogAccessMaxFiles)); v != "" {
+		v, err := strconv.Atoi(v)
+		if err != nil {
+			v = DefaultLogAccessMaxFiles
+		}
+
+		c.AccessMaxFiles = v
+	}
+
+	if c.AccessMaxFiles <= 0 {
+		c.AccessMaxFiles = DefaultLogAccessMaxFiles
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogAccessSyslog)); v != "" {
+		c.AccessSyslog = v
+	}
+
+	if c.AccessSyslog == "" {
+		c.AccessSyslog = DefaultLogAccessSyslog
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogAccessFields)); v != "" {
+		c.AccessFields = v
+	}
+
+	if c.AccessFields == "" {
+		c.AccessFields = DefaultLogAccessFields
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyLogAccessSample)); v != "" {
+		v, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			v = DefaultLogAccessSample
+		}
+
+		c.AccessSample = v
+	}
+
+	if c.AccessSample <= 0 || c.AccessSample > 1 {
+		c.AccessSample = DefaultLogAccessSample
 	}
 }
 
@@ -129,3 +296,158 @@ func (c *Config) LogFormat() string {
 
 	return lf
 }
+
+// LogBackend is the logging library used to write log entries.
+func (c *Config) LogBackend() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	lb := DefaultLogBackend
+
+	if c.log != nil && c.log.Backend != "" {
+		lb = c.log.Backend
+	}
+
+	return lb
+}
+
+// LogForward is the sink to which log entries are also forwarded, or empty if
+// log forwarding is disabled.
+func (c *Config) LogForward() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil {
+		return DefaultLogForward
+	}
+
+	return c.log.Forward
+}
+
+// LogForwardAddress is the address to which log entries are forwarded. This
+// is the address of the syslog server, such as udp://localhost:514, the URL to
+// which entries are posted over HTTP, or the URL of the topic of a Kafka REST
+// proxy, such as http://localhost:8082/topics/logs.
+func (c *Config) LogForwardAddress() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil {
+		return DefaultLogForwardAddress
+	}
+
+	return c.log.ForwardAddress
+}
+
+// LogForwardBuffer is the number of log entries buffered for forwarding.
+// Entries written while the buffer is full are dropped.
+func (c *Config) LogForwardBuffer() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil || c.log.ForwardBuffer <= 0 {
+		return DefaultLogForwardBuffer
+	}
+
+	return c.log.ForwardBuffer
+}
+
+// LogAccess is the sink to which access log entries are written, or empty if
+// the access log is disabled.
+func (c *Config) LogAccess() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil {
+		return DefaultLogAccess
+	}
+
+	return c.log.Access
+}
+
+// LogAccessFile is the path of the access log file, when written to a file.
+func (c *Config) LogAccessFile() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil || c.log.AccessFile == "" {
+		return DefaultLogAccessFile
+	}
+
+	return c.log.AccessFile
+}
+
+// LogAccessMaxSize is the size, in megabytes, at which the access log file is
+// rotated.
+func (c *Config) LogAccessMaxSize() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil || c.log.AccessMaxSize <= 0 {
+		return DefaultLogAccessMaxSize
+	}
+
+	return c.log.AccessMaxSize
+}
+
+// LogAccessMaxFiles is the number of rotated access log files retained.
+func (c *Config) LogAccessMaxFiles() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil || c.log.AccessMaxFiles <= 0 {
+		return DefaultLogAccessMaxFiles
+	}
+
+	return c.log.AccessMaxFiles
+}
+
+// LogAccessSyslog is the address of the syslog server to which access log
+// entries are written, such as udp://localhost:514. If empty, the local syslog
+// server is used.
+func (c *Config) LogAccessSyslog() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil {
+		return DefaultLogAccessSyslog
+	}
+
+	return c.log.AccessSyslog
+}
+
+// LogAccessFields are the fields written in access log entries. If empty, all
+// fields are written.
+func (c *Config) LogAccessFields() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	v := DefaultLogAccessFields
+
+	if c.log != nil {
+		v = c.log.AccessFields
+	}
+
+	res := []string{}
+
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			res = append(res, f)
+		}
+	}
+
+	return res
+}
+
+// LogAccessSample is the ratio, between 0 and 1, of successful requests which
+// are written to the access log. Failed requests are always written.
+func (c *Config) LogAccessSample() float64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.log == nil || c.log.AccessSample <= 0 {
+		return DefaultLogAccessSample
+	}
+
+	return c.log.AccessSample
+}
@@ -13,9 +13,15 @@ func TestLogConfig(t *testing.T) {
 	cfg := &config.Config{}
 
 	cfg.SetLog(&config.LogConfig{
-		Level:  config.LogLvlDebug,
-		Out:    config.LogOutStdout,
-		Format: config.LogFmtText,
+		Level:        config.LogLvlDebug,
+		Out:          config.LogOutStdout,
+		Format:       config.LogFmtText,
+		Backend:      config.LogBackendZap,
+		Forward:      "invalid",
+		Access:       config.LogAccessFile,
+		AccessFile:   "test.log",
+		AccessFields: "method, status",
+		AccessSample: 2,
 	})
 
 	cfg.Load(nil)
@@ -34,4 +40,49 @@ func TestLogConfig(t *testing.T) {
 		t.Errorf("Expected log format: %v, got: %v",
 			config.LogFmtText, cfg.LogFormat())
 	}
+
+	if cfg.LogBackend() != config.LogBackendZap {
+		t.Errorf("Expected log backend: %v, got: %v",
+			config.LogBackendZap, cfg.LogBackend())
+	}
+
+	if cfg.LogForward() != config.DefaultLogForward {
+		t.Errorf("Expected log forward: %v, got: %v",
+			config.DefaultLogForward, cfg.LogForward())
+	}
+
+	if cfg.LogForwardBuffer() != config.DefaultLogForwardBuffer {
+		t.Errorf("Expected log forward buffer: %v, got: %v",
+			config.DefaultLogForwardBuffer, cfg.LogForwardBuffer())
+	}
+
+	if cfg.LogAccess() != config.LogAccessFile {
+		t.Errorf("Expected log access: %v, got: %v",
+			config.LogAccessFile, cfg.LogAccess())
+	}
+
+	if cfg.LogAccessFile() != "test.log" {
+		t.Errorf("Expected log access file: test.log, got: %v",
+			cfg.LogAccessFile())
+	}
+
+	if cfg.LogAccessMaxSize() != config.DefaultLogAccessMaxSize {
+		t.Errorf("Expected log access max size: %v, got: %v",
+			config.DefaultLogAccessMaxSize, cfg.LogAccessMaxSize())
+	}
+
+	if cfg.LogAccessMaxFiles() != config.DefaultLogAccessMaxFiles {
+		t.Errorf("Expected log access max files: %v, got: %v",
+			config.DefaultLogAccessMaxFiles, cfg.LogAccessMaxFiles())
+	}
+
+	if fs := cfg.LogAccessFields(); len(fs) != 2 || fs[0] != "method" ||
+		fs[1] != "status" {
+		t.Errorf("Expected log access fields: [method status], got: %v", fs)
+	}
+
+	if cfg.LogAccessSample() != config.DefaultLogAccessSample {
+		t.Errorf("Expected log access sample: %v, got: %v",
+			config.DefaultLogAccessSample, cfg.LogAccessSample())
+	}
 }
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	KeyNotifySMTPHost     = "notify/smtp_host"
+	KeyNotifySMTPPort     = "notify/smtp_port"
+	KeyNotifySMTPUsername = "notify/smtp_username"
+	KeyNotifySMTPPassword = "notify/smtp_password"
+	KeyNotifySMTPTLS      = "notify/smtp_tls"
+	KeyNotifyFrom         = "notify/from"
+	KeyNotifyTimeout      = "notify/timeout"
+
+	DefaultNotifySMTPHost     = ""
+	DefaultNotifySMTPPort     = 587
+	DefaultNotifySMTPUsername = ""
+	DefaultNotifySMTPPassword = ""
+	DefaultNotifySMTPTLS      = true
+	DefaultNotifyFrom         = "apigo@localhost"
+	DefaultNotifyTimeout      = time.Second * 10
+)
+
+// NotifyConfig values represent email notification configuration data.
+type NotifyConfig struct {
+	SMTPHost     string        `json:"smtp_host,omitempty"     yaml:"smtp_host,omitempty"`
+	SMTPPort     int           `json:"smtp_port,omitempty"     yaml:"smtp_port,omitempty"`
+	SMTPUsername string        `json:"smtp_username,omitempty" yaml:"smtp_username,omitempty"`
+	SMTPPassword string        `json:"smtp_password,omitempty" yaml:"smtp_password,omitempty"`
+	SMTPTLS      *bool         `json:"smtp_tls,omitempty"      yaml:"smtp_tls,omitempty"`
+	From         string        `json:"from,omitempty"          yaml:"from,omitempty"`
+	Timeout      time.Duration `json:"timeout,omitempty"       yaml:"timeout,omitempty"`
+}
+
+// Load reads configuration data from environment variables and applies defaults
+// for any missing or invalid configuration data.
+func (c *NotifyConfig) Load() {
+	if v := os.Getenv(ReplaceEnv(KeyNotifySMTPHost)); v != "" {
+		c.SMTPHost = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyNotifySMTPPort)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			v = DefaultNotifySMTPPort
+		}
+
+		c.SMTPPort = int(v)
+	}
+
+	if c.SMTPPort <= 0 {
+		c.SMTPPort = DefaultNotifySMTPPort
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyNotifySMTPUsername)); v != "" {
+		c.SMTPUsername = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyNotifySMTPPassword)); v != "" {
+		c.SMTPPassword = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyNotifySMTPTLS)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultNotifySMTPTLS
+		}
+
+		c.SMTPTLS = &v
+	}
+
+	if c.SMTPTLS == nil {
+		v := DefaultNotifySMTPTLS
+
+		c.SMTPTLS = &v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyNotifyFrom)); v != "" {
+		c.From = v
+	}
+
+	if c.From == "" {
+		c.From = DefaultNotifyFrom
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyNotifyTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil || v <= 0 {
+			v = DefaultNotifyTimeout
+		}
+
+		c.Timeout = v
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultNotifyTimeout
+	}
+}
+
+// NotifySMTPHost returns the host of the SMTP server through which email
+// notifications are sent. If empty, email notifications are not sent.
+func (c *Config) NotifySMTPHost() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.notify == nil {
+		return DefaultNotifySMTPHost
+	}
+
+	return c.notify.SMTPHost
+}
+
+// NotifySMTPPort returns the port of the SMTP server.
+func (c *Config) NotifySMTPPort() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.notify == nil || c.notify.SMTPPort <= 0 {
+		return DefaultNotifySMTPPort
+	}
+
+	return c.notify.SMTPPort
+}
+
+// NotifySMTPUsername returns the username used to authenticate with the SMTP
+// server. If empty, no authentication is performed.
+func (c *Config) NotifySMTPUsername() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.notify == nil {
+		return DefaultNotifySMTPUsername
+	}
+
+	return c.notify.SMTPUsername
+}
+
+// NotifySMTPPassword returns the password used to authenticate with the SMTP
+// server.
+func (c *Config) NotifySMTPPassword() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.notify == nil {
+		return DefaultNotifySMTPPassword
+	}
+
+	return c.notify.SMTPPassword
+}
+
+// NotifySMTPTLS returns whether connections to the SMTP server must be
+// upgraded to TLS with STARTTLS before any credentials or messages are sent.
+func (c *Config) NotifySMTPTLS() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.notify == nil || c.notify.SMTPTLS == nil {
+		return DefaultNotifySMTPTLS
+	}
+
+	return *c.notify.SMTPTLS
+}
+
+// NotifyFrom returns the sender address of email notifications.
+func (c *Config) NotifyFrom() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.notify == nil || c.notify.From == "" {
+		return DefaultNotifyFrom
+	}
+
+	return c.notify.From
+}
+
+// NotifyTimeout returns the timeout for sending an email notification,
+// including connecting to the SMTP server.
+func (c *Config) NotifyTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.notify == nil || c.notify.Timeout <= 0 {
+		return DefaultNotifyTimeout
+	}
+
+	return c.notify.Timeout
+}
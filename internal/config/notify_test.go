@@ -0,0 +1,72 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+)
+
+func TestNotifyConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.New("")
+
+	cfg.Load([]byte(`notify:
+  smtp_host: smtp.example.com
+  smtp_port: 2525
+  smtp_username: test
+  smtp_tls: false
+  from: alerts@example.com
+  timeout: 5s
+`))
+
+	if v := cfg.NotifySMTPHost(); v != "smtp.example.com" {
+		t.Errorf("Expected host: smtp.example.com, got: %v", v)
+	}
+
+	if v := cfg.NotifySMTPPort(); v != 2525 {
+		t.Errorf("Expected port: 2525, got: %v", v)
+	}
+
+	if v := cfg.NotifySMTPUsername(); v != "test" {
+		t.Errorf("Expected username: test, got: %v", v)
+	}
+
+	if cfg.NotifySMTPTLS() {
+		t.Error("Expected TLS to be disabled")
+	}
+
+	if v := cfg.NotifyFrom(); v != "alerts@example.com" {
+		t.Errorf("Expected from: alerts@example.com, got: %v", v)
+	}
+
+	if v := cfg.NotifyTimeout(); v != time.Second*5 {
+		t.Errorf("Expected timeout: 5s, got: %v", v)
+	}
+
+	if err := cfg.SetSecretValue(config.KeyNotifySMTPPassword,
+		[]byte("password")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.NotifySMTPPassword(); v != "password" {
+		t.Errorf("Expected password: password, got: %v", v)
+	}
+
+	def := config.New("")
+
+	def.Load(nil)
+
+	if v := def.NotifySMTPHost(); v != config.DefaultNotifySMTPHost {
+		t.Errorf("Expected host: %v, got: %v", config.DefaultNotifySMTPHost, v)
+	}
+
+	if !def.NotifySMTPTLS() {
+		t.Error("Expected TLS to be enabled")
+	}
+
+	if v := def.NotifyFrom(); v != config.DefaultNotifyFrom {
+		t.Errorf("Expected from: %v, got: %v", config.DefaultNotifyFrom, v)
+	}
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	RepoCredentialSSH       = "ssh"
+	RepoCredentialGitHubApp = "github_app"
+	RepoCredentialOAuth     = "oauth"
+)
+
+const (
+	KeyRepoCredentials = "repo/credentials"
+	KeyRepoShallow     = "repo/shallow"
+	KeyRepoSparseDirs  = "repo/sparse_dirs"
+
+	DefaultRepoGitHubAPIURL = "https://api.github.com"
+	DefaultRepoShallow      = false
+)
+
+// RepoCredential values represent a named credential used to access git
+// repositories, so that repository URLs do not need to contain passwords. The
+// private key is an SSH private key, for SSH credentials, or the GitHub App
+// private key, for GitHub App credentials.
+type RepoCredential struct {
+	Type           string `json:"type,omitempty"            yaml:"type,omitempty"`
+	Username       string `json:"username,omitempty"        yaml:"username,omitempty"`
+	Token          string `json:"token,omitempty"           yaml:"token,omitempty"`
+	PrivateKey     string `json:"private_key,omitempty"     yaml:"private_key,omitempty"`
+	Passphrase     string `json:"passphrase,omitempty"      yaml:"passphrase,omitempty"`
+	KnownHostsFile string `json:"known_hosts_file,omitempty" yaml:"known_hosts_file,omitempty"`
+	AppID          int64  `json:"app_id,omitempty"          yaml:"app_id,omitempty"`
+	InstallationID int64  `json:"installation_id,omitempty" yaml:"installation_id,omitempty"`
+	APIURL         string `json:"api_url,omitempty"         yaml:"api_url,omitempty"`
+}
+
+// RepoConfig values represent git repository access configuration data.
+type RepoConfig struct {
+	Credentials map[string]*RepoCredential `json:"credentials,omitempty" yaml:"credentials,omitempty"`
+	Shallow     bool                       `json:"shallow,omitempty"     yaml:"shallow,omitempty"`
+	SparseDirs  []string                   `json:"sparse_dirs,omitempty" yaml:"sparse_dirs,omitempty"`
+}
+
+// Load reads configuration data from environment variables and applies defaults
+// for any missing or invalid configuration data. Credentials are only read from
+// configuration files, and secrets loaded from an external provider.
+func (c *RepoConfig) Load() {
+	if v := os.Getenv(ReplaceEnv(KeyRepoShallow)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultRepoShallow
+		}
+
+		c.Shallow = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyRepoSparseDirs)); v != "" {
+		c.SparseDirs = strings.Fields(v)
+	}
+
+	if c.SparseDirs == nil {
+		c.SparseDirs = []string{}
+	}
+
+	if c.Credentials == nil {
+		c.Credentials = map[string]*RepoCredential{}
+	}
+
+	for name, rc := range c.Credentials {
+		if rc == nil {
+			delete(c.Credentials, name)
+
+			continue
+		}
+
+		rc.Type = strings.ToLower(rc.Type)
+
+		switch rc.Type {
+		case RepoCredentialSSH, RepoCredentialGitHubApp, RepoCredentialOAuth:
+		default:
+			delete(c.Credentials, name)
+
+			continue
+		}
+
+		if rc.Type == RepoCredentialGitHubApp && rc.APIURL == "" {
+			rc.APIURL = DefaultRepoGitHubAPIURL
+		}
+	}
+}
+
+// RepoShallow returns whether git repositories are cloned and fetched with a
+// depth of one commit, on a single branch, rather than with their full history.
+func (c *Config) RepoShallow() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.repo == nil {
+		return DefaultRepoShallow
+	}
+
+	return c.repo.Shallow
+}
+
+// RepoSparseDirs returns the directories, relative to the repository path,
+// which are checked out from git repositories. If empty, every file is checked
+// out.
+func (c *Config) RepoSparseDirs() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.repo == nil {
+		return []string{}
+	}
+
+	return slices.Clone(c.repo.SparseDirs)
+}
+
+// RepoCredential returns a copy of the named repository credential, or nil, if
+// no credential with the name is configured. A copy is returned, since the
+// credential values may be refreshed from a secret provider while it is used.
+func (c *Config) RepoCredential(name string) *RepoCredential {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.repo == nil {
+		return nil
+	}
+
+	rc, ok := c.repo.Credentials[name]
+	if !ok || rc == nil {
+		return nil
+	}
+
+	res := *rc
+
+	return &res
+}
+
+// setRepoCredentialValue applies a secret value to a field of a named
+// repository credential, using a key formatted as
+// repo/credentials/{name}/{field}. It must be called with the lock held.
+func (c *Config) setRepoCredentialValue(key string, v []byte) error {
+	name, field, ok := strings.Cut(strings.TrimPrefix(key,
+		KeyRepoCredentials+"/"), "/")
+	if !ok || name == "" {
+		return fmt.Errorf("unsupported secret key: %s", key)
+	}
+
+	if c.repo == nil {
+		return fmt.Errorf("unknown repository credential: %s", name)
+	}
+
+	rc, ok := c.repo.Credentials[name]
+	if !ok || rc == nil {
+		return fmt.Errorf("unknown repository credential: %s", name)
+	}
+
+	switch field {
+	case "token":
+		rc.Token = string(v)
+	case "private_key":
+		rc.PrivateKey = string(v)
+	case "passphrase":
+		rc.Passphrase = string(v)
+	default:
+		return fmt.Errorf("unsupported secret key: %s", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+)
+
+func TestRepoConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.New("")
+
+	cfg.Load([]byte(`repo:
+  shallow: true
+  sparse_dirs: [resources]
+  credentials:
+    app:
+      type: GitHub_App
+      app_id: 1
+      installation_id: 2
+    deploy:
+      type: ssh
+      username: git
+    invalid:
+      type: password
+`))
+
+	if !cfg.RepoShallow() {
+		t.Error("Expected shallow repositories")
+	}
+
+	if v := cfg.RepoSparseDirs(); len(v) != 1 || v[0] != "resources" {
+		t.Errorf("Expected sparse dirs: [resources], got: %v", v)
+	}
+
+	rc := cfg.RepoCredential("app")
+	if rc == nil {
+		t.Fatal("Expected app credential")
+	}
+
+	if rc.Type != config.RepoCredentialGitHubApp {
+		t.Errorf("Expected type: %v, got: %v",
+			config.RepoCredentialGitHubApp, rc.Type)
+	}
+
+	if rc.APIURL != config.DefaultRepoGitHubAPIURL {
+		t.Errorf("Expected API URL: %v, got: %v",
+			config.DefaultRepoGitHubAPIURL, rc.APIURL)
+	}
+
+	if cfg.RepoCredential("invalid") != nil {
+		t.Error("Expected invalid credential to be ignored")
+	}
+
+	if err := cfg.SetSecretValue(config.KeyRepoCredentials+"/deploy/private_key",
+		[]byte("key")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.RepoCredential("deploy").PrivateKey; v != "key" {
+		t.Errorf("Expected private key: key, got: %v", v)
+	}
+
+	if err := cfg.SetSecretValue(config.KeyRepoCredentials+"/missing/token",
+		[]byte("token")); err == nil {
+		t.Error("Expected error for unknown credential")
+	}
+
+	if err := cfg.SetSecretValue(config.KeyRepoCredentials+"/deploy/username",
+		[]byte("user")); err == nil {
+		t.Error("Expected error for unsupported field")
+	}
+}
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	SecretProviderFile  = "file"
+	SecretProviderVault = "vault"
+	SecretProviderAWS   = "aws"
+)
+
+const (
+	KeySecretProvider       = "secret/provider"
+	KeySecretRefresh        = "secret/refresh"
+	KeySecretNames          = "secret/names"
+	KeySecretFileDir        = "secret/file_dir"
+	KeySecretVaultAddress   = "secret/vault_address"
+	KeySecretVaultToken     = "secret/vault_token"
+	KeySecretVaultTokenFile = "secret/vault_token_file"
+	KeySecretVaultMount     = "secret/vault_mount"
+	KeySecretAWSRegion      = "secret/aws_region"
+	KeySecretAWSEndpoint    = "secret/aws_endpoint"
+
+	DefaultSecretProvider       = ""
+	DefaultSecretRefresh        = time.Minute * 5
+	DefaultSecretFileDir        = "/run/secrets"
+	DefaultSecretVaultAddress   = "http://localhost:8200"
+	DefaultSecretVaultToken     = ""
+	DefaultSecretVaultTokenFile = ""
+	DefaultSecretVaultMount     = "secret"
+	DefaultSecretAWSRegion      = "us-east-1"
+	DefaultSecretAWSEndpoint    = ""
+)
+
+// SecretConfig values represent external secret provider configuration data.
+type SecretConfig struct {
+	Provider       string            `json:"provider,omitempty"         yaml:"provider,omitempty"`
+	Refresh        time.Duration     `json:"refresh,omitempty"          yaml:"refresh,omitempty"`
+	Names          map[string]string `json:"names,omitempty"            yaml:"names,omitempty"`
+	FileDir        string            `json:"file_dir,omitempty"         yaml:"file_dir,omitempty"`
+	VaultAddress   string            `json:"vault_address,omitempty"    yaml:"vault_address,omitempty"`
+	VaultToken     string            `json:"vault_token,omitempty"      yaml:"vault_token,omitempty"`
+	VaultTokenFile string            `json:"vault_token_file,omitempty" yaml:"vault_token_file,omitempty"`
+	VaultMount     string            `json:"vault_mount,omitempty"      yaml:"vault_mount,omitempty"`
+	AWSRegion      string            `json:"aws_region,omitempty"       yaml:"aws_region,omitempty"`
+	AWSEndpoint    string            `json:"aws_endpoint,omitempty"     yaml:"aws_endpoint,omitempty"`
+}
+
+// Load reads configuration data from environment variables and applies defaults
+// for any missing or invalid configuration data.
+func (c *SecretConfig) Load() {
+	if v := os.Getenv(ReplaceEnv(KeySecretProvider)); v != "" {
+		c.Provider = v
+	}
+
+	switch c.Provider {
+	case SecretProviderFile, SecretProviderVault, SecretProviderAWS:
+	default:
+		c.Provider = DefaultSecretProvider
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretRefresh)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultSecretRefresh
+		}
+
+		c.Refresh = v
+	}
+
+	if c.Refresh == 0 {
+		c.Refresh = DefaultSecretRefresh
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretNames)); v != "" {
+		c.Names = map[string]string{}
+
+		for _, kv := range strings.Split(v, ",") {
+			k, n, ok := strings.Cut(kv, "=")
+			if !ok {
+				continue
+			}
+
+			c.Names[strings.TrimSpace(k)] = strings.TrimSpace(n)
+		}
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretFileDir)); v != "" {
+		c.FileDir = v
+	}
+
+	if c.FileDir == "" {
+		c.FileDir = DefaultSecretFileDir
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretVaultAddress)); v != "" {
+		c.VaultAddress = v
+	}
+
+	if c.VaultAddress == "" {
+		c.VaultAddress = DefaultSecretVaultAddress
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretVaultToken)); v != "" {
+		c.VaultToken = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretVaultTokenFile)); v != "" {
+		c.VaultTokenFile = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretVaultMount)); v != "" {
+		c.VaultMount = v
+	}
+
+	if c.VaultMount == "" {
+		c.VaultMount = DefaultSecretVaultMount
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretAWSRegion)); v != "" {
+		c.AWSRegion = v
+	}
+
+	if c.AWSRegion == "" {
+		c.AWSRegion = DefaultSecretAWSRegion
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeySecretAWSEndpoint)); v != "" {
+		c.AWSEndpoint = v
+	}
+}
+
+// SecretProvider returns the external provider from which secrets are loaded,
+// or none, if secrets are only provided by the configuration.
+func (c *Config) SecretProvider() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.secret == nil {
+		return DefaultSecretProvider
+	}
+
+	return c.secret.Provider
+}
+
+// SecretRefresh returns the interval at which secrets are loaded again from
+// the external provider.
+func (c *Config) SecretRefresh() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.secret == nil || c.secret.Refresh <= 0 {
+		return DefaultSecretRefresh
+	}
+
+	return c.secret.Refresh
+}
+
+// SecretNames returns the names, in the external provider, of the secrets
+// loaded, by the configuration key of the value they provide.
+func (c *Config) SecretNames() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+
+	res := map[string]string{}
+
+	if c.secret == nil {
+		return res
+	}
+
+	for k, v := range c.secret.Names {
+		res[k] = v
+	}
+
+	return res
+}
+
+// SecretFileDir returns the directory from which file secrets are read, such
+// as the mount point of a secrets volume.
+func (c *Config) SecretFileDir() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.secret == nil {
+		return DefaultSecretFileDir
+	}
+
+	return c.secret.FileDir
+}
+
+// SecretVaultAddress returns the address of the Vault server secrets are
+// loaded from.
+func (c *Config) SecretVaultAddress() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.secret == nil {
+		return DefaultSecretVaultAddress
+	}
+
+	return c.secret.VaultAddress
+}
+
+// SecretVaultToken returns the token used to authenticate with Vault, read
+// from the token file, if one is configured, so that a token renewed by an
+// agent is used.
+func (c *Config) SecretVaultToken() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.secret == nil {
+		return DefaultSecretVaultToken
+	}
+
+	if c.secret.VaultTokenFile != "" {
+		if b, err := os.ReadFile(c.secret.VaultTokenFile); err == nil {
+			return strings.TrimSpace(string(b))
+		}
+	}
+
+	return c.secret.VaultToken
+}
+
+// SecretVaultMount returns the mount path of the Vault KV version 2 secrets
+// engine secrets are loaded from.
+func (c *Config) SecretVaultMount() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.secret == nil {
+		return DefaultSecretVaultMount
+	}
+
+	return c.secret.VaultMount
+}
+
+// SecretAWSRegion returns the region of the AWS Secrets Manager service
+// secrets are loaded from.
+func (c *Config) SecretAWSRegion() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.secret == nil {
+		return DefaultSecretAWSRegion
+	}
+
+	return c.secret.AWSRegion
+}
+
+// SecretAWSEndpoint returns the URL of the AWS Secrets Manager service, which
+// is the regional endpoint unless another, such as a VPC endpoint, is set.
+func (c *Config) SecretAWSEndpoint() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	region := DefaultSecretAWSRegion
+
+	if c.secret != nil {
+		if c.secret.AWSEndpoint != "" {
+			return c.secret.AWSEndpoint
+		}
+
+		region = c.secret.AWSRegion
+	}
+
+	return "https://secretsmanager." + region + ".amazonaws.com"
+}
+
+// SetSecretValue applies a secret value, loaded from an external provider, to
+// the configuration value with the specified key. Only the database connection
+// and password, account secret pepper, token keys, SMTP password, and the
+// token, private key and passphrase of repository credentials can be set.
+func (c *Config) SetSecretValue(key string, v []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	switch key {
+	case KeyDBConn, KeyDBPassword:
+		if c.db == nil {
+			c.db = &DBConfig{}
+		}
+
+		if key == KeyDBConn {
+			c.db.Conn = string(v)
+		} else {
+			c.db.Password = string(v)
+		}
+	case KeyAuthAccountPepper, KeyAuthTokenHMACKey, KeyAuthTokenPrivateKey,
+		KeyAuthTokenPublicKey:
+		if c.auth == nil {
+			c.auth = &AuthConfig{}
+		}
+
+		switch key {
+		case KeyAuthAccountPepper:
+			c.auth.AccountPepper = v
+		case KeyAuthTokenHMACKey:
+			c.auth.TokenHMACKey = v
+		case KeyAuthTokenPrivateKey:
+			c.auth.TokenPrivateKey = v
+		default:
+			c.auth.TokenPublicKey = v
+		}
+	case KeyNotifySMTPPassword:
+		if c.notify == nil {
+			c.notify = &NotifyConfig{}
+		}
+
+		c.notify.SMTPPassword = string(v)
+	default:
+		if strings.HasPrefix(key, KeyRepoCredentials+"/") {
+			return c.setRepoCredentialValue(key, v)
+		}
+
+		return fmt.Errorf("unsupported secret key: %s", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+)
+
+func TestSecretConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	cfg.SetSecret(&config.SecretConfig{
+		Provider:     config.SecretProviderVault,
+		Refresh:      time.Minute,
+		Names:        map[string]string{config.KeyDBConn: "apigo#dsn"},
+		VaultAddress: "http://vault:8200",
+		VaultToken:   "test",
+		VaultMount:   "kv",
+		AWSRegion:    "eu-west-1",
+	})
+
+	if v := cfg.SecretProvider(); v != config.SecretProviderVault {
+		t.Errorf("Expected provider: vault, got: %v", v)
+	}
+
+	if v := cfg.SecretRefresh(); v != time.Minute {
+		t.Errorf("Expected refresh: 1m, got: %v", v)
+	}
+
+	if v := cfg.SecretNames()[config.KeyDBConn]; v != "apigo#dsn" {
+		t.Errorf("Expected name: apigo#dsn, got: %v", v)
+	}
+
+	if v := cfg.SecretVaultToken(); v != "test" {
+		t.Errorf("Expected vault token: test, got: %v", v)
+	}
+
+	exp := "https://secretsmanager.eu-west-1.amazonaws.com"
+
+	if v := cfg.SecretAWSEndpoint(); v != exp {
+		t.Errorf("Expected AWS endpoint: %v, got: %v", exp, v)
+	}
+
+	if err := cfg.SetSecretValue(config.KeyDBConn,
+		[]byte("postgres://test")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.DBConn(config.DBModeNormal); v != "postgres://test" {
+		t.Errorf("Expected connection: postgres://test, got: %v", v)
+	}
+
+	if err := cfg.SetSecretValue(config.KeyAuthAccountPepper,
+		[]byte("pepper")); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.AuthAccountPepper(); string(v) != "pepper" {
+		t.Errorf("Expected pepper: pepper, got: %s", v)
+	}
+
+	if err := cfg.SetSecretValue(config.KeyLogLevel, nil); err == nil {
+		t.Error("Expected error for unsupported key, got: nil")
+	}
+}
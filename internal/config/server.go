@@ -3,9 +3,15 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	ServerClientAuthRequire  = "require"
+	ServerClientAuthOptional = "optional"
+)
+
 const (
 	KeyServerAddress        = "server/address"
 	KeyServerCert           = "server/certificate"
@@ -15,6 +21,29 @@ const (
 	KeyServerHost           = "server/host"
 	KeyServerPathPrefix     = "server/path_prefix"
 	KeyServerMaxRequestSize = "server/max_request_size"
+	KeyServerStaticMaxAge   = "server/static_max_age"
+	KeyServerCDNURL         = "server/cdn_url"
+	KeyServerCDNKey         = "server/cdn_key"
+	KeyServerCDNTTL         = "server/cdn_ttl"
+	KeyServerRecordDir      = "server/record_dir"
+	KeyServerReplayDir      = "server/replay_dir"
+	KeyServerHealthTimeout  = "server/health_timeout"
+	KeyServerHealthRepo     = "server/health_repo"
+	KeyServerDrainTimeout   = "server/drain_timeout"
+	KeyServerClientCA       = "server/client_ca"
+	KeyServerClientAuth     = "server/client_auth"
+	KeyServerReadTimeout    = "server/read_timeout"
+	KeyServerWriteTimeout   = "server/write_timeout"
+	KeyServerMaxHeaderBytes = "server/max_header_bytes"
+	KeyServerMaxStreams     = "server/max_streams"
+	KeyServerH2C            = "server/h2c"
+	KeyServerEnvelope       = "server/envelope"
+	KeyServerIdempotency    = "server/idempotency_window"
+	KeyServerSearchDepth    = "server/search_max_depth"
+	KeyServerSearchTerms    = "server/search_max_terms"
+	KeyServerSearchWildcard = "server/search_no_lead_wildcard"
+	KeyServerNoUI           = "server/no_ui"
+	KeyServerTrustedProxies = "server/trusted_proxies"
 
 	DefaultServerAddress        = ":8080"
 	DefaultServerCert           = ""
@@ -24,6 +53,28 @@ const (
 	DefaultServerHost           = "apigo.io"
 	DefaultServerPathPrefix     = "/api/v1"
 	DefaultServerMaxRequestSize = int64(20971520) // 20 MB
+	DefaultServerStaticMaxAge   = time.Hour
+	DefaultServerCDNURL         = ""
+	DefaultServerCDNKey         = ""
+	DefaultServerCDNTTL         = time.Hour * 24
+	DefaultServerRecordDir      = ""
+	DefaultServerReplayDir      = ""
+	DefaultServerHealthTimeout  = time.Second * 5
+	DefaultServerHealthRepo     = ""
+	DefaultServerDrainTimeout   = time.Minute * 5
+	DefaultServerClientCA       = ""
+	DefaultServerClientAuth     = ServerClientAuthRequire
+	DefaultServerReadTimeout    = time.Duration(0)
+	DefaultServerWriteTimeout   = time.Duration(0)
+	DefaultServerMaxHeaderBytes = 1 << 20 // 1 MB
+	DefaultServerMaxStreams     = uint32(250)
+	DefaultServerH2C            = false
+	DefaultServerEnvelope       = false
+	DefaultServerIdempotency    = time.Hour * 24
+	DefaultServerSearchDepth    = 8
+	DefaultServerSearchTerms    = 50
+	DefaultServerSearchWildcard = false
+	DefaultServerNoUI           = false
 )
 
 // ServerConfig values represent telemetry configuration data.
@@ -36,6 +87,29 @@ type ServerConfig struct {
 	Host           string        `json:"host,omitempty"             yaml:"host,omitempty"`
 	PathPrefix     string        `json:"path_prefix,omitempty"      yaml:"path_prefix,omitempty"`
 	MaxRequestSize int64         `json:"max_request_size,omitempty" yaml:"max_request_size,omitempty"`
+	StaticMaxAge   time.Duration `json:"static_max_age,omitempty"   yaml:"static_max_age,omitempty"`
+	CDNURL         string        `json:"cdn_url,omitempty"          yaml:"cdn_url,omitempty"`
+	CDNKey         string        `json:"cdn_key,omitempty"          yaml:"cdn_key,omitempty"`
+	CDNTTL         time.Duration `json:"cdn_ttl,omitempty"          yaml:"cdn_ttl,omitempty"`
+	RecordDir      string        `json:"record_dir,omitempty"       yaml:"record_dir,omitempty"`
+	ReplayDir      string        `json:"replay_dir,omitempty"       yaml:"replay_dir,omitempty"`
+	HealthTimeout  time.Duration `json:"health_timeout,omitempty"   yaml:"health_timeout,omitempty"`
+	HealthRepo     string        `json:"health_repo,omitempty"      yaml:"health_repo,omitempty"`
+	DrainTimeout   time.Duration `json:"drain_timeout,omitempty"    yaml:"drain_timeout,omitempty"`
+	ClientCA       string        `json:"client_ca,omitempty"        yaml:"client_ca,omitempty"`
+	ClientAuth     string        `json:"client_auth,omitempty"      yaml:"client_auth,omitempty"`
+	ReadTimeout    time.Duration `json:"read_timeout,omitempty"     yaml:"read_timeout,omitempty"`
+	WriteTimeout   time.Duration `json:"write_timeout,omitempty"    yaml:"write_timeout,omitempty"`
+	MaxHeaderBytes int           `json:"max_header_bytes,omitempty" yaml:"max_header_bytes,omitempty"`
+	MaxStreams     uint32        `json:"max_streams,omitempty"      yaml:"max_streams,omitempty"`
+	H2C            bool          `json:"h2c,omitempty"              yaml:"h2c,omitempty"`
+	Envelope       bool          `json:"envelope,omitempty"         yaml:"envelope,omitempty"`
+	Idempotency    time.Duration `json:"idempotency,omitempty"      yaml:"idempotency,omitempty"`
+	SearchDepth    int           `json:"search_max_depth,omitempty" yaml:"search_max_depth,omitempty"`
+	SearchTerms    int           `json:"search_max_terms,omitempty" yaml:"search_max_terms,omitempty"`
+	SearchWildcard bool          `json:"no_lead_wildcard,omitempty" yaml:"no_lead_wildcard,omitempty"`
+	NoUI           bool          `json:"no_ui,omitempty"            yaml:"no_ui,omitempty"`
+	TrustedProxies []string      `json:"trusted_proxies,omitempty"  yaml:"trusted_proxies,omitempty"`
 }
 
 // Load reads configuration data from environment variables and applies defaults
@@ -121,6 +195,251 @@ func (c *ServerConfig) Load() {
 	if c.MaxRequestSize == 0 {
 		c.MaxRequestSize = DefaultServerMaxRequestSize
 	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerStaticMaxAge)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultServerStaticMaxAge
+		}
+
+		c.StaticMaxAge = v
+	}
+
+	if c.StaticMaxAge == 0 {
+		c.StaticMaxAge = DefaultServerStaticMaxAge
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerCDNURL)); v != "" {
+		c.CDNURL = v
+	}
+
+	if c.CDNURL == "" {
+		c.CDNURL = DefaultServerCDNURL
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerCDNKey)); v != "" {
+		c.CDNKey = v
+	}
+
+	if c.CDNKey == "" {
+		c.CDNKey = DefaultServerCDNKey
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerCDNTTL)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultServerCDNTTL
+		}
+
+		c.CDNTTL = v
+	}
+
+	if c.CDNTTL == 0 {
+		c.CDNTTL = DefaultServerCDNTTL
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerRecordDir)); v != "" {
+		c.RecordDir = v
+	}
+
+	if c.RecordDir == "" {
+		c.RecordDir = DefaultServerRecordDir
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerReplayDir)); v != "" {
+		c.ReplayDir = v
+	}
+
+	if c.ReplayDir == "" {
+		c.ReplayDir = DefaultServerReplayDir
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerHealthTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultServerHealthTimeout
+		}
+
+		c.HealthTimeout = v
+	}
+
+	if c.HealthTimeout == 0 {
+		c.HealthTimeout = DefaultServerHealthTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerHealthRepo)); v != "" {
+		c.HealthRepo = v
+	}
+
+	if c.HealthRepo == "" {
+		c.HealthRepo = DefaultServerHealthRepo
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerDrainTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultServerDrainTimeout
+		}
+
+		c.DrainTimeout = v
+	}
+
+	if c.DrainTimeout <= 0 {
+		c.DrainTimeout = DefaultServerDrainTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerClientCA)); v != "" {
+		c.ClientCA = v
+	}
+
+	if c.ClientCA == "" {
+		c.ClientCA = DefaultServerClientCA
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerClientAuth)); v != "" {
+		c.ClientAuth = v
+	}
+
+	switch c.ClientAuth {
+	case ServerClientAuthRequire, ServerClientAuthOptional:
+	default:
+		c.ClientAuth = DefaultServerClientAuth
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerReadTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultServerReadTimeout
+		}
+
+		c.ReadTimeout = v
+	}
+
+	if c.ReadTimeout < 0 {
+		c.ReadTimeout = DefaultServerReadTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerWriteTimeout)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultServerWriteTimeout
+		}
+
+		c.WriteTimeout = v
+	}
+
+	if c.WriteTimeout < 0 {
+		c.WriteTimeout = DefaultServerWriteTimeout
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerMaxHeaderBytes)); v != "" {
+		v, err := strconv.Atoi(v)
+		if err != nil {
+			v = DefaultServerMaxHeaderBytes
+		}
+
+		c.MaxHeaderBytes = v
+	}
+
+	if c.MaxHeaderBytes <= 0 {
+		c.MaxHeaderBytes = DefaultServerMaxHeaderBytes
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerMaxStreams)); v != "" {
+		v, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			v = uint64(DefaultServerMaxStreams)
+		}
+
+		c.MaxStreams = uint32(v)
+	}
+
+	if c.MaxStreams == 0 {
+		c.MaxStreams = DefaultServerMaxStreams
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerH2C)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultServerH2C
+		}
+
+		c.H2C = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerEnvelope)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultServerEnvelope
+		}
+
+		c.Envelope = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerIdempotency)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultServerIdempotency
+		}
+
+		c.Idempotency = v
+	}
+
+	if c.Idempotency == 0 {
+		c.Idempotency = DefaultServerIdempotency
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerSearchDepth)); v != "" {
+		v, err := strconv.Atoi(v)
+		if err != nil {
+			v = DefaultServerSearchDepth
+		}
+
+		c.SearchDepth = v
+	}
+
+	if c.SearchDepth == 0 {
+		c.SearchDepth = DefaultServerSearchDepth
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerSearchTerms)); v != "" {
+		v, err := strconv.Atoi(v)
+		if err != nil {
+			v = DefaultServerSearchTerms
+		}
+
+		c.SearchTerms = v
+	}
+
+	if c.SearchTerms == 0 {
+		c.SearchTerms = DefaultServerSearchTerms
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerSearchWildcard)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultServerSearchWildcard
+		}
+
+		c.SearchWildcard = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerNoUI)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultServerNoUI
+		}
+
+		c.NoUI = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyServerTrustedProxies)); v != "" {
+		c.TrustedProxies = strings.Fields(v)
+	}
+
+	if c.TrustedProxies == nil {
+		c.TrustedProxies = []string{}
+	}
 }
 
 // ServerAddress returns the address of the collector where metrics data is
@@ -223,3 +542,315 @@ func (c *Config) ServerMaxRequestSize() int64 {
 
 	return c.server.MaxRequestSize
 }
+
+// ServerStaticMaxAge returns the maximum time for which clients may cache
+// embedded static files. If not positive, static files are not cached.
+func (c *Config) ServerStaticMaxAge() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerStaticMaxAge
+	}
+
+	return c.server.StaticMaxAge
+}
+
+// ServerCDNURL returns the base URL of a CDN serving the embedded static
+// files. If empty, static files are served directly.
+func (c *Config) ServerCDNURL() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerCDNURL
+	}
+
+	return c.server.CDNURL
+}
+
+// ServerCDNKey returns the key used to sign CDN URLs. If empty, CDN URLs are
+// not signed.
+func (c *Config) ServerCDNKey() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerCDNKey
+	}
+
+	return c.server.CDNKey
+}
+
+// ServerCDNTTL returns the duration for which signed CDN URLs are valid.
+func (c *Config) ServerCDNTTL() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerCDNTTL
+	}
+
+	return c.server.CDNTTL
+}
+
+// ServerRecordDir returns the directory into which sanitized request and
+// response fixtures are recorded. If empty, no fixtures are recorded.
+func (c *Config) ServerRecordDir() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerRecordDir
+	}
+
+	return c.server.RecordDir
+}
+
+// ServerReplayDir returns the directory from which recorded fixtures are
+// replayed. If set, the server responds to requests only using the recorded
+// fixtures.
+func (c *Config) ServerReplayDir() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerReplayDir
+	}
+
+	return c.server.ReplayDir
+}
+
+// ServerHealthTimeout returns the maximum duration of each dependency check
+// performed by readiness health checks.
+func (c *Config) ServerHealthTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil || c.server.HealthTimeout == 0 {
+		return DefaultServerHealthTimeout
+	}
+
+	return c.server.HealthTimeout
+}
+
+// ServerHealthRepo returns the URL of a repository whose connectivity is
+// verified by readiness health checks. If empty, no repository is checked.
+func (c *Config) ServerHealthRepo() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerHealthRepo
+	}
+
+	return c.server.HealthRepo
+}
+
+// ServerDrainTimeout returns the maximum duration the server waits for
+// in-flight requests and background work to complete during a graceful
+// shutdown, before remaining connections are closed.
+func (c *Config) ServerDrainTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil || c.server.DrainTimeout <= 0 {
+		return DefaultServerDrainTimeout
+	}
+
+	return c.server.DrainTimeout
+}
+
+// ServerClientCA returns the name of a file containing the PEM encoded
+// certificate authorities used to verify client certificates. If set, the
+// server uses mutual TLS.
+func (c *Config) ServerClientCA() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerClientCA
+	}
+
+	return c.server.ClientCA
+}
+
+// ServerClientAuth returns whether client certificates are required, or only
+// verified if presented, when the server uses mutual TLS.
+func (c *Config) ServerClientAuth() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerClientAuth
+	}
+
+	switch c.server.ClientAuth {
+	case ServerClientAuthRequire, ServerClientAuthOptional:
+		return c.server.ClientAuth
+	default:
+		return DefaultServerClientAuth
+	}
+}
+
+// ServerReadTimeout returns the maximum duration for reading an entire
+// request, including the body. Zero means there is no limit.
+func (c *Config) ServerReadTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil || c.server.ReadTimeout < 0 {
+		return DefaultServerReadTimeout
+	}
+
+	return c.server.ReadTimeout
+}
+
+// ServerWriteTimeout returns the maximum duration before timing out writes of
+// a response. Zero means there is no limit.
+func (c *Config) ServerWriteTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil || c.server.WriteTimeout < 0 {
+		return DefaultServerWriteTimeout
+	}
+
+	return c.server.WriteTimeout
+}
+
+// ServerMaxHeaderBytes returns the maximum number of bytes the server will read
+// parsing request headers.
+func (c *Config) ServerMaxHeaderBytes() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil || c.server.MaxHeaderBytes <= 0 {
+		return DefaultServerMaxHeaderBytes
+	}
+
+	return c.server.MaxHeaderBytes
+}
+
+// ServerMaxStreams returns the maximum number of concurrent HTTP/2 streams
+// each client connection may have open.
+func (c *Config) ServerMaxStreams() uint32 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil || c.server.MaxStreams == 0 {
+		return DefaultServerMaxStreams
+	}
+
+	return c.server.MaxStreams
+}
+
+// ServerH2C returns whether the server accepts unencrypted HTTP/2 (h2c)
+// connections. This should only be enabled for internal deployments behind a
+// trusted load balancer.
+func (c *Config) ServerH2C() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerH2C
+	}
+
+	return c.server.H2C
+}
+
+// ServerEnvelope returns whether list responses are wrapped in an envelope,
+// containing the data, paging links and any summary, by default. Clients can
+// override this for each request using the X-Envelope request header.
+func (c *Config) ServerEnvelope() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerEnvelope
+	}
+
+	return c.server.Envelope
+}
+
+// ServerIdempotency returns the period for which the responses of requests
+// made with an Idempotency-Key header are retained, and replayed when the
+// requests are retried. If it is negative, responses are not retained.
+func (c *Config) ServerIdempotency() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerIdempotency
+	}
+
+	return c.server.Idempotency
+}
+
+// ServerSearchDepth returns the maximum number of levels of nested operations
+// in the search queries of requests. If it is negative, the depth of search
+// queries is not limited.
+func (c *Config) ServerSearchDepth() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerSearchDepth
+	}
+
+	return c.server.SearchDepth
+}
+
+// ServerSearchTerms returns the maximum number of terms in the search queries
+// of requests. If it is negative, the number of terms is not limited.
+func (c *Config) ServerSearchTerms() int {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerSearchTerms
+	}
+
+	return c.server.SearchTerms
+}
+
+// ServerSearchWildcard returns whether search queries of requests are rejected
+// if they match values beginning with a wildcard, which require every row to
+// be scanned.
+func (c *Config) ServerSearchWildcard() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerSearchWildcard
+	}
+
+	return c.server.SearchWildcard
+}
+
+// ServerNoUI returns whether the embedded admin UI is disabled, so that it is
+// not served by the service.
+func (c *Config) ServerNoUI() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return DefaultServerNoUI
+	}
+
+	return c.server.NoUI
+}
+
+// ServerTrustedProxies returns the networks, in CIDR notation, of the proxies
+// trusted to report the addresses of clients in the X-Forwarded-For header of
+// requests. The header is ignored for requests from any other address.
+func (c *Config) ServerTrustedProxies() []string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.server == nil {
+		return []string{}
+	}
+
+	return c.server.TrustedProxies
+}
@@ -23,6 +23,29 @@ func TestServerConfig(t *testing.T) {
 		Host:           "test.com",
 		PathPrefix:     "/api/v2",
 		MaxRequestSize: 10,
+		StaticMaxAge:   time.Minute,
+		CDNURL:         "https://cdn.test.com",
+		CDNKey:         "test",
+		CDNTTL:         time.Hour,
+		RecordDir:      "record",
+		ReplayDir:      "replay",
+		HealthTimeout:  time.Second,
+		HealthRepo:     "test://test",
+		DrainTimeout:   time.Minute,
+		ClientCA:       "ca.pem",
+		ClientAuth:     config.ServerClientAuthOptional,
+		ReadTimeout:    time.Second * 20,
+		WriteTimeout:   time.Second * 40,
+		MaxHeaderBytes: 4096,
+		MaxStreams:     100,
+		H2C:            true,
+		Envelope:       true,
+		Idempotency:    time.Hour,
+		SearchDepth:    4,
+		SearchTerms:    -1,
+		SearchWildcard: true,
+		NoUI:           true,
+		TrustedProxies: []string{"10.0.0.0/8"},
 	})
 
 	if cfg.ServerAddress() != ":8090" {
@@ -58,4 +81,110 @@ func TestServerConfig(t *testing.T) {
 		t.Errorf("Expected max request size: 10, got: %v",
 			cfg.ServerMaxRequestSize())
 	}
+
+	if cfg.ServerStaticMaxAge() != time.Minute {
+		t.Errorf("Expected static max age: 1m, got: %v",
+			cfg.ServerStaticMaxAge())
+	}
+
+	if cfg.ServerCDNURL() != "https://cdn.test.com" {
+		t.Errorf("Expected CDN URL: https://cdn.test.com, got: %v",
+			cfg.ServerCDNURL())
+	}
+
+	if cfg.ServerCDNKey() != "test" {
+		t.Errorf("Expected CDN key: test, got: %v", cfg.ServerCDNKey())
+	}
+
+	if cfg.ServerCDNTTL() != time.Hour {
+		t.Errorf("Expected CDN TTL: 1h, got: %v", cfg.ServerCDNTTL())
+	}
+
+	if cfg.ServerRecordDir() != "record" {
+		t.Errorf("Expected record dir: record, got: %v",
+			cfg.ServerRecordDir())
+	}
+
+	if cfg.ServerReplayDir() != "replay" {
+		t.Errorf("Expected replay dir: replay, got: %v",
+			cfg.ServerReplayDir())
+	}
+
+	if cfg.ServerHealthTimeout() != time.Second {
+		t.Errorf("Expected health timeout: 1s, got: %v",
+			cfg.ServerHealthTimeout())
+	}
+
+	if cfg.ServerHealthRepo() != "test://test" {
+		t.Errorf("Expected health repo: test://test, got: %v",
+			cfg.ServerHealthRepo())
+	}
+
+	if cfg.ServerDrainTimeout() != time.Minute {
+		t.Errorf("Expected drain timeout: 1m, got: %v",
+			cfg.ServerDrainTimeout())
+	}
+
+	if cfg.ServerClientCA() != "ca.pem" {
+		t.Errorf("Expected client CA: ca.pem, got: %v", cfg.ServerClientCA())
+	}
+
+	if cfg.ServerClientAuth() != config.ServerClientAuthOptional {
+		t.Errorf("Expected client auth: %v, got: %v",
+			config.ServerClientAuthOptional, cfg.ServerClientAuth())
+	}
+
+	if cfg.ServerReadTimeout() != time.Second*20 {
+		t.Errorf("Expected read timeout: 20s, got: %v",
+			cfg.ServerReadTimeout())
+	}
+
+	if cfg.ServerWriteTimeout() != time.Second*40 {
+		t.Errorf("Expected write timeout: 40s, got: %v",
+			cfg.ServerWriteTimeout())
+	}
+
+	if cfg.ServerMaxHeaderBytes() != 4096 {
+		t.Errorf("Expected max header bytes: 4096, got: %v",
+			cfg.ServerMaxHeaderBytes())
+	}
+
+	if cfg.ServerMaxStreams() != 100 {
+		t.Errorf("Expected max streams: 100, got: %v", cfg.ServerMaxStreams())
+	}
+
+	if !cfg.ServerH2C() {
+		t.Errorf("Expected h2c: true, got: %v", cfg.ServerH2C())
+	}
+
+	if !cfg.ServerEnvelope() {
+		t.Errorf("Expected envelope: true, got: %v", cfg.ServerEnvelope())
+	}
+
+	if cfg.ServerIdempotency() != time.Hour {
+		t.Errorf("Expected idempotency: 1h, got: %v",
+			cfg.ServerIdempotency())
+	}
+
+	if cfg.ServerSearchDepth() != 4 {
+		t.Errorf("Expected search depth: 4, got: %v", cfg.ServerSearchDepth())
+	}
+
+	if cfg.ServerSearchTerms() != -1 {
+		t.Errorf("Expected search terms: -1, got: %v", cfg.ServerSearchTerms())
+	}
+
+	if !cfg.ServerSearchWildcard() {
+		t.Errorf("Expected search wildcard: true, got: %v",
+			cfg.ServerSearchWildcard())
+	}
+
+	if !cfg.ServerNoUI() {
+		t.Errorf("Expected no UI: true, got: %v", cfg.ServerNoUI())
+	}
+
+	if cfg.ServerTrustedProxies()[0] != "10.0.0.0/8" {
+		t.Errorf("Expected trusted proxies: 10.0.0.0/8, got: %v",
+			cfg.ServerTrustedProxies()[0])
+	}
 }
@@ -11,11 +11,35 @@ const (
 	KeyServiceMaintenance    = "service/maintenance"
 	KeyImportInterval        = "service/import_interval"
 	KeyResourceDataRetention = "resource/data_retention"
+	KeyResourceCascadeStatus = "resource/cascade_status"
+	KeyPurgeInterval         = "service/purge_interval"
+	KeyClearInterval         = "service/clear_interval"
+	KeyAlertInterval         = "service/alert_interval"
+	KeyAgentStaleAfter       = "agent/stale_after"
+	KeyAgentCheckInterval    = "agent/check_interval"
+	KeyAccountTemplateRepo   = "account/template_repo"
+	KeyUsageInterval         = "service/usage_interval"
+	KeyQuotaDailyRequests    = "quota/daily_requests"
+	KeyQuotaMonthlyRequests  = "quota/monthly_requests"
+	KeyQuotaDailyIngest      = "quota/daily_ingest"
+	KeyQuotaMonthlyIngest    = "quota/monthly_ingest"
 
 	DefaultServiceName           = "api"
 	DefaultServiceMaintenance    = false
 	DefaultImportInterval        = time.Minute * 5
 	DefaultResourceDataRetention = time.Hour * 720 // 30d
+	DefaultResourceCascadeStatus = false
+	DefaultPurgeInterval         = time.Hour
+	DefaultClearInterval         = time.Second * 10
+	DefaultAlertInterval         = time.Minute
+	DefaultAgentStaleAfter       = time.Minute * 5
+	DefaultAgentCheckInterval    = time.Minute
+	DefaultAccountTemplateRepo   = ""
+	DefaultUsageInterval         = time.Minute
+	DefaultQuotaDailyRequests    = 0
+	DefaultQuotaMonthlyRequests  = 0
+	DefaultQuotaDailyIngest      = 0
+	DefaultQuotaMonthlyIngest    = 0
 )
 
 // ServiceConfig values represent telemetry configuration data.
@@ -24,6 +48,18 @@ type ServiceConfig struct {
 	Maintenance           bool          `json:"maintenance,omitempty"             yaml:"maintenance,omitempty"`
 	ImportInterval        time.Duration `json:"import_interval,omitempty"         yaml:"import_interval,omitempty"`
 	ResourceDataRetention time.Duration `json:"resource_data_retention,omitempty" yaml:"resource_data_retention,omitempty"`
+	ResourceCascadeStatus bool          `json:"resource_cascade_status,omitempty" yaml:"resource_cascade_status,omitempty"`
+	PurgeInterval         time.Duration `json:"purge_interval,omitempty"          yaml:"purge_interval,omitempty"`
+	ClearInterval         time.Duration `json:"clear_interval,omitempty"          yaml:"clear_interval,omitempty"`
+	AlertInterval         time.Duration `json:"alert_interval,omitempty"          yaml:"alert_interval,omitempty"`
+	AgentStaleAfter       time.Duration `json:"agent_stale_after,omitempty"       yaml:"agent_stale_after,omitempty"`
+	AgentCheckInterval    time.Duration `json:"agent_check_interval,omitempty"    yaml:"agent_check_interval,omitempty"`
+	AccountTemplateRepo   string        `json:"account_template_repo,omitempty"   yaml:"account_template_repo,omitempty"`
+	UsageInterval         time.Duration `json:"usage_interval,omitempty"          yaml:"usage_interval,omitempty"`
+	QuotaDailyRequests    int64         `json:"quota_daily_requests,omitempty"    yaml:"quota_daily_requests,omitempty"`
+	QuotaMonthlyRequests  int64         `json:"quota_monthly_requests,omitempty"  yaml:"quota_monthly_requests,omitempty"`
+	QuotaDailyIngest      int64         `json:"quota_daily_ingest,omitempty"      yaml:"quota_daily_ingest,omitempty"`
+	QuotaMonthlyIngest    int64         `json:"quota_monthly_ingest,omitempty"    yaml:"quota_monthly_ingest,omitempty"`
 }
 
 // Load reads configuration data from environment variables and applies defaults
@@ -67,6 +103,133 @@ func (c *ServiceConfig) Load() {
 	if c.ResourceDataRetention == 0 {
 		c.ResourceDataRetention = DefaultResourceDataRetention
 	}
+
+	if v := os.Getenv(ReplaceEnv(KeyResourceCascadeStatus)); v != "" {
+		v, err := strconv.ParseBool(v)
+		if err != nil {
+			v = DefaultResourceCascadeStatus
+		}
+
+		c.ResourceCascadeStatus = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyPurgeInterval)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultPurgeInterval
+		}
+
+		c.PurgeInterval = v
+	}
+
+	if c.PurgeInterval <= 0 {
+		c.PurgeInterval = DefaultPurgeInterval
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyClearInterval)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultClearInterval
+		}
+
+		c.ClearInterval = v
+	}
+
+	if c.ClearInterval <= 0 {
+		c.ClearInterval = DefaultClearInterval
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAlertInterval)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAlertInterval
+		}
+
+		c.AlertInterval = v
+	}
+
+	if c.AlertInterval <= 0 {
+		c.AlertInterval = DefaultAlertInterval
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAgentStaleAfter)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAgentStaleAfter
+		}
+
+		c.AgentStaleAfter = v
+	}
+
+	if c.AgentStaleAfter == 0 {
+		c.AgentStaleAfter = DefaultAgentStaleAfter
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAgentCheckInterval)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultAgentCheckInterval
+		}
+
+		c.AgentCheckInterval = v
+	}
+
+	if c.AgentCheckInterval == 0 {
+		c.AgentCheckInterval = DefaultAgentCheckInterval
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyAccountTemplateRepo)); v != "" {
+		c.AccountTemplateRepo = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyUsageInterval)); v != "" {
+		v, err := time.ParseDuration(v)
+		if err != nil {
+			v = DefaultUsageInterval
+		}
+
+		c.UsageInterval = v
+	}
+
+	if c.UsageInterval <= 0 {
+		c.UsageInterval = DefaultUsageInterval
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyQuotaDailyRequests)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			v = DefaultQuotaDailyRequests
+		}
+
+		c.QuotaDailyRequests = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyQuotaMonthlyRequests)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			v = DefaultQuotaMonthlyRequests
+		}
+
+		c.QuotaMonthlyRequests = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyQuotaDailyIngest)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			v = DefaultQuotaDailyIngest
+		}
+
+		c.QuotaDailyIngest = v
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyQuotaMonthlyIngest)); v != "" {
+		v, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			v = DefaultQuotaMonthlyIngest
+		}
+
+		c.QuotaMonthlyIngest = v
+	}
 }
 
 // ServiceName returns the name of the service.
@@ -119,3 +282,165 @@ func (c *Config) ResourceDataRetention() time.Duration {
 
 	return c.service.ResourceDataRetention
 }
+
+// ResourceCascadeStatus returns whether the dependents of a resource are marked
+// degraded when it reports an error, and restored when it recovers.
+func (c *Config) ResourceCascadeStatus() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil {
+		return DefaultResourceCascadeStatus
+	}
+
+	return c.service.ResourceCascadeStatus
+}
+
+// PurgeInterval returns the frequency at which data older than the retention
+// period of each account is purged.
+func (c *Config) PurgeInterval() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.PurgeInterval <= 0 {
+		return DefaultPurgeInterval
+	}
+
+	return c.service.PurgeInterval
+}
+
+// ClearInterval returns the frequency at which resource data items, which were
+// matched by the clear_condition of a resource with a clear_delay, are cleared
+// once their delay has elapsed.
+func (c *Config) ClearInterval() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.ClearInterval <= 0 {
+		return DefaultClearInterval
+	}
+
+	return c.service.ClearInterval
+}
+
+// AlertInterval returns the frequency at which the alert rules of each account
+// are evaluated against its resources.
+func (c *Config) AlertInterval() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.AlertInterval <= 0 {
+		return DefaultAlertInterval
+	}
+
+	return c.service.AlertInterval
+}
+
+// AgentStaleAfter returns the duration after the last heartbeat of an agent
+// at which it is considered disconnected.
+func (c *Config) AgentStaleAfter() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.AgentStaleAfter == 0 {
+		return DefaultAgentStaleAfter
+	}
+
+	return c.service.AgentStaleAfter
+}
+
+// AgentCheckInterval returns the frequency at which agents are checked for
+// missed heartbeats.
+func (c *Config) AgentCheckInterval() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.AgentCheckInterval == 0 {
+		return DefaultAgentCheckInterval
+	}
+
+	return c.service.AgentCheckInterval
+}
+
+// AccountTemplateRepo returns the URL of the repository from which the
+// resources and settings of new accounts are copied. If empty, new accounts
+// are created without any.
+func (c *Config) AccountTemplateRepo() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil {
+		return DefaultAccountTemplateRepo
+	}
+
+	return c.service.AccountTemplateRepo
+}
+
+// UsageInterval returns the frequency at which the API requests counted by the
+// service are recorded in the database.
+func (c *Config) UsageInterval() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.UsageInterval <= 0 {
+		return DefaultUsageInterval
+	}
+
+	return c.service.UsageInterval
+}
+
+// QuotaDailyRequests returns the number of requests each account may make on
+// a UTC day, unless the account sets its own quota. If it is zero, the number
+// of requests is not limited.
+func (c *Config) QuotaDailyRequests() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.QuotaDailyRequests < 0 {
+		return DefaultQuotaDailyRequests
+	}
+
+	return c.service.QuotaDailyRequests
+}
+
+// QuotaMonthlyRequests returns the number of requests each account may make in
+// a UTC month, unless the account sets its own quota. If it is zero, the
+// number of requests is not limited.
+func (c *Config) QuotaMonthlyRequests() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.QuotaMonthlyRequests < 0 {
+		return DefaultQuotaMonthlyRequests
+	}
+
+	return c.service.QuotaMonthlyRequests
+}
+
+// QuotaDailyIngest returns the number of request body bytes each account may
+// send on a UTC day, unless the account sets its own quota. If it is zero, the
+// bytes sent are not limited.
+func (c *Config) QuotaDailyIngest() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.QuotaDailyIngest < 0 {
+		return DefaultQuotaDailyIngest
+	}
+
+	return c.service.QuotaDailyIngest
+}
+
+// QuotaMonthlyIngest returns the number of request body bytes each account may
+// send in a UTC month, unless the account sets its own quota. If it is zero,
+// the bytes sent are not limited.
+func (c *Config) QuotaMonthlyIngest() int64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.service == nil || c.service.QuotaMonthlyIngest < 0 {
+		return DefaultQuotaMonthlyIngest
+	}
+
+	return c.service.QuotaMonthlyIngest
+}
@@ -15,9 +15,18 @@ func TestServiceConfig(t *testing.T) {
 	cfg.Load(nil)
 
 	cfg.SetService(&config.ServiceConfig{
-		Name:           "test name",
-		Maintenance:    true,
-		ImportInterval: time.Second,
+		Name:                  "test name",
+		Maintenance:           true,
+		ImportInterval:        time.Second,
+		PurgeInterval:         time.Minute * 10,
+		ClearInterval:         time.Second * 5,
+		AlertInterval:         time.Second * 15,
+		AgentStaleAfter:       time.Minute,
+		AccountTemplateRepo:   "starter://",
+		UsageInterval:         time.Second * 30,
+		QuotaDailyRequests:    1000,
+		QuotaMonthlyIngest:    1 << 20,
+		ResourceCascadeStatus: true,
 	})
 
 	if cfg.ServiceName() != "test name" {
@@ -29,7 +38,62 @@ func TestServiceConfig(t *testing.T) {
 			cfg.ServiceMaintenance())
 	}
 
+	if !cfg.ResourceCascadeStatus() {
+		t.Error("Expected resource cascade status: true")
+	}
+
 	if cfg.ImportInterval() != time.Second {
 		t.Errorf("Expected import interval: 1s, got: %v", cfg.ImportInterval())
 	}
+
+	if cfg.PurgeInterval() != time.Minute*10 {
+		t.Errorf("Expected purge interval: 10m, got: %v", cfg.PurgeInterval())
+	}
+
+	if cfg.ClearInterval() != time.Second*5 {
+		t.Errorf("Expected clear interval: 5s, got: %v", cfg.ClearInterval())
+	}
+
+	if cfg.AlertInterval() != time.Second*15 {
+		t.Errorf("Expected alert interval: 15s, got: %v", cfg.AlertInterval())
+	}
+
+	if cfg.AgentStaleAfter() != time.Minute {
+		t.Errorf("Expected agent stale after: 1m, got: %v",
+			cfg.AgentStaleAfter())
+	}
+
+	if cfg.AgentCheckInterval() != config.DefaultAgentCheckInterval {
+		t.Errorf("Expected agent check interval: %v, got: %v",
+			config.DefaultAgentCheckInterval, cfg.AgentCheckInterval())
+	}
+
+	if cfg.AccountTemplateRepo() != "starter://" {
+		t.Errorf("Expected account template repo: starter://, got: %v",
+			cfg.AccountTemplateRepo())
+	}
+
+	if cfg.UsageInterval() != time.Second*30 {
+		t.Errorf("Expected usage interval: 30s, got: %v", cfg.UsageInterval())
+	}
+
+	if cfg.QuotaDailyRequests() != 1000 {
+		t.Errorf("Expected daily requests quota: 1000, got: %v",
+			cfg.QuotaDailyRequests())
+	}
+
+	if cfg.QuotaMonthlyRequests() != 0 {
+		t.Errorf("Expected monthly requests quota: 0, got: %v",
+			cfg.QuotaMonthlyRequests())
+	}
+
+	if cfg.QuotaDailyIngest() != 0 {
+		t.Errorf("Expected daily ingest quota: 0, got: %v",
+			cfg.QuotaDailyIngest())
+	}
+
+	if cfg.QuotaMonthlyIngest() != 1<<20 {
+		t.Errorf("Expected monthly ingest quota: %v, got: %v",
+			1<<20, cfg.QuotaMonthlyIngest())
+	}
 }
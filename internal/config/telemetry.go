@@ -2,6 +2,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -10,11 +12,15 @@ const (
 	KeyMetricInterval = "metric/interval"
 	KeyMetricVersion  = "metric/version"
 	KeyTraceAddress   = "trace/address"
+	KeyTraceHeaders   = "trace/headers"
+	KeyTraceSample    = "trace/sample"
 
 	DefaultMetricAddress  = ""
 	DefaultMetricInterval = time.Second * 60
 	DefaultMetricVersion  = "v0.1.0"
 	DefaultTraceAddress   = ""
+	DefaultTraceHeaders   = ""
+	DefaultTraceSample    = 1.0
 )
 
 // TelemetryConfig values represent telemetry configuration data.
@@ -23,6 +29,8 @@ type TelemetryConfig struct {
 	MetricInterval time.Duration `json:"metric_interval,omitempty" yaml:"metric_interval,omitempty"`
 	MetricVersion  string        `json:"metric_version,omitempty"  yaml:"metric_version,omitempty"`
 	TraceAddress   string        `json:"trace_address,omitempty"   yaml:"trace_address,omitempty"`
+	TraceHeaders   string        `json:"trace_headers,omitempty"   yaml:"trace_headers,omitempty"`
+	TraceSample    float64       `json:"trace_sample,omitempty"    yaml:"trace_sample,omitempty"`
 }
 
 // Load reads configuration data from environment variables and applies defaults
@@ -64,6 +72,27 @@ func (c *TelemetryConfig) Load() {
 	if c.TraceAddress == "" {
 		c.TraceAddress = DefaultTraceAddress
 	}
+
+	if v := os.Getenv(ReplaceEnv(KeyTraceHeaders)); v != "" {
+		c.TraceHeaders = v
+	}
+
+	if c.TraceHeaders == "" {
+		c.TraceHeaders = DefaultTraceHeaders
+	}
+
+	if v := os.Getenv(ReplaceEnv(KeyTraceSample)); v != "" {
+		v, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			v = DefaultTraceSample
+		}
+
+		c.TraceSample = v
+	}
+
+	if c.TraceSample <= 0 || c.TraceSample > 1 {
+		c.TraceSample = DefaultTraceSample
+	}
 }
 
 // MetricAddress returns the address of the collector where metrics data is
@@ -116,3 +145,44 @@ func (c *Config) TraceAddress() string {
 
 	return c.telemetry.TraceAddress
 }
+
+// TraceHeaders returns the headers sent with traces data to the collector,
+// such as authentication headers. These are configured as a comma separated
+// list of key=value pairs.
+func (c *Config) TraceHeaders() map[string]string {
+	c.RLock()
+	defer c.RUnlock()
+
+	v := DefaultTraceHeaders
+
+	if c.telemetry != nil {
+		v = c.telemetry.TraceHeaders
+	}
+
+	res := map[string]string{}
+
+	for _, h := range strings.Split(v, ",") {
+		k, v, ok := strings.Cut(h, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+
+		res[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	return res
+}
+
+// TraceSample returns the ratio, between 0 and 1, of new traces which are
+// sampled. Traces continued from a caller follow the sampling decision of the
+// caller.
+func (c *Config) TraceSample() float64 {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.telemetry == nil {
+		return DefaultTraceSample
+	}
+
+	return c.telemetry.TraceSample
+}
@@ -1,6 +1,7 @@
 package config_test
 
 import (
+	"reflect"
 	"testing"
 	"time"
 
@@ -19,6 +20,8 @@ func TestTelemetryConfig(t *testing.T) {
 		MetricInterval: time.Second,
 		MetricVersion:  exp,
 		TraceAddress:   exp,
+		TraceHeaders:   "authorization=test, x-test = test,invalid",
+		TraceSample:    0.5,
 	})
 
 	if cfg.MetricAddress() != exp {
@@ -40,4 +43,15 @@ func TestTelemetryConfig(t *testing.T) {
 		t.Errorf("Expected trace address: %v, got: %v",
 			exp, cfg.TraceAddress())
 	}
+
+	expH := map[string]string{"authorization": exp, "x-test": exp}
+
+	if !reflect.DeepEqual(cfg.TraceHeaders(), expH) {
+		t.Errorf("Expected trace headers: %v, got: %v",
+			expH, cfg.TraceHeaders())
+	}
+
+	if cfg.TraceSample() != 0.5 {
+		t.Errorf("Expected trace sample: 0.5, got: %v", cfg.TraceSample())
+	}
 }
@@ -16,14 +16,27 @@ import (
 // Error values contain information about error conditions.
 type Error struct {
 	Code
-	Msg    string         `json:"message,omitempty"`
-	Proc   string         `json:"procedure,omitempty"`
-	Svr    string         `json:"server,omitempty"`
-	Time   int64          `json:"time,omitempty"`
-	Data   map[string]any `json:"data,omitempty"`
-	Err    *Error         `json:"error,omitempty"`
-	Errors []*Error       `json:"errors,omitempty"`
-	err    error          `json:"-"`
+	Msg       string         `json:"message,omitempty"`
+	Proc      string         `json:"procedure,omitempty"`
+	Svr       string         `json:"server,omitempty"`
+	Time      int64          `json:"time,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+	Err       *Error         `json:"error,omitempty"`
+	Errors    []*Error       `json:"errors,omitempty"`
+	Groups    []*ErrorGroup  `json:"groups,omitempty"`
+	Fields    []*FieldError  `json:"fields,omitempty"`
+	Retryable bool           `json:"retryable,omitempty"`
+	err       error          `json:"-"`
+}
+
+// ErrorGroup values summarize the errors, contained by an error, which have
+// the same code and message, such as those repeated for every item of a failed
+// import.
+type ErrorGroup struct {
+	Code
+	Msg     string   `json:"message,omitempty"`
+	Count   int      `json:"count"`
+	Samples []*Error `json:"samples,omitempty"`
 }
 
 // Code values represent specific error codes and status values.
@@ -134,8 +147,12 @@ func New(code Code, message string, args ...any) *Error {
 // Validation returns a new invalid value error for the specified object
 // and field for the provided invalid value.
 func Validation(obj, field string, value any) error {
-	return New(ErrInvalidRequest,
-		fmt.Sprintf("invalid %s %s: %v", obj, field, value))
+	f := FieldErrors{}
+
+	f.Add(field, FieldInvalid, fmt.Sprintf("invalid %s %s: %v",
+		obj, field, value))
+
+	return f.Err()
 }
 
 // Context creates a new error value wrapping a context error.
@@ -162,6 +179,8 @@ func Wrap(err error, code Code, message string, args ...any) *Error {
 		e.Err = ev
 		e.Time = ev.Time
 		e.Code = ev.Code
+		e.Retryable = ev.Retryable
+		e.Fields = ev.Fields
 
 		if message == "" {
 			e.Msg = ev.Msg
@@ -199,6 +218,42 @@ func Wrap(err error, code Code, message string, args ...any) *Error {
 	return e
 }
 
+// Transient marks the error as retryable, when transient is true, indicating
+// that the operation which failed may succeed if it is attempted again, such as
+// after a lost database connection or a deadlock. It returns the error, so that
+// it can be chained with New or Wrap.
+func (e *Error) Transient(transient bool) *Error {
+	if transient {
+		e.Retryable = true
+	}
+
+	return e
+}
+
+// IsRetryable determines whether the operation which failed with an error may
+// succeed if it is attempted again. Errors marked as transient are retryable,
+// as are rate limited, unavailable and timed out operations. Other errors,
+// including invalid requests and conflicts, are permanent, and repeating the
+// same request will fail in the same way.
+func IsRetryable(err error) bool {
+	var e *Error
+
+	if !errors.As(err, &e) || e == nil {
+		return false
+	}
+
+	if e.Retryable {
+		return true
+	}
+
+	switch e.Code {
+	case ErrorRateLimit, ErrUnavailable, ErrMaintenance, ErrContextTimeout:
+		return true
+	}
+
+	return false
+}
+
 // As implemented for compatibility with go standard library errors package.
 func As(err error, target any) bool {
 	return errors.As(err, target)
@@ -256,13 +311,14 @@ func (e *Error) Wrap(err error) *Error {
 // Copy returns an exact copy of the value.
 func (e *Error) Copy() *Error {
 	err := &Error{
-		Code: e.Code,
-		Msg:  e.Msg,
-		Proc: e.Proc,
-		Svr:  e.Svr,
-		Time: e.Time,
-		Err:  e.Err,
-		err:  e.err,
+		Code:      e.Code,
+		Msg:       e.Msg,
+		Proc:      e.Proc,
+		Svr:       e.Svr,
+		Time:      e.Time,
+		Err:       e.Err,
+		err:       e.err,
+		Retryable: e.Retryable,
 	}
 
 	if len(e.Errors) > 0 {
@@ -270,6 +326,16 @@ func (e *Error) Copy() *Error {
 		copy(err.Errors, e.Errors)
 	}
 
+	if len(e.Groups) > 0 {
+		err.Groups = make([]*ErrorGroup, len(e.Groups))
+		copy(err.Groups, e.Groups)
+	}
+
+	if len(e.Fields) > 0 {
+		err.Fields = make([]*FieldError, len(e.Fields))
+		copy(err.Fields, e.Fields)
+	}
+
 	if len(e.Data) > 0 {
 		err.Data = make(map[string]any, len(e.Data))
 
@@ -298,6 +364,8 @@ func (e *Error) Equal(b *Error) bool {
 		return false
 	case e.Time != b.Time:
 		return false
+	case e.Retryable != b.Retryable:
+		return false
 	case e.Err == nil && b.Err != nil ||
 		e.Err != nil && b.Err == nil:
 		return false
@@ -306,6 +374,10 @@ func (e *Error) Equal(b *Error) bool {
 		return false
 	case len(e.Errors) != len(b.Errors):
 		return false
+	case len(e.Groups) != len(b.Groups):
+		return false
+	case len(e.Fields) != len(b.Fields):
+		return false
 	case len(e.Data) != len(b.Data):
 		return false
 	}
@@ -316,6 +388,20 @@ func (e *Error) Equal(b *Error) bool {
 		}
 	}
 
+	for i, g := range e.Groups {
+		bg := b.Groups[i]
+
+		if g.Code != bg.Code || g.Msg != bg.Msg || g.Count != bg.Count {
+			return false
+		}
+	}
+
+	for i, f := range e.Fields {
+		if *f != *b.Fields[i] {
+			return false
+		}
+	}
+
 	for k, v := range e.Data {
 		if b.Data[k] != v {
 			return false
@@ -325,6 +411,47 @@ func (e *Error) Equal(b *Error) bool {
 	return true
 }
 
+// Group returns a copy of the error in which the errors it contains are
+// grouped by code and message. Each group reports the number of errors in it,
+// and keeps, at most, the specified number of them as representative samples,
+// in the order they occurred. The contained errors are replaced by the groups.
+func (e *Error) Group(samples int) *Error {
+	err := e.Copy()
+
+	if len(e.Errors) == 0 {
+		return err
+	}
+
+	groups := map[string]*ErrorGroup{}
+
+	for _, ev := range e.Errors {
+		if ev == nil {
+			continue
+		}
+
+		key := ev.Name + ":" + ev.Msg
+
+		g, ok := groups[key]
+		if !ok {
+			g = &ErrorGroup{Code: ev.Code, Msg: ev.Msg}
+
+			groups[key] = g
+
+			err.Groups = append(err.Groups, g)
+		}
+
+		g.Count++
+
+		if len(g.Samples) < samples {
+			g.Samples = append(g.Samples, ev)
+		}
+	}
+
+	err.Errors = nil
+
+	return err
+}
+
 // ErrorHas returns true if the provided error as a string contains s.
 func ErrorHas(err error, s string) bool {
 	if err == nil {
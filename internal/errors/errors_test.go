@@ -163,3 +163,152 @@ func TestEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestGroup(t *testing.T) {
+	t.Parallel()
+
+	e := errors.New(errors.ErrImport, "unable to import resources")
+
+	for i := 0; i < 100; i++ {
+		e.Errors = append(e.Errors, errors.New(errors.ErrImport,
+			"unable to parse resource repository file",
+			"resource_id", i))
+	}
+
+	e.Errors = append(e.Errors, errors.New(errors.ErrDatabase,
+		"unable to create imported resource"))
+
+	g := e.Group(3)
+
+	if len(g.Errors) != 0 {
+		t.Errorf("Expected errors: 0, got: %v", len(g.Errors))
+	}
+
+	if len(e.Errors) != 101 {
+		t.Errorf("Expected original errors: 101, got: %v", len(e.Errors))
+	}
+
+	if len(g.Groups) != 2 {
+		t.Fatalf("Expected groups: 2, got: %v", len(g.Groups))
+	}
+
+	if g.Groups[0].Count != 100 || len(g.Groups[0].Samples) != 3 {
+		t.Errorf("Expected count: 100, samples: 3, got: %v, %v",
+			g.Groups[0].Count, len(g.Groups[0].Samples))
+	}
+
+	if g.Groups[0].Samples[0].Data["resource_id"] != 0 {
+		t.Errorf("Expected first sample, got: %v", g.Groups[0].Samples[0])
+	}
+
+	if g.Groups[1].Code != errors.ErrDatabase || g.Groups[1].Count != 1 {
+		t.Errorf("Expected database group, got: %+v", g.Groups[1])
+	}
+
+	exp := `"groups":[{"code":"Import","status":500,` +
+		`"message":"unable to parse resource repository file","count":100`
+
+	if !strings.Contains(g.String(), exp) {
+		t.Errorf("Expected string to contain: %v, got: %v", exp, g.String())
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		exp  bool
+	}{{
+		name: "nil",
+		err:  nil,
+		exp:  false,
+	}, {
+		name: "other error",
+		err:  context.Canceled,
+		exp:  false,
+	}, {
+		name: "rate limited",
+		err:  errors.New(errors.ErrorRateLimit, "rate limit exceeded"),
+		exp:  true,
+	}, {
+		name: "unavailable",
+		err:  errors.New(errors.ErrUnavailable, "service unavailable"),
+		exp:  true,
+	}, {
+		name: "conflict",
+		err:  errors.New(errors.ErrConflict, "resource already exists"),
+		exp:  false,
+	}, {
+		name: "permanent database error",
+		err:  errors.New(errors.ErrDatabase, "unable to execute statement"),
+		exp:  false,
+	}, {
+		name: "transient database error",
+		err: errors.New(errors.ErrDatabase,
+			"unable to execute statement").Transient(true),
+		exp: true,
+	}, {
+		name: "wrapped transient error",
+		err: errors.Wrap(errors.New(errors.ErrDatabase,
+			"unable to begin transaction").Transient(true),
+			errors.ErrServer, "unable to get resource"),
+		exp: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if res := errors.IsRetryable(tt.err); res != tt.exp {
+				t.Errorf("Expected retryable: %v, got: %v", tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	t.Parallel()
+
+	f := errors.FieldErrors{}
+
+	if err := f.Err(); err != nil {
+		t.Errorf("Expected no error, got: %v", err)
+	}
+
+	f.Add("name", errors.FieldRequired, "missing resource name")
+	f.AddErr("key_field", errors.FieldInvalid,
+		errors.Wrap(errors.New(errors.ErrInvalidRequest, "invalid template"),
+			errors.ErrInvalidRequest, "invalid key field"))
+
+	var e *errors.Error
+
+	if !errors.As(f.Err("resource", "test"), &e) {
+		t.Fatalf("Expected error, got: %v", f.Err())
+	}
+
+	if e.Code != errors.ErrInvalidRequest || e.Msg != "missing resource name" {
+		t.Errorf("Expected invalid request: missing resource name, got: %v",
+			e)
+	}
+
+	if len(e.Fields) != 2 || e.Fields[1].Message != "invalid key field" {
+		t.Fatalf("Expected fields: 2, got: %v", e.Fields)
+	}
+
+	exp := `"fields":[{"field":"name","code":"required",` +
+		`"message":"missing resource name"}`
+
+	if !strings.Contains(e.String(), exp) {
+		t.Errorf("Expected string to contain: %v, got: %v", exp, e.String())
+	}
+
+	if w := errors.Wrap(e, errors.ErrInvalidRequest, ""); len(w.Fields) != 2 {
+		t.Errorf("Expected wrapped fields: 2, got: %v", w.Fields)
+	}
+
+	if !e.Equal(e.Copy()) {
+		t.Errorf("Expected copy to be equal: %v", e)
+	}
+}
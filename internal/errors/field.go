@@ -0,0 +1,57 @@
+package errors
+
+// Field error codes, identifying why the value of a field is invalid.
+const (
+	FieldRequired = "required"
+	FieldNull     = "null"
+	FieldInvalid  = "invalid"
+	FieldType     = "type"
+)
+
+// FieldError values identify a field of a request which has an invalid value,
+// so that clients can indicate which values need to be corrected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// FieldErrors values collect the invalid fields of a request, so that they can
+// all be reported in a single error.
+type FieldErrors []*FieldError
+
+// Add adds an invalid field.
+func (f *FieldErrors) Add(field, code, message string) {
+	*f = append(*f, &FieldError{Field: field, Code: code, Message: message})
+}
+
+// AddErr adds an invalid field from an error. The message of the error is
+// used for the field, without the messages of any errors it wraps.
+func (f *FieldErrors) AddErr(field, code string, err error) {
+	if err == nil {
+		return
+	}
+
+	msg := err.Error()
+
+	if e, ok := err.(*Error); ok && e.Msg != "" {
+		msg = e.Msg
+	}
+
+	f.Add(field, code, msg)
+}
+
+// Err returns an invalid request error containing the invalid fields, or nil
+// if there are none. The message of the error is the message of the first
+// invalid field.
+func (f FieldErrors) Err(args ...any) error {
+	if len(f) == 0 {
+		return nil
+	}
+
+	e := New(ErrInvalidRequest, f[0].Message, args...)
+
+	e.Fields = f
+
+	return e
+}
@@ -0,0 +1,199 @@
+// Package fixture provides recording of sanitized API request and response
+// pairs into fixture files, and replaying of recorded fixtures, so that API
+// consumers can run contract tests against actual API behavior.
+package fixture
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Redacted replaces any sensitive values in recorded fixtures.
+const Redacted = "REDACTED"
+
+// sensitiveHeaders are the headers with values which are redacted.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	"Proxy-Authorization",
+	"Set-Cookie",
+	"X-Api-Key",
+}
+
+// volatileHeaders are the headers which differ between otherwise identical
+// responses, and are not recorded.
+var volatileHeaders = []string{
+	"Date",
+	"X-Server",
+}
+
+// sensitiveKeys are the substrings identifying sensitive JSON object keys, or
+// form values, which are redacted.
+var sensitiveKeys = []string{
+	"password",
+	"secret",
+	"token",
+}
+
+// Fixture values contain a single recorded request and response pair.
+type Fixture struct {
+	Key            string      `json:"key"`
+	Version        string      `json:"version,omitempty"`
+	Method         string      `json:"method"`
+	Path           string      `json:"path"`
+	Query          string      `json:"query,omitempty"`
+	RequestHeader  http.Header `json:"request_header,omitempty"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body,omitempty"`
+}
+
+// sensitive determines whether a JSON object key, or form value name,
+// identifies a sensitive value.
+func sensitive(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SanitizeHeader returns a copy of a header with sensitive values redacted and
+// volatile values removed.
+func SanitizeHeader(h http.Header) http.Header {
+	res := h.Clone()
+
+	for _, k := range volatileHeaders {
+		res.Del(k)
+	}
+
+	for _, k := range sensitiveHeaders {
+		if res.Get(k) != "" {
+			res.Set(k, Redacted)
+		}
+	}
+
+	return res
+}
+
+// sanitizeValue redacts sensitive values contained in a decoded JSON value.
+func sanitizeValue(v any) any {
+	switch vt := v.(type) {
+	case map[string]any:
+		for k, mv := range vt {
+			if sensitive(k) {
+				vt[k] = Redacted
+
+				continue
+			}
+
+			vt[k] = sanitizeValue(mv)
+		}
+	case []any:
+		for i, av := range vt {
+			vt[i] = sanitizeValue(av)
+		}
+	case string:
+		if u, err := url.Parse(vt); err == nil && u.User != nil &&
+			u.Host != "" {
+			u.User = url.User(Redacted)
+
+			return u.String()
+		}
+	}
+
+	return v
+}
+
+// SanitizeBody returns a copy of a request or response body with sensitive
+// values redacted. JSON and form encoded bodies are supported, other bodies
+// are returned unchanged.
+func SanitizeBody(contentType string, body []byte) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		vals, err := url.ParseQuery(string(body))
+		if err != nil {
+			return body
+		}
+
+		for k := range vals {
+			if sensitive(k) {
+				vals.Set(k, Redacted)
+			}
+		}
+
+		return []byte(vals.Encode())
+	}
+
+	var v any
+
+	d := json.NewDecoder(bytes.NewReader(body))
+
+	d.UseNumber()
+
+	if err := d.Decode(&v); err != nil {
+		return body
+	}
+
+	buf := &bytes.Buffer{}
+
+	e := json.NewEncoder(buf)
+
+	e.SetEscapeHTML(false)
+
+	if err := e.Encode(sanitizeValue(v)); err != nil {
+		return body
+	}
+
+	return buf.Bytes()
+}
+
+// Key returns the key identifying the recorded responses to a request, derived
+// from the method, path, query and sanitized body of the request.
+func Key(method, p string, query url.Values, body []byte) string {
+	h := sha256.New()
+
+	h.Write([]byte(method + "\n" + path.Clean("/"+p) + "\n" +
+		query.Encode() + "\n"))
+	h.Write(bytes.TrimSpace(body))
+
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// fileName returns the name of the file containing the nth recorded response
+// for a fixture key.
+func fileName(f *Fixture, n int) string {
+	slug := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+
+		return '_'
+	}, f.Path), "_")
+
+	if len(slug) > 64 {
+		slug = slug[:64]
+	}
+
+	return strings.Join([]string{
+		strings.ToLower(f.Method), slug, f.Key, fmt.Sprintf("%04d", n),
+	}, "_") + ".json"
+}
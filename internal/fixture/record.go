@@ -0,0 +1,168 @@
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+// Recorder values record sanitized request and response pairs into fixture
+// files in a directory. Repeated identical requests are recorded in sequence,
+// so that they are replayed in the order they were received.
+type Recorder struct {
+	sync.Mutex
+	dir string
+	log logger.Logger
+	seq map[string]int
+}
+
+// NewRecorder creates a new recorder writing fixture files to a directory.
+func NewRecorder(dir string, log logger.Logger) *Recorder {
+	if log == nil || (reflect.ValueOf(log).Kind() == reflect.Ptr &&
+		reflect.ValueOf(log).IsNil()) {
+		log = logger.NullLog
+	}
+
+	return &Recorder{
+		dir: dir,
+		log: log,
+		seq: map[string]int{},
+	}
+}
+
+// recordWriter values capture the status and body of a response as it is
+// written.
+type recordWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader captures the response status code.
+func (w *recordWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write captures the response body.
+func (w *recordWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.body.Write(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Handler wraps request handlers, recording each request and response.
+func (rec *Recorder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+
+		if r.Body != nil {
+			b, err := io.ReadAll(r.Body)
+			if err != nil {
+				rec.log.Log(r.Context(), logger.LvlError,
+					"unable to read request body for recording",
+					"error", err)
+			}
+
+			body = b
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		rw := &recordWriter{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r)
+
+		if rw.status == 0 {
+			rw.status = http.StatusOK
+		}
+
+		reqBody := SanitizeBody(r.Header.Get("Content-Type"), body)
+
+		f := &Fixture{
+			Key:            Key(r.Method, r.URL.Path, r.URL.Query(), reqBody),
+			Version:        w.Header().Get("X-Version"),
+			Method:         r.Method,
+			Path:           r.URL.Path,
+			Query:          r.URL.Query().Encode(),
+			RequestHeader:  SanitizeHeader(r.Header),
+			RequestBody:    string(reqBody),
+			Status:         rw.status,
+			ResponseHeader: SanitizeHeader(w.Header()),
+			ResponseBody: string(SanitizeBody(w.Header().Get("Content-Type"),
+				rw.body.Bytes())),
+		}
+
+		// Headers added by the server for internal use are not recorded.
+		f.RequestHeader.Del("X-Status-Code")
+
+		if err := rec.Record(r.Context(), f); err != nil {
+			rec.log.Log(r.Context(), logger.LvlError,
+				"unable to record fixture",
+				"error", err,
+				"method", f.Method,
+				"path", f.Path)
+		}
+	})
+}
+
+// Record writes a fixture file for a recorded request and response pair.
+func (rec *Recorder) Record(ctx context.Context, f *Fixture) error {
+	if f == nil {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing fixture")
+	}
+
+	rec.Lock()
+
+	n := rec.seq[f.Key]
+
+	rec.seq[f.Key] = n + 1
+
+	rec.Unlock()
+
+	if err := os.MkdirAll(rec.dir, 0o755); err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to create fixture directory",
+			"dir", rec.dir)
+	}
+
+	buf := &bytes.Buffer{}
+
+	e := json.NewEncoder(buf)
+
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+
+	if err := e.Encode(f); err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to encode fixture",
+			"fixture", f)
+	}
+
+	name := filepath.Join(rec.dir, fileName(f, n))
+
+	if err := os.WriteFile(name, buf.Bytes(), 0o644); err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to write fixture file",
+			"file", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package fixture
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// Replayer values serve recorded fixtures in response to matching requests.
+// Repeated identical requests receive the recorded responses in sequence, and
+// once the sequence is exhausted, the last recorded response is repeated.
+type Replayer struct {
+	sync.Mutex
+	fixtures map[string][]*Fixture
+	pos      map[string]int
+}
+
+// NewReplayer creates a new replayer serving the fixture files contained in a
+// directory.
+func NewReplayer(dir string) (*Replayer, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrConfiguration,
+			"unable to read fixture directory",
+			"dir", dir)
+	}
+
+	rp := &Replayer{
+		fixtures: map[string][]*Fixture{},
+		pos:      map[string]int{},
+	}
+
+	// Directory entries are sorted by file name, which orders the recorded
+	// responses for each key by sequence number.
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+
+		name := filepath.Join(dir, e.Name())
+
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrConfiguration,
+				"unable to read fixture file",
+				"file", name)
+		}
+
+		f := &Fixture{}
+
+		if err := json.Unmarshal(b, f); err != nil {
+			return nil, errors.Wrap(err, errors.ErrConfiguration,
+				"unable to decode fixture file",
+				"file", name)
+		}
+
+		rp.fixtures[f.Key] = append(rp.fixtures[f.Key], f)
+	}
+
+	return rp, nil
+}
+
+// Len returns the number of fixtures loaded by the replayer.
+func (rp *Replayer) Len() int {
+	rp.Lock()
+	defer rp.Unlock()
+
+	n := 0
+
+	for _, fs := range rp.fixtures {
+		n += len(fs)
+	}
+
+	return n
+}
+
+// next returns the next recorded response for a fixture key.
+func (rp *Replayer) next(key string) *Fixture {
+	rp.Lock()
+	defer rp.Unlock()
+
+	fs := rp.fixtures[key]
+	if len(fs) == 0 {
+		return nil
+	}
+
+	i := rp.pos[key]
+	if i >= len(fs) {
+		return fs[len(fs)-1]
+	}
+
+	rp.pos[key] = i + 1
+
+	return fs[i]
+}
+
+// ServeHTTP responds to requests using the matching recorded fixture.
+func (rp *Replayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body []byte
+
+	if r.Body != nil {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to read request body"))
+
+			return
+		}
+
+		body = b
+	}
+
+	key := Key(r.Method, r.URL.Path, r.URL.Query(),
+		SanitizeBody(r.Header.Get("Content-Type"), body))
+
+	f := rp.next(key)
+	if f == nil {
+		writeError(w, errors.New(errors.ErrNotFound,
+			"no recorded fixture for request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"query", r.URL.RawQuery))
+
+		return
+	}
+
+	for k, v := range f.ResponseHeader {
+		w.Header()[k] = v
+	}
+
+	w.Header().Set("X-Fixture-Key", f.Key)
+
+	w.WriteHeader(f.Status)
+
+	_, _ = io.WriteString(w, f.ResponseBody)
+}
+
+// writeError writes an error response.
+func writeError(w http.ResponseWriter, e *errors.Error) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	w.WriteHeader(e.Code.Status)
+
+	_ = json.NewEncoder(w).Encode(e)
+}
@@ -0,0 +1,679 @@
+// Package httpclient provides a pooled, retrying, HTTP client used for all
+// outgoing HTTP requests made by the service.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/metric"
+	"github.com/dhaifley/apigo/internal/request"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The maximum wait between request retries.
+const maxRetryWait = time.Second * 30
+
+var (
+	transportLock sync.Mutex
+	transports    = map[string]*http.Transport{}
+)
+
+// transportKey returns a key identifying a shared transport by the pool, proxy
+// and certificate authority settings of the configuration, and whether its
+// connections are restricted to public addresses.
+func transportKey(cfg *config.Config, public bool) string {
+	proxies := []string{}
+
+	for k, v := range cfg.ClientProxies() {
+		proxies = append(proxies, k+"="+v)
+	}
+
+	slices.Sort(proxies)
+
+	return fmt.Sprint(cfg.ClientMaxIdleConns(), cfg.ClientIdleConnTimeout(),
+		cfg.ClientProxy(), proxies, cfg.ClientNoProxy(), cfg.ClientCAFiles(),
+		cfg.ClientDNSTTL(), cfg.ClientDialTimeout(), cfg.ClientDialNetwork(),
+		cfg.ClientFallbackDelay(), public, cfg.ClientAllowedNetworks())
+}
+
+// sharedTransport returns the pooled transport for the configuration, so that
+// connections are reused across all clients with the same settings. If the
+// configured certificate authority bundles cannot be loaded, or the allowed
+// networks of a public transport are invalid, an error is returned.
+func sharedTransport(cfg *config.Config,
+	public bool,
+) (*http.Transport, error) {
+	k := transportKey(cfg, public)
+
+	transportLock.Lock()
+	defer transportLock.Unlock()
+
+	if t, ok := transports[k]; ok {
+		return t, nil
+	}
+
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if ok {
+		t = t.Clone()
+	} else {
+		t = &http.Transport{}
+	}
+
+	t.MaxIdleConns = cfg.ClientMaxIdleConns()
+	t.MaxIdleConnsPerHost = cfg.ClientMaxIdleConns()
+	t.IdleConnTimeout = cfg.ClientIdleConnTimeout()
+
+	d := &dialer{
+		dialer: &net.Dialer{
+			Timeout:       cfg.ClientDialTimeout(),
+			KeepAlive:     time.Second * 30,
+			FallbackDelay: cfg.ClientFallbackDelay(),
+		},
+		resolver: NewResolver(cfg.ClientDNSTTL(), nil),
+		network:  cfg.ClientDialNetwork(),
+	}
+
+	if public {
+		allowed, err := parseNetworks(cfg.ClientAllowedNetworks())
+		if err != nil {
+			return nil, err
+		}
+
+		d.dialer.Control = publicControl(allowed)
+	}
+
+	t.DialContext = d.DialContext
+
+	t.Proxy = func(r *http.Request) (*url.URL, error) {
+		return proxy(cfg, r)
+	}
+
+	if files := cfg.ClientCAFiles(); len(files) > 0 {
+		pool, err := certPool(files)
+		if err != nil {
+			return nil, err
+		}
+
+		t.TLSClientConfig = &tls.Config{
+			RootCAs:    pool,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	transports[k] = t
+
+	return t, nil
+}
+
+// certPool returns the system certificate pool with the certificates from the
+// specified PEM encoded bundle files added.
+func certPool(files []string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	buf, err := caBundle(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if !pool.AppendCertsFromPEM(buf) {
+		return nil, errors.New(errors.ErrConfiguration,
+			"no certificates found in certificate authority bundles",
+			"files", files)
+	}
+
+	return pool, nil
+}
+
+// caBundle reads and concatenates the specified PEM encoded certificate
+// authority bundle files.
+func caBundle(files []string) ([]byte, error) {
+	res := []byte{}
+
+	for _, f := range files {
+		buf, err := os.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrConfiguration,
+				"unable to read certificate authority bundle",
+				"file", f)
+		}
+
+		res = append(res, buf...)
+		res = append(res, '\n')
+	}
+
+	return res, nil
+}
+
+// proxy returns the URL of the proxy to use for an outgoing request. The
+// per-destination proxies are checked first, followed by the no proxy hosts,
+// the default proxy and, finally, the standard proxy environment variables.
+func proxy(cfg *config.Config, r *http.Request) (*url.URL, error) {
+	host := strings.ToLower(r.URL.Host)
+
+	name := strings.ToLower(r.URL.Hostname())
+
+	match := func(pattern string) bool {
+		pattern = strings.ToLower(pattern)
+
+		switch {
+		case pattern == "*":
+			return true
+		case strings.HasPrefix(pattern, "."):
+			return strings.HasSuffix(name, pattern) || name == pattern[1:]
+		default:
+			return pattern == name || pattern == host
+		}
+	}
+
+	// The most specific matching destination is used.
+	best, found := "", false
+
+	proxies := cfg.ClientProxies()
+
+	for pattern := range proxies {
+		if match(pattern) && (!found || len(pattern) > len(best)) {
+			best, found = pattern, true
+		}
+	}
+
+	if found {
+		if p := proxies[best]; p != "" && p != "direct" {
+			return parseProxy(p)
+		}
+
+		return nil, nil
+	}
+
+	for _, pattern := range cfg.ClientNoProxy() {
+		if match(pattern) {
+			return nil, nil
+		}
+	}
+
+	if p := cfg.ClientProxy(); p != "" {
+		return parseProxy(p)
+	}
+
+	return http.ProxyFromEnvironment(r)
+}
+
+// parseProxy parses a configured proxy URL.
+func parseProxy(p string) (*url.URL, error) {
+	u, err := url.Parse(p)
+	if err != nil || u.Host == "" {
+		return nil, errors.New(errors.ErrConfiguration,
+			"invalid proxy URL",
+			"error", err)
+	}
+
+	return u, nil
+}
+
+// Client values are used to perform outgoing HTTP requests. Requests are
+// retried on network errors and retriable response status codes, and requests
+// to hosts which are consistently failing are rejected by a per host circuit
+// breaker. If the client configuration is invalid, all requests fail.
+type Client struct {
+	sync.Mutex
+	cfg      *config.Config
+	client   *http.Client
+	base     http.RoundTripper
+	err      error
+	breakers map[string]*breaker
+	log      logger.Logger
+	metric   metric.Recorder
+	tracer   trace.Tracer
+}
+
+// NewClient initializes a new outgoing HTTP client.
+func NewClient(cfg *config.Config,
+	log logger.Logger,
+	metric metric.Recorder,
+	tracer trace.Tracer,
+) *Client {
+	return newClient(cfg, log, metric, tracer, false)
+}
+
+// NewPublicClient initializes a new outgoing HTTP client which only connects
+// to public addresses, or to the configured allowed networks. It is used for
+// requests to URLs supplied by users, so that they cannot be used to reach
+// internal services, or cloud instance metadata.
+func NewPublicClient(cfg *config.Config,
+	log logger.Logger,
+	metric metric.Recorder,
+	tracer trace.Tracer,
+) *Client {
+	return newClient(cfg, log, metric, tracer, true)
+}
+
+// newClient initializes a new outgoing HTTP client, optionally restricting its
+// connections to public addresses.
+func newClient(cfg *config.Config,
+	log logger.Logger,
+	metric metric.Recorder,
+	tracer trace.Tracer,
+	public bool,
+) *Client {
+	if cfg == nil {
+		cfg = config.NewDefault()
+	}
+
+	if log == nil || (reflect.ValueOf(log).Kind() == reflect.Ptr &&
+		reflect.ValueOf(log).IsNil()) {
+		log = logger.NullLog
+	}
+
+	if metric == nil || (reflect.ValueOf(metric).Kind() == reflect.Ptr &&
+		reflect.ValueOf(metric).IsNil()) {
+		metric = nil
+	}
+
+	if tracer == nil || (reflect.ValueOf(tracer).Kind() == reflect.Ptr &&
+		reflect.ValueOf(tracer).IsNil()) {
+		tracer = nil
+	}
+
+	t, err := sharedTransport(cfg, public)
+	if err != nil {
+		log.Log(context.Background(), logger.LvlError,
+			"unable to initialize outgoing HTTP client transport",
+			"error", err)
+	}
+
+	c := &Client{
+		cfg:      cfg,
+		base:     t,
+		err:      err,
+		breakers: map[string]*breaker{},
+		log:      log,
+		metric:   metric,
+		tracer:   tracer,
+	}
+
+	c.client = &http.Client{
+		Transport: c,
+		Timeout:   cfg.ClientTimeout(),
+	}
+
+	return c
+}
+
+// HTTPClient returns a standard library HTTP client which performs its
+// requests using this client. It can be passed to third party libraries which
+// perform outgoing HTTP requests.
+func (c *Client) HTTPClient() *http.Client {
+	return c.client
+}
+
+// Config returns the configuration used by the client, which also provides
+// settings used by callers for their outgoing requests, such as credentials.
+func (c *Client) Config() *config.Config {
+	return c.cfg
+}
+
+// Proxy returns the URL of the proxy which is used for outgoing requests to the
+// specified URL, or nil if no proxy is used.
+func (c *Client) Proxy(u *url.URL) (*url.URL, error) {
+	return proxy(c.cfg, &http.Request{URL: u})
+}
+
+// CABundle returns the PEM encoded certificate authority bundles which are
+// trusted, in addition to the system certificate pool, for outgoing requests.
+func (c *Client) CABundle() ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	files := c.cfg.ClientCAFiles()
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	return caBundle(files)
+}
+
+// Do performs an outgoing HTTP request, retrying it if necessary.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var e *errors.Error
+		if errors.As(err, &e) {
+			return nil, e
+		}
+
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to perform request",
+			"method", req.Method,
+			"url", req.URL.Redacted())
+	}
+
+	return resp, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface, performing a single
+// outgoing HTTP request, including any retries. The circuit breaker for the
+// host records a single result for the request, regardless of the number of
+// attempts made.
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	ctx := req.Context()
+
+	host := req.URL.Host
+
+	b := c.breaker(host)
+
+	if !b.allow() {
+		if c.metric != nil {
+			c.metric.Increment(ctx, "http_client_rejected", "host:"+host)
+		}
+
+		return nil, errors.New(errors.ErrUnavailable,
+			"circuit breaker open for host",
+			"host", host)
+	}
+
+	ctx, finish := c.startSpan(ctx, req)
+
+	retries := 0
+	if retriable(req) {
+		retries = c.cfg.ClientMaxRetries()
+	}
+
+	wait := c.cfg.ClientRetryWait()
+
+	for attempt := 0; ; attempt++ {
+		r := req.WithContext(ctx)
+
+		r.Header = req.Header.Clone()
+		if r.Header == nil {
+			r.Header = http.Header{}
+		}
+
+		request.Propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				b.record(false)
+
+				finish(0, err)
+
+				return nil, errors.Wrap(err, errors.ErrClient,
+					"unable to replay request body",
+					"method", req.Method,
+					"url", req.URL.Redacted())
+			}
+
+			r.Body = body
+		}
+
+		start := time.Now()
+
+		resp, err := c.base.RoundTrip(r)
+
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+		}
+
+		if c.metric != nil {
+			c.metric.RecordDuration(ctx, "http_client_latency",
+				time.Since(start), "host:"+host, "method:"+req.Method,
+				"status:"+strconv.Itoa(status))
+		}
+
+		failed := err != nil || retryStatus(status)
+
+		if !failed || attempt >= retries || ctx.Err() != nil ||
+			rejectedAddress(err) {
+			b.record(!failed)
+
+			finish(status, err)
+
+			return resp, err
+		}
+
+		if d := retryAfter(resp); d > 0 {
+			wait = d
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+			if err := resp.Body.Close(); err != nil {
+				c.log.Log(ctx, logger.LvlDebug,
+					"unable to close retried response body",
+					"error", err,
+					"url", req.URL.Redacted())
+			}
+		}
+
+		c.log.Log(ctx, logger.LvlDebug,
+			"retrying outgoing request",
+			"method", req.Method,
+			"url", req.URL.Redacted(),
+			"status", status,
+			"error", err,
+			"attempt", attempt+1,
+			"wait", wait)
+
+		if c.metric != nil {
+			c.metric.Increment(ctx, "http_client_retries", "host:"+host)
+		}
+
+		select {
+		case <-ctx.Done():
+			b.record(false)
+
+			finish(0, ctx.Err())
+
+			return nil, errors.Context(ctx)
+		case <-time.After(wait):
+		}
+
+		// Retries are abandoned if other requests have opened the breaker.
+		if b.open() {
+			b.record(false)
+
+			finish(status, nil)
+
+			return nil, errors.New(errors.ErrUnavailable,
+				"circuit breaker open for host",
+				"host", host)
+		}
+
+		wait = min(wait*2, maxRetryWait)
+	}
+}
+
+// breaker returns the circuit breaker for a host.
+func (c *Client) breaker(host string) *breaker {
+	c.Lock()
+	defer c.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = &breaker{
+			threshold: c.cfg.ClientBreakerThreshold(),
+			timeout:   c.cfg.ClientBreakerTimeout(),
+		}
+
+		c.breakers[host] = b
+	}
+
+	return b
+}
+
+// startSpan starts a client trace span for an outgoing request and returns a
+// function to finish it.
+func (c *Client) startSpan(ctx context.Context,
+	req *http.Request,
+) (context.Context, func(status int, err error)) {
+	if c.tracer == nil {
+		return ctx, func(status int, err error) {}
+	}
+
+	ctx, span := c.tracer.Start(ctx, "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.Redacted()),
+			attribute.String("net.peer.name", req.URL.Hostname()),
+		),
+	)
+
+	return ctx, func(status int, err error) {
+		if status > 0 {
+			span.SetAttributes(attribute.Int("http.status_code", status))
+		}
+
+		if err != nil {
+			span.SetStatus(codes.Error, "request failed")
+			span.RecordError(err)
+		} else if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+
+		span.End()
+	}
+}
+
+// retriable determines whether a request can be safely sent more than once.
+func retriable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryStatus determines whether a response status code indicates a failure
+// which may succeed if retried.
+func retryStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// retryAfter returns the wait requested by the Retry-After header of a
+// response, if any.
+func retryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+		return min(time.Duration(n)*time.Second, maxRetryWait)
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return min(d, maxRetryWait)
+		}
+	}
+
+	return 0
+}
+
+// breaker values implement a circuit breaker for a single host. After a
+// threshold of consecutive failed requests, the breaker opens and requests are
+// rejected until the timeout has elapsed. The breaker is then half-open, and a
+// single trial request is allowed. If it succeeds the breaker closes, otherwise
+// it opens again.
+type breaker struct {
+	sync.Mutex
+	threshold int
+	timeout   time.Duration
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// open determines whether the breaker is open and rejecting all requests.
+func (b *breaker) open() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	return time.Now().Before(b.openUntil)
+}
+
+// allow determines whether a request to the host is allowed. When the breaker
+// is half-open, only the first request is allowed, until its result is
+// recorded.
+func (b *breaker) allow() bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+
+	b.trial = true
+
+	return true
+}
+
+// record records the result of a request to the host.
+func (b *breaker) record(success bool) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.trial = false
+
+	if success {
+		b.failures = 0
+
+		return
+	}
+
+	b.failures++
+
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.timeout)
+	}
+}
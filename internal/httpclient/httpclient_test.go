@@ -0,0 +1,471 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func testConfig(threshold int) *config.Config {
+	cfg := config.NewDefault()
+
+	cfg.SetClient(&config.ClientConfig{
+		Timeout:          time.Second * 5,
+		MaxRetries:       2,
+		RetryWait:        time.Millisecond,
+		MaxIdleConns:     10,
+		IdleConnTimeout:  time.Minute,
+		BreakerThreshold: threshold,
+		BreakerTimeout:   time.Minute,
+	})
+
+	return cfg
+}
+
+func TestClientRetry(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	defer ts.Close()
+
+	cli := httpclient.NewClient(testConfig(10), nil, nil, nil)
+
+	req, err := http.NewRequestWithContext(context.Background(),
+		http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status: %v, got: %v", http.StatusOK,
+			resp.StatusCode)
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("Expected calls: 3, got: %v", calls.Load())
+	}
+}
+
+func TestClientTracePropagation(t *testing.T) {
+	t.Parallel()
+
+	var header atomic.Value
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			header.Store(r.Header.Get("traceparent"))
+
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	defer ts.Close()
+
+	cli := httpclient.NewClient(testConfig(10), nil, nil, nil)
+
+	tID, err := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sID, err := trace.SpanIDFromHex("b7ad6b7169203331")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    tID,
+			SpanID:     sID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	exp := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	if v, _ := header.Load().(string); v != exp {
+		t.Errorf("Expected traceparent: %v, got: %v", exp, v)
+	}
+
+	if req.Header.Get("traceparent") != "" {
+		t.Errorf("Expected original request headers to be unchanged")
+	}
+}
+
+func TestClientNoRetry(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+	defer ts.Close()
+
+	cli := httpclient.NewClient(testConfig(10), nil, nil, nil)
+
+	req, err := http.NewRequestWithContext(context.Background(),
+		http.MethodPost, ts.URL, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status: %v, got: %v",
+			http.StatusServiceUnavailable, resp.StatusCode)
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected calls: 1, got: %v", calls.Load())
+	}
+}
+
+func TestClientBreaker(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+
+	defer ts.Close()
+
+	cli := httpclient.NewClient(testConfig(2), nil, nil, nil)
+
+	req, err := http.NewRequestWithContext(context.Background(),
+		http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each request is attempted three times, but counts as a single failure.
+	for i := 1; i <= 2; i++ {
+		resp, err := cli.Do(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadGateway {
+			t.Errorf("Expected status: %v, got: %v", http.StatusBadGateway,
+				resp.StatusCode)
+		}
+
+		if exp := int32(i * 3); calls.Load() != exp {
+			t.Errorf("Expected calls: %v, got: %v", exp, calls.Load())
+		}
+	}
+
+	if _, err := cli.Do(req); !errors.Has(err, errors.ErrUnavailable) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrUnavailable, err)
+	}
+
+	if calls.Load() != 6 {
+		t.Errorf("Expected calls: 6, got: %v", calls.Load())
+	}
+}
+
+func TestClientBreakerHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	started, release := make(chan struct{}), make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			switch calls.Add(1) {
+			case 1:
+				w.WriteHeader(http.StatusBadGateway)
+
+				return
+			case 2:
+				close(started)
+
+				<-release
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	defer ts.Close()
+
+	cfg := config.NewDefault()
+
+	cfg.SetClient(&config.ClientConfig{
+		Timeout:          time.Second * 5,
+		MaxRetries:       -1,
+		BreakerThreshold: 1,
+		BreakerTimeout:   time.Millisecond * 10,
+	})
+
+	cli := httpclient.NewClient(cfg, nil, nil, nil)
+
+	do := func() (int, error) {
+		req, err := http.NewRequestWithContext(context.Background(),
+			http.MethodGet, ts.URL, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		resp, err := cli.Do(req)
+		if err != nil {
+			return 0, err
+		}
+
+		resp.Body.Close()
+
+		return resp.StatusCode, nil
+	}
+
+	if status, err := do(); err != nil || status != http.StatusBadGateway {
+		t.Fatalf("Expected status: %v, got: %v, %v", http.StatusBadGateway,
+			status, err)
+	}
+
+	if _, err := do(); !errors.Has(err, errors.ErrUnavailable) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrUnavailable, err)
+	}
+
+	time.Sleep(time.Millisecond * 20)
+
+	trial := make(chan int)
+
+	go func() {
+		status, _ := do()
+
+		trial <- status
+	}()
+
+	<-started
+
+	if _, err := do(); !errors.Has(err, errors.ErrUnavailable) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrUnavailable, err)
+	}
+
+	close(release)
+
+	if status := <-trial; status != http.StatusOK {
+		t.Errorf("Expected status: %v, got: %v", http.StatusOK, status)
+	}
+
+	if status, err := do(); err != nil || status != http.StatusOK {
+		t.Errorf("Expected status: %v, got: %v, %v", http.StatusOK,
+			status, err)
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("Expected calls: 3, got: %v", calls.Load())
+	}
+}
+
+func TestClientProxy(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(10)
+
+	cfg.SetClient(&config.ClientConfig{
+		Proxy: "http://proxy:3128",
+		Proxies: map[string]string{
+			".example.com":    "http://example-proxy:3128",
+			"api.example.com": "direct",
+		},
+		NoProxy: []string{".internal"},
+	})
+
+	cli := httpclient.NewClient(cfg, nil, nil, nil)
+
+	tests := []struct {
+		name string
+		url  string
+		exp  string
+	}{{
+		name: "default",
+		url:  "https://test.com/path",
+		exp:  "http://proxy:3128",
+	}, {
+		name: "destination",
+		url:  "https://git.example.com/repo",
+		exp:  "http://example-proxy:3128",
+	}, {
+		name: "direct destination",
+		url:  "https://api.example.com:8443/path",
+		exp:  "",
+	}, {
+		name: "no proxy",
+		url:  "https://auth.internal/jwks",
+		exp:  "",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pu, err := cli.Proxy(u)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			res := ""
+			if pu != nil {
+				res = pu.String()
+			}
+
+			if res != tt.exp {
+				t.Errorf("Expected proxy: %v, got: %v", tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestClientCABundle(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	defer ts.Close()
+
+	dir := t.TempDir()
+
+	caFile := filepath.Join(dir, "ca.pem")
+
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: ts.Certificate().Raw,
+	}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig(10)
+
+	cfg.SetClient(&config.ClientConfig{
+		MaxRetries: -1,
+		CAFiles:    []string{caFile},
+	})
+
+	cli := httpclient.NewClient(cfg, nil, nil, nil)
+
+	buf, err := cli.CABundle()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(buf) == 0 {
+		t.Error("Expected certificate authority bundle")
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(),
+		http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status: %v, got: %v", http.StatusOK,
+			resp.StatusCode)
+	}
+}
+
+func TestClientCABundleInvalid(t *testing.T) {
+	t.Parallel()
+
+	cfg := testConfig(10)
+
+	cfg.SetClient(&config.ClientConfig{
+		CAFiles: []string{filepath.Join(t.TempDir(), "missing.pem")},
+	})
+
+	cli := httpclient.NewClient(cfg, nil, nil, nil)
+
+	if _, err := cli.CABundle(); !errors.Has(err, errors.ErrConfiguration) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrConfiguration, err)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(),
+		http.MethodGet, "https://localhost", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.Do(req); !errors.Has(err, errors.ErrConfiguration) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrConfiguration, err)
+	}
+}
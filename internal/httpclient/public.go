@@ -0,0 +1,95 @@
+package httpclient
+
+import (
+	"net/netip"
+	"syscall"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// nonPublicPrefixes contain the networks which are not reachable on the public
+// internet, but are not reported as private by the standard library.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// parseNetworks parses networks specified in CIDR notation.
+func parseNetworks(networks []string) ([]netip.Prefix, error) {
+	res := make([]netip.Prefix, 0, len(networks))
+
+	for _, n := range networks {
+		p, err := netip.ParsePrefix(n)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrConfiguration,
+				"invalid allowed network",
+				"network", n)
+		}
+
+		res = append(res, p.Masked())
+	}
+
+	return res, nil
+}
+
+// publicAddr determines whether an address is a public unicast address. The
+// loopback, private, link-local, including the cloud instance metadata address
+// 169.254.169.254, unspecified and multicast addresses are not public.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// publicControl returns a dial control function which rejects connections to
+// addresses which are not public, unless they are within one of the allowed
+// networks. It is called with the resolved address of each connection, so host
+// names resolving to internal addresses are also rejected.
+func publicControl(allowed []netip.Prefix,
+) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		ap, err := netip.ParseAddrPort(address)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrForbidden,
+				"invalid connection address",
+				"address", address)
+		}
+
+		addr := ap.Addr().Unmap()
+
+		if publicAddr(addr) {
+			return nil
+		}
+
+		for _, p := range allowed {
+			if p.Contains(addr) {
+				return nil
+			}
+		}
+
+		return errors.New(errors.ErrForbidden,
+			"connection to non-public address not allowed",
+			"address", address)
+	}
+}
+
+// rejectedAddress determines whether a request failed because it connected to
+// an address which is not allowed. Such requests are not retried.
+func rejectedAddress(err error) bool {
+	var e *errors.Error
+
+	return errors.As(err, &e) && e.Code == errors.ErrForbidden
+}
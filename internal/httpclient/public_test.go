@@ -0,0 +1,126 @@
+package httpclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+)
+
+func TestPublicClient(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int64
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	defer ts.Close()
+
+	localhost := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name    string
+		url     string
+		allowed []string
+		err     bool
+	}{{
+		name: "loopback",
+		url:  ts.URL,
+		err:  true,
+	}, {
+		name: "loopback name",
+		url:  localhost,
+		err:  true,
+	}, {
+		name: "metadata",
+		url:  "http://169.254.169.254/latest/meta-data/",
+		err:  true,
+	}, {
+		name: "private",
+		url:  "http://10.0.0.1/",
+		err:  true,
+	}, {
+		name:    "allowed",
+		url:     ts.URL,
+		allowed: []string{"127.0.0.0/8"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.NewDefault()
+
+			cfg.SetClient(&config.ClientConfig{
+				Timeout:          time.Second * 5,
+				MaxRetries:       2,
+				RetryWait:        time.Millisecond,
+				BreakerThreshold: 10,
+				BreakerTimeout:   time.Minute,
+				NoProxy:          []string{"*"},
+				AllowedNetworks:  tt.allowed,
+			})
+
+			cli := httpclient.NewPublicClient(cfg, nil, nil, nil)
+
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := cli.Do(req)
+			if tt.err {
+				if !errors.Has(err, errors.ErrForbidden) {
+					t.Errorf("Expected forbidden error, got: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected status: %v, got: %v", http.StatusOK,
+					resp.StatusCode)
+			}
+		})
+	}
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected calls: 1, got: %v", n)
+	}
+}
+
+func TestPublicClientInvalidNetwork(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	cfg.SetClient(&config.ClientConfig{
+		AllowedNetworks: []string{"invalid"},
+	})
+
+	cli := httpclient.NewPublicClient(cfg, nil, nil, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.Do(req); !errors.Has(err, errors.ErrConfiguration) {
+		t.Errorf("Expected configuration error, got: %v", err)
+	}
+}
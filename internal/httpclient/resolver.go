@@ -0,0 +1,286 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// resolverEntry values contain the cached addresses of a single host.
+type resolverEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// Resolver values are used to resolve host names to addresses, caching the
+// results for a configurable time. If a lookup fails, any expired addresses
+// cached for the host are used instead.
+type Resolver struct {
+	sync.Mutex
+	ttl     time.Duration
+	lookup  func(ctx context.Context, host string) ([]string, error)
+	entries map[string]*resolverEntry
+}
+
+// NewResolver creates a new caching resolver, using the specified lookup
+// function. If lookup is nil, the default system resolver is used. If ttl is
+// not positive, lookups are not cached.
+func NewResolver(ttl time.Duration,
+	lookup func(ctx context.Context, host string) ([]string, error),
+) *Resolver {
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+
+	return &Resolver{
+		ttl:     ttl,
+		lookup:  lookup,
+		entries: map[string]*resolverEntry{},
+	}
+}
+
+// LookupHost returns the addresses of a host.
+func (r *Resolver) LookupHost(ctx context.Context,
+	host string,
+) ([]string, error) {
+	if r.ttl <= 0 {
+		return r.lookup(ctx, host)
+	}
+
+	now := time.Now()
+
+	r.Lock()
+
+	e, ok := r.entries[host]
+
+	r.Unlock()
+
+	if ok && now.Before(e.expires) {
+		return e.addrs, nil
+	}
+
+	addrs, err := r.lookup(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		if ok {
+			return e.addrs, nil
+		}
+
+		if err == nil {
+			err = errors.New(errors.ErrClient,
+				"no addresses found for host",
+				"host", host)
+		}
+
+		return nil, err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for k, v := range r.entries {
+		if now.After(v.expires.Add(r.ttl)) {
+			delete(r.entries, k)
+		}
+	}
+
+	r.entries[host] = &resolverEntry{
+		addrs:   addrs,
+		expires: now.Add(r.ttl),
+	}
+
+	return addrs, nil
+}
+
+// dialer values are used to open outgoing connections using cached host
+// addresses. Addresses of the preferred family are attempted first, with
+// addresses of the other family being attempted in parallel after the fallback
+// delay.
+type dialer struct {
+	dialer   *net.Dialer
+	resolver *Resolver
+	network  string
+}
+
+// DialContext opens a connection to an address.
+func (d *dialer) DialContext(ctx context.Context,
+	network, address string,
+) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || net.ParseIP(host) != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	if network == "tcp" {
+		network = d.network
+	}
+
+	addrs, err := d.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	primaries, fallbacks := partitionAddrs(addrs, network)
+
+	if len(primaries) == 0 {
+		return nil, errors.New(errors.ErrClient,
+			"no suitable addresses found for host",
+			"host", host,
+			"network", network)
+	}
+
+	if len(fallbacks) == 0 || d.dialer.FallbackDelay < 0 {
+		return d.dialSerial(ctx, network, port, append(primaries,
+			fallbacks...))
+	}
+
+	return d.dialParallel(ctx, network, port, primaries, fallbacks)
+}
+
+// dialSerial attempts to connect to each address in turn, returning the first
+// successful connection.
+func (d *dialer) dialSerial(ctx context.Context,
+	network, port string,
+	addrs []string,
+) (net.Conn, error) {
+	var firstErr error
+
+	for _, addr := range addrs {
+		c, err := d.dialer.DialContext(ctx, network,
+			net.JoinHostPort(addr, port))
+		if err == nil {
+			return c, nil
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, firstErr
+}
+
+// dialParallel races connections to the primary and fallback addresses,
+// starting the fallback addresses after the fallback delay, or as soon as the
+// primary addresses have failed.
+func (d *dialer) dialParallel(ctx context.Context,
+	network, port string,
+	primaries, fallbacks []string,
+) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+
+	results := make(chan result, 2)
+
+	start := func(addrs []string) {
+		go func() {
+			c, err := d.dialSerial(ctx, network, port, addrs)
+
+			results <- result{conn: c, err: err}
+		}()
+	}
+
+	start(primaries)
+
+	delay := d.dialer.FallbackDelay
+	if delay == 0 {
+		delay = config.DefaultClientFallbackDelay
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	pending, fallback := 1, false
+
+	var firstErr error
+
+	for {
+		select {
+		case <-timer.C:
+			if !fallback {
+				fallback = true
+				pending++
+
+				start(fallbacks)
+			}
+		case res := <-results:
+			pending--
+
+			if res.err == nil {
+				if pending > 0 {
+					// Close the connection which loses the race.
+					go func() {
+						if r := <-results; r.conn != nil {
+							_ = r.conn.Close()
+						}
+					}()
+				}
+
+				return res.conn, nil
+			}
+
+			if firstErr == nil {
+				firstErr = res.err
+			}
+
+			if !fallback {
+				fallback = true
+				pending++
+
+				start(fallbacks)
+
+				continue
+			}
+
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// partitionAddrs splits addresses into those of the preferred family, which is
+// the family of the first address, and the remaining addresses. If the network
+// is restricted to a single family, only addresses of that family are returned.
+func partitionAddrs(addrs []string,
+	network string,
+) (primaries, fallbacks []string) {
+	isV4 := func(addr string) bool {
+		ip := net.ParseIP(addr)
+
+		return ip != nil && ip.To4() != nil
+	}
+
+	for _, addr := range addrs {
+		switch network {
+		case "tcp4":
+			if isV4(addr) {
+				primaries = append(primaries, addr)
+			}
+		case "tcp6":
+			if !isV4(addr) {
+				primaries = append(primaries, addr)
+			}
+		default:
+			if len(primaries) == 0 || isV4(addr) == isV4(primaries[0]) {
+				primaries = append(primaries, addr)
+			} else {
+				fallbacks = append(fallbacks, addr)
+			}
+		}
+	}
+
+	return primaries, fallbacks
+}
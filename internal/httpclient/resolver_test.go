@@ -0,0 +1,119 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/httpclient"
+)
+
+func TestResolver(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	fail := atomic.Bool{}
+
+	r := httpclient.NewResolver(time.Millisecond*50,
+		func(ctx context.Context, host string) ([]string, error) {
+			calls.Add(1)
+
+			if fail.Load() {
+				return nil, errors.New("lookup failed")
+			}
+
+			return []string{"127.0.0.1"}, nil
+		})
+
+	ctx := context.Background()
+
+	for range 3 {
+		addrs, err := r.LookupHost(ctx, "test.com")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(addrs) != 1 || addrs[0] != "127.0.0.1" {
+			t.Errorf("Expected addresses: [127.0.0.1], got: %v", addrs)
+		}
+	}
+
+	if calls.Load() != 1 {
+		t.Errorf("Expected lookups: 1, got: %v", calls.Load())
+	}
+
+	time.Sleep(time.Millisecond * 60)
+
+	fail.Store(true)
+
+	addrs, err := r.LookupHost(ctx, "test.com")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(addrs) != 1 || addrs[0] != "127.0.0.1" {
+		t.Errorf("Expected stale addresses: [127.0.0.1], got: %v", addrs)
+	}
+
+	if calls.Load() != 2 {
+		t.Errorf("Expected lookups: 2, got: %v", calls.Load())
+	}
+
+	if _, err := r.LookupHost(ctx, "other.com"); err == nil {
+		t.Error("Expected error for failed lookup but got nil")
+	}
+}
+
+func TestClientDial(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+	defer ts.Close()
+
+	for _, network := range []string{"tcp", "tcp4"} {
+		t.Run(network, func(t *testing.T) {
+			cfg := config.NewDefault()
+
+			cfg.SetClient(&config.ClientConfig{
+				Timeout:       time.Second * 5,
+				MaxRetries:    -1,
+				DNSTTL:        time.Minute,
+				DialTimeout:   time.Second,
+				DialNetwork:   network,
+				FallbackDelay: time.Millisecond * 10,
+			})
+
+			cli := httpclient.NewClient(cfg, nil, nil, nil)
+
+			req, err := http.NewRequestWithContext(context.Background(),
+				http.MethodGet,
+				strings.Replace(ts.URL, "127.0.0.1", "localhost", 1), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := cli.Do(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected status: %v, got: %v", http.StatusOK,
+					resp.StatusCode)
+			}
+		})
+	}
+}
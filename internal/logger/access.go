@@ -0,0 +1,182 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Access log sinks supported.
+const (
+	AccessSinkStdout = "stdout"
+	AccessSinkStderr = "stderr"
+	AccessSinkFile   = "file"
+	AccessSinkSyslog = "syslog"
+)
+
+// Access log fields supported.
+const (
+	AccessFieldTime      = "time"
+	AccessFieldMethod    = "method"
+	AccessFieldURI       = "uri"
+	AccessFieldPath      = "path"
+	AccessFieldRoute     = "route"
+	AccessFieldProtocol  = "protocol"
+	AccessFieldStatus    = "status"
+	AccessFieldBytes     = "bytes"
+	AccessFieldLatency   = "latency_ms"
+	AccessFieldRemote    = "remote"
+	AccessFieldUserAgent = "user_agent"
+	AccessFieldReferer   = "referer"
+	AccessFieldTraceID   = "trace_id"
+)
+
+// AccessFields contains all of the supported access log fields, in the order
+// they are written when no field set is configured.
+var AccessFields = []string{
+	AccessFieldTime,
+	AccessFieldMethod,
+	AccessFieldURI,
+	AccessFieldPath,
+	AccessFieldRoute,
+	AccessFieldProtocol,
+	AccessFieldStatus,
+	AccessFieldBytes,
+	AccessFieldLatency,
+	AccessFieldRemote,
+	AccessFieldUserAgent,
+	AccessFieldReferer,
+	AccessFieldTraceID,
+}
+
+// AccessEntry values represent a single request in the access log.
+type AccessEntry struct {
+	Time      time.Time
+	Method    string
+	URI       string
+	Path      string
+	Route     string
+	Protocol  string
+	Status    int
+	Bytes     int64
+	Latency   time.Duration
+	Remote    string
+	UserAgent string
+	Referer   string
+	TraceID   string
+}
+
+// values returns the values of the entry keyed by field name.
+func (e *AccessEntry) values() map[string]any {
+	return map[string]any{
+		AccessFieldTime:      e.Time.UTC().Format(time.RFC3339Nano),
+		AccessFieldMethod:    e.Method,
+		AccessFieldURI:       e.URI,
+		AccessFieldPath:      e.Path,
+		AccessFieldRoute:     e.Route,
+		AccessFieldProtocol:  e.Protocol,
+		AccessFieldStatus:    e.Status,
+		AccessFieldBytes:     e.Bytes,
+		AccessFieldLatency:   float64(e.Latency.Microseconds()) / 1000,
+		AccessFieldRemote:    e.Remote,
+		AccessFieldUserAgent: e.UserAgent,
+		AccessFieldReferer:   e.Referer,
+		AccessFieldTraceID:   e.TraceID,
+	}
+}
+
+// AccessLog values write structured access log entries, as JSON lines, to a
+// sink. Successful requests may be sampled, but failed requests are always
+// written.
+type AccessLog struct {
+	sync.Mutex
+	w      io.Writer
+	fields []string
+	sample float64
+}
+
+// NewAccessLog creates a new access log writing to w. Only the fields listed
+// are written, or all supported fields if none are listed. The sample is the
+// ratio, between 0 and 1, of successful requests written.
+func NewAccessLog(w io.Writer, fields []string, sample float64) *AccessLog {
+	if w == nil {
+		w = io.Discard
+	}
+
+	fs := make([]string, 0, len(fields))
+
+	for _, f := range fields {
+		if slices.Contains(AccessFields, f) && !slices.Contains(fs, f) {
+			fs = append(fs, f)
+		}
+	}
+
+	if len(fs) == 0 {
+		fs = AccessFields
+	}
+
+	if sample <= 0 || sample > 1 {
+		sample = 1
+	}
+
+	return &AccessLog{
+		w:      w,
+		fields: fs,
+		sample: sample,
+	}
+}
+
+// Fields returns the fields written to the access log.
+func (a *AccessLog) Fields() []string {
+	return slices.Clone(a.fields)
+}
+
+// Write writes an entry to the access log, unless it is excluded by sampling.
+func (a *AccessLog) Write(e *AccessEntry) error {
+	if e == nil {
+		return nil
+	}
+
+	if e.Status < http.StatusBadRequest && a.sample < 1 &&
+		rand.Float64() >= a.sample {
+		return nil
+	}
+
+	vals := e.values()
+
+	res := make(map[string]any, len(a.fields))
+
+	for _, f := range a.fields {
+		res[f] = vals[f]
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	a.Lock()
+	defer a.Unlock()
+
+	_, err = a.w.Write(append(b, '\n'))
+
+	return err
+}
+
+// Close closes the sink of the access log, unless it is a standard output.
+func (a *AccessLog) Close() error {
+	if a.w == os.Stdout || a.w == os.Stderr {
+		return nil
+	}
+
+	if c, ok := a.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package logger_test
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	e := &logger.AccessEntry{
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Method:  http.MethodGet,
+		URI:     "/api/v1/resources?size=1",
+		Path:    "/api/v1/resources",
+		Status:  http.StatusOK,
+		Bytes:   10,
+		Latency: time.Millisecond * 1500,
+		TraceID: "0af7651916cd43dd8448eb211c80319c",
+	}
+
+	tests := []struct {
+		name   string
+		fields []string
+		sample float64
+		status int
+		exp    string
+	}{{
+		name:   "fields",
+		fields: []string{"method", "status", "latency_ms", "invalid", "method"},
+		sample: 1,
+		status: http.StatusOK,
+		exp:    `{"latency_ms":1500,"method":"GET","status":200}`,
+	}, {
+		name:   "all fields",
+		sample: 1,
+		status: http.StatusOK,
+		exp: `{"bytes":10,"latency_ms":1500,"method":"GET",` +
+			`"path":"/api/v1/resources","protocol":"","referer":"",` +
+			`"remote":"","route":"","status":200,` +
+			`"time":"2024-01-02T03:04:05Z",` +
+			`"trace_id":"0af7651916cd43dd8448eb211c80319c",` +
+			`"uri":"/api/v1/resources?size=1","user_agent":""}`,
+	}, {
+		name:   "sampled",
+		fields: []string{"status"},
+		sample: 0.000001,
+		status: http.StatusOK,
+		exp:    ``,
+	}, {
+		name:   "sampled error",
+		fields: []string{"status"},
+		sample: 0.000001,
+		status: http.StatusInternalServerError,
+		exp:    `{"status":500}`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := &bytes.Buffer{}
+
+			al := logger.NewAccessLog(buf, tt.fields, tt.sample)
+
+			te := *e
+
+			te.Status = tt.status
+
+			if err := al.Write(&te); err != nil {
+				t.Fatal(err)
+			}
+
+			if res := strings.TrimSpace(buf.String()); res != tt.exp {
+				t.Errorf("Expected entry: %v, got: %v", tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+
+	rf, err := logger.NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := rf.Write([]byte(v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, exp := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != exp {
+			t.Errorf("Expected %v to contain: %q, got: %q", name, exp, b)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected only 2 rotated files, got: %v", err)
+	}
+}
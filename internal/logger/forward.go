@@ -0,0 +1,331 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// Log forwarding sinks supported.
+const (
+	ForwardSinkSyslog = "syslog"
+	ForwardSinkHTTP   = "http"
+	ForwardSinkKafka  = "kafka"
+)
+
+const (
+	forwardBatchSize = 100
+	forwardInterval  = time.Second
+	forwardTimeout   = 10 * time.Second
+)
+
+// Counter is implemented by the metric recorders used to count the log
+// entries forwarded and dropped.
+type Counter interface {
+	Add(ctx context.Context, name string, value int64, tags ...string)
+}
+
+// Forwarder values implement an io.WriteCloser which forwards log entries,
+// one per write, to a sink in the background. Entries are buffered and sent
+// in batches, so writes never wait on the sink. Entries written while the
+// buffer is full, or which the sink fails to accept, are dropped and counted.
+type Forwarder struct {
+	sync.RWMutex
+	sink    string
+	send    func(ctx context.Context, entries [][]byte) error
+	close   func() error
+	entries chan []byte
+	done    chan struct{}
+	closed  bool
+	counter Counter
+	sent    atomic.Int64
+	dropped atomic.Int64
+}
+
+// NewForwarder creates a new forwarder buffering up to size entries for the
+// sink. The address is that of the syslog server, such as udp://localhost:514,
+// the URL to which batches of entries are posted as JSON lines, or the URL of
+// a topic of a Kafka REST proxy, such as http://localhost:8082/topics/logs.
+// Syslog entries are written with the tag.
+func NewForwarder(sink, address, tag string, size int) (*Forwarder, error) {
+	if size <= 0 {
+		size = 1
+	}
+
+	f := &Forwarder{
+		sink:    sink,
+		entries: make(chan []byte, size),
+		done:    make(chan struct{}),
+	}
+
+	switch sink {
+	case ForwardSinkSyslog:
+		w, err := NewSyslogWriter(address, tag)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrLog,
+				"unable to connect to syslog server",
+				"address", address)
+		}
+
+		f.send = func(_ context.Context, entries [][]byte) error {
+			for _, e := range entries {
+				if _, err := w.Write(e); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		f.close = w.Close
+	case ForwardSinkHTTP, ForwardSinkKafka:
+		u, err := url.Parse(address)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") ||
+			u.Host == "" {
+			return nil, errors.New(errors.ErrLog,
+				"invalid log forwarding address",
+				"sink", sink,
+				"address", address)
+		}
+
+		client := &http.Client{Timeout: forwardTimeout}
+
+		f.send = func(ctx context.Context, entries [][]byte) error {
+			ct, body := "application/x-ndjson", ndjsonBody(entries)
+
+			if sink == ForwardSinkKafka {
+				ct, body = "application/vnd.kafka.json.v2+json",
+					kafkaBody(entries)
+			}
+
+			return post(ctx, client, address, ct, body)
+		}
+	default:
+		return nil, errors.New(errors.ErrLog,
+			"invalid log forwarding sink",
+			"sink", sink)
+	}
+
+	go f.run()
+
+	return f, nil
+}
+
+// SetCounter sets the metric recorder used to count the entries forwarded
+// and dropped.
+func (f *Forwarder) SetCounter(c Counter) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.counter = c
+}
+
+// Sent returns the number of entries forwarded to the sink.
+func (f *Forwarder) Sent() int64 {
+	return f.sent.Load()
+}
+
+// Dropped returns the number of entries dropped.
+func (f *Forwarder) Dropped() int64 {
+	return f.dropped.Load()
+}
+
+// count records the number of entries forwarded, or dropped for a reason.
+func (f *Forwarder) count(n int64, reason string) {
+	if n == 0 {
+		return
+	}
+
+	name, tags := "log_forward_sent", []string{"sink:" + f.sink}
+
+	if reason == "" {
+		f.sent.Add(n)
+	} else {
+		f.dropped.Add(n)
+
+		name, tags = "log_forward_dropped", append(tags, "reason:"+reason)
+	}
+
+	f.RLock()
+	c := f.counter
+	f.RUnlock()
+
+	if c != nil {
+		c.Add(context.Background(), name, n, tags...)
+	}
+}
+
+// Write buffers an entry for forwarding, or drops it if the buffer is full.
+// It never returns an error, so forwarding does not interrupt other outputs.
+func (f *Forwarder) Write(p []byte) (int, error) {
+	e := bytes.Clone(bytes.TrimRight(p, "\n"))
+
+	f.RLock()
+
+	if f.closed {
+		f.RUnlock()
+
+		f.count(1, "closed")
+
+		return len(p), nil
+	}
+
+	select {
+	case f.entries <- e:
+		f.RUnlock()
+	default:
+		f.RUnlock()
+
+		f.count(1, "full")
+	}
+
+	return len(p), nil
+}
+
+// run sends the buffered entries to the sink, in batches, until the
+// forwarder is closed.
+func (f *Forwarder) run() {
+	defer close(f.done)
+
+	t := time.NewTicker(forwardInterval)
+	defer t.Stop()
+
+	batch := make([][]byte, 0, forwardBatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(),
+			forwardTimeout)
+
+		if err := f.send(ctx, batch); err != nil {
+			f.count(int64(len(batch)), "error")
+		} else {
+			f.count(int64(len(batch)), "")
+		}
+
+		cancel()
+
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e, ok := <-f.entries:
+			if !ok {
+				flush()
+
+				return
+			}
+
+			batch = append(batch, e)
+
+			if len(batch) >= forwardBatchSize {
+				flush()
+			}
+		case <-t.C:
+			flush()
+		}
+	}
+}
+
+// Close sends any buffered entries to the sink and closes the forwarder.
+func (f *Forwarder) Close() error {
+	f.Lock()
+
+	if f.closed {
+		f.Unlock()
+
+		return nil
+	}
+
+	f.closed = true
+
+	close(f.entries)
+
+	f.Unlock()
+
+	<-f.done
+
+	if f.close != nil {
+		return f.close()
+	}
+
+	return nil
+}
+
+// ndjsonBody returns a request body containing the entries as JSON lines.
+func ndjsonBody(entries [][]byte) []byte {
+	return append(bytes.Join(entries, []byte{'\n'}), '\n')
+}
+
+// kafkaBody returns a Kafka REST proxy request body producing a record for
+// each entry. Entries which are not JSON, such as text entries, are produced
+// as JSON strings.
+func kafkaBody(entries [][]byte) []byte {
+	type record struct {
+		Value json.RawMessage `json:"value"`
+	}
+
+	req := struct {
+		Records []record `json:"records"`
+	}{Records: make([]record, 0, len(entries))}
+
+	for _, e := range entries {
+		v := json.RawMessage(e)
+
+		if !json.Valid(e) {
+			v, _ = json.Marshal(string(e))
+		}
+
+		req.Records = append(req.Records, record{Value: v})
+	}
+
+	b, _ := json.Marshal(req)
+
+	return b
+}
+
+// post sends a request body to the address, failing unless the response is
+// successful.
+func post(ctx context.Context,
+	client *http.Client,
+	address, contentType string,
+	body []byte,
+) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address,
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return errors.New(errors.ErrLog,
+			"unable to forward log entries",
+			"address", address,
+			"status", res.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package logger_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+func TestForwarder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sink string
+		ct   string
+		body string
+	}{{
+		name: "http",
+		sink: logger.ForwardSinkHTTP,
+		ct:   "application/x-ndjson",
+		body: `{"msg":"test"}` + "\ntext\n",
+	}, {
+		name: "kafka",
+		sink: logger.ForwardSinkKafka,
+		ct:   "application/vnd.kafka.json.v2+json",
+		body: `{"records":[{"value":{"msg":"test"}},{"value":"text"}]}`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				mu     sync.Mutex
+				ct     string
+				bodies []string
+			)
+
+			ts := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					b, _ := io.ReadAll(r.Body)
+
+					mu.Lock()
+					defer mu.Unlock()
+
+					ct = r.Header.Get("Content-Type")
+					bodies = append(bodies, string(b))
+				}))
+
+			defer ts.Close()
+
+			f, err := logger.NewForwarder(tt.sink, ts.URL+"/topics/logs",
+				"test", 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, e := range []string{`{"msg":"test"}` + "\n", "text\n"} {
+				if _, err := f.Write([]byte(e)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if ct != tt.ct {
+				t.Errorf("Expected content type: %v, got: %v", tt.ct, ct)
+			}
+
+			if body := strings.Join(bodies, ""); body != tt.body {
+				t.Errorf("Expected body: %v, got: %v", tt.body, body)
+			}
+
+			if f.Sent() != 2 {
+				t.Errorf("Expected sent: 2, got: %v", f.Sent())
+			}
+
+			if _, err := f.Write([]byte("closed\n")); err != nil {
+				t.Fatal(err)
+			}
+
+			if f.Dropped() != 1 {
+				t.Errorf("Expected dropped: 1, got: %v", f.Dropped())
+			}
+		})
+	}
+}
+
+func TestForwarderDropped(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+	defer ts.Close()
+
+	f, err := logger.NewForwarder(logger.ForwardSinkHTTP, ts.URL, "test", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logger.NewWriter(f, logger.LogBackendSlog, logger.LogFmtJSON,
+		logger.LvlInfo)
+
+	for range 100 {
+		l.Log(mockContext(), logger.LvlInfo, "test")
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Sent() != 0 {
+		t.Errorf("Expected sent: 0, got: %v", f.Sent())
+	}
+
+	if f.Dropped() != 100 {
+		t.Errorf("Expected dropped: 100, got: %v", f.Dropped())
+	}
+}
+
+func TestNewForwarderInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := logger.NewForwarder("invalid", "", "test", 1); err == nil {
+		t.Error("Expected error for invalid sink")
+	}
+
+	if _, err := logger.NewForwarder(logger.ForwardSinkHTTP, "localhost",
+		"test", 1); err == nil {
+		t.Error("Expected error for invalid address")
+	}
+}
@@ -3,8 +3,10 @@ package logger
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
+	"time"
 )
 
 // Log levels supported.
@@ -26,6 +28,13 @@ const (
 	LogFmtText = "text"
 )
 
+// Logging libraries supported.
+const (
+	LogBackendSlog    = "slog"
+	LogBackendZap     = "zap"
+	LogBackendZerolog = "zerolog"
+)
+
 // Logger is the required logger interface for this service.
 type Logger interface {
 	Log(ctx context.Context, level slog.Level, msg string, args ...any)
@@ -41,15 +50,83 @@ func New(output, format string,
 		out = os.Stdout
 	}
 
+	return NewWriter(out, LogBackendSlog, format, level)
+}
+
+// NewWriter returns a new logger, writing entries to w using the specified
+// logging library. The minimum level of the entries written is read from level
+// for each entry, so that a *slog.LevelVar may be used to change it while the
+// logger is in use.
+func NewWriter(w io.Writer,
+	backend, format string,
+	level slog.Leveler,
+) Logger {
+	switch backend {
+	case LogBackendZap:
+		return NewZapLogger(newZap(w, format, level))
+	case LogBackendZerolog:
+		return &ZerologLogger{l: newZerolog(w, format), level: level}
+	}
+
 	if format == LogFmtText {
-		return slog.New(NewLogHandler(slog.NewTextHandler(out,
+		return slog.New(NewLogHandler(slog.NewTextHandler(w,
 			&slog.HandlerOptions{Level: level})))
 	}
 
-	return slog.New(NewLogHandler(slog.NewJSONHandler(out,
+	return slog.New(NewLogHandler(slog.NewJSONHandler(w,
 		&slog.HandlerOptions{Level: level})))
 }
 
+// traceID returns the trace ID stored in the context, or none.
+func traceID(ctx context.Context) string {
+	tID, ok := ctx.Value(5).(string)
+	if !ok {
+		tID = "none"
+	}
+
+	return tID
+}
+
+// attrs returns the attributes for a set of log arguments, which are
+// key-value pairs or slog.Attr values, as they are parsed by slog.
+func attrs(args []any) []slog.Attr {
+	if len(args) == 0 {
+		return nil
+	}
+
+	r := slog.NewRecord(time.Time{}, 0, "", 0)
+
+	r.Add(args...)
+
+	res := make([]slog.Attr, 0, r.NumAttrs())
+
+	r.Attrs(func(a slog.Attr) bool {
+		a.Value = a.Value.Resolve()
+
+		res = append(res, a)
+
+		return true
+	})
+
+	return res
+}
+
+// attrValue returns the value of an attribute, with groups of attributes
+// returned as maps.
+func attrValue(v slog.Value) any {
+	if v.Kind() != slog.KindGroup {
+		return v.Any()
+	}
+
+	res := map[string]any{}
+
+	for _, a := range v.Group() {
+		res[a.Key] = attrValue(a.Value.Resolve())
+	}
+
+	return res
+}
+
 // A LogHandler wraps an slog.Handler for use with this logger interface.
 type LogHandler struct {
 	handler slog.Handler
@@ -72,12 +149,7 @@ func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
 // Handle implements Handler.Handle and adds the context data for this service.
 func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
 	if r.NumAttrs() > 0 {
-		tID, ok := ctx.Value(5).(string)
-		if !ok {
-			tID = "none"
-		}
-
-		r.Add("source", "api", "trace_id", tID)
+		r.Add("source", "api", "trace_id", traceID(ctx))
 	}
 
 	return h.handler.Handle(ctx, r)
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"log"
 	"log/slog"
+	"strings"
 	"testing"
 	"testing/slogtest"
 
@@ -49,3 +50,110 @@ func TestLogger(t *testing.T) {
 		log.Fatal(err)
 	}
 }
+
+func TestNewWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		backend string
+		msgKey  string
+	}{{
+		name:    "slog",
+		backend: logger.LogBackendSlog,
+		msgKey:  "msg",
+	}, {
+		name:    "zap",
+		backend: logger.LogBackendZap,
+		msgKey:  "msg",
+	}, {
+		name:    "zerolog",
+		backend: logger.LogBackendZerolog,
+		msgKey:  "message",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			l := logger.NewWriter(&buf, tt.backend, logger.LogFmtJSON,
+				logger.LvlInfo)
+
+			l.Log(mockContext(), logger.LvlDebug, "hidden")
+
+			l.Log(mockContext(), logger.LvlWarn, "test",
+				"count", 1,
+				slog.Group("group", "key", "value"))
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 1 {
+				t.Fatalf("Expected 1 entry, got: %v", lines)
+			}
+
+			m := map[string]any{}
+
+			if err := json.Unmarshal([]byte(lines[0]), &m); err != nil {
+				t.Fatal(err)
+			}
+
+			if m[tt.msgKey] != "test" {
+				t.Errorf("Expected message: test, got: %v", m[tt.msgKey])
+			}
+
+			if m["level"] != "warn" && m["level"] != "WARN" {
+				t.Errorf("Expected level: warn, got: %v", m["level"])
+			}
+
+			if m["count"] != float64(1) {
+				t.Errorf("Expected count: 1, got: %v", m["count"])
+			}
+
+			if g, ok := m["group"].(map[string]any); !ok ||
+				g["key"] != "value" {
+				t.Errorf("Expected group key: value, got: %v", m["group"])
+			}
+
+			exp := "11223344-5566-7788-9900-aabbccddeeff"
+
+			if m["trace_id"] != exp {
+				t.Errorf("Expected trace_id: %v, got: %v", exp, m["trace_id"])
+			}
+		})
+	}
+}
+
+func TestNewWriterLevelVar(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range []string{logger.LogBackendSlog,
+		logger.LogBackendZap, logger.LogBackendZerolog} {
+		t.Run(backend, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			lv := &slog.LevelVar{}
+
+			lv.Set(logger.LvlInfo)
+
+			l := logger.NewWriter(&buf, backend, logger.LogFmtJSON, lv)
+
+			l.Log(mockContext(), logger.LvlDebug, "hidden")
+
+			lv.Set(logger.LvlDebug)
+
+			l.Log(mockContext(), logger.LvlDebug, "shown")
+
+			lv.Set(logger.LvlError)
+
+			l.Log(mockContext(), logger.LvlWarn, "hidden")
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != 1 || !strings.Contains(lines[0], "shown") {
+				t.Errorf("Expected 1 shown entry, got: %v", lines)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// RotatingFile values implement an io.WriteCloser which writes to a file,
+// rotating it when it reaches a maximum size. Rotated files are renamed with
+// a numeric suffix, with .1 being the most recent, and only a limited number
+// of them are retained.
+type RotatingFile struct {
+	sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	f        *os.File
+	size     int64
+}
+
+// NewRotatingFile opens, or creates, a file for appending which is rotated
+// once it exceeds maxSize bytes. At most maxFiles rotated files are retained.
+// A maxSize of zero or less disables rotation.
+func NewRotatingFile(path string,
+	maxSize int64,
+	maxFiles int,
+) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+
+	return rf, nil
+}
+
+// open opens the current file for appending.
+func (rf *RotatingFile) open() error {
+	if dir := filepath.Dir(rf.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return err
+	}
+
+	rf.f = f
+	rf.size = fi.Size()
+
+	return nil
+}
+
+// rotate closes the current file, shifts the rotated files, and opens a new
+// current file.
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+
+	name := func(i int) string {
+		return fmt.Sprintf("%s.%d", rf.path, i)
+	}
+
+	if rf.maxFiles > 0 {
+		if err := os.Remove(name(rf.maxFiles)); err != nil &&
+			!os.IsNotExist(err) {
+			return err
+		}
+
+		for i := rf.maxFiles - 1; i > 0; i-- {
+			if err := os.Rename(name(i), name(i+1)); err != nil &&
+				!os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		if err := os.Rename(rf.path, name(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(rf.path); err != nil {
+		return err
+	}
+
+	return rf.open()
+}
+
+// Write writes to the file, rotating it first if the write would cause it to
+// exceed its maximum size.
+func (rf *RotatingFile) Write(b []byte) (int, error) {
+	rf.Lock()
+	defer rf.Unlock()
+
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(b)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(b)
+
+	rf.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the file.
+func (rf *RotatingFile) Close() error {
+	rf.Lock()
+	defer rf.Unlock()
+
+	if rf.f == nil {
+		return nil
+	}
+
+	err := rf.f.Close()
+
+	rf.f = nil
+
+	return err
+}
@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// NewSyslogWriter connects to a syslog server, at an address such as
+// udp://localhost:514, or to the local syslog server if the address is empty.
+// Entries are written with the informational priority and the tag.
+func NewSyslogWriter(address, tag string) (io.WriteCloser, error) {
+	network, addr, ok := strings.Cut(address, "://")
+	if !ok {
+		network, addr = "", address
+
+		if addr != "" {
+			network = "udp"
+		}
+	}
+
+	return syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+}
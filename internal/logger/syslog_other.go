@@ -0,0 +1,15 @@
+//go:build windows || plan9
+
+package logger
+
+import (
+	"io"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// NewSyslogWriter is not supported on this platform.
+func NewSyslogWriter(address, tag string) (io.WriteCloser, error) {
+	return nil, errors.New(errors.ErrLog,
+		"syslog is not supported on this platform")
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ZapLogger values implement the Logger interface using a zap logger.
+type ZapLogger struct {
+	l *zap.Logger
+}
+
+// NewZapLogger returns a new ZapLogger writing entries using l.
+func NewZapLogger(l *zap.Logger) *ZapLogger {
+	if l == nil {
+		l = zap.NewNop()
+	}
+
+	return &ZapLogger{l: l}
+}
+
+// newZap returns a new zap logger writing entries to w, of at least the
+// current level of level.
+func newZap(w io.Writer, format string, level slog.Leveler) *zap.Logger {
+	ec := zap.NewProductionEncoderConfig()
+
+	ec.TimeKey = "time"
+	ec.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	enc := zapcore.NewJSONEncoder(ec)
+
+	if format == LogFmtText {
+		enc = zapcore.NewConsoleEncoder(ec)
+	}
+
+	return zap.New(zapcore.NewCore(enc, zapcore.AddSync(w),
+		zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+			return l >= zapLevel(level.Level())
+		})))
+}
+
+// zapLevel returns the zap level for a log level.
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < LvlInfo:
+		return zapcore.DebugLevel
+	case level < LvlWarn:
+		return zapcore.InfoLevel
+	case level < LvlError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// Log writes a log entry, adding the context data for this service.
+func (z *ZapLogger) Log(ctx context.Context,
+	level slog.Level,
+	msg string,
+	args ...any,
+) {
+	ce := z.l.Check(zapLevel(level), msg)
+	if ce == nil {
+		return
+	}
+
+	as := attrs(args)
+
+	fields := make([]zap.Field, 0, len(as)+2)
+
+	for _, a := range as {
+		if err, ok := a.Value.Any().(error); ok {
+			fields = append(fields, zap.NamedError(a.Key, err))
+
+			continue
+		}
+
+		fields = append(fields, zap.Any(a.Key, attrValue(a.Value)))
+	}
+
+	if len(as) > 0 {
+		fields = append(fields, zap.String("source", "api"),
+			zap.String("trace_id", traceID(ctx)))
+	}
+
+	ce.Write(fields...)
+}
+
+// Logger returns the zap logger wrapped by z.
+func (z *ZapLogger) Logger() *zap.Logger {
+	return z.l
+}
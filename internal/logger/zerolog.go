@@ -0,0 +1,83 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// ZerologLogger values implement the Logger interface using a zerolog logger.
+type ZerologLogger struct {
+	l     zerolog.Logger
+	level slog.Leveler
+}
+
+// NewZerologLogger returns a new ZerologLogger writing entries using l.
+func NewZerologLogger(l zerolog.Logger) *ZerologLogger {
+	return &ZerologLogger{l: l}
+}
+
+// newZerolog returns a new zerolog logger writing entries to w.
+func newZerolog(w io.Writer, format string) zerolog.Logger {
+	if format == LogFmtText {
+		w = zerolog.ConsoleWriter{Out: w, NoColor: true}
+	}
+
+	return zerolog.New(w).With().Timestamp().Logger()
+}
+
+// zerologLevel returns the zerolog level for a log level.
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level < LvlInfo:
+		return zerolog.DebugLevel
+	case level < LvlWarn:
+		return zerolog.InfoLevel
+	case level < LvlError:
+		return zerolog.WarnLevel
+	default:
+		return zerolog.ErrorLevel
+	}
+}
+
+// Log writes a log entry, adding the context data for this service.
+func (z *ZerologLogger) Log(ctx context.Context,
+	level slog.Level,
+	msg string,
+	args ...any,
+) {
+	if z.level != nil &&
+		zerologLevel(level) < zerologLevel(z.level.Level()) {
+		return
+	}
+
+	e := z.l.WithLevel(zerologLevel(level))
+	if !e.Enabled() {
+		return
+	}
+
+	as := attrs(args)
+
+	for _, a := range as {
+		if err, ok := a.Value.Any().(error); ok {
+			e = e.AnErr(a.Key, err)
+
+			continue
+		}
+
+		e = e.Interface(a.Key, attrValue(a.Value))
+	}
+
+	if len(as) > 0 {
+		e = e.Str("source", "api").Str("trace_id", traceID(ctx))
+	}
+
+	e.Msg(msg)
+}
+
+// Logger returns the zerolog logger wrapped by z.
+func (z *ZerologLogger) Logger() zerolog.Logger {
+	return z.l
+}
@@ -0,0 +1,301 @@
+// Package notify provides templated email notifications, which are sent
+// through an SMTP server, or another transport.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/metric"
+	"github.com/google/uuid"
+)
+
+// Transport values deliver email messages. The message is a complete RFC 5322
+// message, including its headers.
+type Transport interface {
+	Send(ctx context.Context, from string, to []string, msg []byte) error
+}
+
+// Template values contain the templates for the subject and the plain text
+// body of an email notification. They are executed with the data of each
+// notification.
+type Template struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewTemplate parses the subject and body templates of an email notification.
+func NewTemplate(name, subject, body string) (*Template, error) {
+	st, err := template.New(name + "_subject").Parse(subject)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid subject template",
+			"name", name)
+	}
+
+	bt, err := template.New(name + "_body").Parse(body)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid body template",
+			"name", name)
+	}
+
+	return &Template{subject: st, body: bt}, nil
+}
+
+// MustTemplate parses the subject and body templates of an email notification,
+// and panics if they are invalid. It is intended for templates defined in
+// package variables.
+func MustTemplate(name, subject, body string) *Template {
+	t, err := NewTemplate(name, subject, body)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// Render executes the templates with the data of a notification, and returns
+// its subject and body. The subject is collapsed onto a single line.
+func (t *Template) Render(data any) (string, string, error) {
+	buf := &bytes.Buffer{}
+
+	if err := t.subject.Execute(buf, data); err != nil {
+		return "", "", errors.Wrap(err, errors.ErrServer,
+			"unable to render notification subject")
+	}
+
+	subject := strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+
+	if err := t.body.Execute(buf, data); err != nil {
+		return "", "", errors.Wrap(err, errors.ErrServer,
+			"unable to render notification body")
+	}
+
+	return subject, buf.String(), nil
+}
+
+// Sender values are used to send email notifications.
+type Sender struct {
+	cfg       *config.Config
+	transport Transport
+	enabled   bool
+	metric    metric.Recorder
+}
+
+// NewSender creates a new email notification sender. If the transport is nil,
+// messages are sent through the configured SMTP server, and the sender is only
+// enabled when an SMTP host is configured.
+func NewSender(cfg *config.Config,
+	transport Transport,
+	metric metric.Recorder,
+) *Sender {
+	if cfg == nil {
+		cfg = config.NewDefault()
+	}
+
+	if metric == nil || (reflect.ValueOf(metric).Kind() == reflect.Ptr &&
+		reflect.ValueOf(metric).IsNil()) {
+		metric = nil
+	}
+
+	s := &Sender{
+		cfg:       cfg,
+		transport: transport,
+		enabled:   transport != nil,
+		metric:    metric,
+	}
+
+	if transport == nil {
+		s.transport = NewSMTPTransport(cfg)
+	}
+
+	return s
+}
+
+// Enabled returns whether email notifications can be sent.
+func (s *Sender) Enabled() bool {
+	if s == nil {
+		return false
+	}
+
+	return s.enabled || s.cfg.NotifySMTPHost() != ""
+}
+
+// Send renders an email notification from a template, and sends it to the
+// recipients. Each recipient must be a valid email address, optionally with a
+// display name.
+func (s *Sender) Send(ctx context.Context,
+	to []string,
+	t *Template,
+	data any,
+) error {
+	if !s.Enabled() {
+		return errors.New(errors.ErrConfiguration,
+			"email notifications are not configured")
+	}
+
+	if len(to) == 0 {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing notification recipients")
+	}
+
+	from, err := mail.ParseAddress(s.cfg.NotifyFrom())
+	if err != nil {
+		return errors.Wrap(err, errors.ErrConfiguration,
+			"invalid notification sender address",
+			"from", s.cfg.NotifyFrom())
+	}
+
+	rcpts := make([]*mail.Address, 0, len(to))
+
+	for _, v := range to {
+		a, err := mail.ParseAddress(v)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid notification recipient",
+				"to", v)
+		}
+
+		rcpts = append(rcpts, a)
+	}
+
+	subject, body, err := t.Render(data)
+	if err != nil {
+		return err
+	}
+
+	msg, err := newMessage(from, rcpts, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	addrs := make([]string, len(rcpts))
+
+	for i, a := range rcpts {
+		addrs[i] = a.Address
+	}
+
+	if err := s.transport.Send(ctx, from.Address, addrs, msg); err != nil {
+		if s.metric != nil {
+			s.metric.Increment(ctx, "email_errors")
+		}
+
+		return err
+	}
+
+	if s.metric != nil {
+		s.metric.Increment(ctx, "emails_sent")
+	}
+
+	return nil
+}
+
+// newMessage builds a plain text email message. Header values are encoded, so
+// that rendered subjects can not add headers to the message.
+func newMessage(from *mail.Address,
+	to []*mail.Address,
+	subject, body string,
+	now time.Time,
+) ([]byte, error) {
+	tos := make([]string, len(to))
+
+	for i, a := range to {
+		tos[i] = a.String()
+	}
+
+	domain := "localhost"
+
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+
+	buf := &bytes.Buffer{}
+
+	for _, h := range [][2]string{
+		{"From", from.String()},
+		{"To", strings.Join(tos, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + uuid.NewString() + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=UTF-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	} {
+		buf.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+
+	buf.WriteString("\r\n")
+
+	w := quotedprintable.NewWriter(buf)
+
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to encode notification body")
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to encode notification body")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// MockMessage values contain an email message sent with a mock transport.
+type MockMessage struct {
+	From string
+	To   []string
+	Data []byte
+}
+
+// MockTransport values are used to test email notifications. Messages are
+// recorded, rather than delivered. If Err is set, it is returned for every
+// message, and the message is not recorded.
+type MockTransport struct {
+	sync.Mutex
+	Err      error
+	messages []*MockMessage
+}
+
+// Send records an email message.
+func (m *MockTransport) Send(ctx context.Context,
+	from string,
+	to []string,
+	msg []byte,
+) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+
+	m.messages = append(m.messages, &MockMessage{
+		From: from,
+		To:   append([]string{}, to...),
+		Data: append([]byte{}, msg...),
+	})
+
+	return nil
+}
+
+// Messages returns the email messages recorded by the transport.
+func (m *MockTransport) Messages() []*MockMessage {
+	m.Lock()
+	defer m.Unlock()
+
+	return append([]*MockMessage{}, m.messages...)
+}
@@ -0,0 +1,133 @@
+package notify_test
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/notify"
+)
+
+var testTemplate = notify.MustTemplate("test",
+	`[{{.State}}] {{.Name}}`,
+	`The rule {{.Name}} is {{.State}}.
+`)
+
+func TestSend(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.New("")
+
+	cfg.Load([]byte(`notify:
+  from: Alerts <alerts@example.com>
+`))
+
+	mt := &notify.MockTransport{}
+
+	s := notify.NewSender(cfg, mt, nil)
+
+	if !s.Enabled() {
+		t.Fatal("Expected sender to be enabled")
+	}
+
+	if err := s.Send(context.Background(), []string{
+		"Ops <ops@example.com>", "test@example.com",
+	}, testTemplate, map[string]any{
+		"Name":  "Résumé\r\nBcc: attacker@example.com",
+		"State": "firing",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := mt.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Expected messages: 1, got: %v", len(msgs))
+	}
+
+	if msgs[0].From != "alerts@example.com" {
+		t.Errorf("Expected from: alerts@example.com, got: %v", msgs[0].From)
+	}
+
+	if exp := []string{"ops@example.com", "test@example.com"}; strings.Join(
+		msgs[0].To, ",") != strings.Join(exp, ",") {
+		t.Errorf("Expected to: %v, got: %v", exp, msgs[0].To)
+	}
+
+	m, err := mail.ReadMessage(strings.NewReader(string(msgs[0].Data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := m.Header.Get("Bcc"); v != "" {
+		t.Errorf("Expected no Bcc header, got: %v", v)
+	}
+
+	dec := &mime.WordDecoder{}
+
+	subject, err := dec.DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "[firing] Résumé Bcc: attacker@example.com"; subject != exp {
+		t.Errorf("Expected subject: %v, got: %v", exp, subject)
+	}
+
+	if m.Header.Get("Message-ID") == "" {
+		t.Error("Expected Message-ID header")
+	}
+
+	b, err := io.ReadAll(m.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(b), "is firing.") {
+		t.Errorf("Expected body to contain: is firing., got: %s", b)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	if err := notify.NewSender(nil, nil, nil).Send(ctx,
+		[]string{"ops@example.com"}, testTemplate, nil); !errors.Has(err,
+		errors.ErrConfiguration) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrConfiguration, err)
+	}
+
+	mt := &notify.MockTransport{}
+
+	s := notify.NewSender(nil, mt, nil)
+
+	if err := s.Send(ctx, nil, testTemplate, nil); !errors.Has(err,
+		errors.ErrInvalidRequest) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrInvalidRequest, err)
+	}
+
+	if err := s.Send(ctx, []string{"ops"}, testTemplate, nil); !errors.Has(err,
+		errors.ErrInvalidRequest) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrInvalidRequest, err)
+	}
+
+	mt.Err = errors.New(errors.ErrClient, "test")
+
+	if err := s.Send(ctx, []string{"ops@example.com"}, testTemplate,
+		nil); !errors.Has(err, errors.ErrClient) {
+		t.Errorf("Expected error code: %v, got: %v", errors.ErrClient, err)
+	}
+
+	if _, err := notify.NewTemplate("invalid", "{{", ""); err == nil {
+		t.Error("Expected template error")
+	}
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"strconv"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// SMTPTransport values deliver email messages through the configured SMTP
+// server. The configuration is read for each message, so changes to it, such
+// as a rotated password, apply to the next message sent.
+type SMTPTransport struct {
+	cfg *config.Config
+}
+
+// NewSMTPTransport creates a new SMTP email transport.
+func NewSMTPTransport(cfg *config.Config) *SMTPTransport {
+	if cfg == nil {
+		cfg = config.NewDefault()
+	}
+
+	return &SMTPTransport{cfg: cfg}
+}
+
+// Send delivers an email message through the SMTP server. Unless TLS is
+// disabled in the configuration, the connection is upgraded with STARTTLS
+// before authenticating, and servers which do not support it are rejected.
+func (t *SMTPTransport) Send(ctx context.Context,
+	from string,
+	to []string,
+	msg []byte,
+) error {
+	host := t.cfg.NotifySMTPHost()
+	if host == "" {
+		return errors.New(errors.ErrConfiguration,
+			"missing SMTP host")
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(t.cfg.NotifySMTPPort()))
+
+	ctx, cancel := context.WithTimeout(ctx, t.cfg.NotifyTimeout())
+
+	defer cancel()
+
+	d := &net.Dialer{}
+
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to connect to SMTP server",
+			"address", addr)
+	}
+
+	defer conn.Close()
+
+	if dl, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(dl); err != nil {
+			return errors.Wrap(err, errors.ErrClient,
+				"unable to set SMTP connection deadline",
+				"address", addr)
+		}
+	}
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to start SMTP session",
+			"address", addr)
+	}
+
+	defer c.Close()
+
+	if t.cfg.NotifySMTPTLS() {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New(errors.ErrClient,
+				"SMTP server does not support STARTTLS",
+				"address", addr)
+		}
+
+		if err := c.StartTLS(&tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}); err != nil {
+			return errors.Wrap(err, errors.ErrClient,
+				"unable to start SMTP TLS session",
+				"address", addr)
+		}
+	}
+
+	if u := t.cfg.NotifySMTPUsername(); u != "" {
+		if err := c.Auth(smtp.PlainAuth("", u, t.cfg.NotifySMTPPassword(),
+			host)); err != nil {
+			return errors.Wrap(err, errors.ErrClient,
+				"unable to authenticate with SMTP server",
+				"address", addr)
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"SMTP sender rejected",
+			"from", from)
+	}
+
+	for _, r := range to {
+		if err := c.Rcpt(r); err != nil {
+			return errors.Wrap(err, errors.ErrClient,
+				"SMTP recipient rejected",
+				"to", r)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to send SMTP message")
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to send SMTP message")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"SMTP message rejected")
+	}
+
+	if err := c.Quit(); err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to end SMTP session")
+	}
+
+	return nil
+}
@@ -0,0 +1,172 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/notify"
+)
+
+// mockSMTPServer accepts a single SMTP session, without STARTTLS support, and
+// returns the commands and message data it received.
+func mockSMTPServer(t *testing.T) (string, <-chan []string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { l.Close() })
+
+	ch := make(chan []string, 1)
+
+	go func() {
+		received := []string{}
+
+		defer func() { ch <- received }()
+
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+
+		reply := func(s string) {
+			_, _ = conn.Write([]byte(s + "\r\n"))
+		}
+
+		reply("220 localhost ready")
+
+		data := false
+
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			line = strings.TrimRight(line, "\r\n")
+
+			received = append(received, line)
+
+			if data {
+				if line == "." {
+					data = false
+
+					reply("250 OK")
+				}
+
+				continue
+			}
+
+			switch cmd := strings.ToUpper(strings.Fields(line + " ")[0]); cmd {
+			case "EHLO", "HELO":
+				reply("250 localhost")
+			case "DATA":
+				data = true
+
+				reply("354 Go ahead")
+			case "QUIT":
+				reply("221 Bye")
+
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return l.Addr().String(), ch
+}
+
+func TestSMTPTransport(t *testing.T) {
+	t.Parallel()
+
+	addr, ch := mockSMTPServer(t)
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsOff := false
+
+	cfg := config.New("")
+
+	cfg.SetNotify(&config.NotifyConfig{
+		SMTPHost: host,
+		SMTPPort: p,
+		SMTPTLS:  &tlsOff,
+	})
+
+	s := notify.NewSender(cfg, nil, nil)
+
+	if !s.Enabled() {
+		t.Fatal("Expected sender to be enabled")
+	}
+
+	if err := s.Send(context.Background(), []string{"ops@example.com"},
+		testTemplate, map[string]any{
+			"Name":  "test",
+			"State": "resolved",
+		}); err != nil {
+		t.Fatal(err)
+	}
+
+	received := strings.Join(<-ch, "\n")
+
+	for _, exp := range []string{
+		"MAIL FROM:<" + config.DefaultNotifyFrom + ">",
+		"RCPT TO:<ops@example.com>",
+		"Subject: [resolved] test",
+		"The rule test is resolved.",
+	} {
+		if !strings.Contains(received, exp) {
+			t.Errorf("Expected session to contain: %v, got: %v",
+				exp, received)
+		}
+	}
+}
+
+func TestSMTPTransportRequiresTLS(t *testing.T) {
+	t.Parallel()
+
+	addr, _ := mockSMTPServer(t)
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.New("")
+
+	cfg.SetNotify(&config.NotifyConfig{SMTPHost: host, SMTPPort: p})
+
+	tr := notify.NewSMTPTransport(cfg)
+
+	if err := tr.Send(context.Background(), config.DefaultNotifyFrom,
+		[]string{"ops@example.com"}, []byte("test")); !errors.Has(err,
+		errors.ErrClient) {
+		t.Errorf("Expected error code: %v, got: %v", errors.ErrClient, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/metric"
 	"github.com/ktrysmt/go-bitbucket"
 	"go.opentelemetry.io/otel/trace"
@@ -23,11 +24,14 @@ type bitBucketClient struct {
 // newBitBucketClient creates a new BitBucket repository client.
 func newBitBucketClient(username, password string,
 	cfg *Config,
+	client *httpclient.Client,
 	metric metric.Recorder,
 	tracer trace.Tracer,
 ) (*bitBucketClient, error) {
 	cli := bitbucket.NewBasicAuth(username, password)
 
+	cli.HttpClient = client.HTTPClient()
+
 	return &bitBucketClient{
 		cfg:    cfg,
 		cli:    cli,
@@ -256,3 +260,11 @@ func (c *bitBucketClient) Commit(ctx context.Context) (string, error) {
 
 	return commit, nil
 }
+
+// Ping checks that the repository is reachable, by retrieving its main branch
+// reference.
+func (c *bitBucketClient) Ping(ctx context.Context) error {
+	_, err := c.Commit(ctx)
+
+	return err
+}
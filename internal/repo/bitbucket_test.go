@@ -31,7 +31,7 @@ func TestBitBucketClient(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := repo.NewClient(tt.repoURL, nil, nil)
+			_, err := repo.NewClient(tt.repoURL, nil, nil, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
 
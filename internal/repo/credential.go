@@ -0,0 +1,284 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+// appTokenMargin is how long before it expires a GitHub App installation token
+// is replaced.
+const appTokenMargin = time.Minute * 5
+
+// credentialKey values identify a named credential of a configuration.
+type credentialKey struct {
+	cfg  *config.Config
+	name string
+}
+
+var credentials = make(map[credentialKey]*credential)
+
+var credentialLock = sync.Mutex{}
+
+// credential values authenticate repository requests using a named credential
+// from the configuration. The credential is read from the configuration for
+// each request, so that values refreshed from a secret provider are used.
+// GitHub App installation tokens are cached until shortly before they expire.
+type credential struct {
+	sync.Mutex
+	name    string
+	client  *httpclient.Client
+	key     string
+	token   string
+	expires time.Time
+}
+
+// getCredential returns the repository credential with the specified name,
+// from the configuration of the HTTP client.
+func getCredential(name string,
+	client *httpclient.Client,
+) (*credential, error) {
+	cfg := client.Config()
+
+	if cfg.RepoCredential(name) == nil {
+		return nil, errors.New(errors.ErrClient,
+			"invalid repository URL: unknown credential",
+			"credential", name)
+	}
+
+	k := credentialKey{cfg: cfg, name: name}
+
+	credentialLock.Lock()
+	defer credentialLock.Unlock()
+
+	if c, ok := credentials[k]; ok {
+		return c, nil
+	}
+
+	c := &credential{name: name, client: client}
+
+	credentials[k] = c
+
+	return c, nil
+}
+
+// config returns the current configuration values of the credential.
+func (c *credential) config() (*config.RepoCredential, error) {
+	rc := c.client.Config().RepoCredential(c.name)
+	if rc == nil {
+		return nil, errors.New(errors.ErrClient,
+			"repository credential not found",
+			"credential", c.name)
+	}
+
+	return rc, nil
+}
+
+// gitAuth returns the method used to authenticate git requests.
+func (c *credential) gitAuth(ctx context.Context,
+) (transport.AuthMethod, error) {
+	rc, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+
+	if rc.Type == config.RepoCredentialSSH {
+		user := rc.Username
+		if user == "" {
+			user = "git"
+		}
+
+		pk, err := gitssh.NewPublicKeys(user, []byte(rc.PrivateKey),
+			rc.Passphrase)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrClient,
+				"invalid repository credential private key",
+				"credential", c.name)
+		}
+
+		if rc.KnownHostsFile != "" {
+			cb, err := gitssh.NewKnownHostsCallback(rc.KnownHostsFile)
+			if err != nil {
+				return nil, errors.Wrap(err, errors.ErrClient,
+					"invalid repository credential known hosts file",
+					"credential", c.name,
+					"known_hosts_file", rc.KnownHostsFile)
+			}
+
+			pk.HostKeyCallback = cb
+		}
+
+		return pk, nil
+	}
+
+	tok, err := c.accessToken(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	user := rc.Username
+	if user == "" {
+		user = "oauth2"
+
+		if rc.Type == config.RepoCredentialGitHubApp {
+			user = "x-access-token"
+		}
+	}
+
+	return &githttp.BasicAuth{Username: user, Password: tok}, nil
+}
+
+// Token returns an access token for API requests, implementing the
+// oauth2.TokenSource interface.
+func (c *credential) Token() (*oauth2.Token, error) {
+	rc, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+
+	if rc.Type == config.RepoCredentialSSH {
+		return nil, errors.New(errors.ErrClient,
+			"SSH repository credentials cannot be used for API requests",
+			"credential", c.name)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		c.client.Config().ClientTimeout())
+	defer cancel()
+
+	tok, err := c.accessToken(ctx, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Token{AccessToken: tok}, nil
+}
+
+// accessToken returns the OAuth token of the credential, or a GitHub App
+// installation token, which is created again when it is about to expire, or
+// the app configuration has changed.
+func (c *credential) accessToken(ctx context.Context,
+	rc *config.RepoCredential,
+) (string, error) {
+	if rc.Type != config.RepoCredentialGitHubApp {
+		if rc.Token == "" {
+			return "", errors.New(errors.ErrClient,
+				"repository credential token not set",
+				"credential", c.name)
+		}
+
+		return rc.Token, nil
+	}
+
+	key := strings.Join([]string{
+		strconv.FormatInt(rc.AppID, 10),
+		strconv.FormatInt(rc.InstallationID, 10),
+		rc.APIURL,
+		rc.PrivateKey,
+	}, "\n")
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.key == key && time.Now().Before(c.expires.Add(-appTokenMargin)) {
+		return c.token, nil
+	}
+
+	tok, exp, err := c.installationToken(ctx, rc)
+	if err != nil {
+		return "", err
+	}
+
+	c.key, c.token, c.expires = key, tok, exp
+
+	return tok, nil
+}
+
+// installationToken creates a new GitHub App installation token, using a JWT
+// signed with the app private key.
+func (c *credential) installationToken(ctx context.Context,
+	rc *config.RepoCredential,
+) (string, time.Time, error) {
+	pk, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(rc.PrivateKey))
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, errors.ErrClient,
+			"invalid repository credential private key",
+			"credential", c.name)
+	}
+
+	now := time.Now()
+
+	// The issued at time is set in the past to allow for clock drift.
+	ss, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(time.Minute * 9).Unix(),
+		"iss": strconv.FormatInt(rc.AppID, 10),
+	}).SignedString(pk)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, errors.ErrClient,
+			"unable to sign GitHub App token",
+			"credential", c.name)
+	}
+
+	u := strings.TrimSuffix(rc.APIURL, "/") + "/app/installations/" +
+		strconv.FormatInt(rc.InstallationID, 10) + "/access_tokens"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, nil)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, errors.ErrClient,
+			"unable to create GitHub App token request",
+			"credential", c.name)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+ss)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, errors.ErrClient,
+			"unable to read GitHub App token response",
+			"credential", c.name)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", time.Time{}, errors.New(errors.ErrClient,
+			"unable to create GitHub App installation token",
+			"credential", c.name,
+			"status", resp.StatusCode,
+			"response", string(b))
+	}
+
+	res := struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{}
+
+	if err := json.Unmarshal(b, &res); err != nil || res.Token == "" {
+		return "", time.Time{}, errors.New(errors.ErrClient,
+			"invalid GitHub App token response",
+			"credential", c.name)
+	}
+
+	return res.Token, res.ExpiresAt, nil
+}
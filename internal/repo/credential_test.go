@@ -0,0 +1,170 @@
+package repo_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+	"github.com/dhaifley/apigo/internal/repo"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestGitClientCredential(t *testing.T) {
+	t.Parallel()
+
+	pkt := func(s string) string {
+		return fmt.Sprintf("%04x%s", len(s)+4, s)
+	}
+
+	hash := strings.Repeat("a", 40)
+
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu     sync.Mutex
+		users  []string
+		tokens int
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if r.URL.Path == "/app/installations/2/access_tokens" {
+				_, err := jwt.Parse(strings.TrimPrefix(
+					r.Header.Get("Authorization"), "Bearer "),
+					func(*jwt.Token) (any, error) {
+						return &pk.PublicKey, nil
+					}, jwt.WithIssuer("1"))
+				if err != nil {
+					w.WriteHeader(http.StatusUnauthorized)
+
+					return
+				}
+
+				tokens++
+
+				w.WriteHeader(http.StatusCreated)
+
+				_, _ = fmt.Fprintf(w, `{"token":"app","expires_at":%q}`,
+					time.Now().Add(time.Hour).Format(time.RFC3339))
+
+				return
+			}
+
+			u, p, ok := r.BasicAuth()
+			if !ok {
+				w.WriteHeader(http.StatusUnauthorized)
+
+				return
+			}
+
+			users = append(users, u+":"+p)
+
+			w.Header().Set("Content-Type",
+				"application/x-git-upload-pack-advertisement")
+
+			_, _ = w.Write([]byte(pkt("# service=git-upload-pack\n") +
+				"0000" +
+				pkt(hash+" HEAD\x00symref=HEAD:refs/heads/main\n") +
+				pkt(hash+" refs/heads/main\n") +
+				"0000"))
+		}))
+
+	defer ts.Close()
+
+	cfg := config.NewDefault()
+
+	cfg.SetRepo(&config.RepoConfig{
+		Credentials: map[string]*config.RepoCredential{
+			"ci": {
+				Type:  config.RepoCredentialOAuth,
+				Token: "test",
+			},
+			"app": {
+				Type:           config.RepoCredentialGitHubApp,
+				AppID:          1,
+				InstallationID: 2,
+				APIURL:         ts.URL,
+				PrivateKey: string(pem.EncodeToMemory(&pem.Block{
+					Type:  "RSA PRIVATE KEY",
+					Bytes: x509.MarshalPKCS1PrivateKey(pk),
+				})),
+			},
+		},
+	})
+
+	client := httpclient.NewClient(cfg, nil, nil, nil)
+
+	ctx := context.Background()
+
+	cli, err := repo.NewClient(ts.URL+"/test.git?credential=ci", client,
+		nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.Ping(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := cfg.SetSecretValue(config.KeyRepoCredentials+"/ci/token",
+		[]byte("refreshed")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.Ping(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	cli, err = repo.NewClient(ts.URL+"/app.git?credential=app", client,
+		nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := cli.Ping(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	exp := []string{
+		"oauth2:test",
+		"oauth2:refreshed",
+		"x-access-token:app",
+		"x-access-token:app",
+	}
+
+	if strings.Join(users, ",") != strings.Join(exp, ",") {
+		t.Errorf("Expected credentials: %v, got: %v", exp, users)
+	}
+
+	if tokens != 1 {
+		t.Errorf("Expected 1 installation token request, got: %v", tokens)
+	}
+
+	if _, err := repo.NewClient(ts.URL+"/test.git?credential=missing",
+		client, nil, nil); !errors.Has(err, errors.ErrClient) {
+		t.Errorf("Expected client error, got: %v", err)
+	}
+}
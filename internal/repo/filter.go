@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// Filter values select the repository files to import. A file is selected
+// when its path, relative to the imported directory, matches the path glob,
+// its name field starts with the name prefix, and its metadata labels contain
+// every selected label. Empty criteria select every file.
+type Filter struct {
+	Path       string            `json:"path,omitempty"        yaml:"path,omitempty"`
+	NamePrefix string            `json:"name_prefix,omitempty" yaml:"name_prefix,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"      yaml:"labels,omitempty"`
+}
+
+// NewFilter creates a new filter from a JSON object, such as a stored account
+// repository filter. A nil or empty object returns a nil filter.
+func NewFilter(m map[string]any) (*Filter, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid repository filter",
+			"filter", m)
+	}
+
+	f := &Filter{}
+
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid repository filter",
+			"filter", m)
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// ParseLabels parses a label selector, formatted as a comma separated list of
+// key=value pairs.
+func ParseLabels(selector string) (map[string]string, error) {
+	res := map[string]string{}
+
+	for _, l := range strings.Split(selector, ",") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+
+		k, v, ok := strings.Cut(l, "=")
+
+		k = strings.TrimSpace(k)
+
+		if !ok || k == "" {
+			return nil, errors.New(errors.ErrInvalidRequest,
+				"invalid label selector",
+				"labels", selector)
+		}
+
+		res[k] = strings.TrimSpace(v)
+	}
+
+	return res, nil
+}
+
+// Validate checks that the filter contains a valid path glob.
+func (f *Filter) Validate() error {
+	if f.Path == "" {
+		return nil
+	}
+
+	if _, err := path.Match(f.Path, ""); err != nil {
+		return errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid repository filter path",
+			"path", f.Path)
+	}
+
+	return nil
+}
+
+// Empty returns whether the filter selects every file.
+func (f *Filter) Empty() bool {
+	return f == nil ||
+		(f.Path == "" && f.NamePrefix == "" && len(f.Labels) == 0)
+}
+
+// HasSelector returns whether the filter selects files using their contents,
+// rather than only their path.
+func (f *Filter) HasSelector() bool {
+	return f != nil && (f.NamePrefix != "" || len(f.Labels) > 0)
+}
+
+// MatchPath returns whether a file path, relative to the imported directory,
+// matches the filter path glob.
+func (f *Filter) MatchPath(filePath string) bool {
+	if f == nil || f.Path == "" {
+		return true
+	}
+
+	ok, err := path.Match(f.Path, strings.TrimPrefix(filePath, "/"))
+
+	return err == nil && ok
+}
+
+// Match returns whether the decoded contents of a file match the filter name
+// prefix and label selector. Labels are read from the labels object of the
+// file metadata.
+func (f *Filter) Match(contents map[string]any) bool {
+	if !f.HasSelector() {
+		return true
+	}
+
+	if f.NamePrefix != "" {
+		name, _ := contents["name"].(string)
+
+		if !strings.HasPrefix(name, f.NamePrefix) {
+			return false
+		}
+	}
+
+	if len(f.Labels) == 0 {
+		return true
+	}
+
+	md, _ := contents["metadata"].(map[string]any)
+
+	labels, _ := md["labels"].(map[string]any)
+
+	for k, v := range f.Labels {
+		lv, ok := labels[k]
+		if !ok || lv == nil || fmt.Sprint(lv) != v {
+			return false
+		}
+	}
+
+	return true
+}
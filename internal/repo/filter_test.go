@@ -0,0 +1,91 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/repo"
+)
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+
+	labels, err := repo.ParseLabels("env=prod, team = a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := repo.NewFilter(map[string]any{
+		"path":        "prod/*.yaml",
+		"name_prefix": "prod-",
+		"labels":      labels,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !f.MatchPath("/prod/a.yaml") || f.MatchPath("dev/a.yaml") {
+		t.Errorf("Unexpected path match for filter: %+v", f)
+	}
+
+	tests := []struct {
+		name     string
+		contents map[string]any
+		exp      bool
+	}{{
+		name: "match",
+		contents: map[string]any{
+			"name": "prod-a",
+			"metadata": map[string]any{
+				"labels": map[string]any{"env": "prod", "team": "a"},
+			},
+		},
+		exp: true,
+	}, {
+		name: "name",
+		contents: map[string]any{
+			"name": "dev-a",
+			"metadata": map[string]any{
+				"labels": map[string]any{"env": "prod", "team": "a"},
+			},
+		},
+	}, {
+		name: "label",
+		contents: map[string]any{
+			"name": "prod-a",
+			"metadata": map[string]any{
+				"labels": map[string]any{"env": "prod"},
+			},
+		},
+	}, {
+		name:     "no metadata",
+		contents: map[string]any{"name": "prod-a"},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if res := f.Match(tt.contents); res != tt.exp {
+				t.Errorf("Expected match: %v, got: %v", tt.exp, res)
+			}
+		})
+	}
+
+	var nf *repo.Filter
+
+	if !nf.Empty() || !nf.MatchPath("a.yaml") || !nf.Match(nil) {
+		t.Error("Expected nil filter to match everything")
+	}
+
+	if _, err := repo.NewFilter(map[string]any{
+		"path": "[",
+	}); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if _, err := repo.ParseLabels("env"); !errors.Has(err,
+		errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+}
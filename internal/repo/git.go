@@ -3,18 +3,23 @@ package repo
 import (
 	"context"
 	"io"
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/metric"
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/memory"
@@ -27,44 +32,209 @@ var gitLock = sync.RWMutex{}
 
 // gitClient values are used for interacting with git repositories.
 type gitClient struct {
+	sync.Mutex
 	cfg                *Config
 	username, password string
+	cred               *credential
 	s                  storage.Storer
 	fs                 billy.Filesystem
 	r                  *git.Repository
+	client             *httpclient.Client
+	stats              *FetchStats
+	objects            int
+	bytes              int64
 	metric             metric.Recorder
 	tracer             trace.Tracer
 }
 
-// newGitClient creates a new git repository client.
+// newGitClient creates a new git repository client. Requests are authenticated
+// using the credential, if one is provided, or else the username and password.
 func newGitClient(username, password string,
+	cred *credential,
 	cfg *Config,
+	client *httpclient.Client,
 	metric metric.Recorder,
 	tracer trace.Tracer,
 ) (*gitClient, error) {
 	return &gitClient{
 		username: username,
 		password: password,
+		cred:     cred,
 		cfg:      cfg,
 		s:        memory.NewStorage(),
 		fs:       memfs.New(),
+		client:   client,
 		metric:   metric,
 		tracer:   tracer,
 	}, nil
 }
 
-// clone creates or updates the repository.
+// transportOptions returns the proxy and additional certificate authorities
+// used to connect to the repository, as configured for the HTTP client.
+func (c *gitClient) transportOptions() (transport.ProxyOptions, []byte, error) {
+	po := transport.ProxyOptions{}
+
+	u, err := url.Parse(c.cfg.URL)
+	if err != nil {
+		return po, nil, errors.Wrap(err, errors.ErrClient,
+			"invalid repository URL",
+			"url", c.cfg.URL)
+	}
+
+	if u.Scheme == "http" || u.Scheme == "https" {
+		pu, err := c.client.Proxy(u)
+		if err != nil {
+			return po, nil, err
+		}
+
+		if pu != nil {
+			po.Username = pu.User.Username()
+			po.Password, _ = pu.User.Password()
+
+			pu.User = nil
+
+			po.URL = pu.String()
+		}
+	}
+
+	ca, err := c.client.CABundle()
+	if err != nil {
+		return po, nil, err
+	}
+
+	return po, ca, nil
+}
+
+// auth returns the method used to authenticate requests to the repository, or
+// nil, if requests are not authenticated.
+func (c *gitClient) auth(ctx context.Context) (transport.AuthMethod, error) {
+	if c.cred != nil {
+		return c.cred.gitAuth(ctx)
+	}
+
+	if c.username != "" || c.password != "" {
+		return &http.BasicAuth{
+			Username: c.username,
+			Password: c.password,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// FetchStats values contain statistics for the most recent clone of a
+// repository, or fetch which retrieved new commits. The objects and bytes are
+// the number of objects received, and their uncompressed size.
+type FetchStats struct {
+	Clone      bool  `json:"clone"`
+	Shallow    bool  `json:"shallow"`
+	Sparse     bool  `json:"sparse"`
+	Objects    int   `json:"objects"`
+	Bytes      int64 `json:"bytes"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// FetchStats returns the statistics for the most recent clone of the
+// repository, or fetch which retrieved new commits, or nil, if it has not been
+// retrieved.
+func (c *gitClient) FetchStats() *FetchStats {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.stats == nil {
+		return nil
+	}
+
+	res := *c.stats
+
+	return &res
+}
+
+// objectStats returns the number of objects stored for the repository, and
+// their total size. Every object is read, so it is only used once objects have
+// been received.
+func (c *gitClient) objectStats() (int, int64) {
+	ms, ok := c.s.(*memory.Storage)
+	if !ok {
+		return 0, 0
+	}
+
+	n, size := 0, int64(0)
+
+	for _, o := range ms.ObjectStorage.Objects {
+		n++
+
+		size += o.Size()
+	}
+
+	return n, size
+}
+
+// sparseDirs returns the configured sparse checkout directories, relative to
+// the root of the repository.
+func (c *gitClient) sparseDirs() []string {
+	dirs := c.client.Config().RepoSparseDirs()
+
+	res := make([]string, 0, len(dirs))
+
+	for _, d := range dirs {
+		d = strings.Trim(path.Join(c.cfg.Path, d), "/")
+		if d == "" || d == "." {
+			// The whole repository path is checked out.
+			return nil
+		}
+
+		res = append(res, d+"/")
+	}
+
+	return res
+}
+
+// clone creates or updates the repository. If shallow retrieval is configured,
+// only the most recent commit of a single branch is retrieved, and if sparse
+// directories are configured, only they are checked out.
 func (c *gitClient) clone(ctx context.Context) (*git.Repository, error) {
+	po, ca, err := c.transportOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	shallow, dirs := c.client.Config().RepoShallow(), c.sparseDirs()
+
+	start := time.Now()
+
+	record := func() {
+		n, size := c.objectStats()
+
+		c.stats = &FetchStats{
+			Clone:      c.r == nil,
+			Shallow:    shallow,
+			Sparse:     len(dirs) > 0,
+			Objects:    n - c.objects,
+			Bytes:      size - c.bytes,
+			DurationMS: time.Since(start).Milliseconds(),
+		}
+
+		c.objects, c.bytes = n, size
+	}
+
 	if c.r == nil {
 		opt := &git.CloneOptions{
-			URL: c.cfg.URL,
+			URL:          c.cfg.URL,
+			CABundle:     ca,
+			ProxyOptions: po,
+			NoCheckout:   len(dirs) > 0,
 		}
 
-		if c.username != "" || c.password != "" {
-			opt.Auth = &http.BasicAuth{
-				Username: c.username,
-				Password: c.password,
-			}
+		if shallow {
+			opt.Depth, opt.SingleBranch = 1, true
+		}
+
+		if opt.Auth, err = c.auth(ctx); err != nil {
+			return nil, err
 		}
 
 		if c.cfg.Ref != "" {
@@ -78,31 +248,128 @@ func (c *gitClient) clone(ctx context.Context) (*git.Repository, error) {
 				"url", c.cfg.URL)
 		}
 
+		if len(dirs) > 0 {
+			h, err := r.Head()
+			if err != nil {
+				return nil, errors.Wrap(err, errors.ErrClient,
+					"unable to get repository commit hash",
+					"url", c.cfg.URL)
+			}
+
+			if err := c.checkout(r, h.Hash(), dirs); err != nil {
+				return nil, err
+			}
+		}
+
+		record()
+
 		c.r = r
 
 		return r, nil
 	}
 
-	opt := &git.FetchOptions{}
+	h, err := c.r.Head()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to get repository commit hash",
+			"url", c.cfg.URL)
+	}
+
+	opt := &git.FetchOptions{
+		CABundle:     ca,
+		ProxyOptions: po,
+	}
 
-	if c.username != "" || c.password != "" {
-		opt.Auth = &http.BasicAuth{
-			Username: c.username,
-			Password: c.password,
+	// Only the checked out branch is fetched. A detached head, such as a
+	// checked out tag, is not moved by fetches.
+	branch := h.Name().IsBranch()
+
+	rn := plumbing.NewRemoteReferenceName(git.DefaultRemoteName,
+		h.Name().Short())
+
+	if branch {
+		opt.RefSpecs = []config.RefSpec{
+			config.RefSpec("+" + h.Name().String() + ":" + rn.String()),
 		}
 	}
 
+	if shallow {
+		opt.Depth = 1
+	}
+
+	if opt.Auth, err = c.auth(ctx); err != nil {
+		return nil, err
+	}
+
 	if err := c.r.FetchContext(ctx, opt); err != nil {
 		if !errors.Is(err, git.NoErrAlreadyUpToDate) {
 			return nil, errors.Wrap(err, errors.ErrClient,
 				"unable to fetch repository",
 				"url", c.cfg.URL)
 		}
+
+		return c.r, nil
 	}
 
+	if !branch {
+		record()
+
+		return c.r, nil
+	}
+
+	rr, err := c.r.Reference(rn, true)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to get repository remote branch",
+			"url", c.cfg.URL,
+			"branch", h.Name().Short())
+	}
+
+	if rr.Hash() == h.Hash() {
+		return c.r, nil
+	}
+
+	if err := c.checkout(c.r, rr.Hash(), dirs); err != nil {
+		return nil, err
+	}
+
+	record()
+
 	return c.r, nil
 }
 
+// checkout updates the current branch, and the files checked out, to the
+// commit. Only the files in the directories are checked out, if any are
+// provided.
+func (c *gitClient) checkout(r *git.Repository,
+	commit plumbing.Hash,
+	dirs []string,
+) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to get repository worktree",
+			"url", c.cfg.URL)
+	}
+
+	opt := &git.ResetOptions{Commit: commit, Mode: git.HardReset}
+
+	if len(dirs) > 0 {
+		err = w.ResetSparsely(opt, dirs)
+	} else {
+		err = w.Reset(opt)
+	}
+
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to check out repository",
+			"url", c.cfg.URL,
+			"commit", commit.String())
+	}
+
+	return nil
+}
+
 // List retrieves a directory listing from the repository.
 func (c *gitClient) List(ctx context.Context,
 	dirPath string,
@@ -380,3 +647,47 @@ func (c *gitClient) Commit(ctx context.Context) (string, error) {
 
 	return h.Hash().String(), nil
 }
+
+// Ping checks that the repository is reachable, by listing its remote
+// references, without cloning or fetching its contents.
+func (c *gitClient) Ping(ctx context.Context) error {
+	ctx, finish := startRepoSpan(ctx, c.metric, c.tracer, "git",
+		c.cfg, "main", "ping")
+
+	po, ca, err := c.transportOptions()
+	if err != nil {
+		finish(err)
+
+		return err
+	}
+
+	opt := &git.ListOptions{
+		CABundle:     ca,
+		ProxyOptions: po,
+	}
+
+	if opt.Auth, err = c.auth(ctx); err != nil {
+		finish(err)
+
+		return err
+	}
+
+	rem := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{c.cfg.URL},
+	})
+
+	if _, err := rem.ListContext(ctx, opt); err != nil {
+		err = errors.Wrap(err, errors.ErrClient,
+			"unable to list repository references",
+			"url", c.cfg.URL)
+
+		finish(err)
+
+		return err
+	}
+
+	finish(nil)
+
+	return nil
+}
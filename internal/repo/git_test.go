@@ -1,8 +1,21 @@
 package repo_test
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/repo"
 )
 
@@ -31,7 +44,7 @@ func TestGitClient(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := repo.NewClient(tt.repoURL, nil, nil)
+			_, err := repo.NewClient(tt.repoURL, nil, nil, nil)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
 
@@ -40,3 +53,220 @@ func TestGitClient(t *testing.T) {
 		})
 	}
 }
+
+func TestGitClientPing(t *testing.T) {
+	t.Parallel()
+
+	pkt := func(s string) string {
+		return fmt.Sprintf("%04x%s", len(s)+4, s)
+	}
+
+	hash := strings.Repeat("a", 40)
+
+	paths := []string{}
+
+	var mu sync.Mutex
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+
+			paths = append(paths, r.URL.Path)
+
+			mu.Unlock()
+
+			if r.URL.Path != "/test.git/info/refs" {
+				w.WriteHeader(http.StatusNotFound)
+
+				return
+			}
+
+			w.Header().Set("Content-Type",
+				"application/x-git-upload-pack-advertisement")
+
+			_, _ = w.Write([]byte(pkt("# service=git-upload-pack\n") +
+				"0000" +
+				pkt(hash+" HEAD\x00symref=HEAD:refs/heads/main\n") +
+				pkt(hash+" refs/heads/main\n") +
+				"0000"))
+		}))
+
+	defer ts.Close()
+
+	cli, err := repo.NewClient(ts.URL+"/test.git", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.Ping(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	mu.Lock()
+
+	if len(paths) != 1 || paths[0] != "/test.git/info/refs" {
+		t.Errorf("Expected only reference listing request, got: %v", paths)
+	}
+
+	mu.Unlock()
+
+	cli, err = repo.NewClient(ts.URL+"/missing.git", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.Ping(context.Background()); err == nil {
+		t.Error("Expected error for missing repository but got nil")
+	}
+}
+
+func TestGitClientShallowSparse(t *testing.T) {
+	t.Parallel()
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip("git not available")
+	}
+
+	out, err := exec.Command(gitPath, "--exec-path").Output()
+	if err != nil {
+		t.Skip("git exec path not available")
+	}
+
+	dir := t.TempDir()
+
+	git := func(args ...string) string {
+		t.Helper()
+
+		cmd := exec.Command(gitPath, args...)
+
+		cmd.Dir = filepath.Join(dir, "src")
+
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@apigo.io",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@apigo.io")
+
+		b, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, b)
+		}
+
+		return strings.TrimSpace(string(b))
+	}
+
+	write := func(name, contents string) {
+		t.Helper()
+
+		fn := filepath.Join(dir, "src", name)
+
+		if err := os.MkdirAll(filepath.Dir(fn), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(fn, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	git("init", "-q", "-b", "main")
+
+	for i := range 3 {
+		write("resources/a.yaml", fmt.Sprintf("name: a%d\n", i))
+		write("other/b.txt", fmt.Sprintf("b%d\n", i))
+		git("add", "-A")
+		git("commit", "-q", "-m", fmt.Sprint("commit ", i))
+	}
+
+	git("clone", "-q", "--bare", ".", "../shallow.git")
+	git("clone", "-q", "--bare", ".", "../full.git")
+
+	ts := httptest.NewServer(&cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+		Env: []string{
+			"GIT_PROJECT_ROOT=" + dir,
+			"GIT_HTTP_EXPORT_ALL=1",
+		},
+	})
+
+	defer ts.Close()
+
+	cfg := config.NewDefault()
+
+	cfg.SetRepo(&config.RepoConfig{
+		Shallow:    true,
+		SparseDirs: []string{"resources"},
+	})
+
+	ctx := context.Background()
+
+	cli, err := repo.NewClient(ts.URL+"/shallow.git",
+		httpclient.NewClient(cfg, nil, nil, nil), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := repo.NewClient(ts.URL+"/full.git",
+		httpclient.NewClient(config.NewDefault(), nil, nil, nil), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := full.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := cli.Commit(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := git("rev-parse", "HEAD"); hash != exp {
+		t.Errorf("Expected commit: %v, got: %v", exp, hash)
+	}
+
+	if _, err := cli.Get(ctx, "other/b.txt"); !errors.Has(err,
+		errors.ErrNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	fs := cli.(repo.FetchStatsClient).FetchStats()
+	if fs == nil || !fs.Clone || !fs.Shallow || !fs.Sparse || fs.Objects == 0 {
+		t.Fatalf("Unexpected clone stats: %+v", fs)
+	}
+
+	if ffs := full.(repo.FetchStatsClient).FetchStats(); ffs == nil ||
+		fs.Objects >= ffs.Objects {
+		t.Errorf("Expected fewer objects than full clone: %+v, got: %+v",
+			ffs, fs)
+	}
+
+	write("resources/a.yaml", "name: updated\n")
+	git("commit", "-q", "-a", "-m", "update")
+	git("push", "-q", "../shallow.git", "main")
+
+	if hash, err = cli.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := git("rev-parse", "HEAD"); hash != exp {
+		t.Errorf("Expected fetched commit: %v, got: %v", exp, hash)
+	}
+
+	b, err := cli.Get(ctx, "resources/a.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(b) != "name: updated\n" {
+		t.Errorf("Expected updated file, got: %s", b)
+	}
+
+	fs = cli.(repo.FetchStatsClient).FetchStats()
+	if fs.Clone || fs.Objects == 0 {
+		t.Errorf("Unexpected fetch stats: %+v", fs)
+	}
+}
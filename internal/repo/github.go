@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/metric"
 	"github.com/google/go-github/v39/github"
 	"go.opentelemetry.io/otel/trace"
@@ -22,15 +23,18 @@ type gitHubClient struct {
 	tracer trace.Tracer
 }
 
-// newGitHubClient creates a new GitHub repository client.
-func newGitHubClient(password string,
+// newGitHubClient creates a new GitHub repository client, which authenticates
+// its requests using tokens from the token source.
+func newGitHubClient(ts oauth2.TokenSource,
 	cfg *Config,
+	client *httpclient.Client,
 	metric metric.Recorder,
 	tracer trace.Tracer,
 ) (*gitHubClient, error) {
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: password})
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+		client.HTTPClient())
 
-	c := oauth2.NewClient(context.Background(), ts)
+	c := oauth2.NewClient(ctx, ts)
 
 	cli := github.NewClient(c)
 
@@ -278,3 +282,11 @@ func (c *gitHubClient) Commit(ctx context.Context) (string, error) {
 
 	return *r.Commit.SHA, nil
 }
+
+// Ping checks that the repository is reachable, by retrieving its main branch
+// reference.
+func (c *gitHubClient) Ping(ctx context.Context) error {
+	_, err := c.Commit(ctx)
+
+	return err
+}
@@ -10,10 +10,12 @@ import (
 	"time"
 
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/metric"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
 )
 
 // Client values are used to interact with a git repository.
@@ -22,6 +24,13 @@ type Client interface {
 	ListAll(ctx context.Context, dirPath string) ([]Item, error)
 	Get(ctx context.Context, filePath string) ([]byte, error)
 	Commit(ctx context.Context) (string, error)
+	Ping(ctx context.Context) error
+}
+
+// FetchStatsClient values are clients which retrieve the contents of a
+// repository, and report statistics for the most recent retrieval.
+type FetchStatsClient interface {
+	FetchStats() *FetchStats
 }
 
 // Item values represent a single item in a repository.
@@ -43,8 +52,10 @@ type Config struct {
 	Ref   string `json:"ref"`
 }
 
-// New is used to create a new repo client from a specified URL.
+// New is used to create a new repo client from a specified URL. Outgoing
+// requests are made using the specified HTTP client.
 func NewClient(repoURL string,
+	client *httpclient.Client,
 	metric metric.Recorder,
 	tracer trace.Tracer,
 ) (Client, error) {
@@ -58,6 +69,10 @@ func NewClient(repoURL string,
 		tracer = nil
 	}
 
+	if client == nil {
+		client = httpclient.NewClient(nil, nil, metric, tracer)
+	}
+
 	u, err := url.Parse(repoURL)
 	if err != nil {
 		return nil, errors.Wrap(err, errors.ErrClient,
@@ -97,17 +112,33 @@ func NewClient(repoURL string,
 
 		cfg.Ref = u.Fragment
 
-		return newBitBucketClient(username, password, cfg, metric, tracer)
+		return newBitBucketClient(username, password, cfg, client, metric,
+			tracer)
 	case "github":
-		if u.User == nil {
-			return nil, errors.New(errors.ErrClient,
-				"invalid repository URL: no user information")
-		}
+		var ts oauth2.TokenSource
 
-		password, ok := u.User.Password()
-		if !ok {
-			return nil, errors.New(errors.ErrClient,
-				"invalid repository URL: no access token")
+		if name := u.Query().Get("credential"); name != "" {
+			cred, err := getCredential(name, client)
+			if err != nil {
+				return nil, err
+			}
+
+			ts = cred
+		} else {
+			if u.User == nil {
+				return nil, errors.New(errors.ErrClient,
+					"invalid repository URL: no user information")
+			}
+
+			password, ok := u.User.Password()
+			if !ok {
+				return nil, errors.New(errors.ErrClient,
+					"invalid repository URL: no access token")
+			}
+
+			ts = oauth2.StaticTokenSource(&oauth2.Token{
+				AccessToken: password,
+			})
 		}
 
 		cfg := &Config{Owner: u.Host}
@@ -127,7 +158,7 @@ func NewClient(repoURL string,
 
 		cfg.Ref = u.Fragment
 
-		return newGitHubClient(password, cfg, metric, tracer)
+		return newGitHubClient(ts, cfg, client, metric, tracer)
 	case "test":
 		if u.User == nil {
 			return nil, errors.New(errors.ErrClient,
@@ -160,6 +191,8 @@ func NewClient(repoURL string,
 		cfg.Ref = u.Fragment
 
 		return newTestClient(username, password, cfg, metric, tracer)
+	case "starter":
+		return newStarterClient(metric, tracer)
 	case "git", "ssh", "http", "https", "git+ssh", "git+http", "git+https":
 		gitLock.RLock()
 
@@ -171,6 +204,22 @@ func NewClient(repoURL string,
 
 		gitLock.RUnlock()
 
+		key := u.String()
+
+		var cred *credential
+
+		q := u.Query()
+
+		if name := q.Get("credential"); name != "" {
+			if cred, err = getCredential(name, client); err != nil {
+				return nil, err
+			}
+
+			q.Del("credential")
+
+			u.RawQuery = q.Encode()
+		}
+
 		username := u.User.Username()
 
 		password, _ := u.User.Password()
@@ -179,14 +228,15 @@ func NewClient(repoURL string,
 
 		cfg := &Config{URL: u.String()}
 
-		gc, err := newGitClient(username, password, cfg, metric, tracer)
+		gc, err := newGitClient(username, password, cred, cfg, client, metric,
+			tracer)
 		if err != nil {
 			return nil, err
 		}
 
 		gitLock.Lock()
 
-		gitClients[u.String()] = gc
+		gitClients[key] = gc
 
 		gitLock.Unlock()
 
@@ -267,6 +317,16 @@ func (c *testClient) Commit(ctx context.Context) (string, error) {
 	return "", nil
 }
 
+// Ping checks that the repository is reachable.
+func (c *testClient) Ping(ctx context.Context) error {
+	_, finish := startRepoSpan(ctx, c.metric, c.tracer, "test",
+		c.cfg, "main", "ping")
+
+	defer finish(nil)
+
+	return nil
+}
+
 // startRepoSpan starts a cache tracing span. It returns an updated context,
 // and a closing function.
 func startRepoSpan(ctx context.Context,
@@ -0,0 +1,215 @@
+package repo
+
+import (
+	"context"
+	"crypto/sha1"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StarterURL is the repository URL of the embedded starter bundle, which can
+// be used in place of a template repository to bootstrap new accounts.
+const StarterURL = "starter://"
+
+//go:embed starter
+var starterFS embed.FS
+
+// starterClient values are used to read the embedded starter bundle as a
+// repository.
+type starterClient struct {
+	fs     fs.FS
+	metric metric.Recorder
+	tracer trace.Tracer
+}
+
+// newStarterClient creates a new starter bundle repository client.
+func newStarterClient(metric metric.Recorder,
+	tracer trace.Tracer,
+) (*starterClient, error) {
+	sfs, err := fs.Sub(starterFS, "starter")
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to open starter bundle")
+	}
+
+	return &starterClient{
+		fs:     sfs,
+		metric: metric,
+		tracer: tracer,
+	}, nil
+}
+
+// List retrieves a directory listing from the repository.
+func (c *starterClient) List(ctx context.Context,
+	dirPath string,
+) ([]Item, error) {
+	_, finish := startRepoSpan(ctx, c.metric, c.tracer, "starter",
+		nil, dirPath, "list")
+
+	res, err := c.list(ctx, dirPath, false)
+
+	finish(err)
+
+	return res, err
+}
+
+// ListAll retrieves a tree listing, recursively, from the repository.
+func (c *starterClient) ListAll(ctx context.Context,
+	dirPath string,
+) ([]Item, error) {
+	_, finish := startRepoSpan(ctx, c.metric, c.tracer, "starter",
+		nil, dirPath, "listAll")
+
+	res, err := c.list(ctx, dirPath, true)
+
+	finish(err)
+
+	return res, err
+}
+
+// list retrieves a directory listing, optionally recursive, from the
+// embedded bundle.
+func (c *starterClient) list(ctx context.Context,
+	dirPath string,
+	recursive bool,
+) ([]Item, error) {
+	commit, err := c.Commit(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dirPath = strings.Trim(dirPath, "/")
+
+	if dirPath == "" {
+		dirPath = "."
+	}
+
+	des, err := fs.ReadDir(c.fs, dirPath)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrNotFound,
+			"repository directory not found",
+			"path", dirPath)
+	}
+
+	res := make([]Item, 0, len(des))
+
+	for _, de := range des {
+		p := path.Join(dirPath, de.Name())
+
+		t := "file"
+
+		if de.IsDir() {
+			t = "dir"
+
+			if recursive {
+				rs, err := c.list(ctx, p, recursive)
+				if err != nil {
+					return nil, err
+				}
+
+				res = append(res, rs...)
+			}
+		}
+
+		mt := "text/plain"
+
+		switch path.Ext(de.Name()) {
+		case ".yaml", ".yml":
+			mt = "application/yaml"
+		case ".json":
+			mt = "application/json"
+		}
+
+		size := 0
+
+		if fi, err := de.Info(); err == nil {
+			size = int(fi.Size())
+		}
+
+		res = append(res, Item{
+			Mimetype: mt,
+			Path:     p,
+			Size:     size,
+			Type:     t,
+			Commit:   commit,
+		})
+	}
+
+	return res, nil
+}
+
+// Get retrieves file contents from the repository.
+func (c *starterClient) Get(ctx context.Context,
+	filePath string,
+) ([]byte, error) {
+	_, finish := startRepoSpan(ctx, c.metric, c.tracer, "starter",
+		nil, filePath, "get")
+
+	buf, err := fs.ReadFile(c.fs, strings.Trim(filePath, "/"))
+	if err != nil {
+		err = errors.Wrap(err, errors.ErrNotFound,
+			"repository file not found",
+			"path", filePath)
+	}
+
+	finish(err)
+
+	return buf, err
+}
+
+// Commit retrieves a hash of the contents of the embedded bundle, which
+// changes only when the bundle does.
+func (c *starterClient) Commit(ctx context.Context) (string, error) {
+	_, finish := startRepoSpan(ctx, c.metric, c.tracer, "starter",
+		nil, "main", "commit")
+
+	h := sha1.New()
+
+	err := fs.WalkDir(c.fs, ".", func(p string,
+		de fs.DirEntry,
+		err error,
+	) error {
+		if err != nil || de.IsDir() {
+			return err
+		}
+
+		buf, err := fs.ReadFile(c.fs, p)
+		if err != nil {
+			return err
+		}
+
+		h.Write([]byte(p))
+		h.Write(buf)
+
+		return nil
+	})
+	if err != nil {
+		err = errors.Wrap(err, errors.ErrClient,
+			"unable to read starter bundle")
+
+		finish(err)
+
+		return "", err
+	}
+
+	finish(nil)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Ping checks that the repository is reachable.
+func (c *starterClient) Ping(ctx context.Context) error {
+	_, finish := startRepoSpan(ctx, c.metric, c.tracer, "starter",
+		nil, "main", "ping")
+
+	defer finish(nil)
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package request
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CanonicalJSON encodes a value into its canonical JSON form, so that equal
+// values always produce identical output. Object keys are sorted, the output
+// is compact, and maps with non-string keys, such as those decoded from YAML,
+// are encoded as objects with their keys formatted as strings.
+func CanonicalJSON(v any) ([]byte, error) {
+	return json.Marshal(canonicalValue(v))
+}
+
+// canonicalValue returns a copy of a decoded JSON or YAML value in which all
+// maps are keyed by strings. Other values are returned unchanged.
+func canonicalValue(v any) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(vv))
+
+		for k, val := range vv {
+			res[k] = canonicalValue(val)
+		}
+
+		return res
+	case map[any]any:
+		res := make(map[string]any, len(vv))
+
+		for k, val := range vv {
+			res[fmt.Sprint(k)] = canonicalValue(val)
+		}
+
+		return res
+	case []any:
+		res := make([]any, len(vv))
+
+		for i, val := range vv {
+			res[i] = canonicalValue(val)
+		}
+
+		return res
+	default:
+		return v
+	}
+}
@@ -0,0 +1,77 @@
+package request_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/request"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	t.Parallel()
+
+	exp := `{"a":[{"x":1,"y":"\u003cb\u003e"}],"b":{"1":true,"c":null},"c":"test"}`
+
+	tests := []struct {
+		name string
+		v    any
+	}{{
+		name: "map",
+		v: map[string]any{
+			"c": "test",
+			"b": map[string]any{"c": nil, "1": true},
+			"a": []any{map[string]any{"y": "<b>", "x": 1}},
+		},
+	}, {
+		name: "yaml map",
+		v: map[string]any{
+			"b": map[any]any{1: true, "c": nil},
+			"a": []any{map[any]any{"x": 1, "y": "<b>"}},
+			"c": "test",
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			for range 10 {
+				b, err := request.CanonicalJSON(tt.v)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if string(b) != exp {
+					t.Fatalf("Expected JSON: %v, got: %v", exp, string(b))
+				}
+			}
+		})
+	}
+}
+
+func TestFieldJSONCanonical(t *testing.T) {
+	t.Parallel()
+
+	f := request.FieldJSON{}
+
+	if err := yaml.Unmarshal([]byte("b:\n  2: two\n  1: one\na: test\n"),
+		&f); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(&f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"a":"test","b":{"1":"one","2":"two"}}`
+
+	if string(b) != exp {
+		t.Errorf("Expected JSON: %v, got: %v", exp, string(b))
+	}
+
+	if f.String() != exp {
+		t.Errorf("Expected string: %v, got: %v", exp, f.String())
+	}
+}
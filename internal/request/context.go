@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/google/uuid"
 )
 
 // ContextKey values are used to index context data.
@@ -53,6 +54,26 @@ const (
 
 	// CtxKeyUserID is used to select the user id from a context.
 	CtxKeyUserID
+
+	// CtxKeyClientID is used to select the identity of a client authenticated
+	// using a TLS client certificate from a context.
+	CtxKeyClientID
+
+	// CtxKeyTimeZone is used to select the time zone of the request from a
+	// context.
+	CtxKeyTimeZone
+
+	// CtxKeyMemo is used to select the values retrieved during the request
+	// from a context.
+	CtxKeyMemo
+
+	// CtxKeyCSRFToken is used to select the CSRF token of the session which
+	// authenticated the request from a context.
+	CtxKeyCSRFToken
+
+	// CtxKeyTx is used to select the database transaction of a unit of work
+	// from a context.
+	CtxKeyTx
 )
 
 // ContextService extracts the service name from the context.
@@ -177,8 +198,153 @@ func ContextUserID(ctx context.Context) (string, error) {
 	return id, nil
 }
 
+// ContextClientID extracts the identity of a client authenticated using a TLS
+// client certificate from the context.
+func ContextClientID(ctx context.Context) (string, error) {
+	id, ok := ctx.Value(CtxKeyClientID).(string)
+	if !ok {
+		return "", errors.New(errors.ErrContext,
+			"unable to extract client id from context")
+	}
+
+	return id, nil
+}
+
+// ContextCSRFToken extracts the CSRF token of the session which authenticated
+// the request from the context.
+func ContextCSRFToken(ctx context.Context) (string, error) {
+	token, ok := ctx.Value(CtxKeyCSRFToken).(string)
+	if !ok {
+		return "", errors.New(errors.ErrContext,
+			"unable to extract CSRF token from context")
+	}
+
+	return token, nil
+}
+
+// ContextTimeZone extracts the time zone used to interpret time inputs of the
+// request from the context.
+func ContextTimeZone(ctx context.Context) (*time.Location, error) {
+	loc, ok := ctx.Value(CtxKeyTimeZone).(*time.Location)
+	if !ok || loc == nil {
+		return nil, errors.New(errors.ErrContext,
+			"unable to extract time zone from context")
+	}
+
+	return loc, nil
+}
+
+// Context values contain the identity and authorization of the principal
+// making a request, and the trace ID of the request, as stored in a context.
+type Context struct {
+	AccountID   string
+	AccountName string
+	UserID      string
+	Scopes      string
+	TraceID     string
+}
+
+// FromContext extracts the identity, authorization and trace ID of a request
+// from a context. Values which are not set in the context are empty.
+func FromContext(ctx context.Context) *Context {
+	c := &Context{}
+
+	c.AccountID, _ = ctx.Value(CtxKeyAccountID).(string)
+	c.AccountName, _ = ctx.Value(CtxKeyAccountName).(string)
+	c.UserID, _ = ctx.Value(CtxKeyUserID).(string)
+	c.Scopes, _ = ctx.Value(CtxKeyScopes).(string)
+	c.TraceID, _ = ctx.Value(CtxKeyTraceID).(string)
+
+	return c
+}
+
+// With returns a copy of a context containing the non-empty values.
+func (c *Context) With(ctx context.Context) context.Context {
+	if c.AccountID != "" {
+		ctx = WithAccountID(ctx, c.AccountID)
+	}
+
+	if c.AccountName != "" {
+		ctx = WithAccountName(ctx, c.AccountName)
+	}
+
+	if c.UserID != "" {
+		ctx = WithUserID(ctx, c.UserID)
+	}
+
+	if c.Scopes != "" {
+		ctx = WithScopes(ctx, c.Scopes)
+	}
+
+	if c.TraceID != "" {
+		ctx = WithTraceID(ctx, c.TraceID)
+	}
+
+	return ctx
+}
+
+// WithAccountID returns a copy of a context containing an account ID.
+func WithAccountID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, CtxKeyAccountID, id)
+}
+
+// WithAccountName returns a copy of a context containing an account name.
+func WithAccountName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, CtxKeyAccountName, name)
+}
+
+// WithUserID returns a copy of a context containing a user ID.
+func WithUserID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, CtxKeyUserID, id)
+}
+
+// WithScopes returns a copy of a context containing authorization scopes.
+func WithScopes(ctx context.Context, scopes string) context.Context {
+	return context.WithValue(ctx, CtxKeyScopes, scopes)
+}
+
+// WithTraceID returns a copy of a context containing a trace ID.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, CtxKeyTraceID, id)
+}
+
+// WithNewTraceID returns a copy of a context containing a new random trace ID,
+// for operations which are not part of a request, such as periodic updates.
+func WithNewTraceID(ctx context.Context) context.Context {
+	tu, err := uuid.NewRandom()
+	if err != nil {
+		return ctx
+	}
+
+	return WithTraceID(ctx, tu.String())
+}
+
+// Elevate returns a copy of a context authorized to perform system operations
+// for an account, as the system user with the superuser scope. If no account
+// ID is specified, the account of the context is kept. The credentials of the
+// request are not carried into the elevated context, so that they can not be
+// used with its authorization, and changes made using it are attributed to the
+// system user, rather than to the user who made the request.
+func Elevate(ctx context.Context, accountID string) context.Context {
+	if accountID != "" {
+		if id, _ := ctx.Value(CtxKeyAccountID).(string); id != accountID {
+			ctx = context.WithValue(ctx, CtxKeyAccountName, nil)
+		}
+
+		ctx = WithAccountID(ctx, accountID)
+	}
+
+	ctx = context.WithValue(ctx, CtxKeyJWT, nil)
+	ctx = context.WithValue(ctx, CtxKeyCSRFToken, nil)
+	ctx = context.WithValue(ctx, CtxKeyClientID, nil)
+	ctx = WithUserID(ctx, SystemUser)
+
+	return WithScopes(ctx, ScopeSuperuser)
+}
+
 // ContextReplaceTimeout creates a copy of an existing context but with a new
-// timeout.
+// timeout. The request memo is not copied, since the new context may outlive
+// the request.
 func ContextReplaceTimeout(ctx context.Context,
 	d time.Duration,
 ) (context.Context, context.CancelFunc) {
@@ -203,6 +369,10 @@ func ContextReplaceTimeout(ctx context.Context,
 	newCtx = context.WithValue(newCtx, CtxKeyAccountName,
 		ctx.Value(CtxKeyAccountName))
 	newCtx = context.WithValue(newCtx, CtxKeyUserID, ctx.Value(CtxKeyUserID))
+	newCtx = context.WithValue(newCtx, CtxKeyClientID,
+		ctx.Value(CtxKeyClientID))
+	newCtx = context.WithValue(newCtx, CtxKeyTimeZone,
+		ctx.Value(CtxKeyTimeZone))
 
 	return newCtx, newCancel
 }
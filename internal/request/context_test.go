@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/dhaifley/apigo/internal/request"
 )
@@ -162,3 +163,128 @@ func TestContextUserID(t *testing.T) {
 		t.Errorf("Expected value: %v, got: %v", exp, val)
 	}
 }
+
+func TestContextClientID(t *testing.T) {
+	t.Parallel()
+
+	exp := "test"
+
+	ctx := context.WithValue(context.Background(), request.CtxKeyClientID, exp)
+
+	val, err := request.ContextClientID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val != exp {
+		t.Errorf("Expected value: %v, got: %v", exp, val)
+	}
+}
+
+func TestContextCSRFToken(t *testing.T) {
+	t.Parallel()
+
+	exp := "test"
+
+	ctx := context.WithValue(context.Background(), request.CtxKeyCSRFToken,
+		exp)
+
+	val, err := request.ContextCSRFToken(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val != exp {
+		t.Errorf("Expected value: %v, got: %v", exp, val)
+	}
+}
+
+func TestContextTimeZone(t *testing.T) {
+	t.Parallel()
+
+	exp := time.FixedZone("test", 3600)
+
+	ctx := context.WithValue(context.Background(), request.CtxKeyTimeZone, exp)
+
+	val, err := request.ContextTimeZone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val != exp {
+		t.Errorf("Expected value: %v, got: %v", exp, val)
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+
+	c := &request.Context{
+		AccountID:   "1",
+		AccountName: "test",
+		UserID:      "user@test.com",
+		Scopes:      request.ScopeResourcesRead,
+		TraceID:     "trace",
+	}
+
+	ctx := c.With(context.Background())
+
+	if res := request.FromContext(ctx); *res != *c {
+		t.Errorf("Expected context: %+v, got: %+v", c, res)
+	}
+
+	ctx = request.WithUserID(ctx, "other@test.com")
+
+	if id, err := request.ContextUserID(ctx); err != nil ||
+		id != "other@test.com" {
+		t.Errorf("Expected user ID: other@test.com, got: %v", id)
+	}
+
+	if res := request.FromContext(context.Background()); *res !=
+		(request.Context{}) {
+		t.Errorf("Expected empty context, got: %+v", res)
+	}
+
+	ctx = request.WithNewTraceID(context.Background())
+
+	if id, err := request.ContextTraceID(ctx); err != nil || id == "" {
+		t.Errorf("Expected new trace ID, got: %v", id)
+	}
+}
+
+func TestElevate(t *testing.T) {
+	t.Parallel()
+
+	ctx := (&request.Context{
+		AccountID:   "1",
+		AccountName: "test",
+		UserID:      "user@test.com",
+		Scopes:      request.ScopeResourcesRead,
+	}).With(context.Background())
+
+	ctx = context.WithValue(ctx, request.CtxKeyJWT, "token")
+
+	ectx := request.Elevate(ctx, "")
+
+	if c := request.FromContext(ectx); c.AccountID != "1" ||
+		c.AccountName != "test" || c.UserID != request.SystemUser ||
+		c.Scopes != request.ScopeSuperuser {
+		t.Errorf("Expected elevated context, got: %+v", c)
+	}
+
+	if _, err := request.ContextJWT(ectx); err == nil {
+		t.Error("Expected no token in elevated context")
+	}
+
+	ectx = request.Elevate(ctx, "2")
+
+	if c := request.FromContext(ectx); c.AccountID != "2" ||
+		c.AccountName != "" {
+		t.Errorf("Expected elevated context for account 2, got: %+v", c)
+	}
+
+	if c := request.FromContext(ctx); c.UserID != "user@test.com" ||
+		c.Scopes != request.ScopeResourcesRead {
+		t.Errorf("Expected original context unchanged, got: %+v", c)
+	}
+}
@@ -27,13 +27,15 @@ func (f *FieldString) UnmarshalJSON(b []byte) error {
 
 	var v any
 
-	if err := json.Unmarshal(b, &v); err != nil {
+	if err := unmarshalNumber(b, &v); err != nil {
 		return err
 	}
 
 	switch tv := v.(type) {
 	case string:
 		f.Value = tv
+	case json.Number:
+		f.Value = tv.String()
 	case float64:
 		f.Value = strconv.FormatFloat(tv, 'f', -1, 64)
 	case int64:
@@ -122,7 +124,7 @@ func (f *FieldInt64) UnmarshalJSON(b []byte) error {
 
 	var v any
 
-	if err := json.Unmarshal(b, &v); err != nil {
+	if err := unmarshalNumber(b, &v); err != nil {
 		return err
 	}
 
@@ -141,6 +143,15 @@ func (f *FieldInt64) UnmarshalJSON(b []byte) error {
 			i = int64(n)
 		}
 
+		f.Value = i
+	case json.Number:
+		i, err := numberInt64(tv)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to parse JSON number into int64",
+				"json", string(b))
+		}
+
 		f.Value = i
 	case float64:
 		f.Value = int64(tv)
@@ -452,23 +463,27 @@ func (f *FieldTime) UnmarshalJSON(b []byte) error {
 
 	var v any
 
-	if err := json.Unmarshal(b, &v); err != nil {
+	if err := unmarshalNumber(b, &v); err != nil {
 		return err
 	}
 
 	switch tv := v.(type) {
 	case string:
-		i, err := strconv.ParseInt(tv, 10, 64)
+		i, err := ParseTime(tv, time.UTC)
 		if err != nil {
-			t, tErr := time.Parse(time.RFC3339, tv)
-			if tErr != nil {
-				return errors.Wrap(tErr, errors.ErrInvalidRequest,
-					"unable to parse JSON string into timestamp",
-					"json", string(b),
-					"string", tv)
-			}
+			return errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to parse JSON string into timestamp",
+				"json", string(b),
+				"string", tv)
+		}
 
-			i = t.Unix()
+		f.Value = i
+	case json.Number:
+		i, err := numberInt64(tv)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to parse JSON number into int64",
+				"json", string(b))
 		}
 
 		f.Value = i
@@ -708,7 +723,7 @@ func (f *FieldInt64Array) UnmarshalJSON(b []byte) error {
 
 	var v any
 
-	if err := json.Unmarshal(b, &v); err != nil {
+	if err := unmarshalNumber(b, &v); err != nil {
 		return err
 	}
 
@@ -716,6 +731,15 @@ func (f *FieldInt64Array) UnmarshalJSON(b []byte) error {
 	case []any:
 		for _, sv := range tv {
 			switch vv := sv.(type) {
+			case json.Number:
+				i, err := numberInt64(vv)
+				if err != nil {
+					return errors.Wrap(err, errors.ErrInvalidRequest,
+						"unable to parse JSON array into []int64",
+						"json", string(b))
+				}
+
+				f.Value = append(f.Value, i)
 			case int64:
 				f.Value = append(f.Value, vv)
 			case float64:
@@ -831,7 +855,8 @@ func (f *FieldInt64Array) String() string {
 	return fmt.Sprintf("%v", f.Value)
 }
 
-// FieldJSON values represent unparsed JSON objects.
+// FieldJSON values represent unparsed JSON objects. Numbers are decoded as
+// json.Number values, so that their precision is preserved.
 type FieldJSON struct {
 	Set   bool
 	Valid bool
@@ -842,7 +867,7 @@ type FieldJSON struct {
 func (f *FieldJSON) UnmarshalJSON(b []byte) error {
 	f.Set = true
 
-	if err := json.Unmarshal(b, &f.Value); err != nil {
+	if err := unmarshalNumber(b, &f.Value); err != nil {
 		return err
 	}
 
@@ -857,7 +882,7 @@ func (f *FieldJSON) MarshalJSON() ([]byte, error) {
 		return json.Marshal(nil)
 	}
 
-	return json.Marshal(f.Value)
+	return CanonicalJSON(f.Value)
 }
 
 // UnmarshalYAML decodes a YAML format byte slice into this value.
@@ -918,7 +943,7 @@ func (f *FieldJSON) Scan(src any) error {
 
 // String returns the value as a string.
 func (f *FieldJSON) String() string {
-	if b, err := json.Marshal(f.Value); err == nil {
+	if b, err := CanonicalJSON(f.Value); err == nil {
 		return string(b)
 	}
 
@@ -1115,7 +1140,7 @@ func SetField(name string, field any,
 			*sets = append(*sets, name)
 
 			if f.Valid {
-				b, err := json.Marshal(f.Value)
+				b, err := CanonicalJSON(f.Value)
 				if err == nil {
 					*params = append(*params, b)
 				} else {
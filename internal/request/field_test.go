@@ -548,3 +548,57 @@ func TestSetField(t *testing.T) {
 		t.Errorf("Expected params length: %v, got: %v", exp, len(params))
 	}
 }
+
+func TestFieldLargeIntegers(t *testing.T) {
+	t.Parallel()
+
+	var v struct {
+		String     request.FieldString     `json:"string"`
+		Int64      request.FieldInt64      `json:"int64"`
+		Int64Array request.FieldInt64Array `json:"int64_array"`
+		JSON       request.FieldJSON       `json:"json"`
+	}
+
+	s := `{
+		"string":9007199254740993,
+		"int64":9007199254740993,
+		"int64_array":[9007199254740993, 1.5],
+		"json":{"id":9007199254740993,"nested":[{"id":-9007199254740993}]}
+	}`
+
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.String.Value != "9007199254740993" {
+		t.Errorf("Expected string: 9007199254740993, got: %v", v.String.Value)
+	}
+
+	if v.Int64.Value != 9007199254740993 {
+		t.Errorf("Expected int64: 9007199254740993, got: %v", v.Int64.Value)
+	}
+
+	if len(v.Int64Array.Value) != 2 ||
+		v.Int64Array.Value[0] != 9007199254740993 ||
+		v.Int64Array.Value[1] != 1 {
+		t.Errorf("Expected int64 array: [9007199254740993 1], got: %v",
+			v.Int64Array.Value)
+	}
+
+	if n, ok := v.JSON.Value["id"].(json.Number); !ok ||
+		n.String() != "9007199254740993" {
+		t.Errorf("Expected JSON id: 9007199254740993, got: %v",
+			v.JSON.Value["id"])
+	}
+
+	b, err := json.Marshal(&v.JSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"id":9007199254740993,"nested":[{"id":-9007199254740993}]}`
+
+	if string(b) != exp {
+		t.Errorf("Expected JSON: %v, got: %v", exp, string(b))
+	}
+}
@@ -0,0 +1,158 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// NewJSONDecoder returns a JSON decoder which decodes numbers into json.Number
+// values, rather than float64 values, so that integers too large to be
+// represented exactly as float64 values, such as int64 IDs, are preserved.
+func NewJSONDecoder(r io.Reader) *json.Decoder {
+	d := json.NewDecoder(r)
+
+	d.UseNumber()
+
+	return d
+}
+
+// DecodeJSON decodes a JSON format request body into a value. Errors are
+// returned as invalid request errors, which identify, where possible, the
+// fields of the body which could not be decoded. If the body is empty, the
+// returned error wraps io.EOF.
+func DecodeJSON(r io.Reader, v any) error {
+	return decodeJSON(r, v, false)
+}
+
+// DecodeJSONNumber decodes a JSON format request body into a value, as
+// DecodeJSON, preserving numbers as json.Number values, as NewJSONDecoder.
+func DecodeJSONNumber(r io.Reader, v any) error {
+	return decodeJSON(r, v, true)
+}
+
+// decodeJSON decodes a JSON format request body into a value.
+func decodeJSON(r io.Reader, v any, number bool) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to read request")
+	}
+
+	unmarshal := json.Unmarshal
+	if number {
+		unmarshal = unmarshalNumber
+	}
+
+	if len(bytes.TrimSpace(b)) == 0 {
+		return errors.Wrap(io.EOF, errors.ErrInvalidRequest,
+			"unable to decode request")
+	}
+
+	err = unmarshal(b, v)
+	if err == nil {
+		return nil
+	}
+
+	f := errors.FieldErrors{}
+
+	if ute, ok := err.(*json.UnmarshalTypeError); ok && ute.Field != "" {
+		f.Add(ute.Field, errors.FieldType,
+			fmt.Sprintf("%s must not be a JSON %s", ute.Field, ute.Value))
+	} else {
+		decodeFields(b, v, unmarshal, &f)
+	}
+
+	return decodeError(err, f)
+}
+
+// decodeError returns an error decoding a request body as an invalid request
+// error, containing the fields which could not be decoded.
+func decodeError(err error, f errors.FieldErrors) error {
+	var e *errors.Error
+
+	if ev, ok := err.(*errors.Error); ok {
+		e = ev.Copy()
+	} else {
+		e = errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to decode request")
+	}
+
+	if len(f) > 0 {
+		e.Fields = f
+	}
+
+	return e
+}
+
+// decodeFields adds the fields of a JSON object which can not be decoded into
+// a struct value to the field errors. Each field is decoded separately, since
+// errors returned by the decoding methods of field types do not identify the
+// field being decoded.
+func decodeFields(b []byte,
+	v any,
+	unmarshal func([]byte, any) error,
+	f *errors.FieldErrors,
+) {
+	t := reflect.TypeOf(v)
+
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+
+	obj := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(obj))
+
+	for k := range obj {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fb, err := json.Marshal(map[string]json.RawMessage{k: obj[k]})
+		if err != nil {
+			continue
+		}
+
+		if err := unmarshal(fb, reflect.New(t).Interface()); err != nil {
+			f.AddErr(k, errors.FieldType, err)
+		}
+	}
+}
+
+// unmarshalNumber decodes a JSON format byte slice into a value, preserving
+// numbers as json.Number values.
+func unmarshalNumber(b []byte, v any) error {
+	return NewJSONDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// numberInt64 converts a JSON number into an int64, truncating any fractional
+// part.
+func numberInt64(n json.Number) (int64, error) {
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+
+	f, err := strconv.ParseFloat(n.String(), 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(f), nil
+}
@@ -0,0 +1,97 @@
+package request_test
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	t.Parallel()
+
+	type body struct {
+		Name  request.FieldString `json:"name"`
+		Count request.FieldInt64  `json:"count"`
+		Tags  []string            `json:"tags"`
+		Size  int                 `json:"size"`
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		fields []string
+		msg    string
+	}{{
+		name: "valid",
+		body: `{"name":"test","count":1,"tags":["a"],"size":2}`,
+	}, {
+		name:   "field types",
+		body:   `{"name":{},"count":"test","tags":["a"]}`,
+		fields: []string{"count", "name"},
+		msg:    "unable to parse JSON into string",
+	}, {
+		name:   "type",
+		body:   `{"name":"test","size":"test"}`,
+		fields: []string{"size"},
+		msg:    "unable to decode request",
+	}, {
+		name: "syntax",
+		body: `{"name":`,
+		msg:  "unable to decode request",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := request.DecodeJSON(strings.NewReader(tt.body), &body{})
+			if tt.msg == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+
+				return
+			}
+
+			var e *errors.Error
+
+			if !errors.As(err, &e) {
+				t.Fatalf("Expected error, got: %v", err)
+			}
+
+			if e.Code != errors.ErrInvalidRequest || e.Msg != tt.msg {
+				t.Errorf("Expected message: %v, got: %v", tt.msg, e)
+			}
+
+			if len(e.Fields) != len(tt.fields) {
+				t.Fatalf("Expected fields: %v, got: %v", tt.fields, e.Fields)
+			}
+
+			for i, f := range tt.fields {
+				if e.Fields[i].Field != f {
+					t.Errorf("Expected field: %v, got: %v", f, e.Fields[i])
+				}
+			}
+		})
+	}
+
+	err := request.DecodeJSON(strings.NewReader(" "), &body{})
+	if !errors.Is(err, io.EOF) || !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected EOF invalid request error, got: %v", err)
+	}
+
+	v := map[string]any{}
+
+	if err := request.DecodeJSONNumber(strings.NewReader(`{"id":1}`),
+		&v); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, ok := v["id"].(json.Number); !ok {
+		t.Errorf("Expected number, got: %T", v["id"])
+	}
+}
@@ -0,0 +1,88 @@
+package request
+
+import (
+	"net/http"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// MultiStatusItem values represent the result of a single item in a batch
+// request. The index correlates the result with the position of the item in
+// the request.
+type MultiStatusItem struct {
+	Index  int           `json:"index"`
+	ID     string        `json:"id,omitempty"`
+	Status int           `json:"status"`
+	Error  *errors.Error `json:"error,omitempty"`
+	Data   any           `json:"data,omitempty"`
+}
+
+// MultiStatus values represent the results of a batch request in which each
+// item may succeed, or fail, independently of the others.
+type MultiStatus struct {
+	Total     int                `json:"total"`
+	Succeeded int                `json:"succeeded"`
+	Failed    int                `json:"failed"`
+	Items     []*MultiStatusItem `json:"items"`
+}
+
+// NewMultiStatus creates a new, empty, batch result.
+func NewMultiStatus() *MultiStatus {
+	return &MultiStatus{Items: []*MultiStatusItem{}}
+}
+
+// Add records the result of the item at the specified index of a batch
+// request. If err is not nil, the item is recorded as failed, using the status
+// of the error, otherwise it is recorded with the provided success status.
+func (m *MultiStatus) Add(index int, id string, status int, data any,
+	err error,
+) {
+	item := &MultiStatusItem{
+		Index:  index,
+		ID:     id,
+		Status: status,
+		Data:   data,
+	}
+
+	if err != nil {
+		e, ok := err.(*errors.Error)
+		if !ok {
+			e = errors.Wrap(err, errors.ErrServer, err.Error())
+		}
+
+		e.Retryable = errors.IsRetryable(e)
+
+		item.Status = e.Code.Status
+		item.Error = e
+		item.Data = nil
+	}
+
+	if item.Status < http.StatusBadRequest {
+		m.Succeeded++
+	} else {
+		m.Failed++
+	}
+
+	m.Total++
+
+	m.Items = append(m.Items, item)
+}
+
+// Status returns the HTTP status code which should be used to respond to the
+// batch request. If all items share the same status, that status is used,
+// otherwise the response is 207 Multi-Status.
+func (m *MultiStatus) Status() int {
+	if len(m.Items) == 0 {
+		return http.StatusOK
+	}
+
+	status := m.Items[0].Status
+
+	for _, item := range m.Items[1:] {
+		if item.Status != status {
+			return http.StatusMultiStatus
+		}
+	}
+
+	return status
+}
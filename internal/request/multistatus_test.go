@@ -0,0 +1,56 @@
+package request_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+func TestMultiStatus(t *testing.T) {
+	t.Parallel()
+
+	res := request.NewMultiStatus()
+
+	if res.Status() != http.StatusOK {
+		t.Errorf("Expected status: %v, got: %v", http.StatusOK, res.Status())
+	}
+
+	res.Add(0, TestUUID, http.StatusCreated, TestName, nil)
+
+	if res.Status() != http.StatusCreated {
+		t.Errorf("Expected status: %v, got: %v",
+			http.StatusCreated, res.Status())
+	}
+
+	res.Add(1, TestID, http.StatusCreated, TestName,
+		errors.New(errors.ErrInvalidRequest, "test error"))
+
+	if res.Status() != http.StatusMultiStatus {
+		t.Errorf("Expected status: %v, got: %v",
+			http.StatusMultiStatus, res.Status())
+	}
+
+	if res.Total != 2 || res.Succeeded != 1 || res.Failed != 1 {
+		t.Errorf("Expected total: 2, succeeded: 1, failed: 1, got: %v, %v, %v",
+			res.Total, res.Succeeded, res.Failed)
+	}
+
+	item := res.Items[1]
+
+	if item.Index != 1 || item.ID != TestID {
+		t.Errorf("Expected index: 1, id: %v, got: %v, %v",
+			TestID, item.Index, item.ID)
+	}
+
+	if item.Status != errors.ErrInvalidRequest.Status {
+		t.Errorf("Expected status: %v, got: %v",
+			errors.ErrInvalidRequest.Status, item.Status)
+	}
+
+	if item.Error == nil || item.Data != nil {
+		t.Errorf("Expected error and no data, got: %v, %v",
+			item.Error, item.Data)
+	}
+}
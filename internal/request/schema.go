@@ -0,0 +1,125 @@
+package request
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaDialect is the JSON Schema dialect used by generated schemas.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// fieldSchemas contains the JSON Schemas of the wire formats of the request
+// field types. All field types may be null.
+var fieldSchemas = map[reflect.Type]map[string]any{
+	reflect.TypeOf(FieldString{}):  {"type": []string{"string", "null"}},
+	reflect.TypeOf(FieldInt64{}):   {"type": []string{"integer", "null"}},
+	reflect.TypeOf(FieldFloat64{}): {"type": []string{"number", "null"}},
+	reflect.TypeOf(FieldBool{}):    {"type": []string{"boolean", "null"}},
+	reflect.TypeOf(FieldTime{}): {
+		"type":        []string{"integer", "null"},
+		"description": "A Unix timestamp, in seconds.",
+	},
+	reflect.TypeOf(FieldStringArray{}): {
+		"type":  []string{"array", "null"},
+		"items": map[string]any{"type": "string"},
+	},
+	reflect.TypeOf(FieldInt64Array{}): {
+		"type":  []string{"array", "null"},
+		"items": map[string]any{"type": "integer"},
+	},
+	reflect.TypeOf(FieldJSON{}): {"type": []string{"object", "null"}},
+	reflect.TypeOf(FieldDuration{}): {
+		"type":        []string{"string", "null"},
+		"description": "A duration, such as 1h30m.",
+	},
+}
+
+// JSONSchema generates a JSON Schema describing the JSON wire format of a
+// struct value, including any request field types it contains. The id and
+// title are used to identify the schema.
+func JSONSchema(id, title string, v any) map[string]any {
+	res := typeSchema(reflect.TypeOf(v))
+
+	res["$schema"] = SchemaDialect
+
+	if id != "" {
+		res["$id"] = id
+	}
+
+	if title != "" {
+		res["title"] = title
+	}
+
+	return res
+}
+
+// typeSchema generates the JSON Schema of a type.
+func typeSchema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+
+	if s, ok := fieldSchemas[t]; ok {
+		res := make(map[string]any, len(s))
+
+		for k, v := range s {
+			res[k] = v
+		}
+
+		return res
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		props := map[string]any{}
+
+		for i := range t.NumField() {
+			f := t.Field(i)
+
+			if !f.IsExported() {
+				continue
+			}
+
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+			if name == "-" {
+				continue
+			}
+
+			if name == "" {
+				name = f.Name
+			}
+
+			props[name] = typeSchema(f.Type)
+		}
+
+		return map[string]any{
+			"type":       "object",
+			"properties": props,
+		}
+	}
+
+	return map[string]any{}
+}
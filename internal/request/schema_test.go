@@ -0,0 +1,50 @@
+package request_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+func TestJSONSchema(t *testing.T) {
+	t.Parallel()
+
+	type nested struct {
+		Count int `json:"count"`
+	}
+
+	type test struct {
+		ID       request.FieldString      `json:"id"`
+		Time     request.FieldTime        `json:"time"`
+		Tags     request.FieldStringArray `json:"tags"`
+		Data     request.FieldJSON        `json:"data"`
+		Secret   request.FieldString      `json:"-"`
+		Password *string                  `json:"password,omitempty"`
+		Nested   []nested                 `json:"nested"`
+		hidden   string
+	}
+
+	b, err := json.Marshal(request.JSONSchema("https://test.com/schemas/test",
+		"test", test{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"$id":"https://test.com/schemas/test",` +
+		`"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"properties":{` +
+		`"data":{"type":["object","null"]},` +
+		`"id":{"type":["string","null"]},` +
+		`"nested":{"items":{"properties":{"count":{"type":"integer"}},` +
+		`"type":"object"},"type":"array"},` +
+		`"password":{"type":"string"},` +
+		`"tags":{"items":{"type":"string"},"type":["array","null"]},` +
+		`"time":{"description":"A Unix timestamp, in seconds.",` +
+		`"type":["integer","null"]}},` +
+		`"title":"test","type":"object"}`
+
+	if string(b) != exp {
+		t.Errorf("Expected schema: %v, got: %v", exp, string(b))
+	}
+}
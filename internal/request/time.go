@@ -0,0 +1,146 @@
+package request
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// HeaderTimeZone is the request header used by callers to specify the IANA
+// time zone, such as America/New_York, used to interpret time inputs which do
+// not include a time zone offset.
+const HeaderTimeZone = "Time-Zone"
+
+// Time layouts accepted without a time zone offset, which are interpreted in
+// the time zone of the request.
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+// ParseTimeZone parses an IANA time zone name into a location.
+func ParseTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+
+	if name == "" || strings.EqualFold(name, "local") {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid time zone",
+			"time_zone", name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid time zone",
+			"time_zone", name)
+	}
+
+	return loc, nil
+}
+
+// ParseTime parses a time input into a Unix timestamp. Integer Unix timestamps
+// and RFC3339 times are accepted, as are date-only and date and time inputs
+// without an offset, which are interpreted in the specified location. If loc is
+// nil, UTC is used.
+func ParseTime(v string, loc *time.Location) (int64, error) {
+	v = strings.TrimSpace(v)
+
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return i, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Unix(), nil
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t.Unix(), nil
+		}
+	}
+
+	return 0, errors.New(errors.ErrInvalidRequest,
+		"unable to parse time",
+		"time", v)
+}
+
+// IsRelativeTime determines whether a time input is relative to the current
+// time, such as now-24h.
+func IsRelativeTime(v string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(v)), "now")
+}
+
+// ParseRelativeTime parses a time input relative to a time, such as now-24h,
+// into a Unix timestamp. The input is now, optionally followed by + or - and
+// a duration, such as 90s, 1h30m, 7d or 2w, where d and w are days and weeks.
+func ParseRelativeTime(v string, now time.Time) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(v))
+
+	rest, ok := strings.CutPrefix(s, "now")
+	if !ok {
+		return 0, errors.New(errors.ErrInvalidRequest,
+			"unable to parse relative time",
+			"time", v)
+	}
+
+	if rest == "" {
+		return now.Unix(), nil
+	}
+
+	sign := time.Duration(1)
+
+	switch rest[0] {
+	case '+':
+	case '-':
+		sign = -1
+	default:
+		return 0, errors.New(errors.ErrInvalidRequest,
+			"unable to parse relative time",
+			"time", v)
+	}
+
+	rest = rest[1:]
+
+	if rest == "" || rest[0] < '0' || rest[0] > '9' {
+		return 0, errors.New(errors.ErrInvalidRequest,
+			"unable to parse relative time",
+			"time", v)
+	}
+
+	var d time.Duration
+
+	switch unit := rest[len(rest)-1]; unit {
+	case 'd', 'w':
+		n, err := strconv.ParseUint(rest[:len(rest)-1], 10, 32)
+		if err != nil {
+			return 0, errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to parse relative time",
+				"time", v)
+		}
+
+		d = time.Duration(n) * 24 * time.Hour
+
+		if unit == 'w' {
+			d *= 7
+		}
+	default:
+		var err error
+
+		if d, err = time.ParseDuration(rest); err != nil {
+			return 0, errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to parse relative time",
+				"time", v)
+		}
+	}
+
+	return now.Add(sign * d).Unix(), nil
+}
@@ -0,0 +1,125 @@
+package request_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+func TestParseTime(t *testing.T) {
+	t.Parallel()
+
+	loc := time.FixedZone("test", -5*60*60)
+
+	tests := []struct {
+		name string
+		v    string
+		loc  *time.Location
+		exp  int64
+		err  bool
+	}{{
+		name: "unix",
+		v:    "1704067200",
+		loc:  loc,
+		exp:  1704067200,
+	}, {
+		name: "rfc3339",
+		v:    "2024-01-01T00:00:00Z",
+		loc:  loc,
+		exp:  1704067200,
+	}, {
+		name: "date utc",
+		v:    "2024-01-01",
+		exp:  1704067200,
+	}, {
+		name: "date",
+		v:    "2024-01-01",
+		loc:  loc,
+		exp:  1704085200,
+	}, {
+		name: "date time",
+		v:    "2024-01-01T01:30",
+		loc:  loc,
+		exp:  1704090600,
+	}, {
+		name: "invalid",
+		v:    "test",
+		err:  true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := request.ParseTime(tt.v, tt.loc)
+			if tt.err {
+				if err == nil {
+					t.Errorf("Expected error, got: %v", v)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if v != tt.exp {
+				t.Errorf("Expected time: %v, got: %v", tt.exp, v)
+			}
+		})
+	}
+}
+
+func TestParseRelativeTime(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1704067200, 0)
+
+	for v, exp := range map[string]int64{
+		"now":       1704067200,
+		"NOW-24h":   1703980800,
+		"now+1h30m": 1704072600,
+		"now-7d":    1703462400,
+		"now-2w":    1702857600,
+	} {
+		if !request.IsRelativeTime(v) {
+			t.Errorf("Expected relative time: %v", v)
+		}
+
+		res, err := request.ParseRelativeTime(v, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res != exp {
+			t.Errorf("Expected %v time: %v, got: %v", v, exp, res)
+		}
+	}
+
+	for _, v := range []string{"now-", "now-h", "now*1h", "now-1y", "today"} {
+		if _, err := request.ParseRelativeTime(v, now); err == nil {
+			t.Errorf("Expected error parsing: %v", v)
+		}
+	}
+}
+
+func TestParseTimeZone(t *testing.T) {
+	t.Parallel()
+
+	loc, err := request.ParseTimeZone("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loc.String() != "America/New_York" {
+		t.Errorf("Expected time zone: America/New_York, got: %v", loc)
+	}
+
+	for _, v := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		if _, err := request.ParseTimeZone(v); err == nil {
+			t.Errorf("Expected error for time zone: %q", v)
+		}
+	}
+}
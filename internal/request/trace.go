@@ -0,0 +1,10 @@
+package request
+
+import "go.opentelemetry.io/otel/propagation"
+
+// Propagator extracts and injects W3C trace context and baggage headers, so
+// that traces flow across services.
+var Propagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+)
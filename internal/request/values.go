@@ -30,6 +30,8 @@ const (
 	StatusDeactivating = "deactivating"
 	StatusDisconnected = "disconnected"
 	StatusImporting    = "importing"
+	StatusStale        = "stale"
+	StatusDegraded     = "degraded"
 )
 
 // Valid system entities.
@@ -117,6 +119,29 @@ func ValidResourceID(id string) bool {
 	return true
 }
 
+// ValidAgentID checks whether a string is a valid agent ID.
+func ValidAgentID(id string) bool {
+	validChars := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"1234567890-_:."
+
+	if len(id) == 0 {
+		return false
+	}
+
+	for _, r := range id {
+		if !strings.ContainsRune(validChars, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ValidGroupID checks whether a string is a valid group ID.
+func ValidGroupID(id string) bool {
+	return ValidAgentID(id)
+}
+
 // ValidScope checks whether a string is a valid scope.
 func ValidScope(scope string) bool {
 	for _, s := range Scopes {
@@ -47,6 +47,78 @@ func TestValidAccountID(t *testing.T) {
 	}
 }
 
+func TestValidAgentID(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		id string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{{
+		name: "valid",
+		args: args{id: "agent-1.example.com"},
+		want: true,
+	}, {
+		name: "invalid",
+		args: args{id: "agent/1"},
+		want: false,
+	}, {
+		name: "empty",
+		args: args{id: ""},
+		want: false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := request.ValidAgentID(tt.args.id); got != tt.want {
+				t.Errorf("ValidAgentID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidGroupID(t *testing.T) {
+	t.Parallel()
+
+	type args struct {
+		id string
+	}
+
+	tests := []struct {
+		name string
+		args args
+		want bool
+	}{{
+		name: "valid",
+		args: args{id: "ops-team"},
+		want: true,
+	}, {
+		name: "invalid",
+		args: args{id: "ops team"},
+		want: false,
+	}, {
+		name: "empty",
+		args: args{id: ""},
+		want: false,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := request.ValidGroupID(tt.args.id); got != tt.want {
+				t.Errorf("ValidGroupID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestValidAccountName(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,220 @@
+package request
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ContentTypeYAML is the media type of YAML format request and response
+// bodies.
+const ContentTypeYAML = "application/yaml"
+
+// yamlMediaTypes are the media types identifying YAML format content.
+var yamlMediaTypes = []string{
+	ContentTypeYAML,
+	"application/x-yaml",
+	"text/yaml",
+	"text/x-yaml",
+}
+
+// yamlKinds are the names of YAML value types, by tag, used in errors.
+var yamlKinds = map[string]string{
+	"!!str":   "string",
+	"!!int":   "integer",
+	"!!float": "number",
+	"!!bool":  "boolean",
+	"!!null":  "null",
+	"!!map":   "mapping",
+	"!!seq":   "sequence",
+}
+
+// IsYAML determines whether a media type, such as the value of a Content-Type
+// header, identifies YAML format content.
+func IsYAML(mediaType string) bool {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+
+	return slices.Contains(yamlMediaTypes, mt)
+}
+
+// AcceptsYAML determines whether the value of an Accept header prefers YAML
+// format content to JSON format content. Media types are compared by their
+// quality values, and then by the order in which they are listed. Wildcards
+// are treated as accepting JSON, which is the default format.
+func AcceptsYAML(accept string) bool {
+	yes, best := false, 0.0
+
+	for _, v := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+
+		if qv, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qv, 64); err != nil {
+				continue
+			}
+		}
+
+		if q <= best {
+			continue
+		}
+
+		switch {
+		case slices.Contains(yamlMediaTypes, mt):
+			yes, best = true, q
+		case mt == "application/json" || mt == "application/*" ||
+			mt == "*/*":
+			yes, best = false, q
+		}
+	}
+
+	return yes
+}
+
+// DecodeYAML decodes a YAML format request body into a value, using the YAML
+// decoding methods of its field types. Errors are returned as invalid request
+// errors, which identify, where possible, the fields of the body which could
+// not be decoded. If the body is empty, the returned error wraps io.EOF.
+func DecodeYAML(r io.Reader, v any) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to read request")
+	}
+
+	if len(bytes.TrimSpace(b)) == 0 {
+		return errors.Wrap(io.EOF, errors.ErrInvalidRequest,
+			"unable to decode request")
+	}
+
+	err = yaml.Unmarshal(b, v)
+	if err == nil {
+		return nil
+	}
+
+	f := errors.FieldErrors{}
+
+	decodeYAMLFields(b, v, &f)
+
+	return decodeError(err, f)
+}
+
+// decodeYAMLFields adds the fields of a YAML mapping which can not be decoded
+// into a struct value to the field errors, decoding each field separately, as
+// decodeFields does for JSON objects.
+func decodeYAMLFields(b []byte, v any, f *errors.FieldErrors) {
+	t := reflect.TypeOf(v)
+
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+
+	doc := &yaml.Node{}
+
+	if err := yaml.Unmarshal(b, doc); err != nil || len(doc.Content) == 0 ||
+		doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+
+	obj := map[string]*yaml.Node{}
+
+	for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+		obj[doc.Content[0].Content[i].Value] = doc.Content[0].Content[i+1]
+	}
+
+	keys := make([]string, 0, len(obj))
+
+	for k := range obj {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fb, err := yaml.Marshal(map[string]*yaml.Node{k: obj[k]})
+		if err != nil {
+			continue
+		}
+
+		err = yaml.Unmarshal(fb, reflect.New(t).Interface())
+		if err == nil {
+			continue
+		}
+
+		if _, ok := err.(*yaml.TypeError); ok {
+			kind, ok := yamlKinds[obj[k].ShortTag()]
+			if !ok {
+				kind = "value"
+			}
+
+			f.Add(k, errors.FieldType,
+				fmt.Sprintf("%s must not be a YAML %s", k, kind))
+
+			continue
+		}
+
+		f.AddErr(k, errors.FieldType, err)
+	}
+}
+
+// YAMLFromJSON converts a JSON format byte slice into a YAML format byte
+// slice, in block style, preserving the order of object keys.
+func YAMLFromJSON(b []byte) ([]byte, error) {
+	n := &yaml.Node{}
+
+	if err := yaml.Unmarshal(b, n); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to convert JSON into YAML")
+	}
+
+	blockStyle(n)
+
+	buf := &bytes.Buffer{}
+
+	enc := yaml.NewEncoder(buf)
+
+	enc.SetIndent(2)
+
+	if err := enc.Encode(n); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to convert JSON into YAML")
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to convert JSON into YAML")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// blockStyle clears the flow and quoting styles of a YAML node, and its
+// children, parsed from JSON, so that it is encoded in block style. Strings
+// which would otherwise be read as another type remain quoted, since the
+// encoder preserves their tags.
+func blockStyle(n *yaml.Node) {
+	n.Style = 0
+
+	for _, c := range n.Content {
+		blockStyle(c)
+	}
+}
@@ -0,0 +1,90 @@
+package request_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	t.Parallel()
+
+	type body struct {
+		Name  request.FieldString `json:"name"  yaml:"name"`
+		Count request.FieldInt64  `json:"count" yaml:"count"`
+		Data  request.FieldJSON   `json:"data"  yaml:"data"`
+		Size  int                 `json:"size"  yaml:"size"`
+	}
+
+	v := &body{}
+
+	if err := request.DecodeYAML(strings.NewReader(
+		"name: test\ncount: 2\ndata:\n  key: value\n"), v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Name.Value != "test" || v.Count.Value != 2 ||
+		v.Data.Value["key"] != "value" {
+		t.Errorf("Unexpected decoded value: %+v", v)
+	}
+
+	err := request.DecodeYAML(strings.NewReader(
+		"name: test\nsize: [1]\n"), &body{})
+
+	var e *errors.Error
+
+	if !errors.As(err, &e) || e.Code != errors.ErrInvalidRequest {
+		t.Fatalf("Expected invalid request error, got: %v", err)
+	}
+
+	if len(e.Fields) != 1 || e.Fields[0].Field != "size" ||
+		e.Fields[0].Message != "size must not be a YAML sequence" {
+		t.Errorf("Expected size field error, got: %v", e.Fields)
+	}
+
+	if err := request.DecodeYAML(strings.NewReader(" \n"),
+		&body{}); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+}
+
+func TestYAMLFromJSON(t *testing.T) {
+	t.Parallel()
+
+	b, err := request.YAMLFromJSON([]byte(
+		`{"name":"test","id":"123","count":9007199254740993,` +
+			`"tags":["a","b"],"data":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "name: test\nid: \"123\"\ncount: 9007199254740993\n" +
+		"tags:\n  - a\n  - b\ndata: {}\n"
+
+	if string(b) != exp {
+		t.Errorf("Expected YAML: %q, got: %q", exp, string(b))
+	}
+}
+
+func TestAcceptsYAML(t *testing.T) {
+	t.Parallel()
+
+	for accept, exp := range map[string]bool{
+		"":                              false,
+		"*/*":                           false,
+		"application/json":              false,
+		"application/yaml":              true,
+		"text/yaml; charset=utf-8":      true,
+		"application/json, text/yaml":   false,
+		"application/yaml, */*;q=0.8":   true,
+		"application/yaml;q=0.5, */*":   false,
+		"application/x-yaml;q=0.9, x/y": true,
+	} {
+		if res := request.AcceptsYAML(accept); res != exp {
+			t.Errorf("Expected %q accepts YAML: %v, got: %v", accept, exp,
+				res)
+		}
+	}
+}
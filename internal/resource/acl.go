@@ -0,0 +1,494 @@
+package resource
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// Resource ACL permissions. Each permission includes the permissions listed
+// before it.
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+	PermissionAdmin = "admin"
+)
+
+// Resource ACL principal types.
+const (
+	PrincipalUser  = "user"
+	PrincipalGroup = "group"
+)
+
+// ACL values grant a permission on a resource to a user, or to the users of a
+// group. Resources without any ACL entries are accessible to all users of the
+// account, according to their scopes. Once a resource has an ACL entry, only
+// the principals granted a permission are able to access it.
+type ACL struct {
+	ResourceID    request.FieldString `json:"resource_id"    yaml:"resource_id"`
+	PrincipalType request.FieldString `json:"principal_type" yaml:"principal_type"`
+	PrincipalID   request.FieldString `json:"principal_id"   yaml:"principal_id"`
+	Permission    request.FieldString `json:"permission"     yaml:"permission"`
+	CreatedAt     request.FieldTime   `json:"created_at"     yaml:"created_at"`
+	CreatedBy     request.FieldString `json:"created_by"     yaml:"created_by"`
+	UpdatedAt     request.FieldTime   `json:"updated_at"     yaml:"updated_at"`
+	UpdatedBy     request.FieldString `json:"updated_by"     yaml:"updated_by"`
+}
+
+// Validate checks that the value contains valid data.
+func (a *ACL) Validate() error {
+	if !a.ResourceID.Valid || !request.ValidResourceID(a.ResourceID.Value) {
+		return errors.New(errors.ErrInvalidRequest,
+			"invalid resource_id",
+			"acl", a)
+	}
+
+	switch a.PrincipalType.Value {
+	case PrincipalUser:
+		if !request.ValidUserID(a.PrincipalID.Value) {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid principal_id",
+				"acl", a)
+		}
+	case PrincipalGroup:
+		if !request.ValidGroupID(a.PrincipalID.Value) {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid principal_id",
+				"acl", a)
+		}
+	default:
+		return errors.New(errors.ErrInvalidRequest,
+			"invalid principal_type",
+			"acl", a)
+	}
+
+	if a.Permission.Set && aclPermissions(a.Permission.Value) == nil {
+		return errors.New(errors.ErrInvalidRequest,
+			"invalid permission",
+			"acl", a)
+	}
+
+	return nil
+}
+
+// ValidateCreate checks that the value contains valid data for creation.
+func (a *ACL) ValidateCreate() error {
+	if !a.Permission.Set {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing permission",
+			"acl", a)
+	}
+
+	return a.Validate()
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (a *ACL) ScanDest() []any {
+	return sqldb.ScanFields("resource_acl", aclFields, nil,
+		map[string]any{
+			"resource_id":    &a.ResourceID,
+			"principal_type": &a.PrincipalType,
+			"principal_id":   &a.PrincipalID,
+			"permission":     &a.Permission,
+			"created_at":     &a.CreatedAt,
+			"created_by":     &a.CreatedBy,
+			"updated_at":     &a.UpdatedAt,
+			"updated_by":     &a.UpdatedBy,
+		})
+}
+
+// aclFields contain the fields for resource ACL entries.
+var aclFields = []*sqldb.Field{{
+	Name:   "resource_acl_key",
+	Type:   sqldb.FieldInt,
+	Table:  "resource_acl",
+	Hidden: true,
+}, {
+	Name:  "resource_id",
+	Type:  sqldb.FieldString,
+	Table: "resource_acl",
+}, {
+	Name:  "principal_type",
+	Type:  sqldb.FieldString,
+	Table: "resource_acl",
+}, {
+	Name:    "principal_id",
+	Type:    sqldb.FieldString,
+	Table:   "resource_acl",
+	Primary: true,
+}, {
+	Name:  "permission",
+	Type:  sqldb.FieldString,
+	Table: "resource_acl",
+}, {
+	Name:  "created_at",
+	Type:  sqldb.FieldTime,
+	Table: "resource_acl",
+}, {
+	Name:  "created_by",
+	Type:  sqldb.FieldString,
+	Table: "created_by_user",
+	From:  `"user"`,
+	Key:   "user_key",
+	Join:  "created_by",
+	Expr:  "created_by_user.user_id",
+}, {
+	Name:  "updated_at",
+	Type:  sqldb.FieldTime,
+	Table: "resource_acl",
+}, {
+	Name:  "updated_by",
+	Type:  sqldb.FieldString,
+	Table: "updated_by_user",
+	From:  `"user"`,
+	Key:   "user_key",
+	Join:  "updated_by",
+	Expr:  "updated_by_user.user_id",
+}}
+
+// aclPermissions returns the permissions which include a permission, or nil if
+// the permission is not valid.
+func aclPermissions(permission string) []string {
+	perms := []string{PermissionRead, PermissionWrite, PermissionAdmin}
+
+	i := slices.Index(perms, permission)
+	if i < 0 {
+		return nil
+	}
+
+	return perms[i:]
+}
+
+// aclExempt returns whether the current user is exempt from resource ACLs.
+// Users holding the resources:admin scope, including the system user, are able
+// to access all resources.
+func aclExempt(ctx context.Context) bool {
+	return request.ContextHasScope(ctx, request.ScopeResourcesAdmin)
+}
+
+// aclRestrictedExpr returns a SQL expression which is true when the resource
+// identified by the resourceID expression has any ACL entries.
+func aclRestrictedExpr(resourceID string) string {
+	return `EXISTS (SELECT 1 FROM resource_acl
+		WHERE resource_acl.resource_id = ` + resourceID + `)`
+}
+
+// aclGrantedExpr returns a SQL expression which is true when the resource
+// identified by the resourceID expression has an ACL entry granting the user,
+// or an active group containing the user, one of a list of permissions. The
+// user ID and list of permissions are the numbered query parameters.
+func aclGrantedExpr(resourceID string, userParam, permParam int) string {
+	user := "$" + strconv.Itoa(userParam) + "::TEXT"
+
+	return `EXISTS (SELECT 1 FROM resource_acl
+		WHERE resource_acl.resource_id = ` + resourceID + `
+		AND resource_acl.permission = ANY($` + strconv.Itoa(permParam) +
+		`::TEXT[])
+		AND ((resource_acl.principal_type = '` + PrincipalUser + `'
+			AND resource_acl.principal_id = ` + user + `)
+		OR (resource_acl.principal_type = '` + PrincipalGroup + `'
+			AND resource_acl.principal_id IN (
+				SELECT user_group.group_id FROM user_group
+				WHERE user_group.status = '` + request.StatusActive + `'
+				AND ` + user + ` = ANY(user_group.users)))))`
+}
+
+// aclFilter returns a SQL condition, and its parameters, which selects only
+// the resources the current user is able to read. The parameters are numbered
+// starting after the number of existing parameters. An empty condition is
+// returned for users exempt from resource ACLs.
+func aclFilter(ctx context.Context, existing int) (string, []any, error) {
+	return aclFilterExpr(ctx, "resource.resource_id", existing)
+}
+
+// aclFilterExpr returns a SQL condition, and its parameters, which selects only
+// the resources, identified by the resourceID expression, the current user is
+// able to read.
+func aclFilterExpr(ctx context.Context,
+	resourceID string,
+	existing int,
+) (string, []any, error) {
+	if aclExempt(ctx) {
+		return "", nil, nil
+	}
+
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return `(NOT ` + aclRestrictedExpr(resourceID) + `
+		OR ` + aclGrantedExpr(resourceID, existing+1, existing+2) + `)`,
+		[]any{userID, aclPermissions(PermissionRead)}, nil
+}
+
+// checkAccess checks that the current user has a permission on a resource by
+// ID. Resources which the user is unable to read are reported as not found.
+// The result reports whether the resource has any ACL entries.
+func (s *Service) checkAccess(ctx context.Context,
+	id, permission string,
+) (bool, error) {
+	if aclExempt(ctx) {
+		return false, nil
+	}
+
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if !request.ValidResourceID(id) {
+		return false, errors.New(errors.ErrNotFound,
+			"resource not found",
+			"id", id)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT ` + aclRestrictedExpr("$1::UUID") + ` AS restricted,
+			` + aclGrantedExpr("$1::UUID", 2, 3) + ` AS readable,
+			` + aclGrantedExpr("$1::UUID", 2, 4) + ` AS permitted`,
+		Params: []any{
+			id,
+			userID,
+			aclPermissions(PermissionRead),
+			aclPermissions(permission),
+		},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	restricted, readable, permitted := false, false, false
+
+	if err := row.Scan(&restricted, &readable, &permitted); err != nil {
+		return false, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource access row",
+			"id", id)
+	}
+
+	switch {
+	case restricted && !readable:
+		return true, errors.New(errors.ErrNotFound,
+			"resource not found",
+			"id", id)
+	case restricted && !permitted:
+		return true, errors.New(errors.ErrForbidden,
+			"unable to access resource: "+permission+
+				" permission required",
+			"id", id)
+	}
+
+	return restricted, nil
+}
+
+// checkACLAccess checks that the current user is able to manage the ACL of a
+// resource by ID. Only users exempt from resource ACLs are able to restrict an
+// unrestricted resource. Otherwise, the admin permission is required.
+func (s *Service) checkACLAccess(ctx context.Context, id string) error {
+	if aclExempt(ctx) {
+		return nil
+	}
+
+	restricted, err := s.checkAccess(ctx, id, PermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	if !restricted {
+		return errors.New(errors.ErrForbidden,
+			"unable to restrict resource: "+request.ScopeResourcesAdmin+
+				" scope required",
+			"id", id)
+	}
+
+	return nil
+}
+
+// GetResourceACL retrieves the ACL entries of a resource by ID.
+func (s *Service) GetResourceACL(ctx context.Context,
+	id string,
+) ([]*ACL, error) {
+	if err := s.checkACLAccess(ctx, id); err != nil {
+		return nil, err
+	}
+
+	base := sqldb.SelectFields("resource_acl", aclFields, nil, nil) +
+		`WHERE resource_acl.resource_id = $1
+		ORDER BY resource_acl.principal_type, resource_acl.principal_id`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: aclFields,
+		Params: []any{id},
+	})
+
+	q.Limit = q.Config.DBMaxSize()
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	defer rows.Close()
+
+	res := []*ACL{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		a := &ACL{}
+
+		if err := rows.Scan(a.ScanDest()...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select resource acl row",
+				"id", id)
+		}
+
+		res = append(res, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource acl rows",
+			"id", id)
+	}
+
+	return res, nil
+}
+
+// SetResourceACL grants a permission on a resource to a principal, replacing
+// any permission previously granted to it.
+func (s *Service) SetResourceACL(ctx context.Context,
+	v *ACL,
+) (*ACL, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing acl",
+			"acl", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkACLAccess(ctx, v.ResourceID.Value); err != nil {
+		return nil, err
+	}
+
+	base := `INSERT INTO resource_acl () VALUES ()
+		ON CONFLICT (account_id, resource_id, principal_type, principal_id)
+		DO UPDATE SET` +
+		sqldb.ReturningFields("resource_acl", aclFields, nil)
+
+	sets, params := []string{}, []any{}
+
+	request.SetField("resource_id", v.ResourceID, &sets, &params)
+	request.SetField("principal_type", v.PrincipalType, &sets, &params)
+	request.SetField("principal_id", v.PrincipalID, &sets, &params)
+	request.SetField("permission", v.Permission, &sets, &params)
+	request.SetField("created_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+	request.SetField("updated_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryInsert,
+		Base:   base,
+		Fields: aclFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "acl", v)
+	}
+
+	a := &ACL{}
+
+	if err := row.Scan(a.ScanDest()...); err != nil {
+		if errors.ErrorHas(err, "resource_acl_account_id_resource_id_fkey") {
+			return nil, errors.New(errors.ErrNotFound,
+				"resource not found",
+				"acl", v)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to insert resource acl row",
+			"acl", v)
+	}
+
+	return a, nil
+}
+
+// DeleteResourceACL removes the permission granted on a resource to a
+// principal. Once all of its ACL entries are removed, a resource is accessible
+// to all users of the account.
+func (s *Service) DeleteResourceACL(ctx context.Context,
+	v *ACL,
+) error {
+	if v == nil {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing acl",
+			"acl", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return err
+	}
+
+	if err := s.checkACLAccess(ctx, v.ResourceID.Value); err != nil {
+		return err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryDelete,
+		Base: `DELETE FROM resource_acl
+			WHERE resource_acl.resource_id = $1
+			AND resource_acl.principal_type = $2
+			AND resource_acl.principal_id = $3`,
+		Fields: aclFields,
+		Params: []any{
+			v.ResourceID.Value,
+			v.PrincipalType.Value,
+			v.PrincipalID.Value,
+		},
+	})
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "", "acl", v)
+	}
+
+	if n := res.RowsAffected(); n == 0 {
+		return errors.New(errors.ErrNotFound, "resource acl not found",
+			"acl", v)
+	}
+
+	return nil
+}
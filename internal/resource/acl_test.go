@@ -0,0 +1,292 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+var TestACL = resource.ACL{
+	ResourceID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestUUID,
+	},
+	PrincipalType: request.FieldString{
+		Set: true, Valid: true,
+		Value: resource.PrincipalGroup,
+	},
+	PrincipalID: request.FieldString{
+		Set: true, Valid: true,
+		Value: "test-group",
+	},
+	Permission: request.FieldString{
+		Set: true, Valid: true,
+		Value: resource.PermissionWrite,
+	},
+}
+
+func mockACLRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"resource_id",
+		"principal_type",
+		"principal_id",
+		"permission",
+		"created_at",
+		"created_by",
+		"updated_at",
+		"updated_by",
+	}).AddRow(
+		TestACL.ResourceID.Value,
+		TestACL.PrincipalType.Value,
+		TestACL.PrincipalID.Value,
+		TestACL.Permission.Value,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+}
+
+func mockResourceAccess(mock pgxmock.PgxCommonIface) {
+	mockResourceAccessRows(mock, false, false, false)
+}
+
+func mockResourceAccessRows(mock pgxmock.PgxCommonIface,
+	restricted, readable, permitted bool,
+) {
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM resource_acl").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{
+			"restricted", "readable", "permitted",
+		}).AddRow(restricted, readable, permitted))
+}
+
+func TestACLValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		v    func(a *resource.ACL)
+		err  bool
+	}{{
+		name: "valid",
+		v:    func(a *resource.ACL) {},
+	}, {
+		name: "invalid resource_id",
+		v: func(a *resource.ACL) {
+			a.ResourceID.Value = "invalid"
+		},
+		err: true,
+	}, {
+		name: "invalid principal_type",
+		v: func(a *resource.ACL) {
+			a.PrincipalType.Value = "invalid"
+		},
+		err: true,
+	}, {
+		name: "invalid principal_id",
+		v: func(a *resource.ACL) {
+			a.PrincipalType.Value = resource.PrincipalUser
+			a.PrincipalID.Value = ""
+		},
+		err: true,
+	}, {
+		name: "invalid permission",
+		v: func(a *resource.ACL) {
+			a.Permission.Value = "invalid"
+		},
+		err: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := TestACL
+
+			tt.v(&v)
+
+			if err := v.ValidateCreate(); (err != nil) != tt.err {
+				t.Errorf("Expected error: %v, got: %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestGetResourceACL(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource_acl").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockACLRows(mock))
+
+	res, err := svc.GetResourceACL(ctx, TestUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 {
+		t.Fatalf("Expected length: 1, got: %v", len(res))
+	}
+
+	if res[0].PrincipalID.Value != TestACL.PrincipalID.Value {
+		t.Errorf("Expected principal_id: %v, got: %v",
+			TestACL.PrincipalID.Value, res[0].PrincipalID.Value)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestSetResourceACL(t *testing.T) {
+	t.Parallel()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	args := make([]any, 6)
+
+	for i := 0; i < 6; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("INSERT INTO resource_acl (.+) ON CONFLICT").
+		WithArgs(args...).WillReturnRows(mockACLRows(mock))
+
+	v := TestACL
+
+	res, err := svc.SetResourceACL(mockAdminAuthContext(), &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Permission.Value != TestACL.Permission.Value {
+		t.Errorf("Expected permission: %v, got: %v",
+			TestACL.Permission.Value, res.Permission.Value)
+	}
+
+	mockResourceAccess(mock)
+
+	if _, err := svc.SetResourceACL(mockAuthContext(),
+		&v); !errors.Has(err, errors.ErrForbidden) {
+		t.Errorf("Expected forbidden error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestDeleteResourceACL(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM resource_acl").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	mock.ExpectCommit()
+
+	v := TestACL
+
+	if err := svc.DeleteResourceACL(ctx, &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestResourceAccess(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockResourceAccessRows(mock, true, false, false)
+
+	if _, err := svc.GetResource(ctx, TestUUID,
+		nil); !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	mockResourceAccessRows(mock, true, true, false)
+
+	if err := svc.DeleteResource(ctx,
+		TestUUID); !errors.Has(err, errors.ErrForbidden) {
+		t.Errorf("Expected forbidden error, got: %v", err)
+	}
+
+	mockResourceAccessRows(mock, true, false, false)
+
+	if _, err := svc.GetResourceTags(ctx,
+		TestUUID); !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	mockResourceAccessRows(mock, true, true, false)
+
+	if _, err := svc.AddResourceTags(ctx, TestUUID,
+		[]string{TestTag}); !errors.Has(err, errors.ErrForbidden) {
+		t.Errorf("Expected forbidden error, got: %v", err)
+	}
+
+	mockResourceAccessRows(mock, true, true, false)
+
+	if err := svc.DeleteResourceTags(ctx, TestUUID,
+		[]string{TestTag}); !errors.Has(err, errors.ErrForbidden) {
+		t.Errorf("Expected forbidden error, got: %v", err)
+	}
+
+	mockResourceAccessRows(mock, true, false, false)
+
+	if _, err := svc.PreviewClearCondition(ctx, TestUUID,
+		"and(status:ok)", 10); !errors.Has(err, errors.ErrNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,724 @@
+package resource
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Agent values represent external agents reporting resource data.
+type Agent struct {
+	AgentID       request.FieldString      `json:"agent_id"       yaml:"agent_id"`
+	Name          request.FieldString      `json:"name"           yaml:"name"`
+	Version       request.FieldString      `json:"version"        yaml:"version"`
+	Status        request.FieldString      `json:"status"         yaml:"status"`
+	StatusData    request.FieldJSON        `json:"status_data"    yaml:"status_data"`
+	Resources     request.FieldStringArray `json:"resources"      yaml:"resources"`
+	Data          request.FieldJSON        `json:"data"           yaml:"data"`
+	LastSeenAt    request.FieldTime        `json:"last_seen_at"   yaml:"last_seen_at"`
+	ConfigVersion request.FieldString      `json:"config_version" yaml:"config_version"`
+	CreatedAt     request.FieldTime        `json:"created_at"     yaml:"created_at"`
+	CreatedBy     request.FieldString      `json:"created_by"     yaml:"created_by"`
+	UpdatedAt     request.FieldTime        `json:"updated_at"     yaml:"updated_at"`
+	UpdatedBy     request.FieldString      `json:"updated_by"     yaml:"updated_by"`
+}
+
+// Validate checks that the value contains valid data.
+func (a *Agent) Validate() error {
+	if a.AgentID.Set {
+		if !a.AgentID.Valid {
+			return errors.New(errors.ErrInvalidRequest,
+				"agent_id must not be null",
+				"agent", a)
+		}
+
+		if !request.ValidAgentID(a.AgentID.Value) {
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid agent_id",
+				"agent", a)
+		}
+	}
+
+	if a.Name.Set && !a.Name.Valid {
+		return errors.New(errors.ErrInvalidRequest,
+			"name must not be null",
+			"agent", a)
+	}
+
+	if a.Resources.Set {
+		if !a.Resources.Valid {
+			return errors.New(errors.ErrInvalidRequest,
+				"resources must not be null",
+				"agent", a)
+		}
+
+		ids := make([]string, 0, len(a.Resources.Value))
+
+		for _, id := range a.Resources.Value {
+			u, err := uuid.Parse(id)
+			if err != nil {
+				return errors.New(errors.ErrInvalidRequest,
+					"invalid resources: invalid resource_id: "+id,
+					"agent", a)
+			}
+
+			ids = append(ids, u.String())
+		}
+
+		// Resource IDs are stored in their canonical form, so that they can be
+		// matched against the resources table.
+		a.Resources.Value = ids
+	}
+
+	if a.Status.Set {
+		if !a.Status.Valid {
+			return errors.New(errors.ErrInvalidRequest,
+				"status must not be null",
+				"agent", a)
+		}
+
+		switch a.Status.Value {
+		case request.StatusActive, request.StatusInactive,
+			request.StatusDisconnected:
+		default:
+			return errors.New(errors.ErrInvalidRequest,
+				"invalid status",
+				"agent", a)
+		}
+	}
+
+	return nil
+}
+
+// ValidateCreate checks that the value contains valid data for registration.
+func (a *Agent) ValidateCreate() error {
+	if !a.AgentID.Set {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing agent_id",
+			"agent", a)
+	}
+
+	if !a.Name.Set {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing name",
+			"agent", a)
+	}
+
+	return a.Validate()
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (a *Agent) ScanDest(options sqldb.FieldOptions) []any {
+	return sqldb.ScanFields("agent", agentFields, options,
+		map[string]any{
+			"agent_id":       &a.AgentID,
+			"name":           &a.Name,
+			"version":        &a.Version,
+			"status":         &a.Status,
+			"status_data":    &a.StatusData,
+			"resources":      &a.Resources,
+			"data":           &a.Data,
+			"last_seen_at":   &a.LastSeenAt,
+			"config_version": &a.ConfigVersion,
+			"created_at":     &a.CreatedAt,
+			"created_by":     &a.CreatedBy,
+			"updated_at":     &a.UpdatedAt,
+			"updated_by":     &a.UpdatedBy,
+		})
+}
+
+// agentFields contain the search fields for agents.
+var agentFields = []*sqldb.Field{{
+	Name:   "agent_key",
+	Type:   sqldb.FieldInt,
+	Table:  "agent",
+	Hidden: true,
+}, {
+	Name:  "agent_id",
+	Type:  sqldb.FieldString,
+	Table: "agent",
+}, {
+	Name:    "name",
+	Type:    sqldb.FieldString,
+	Table:   "agent",
+	Primary: true,
+}, {
+	Name:  "version",
+	Type:  sqldb.FieldString,
+	Table: "agent",
+}, {
+	Name:  "status",
+	Type:  sqldb.FieldString,
+	Table: "agent",
+}, {
+	Name:  "status_data",
+	Type:  sqldb.FieldJSON,
+	Table: "agent",
+}, {
+	Name:  "resources",
+	Type:  sqldb.FieldArray,
+	Table: "agent",
+}, {
+	Name:  "data",
+	Type:  sqldb.FieldJSON,
+	Table: "agent",
+}, {
+	Name:  "last_seen_at",
+	Type:  sqldb.FieldTime,
+	Table: "agent",
+}, {
+	Name:  "config_version",
+	Type:  sqldb.FieldString,
+	Table: "agent",
+}, {
+	Name:   "created_at",
+	Type:   sqldb.FieldTime,
+	Option: "user_details",
+	Table:  "agent",
+}, {
+	Name:   "created_by",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "created_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "created_by",
+	Expr:   "created_by_user.user_id",
+}, {
+	Name:   "updated_at",
+	Type:   sqldb.FieldTime,
+	Option: "user_details",
+	Table:  "agent",
+}, {
+	Name:   "updated_by",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "updated_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "updated_by",
+	Expr:   "updated_by_user.user_id",
+}}
+
+// GetAgents retrieves agents based on a search query.
+func (s *Service) GetAgents(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*Agent, []*sqldb.SummaryData, error) {
+	if err := options.ValidateFields(agentFields); err != nil {
+		return nil, nil, err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   sqldb.SelectFields("agent", agentFields, query, options),
+		Search: query,
+		Fields: agentFields,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res, sum := []*Agent{}, []*sqldb.SummaryData{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, nil, errors.Context(ctx)
+		default:
+		}
+
+		if query != nil && query.Summary != "" {
+			sr := &sqldb.SummaryData{}
+
+			if err = rows.Scan(sr.ScanDest(agentFields,
+				query)...); err != nil {
+				return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+					"unable to select agent summary row",
+					"search", query)
+			}
+
+			sum = append(sum, sr)
+
+			continue
+		}
+
+		a := &Agent{}
+
+		if err = rows.Scan(a.ScanDest(options)...); err != nil {
+			return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select agent row",
+				"search", query)
+		}
+
+		res = append(res, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select agent rows",
+			"search", query)
+	}
+
+	return res, sum, nil
+}
+
+// GetAgent retrieves a single agent by ID.
+func (s *Service) GetAgent(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*Agent, error) {
+	if err := options.ValidateFields(agentFields); err != nil {
+		return nil, err
+	}
+
+	base := sqldb.SelectFields("agent", agentFields, nil, options) +
+		`WHERE agent.agent_id = $1`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: agentFields,
+		Params: []any{id},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	a := &Agent{}
+
+	if err := row.Scan(a.ScanDest(options)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"agent not found",
+				"id", id)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select agent row",
+			"id", id)
+	}
+
+	return a, nil
+}
+
+// RegisterAgent registers an agent, or updates the registration of an existing
+// agent with the same ID. Registration counts as a heartbeat. Disabled agents
+// are not able to register again until they are enabled.
+func (s *Service) RegisterAgent(ctx context.Context,
+	v *Agent,
+) (*Agent, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing agent",
+			"agent", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	if !v.Resources.Set {
+		v.Resources = request.FieldStringArray{
+			Set: true, Valid: true, Value: []string{},
+		}
+	}
+
+	base := `INSERT INTO agent () VALUES ()
+		ON CONFLICT (account_id, agent_id) DO UPDATE SET
+		WHERE agent.status <> '` + request.StatusInactive + `'` +
+		sqldb.ReturningFields("agent", agentFields, nil)
+
+	sets, params := []string{}, []any{}
+
+	request.SetField("agent_id", v.AgentID, &sets, &params)
+	request.SetField("name", v.Name, &sets, &params)
+	request.SetField("version", v.Version, &sets, &params)
+	request.SetField("status", request.FieldString{
+		Set: true, Valid: true, Value: request.StatusActive,
+	}, &sets, &params)
+	request.SetField("status_data", v.StatusData, &sets, &params)
+	request.SetField("resources", v.Resources, &sets, &params)
+	request.SetField("data", v.Data, &sets, &params)
+	request.SetField("last_seen_at", request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}, &sets, &params)
+	request.SetField("created_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+	request.SetField("updated_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryInsert,
+		Base:   base,
+		Fields: agentFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "agent", v)
+	}
+
+	a := &Agent{}
+
+	if err := row.Scan(a.ScanDest(nil)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrForbidden,
+				"agent is disabled",
+				"agent", v)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to insert agent row",
+			"agent", v)
+	}
+
+	return a, nil
+}
+
+// HeartbeatAgent records a heartbeat from an agent, marking it as active. The
+// version, status data, and reported resources of the agent are also updated,
+// if they are provided.
+func (s *Service) HeartbeatAgent(ctx context.Context,
+	v *Agent,
+) (*Agent, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing agent",
+			"agent", v)
+	}
+
+	if !v.AgentID.Set || !v.AgentID.Valid {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing agent_id",
+			"agent", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	base := `UPDATE agent SET
+		WHERE agent.agent_id = $1
+			AND agent.status <> '` + request.StatusInactive + `'` +
+		sqldb.ReturningFields("agent", agentFields, nil)
+
+	sets, params := []string{}, []any{v.AgentID.Value}
+
+	request.SetField("version", v.Version, &sets, &params)
+	request.SetField("status", request.FieldString{
+		Set: true, Valid: true, Value: request.StatusActive,
+	}, &sets, &params)
+	request.SetField("status_data", v.StatusData, &sets, &params)
+	request.SetField("resources", v.Resources, &sets, &params)
+	request.SetField("last_seen_at", request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryUpdate,
+		Base:   base,
+		Fields: agentFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "agent", v)
+	}
+
+	a := &Agent{}
+
+	if err := row.Scan(a.ScanDest(nil)...); err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to update agent row",
+				"agent", v)
+		}
+
+		// No row is updated either if the agent does not exist, or if it has
+		// been disabled.
+		if _, err := s.GetAgent(ctx, v.AgentID.Value, nil); err != nil {
+			return nil, err
+		}
+
+		return nil, errors.New(errors.ErrForbidden,
+			"agent is disabled",
+			"agent", v)
+	}
+
+	return a, nil
+}
+
+// DisableAgent disables an agent. Heartbeats from disabled agents are rejected,
+// and the resources they report for become stale, unless reported by another
+// agent.
+func (s *Service) DisableAgent(ctx context.Context,
+	id string,
+) (*Agent, error) {
+	return s.setAgentStatus(ctx, id, request.StatusInactive)
+}
+
+// EnableAgent enables a disabled agent. The agent remains disconnected until
+// its next heartbeat is received.
+func (s *Service) EnableAgent(ctx context.Context,
+	id string,
+) (*Agent, error) {
+	return s.setAgentStatus(ctx, id, request.StatusDisconnected)
+}
+
+// setAgentStatus updates the status of an agent.
+func (s *Service) setAgentStatus(ctx context.Context,
+	id, status string,
+) (*Agent, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	base := `UPDATE agent SET
+		WHERE agent.agent_id = $1` +
+		sqldb.ReturningFields("agent", agentFields, nil)
+
+	sets, params := []string{}, []any{id}
+
+	request.SetField("status", request.FieldString{
+		Set: true, Valid: true, Value: status,
+	}, &sets, &params)
+	request.SetField("updated_at", request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}, &sets, &params)
+
+	if userID == request.SystemUser {
+		request.SetField("updated_by", request.FieldString{
+			Set: true, Valid: false,
+		}, &sets, &params)
+	} else {
+		request.SetField("updated_by", request.FieldString{
+			Set: true, Valid: true, Value: userID,
+		}, &sets, &params)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryUpdate,
+		Base:   base,
+		Fields: agentFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id,
+			"status", status)
+	}
+
+	a := &Agent{}
+
+	if err := row.Scan(a.ScanDest(nil)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"agent not found",
+				"id", id)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update agent row",
+			"id", id,
+			"status", status)
+	}
+
+	return a, nil
+}
+
+// DeleteAgent deletes an agent.
+func (s *Service) DeleteAgent(ctx context.Context,
+	id string,
+) error {
+	base := `DELETE FROM agent
+		WHERE agent.agent_id = $1`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryDelete,
+		Base:   base,
+		Fields: agentFields,
+		Params: []any{id},
+	})
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	if n := res.RowsAffected(); n == 0 {
+		return errors.New(errors.ErrNotFound, "agent not found",
+			"id", id)
+	}
+
+	return nil
+}
+
+// UpdateStaleAgents marks active agents which have not sent a heartbeat within
+// the configured period as disconnected. Active resources reported for only by
+// agents which are not active are then marked as stale, and stale resources
+// with an active agent, or no agent at all, are marked as active again.
+func (s *Service) UpdateStaleAgents(ctx context.Context) error {
+	tx, err := sqldb.BeginTx(ctx, s.db, pgx.TxOptions{})
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to begin stale agents transaction")
+	}
+
+	before := time.Now().Add(0 - s.cfg.AgentStaleAfter()).Unix()
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Tx:   tx,
+		Type: sqldb.QueryExec,
+		Base: `UPDATE agent SET
+				status = '` + request.StatusDisconnected + `',
+				updated_at = CURRENT_TIMESTAMP
+			WHERE agent.status = '` + request.StatusActive + `'
+				AND agent.last_seen_at < TO_TIMESTAMP($1)`,
+		Params: []any{before},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return s.closeTx(ctx, tx, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update stale agent rows",
+			"before", before))
+	}
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Tx:   tx,
+		Type: sqldb.QueryExec,
+		Base: `WITH r AS (
+				SELECT resource.resource_key,
+					EXISTS (SELECT 1 FROM agent
+						WHERE agent.resources @> ARRAY[resource.resource_id::TEXT])
+					AND NOT EXISTS (SELECT 1 FROM agent
+						WHERE agent.status = '` + request.StatusActive + `'
+							AND agent.resources @>
+								ARRAY[resource.resource_id::TEXT]) AS stale
+				FROM resource
+				WHERE resource.status IN ('` + request.StatusActive + `', '` +
+			request.StatusStale + `')
+			)
+			UPDATE resource SET
+				status = CASE WHEN r.stale THEN '` + request.StatusStale + `'
+					ELSE '` + request.StatusActive + `' END,
+				updated_at = CURRENT_TIMESTAMP
+			FROM r
+			WHERE resource.resource_key = r.resource_key
+				AND r.stale <> (resource.status = '` + request.StatusStale + `')
+			RETURNING resource.resource_id`,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return s.closeTx(ctx, tx, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update stale resource rows"))
+	}
+
+	ids := []string{}
+
+	for rows.Next() {
+		id := ""
+
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+
+			return s.closeTx(ctx, tx, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select stale resource row"))
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		rows.Close()
+
+		return s.closeTx(ctx, tx, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select stale resource rows"))
+	}
+
+	rows.Close()
+
+	if err := s.closeTx(ctx, tx, nil); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		s.deleteResourceCache(ctx, id)
+	}
+
+	return nil
+}
+
+// updateAgents periodically updates the status of stale agents, and of the
+// resources they report for, in all accounts.
+func (s *Service) updateAgents(ctx context.Context) {
+	tick := time.NewTimer(s.cfg.AgentCheckInterval())
+
+	for {
+		select {
+		case <-ctx.Done():
+			tick.Stop()
+
+			return
+		case <-tick.C:
+			accounts, err := s.getAllAccounts(ctx)
+			if err != nil {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to get accounts to update agents",
+					"error", err)
+			}
+
+			for _, aID := range accounts {
+				actx := request.Elevate(ctx, aID)
+
+				if err := s.UpdateStaleAgents(actx); err != nil {
+					s.log.Log(actx, logger.LvlError,
+						"unable to update stale agents",
+						"error", err)
+				}
+			}
+		}
+
+		tick = time.NewTimer(s.cfg.AgentCheckInterval())
+	}
+}
@@ -0,0 +1,332 @@
+package resource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+// AgentResource values contain the rules of a resource which an agent acts on.
+type AgentResource struct {
+	ResourceID      request.FieldString `json:"resource_id"      yaml:"resource_id"`
+	Name            request.FieldString `json:"name"             yaml:"name"`
+	Version         request.FieldString `json:"version"          yaml:"version"`
+	KeyField        request.FieldString `json:"key_field"        yaml:"key_field"`
+	KeyRegex        request.FieldString `json:"key_regex"        yaml:"key_regex"`
+	ClearCondition  request.FieldString `json:"clear_condition"  yaml:"clear_condition"`
+	ClearAfter      request.FieldInt64  `json:"clear_after"      yaml:"clear_after"`
+	ClearDelay      request.FieldInt64  `json:"clear_delay"      yaml:"clear_delay"`
+	DuplicatePolicy request.FieldString `json:"duplicate_policy" yaml:"duplicate_policy"`
+	KeyStrategy     request.FieldString `json:"key_strategy"     yaml:"key_strategy"`
+}
+
+// AgentConfig values contain the configuration distributed to an agent. The
+// version identifies the content of the configuration, and changes whenever
+// the rules of any of its resources change. Pinned configurations are not
+// changed until the agent is unpinned.
+type AgentConfig struct {
+	AgentID   string           `json:"agent_id"  yaml:"agent_id"`
+	Version   string           `json:"version"   yaml:"version"`
+	Pinned    bool             `json:"pinned"    yaml:"pinned"`
+	Resources []*AgentResource `json:"resources" yaml:"resources"`
+}
+
+// NewAgentConfig creates a new, unpinned, agent configuration containing the
+// specified resources. The version is derived from the content of the
+// resources, which should be sorted by ID.
+func NewAgentConfig(id string,
+	resources []*AgentResource,
+) (*AgentConfig, error) {
+	if resources == nil {
+		resources = []*AgentResource{}
+	}
+
+	buf, err := json.Marshal(resources)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to encode agent config",
+			"id", id)
+	}
+
+	sum := sha256.Sum256(buf)
+
+	return &AgentConfig{
+		AgentID:   id,
+		Version:   hex.EncodeToString(sum[:16]),
+		Resources: resources,
+	}, nil
+}
+
+// getAgentConfigRow retrieves the reported resources and pinned configuration,
+// if any, of an agent. Disabled agents are not able to retrieve configuration.
+func (s *Service) getAgentConfigRow(ctx context.Context,
+	id string,
+) ([]string, []byte, error) {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+				agent.status,
+				agent.resources,
+				agent.config
+			FROM agent
+			WHERE agent.agent_id = $1`,
+		Params: []any{id},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id)
+	}
+
+	status, resources, pinned := "", []string{}, []byte(nil)
+
+	if err := row.Scan(&status, &resources, &pinned); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil, errors.New(errors.ErrNotFound,
+				"agent not found",
+				"id", id)
+		}
+
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select agent config row",
+			"id", id)
+	}
+
+	if status == request.StatusInactive {
+		return nil, nil, errors.New(errors.ErrForbidden,
+			"agent is disabled",
+			"id", id)
+	}
+
+	return resources, pinned, nil
+}
+
+// currentAgentConfig builds the current configuration for the resources
+// reported by an agent. Inactive resources are not included.
+func (s *Service) currentAgentConfig(ctx context.Context,
+	id string,
+	resources []string,
+) (*AgentConfig, error) {
+	list := []*AgentResource{}
+
+	if len(resources) > 0 {
+		q := sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   s.db,
+			Type: sqldb.QuerySelect,
+			Base: `SELECT
+					resource.resource_id,
+					resource.name,
+					resource.version,
+					resource.key_field,
+					resource.key_regex,
+					resource.clear_condition,
+					resource.clear_after,
+					resource.clear_delay,
+					resource.duplicate_policy,
+					resource.key_strategy
+				FROM resource
+				WHERE resource.resource_id::TEXT = ANY($1::TEXT[])
+					AND resource.status <> '` + request.StatusInactive + `'
+				ORDER BY resource.resource_id
+				LIMIT ALL`,
+			Params: []any{resources},
+		})
+
+		rows, err := q.Query(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase, "",
+				"id", id)
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			select {
+			case <-ctx.Done():
+				return nil, errors.Context(ctx)
+			default:
+			}
+
+			r := &AgentResource{}
+
+			if err := rows.Scan(&r.ResourceID, &r.Name, &r.Version,
+				&r.KeyField, &r.KeyRegex, &r.ClearCondition, &r.ClearAfter,
+				&r.ClearDelay, &r.DuplicatePolicy, &r.KeyStrategy); err != nil {
+				return nil, errors.Wrap(err, errors.ErrDatabase,
+					"unable to select agent config resource row",
+					"id", id)
+			}
+
+			list = append(list, r)
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select agent config resource rows",
+				"id", id)
+		}
+	}
+
+	return NewAgentConfig(id, list)
+}
+
+// GetAgentConfig retrieves the configuration an agent should act on. This is
+// the pinned configuration of the agent, if it has been pinned, or otherwise
+// the current rules of the resources it reports for.
+func (s *Service) GetAgentConfig(ctx context.Context,
+	id string,
+) (*AgentConfig, error) {
+	resources, pinned, err := s.getAgentConfigRow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if pinned != nil {
+		res := &AgentConfig{}
+
+		if err := json.Unmarshal(pinned, res); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to decode pinned agent config",
+				"id", id)
+		}
+
+		res.AgentID = id
+		res.Pinned = true
+
+		return res, nil
+	}
+
+	return s.currentAgentConfig(ctx, id, resources)
+}
+
+// PinAgentConfig pins an agent to the current version of its configuration,
+// so that later changes to the rules of its resources are not distributed to
+// it until it is unpinned. If a version is specified, it must match the current
+// version, so that an agent is not pinned to rules which have changed since
+// they were reviewed.
+func (s *Service) PinAgentConfig(ctx context.Context,
+	id, version string,
+) (*Agent, error) {
+	resources, _, err := s.getAgentConfigRow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.currentAgentConfig(ctx, id, resources)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != "" && version != cfg.Version {
+		return nil, errors.New(errors.ErrConflict,
+			"agent config version has changed",
+			"id", id,
+			"version", version,
+			"current_version", cfg.Version)
+	}
+
+	buf, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to encode agent config",
+			"id", id)
+	}
+
+	return s.setAgentConfig(ctx, id, cfg.Version, buf)
+}
+
+// UnpinAgentConfig unpins the configuration of an agent, so that the current
+// rules of its resources are distributed to it again.
+func (s *Service) UnpinAgentConfig(ctx context.Context,
+	id string,
+) (*Agent, error) {
+	return s.setAgentConfig(ctx, id, "", nil)
+}
+
+// setAgentConfig updates the pinned configuration of an agent. An empty version
+// unpins the agent.
+func (s *Service) setAgentConfig(ctx context.Context,
+	id, version string,
+	config []byte,
+) (*Agent, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	base := `UPDATE agent SET
+		WHERE agent.agent_id = $1` +
+		sqldb.ReturningFields("agent", agentFields, nil)
+
+	sets, params := []string{}, []any{id}
+
+	request.SetField("config_version", request.FieldString{
+		Set: true, Valid: version != "", Value: version,
+	}, &sets, &params)
+
+	sets = append(sets, "config")
+
+	if config != nil {
+		params = append(params, config)
+	} else {
+		params = append(params, nil)
+	}
+
+	request.SetField("updated_at", request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}, &sets, &params)
+
+	if userID == request.SystemUser {
+		request.SetField("updated_by", request.FieldString{
+			Set: true, Valid: false,
+		}, &sets, &params)
+	} else {
+		request.SetField("updated_by", request.FieldString{
+			Set: true, Valid: true, Value: userID,
+		}, &sets, &params)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryUpdate,
+		Base:   base,
+		Fields: agentFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id,
+			"version", version)
+	}
+
+	a := &Agent{}
+
+	if err := row.Scan(a.ScanDest(nil)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"agent not found",
+				"id", id)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update agent config row",
+			"id", id,
+			"version", version)
+	}
+
+	return a, nil
+}
@@ -0,0 +1,189 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func mockAgentConfigRows(mock pgxmock.PgxCommonIface,
+	pinned []byte,
+) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"status",
+		"resources",
+		"config",
+	}).AddRow(
+		request.StatusActive,
+		[]string{TestUUID},
+		pinned,
+	)
+}
+
+func mockAgentResourceRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"resource_id",
+		"name",
+		"version",
+		"key_field",
+		"key_regex",
+		"clear_condition",
+		"clear_after",
+		"clear_delay",
+		"duplicate_policy",
+		"key_strategy",
+	}).AddRow(
+		TestResource.ResourceID.Value,
+		TestResource.Name.Value,
+		TestResource.Version.Value,
+		TestResource.KeyField.Value,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+}
+
+func TestGetAgentConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM agent").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAgentConfigRows(mock, nil))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAgentResourceRows(mock))
+
+	res, err := svc.GetAgentConfig(ctx, TestAgent.AgentID.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Pinned {
+		t.Error("Expected unpinned config")
+	}
+
+	if len(res.Resources) != 1 ||
+		res.Resources[0].ResourceID.Value != TestResource.ResourceID.Value {
+		t.Errorf("Expected resources: [%v], got: %+v",
+			TestResource.ResourceID.Value, res.Resources)
+	}
+
+	exp, err := resource.NewAgentConfig(TestAgent.AgentID.Value,
+		res.Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Version == "" || res.Version != exp.Version {
+		t.Errorf("Expected version: %v, got: %v", exp.Version, res.Version)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM agent").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAgentConfigRows(mock,
+			[]byte(`{"version":"test","resources":[]}`)))
+
+	res, err = svc.GetAgentConfig(ctx, TestAgent.AgentID.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Pinned || res.Version != "test" {
+		t.Errorf("Expected pinned version: test, got: %v, %v",
+			res.Pinned, res.Version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestPinAgentConfig(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM agent").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAgentConfigRows(mock, nil))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAgentResourceRows(mock))
+
+	if _, err := svc.PinAgentConfig(ctx, TestAgent.AgentID.Value,
+		"invalid"); !errors.Has(err, errors.ErrConflict) {
+		t.Errorf("Expected conflict error, got: %v", err)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM agent").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAgentConfigRows(mock, nil))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAgentResourceRows(mock))
+
+	mockTransaction(mock)
+
+	args := make([]any, 5)
+
+	for i := 0; i < 5; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE agent").
+		WithArgs(args...).WillReturnRows(mockAgentRows(mock))
+
+	res, err := svc.PinAgentConfig(ctx, TestAgent.AgentID.Value, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.AgentID.Value != TestAgent.AgentID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestAgent.AgentID.Value, res.AgentID.Value)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+var TestAgent = resource.Agent{
+	AgentID: request.FieldString{
+		Set: true, Valid: true,
+		Value: "test-agent",
+	},
+	Name: request.FieldString{
+		Set: true, Valid: true,
+		Value: "testName",
+	},
+	Version: request.FieldString{
+		Set: true, Valid: true,
+		Value: "1",
+	},
+	Status: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.StatusActive,
+	},
+	Resources: request.FieldStringArray{
+		Set: true, Valid: true,
+		Value: []string{TestUUID},
+	},
+	LastSeenAt: request.FieldTime{
+		Set: true, Valid: true,
+		Value: 1,
+	},
+}
+
+func mockAgentRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"agent_id",
+		"name",
+		"version",
+		"status",
+		"status_data",
+		"resources",
+		"data",
+		"last_seen_at",
+		"config_version",
+	}).AddRow(
+		TestAgent.AgentID.Value,
+		TestAgent.Name.Value,
+		TestAgent.Version.Value,
+		TestAgent.Status.Value,
+		nil,
+		nil,
+		nil,
+		TestAgent.LastSeenAt.Value,
+		nil,
+	)
+}
+
+func TestGetAgent(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM agent").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockAgentRows(mock))
+
+	res, err := svc.GetAgent(ctx, TestAgent.AgentID.Value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.AgentID.Value != TestAgent.AgentID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestAgent.AgentID.Value, res.AgentID.Value)
+	}
+
+	if res.LastSeenAt.Value != TestAgent.LastSeenAt.Value {
+		t.Errorf("Expected last_seen_at: %v, got: %v",
+			TestAgent.LastSeenAt.Value, res.LastSeenAt.Value)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestRegisterAgent(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	args := make([]any, 8)
+
+	for i := 0; i < 8; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("INSERT INTO agent (.+) ON CONFLICT").
+		WithArgs(args...).WillReturnRows(mockAgentRows(mock))
+
+	v := TestAgent
+
+	res, err := svc.RegisterAgent(ctx, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.AgentID.Value != TestAgent.AgentID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestAgent.AgentID.Value, res.AgentID.Value)
+	}
+
+	if _, err := svc.RegisterAgent(ctx, &resource.Agent{
+		AgentID: request.FieldString{
+			Set: true, Valid: true, Value: "invalid/agent",
+		},
+		Name: TestAgent.Name,
+	}); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestHeartbeatAgent(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	args := make([]any, 5)
+
+	for i := 0; i < 5; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE agent").
+		WithArgs(args...).WillReturnRows(mockAgentRows(mock))
+
+	v := TestAgent
+
+	res, err := svc.HeartbeatAgent(ctx, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Status.Value != request.StatusActive {
+		t.Errorf("Expected status: %v, got: %v",
+			request.StatusActive, res.Status.Value)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestUpdateStaleAgents(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("UPDATE agent SET").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectQuery("WITH r AS (.+) UPDATE resource SET").
+		WillReturnRows(mockResourceIDRows(mock))
+
+	mock.ExpectCommit()
+
+	if err := svc.UpdateStaleAgents(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
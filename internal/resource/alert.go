@@ -0,0 +1,1144 @@
+package resource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/notify"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Alert states.
+const (
+	AlertStatePending  = "pending"
+	AlertStateFiring   = "firing"
+	AlertStateResolved = "resolved"
+)
+
+// AlertRule values represent rules raising alerts for the resources which
+// match a search query for a duration. Notifications are delivered to the
+// channels of the rule when alerts start firing, and when they are resolved.
+type AlertRule struct {
+	AlertRuleID request.FieldString      `json:"alert_rule_id" yaml:"alert_rule_id"`
+	Name        request.FieldString      `json:"name"          yaml:"name"`
+	Description request.FieldString      `json:"description"   yaml:"description"`
+	Search      request.FieldString      `json:"search"        yaml:"search"`
+	Duration    request.FieldInt64       `json:"duration"      yaml:"duration"`
+	Channels    request.FieldStringArray `json:"channels"      yaml:"channels"`
+	Status      request.FieldString      `json:"status"        yaml:"status"`
+	CreatedAt   request.FieldTime        `json:"created_at"    yaml:"created_at"`
+	CreatedBy   request.FieldString      `json:"created_by"    yaml:"created_by"`
+	UpdatedAt   request.FieldTime        `json:"updated_at"    yaml:"updated_at"`
+	UpdatedBy   request.FieldString      `json:"updated_by"    yaml:"updated_by"`
+}
+
+// Validate checks that the value contains valid data. All invalid fields are
+// reported in the returned error.
+func (a *AlertRule) Validate() error {
+	f := errors.FieldErrors{}
+
+	a.validate(&f)
+
+	return f.Err("alert_rule", a)
+}
+
+// validate adds the invalid fields of the value to the field errors.
+func (a *AlertRule) validate(f *errors.FieldErrors) {
+	if a.AlertRuleID.Set {
+		if !a.AlertRuleID.Valid {
+			f.Add("alert_rule_id", errors.FieldNull,
+				"alert_rule_id must not be null")
+		} else if _, err := uuid.Parse(a.AlertRuleID.Value); err != nil {
+			f.Add("alert_rule_id", errors.FieldInvalid,
+				"invalid alert_rule_id")
+		}
+	}
+
+	if a.Name.Set && !a.Name.Valid {
+		f.Add("name", errors.FieldNull, "name must not be null")
+	}
+
+	if a.Search.Set {
+		if !a.Search.Valid || a.Search.Value == "" {
+			f.Add("search", errors.FieldNull, "search must not be empty")
+		} else if err := alertRuleQuery(nil, a.Search.Value).
+			Parse(); err != nil {
+			msg := err.Error()
+
+			if e, ok := err.(*errors.Error); ok && e.Msg != "" {
+				msg = e.Msg
+			}
+
+			f.Add("search", errors.FieldInvalid, "invalid search: "+msg)
+		}
+	}
+
+	if a.Duration.Set {
+		if !a.Duration.Valid {
+			f.Add("duration", errors.FieldNull, "duration must not be null")
+		} else if a.Duration.Value < 0 {
+			f.Add("duration", errors.FieldInvalid, "invalid duration")
+		}
+	}
+
+	if a.Channels.Set {
+		if !a.Channels.Valid {
+			f.Add("channels", errors.FieldNull, "channels must not be null")
+		}
+
+		for _, ch := range a.Channels.Value {
+			if !validAlertChannel(ch) {
+				f.Add("channels", errors.FieldInvalid,
+					"invalid channels: invalid channel: "+ch)
+			}
+		}
+	}
+
+	if a.Status.Set {
+		if !a.Status.Valid {
+			f.Add("status", errors.FieldNull, "status must not be null")
+		} else {
+			switch a.Status.Value {
+			case request.StatusActive, request.StatusInactive:
+			default:
+				f.Add("status", errors.FieldInvalid, "invalid status")
+			}
+		}
+	}
+}
+
+// ValidateCreate checks that the value contains valid data for creation. All
+// missing and invalid fields are reported in the returned error.
+func (a *AlertRule) ValidateCreate() error {
+	f := errors.FieldErrors{}
+
+	if !a.Name.Set {
+		f.Add("name", errors.FieldRequired, "missing name")
+	}
+
+	if !a.Search.Set {
+		f.Add("search", errors.FieldRequired, "missing search")
+	}
+
+	a.validate(&f)
+
+	return f.Err("alert_rule", a)
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (a *AlertRule) ScanDest(options sqldb.FieldOptions) []any {
+	return sqldb.ScanFields("alert_rule", alertRuleFields, options,
+		map[string]any{
+			"alert_rule_id": &a.AlertRuleID,
+			"name":          &a.Name,
+			"description":   &a.Description,
+			"search":        &a.Search,
+			"duration":      &a.Duration,
+			"channels":      &a.Channels,
+			"status":        &a.Status,
+			"created_at":    &a.CreatedAt,
+			"created_by":    &a.CreatedBy,
+			"updated_at":    &a.UpdatedAt,
+			"updated_by":    &a.UpdatedBy,
+		})
+}
+
+// validAlertChannel checks whether a string is a valid alert notification
+// channel. Channels are webhook URLs, to which notifications are sent in POST
+// requests, or mailto URLs, to which notifications are sent by email.
+func validAlertChannel(ch string) bool {
+	u, err := url.Parse(ch)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		_, err := alertChannelEmails(u)
+
+		return err == nil
+	}
+
+	return false
+}
+
+// alertChannelEmails returns the recipients of a mailto alert channel. A
+// channel without addresses is sent to the notification recipients of the
+// account, and nil is returned for it.
+func alertChannelEmails(u *url.URL) ([]string, error) {
+	v, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == "" {
+		return nil, nil
+	}
+
+	l, err := mail.ParseAddressList(v)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, len(l))
+
+	for i, a := range l {
+		res[i] = a.Address
+	}
+
+	return res, nil
+}
+
+// alertRuleFields contain the search fields for alert rules.
+var alertRuleFields = []*sqldb.Field{{
+	Name:   "alert_rule_key",
+	Type:   sqldb.FieldInt,
+	Table:  "alert_rule",
+	Hidden: true,
+}, {
+	Name:  "alert_rule_id",
+	Type:  sqldb.FieldString,
+	Table: "alert_rule",
+}, {
+	Name:    "name",
+	Type:    sqldb.FieldString,
+	Table:   "alert_rule",
+	Primary: true,
+}, {
+	Name:  "description",
+	Type:  sqldb.FieldString,
+	Table: "alert_rule",
+}, {
+	Name:  "search",
+	Type:  sqldb.FieldString,
+	Table: "alert_rule",
+}, {
+	Name:  "duration",
+	Type:  sqldb.FieldInt,
+	Table: "alert_rule",
+}, {
+	Name:  "channels",
+	Type:  sqldb.FieldArray,
+	Table: "alert_rule",
+}, {
+	Name:  "status",
+	Type:  sqldb.FieldString,
+	Table: "alert_rule",
+}, {
+	Name:   "created_at",
+	Type:   sqldb.FieldTime,
+	Option: "user_details",
+	Table:  "alert_rule",
+}, {
+	Name:   "created_by",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "created_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "created_by",
+	Expr:   "created_by_user.user_id",
+}, {
+	Name:   "updated_at",
+	Type:   sqldb.FieldTime,
+	Option: "user_details",
+	Table:  "alert_rule",
+}, {
+	Name:   "updated_by",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "updated_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "updated_by",
+	Expr:   "updated_by_user.user_id",
+}}
+
+// Alert values represent the state of the alert raised by an alert rule for a
+// resource.
+type Alert struct {
+	AlertRuleID request.FieldString `json:"alert_rule_id" yaml:"alert_rule_id"`
+	ResourceID  request.FieldString `json:"resource_id"   yaml:"resource_id"`
+	State       request.FieldString `json:"state"         yaml:"state"`
+	StartedAt   request.FieldTime   `json:"started_at"    yaml:"started_at"`
+	FiredAt     request.FieldTime   `json:"fired_at"      yaml:"fired_at"`
+	ResolvedAt  request.FieldTime   `json:"resolved_at"   yaml:"resolved_at"`
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (a *Alert) ScanDest(options sqldb.FieldOptions) []any {
+	return sqldb.ScanFields("alert", alertFields, options,
+		map[string]any{
+			"alert_rule_id": &a.AlertRuleID,
+			"resource_id":   &a.ResourceID,
+			"state":         &a.State,
+			"started_at":    &a.StartedAt,
+			"fired_at":      &a.FiredAt,
+			"resolved_at":   &a.ResolvedAt,
+		})
+}
+
+// alertFields contain the search fields for alerts.
+var alertFields = []*sqldb.Field{{
+	Name:  "alert_rule_id",
+	Type:  sqldb.FieldString,
+	Table: "alert",
+}, {
+	Name:  "resource_id",
+	Type:  sqldb.FieldString,
+	Table: "alert",
+}, {
+	Name:    "state",
+	Type:    sqldb.FieldString,
+	Table:   "alert",
+	Primary: true,
+}, {
+	Name:  "started_at",
+	Type:  sqldb.FieldTime,
+	Table: "alert",
+}, {
+	Name:  "fired_at",
+	Type:  sqldb.FieldTime,
+	Table: "alert",
+}, {
+	Name:  "resolved_at",
+	Type:  sqldb.FieldTime,
+	Table: "alert",
+}}
+
+// alertEmail is the email notification template for alerts.
+var alertEmail = notify.MustTemplate("alert",
+	`[{{.State}}] {{.AlertRule.Name.Value}}`,
+	`Alerts of the rule {{.AlertRule.Name.Value}} are now {{.State}} for:
+{{range .Alerts}}
+- resource {{.ResourceID.Value}}
+{{- end}}
+
+Search: {{.AlertRule.Search.Value}}
+{{- with .AlertRule.Description.Value}}
+Description: {{.}}{{end}}
+Account: {{.AccountID}}
+`)
+
+// AlertNotification values contain the alerts of an alert rule which have
+// started firing, or have been resolved, and are delivered to the channels of
+// the rule.
+type AlertNotification struct {
+	AccountID string     `json:"account_id" yaml:"account_id"`
+	AlertRule *AlertRule `json:"alert_rule" yaml:"alert_rule"`
+	State     string     `json:"state"      yaml:"state"`
+	Alerts    []*Alert   `json:"alerts"     yaml:"alerts"`
+}
+
+// GetAlertRules retrieves alert rules based on a search query.
+func (s *Service) GetAlertRules(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*AlertRule, []*sqldb.SummaryData, error) {
+	if err := options.ValidateFields(alertRuleFields); err != nil {
+		return nil, nil, err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   sqldb.SelectFields("alert_rule", alertRuleFields, query, options),
+		Search: query,
+		Fields: alertRuleFields,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res, sum := []*AlertRule{}, []*sqldb.SummaryData{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, nil, errors.Context(ctx)
+		default:
+		}
+
+		if query != nil && query.Summary != "" {
+			sr := &sqldb.SummaryData{}
+
+			if err = rows.Scan(sr.ScanDest(alertRuleFields,
+				query)...); err != nil {
+				return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+					"unable to select alert rule summary row",
+					"search", query)
+			}
+
+			sum = append(sum, sr)
+
+			continue
+		}
+
+		a := &AlertRule{}
+
+		if err = rows.Scan(a.ScanDest(options)...); err != nil {
+			return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select alert rule row",
+				"search", query)
+		}
+
+		res = append(res, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select alert rule rows",
+			"search", query)
+	}
+
+	return res, sum, nil
+}
+
+// GetAlertRule retrieves a single alert rule by ID.
+func (s *Service) GetAlertRule(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*AlertRule, error) {
+	if err := options.ValidateFields(alertRuleFields); err != nil {
+		return nil, err
+	}
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid alert_rule_id",
+			"id", id)
+	}
+
+	base := sqldb.SelectFields("alert_rule", alertRuleFields, nil, options) +
+		`WHERE alert_rule.alert_rule_id = $1`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: alertRuleFields,
+		Params: []any{id},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	a := &AlertRule{}
+
+	if err := row.Scan(a.ScanDest(options)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"alert rule not found",
+				"id", id)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select alert rule row",
+			"id", id)
+	}
+
+	return a, nil
+}
+
+// CreateAlertRule creates a new alert rule.
+func (s *Service) CreateAlertRule(ctx context.Context,
+	v *AlertRule,
+) (*AlertRule, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing alert rule",
+			"alert_rule", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	if v.AlertRuleID.Value == "" {
+		uID, err := uuid.NewRandom()
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrServer,
+				"unable to create ID for alert rule")
+		}
+
+		v.AlertRuleID = request.FieldString{
+			Set: true, Valid: true, Value: uID.String(),
+		}
+	}
+
+	if !v.Channels.Set {
+		v.Channels = request.FieldStringArray{
+			Set: true, Valid: true, Value: []string{},
+		}
+	}
+
+	base := `INSERT INTO alert_rule () VALUES ()` +
+		sqldb.ReturningFields("alert_rule", alertRuleFields, nil)
+
+	sets, params := []string{}, []any{}
+
+	request.SetField("alert_rule_id", v.AlertRuleID, &sets, &params)
+	request.SetField("name", v.Name, &sets, &params)
+	request.SetField("description", v.Description, &sets, &params)
+	request.SetField("search", v.Search, &sets, &params)
+	request.SetField("duration", v.Duration, &sets, &params)
+	request.SetField("channels", v.Channels, &sets, &params)
+	request.SetField("status", v.Status, &sets, &params)
+	request.SetField("created_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+	request.SetField("updated_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryInsert,
+		Base:   base,
+		Fields: alertRuleFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"alert_rule", v)
+	}
+
+	a := &AlertRule{}
+
+	if err := row.Scan(a.ScanDest(nil)...); err != nil {
+		if errors.ErrorHas(err,
+			`"alert_rule_account_id_alert_rule_id_key"`) {
+			return nil, errors.New(errors.ErrConflict,
+				"invalid alert_rule_id: already in use by another alert rule",
+				"alert_rule", v)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to insert alert rule row",
+			"alert_rule", v)
+	}
+
+	return a, nil
+}
+
+// UpdateAlertRule updates an alert rule. Alerts already raised by the rule are
+// updated the next time it is evaluated.
+func (s *Service) UpdateAlertRule(ctx context.Context,
+	v *AlertRule,
+) (*AlertRule, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing alert rule",
+			"alert_rule", v)
+	}
+
+	if !v.AlertRuleID.Set || !v.AlertRuleID.Valid {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing alert_rule_id",
+			"alert_rule", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	base := `UPDATE alert_rule SET
+		WHERE alert_rule.alert_rule_id = $1` +
+		sqldb.ReturningFields("alert_rule", alertRuleFields, nil)
+
+	sets, params := []string{}, []any{v.AlertRuleID.Value}
+
+	request.SetField("name", v.Name, &sets, &params)
+	request.SetField("description", v.Description, &sets, &params)
+	request.SetField("search", v.Search, &sets, &params)
+	request.SetField("duration", v.Duration, &sets, &params)
+	request.SetField("channels", v.Channels, &sets, &params)
+	request.SetField("status", v.Status, &sets, &params)
+	request.SetField("updated_at", request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}, &sets, &params)
+	request.SetField("updated_by", request.FieldString{
+		Set: true, Valid: true, Value: userID,
+	}, &sets, &params)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryUpdate,
+		Base:   base,
+		Fields: alertRuleFields,
+		Sets:   sets,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"alert_rule", v)
+	}
+
+	a := &AlertRule{}
+
+	if err := row.Scan(a.ScanDest(nil)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"alert rule not found",
+				"alert_rule", v)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update alert rule row",
+			"alert_rule", v)
+	}
+
+	return a, nil
+}
+
+// DeleteAlertRule deletes an alert rule, and the alerts raised by it.
+func (s *Service) DeleteAlertRule(ctx context.Context,
+	id string,
+) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return errors.New(errors.ErrInvalidRequest,
+			"invalid alert_rule_id",
+			"id", id)
+	}
+
+	base := `DELETE FROM alert_rule
+		WHERE alert_rule.alert_rule_id = $1`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryDelete,
+		Base:   base,
+		Fields: alertRuleFields,
+		Params: []any{id},
+	})
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	if n := res.RowsAffected(); n == 0 {
+		return errors.New(errors.ErrNotFound, "alert rule not found",
+			"id", id)
+	}
+
+	return nil
+}
+
+// GetAlerts retrieves the alerts raised by alert rules based on a search
+// query.
+func (s *Service) GetAlerts(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*Alert, []*sqldb.SummaryData, error) {
+	if err := options.ValidateFields(alertFields); err != nil {
+		return nil, nil, err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   sqldb.SelectFields("alert", alertFields, query, options),
+		Search: query,
+		Fields: alertFields,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res, sum := []*Alert{}, []*sqldb.SummaryData{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, nil, errors.Context(ctx)
+		default:
+		}
+
+		if query != nil && query.Summary != "" {
+			sr := &sqldb.SummaryData{}
+
+			if err = rows.Scan(sr.ScanDest(alertFields,
+				query)...); err != nil {
+				return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+					"unable to select alert summary row",
+					"search", query)
+			}
+
+			sum = append(sum, sr)
+
+			continue
+		}
+
+		a := &Alert{}
+
+		if err = rows.Scan(a.ScanDest(options)...); err != nil {
+			return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select alert row",
+				"search", query)
+		}
+
+		res = append(res, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select alert rows",
+			"search", query)
+	}
+
+	return res, sum, nil
+}
+
+// alertRuleQuery returns the query selecting the IDs of all of the resources
+// matching the search of an alert rule.
+func alertRuleQuery(db sqldb.SQLDB, expr string) *sqldb.Query {
+	return sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   db,
+		Type: sqldb.QueryExec,
+		Base: `SELECT resource.resource_id::TEXT
+			FROM resource`,
+		Search: &search.Query{Search: expr},
+		Fields: resourceFields,
+	})
+}
+
+// getActiveAlertRules retrieves all of the active alert rules of the account.
+func (s *Service) getActiveAlertRules(ctx context.Context,
+) ([]*AlertRule, error) {
+	base := sqldb.SelectFields("alert_rule", alertRuleFields, nil, nil) +
+		`WHERE alert_rule.status = '` + request.StatusActive + `'`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: alertRuleFields,
+	})
+
+	q.Limit = 10000
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select active alert rule rows")
+	}
+
+	defer rows.Close()
+
+	res := []*AlertRule{}
+
+	for rows.Next() {
+		a := &AlertRule{}
+
+		if err := rows.Scan(a.ScanDest(nil)...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select active alert rule row")
+		}
+
+		res = append(res, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select active alert rule rows")
+	}
+
+	return res, nil
+}
+
+// updateAlerts performs a statement updating the alerts of an alert rule, and
+// returns the updated alerts.
+func (s *Service) updateAlerts(ctx context.Context,
+	base string,
+	params ...any,
+) ([]*Alert, error) {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryExec,
+		Base:   base + sqldb.ReturningFields("alert", alertFields, nil),
+		Params: params,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update alert rows")
+	}
+
+	defer rows.Close()
+
+	res := []*Alert{}
+
+	for rows.Next() {
+		a := &Alert{}
+
+		if err := rows.Scan(a.ScanDest(nil)...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to update alert row")
+		}
+
+		res = append(res, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to update alert rows")
+	}
+
+	return res, nil
+}
+
+// evaluateAlertRule updates the alerts of an alert rule from the resources
+// currently matching its search. Alerts are pending for new matches, fire once
+// the resource has matched for the duration of the rule, and are resolved when
+// the resource no longer matches. The alerts which started firing, and the
+// alerts which were resolved, are returned.
+func (s *Service) evaluateAlertRule(ctx context.Context,
+	rule *AlertRule,
+) ([]*Alert, []*Alert, error) {
+	var fired, resolved []*Alert
+
+	id := rule.AlertRuleID.Value
+
+	if err := sqldb.RunTx(ctx, s.db, func(ctx context.Context) error {
+		q := alertRuleQuery(s.db, rule.Search.Value)
+
+		rows, err := q.Query(ctx)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to select alert rule resources",
+				"alert_rule_id", id)
+		}
+
+		ids := []string{}
+
+		for rows.Next() {
+			rID := ""
+
+			if err := rows.Scan(&rID); err != nil {
+				rows.Close()
+
+				return errors.Wrap(err, errors.ErrDatabase,
+					"unable to select alert rule resource",
+					"alert_rule_id", id)
+			}
+
+			ids = append(ids, rID)
+		}
+
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to select alert rule resources",
+				"alert_rule_id", id)
+		}
+
+		if resolved, err = s.updateAlerts(ctx, `UPDATE alert SET
+				state = '`+AlertStateResolved+`',
+				resolved_at = CURRENT_TIMESTAMP
+			WHERE alert.alert_rule_id = $1
+				AND alert.state = '`+AlertStateFiring+`'
+				AND NOT alert.resource_id::TEXT = ANY($2::TEXT[])`,
+			id, ids); err != nil {
+			return err
+		}
+
+		q = sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   s.db,
+			Type: sqldb.QueryExec,
+			Base: `DELETE FROM alert
+				WHERE alert.alert_rule_id = $1
+					AND alert.state = '` + AlertStatePending + `'
+					AND NOT alert.resource_id::TEXT = ANY($2::TEXT[])`,
+			Params: []any{id, ids},
+		})
+
+		if _, err := q.Exec(ctx); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to delete pending alert rows",
+				"alert_rule_id", id)
+		}
+
+		// Resolved alerts are pending again when the resource matches again.
+		q = sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   s.db,
+			Type: sqldb.QueryExec,
+			Base: `INSERT INTO alert (alert_rule_id, resource_id)
+				SELECT $1::UUID, UNNEST($2::TEXT[])::UUID
+				ON CONFLICT (account_id, alert_rule_id, resource_id)
+				DO UPDATE SET
+					state = '` + AlertStatePending + `',
+					started_at = CURRENT_TIMESTAMP,
+					fired_at = NULL,
+					resolved_at = NULL
+				WHERE alert.state = '` + AlertStateResolved + `'`,
+			Params: []any{id, ids},
+		})
+
+		if _, err := q.Exec(ctx); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to insert pending alert rows",
+				"alert_rule_id", id)
+		}
+
+		fired, err = s.updateAlerts(ctx, `UPDATE alert SET
+				state = '`+AlertStateFiring+`',
+				fired_at = CURRENT_TIMESTAMP
+			WHERE alert.alert_rule_id = $1
+				AND alert.state = '`+AlertStatePending+`'
+				AND alert.started_at <=
+					CURRENT_TIMESTAMP - $2::BIGINT * INTERVAL '1 second'`,
+			id, rule.Duration.Value)
+
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return fired, resolved, nil
+}
+
+// EvaluateAlerts evaluates all of the active alert rules of the account
+// against its resources, and notifies the channels of each rule of the alerts
+// which started firing, or were resolved. Rules which can not be evaluated are
+// logged, and do not prevent the evaluation of the others.
+func (s *Service) EvaluateAlerts(ctx context.Context) error {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return err
+	}
+
+	rules, err := s.getActiveAlertRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		select {
+		case <-ctx.Done():
+			return errors.Context(ctx)
+		default:
+		}
+
+		fired, resolved, err := s.evaluateAlertRule(ctx, rule)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to evaluate alert rule",
+				"error", err,
+				"alert_rule_id", rule.AlertRuleID.Value)
+
+			continue
+		}
+
+		if s.metric != nil {
+			if len(fired) > 0 {
+				s.metric.Add(ctx, "alerts_fired", int64(len(fired)))
+			}
+
+			if len(resolved) > 0 {
+				s.metric.Add(ctx, "alerts_resolved", int64(len(resolved)))
+			}
+		}
+
+		if len(fired) > 0 {
+			s.notifyAlert(ctx, &AlertNotification{
+				AccountID: accountID,
+				AlertRule: rule,
+				State:     AlertStateFiring,
+				Alerts:    fired,
+			})
+		}
+
+		if len(resolved) > 0 {
+			s.notifyAlert(ctx, &AlertNotification{
+				AccountID: accountID,
+				AlertRule: rule,
+				State:     AlertStateResolved,
+				Alerts:    resolved,
+			})
+		}
+	}
+
+	return nil
+}
+
+// notifyAlert delivers an alert notification to each of the channels of its
+// alert rule. Delivery failures are logged, and recorded in the
+// alert_notification_errors metric, but do not change the state of the
+// alerts.
+func (s *Service) notifyAlert(ctx context.Context, n *AlertNotification) {
+	for _, ch := range n.AlertRule.Channels.Value {
+		if err := s.sendAlert(ctx, ch, n); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to send alert notification",
+				"error", err,
+				"alert_rule_id", n.AlertRule.AlertRuleID.Value,
+				"channel", ch)
+
+			if s.metric != nil {
+				s.metric.Increment(ctx, "alert_notification_errors")
+			}
+		}
+	}
+}
+
+// sendAlert sends an alert notification to a channel. Notifications are sent
+// to webhook channels as the JSON body of a POST request, and to mailto
+// channels by email.
+func (s *Service) sendAlert(ctx context.Context,
+	channel string,
+	n *AlertNotification,
+) error {
+	u, err := url.Parse(channel)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"invalid alert channel",
+			"channel", channel)
+	}
+
+	if u.Scheme == "mailto" {
+		return s.sendAlertEmail(ctx, u, n)
+	}
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to encode alert notification")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, channel,
+		bytes.NewReader(b))
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"unable to create alert notification request",
+			"channel", channel)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// The notification is delivered once, even if the request is retried.
+	req.Header.Set("Idempotency-Key", uuid.NewString())
+
+	// Webhooks are only sent to public addresses, so that alert rules cannot
+	// be used to make requests to internal services.
+	resp, err := s.alertClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(errors.ErrClient,
+			"alert notification rejected",
+			"channel", channel,
+			"status", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// sendAlertEmail sends an alert notification to the recipients of a mailto
+// channel, or, if it has none, to the notification recipients of the account.
+func (s *Service) sendAlertEmail(ctx context.Context,
+	u *url.URL,
+	n *AlertNotification,
+) error {
+	to, err := alertChannelEmails(u)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrClient,
+			"invalid alert channel",
+			"channel", u.String())
+	}
+
+	if len(to) == 0 {
+		if to, err = s.getAccountNotifyEmails(ctx); err != nil {
+			return err
+		}
+
+		if len(to) == 0 {
+			return errors.New(errors.ErrConfiguration,
+				"no notification recipients for account",
+				"channel", u.String())
+		}
+	}
+
+	return s.notify.Send(ctx, to, alertEmail, n)
+}
+
+// alertAccounts periodically evaluates the alert rules of each account.
+func (s *Service) alertAccounts(ctx context.Context) {
+	tick := time.NewTimer(s.cfg.AlertInterval())
+
+	for {
+		select {
+		case <-ctx.Done():
+			tick.Stop()
+
+			return
+		case <-tick.C:
+			accounts, err := s.getAllAccounts(ctx)
+			if err != nil {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to get accounts to evaluate alert rules",
+					"error", err)
+			}
+
+			for _, aID := range accounts {
+				actx := request.Elevate(ctx, aID)
+
+				if err := s.EvaluateAlerts(actx); err != nil {
+					s.log.Log(actx, logger.LvlError,
+						"unable to evaluate alert rules",
+						"error", err)
+				}
+			}
+		}
+
+		tick = time.NewTimer(s.cfg.AlertInterval())
+	}
+}
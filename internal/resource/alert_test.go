@@ -0,0 +1,368 @@
+package resource_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+var TestAlertRule = resource.AlertRule{
+	AlertRuleID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestUUID,
+	},
+	Name: request.FieldString{
+		Set: true, Valid: true,
+		Value: "testName",
+	},
+	Search: request.FieldString{
+		Set: true, Valid: true,
+		Value: "and(status:error)",
+	},
+	Duration: request.FieldInt64{
+		Set: true, Valid: true,
+		Value: 300,
+	},
+	Channels: request.FieldStringArray{
+		Set: true, Valid: true,
+		Value: []string{"https://hooks.example.com/alerts"},
+	},
+	Status: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.StatusActive,
+	},
+}
+
+func mockAlertRuleRows(mock pgxmock.PgxCommonIface,
+	v resource.AlertRule,
+) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"alert_rule_id",
+		"name",
+		"description",
+		"search",
+		"duration",
+		"channels",
+		"status",
+	}).AddRow(
+		v.AlertRuleID.Value,
+		v.Name.Value,
+		nil,
+		v.Search.Value,
+		v.Duration.Value,
+		v.Channels,
+		v.Status.Value,
+	)
+}
+
+func mockAlertRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"alert_rule_id",
+		"resource_id",
+		"state",
+		"started_at",
+		"fired_at",
+		"resolved_at",
+	})
+}
+
+func TestAlertRuleValidate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		field string
+		v     resource.AlertRule
+	}{{
+		name:  "missing search",
+		field: "search",
+		v: resource.AlertRule{
+			Name: TestAlertRule.Name,
+		},
+	}, {
+		name:  "invalid search",
+		field: "search",
+		v: resource.AlertRule{
+			Name: TestAlertRule.Name,
+			Search: request.FieldString{
+				Set: true, Valid: true, Value: "and(",
+			},
+		},
+	}, {
+		name:  "invalid duration",
+		field: "duration",
+		v: resource.AlertRule{
+			Name:   TestAlertRule.Name,
+			Search: TestAlertRule.Search,
+			Duration: request.FieldInt64{
+				Set: true, Valid: true, Value: -1,
+			},
+		},
+	}, {
+		name:  "invalid channel",
+		field: "channels",
+		v: resource.AlertRule{
+			Name:   TestAlertRule.Name,
+			Search: TestAlertRule.Search,
+			Channels: request.FieldStringArray{
+				Set: true, Valid: true, Value: []string{"ftp://example.com"},
+			},
+		},
+	}, {
+		name:  "invalid status",
+		field: "status",
+		v: resource.AlertRule{
+			Name:   TestAlertRule.Name,
+			Search: TestAlertRule.Search,
+			Status: request.FieldString{
+				Set: true, Valid: true, Value: "test",
+			},
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.v.ValidateCreate()
+			if !errors.Has(err, errors.ErrInvalidRequest) {
+				t.Fatalf("Expected error code: %v, got: %v",
+					errors.ErrInvalidRequest, err)
+			}
+
+			var e *errors.Error
+
+			if !errors.As(err, &e) || len(e.Fields) == 0 ||
+				e.Fields[0].Field != tt.field {
+				t.Errorf("Expected field error: %v, got: %v", tt.field, err)
+			}
+		})
+	}
+
+	v := TestAlertRule
+
+	if err := v.ValidateCreate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestGetAlertRule(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM alert_rule").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAlertRuleRows(mock, TestAlertRule))
+
+	res, err := svc.GetAlertRule(ctx, TestAlertRule.AlertRuleID.Value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Search.Value != TestAlertRule.Search.Value {
+		t.Errorf("Expected search: %v, got: %v",
+			TestAlertRule.Search.Value, res.Search.Value)
+	}
+
+	if _, err := svc.GetAlertRule(ctx, "invalid", nil); !errors.Has(err,
+		errors.ErrInvalidRequest) {
+		t.Errorf("Expected error code: %v, got: %v",
+			errors.ErrInvalidRequest, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestCreateAlertRule(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	args := make([]any, 8)
+
+	for i := 0; i < 8; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("INSERT INTO alert_rule").
+		WithArgs(args...).
+		WillReturnRows(mockAlertRuleRows(mock, TestAlertRule))
+
+	v := TestAlertRule
+
+	res, err := svc.CreateAlertRule(ctx, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.AlertRuleID.Value != TestAlertRule.AlertRuleID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestAlertRule.AlertRuleID.Value, res.AlertRuleID.Value)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	var mu sync.Mutex
+
+	received := []*resource.AlertNotification{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request,
+	) {
+		if r.Header.Get("Idempotency-Key") == "" {
+			t.Error("Expected Idempotency-Key header")
+		}
+
+		n := &resource.AlertNotification{}
+
+		if err := json.NewDecoder(r.Body).Decode(n); err != nil {
+			t.Error(err)
+		}
+
+		mu.Lock()
+		received = append(received, n)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer ts.Close()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.NewDefault()
+
+	// The test webhook server is only reachable on a loopback address.
+	cfg.SetClient(&config.ClientConfig{
+		AllowedNetworks: []string{"127.0.0.0/8", "::1/128"},
+	})
+
+	svc := resource.NewService(cfg, md, nil, nil, nil, nil)
+
+	rule := TestAlertRule
+
+	rule.Channels = request.FieldStringArray{
+		Set: true, Valid: true, Value: []string{ts.URL},
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM alert_rule (.+)status = 'active'").
+		WillReturnRows(mockAlertRuleRows(mock, rule))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT resource.resource_id::TEXT").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockResourceIDRows(mock))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectQuery("UPDATE alert SET state = 'resolved'").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockAlertRows(mock))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectExec("DELETE FROM alert").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectExec("INSERT INTO alert (.+) ON CONFLICT").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectQuery("UPDATE alert SET state = 'firing'").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockAlertRows(mock).AddRow(
+			TestAlertRule.AlertRuleID.Value,
+			TestResource.ResourceID.Value,
+			resource.AlertStateFiring,
+			int64(1),
+			int64(301),
+			nil,
+		))
+
+	mock.ExpectCommit()
+
+	if err := svc.EvaluateAlerts(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(received) != 1 {
+		t.Fatalf("Expected notifications: 1, got: %v", len(received))
+	}
+
+	n := received[0]
+
+	if n.State != resource.AlertStateFiring {
+		t.Errorf("Expected state: %v, got: %v",
+			resource.AlertStateFiring, n.State)
+	}
+
+	if len(n.Alerts) != 1 ||
+		n.Alerts[0].ResourceID.Value != TestResource.ResourceID.Value {
+		t.Errorf("Expected alert for resource: %v, got: %+v",
+			TestResource.ResourceID.Value, n.Alerts)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package resource
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// ClearDeferred deletes the resource data items of the account which matched
+// the clear_condition of a resource with a clear_delay, and have not been
+// updated by data no longer matching the condition before the delay elapsed.
+// The number of items cleared is returned, and recorded in the
+// deferred_clears metric.
+func (s *Service) ClearDeferred(ctx context.Context) (int64, error) {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryDelete,
+		Base: `DELETE FROM resource_data USING resource
+			WHERE resource_data.resource_key = resource.resource_key
+				AND resource_data.clear_at <= $1
+			RETURNING resource.resource_id`,
+		Fields: resourceFields,
+		Params: []any{time.Now()},
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrDatabase,
+			"unable to clear deferred resource data rows")
+	}
+
+	defer rows.Close()
+
+	n := int64(0)
+
+	ids := map[string]bool{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return 0, errors.Context(ctx)
+		default:
+		}
+
+		id := ""
+
+		if err := rows.Scan(&id); err != nil {
+			return 0, errors.Wrap(err, errors.ErrDatabase,
+				"unable to clear deferred resource data row")
+		}
+
+		ids[id] = true
+
+		n++
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, errors.ErrDatabase,
+			"unable to clear deferred resource data rows")
+	}
+
+	for id := range ids {
+		s.deleteResourceCache(ctx, id)
+	}
+
+	if s.metric != nil && n > 0 {
+		s.metric.Add(ctx, "deferred_clears", n)
+	}
+
+	return n, nil
+}
+
+// clearAccounts periodically clears the resource data items of each account
+// whose clear_delay has elapsed.
+func (s *Service) clearAccounts(ctx context.Context) {
+	tick := time.NewTimer(s.cfg.ClearInterval())
+
+	for {
+		select {
+		case <-ctx.Done():
+			tick.Stop()
+
+			return
+		case <-tick.C:
+			accounts, err := s.getAllAccounts(ctx)
+			if err != nil {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to get accounts to clear resource data",
+					"error", err)
+			}
+
+			for _, aID := range accounts {
+				actx := request.Elevate(ctx, aID)
+
+				n, err := s.ClearDeferred(actx)
+				if err != nil {
+					s.log.Log(actx, logger.LvlError,
+						"unable to clear deferred resource data",
+						"error", err)
+
+					continue
+				}
+
+				if n > 0 {
+					s.log.Log(actx, logger.LvlDebug,
+						"deferred resource data cleared",
+						"resource_data", n)
+				}
+			}
+		}
+
+		tick = time.NewTimer(s.cfg.ClearInterval())
+	}
+}
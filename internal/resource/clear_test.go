@@ -0,0 +1,50 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestClearDeferred(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("DELETE FROM resource_data (.+) " +
+		"RETURNING resource.resource_id").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockResourceIDRows(mock).
+			AddRow(TestResource.ResourceID.Value))
+
+	n, err := svc.ClearDeferred(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Errorf("Expected cleared items: 2, got: %v", n)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("Expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,585 @@
+package resource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/google/uuid"
+)
+
+// Resource data duplicate key policies, which determine how payload items with
+// the same key are resolved.
+const (
+	DuplicatePolicyLast   = "last"
+	DuplicatePolicyFirst  = "first"
+	DuplicatePolicyMerge  = "merge"
+	DuplicatePolicyReject = "reject"
+)
+
+// resourceDataExpr is the SQL expression used to aggregate the keyed resource
+// data items of a resource into a single JSON object when it is read.
+const resourceDataExpr = `(SELECT JSONB_OBJECT_AGG(resource_data.data_key,
+		resource_data.data)
+		FROM resource_data
+		WHERE resource_data.resource_key = resource.resource_key)`
+
+// setResourceData upserts the keyed resource data items for a resource by ID
+// and deletes any cleared items, optionally within a transaction. Items not
+// contained in data, which have not been updated since the before timestamp,
+// or within their own ttl, in seconds, if they have one, are also deleted. If
+// replace is true, all items not contained in data are deleted. The deferred
+// items are scheduled to be cleared after delay seconds, unless they were
+// already scheduled, and any other updated items have their scheduled clears
+// cancelled.
+func (s *Service) setResourceData(ctx context.Context,
+	tx sqldb.SQLTX,
+	resourceID string,
+	data map[string]any,
+	clears []string,
+	deferred []string,
+	delay int64,
+	before int64,
+	replace bool,
+) error {
+	items := make(map[string]any, len(data))
+
+	keys := make([]string, 0, len(data))
+
+	for k, v := range data {
+		if slices.Contains(clears, k) {
+			continue
+		}
+
+		items[k] = v
+		keys = append(keys, k)
+	}
+
+	if clears == nil {
+		clears = []string{}
+	}
+
+	if deferred == nil {
+		deferred = []string{}
+	}
+
+	buf, err := json.Marshal(items)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to encode resource data",
+			"resource_id", resourceID,
+			"data", data)
+	}
+
+	base := `WITH r AS (
+			SELECT resource.resource_key
+			FROM resource
+			WHERE resource.resource_id = $1
+		), d AS (
+			DELETE FROM resource_data USING r
+			WHERE resource_data.resource_key = r.resource_key
+				AND (resource_data.data_key = ANY($2::TEXT[])
+					OR (($5::BOOLEAN
+						OR CASE WHEN resource_data.data->>'ttl'
+							~ '^[0-9]+(\.[0-9]+)?$'
+						THEN resource_data.ts < CURRENT_TIMESTAMP -
+							MAKE_INTERVAL(secs =>
+								(resource_data.data->>'ttl')::DOUBLE PRECISION)
+						ELSE resource_data.ts < TO_TIMESTAMP($4) END)
+					AND NOT resource_data.data_key = ANY($3::TEXT[])))
+		)
+		INSERT INTO resource_data (resource_key, data_key, data, ts, clear_at)
+		SELECT r.resource_key, item.key, item.value,
+			CASE WHEN item.value->>'ts' ~ '^[0-9]+(\.[0-9]+)?$'
+				THEN TO_TIMESTAMP((item.value->>'ts')::DOUBLE PRECISION)
+				ELSE CURRENT_TIMESTAMP END,
+			CASE WHEN item.key = ANY($7::TEXT[])
+				THEN CURRENT_TIMESTAMP + $8::BIGINT * INTERVAL '1 second'
+				END
+		FROM r, JSONB_EACH($6::JSONB) AS item
+		ON CONFLICT (account_id, resource_key, data_key) DO UPDATE
+		SET data = EXCLUDED.data, ts = EXCLUDED.ts,
+			clear_at = CASE WHEN EXCLUDED.clear_at IS NOT NULL
+				THEN COALESCE(resource_data.clear_at, EXCLUDED.clear_at) END`
+
+	params := []any{resourceID, clears, keys, before, replace, string(buf),
+		deferred, delay}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Tx:     tx,
+		Type:   sqldb.QueryExec,
+		Base:   base,
+		Params: params,
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to update resource data rows",
+			"resource_id", resourceID)
+	}
+
+	return nil
+}
+
+// ResourceDataEntry values contain a resource data update payload for a single
+// resource, as used by batch resource data updates.
+type ResourceDataEntry struct {
+	ResourceID request.FieldString `json:"resource_id" yaml:"resource_id"`
+	Data       map[string]any      `json:"data"        yaml:"data"`
+}
+
+// UpdateResourcesData allows external systems to update the resource data of
+// multiple resources in a single request. Each resource is updated in its own
+// transaction and the result of each update is reported individually. The
+// current user must have write permission on each resource.
+func (s *Service) UpdateResourcesData(ctx context.Context,
+	entries []*ResourceDataEntry,
+) (*request.MultiStatus, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(entries) == 0 {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing resource data entries")
+	}
+
+	res := request.NewMultiStatus()
+
+	for i, e := range entries {
+		select {
+		case <-ctx.Done():
+			res.Add(i, "", 0, nil, errors.Context(ctx))
+
+			continue
+		default:
+		}
+
+		if e == nil || !e.ResourceID.Set || !e.ResourceID.Valid ||
+			e.ResourceID.Value == "" {
+			res.Add(i, "", 0, nil, errors.New(errors.ErrInvalidRequest,
+				"missing resource_id",
+				"index", i))
+
+			continue
+		}
+
+		if e.Data == nil {
+			res.Add(i, e.ResourceID.Value, 0, nil,
+				errors.New(errors.ErrInvalidRequest,
+					"missing data",
+					"index", i,
+					"resource_id", e.ResourceID.Value))
+
+			continue
+		}
+
+		// Access is checked as the current user, before the update elevates
+		// the context to the account.
+		if _, err := s.checkAccess(ctx, e.ResourceID.Value,
+			PermissionWrite); err != nil {
+			res.Add(i, e.ResourceID.Value, 0, nil, err)
+
+			continue
+		}
+
+		r, err := s.UpdateResourceData(ctx, e.Data, accountID,
+			e.ResourceID.Value)
+
+		res.Add(i, e.ResourceID.Value, http.StatusOK, r, err)
+	}
+
+	return res, nil
+}
+
+// ClearPreview values contain the result of evaluating a clear_condition
+// against the most recently stored data items of a resource, before the
+// condition is applied to the resource.
+type ClearPreview struct {
+	ClearCondition string   `json:"clear_condition"`
+	Evaluated      int      `json:"evaluated"`
+	Cleared        int      `json:"cleared"`
+	Keys           []string `json:"keys"`
+}
+
+// PreviewClearCondition evaluates a clear_condition against, at most, the size
+// most recently stored data items of a resource by ID, and reports the keys of
+// the items which would be cleared by it. Aggregate functions in the condition
+// are evaluated over the same items. The resource is not changed.
+func (s *Service) PreviewClearCondition(ctx context.Context,
+	id, condition string,
+	size int64,
+) (*ClearPreview, error) {
+	ast, err := search.NewParser(bytes.NewBufferString(condition)).Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid clear_condition",
+			"id", id,
+			"clear_condition", condition)
+	}
+
+	if _, err := s.checkAccess(ctx, id, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+				resource_data.data_key,
+				resource_data.data
+			FROM resource_data
+			INNER JOIN resource
+				ON resource.resource_key = resource_data.resource_key
+			WHERE resource.resource_id = $1
+			ORDER BY resource_data.ts DESC, resource_data.data_key`,
+		Params: []any{id},
+	})
+
+	q.Limit = size
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id)
+	}
+
+	defer rows.Close()
+
+	res := &ClearPreview{
+		ClearCondition: condition,
+		Keys:           []string{},
+	}
+
+	keys, set := []string{}, []map[string]any{}
+
+	for rows.Next() && int64(len(keys)) < size {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		key, buf := "", []byte(nil)
+
+		if err := rows.Scan(&key, &buf); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select resource data row",
+				"id", id)
+		}
+
+		am := map[string]any{}
+
+		if err := request.NewJSONDecoder(bytes.NewReader(buf)).
+			Decode(&am); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to decode resource data item",
+				"id", id,
+				"key", key)
+		}
+
+		keys = append(keys, key)
+		set = append(set, am)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource data rows",
+			"id", id)
+	}
+
+	for i, am := range set {
+		res.Evaluated++
+
+		cleared, err := ast.MatchItem(am, set)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to evaluate resource clear_condition",
+				"id", id,
+				"clear_condition", condition,
+				"key", keys[i])
+		}
+
+		if cleared {
+			res.Cleared++
+			res.Keys = append(res.Keys, keys[i])
+		}
+	}
+
+	return res, nil
+}
+
+// DataItem values contain a single keyed resource data item of a resource.
+type DataItem struct {
+	Key  request.FieldString `json:"key"  yaml:"key"`
+	Data request.FieldJSON   `json:"data" yaml:"data"`
+	TS   request.FieldTime   `json:"ts"   yaml:"ts"`
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (d *DataItem) ScanDest() []any {
+	return sqldb.ScanFields("resource_data", dataItemFields, nil,
+		map[string]any{
+			"key":  &d.Key,
+			"data": &d.Data,
+			"ts":   &d.TS,
+		})
+}
+
+// dataItemFields contain the fields for resource data items.
+var dataItemFields = []*sqldb.Field{{
+	Name:    "key",
+	Type:    sqldb.FieldString,
+	Table:   "resource_data",
+	Expr:    "resource_data.data_key",
+	Primary: true,
+}, {
+	Name:  "data",
+	Type:  sqldb.FieldJSON,
+	Table: "resource_data",
+}, {
+	Name:  "ts",
+	Type:  sqldb.FieldTime,
+	Table: "resource_data",
+}}
+
+// GetResourceData retrieves the keyed data items of a resource by ID which
+// match a search query, ordered by key unless the query is sorted. The
+// contents of the items are searched using JSON path fields, such as
+// data.status:error, so that only the requested items are returned rather
+// than the entire resource data.
+func (s *Service) GetResourceData(ctx context.Context,
+	id string,
+	query *search.Query,
+) ([]*DataItem, error) {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource_id",
+			"id", id)
+	}
+
+	id = u.String()
+
+	if _, err := s.checkAccess(ctx, id, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	q := &search.Query{}
+
+	if query != nil {
+		*q = *query
+	}
+
+	if q.Sort == "" {
+		q.Sort = "key"
+	}
+
+	q.Summary = ""
+
+	base := sqldb.SelectFields("resource_data", dataItemFields, nil, nil) +
+		`INNER JOIN resource
+			ON resource.resource_key = resource_data.resource_key
+		WHERE resource.resource_id = $1`
+
+	sq := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Search: q,
+		Fields: dataItemFields,
+		Params: []any{id},
+	})
+
+	rows, err := sq.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id,
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res := []*DataItem{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		d := &DataItem{}
+
+		if err := rows.Scan(d.ScanDest()...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select resource data row",
+				"id", id)
+		}
+
+		res = append(res, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource data rows",
+			"id", id)
+	}
+
+	return res, nil
+}
+
+// DataSummary values contain the number of data items of a resource with a
+// value of a grouping data field, and aggregates of the numeric values of
+// another data field over those items.
+type DataSummary struct {
+	Value request.FieldString  `json:"value" yaml:"value"`
+	Count int64                `json:"count" yaml:"count"`
+	Sum   request.FieldFloat64 `json:"sum"   yaml:"sum"`
+	Min   request.FieldFloat64 `json:"min"   yaml:"min"`
+	Max   request.FieldFloat64 `json:"max"   yaml:"max"`
+	Avg   request.FieldFloat64 `json:"avg"   yaml:"avg"`
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (d *DataSummary) ScanDest() []any {
+	return []any{&d.Value, &d.Count, &d.Sum, &d.Min, &d.Max, &d.Avg}
+}
+
+// dataPath splits a data field name, such as host.region, into the JSON path
+// of the field within a data item.
+func dataPath(name string) ([]string, bool) {
+	if name == "" {
+		return []string{}, true
+	}
+
+	res := strings.Split(name, ".")
+
+	if slices.Contains(res, "") {
+		return nil, false
+	}
+
+	return res, true
+}
+
+// GetResourceDataSummary summarizes the data items of a resource by ID which
+// match a search query, grouped by the value of the group data field, ordered
+// by the number of items with each value, most first. The sum, minimum,
+// maximum, and average of the numeric values of the value data field, if one
+// is given, are computed for each group. Items without a numeric value are
+// counted, but are not aggregated. The summary is computed by the database, so
+// that the data items are not retrieved.
+func (s *Service) GetResourceDataSummary(ctx context.Context,
+	id, group, value string,
+	query *search.Query,
+) ([]*DataSummary, error) {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource_id",
+			"id", id)
+	}
+
+	id = u.String()
+
+	groupPath, ok := dataPath(group)
+	if !ok || group == "" {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource data summary group",
+			"id", id,
+			"group", group)
+	}
+
+	valuePath, ok := dataPath(value)
+	if !ok {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource data summary value",
+			"id", id,
+			"value", value)
+	}
+
+	if _, err := s.checkAccess(ctx, id, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	q := &search.Query{}
+
+	if query != nil {
+		*q = *query
+	}
+
+	q.Sort, q.Summary = "", ""
+
+	sq := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT
+				resource_data.data #>> $2::TEXT[] AS value,
+				COUNT(*) AS count,
+				SUM(item.num) AS sum,
+				MIN(item.num) AS min,
+				MAX(item.num) AS max,
+				AVG(item.num) AS avg
+			FROM resource_data
+			INNER JOIN resource
+				ON resource.resource_key = resource_data.resource_key
+			CROSS JOIN LATERAL (
+				SELECT CASE WHEN JSONB_TYPEOF(
+						resource_data.data #> $3::TEXT[]) = 'number'
+					THEN (resource_data.data #>> $3::TEXT[])::DOUBLE PRECISION
+					END AS num
+			) AS item
+			WHERE resource.resource_id = $1
+			GROUP BY 1
+			ORDER BY count DESC, value`,
+		Search: q,
+		Fields: dataItemFields,
+		Params: []any{id, groupPath, valuePath},
+	})
+
+	rows, err := sq.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id,
+			"search", query)
+	}
+
+	defer rows.Close()
+
+	res := []*DataSummary{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		d := &DataSummary{}
+
+		if err := rows.Scan(d.ScanDest()...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select resource data summary row",
+				"id", id)
+		}
+
+		res = append(res, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource data summary rows",
+			"id", id)
+	}
+
+	return res, nil
+}
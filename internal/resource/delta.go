@@ -0,0 +1,162 @@
+package resource
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// ResourceDelta values contain the IDs of the resources created, updated and
+// deleted between two revisions, and the revision from which the next delta
+// should be requested.
+type ResourceDelta struct {
+	Revision int64    `json:"revision"`
+	Created  []string `json:"created"`
+	Updated  []string `json:"updated"`
+	Deleted  []string `json:"deleted"`
+}
+
+// NewResourceDelta creates a new, empty, resource delta for a revision.
+func NewResourceDelta(revision int64) *ResourceDelta {
+	return &ResourceDelta{
+		Revision: revision,
+		Created:  []string{},
+		Updated:  []string{},
+		Deleted:  []string{},
+	}
+}
+
+// Add adds a changed resource to the delta, using the first and last change
+// operations made to the resource within the delta. Resources last deleted are
+// reported as deleted, resources first created are reported as created, and all
+// others as updated.
+func (d *ResourceDelta) Add(id, first, last string) {
+	switch {
+	case last == auth.ChangeOperationDelete:
+		d.Deleted = append(d.Deleted, id)
+	case first == auth.ChangeOperationCreate:
+		d.Created = append(d.Created, id)
+	default:
+		d.Updated = append(d.Updated, id)
+	}
+}
+
+// GetResourcesDelta retrieves the IDs of the resources changed at, or after,
+// the specified revision. Revisions are transaction horizons, before which all
+// transactions have completed, so that no change is skipped by a later request
+// using the returned revision.
+func (s *Service) GetResourcesDelta(ctx context.Context,
+	sinceRevision int64,
+) (*ResourceDelta, error) {
+	if sinceRevision < 0 {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid since_revision",
+			"since_revision", sinceRevision)
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT pg_snapshot_xmin(pg_current_snapshot())::TEXT
+			LIMIT 1`,
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	rev := ""
+
+	if err := row.Scan(&rev); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource revision")
+	}
+
+	revision, err := strconv.ParseInt(rev, 10, 64)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"invalid resource revision",
+			"revision", rev)
+	}
+
+	res := NewResourceDelta(revision)
+
+	if sinceRevision >= revision {
+		return res, nil
+	}
+
+	params := []any{strconv.FormatInt(sinceRevision, 10), rev}
+
+	base := `SELECT
+		change.entity_id,
+		(ARRAY_AGG(change.operation
+			ORDER BY change.txid, change.change_key))[1],
+		(ARRAY_AGG(change.operation
+			ORDER BY change.txid DESC, change.change_key DESC))[1]
+	FROM change
+	WHERE change.entity_type = 'resource'
+		AND change.txid >= $1::TEXT::XID8
+		AND change.txid < $2::TEXT::XID8`
+
+	filter, fp, err := aclFilterExpr(ctx, "change.entity_id::UUID", len(params))
+	if err != nil {
+		return nil, err
+	} else if filter != "" {
+		base += `
+		AND ` + filter
+
+		params = append(params, fp...)
+	}
+
+	base += `
+	GROUP BY change.entity_id
+	ORDER BY change.entity_id
+	LIMIT ALL`
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Params: params,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"since_revision", sinceRevision)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		id, first, last := "", "", ""
+
+		if err := rows.Scan(&id, &first, &last); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select resource change row",
+				"since_revision", sinceRevision)
+		}
+
+		res.Add(id, first, last)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource change rows",
+			"since_revision", sinceRevision)
+	}
+
+	return res, nil
+}
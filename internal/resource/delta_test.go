@@ -0,0 +1,74 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestGetResourcesDelta(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, &cache.MockCache{}, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT pg_snapshot_xmin").
+		WillReturnRows(mock.NewRows([]string{"xmin"}).AddRow("120"))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM change").
+		WithArgs("100", "120", TestID, pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{
+			"entity_id", "first", "last",
+		}).AddRow(
+			"a", "create", "update",
+		).AddRow(
+			"b", "update", "update",
+		).AddRow(
+			"c", "create", "delete",
+		).AddRow(
+			"d", "delete", "create",
+		))
+
+	res, err := svc.GetResourcesDelta(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Revision != 120 {
+		t.Errorf("Expected revision: 120, got: %v", res.Revision)
+	}
+
+	if len(res.Created) != 1 || res.Created[0] != "a" {
+		t.Errorf("Expected created: [a], got: %v", res.Created)
+	}
+
+	if len(res.Updated) != 2 || res.Updated[0] != "b" ||
+		res.Updated[1] != "d" {
+		t.Errorf("Expected updated: [b d], got: %v", res.Updated)
+	}
+
+	if len(res.Deleted) != 1 || res.Deleted[0] != "c" {
+		t.Errorf("Expected deleted: [c], got: %v", res.Deleted)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+
+	if _, err := svc.GetResourcesDelta(ctx, -1); err == nil {
+		t.Error("Expected error for negative since_revision")
+	}
+}
@@ -0,0 +1,267 @@
+package resource
+
+import (
+	"context"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/google/uuid"
+)
+
+// validateDependsOn adds an invalid depends_on field to the field errors. The
+// resource IDs are stored in their canonical form, so that they can be matched
+// against the resources table.
+func (r *Resource) validateDependsOn(f *errors.FieldErrors) {
+	if !r.DependsOn.Set {
+		return
+	}
+
+	if !r.DependsOn.Valid {
+		f.Add("depends_on", errors.FieldNull, "depends_on must not be null")
+
+		return
+	}
+
+	ids := make([]string, 0, len(r.DependsOn.Value))
+
+	seen := map[string]bool{}
+
+	for _, id := range r.DependsOn.Value {
+		u, err := uuid.Parse(id)
+		if err != nil {
+			f.Add("depends_on", errors.FieldInvalid,
+				"invalid depends_on: invalid resource_id: "+id)
+
+			return
+		}
+
+		if u.String() == r.ResourceID.Value {
+			f.Add("depends_on", errors.FieldInvalid,
+				"invalid depends_on: a resource cannot depend on itself")
+
+			return
+		}
+
+		if !seen[u.String()] {
+			seen[u.String()] = true
+
+			ids = append(ids, u.String())
+		}
+	}
+
+	r.DependsOn.Value = ids
+}
+
+// checkDependencies returns an error if any of the resources a resource depends
+// on do not exist, or depend on the resource themselves, directly or through
+// other resources. Resources which are known to be created later, such as by
+// the same import, are not required to exist yet.
+func (s *Service) checkDependencies(ctx context.Context,
+	tx sqldb.SQLTX,
+	v *Resource,
+	pending map[string]bool,
+) error {
+	if !v.DependsOn.Set || len(v.DependsOn.Value) == 0 {
+		return nil
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Tx:   tx,
+		Type: sqldb.QuerySelect,
+		Base: `WITH RECURSIVE dependency(id) AS (
+			SELECT UNNEST($1::TEXT[])
+			UNION
+			SELECT dep.id
+			FROM resource
+			JOIN dependency ON resource.resource_id::TEXT = dependency.id
+			CROSS JOIN LATERAL UNNEST(resource.depends_on) AS dep(id)
+		)
+		SELECT
+			ARRAY(SELECT resource.resource_id::TEXT FROM resource
+				WHERE resource.resource_id::TEXT = ANY($1::TEXT[])),
+			EXISTS (SELECT 1 FROM dependency WHERE dependency.id = $2::TEXT)`,
+		Params: []any{v.DependsOn.Value, v.ResourceID.Value},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "",
+			"resource", v)
+	}
+
+	found, cycle := []string{}, false
+
+	if err := row.Scan(&found, &cycle); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource dependencies",
+			"resource", v)
+	}
+
+	f := errors.FieldErrors{}
+
+	exists := map[string]bool{}
+
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	for _, id := range v.DependsOn.Value {
+		if !exists[id] && !pending[id] {
+			f.Add("depends_on", errors.FieldInvalid,
+				"invalid depends_on: resource not found: "+id)
+		}
+	}
+
+	if cycle {
+		f.Add("depends_on", errors.FieldInvalid,
+			"invalid depends_on: dependency cycle")
+	}
+
+	return f.Err("resource", v)
+}
+
+// cascadeStatus marks the active resources which depend on a resource,
+// directly or through other resources, as degraded when it reports an error,
+// and restores the resources it degraded when it becomes active again. It has
+// no effect unless cascading resource status is enabled.
+func (s *Service) cascadeStatus(ctx context.Context,
+	tx sqldb.SQLTX,
+	id, status string,
+) error {
+	if !s.cfg.ResourceCascadeStatus() {
+		return nil
+	}
+
+	set, where := "", ""
+
+	switch status {
+	case request.StatusError:
+		set = `status = '` + request.StatusDegraded + `',
+			status_data = COALESCE(resource.status_data, '{}'::JSONB) ||
+				JSONB_BUILD_OBJECT('degraded_by', $1::TEXT),
+			updated_at = CURRENT_TIMESTAMP`
+		where = `resource.status = '` + request.StatusActive + `'`
+	case request.StatusActive:
+		set = `status = '` + request.StatusActive + `',
+			status_data = resource.status_data - 'degraded_by',
+			updated_at = CURRENT_TIMESTAMP`
+		where = `resource.status = '` + request.StatusDegraded + `'
+			AND resource.status_data->>'degraded_by' = $1::TEXT`
+	default:
+		return nil
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Tx:   tx,
+		Type: sqldb.QueryExec,
+		Base: `WITH RECURSIVE dependent(id) AS (
+			SELECT resource.resource_id::TEXT FROM resource
+			WHERE resource.depends_on @> ARRAY[$1::TEXT]
+			UNION
+			SELECT resource.resource_id::TEXT
+			FROM resource
+			JOIN dependent ON resource.depends_on @> ARRAY[dependent.id]
+		)
+		UPDATE resource SET ` + set + `
+		FROM dependent
+		WHERE resource.resource_id::TEXT = dependent.id
+			AND ` + where + `
+		RETURNING resource.resource_id::TEXT`,
+		Params: []any{id},
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "",
+			"resource_id", id,
+			"status", status)
+	}
+
+	defer rows.Close()
+
+	ids := []string{}
+
+	for rows.Next() {
+		rID := ""
+
+		if err := rows.Scan(&rID); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to update dependent resource status",
+				"resource_id", id,
+				"status", status)
+		}
+
+		ids = append(ids, rID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to update dependent resource status",
+			"resource_id", id,
+			"status", status)
+	}
+
+	for _, rID := range ids {
+		s.deleteResourceCache(ctx, rID)
+	}
+
+	if len(ids) > 0 {
+		s.log.Log(ctx, logger.LvlInfo,
+			"dependent resource status updated",
+			"resource_id", id,
+			"status", status,
+			"dependents", ids)
+	}
+
+	return nil
+}
+
+// GetResourceDependents retrieves the resources which directly depend on a
+// resource, and which also match the search query, if it has one. The current
+// user must have read permission on the resource.
+func (s *Service) GetResourceDependents(ctx context.Context,
+	id string,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*Resource, error) {
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource_id",
+			"id", id)
+	}
+
+	id = u.String()
+
+	if _, err := s.checkAccess(ctx, id, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	q := &search.Query{}
+
+	if query != nil {
+		*q = *query
+	}
+
+	if q.Search == "" {
+		q.Search = "and(depends_on:" + id + ")"
+	} else {
+		q.Search = "and(depends_on:" + id + "," + q.Search + ")"
+	}
+
+	q.Summary = ""
+
+	res, _, err := s.GetResources(ctx, q, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
@@ -0,0 +1,248 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+const TestDependencyUUID = "aabbccdd-eeff-0011-2233-445566778899"
+
+func TestResourceValidateDependsOn(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		v    request.FieldStringArray
+		exp  []string
+		err  bool
+	}{{
+		name: "canonical",
+		v: request.FieldStringArray{
+			Set: true, Valid: true,
+			Value: []string{
+				"AABBCCDD-EEFF-0011-2233-445566778899",
+				TestDependencyUUID,
+			},
+		},
+		exp: []string{TestDependencyUUID},
+	}, {
+		name: "null",
+		v:    request.FieldStringArray{Set: true},
+		err:  true,
+	}, {
+		name: "invalid",
+		v: request.FieldStringArray{
+			Set: true, Valid: true, Value: []string{"invalid"},
+		},
+		err: true,
+	}, {
+		name: "self",
+		v: request.FieldStringArray{
+			Set: true, Valid: true,
+			Value: []string{TestResource.ResourceID.Value},
+		},
+		err: true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &resource.Resource{
+				ResourceID: TestResource.ResourceID,
+				DependsOn:  tt.v,
+			}
+
+			err := r.Validate(config.NewDefault())
+			if tt.err {
+				if !errors.Has(err, errors.ErrInvalidRequest) {
+					t.Errorf("Expected invalid request error, got: %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(r.DependsOn.Value) != len(tt.exp) ||
+				r.DependsOn.Value[0] != tt.exp[0] {
+				t.Errorf("Expected depends_on: %v, got: %v", tt.exp,
+					r.DependsOn.Value)
+			}
+		})
+	}
+}
+
+func TestCreateResourceDependencies(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("WITH RECURSIVE dependency").
+		WithArgs([]string{TestDependencyUUID},
+			TestResource.ResourceID.Value).
+		WillReturnRows(mock.NewRows([]string{"found", "cycle"}).
+			AddRow([]string{}, true))
+
+	mock.ExpectRollback()
+
+	v := TestResource
+
+	v.DependsOn = request.FieldStringArray{
+		Set: true, Valid: true, Value: []string{TestDependencyUUID},
+	}
+
+	_, err = svc.CreateResource(ctx, &v)
+
+	var e *errors.Error
+
+	if !errors.As(err, &e) {
+		t.Fatalf("Expected invalid request error, got: %v", err)
+	}
+
+	exp := []string{
+		"invalid depends_on: resource not found: " + TestDependencyUUID,
+		"invalid depends_on: dependency cycle",
+	}
+
+	if len(e.Fields) != len(exp) {
+		t.Fatalf("Expected fields: %v, got: %v", len(exp), len(e.Fields))
+	}
+
+	for i, msg := range exp {
+		if e.Fields[i].Message != msg {
+			t.Errorf("Expected message: %v, got: %v", msg,
+				e.Fields[i].Message)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestUpdateResourceCascadeStatus(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	cfg := config.NewDefault()
+
+	cfg.SetService(&config.ServiceConfig{
+		Name:                  config.DefaultServiceName,
+		ResourceCascadeStatus: true,
+	})
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(cfg, md, mc, nil, nil, nil)
+
+	mockResourceAccess(mock)
+
+	r := TestResource
+
+	r.Status = request.FieldString{
+		Set: true, Valid: true, Value: request.StatusError,
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("UPDATE resource").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg()).
+		WillReturnRows(mockResourceRowsFor(mock, r))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("WITH RECURSIVE dependent(.+)UPDATE resource").
+		WithArgs(TestResource.ResourceID.Value).
+		WillReturnRows(mock.NewRows([]string{"resource_id"}).
+			AddRow(TestDependencyUUID))
+
+	if _, err := svc.UpdateResource(ctx, &resource.Resource{
+		ResourceID: TestResource.ResourceID,
+		Status:     r.Status,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestGetResourceDependents(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockResourceAccess(mock)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), TestDependencyUUID,
+			request.StatusActive).
+		WillReturnRows(mockResourceKeyRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceRows(mock))
+
+	res, err := svc.GetResourceDependents(ctx, TestDependencyUUID,
+		&search.Query{Search: "status:active"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 ||
+		res[0].ResourceID.Value != TestResource.ResourceID.Value {
+		t.Errorf("Expected dependent: %v, got: %+v",
+			TestResource.ResourceID.Value, res)
+	}
+
+	if _, err := svc.GetResourceDependents(ctx, "invalid", nil,
+		nil); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package resource
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ResourceDiff values contain the differences between the definition of a
+// stored resource and its definition in the current import repository file.
+// Only the definition fields present in the repository file are compared, as
+// those are the only fields an import would change.
+type ResourceDiff struct {
+	ResourceID     string       `json:"resource_id"`
+	CommitHash     string       `json:"commit_hash"`
+	RepoCommitHash string       `json:"repo_commit_hash"`
+	InRepo         bool         `json:"in_repo"`
+	Changed        bool         `json:"changed"`
+	Fields         []*FieldDiff `json:"fields"`
+}
+
+// FieldDiff values contain the stored and repository values of a changed
+// resource definition field.
+type FieldDiff struct {
+	Field string `json:"field"`
+	Live  any    `json:"live"`
+	Repo  any    `json:"repo"`
+}
+
+// DiffResource compares the definition of a stored resource by ID with its
+// definition in the current import repository file, so that drift can be
+// reviewed before the resource is imported. The current user must have read
+// permission on the resource.
+func (s *Service) DiffResource(ctx context.Context,
+	authSvc AuthService,
+	id string,
+) (*ResourceDiff, error) {
+	live, err := s.GetResource(ctx, id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ar, err := authSvc.GetAccountRepo(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to get account repository")
+	}
+
+	cli, err := s.getRepoClient(ar.Repo.Value)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"unable to create repository client")
+	}
+
+	hash, err := cli.Commit(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"unable to get repository commit hash")
+	}
+
+	res := &ResourceDiff{
+		ResourceID:     live.ResourceID.Value,
+		CommitHash:     live.CommitHash.Value,
+		RepoCommitHash: hash,
+		Fields:         []*FieldDiff{},
+	}
+
+	vb, err := cli.Get(ctx, "resources/"+live.ResourceID.Value+".yaml")
+	if err != nil {
+		if errors.Has(err, errors.ErrNotFound) {
+			return res, nil
+		}
+
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"unable to get resource repository file",
+			"resource_id", id)
+	}
+
+	res.InRepo = true
+
+	keys := map[string]any{}
+
+	a := &Resource{}
+
+	if err := yaml.Unmarshal(vb, &keys); err != nil {
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"invalid repository resource contents",
+			"resource_id", id,
+			"contents", string(vb))
+	}
+
+	if err := yaml.Unmarshal(vb, a); err != nil {
+		return nil, errors.Wrap(err, errors.ErrImport,
+			"invalid repository resource contents",
+			"resource_id", id,
+			"contents", string(vb))
+	}
+
+	ld, err := live.Definition()
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := a.Definition()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range definitionFields {
+		if _, ok := keys[k]; !ok {
+			continue
+		}
+
+		if !reflect.DeepEqual(ld[k], rd[k]) {
+			res.Fields = append(res.Fields, &FieldDiff{
+				Field: k,
+				Live:  ld[k],
+				Repo:  rd[k],
+			})
+		}
+	}
+
+	res.Changed = len(res.Fields) > 0
+
+	return res, nil
+}
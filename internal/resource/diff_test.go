@@ -0,0 +1,112 @@
+package resource_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+type mockDiffRepoClient struct {
+	mockRepoClient
+	file string
+}
+
+func (m *mockDiffRepoClient) Get(ctx context.Context, filePath string,
+) ([]byte, error) {
+	if m.file == "" {
+		return nil, errors.New(errors.ErrNotFound, "file not found",
+			"path", filePath)
+	}
+
+	return []byte(m.file), nil
+}
+
+func TestDiffResource(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		file   string
+		inRepo bool
+		fields []string
+	}{{
+		name:   "changed",
+		file:   "name: changed\nkey_field: " + TestResource.KeyField.Value,
+		inRepo: true,
+		fields: []string{"name"},
+	}, {
+		name:   "unchanged",
+		file:   "name: " + TestResource.Name.Value,
+		inRepo: true,
+		fields: []string{},
+	}, {
+		name:   "not in repo",
+		fields: []string{},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := mockAuthContext()
+
+			md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+			svc.SetRepoClient(&mockDiffRepoClient{file: tt.file})
+
+			mockResourceAccess(mock)
+
+			mockTransaction(mock)
+
+			mock.ExpectQuery("SELECT (.+) FROM resource").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRows(mock))
+
+			res, err := svc.DiffResource(ctx, &mockAuthSvc{},
+				TestResource.ResourceID.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.InRepo != tt.inRepo {
+				t.Errorf("Expected in_repo: %v, got: %v", tt.inRepo,
+					res.InRepo)
+			}
+
+			if res.RepoCommitHash != "test" {
+				t.Errorf("Expected repo_commit_hash: test, got: %v",
+					res.RepoCommitHash)
+			}
+
+			if res.Changed != (len(tt.fields) > 0) {
+				t.Errorf("Expected changed: %v, got: %v",
+					len(tt.fields) > 0, res.Changed)
+			}
+
+			if len(res.Fields) != len(tt.fields) {
+				t.Fatalf("Expected fields: %v, got: %+v", tt.fields,
+					res.Fields)
+			}
+
+			for i, f := range tt.fields {
+				if res.Fields[i].Field != f {
+					t.Errorf("Expected field: %v, got: %v", f,
+						res.Fields[i].Field)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet database expectations: %v", err)
+			}
+		})
+	}
+}
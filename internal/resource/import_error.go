@@ -0,0 +1,151 @@
+package resource
+
+import (
+	"context"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// ImportError values contain a repository file which was quarantined by the
+// most recent import which read it, because its contents could not be parsed
+// or were not a valid resource.
+type ImportError struct {
+	Path       request.FieldString `json:"path"        yaml:"path"`
+	Error      request.FieldString `json:"error"       yaml:"error"`
+	CommitHash request.FieldString `json:"commit_hash" yaml:"commit_hash"`
+	CreatedAt  request.FieldTime   `json:"created_at"  yaml:"created_at"`
+}
+
+// newImportError creates a new import error for a repository file.
+func newImportError(path, commit string, err error) *ImportError {
+	return &ImportError{
+		Path:       request.FieldString{Set: true, Valid: true, Value: path},
+		Error:      request.FieldString{Set: true, Valid: true, Value: err.Error()},
+		CommitHash: request.FieldString{Set: true, Valid: true, Value: commit},
+	}
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (e *ImportError) ScanDest() []any {
+	return sqldb.ScanFields("import_error", importErrorFields, nil,
+		map[string]any{
+			"path":        &e.Path,
+			"error":       &e.Error,
+			"commit_hash": &e.CommitHash,
+			"created_at":  &e.CreatedAt,
+		})
+}
+
+// importErrorFields contain the fields for import errors.
+var importErrorFields = []*sqldb.Field{{
+	Name:    "path",
+	Type:    sqldb.FieldString,
+	Table:   "import_error",
+	Primary: true,
+}, {
+	Name:  "error",
+	Type:  sqldb.FieldString,
+	Table: "import_error",
+}, {
+	Name:  "commit_hash",
+	Type:  sqldb.FieldString,
+	Table: "import_error",
+}, {
+	Name:  "created_at",
+	Type:  sqldb.FieldTime,
+	Table: "import_error",
+}}
+
+// GetImportErrors retrieves the repository files quarantined by imports,
+// ordered by path.
+func (s *Service) GetImportErrors(ctx context.Context,
+) ([]*ImportError, error) {
+	base := sqldb.SelectFields("import_error", importErrorFields, nil,
+		nil) + `ORDER BY import_error.path`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: importErrorFields,
+	})
+
+	q.Limit = q.Config.DBMaxSize()
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	defer rows.Close()
+
+	res := []*ImportError{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		e := &ImportError{}
+
+		if err := rows.Scan(e.ScanDest()...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select import error row")
+		}
+
+		res = append(res, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select import error rows")
+	}
+
+	return res, nil
+}
+
+// setImportErrors records the repository files quarantined by an import,
+// replacing the errors recorded for every other file, except for the files
+// which the import did not read.
+func (s *Service) setImportErrors(ctx context.Context,
+	quarantined []*ImportError,
+	skipped []string,
+) error {
+	paths := make([]string, len(quarantined))
+	msgs := make([]string, len(quarantined))
+	commits := make([]string, len(quarantined))
+
+	for i, e := range quarantined {
+		paths[i] = e.Path.Value
+		msgs[i] = e.Error.Value
+		commits[i] = e.CommitHash.Value
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryExec,
+		Base: `WITH resolved AS (
+			DELETE FROM import_error
+			WHERE NOT import_error.path = ANY($1::TEXT[])
+				AND NOT import_error.path = ANY($2::TEXT[])
+		)
+		INSERT INTO import_error (path, error, commit_hash)
+		SELECT * FROM UNNEST($1::TEXT[], $3::TEXT[], $4::TEXT[])
+		ON CONFLICT (account_id, path) DO UPDATE SET
+			error = EXCLUDED.error,
+			commit_hash = EXCLUDED.commit_hash,
+			created_at = CURRENT_TIMESTAMP`,
+		Params: []any{paths, skipped, msgs, commits},
+	})
+
+	if _, err := q.Exec(ctx); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "",
+			"quarantined", paths)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package resource_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/repo"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+type mockInvalidRepoClient struct {
+	mockRepoClient
+}
+
+func (m *mockInvalidRepoClient) ListAll(ctx context.Context, dirPath string,
+) ([]repo.Item, error) {
+	return []repo.Item{{
+		Path: "resources/" + TestUUID + ".yaml",
+		Type: "file",
+	}}, nil
+}
+
+func (m *mockInvalidRepoClient) Get(ctx context.Context, filePath string,
+) ([]byte, error) {
+	return []byte("name: [invalid"), nil
+}
+
+func TestImportResourcesQuarantine(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	svc.SetRepoClient(&mockInvalidRepoClient{})
+
+	ma := &mockAuthSvc{}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT resource_commit_hash FROM account").
+		WillReturnRows(mockAccountCommitHashRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM import_error (.+) INSERT INTO import_error").
+		WithArgs([]string{"resources/" + TestUUID + ".yaml"}, []string{},
+			pgxmock.AnyArg(), []string{"test"}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("UPDATE account SET resource_commit_hash").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAccountCommitHashRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("DELETE FROM resource").
+		WithArgs("test", []string{TestUUID}).
+		WillReturnRows(mock.NewRows([]string{"resource_id"}))
+
+	if err := svc.ImportResources(ctx, true, nil, ma); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := ma.v.RepoStatusData.Value["resources_quarantined"]; v != 1 {
+		t.Errorf("Expected resources_quarantined: 1, got: %v", v)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestGetImportErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM import_error").
+		WillReturnRows(mock.NewRows([]string{
+			"path", "error", "commit_hash", "created_at",
+		}).AddRow("resources/"+TestUUID+".yaml", "invalid resource",
+			"test", time.Unix(1, 0)))
+
+	res, err := svc.GetImportErrors(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || res[0].Path.Value != "resources/"+TestUUID+".yaml" {
+		t.Errorf("Expected import error for: %v, got: %+v", TestUUID, res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package resource
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// Resource data key strategies, which determine how the key of each resource
+// data item is extracted from a payload.
+const (
+	KeyStrategyField     = "field"
+	KeyStrategyComposite = "composite"
+	KeyStrategyTemplate  = "template"
+	KeyStrategyHash      = "hash"
+)
+
+// keyFieldSeparator separates the field names of a composite key_field.
+const keyFieldSeparator = ","
+
+// keyValueSeparator joins the field values of a composite key.
+const keyValueSeparator = ":"
+
+// keyFunc values extract a resource data key from a payload item. An empty key
+// is returned if no key can be extracted.
+type keyFunc func(item map[string]any) string
+
+// keyValue returns a field value as a string to be used in a key.
+func keyValue(v any) (string, bool) {
+	switch vt := v.(type) {
+	case string:
+		return vt, true
+	default:
+		b, err := json.Marshal(vt)
+		if err != nil {
+			return "", false
+		}
+
+		return string(b), true
+	}
+}
+
+// parseKeyTemplate parses a key_field template for the template key strategy.
+func parseKeyTemplate(text string) (*template.Template, error) {
+	tpl, err := template.New("key").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid key_field template",
+			"key_field", text)
+	}
+
+	return tpl, nil
+}
+
+// newKeyFunc creates the function used to extract resource data keys from
+// payload items, using the key strategy, field and regular expression of the
+// resource.
+func newKeyFunc(resource *Resource) (keyFunc, error) {
+	var re *regexp.Regexp
+
+	if resource.KeyRegex.Value != "" {
+		var err error
+
+		re, err = regexp.Compile(resource.KeyRegex.Value)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid resource key_regex",
+				"resource", resource)
+		}
+	}
+
+	match := func(key string) string {
+		if re == nil {
+			return key
+		}
+
+		return re.FindString(key)
+	}
+
+	strategy := resource.KeyStrategy.Value
+	if strategy == "" {
+		strategy = KeyStrategyField
+	}
+
+	if strategy != KeyStrategyHash && resource.KeyField.Value == "" {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"unable to extract resource data: missing key field",
+			"resource", resource)
+	}
+
+	switch strategy {
+	case KeyStrategyField:
+		return func(item map[string]any) string {
+			v, ok := item[resource.KeyField.Value]
+			if !ok {
+				return ""
+			}
+
+			key, ok := keyValue(v)
+			if !ok {
+				return ""
+			}
+
+			return match(key)
+		}, nil
+	case KeyStrategyComposite:
+		fields := strings.Split(resource.KeyField.Value, keyFieldSeparator)
+
+		for i, f := range fields {
+			fields[i] = strings.TrimSpace(f)
+		}
+
+		return func(item map[string]any) string {
+			values := make([]string, 0, len(fields))
+
+			for _, f := range fields {
+				v, ok := item[f]
+				if !ok {
+					return ""
+				}
+
+				value, ok := keyValue(v)
+				if !ok {
+					return ""
+				}
+
+				values = append(values, value)
+			}
+
+			return match(strings.Join(values, keyValueSeparator))
+		}, nil
+	case KeyStrategyTemplate:
+		tpl, err := parseKeyTemplate(resource.KeyField.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(item map[string]any) string {
+			buf := &bytes.Buffer{}
+
+			if err := tpl.Execute(buf, item); err != nil {
+				return ""
+			}
+
+			return match(buf.String())
+		}, nil
+	case KeyStrategyHash:
+		return func(item map[string]any) string {
+			b, err := request.CanonicalJSON(item)
+			if err != nil {
+				return ""
+			}
+
+			sum := sha256.Sum256(b)
+
+			return hex.EncodeToString(sum[:])
+		}, nil
+	default:
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource key_strategy",
+			"resource", resource)
+	}
+}
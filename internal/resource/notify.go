@@ -0,0 +1,125 @@
+package resource
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/notify"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+// importFailureEmail is the email notification template for failed resource
+// imports.
+var importFailureEmail = notify.MustTemplate("import_failure",
+	`Resource import failed for account {{.AccountID}}`,
+	`The import of resources from the repository of account {{.AccountID}}
+failed at {{.FailedAt.UTC.Format "2006-01-02 15:04:05 MST"}}.
+
+Error: {{.Error}}
+
+Resources imported previously are unchanged. The import is retried at the next
+import interval, or when requested.
+`)
+
+// importFailure values contain the data of a failed resource import email
+// notification.
+type importFailure struct {
+	AccountID string
+	Error     string
+	FailedAt  time.Time
+}
+
+// SetNotifyTransport sets the transport used to send email notifications.
+func (s *Service) SetNotifyTransport(t notify.Transport) {
+	s.notify = notify.NewSender(s.cfg, t, s.metric)
+}
+
+// getAccountNotifyEmails retrieves the notification recipients of the account.
+func (s *Service) getAccountNotifyEmails(ctx context.Context,
+) ([]string, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT account.data FROM account
+			WHERE account.account_id = $1`,
+		Fields: []*sqldb.Field{{
+			Name:  "data",
+			Type:  sqldb.FieldJSON,
+			Table: "account",
+		}},
+		Params: []any{accountID},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	a := &auth.Account{}
+
+	if err := row.Scan(&a.Data); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return []string{}, nil
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select account data")
+	}
+
+	return a.NotifyEmails(), nil
+}
+
+// notifyImportFailure sends an email notification of a failed resource import
+// to the notification recipients of the account, if email notifications are
+// configured. Delivery failures are logged, and do not affect the import.
+func (s *Service) notifyImportFailure(ctx context.Context, importErr error) {
+	if !s.notify.Enabled() {
+		return
+	}
+
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return
+	}
+
+	to, err := s.getAccountNotifyEmails(ctx)
+	if err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to get import failure notification recipients",
+			"error", err)
+
+		return
+	}
+
+	if len(to) == 0 {
+		return
+	}
+
+	msg := importErr.Error()
+
+	if e, ok := importErr.(*errors.Error); ok && e.Msg != "" {
+		msg = e.Msg
+	}
+
+	if err := s.notify.Send(ctx, to, importFailureEmail, &importFailure{
+		AccountID: accountID,
+		Error:     msg,
+		FailedAt:  time.Now(),
+	}); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to send import failure notification",
+			"error", err)
+	}
+}
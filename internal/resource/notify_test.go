@@ -0,0 +1,77 @@
+package resource_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/notify"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+type mockFailRepoClient struct {
+	mockRepoClient
+}
+
+func (m *mockFailRepoClient) Commit(ctx context.Context) (string, error) {
+	return "", errors.New(errors.ErrImport, "repository unavailable")
+}
+
+func TestImportResourcesNotify(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	svc.SetRepoClient(&mockFailRepoClient{})
+
+	mt := &notify.MockTransport{}
+
+	svc.SetNotifyTransport(mt)
+
+	ma := &mockAuthSvc{}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT account.data FROM account").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{"data"}).
+			AddRow(`{"notify":{"emails":["ops@example.com"]}}`))
+
+	if err := svc.ImportResources(ctx, true, nil, ma); !errors.Has(err,
+		errors.ErrImport) {
+		t.Fatalf("Expected error code: %v, got: %v", errors.ErrImport, err)
+	}
+
+	if ma.v.RepoStatus.Value != request.StatusError {
+		t.Errorf("Expected repo status: %v, got: %v",
+			request.StatusError, ma.v.RepoStatus.Value)
+	}
+
+	msgs := mt.Messages()
+	if len(msgs) != 1 {
+		t.Fatalf("Expected messages: 1, got: %v", len(msgs))
+	}
+
+	if len(msgs[0].To) != 1 || msgs[0].To[0] != "ops@example.com" {
+		t.Errorf("Expected to: [ops@example.com], got: %v", msgs[0].To)
+	}
+
+	if !strings.Contains(string(msgs[0].Data), "Resource import failed") {
+		t.Errorf("Expected import failure subject, got: %s", msgs[0].Data)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
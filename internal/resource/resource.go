@@ -4,10 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"maps"
 	"math/rand/v2"
 	"path/filepath"
 	"reflect"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,8 +19,10 @@ import (
 	"github.com/dhaifley/apigo/internal/cache"
 	"github.com/dhaifley/apigo/internal/config"
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/logger"
 	"github.com/dhaifley/apigo/internal/metric"
+	"github.com/dhaifley/apigo/internal/notify"
 	"github.com/dhaifley/apigo/internal/repo"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/search"
@@ -29,6 +33,10 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// importErrorSamples is the number of representative errors kept for each
+// group of similar errors reported by a failed resource import.
+const importErrorSamples = 3
+
 // AuthService values are used to access authentication services.
 type AuthService interface {
 	GetAccountRepo(ctx context.Context) (*auth.AccountRepo, error)
@@ -41,10 +49,14 @@ type Service struct {
 	cfg           *config.Config
 	db            sqldb.SQLDB
 	cache         cache.Accessor
+	client        *httpclient.Client
+	alertClient   *httpclient.Client
 	log           logger.Logger
 	metric        metric.Recorder
 	tracer        trace.Tracer
 	getRepoClient func(repoURL string) (repo.Client, error)
+	notify        *notify.Sender
+	loads         cache.Group
 }
 
 // NewService creates a new service.
@@ -86,16 +98,19 @@ func NewService(
 	}
 
 	s := &Service{
-		cfg:    cfg,
-		db:     db,
-		cache:  cache,
-		log:    log,
-		metric: metric,
-		tracer: tracer,
+		cfg:         cfg,
+		db:          db,
+		cache:       cache,
+		client:      httpclient.NewClient(cfg, log, metric, tracer),
+		alertClient: httpclient.NewPublicClient(cfg, log, metric, tracer),
+		notify:      notify.NewSender(cfg, nil, metric),
+		log:         log,
+		metric:      metric,
+		tracer:      tracer,
 	}
 
 	s.getRepoClient = func(repoURL string) (repo.Client, error) {
-		return repo.NewClient(repoURL, s.metric, s.tracer)
+		return repo.NewClient(repoURL, s.client, s.metric, s.tracer)
 	}
 
 	return s
@@ -110,159 +125,209 @@ func (s *Service) SetRepoClient(cli repo.Client) {
 
 // Resource values represent individual external resource conditions.
 type Resource struct {
-	ResourceID     request.FieldString `json:"resource_id"`
-	Name           request.FieldString `json:"name"`
-	Version        request.FieldString `json:"version"`
-	Description    request.FieldString `json:"description"`
-	Status         request.FieldString `json:"status"`
-	StatusData     request.FieldJSON   `json:"status_data"`
-	KeyField       request.FieldString `json:"key_field"`
-	KeyRegex       request.FieldString `json:"key_regex"`
-	ClearCondition request.FieldString `json:"clear_condition"`
-	ClearAfter     request.FieldInt64  `json:"clear_after"`
-	ClearDelay     request.FieldInt64  `json:"clear_delay"`
-	Data           request.FieldJSON   `json:"data"`
-	Source         request.FieldString `json:"source"`
-	CommitHash     request.FieldString `json:"commit_hash"`
-	CreatedAt      request.FieldTime   `json:"created_at"`
-	CreatedBy      request.FieldString `json:"created_by"`
-	UpdatedAt      request.FieldTime   `json:"updated_at"`
-	UpdatedBy      request.FieldString `json:"updated_by"`
+	ResourceID      request.FieldString      `json:"resource_id"      yaml:"resource_id"`
+	Name            request.FieldString      `json:"name"             yaml:"name"`
+	Version         request.FieldString      `json:"version"          yaml:"version"`
+	Description     request.FieldString      `json:"description"      yaml:"description"`
+	Status          request.FieldString      `json:"status"           yaml:"status"`
+	StatusData      request.FieldJSON        `json:"status_data"      yaml:"status_data"`
+	KeyField        request.FieldString      `json:"key_field"        yaml:"key_field"`
+	KeyRegex        request.FieldString      `json:"key_regex"        yaml:"key_regex"`
+	ClearCondition  request.FieldString      `json:"clear_condition"  yaml:"clear_condition"`
+	ClearAfter      request.FieldInt64       `json:"clear_after"      yaml:"clear_after"`
+	ClearDelay      request.FieldInt64       `json:"clear_delay"      yaml:"clear_delay"`
+	DuplicatePolicy request.FieldString      `json:"duplicate_policy" yaml:"duplicate_policy"`
+	KeyStrategy     request.FieldString      `json:"key_strategy"     yaml:"key_strategy"`
+	DependsOn       request.FieldStringArray `json:"depends_on"       yaml:"depends_on"`
+	Data            request.FieldJSON        `json:"data"             yaml:"data"`
+	Source          request.FieldString      `json:"source"           yaml:"source"`
+	CommitHash      request.FieldString      `json:"commit_hash"      yaml:"commit_hash"`
+	CreatedAt       request.FieldTime        `json:"created_at"       yaml:"created_at"`
+	CreatedBy       request.FieldString      `json:"created_by"       yaml:"created_by"`
+	UpdatedAt       request.FieldTime        `json:"updated_at"       yaml:"updated_at"`
+	UpdatedBy       request.FieldString      `json:"updated_by"       yaml:"updated_by"`
+	CreatedByUser   request.FieldJSON        `json:"created_by_user"  yaml:"created_by_user"`
+	UpdatedByUser   request.FieldJSON        `json:"updated_by_user"  yaml:"updated_by_user"`
+	Tags            request.FieldStringArray `json:"tags"             yaml:"tags"`
+	Revisions       request.FieldInt64       `json:"revisions"        yaml:"revisions"`
+	DataCount       request.FieldInt64       `json:"data_count"       yaml:"data_count"`
+	AgeSeconds      request.FieldInt64       `json:"age_seconds"      yaml:"age_seconds"`
+	ClearedRatio    request.FieldFloat64     `json:"cleared_ratio"    yaml:"cleared_ratio"`
 }
 
-// Validate checks that the value contains valid data.
+// Validate checks that the value contains valid data. All invalid fields are
+// reported in the returned error.
 func (r *Resource) Validate(cfg *config.Config) error {
+	f := errors.FieldErrors{}
+
+	r.validate(cfg, &f)
+
+	return f.Err("resource", r)
+}
+
+// validate adds the invalid fields of the value to the field errors.
+func (r *Resource) validate(cfg *config.Config, f *errors.FieldErrors) {
 	if r.ResourceID.Set {
 		if !r.ResourceID.Valid {
-			return errors.New(errors.ErrInvalidRequest,
-				"resource_id must not be null",
-				"resource", r)
-		}
-
-		if !request.ValidResourceID(r.ResourceID.Value) {
-			return errors.New(errors.ErrInvalidRequest,
-				"invalid resource_id",
-				"resource", r)
+			f.Add("resource_id", errors.FieldNull,
+				"resource_id must not be null")
+		} else if !request.ValidResourceID(r.ResourceID.Value) {
+			f.Add("resource_id", errors.FieldInvalid, "invalid resource_id")
 		}
 	}
 
 	if r.Name.Set && !r.Name.Valid {
-		return errors.New(errors.ErrInvalidRequest,
-			"name must not be null",
-			"resource", r)
+		f.Add("name", errors.FieldNull, "name must not be null")
 	}
 
 	if r.KeyField.Set && !r.KeyField.Valid {
-		return errors.New(errors.ErrInvalidRequest,
-			"key_field must not be null",
-			"resource", r)
+		f.Add("key_field", errors.FieldNull, "key_field must not be null")
 	}
 
 	if r.ClearCondition.Set && r.ClearCondition.Valid {
 		p := search.NewParser(bytes.NewBufferString(r.ClearCondition.Value))
 
-		if _, err := p.Parse(); err != nil {
-			return errors.Wrap(err, errors.ErrInvalidRequest,
-				"invalid clear_condition",
-				"resource", r)
+		ast, err := p.Parse()
+		if err == nil {
+			// Evaluating the condition against an empty item reports
+			// invalid expressions, such as calls to unknown functions.
+			_, err = ast.MatchItem(map[string]any{}, nil)
+		}
+
+		if err != nil {
+			msg := err.Error()
+
+			if e, ok := err.(*errors.Error); ok && e.Msg != "" {
+				msg = e.Msg
+			}
+
+			f.Add("clear_condition", errors.FieldInvalid,
+				"invalid clear_condition: "+msg)
 		}
 	}
 
 	if r.ClearAfter.Set {
 		if !r.ClearAfter.Valid {
-			return errors.New(errors.ErrInvalidRequest,
-				"clear_after must not be null",
-				"resource", r)
-		}
-
-		if r.ClearAfter.Value < 0 ||
+			f.Add("clear_after", errors.FieldNull,
+				"clear_after must not be null")
+		} else if r.ClearAfter.Value < 0 ||
 			r.ClearAfter.Value > int64(cfg.ResourceDataRetention().Seconds()) {
-			return errors.New(errors.ErrInvalidRequest,
-				"invalid clear_after",
-				"resource", r)
+			f.Add("clear_after", errors.FieldInvalid, "invalid clear_after")
 		}
 	}
 
 	if r.ClearDelay.Set {
 		if !r.ClearDelay.Valid {
-			return errors.New(errors.ErrInvalidRequest,
-				"clear_delay must not be null",
-				"resource", r)
+			f.Add("clear_delay", errors.FieldNull,
+				"clear_delay must not be null")
+		} else if r.ClearDelay.Value < 0 || r.ClearDelay.Value > 60*60 {
+			f.Add("clear_delay", errors.FieldInvalid, "invalid clear_delay")
 		}
+	}
 
-		if r.ClearDelay.Value < 0 || r.ClearDelay.Value > 60*60 {
-			return errors.New(errors.ErrInvalidRequest,
-				"invalid clear_delay",
-				"resource", r)
+	if r.DuplicatePolicy.Set {
+		if !r.DuplicatePolicy.Valid {
+			f.Add("duplicate_policy", errors.FieldNull,
+				"duplicate_policy must not be null")
+		} else {
+			switch r.DuplicatePolicy.Value {
+			case DuplicatePolicyLast, DuplicatePolicyFirst,
+				DuplicatePolicyMerge, DuplicatePolicyReject:
+			default:
+				f.Add("duplicate_policy", errors.FieldInvalid,
+					"invalid duplicate_policy")
+			}
 		}
 	}
 
-	if r.Status.Set {
-		if !r.Status.Valid {
-			return errors.New(errors.ErrInvalidRequest,
-				"status must not be null",
-				"resource", r)
-		}
+	if r.KeyStrategy.Set {
+		if !r.KeyStrategy.Valid {
+			f.Add("key_strategy", errors.FieldNull,
+				"key_strategy must not be null")
+		} else {
+			switch r.KeyStrategy.Value {
+			case KeyStrategyField, KeyStrategyComposite, KeyStrategyHash:
+			case KeyStrategyTemplate:
+				if r.KeyField.Set {
+					_, err := parseKeyTemplate(r.KeyField.Value)
 
-		switch r.Status.Value {
-		case request.StatusNew, request.StatusActive, request.StatusInactive,
-			request.StatusError:
-		default:
-			return errors.New(errors.ErrInvalidRequest,
-				"invalid status",
-				"resource", r)
+					f.AddErr("key_field", errors.FieldInvalid, err)
+				}
+			default:
+				f.Add("key_strategy", errors.FieldInvalid,
+					"invalid key_strategy")
+			}
 		}
 	}
 
-	return nil
+	r.validateDependsOn(f)
+
+	if r.Status.Set {
+		if !r.Status.Valid {
+			f.Add("status", errors.FieldNull, "status must not be null")
+		} else {
+			switch r.Status.Value {
+			case request.StatusNew, request.StatusActive,
+				request.StatusInactive, request.StatusError,
+				request.StatusDegraded:
+			default:
+				f.Add("status", errors.FieldInvalid, "invalid status")
+			}
+		}
+	}
 }
 
-// ValidateCreate checks that the value contains valid data for creation.
+// ValidateCreate checks that the value contains valid data for creation. All
+// missing and invalid fields are reported in the returned error.
 func (r *Resource) ValidateCreate(cfg *config.Config) error {
+	f := errors.FieldErrors{}
+
 	if !r.Name.Set {
-		return errors.New(errors.ErrInvalidRequest,
-			"missing name",
-			"resource", r)
+		f.Add("name", errors.FieldRequired, "missing name")
 	}
 
-	if !r.KeyField.Set {
-		return errors.New(errors.ErrInvalidRequest,
-			"missing key_field",
-			"resource", r)
+	if !r.KeyField.Set && r.KeyStrategy.Value != KeyStrategyHash {
+		f.Add("key_field", errors.FieldRequired, "missing key_field")
 	}
 
-	return r.Validate(cfg)
+	r.validate(cfg, &f)
+
+	return f.Err("resource", r)
 }
 
 // ScanDest returns the destination fields for a SQL row scan.
 func (r *Resource) ScanDest(options sqldb.FieldOptions) []any {
-	dest := []any{
-		&r.ResourceID,
-		&r.Name,
-		&r.Version,
-		&r.Description,
-		&r.Status,
-		&r.StatusData,
-		&r.KeyField,
-		&r.KeyRegex,
-		&r.ClearCondition,
-		&r.ClearAfter,
-		&r.ClearDelay,
-		&r.Data,
-		&r.Source,
-		&r.CommitHash,
-	}
-
-	if options != nil && options.Contains(sqldb.OptUserDetails) {
-		dest = append(dest,
-			&r.CreatedAt,
-			&r.CreatedBy,
-			&r.UpdatedAt,
-			&r.UpdatedBy,
-		)
-	}
-
-	return dest
+	return sqldb.ScanFields("resource", resourceFields, options,
+		map[string]any{
+			"resource_id":      &r.ResourceID,
+			"name":             &r.Name,
+			"version":          &r.Version,
+			"description":      &r.Description,
+			"status":           &r.Status,
+			"status_data":      &r.StatusData,
+			"key_field":        &r.KeyField,
+			"key_regex":        &r.KeyRegex,
+			"clear_condition":  &r.ClearCondition,
+			"clear_after":      &r.ClearAfter,
+			"clear_delay":      &r.ClearDelay,
+			"duplicate_policy": &r.DuplicatePolicy,
+			"key_strategy":     &r.KeyStrategy,
+			"depends_on":       &r.DependsOn,
+			"data":             &r.Data,
+			"source":           &r.Source,
+			"commit_hash":      &r.CommitHash,
+			"created_at":       &r.CreatedAt,
+			"created_by":       &r.CreatedBy,
+			"updated_at":       &r.UpdatedAt,
+			"updated_by":       &r.UpdatedBy,
+			"created_by_user":  &r.CreatedByUser,
+			"updated_by_user":  &r.UpdatedByUser,
+			"tags":             &r.Tags,
+			"revisions":        &r.Revisions,
+			"data_count":       &r.DataCount,
+			"age_seconds":      &r.AgeSeconds,
+			"cleared_ratio":    &r.ClearedRatio,
+		})
 }
 
 // resourceFields contain the search fields for resources.
@@ -316,10 +381,23 @@ var resourceFields = []*sqldb.Field{{
 	Name:  "clear_delay",
 	Type:  sqldb.FieldInt,
 	Table: "resource",
+}, {
+	Name:  "duplicate_policy",
+	Type:  sqldb.FieldString,
+	Table: "resource",
+}, {
+	Name:  "key_strategy",
+	Type:  sqldb.FieldString,
+	Table: "resource",
+}, {
+	Name:  "depends_on",
+	Type:  sqldb.FieldArray,
+	Table: "resource",
 }, {
 	Name:  "data",
 	Type:  sqldb.FieldJSON,
 	Table: "resource",
+	Expr:  resourceDataExpr,
 }, {
 	Name:  "source",
 	Type:  sqldb.FieldString,
@@ -337,7 +415,21 @@ var resourceFields = []*sqldb.Field{{
 	Name:   "created_by",
 	Type:   sqldb.FieldString,
 	Option: "user_details",
-	Table:  `"user"`,
+	Table:  "created_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "created_by",
+	Expr:   "created_by_user.user_id",
+}, {
+	Name:   "created_by_email",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "created_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "created_by",
+	Expr:   "created_by_user.email",
+	Hidden: true,
 }, {
 	Name:   "updated_at",
 	Type:   sqldb.FieldTime,
@@ -347,20 +439,114 @@ var resourceFields = []*sqldb.Field{{
 	Name:   "updated_by",
 	Type:   sqldb.FieldString,
 	Option: "user_details",
-	Table:  `"user"`,
+	Table:  "updated_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "updated_by",
+	Expr:   "updated_by_user.user_id",
+}, {
+	Name:   "updated_by_email",
+	Type:   sqldb.FieldString,
+	Option: "user_details",
+	Table:  "updated_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "updated_by",
+	Expr:   "updated_by_user.email",
+	Hidden: true,
+}, {
+	Name:   "created_by_user",
+	Type:   sqldb.FieldJSON,
+	Option: "created_by_user",
+	Table:  "created_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "created_by",
+	Expr:   userDetailsExpr("created_by_user"),
+}, {
+	Name:   "updated_by_user",
+	Type:   sqldb.FieldJSON,
+	Option: "updated_by_user",
+	Table:  "updated_by_user",
+	From:   `"user"`,
+	Key:    "user_key",
+	Join:   "updated_by",
+	Expr:   userDetailsExpr("updated_by_user"),
+}, {
+	Name:   "tags",
+	Type:   sqldb.FieldArray,
+	Option: "tags",
+	Table:  "resource",
+	Tags:   true,
+}, {
+	Name:   "revisions",
+	Type:   sqldb.FieldInt,
+	Option: "revisions",
+	Table:  "resource",
+	Expr: `(SELECT COUNT(*) FROM change
+		WHERE change.entity_type = 'resource'
+		AND change.entity_id = resource.resource_id
+		AND change.operation <> 'delete')`,
+}, {
+	Name:   "data_count",
+	Type:   sqldb.FieldInt,
+	Option: "computed",
+	Table:  "resource",
+	Expr: `(SELECT COUNT(*) FROM resource_data
+		WHERE resource_data.resource_key = resource.resource_key)`,
+}, {
+	Name:   "age_seconds",
+	Type:   sqldb.FieldInt,
+	Option: "computed",
+	Table:  "resource",
+	Expr: `FLOOR(EXTRACT(EPOCH FROM
+		CURRENT_TIMESTAMP - resource.updated_at))::BIGINT`,
+}, {
+	Name:   "cleared_ratio",
+	Type:   sqldb.FieldFloat,
+	Option: "computed",
+	Table:  "resource",
+	Expr: `(resource.status_data->>'cleared_items')::DOUBLE PRECISION /
+		NULLIF((resource.status_data->>'data_items')::DOUBLE PRECISION +
+		(resource.status_data->>'cleared_items')::DOUBLE PRECISION, 0)`,
 }}
 
+// userDetailsExpr returns a SQL expression selecting the details of a joined
+// user as a JSON object.
+func userDetailsExpr(table string) string {
+	return `CASE WHEN ` + table + `.user_key IS NULL THEN NULL
+		ELSE JSONB_BUILD_OBJECT(
+			'user_id', ` + table + `.user_id,
+			'email', ` + table + `.email,
+			'first_name', ` + table + `.first_name,
+			'last_name', ` + table + `.last_name) END`
+}
+
 // GetResources retrieves resources based on a search query.
 func (s *Service) GetResources(ctx context.Context,
 	query *search.Query,
 	options sqldb.FieldOptions,
 ) ([]*Resource, []*sqldb.SummaryData, error) {
+	if err := options.ValidateFields(resourceFields); err != nil {
+		return nil, nil, err
+	}
+
+	base := sqldb.SearchFields("resource", resourceFields)
+
+	filter, params, err := aclFilter(ctx, 0)
+	if err != nil {
+		return nil, nil, err
+	} else if filter != "" {
+		base += "WHERE " + filter
+	}
+
 	q := sqldb.NewQuery(&sqldb.QueryOptions{
 		DB:     s.db,
 		Type:   sqldb.QuerySelect,
-		Base:   sqldb.SearchFields("resource", resourceFields),
+		Base:   base,
 		Search: query.NoSummary(),
 		Fields: resourceFields,
+		Params: params,
 	})
 
 	rows, err := q.Query(ctx)
@@ -417,7 +603,8 @@ func (s *Service) GetResources(ctx context.Context,
 		return res, sum, nil
 	}
 
-	if s.cache != nil && query != nil && query.Summary == "" {
+	if s.cache != nil && query != nil && query.Summary == "" &&
+		len(options) == 0 {
 		found := false
 
 		cMap, err := s.cache.GetMulti(ctx, cacheKeys...)
@@ -519,7 +706,7 @@ func (s *Service) GetResources(ctx context.Context,
 					"search", query)
 			}
 
-			if s.cache != nil {
+			if s.cache != nil && len(options) == 0 {
 				ck := cache.KeyResource(r.ResourceID.Value)
 
 				buf, err := json.Marshal(r)
@@ -530,20 +717,18 @@ func (s *Service) GetResources(ctx context.Context,
 						"cache_key", ck,
 						"cache_value", r,
 						"search", query)
-				} else if len(buf) < s.cfg.CacheMaxBytes() {
-					if err := s.cache.Set(ctx, &cache.Item{
-						Key:        ck,
-						Value:      buf,
-						Expiration: s.cfg.CacheExpiration(),
-					}); err != nil {
-						s.log.Log(ctx, logger.LvlError,
-							"unable to set resource cache value",
-							"error", err,
-							"cache_key", ck,
-							"cache_value", string(buf),
-							"expiration", s.cfg.CacheExpiration(),
-							"search", query)
-					}
+				} else if err := s.cache.Set(ctx, &cache.Item{
+					Key:        ck,
+					Value:      buf,
+					Expiration: s.cfg.CacheExpiration(),
+				}); err != nil {
+					s.log.Log(ctx, logger.LvlError,
+						"unable to set resource cache value",
+						"error", err,
+						"cache_key", ck,
+						"cache_value", string(buf),
+						"expiration", s.cfg.CacheExpiration(),
+						"search", query)
 				}
 			}
 
@@ -564,14 +749,124 @@ func (s *Service) GetResources(ctx context.Context,
 	return res, sum, nil
 }
 
-// GetResource retrieves a single resource by ID.
+// CountResources retrieves the total number of resources matching a search
+// query, ignoring the size and skip values of the query.
+func (s *Service) CountResources(ctx context.Context,
+	query *search.Query,
+) (int64, error) {
+	sq := &search.Query{}
+
+	if query != nil {
+		sq.Search = query.Search
+		sq.CaseInsensitive = query.CaseInsensitive
+	}
+
+	base := sqldb.SearchFields("resource", resourceFields)
+
+	filter, params, err := aclFilter(ctx, 0)
+	if err != nil {
+		return 0, err
+	} else if filter != "" {
+		base += "WHERE " + filter
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryCount,
+		Base:   base,
+		Search: sq,
+		Fields: resourceFields,
+		Params: params,
+	})
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	n := int64(0)
+
+	if err := row.Scan(&n); err != nil {
+		return 0, errors.Wrap(err, errors.ErrDatabase,
+			"unable to count resources",
+			"search", query)
+	}
+
+	return n, nil
+}
+
+// ExplainResources returns the cost of searching for resources using a search
+// query, as estimated by the database, without performing the search.
+func (s *Service) ExplainResources(ctx context.Context,
+	query *search.Query,
+) (*sqldb.QueryPlan, error) {
+	base := sqldb.SearchFields("resource", resourceFields)
+
+	filter, params, err := aclFilter(ctx, 0)
+	if err != nil {
+		return nil, err
+	} else if filter != "" {
+		base += "WHERE " + filter
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Search: query.NoSummary(),
+		Fields: resourceFields,
+		Params: params,
+	})
+
+	res, err := q.Explain(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"search", query)
+	}
+
+	return res, nil
+}
+
+// GetResource retrieves a single resource by ID. The current user must have
+// read permission on the resource.
 func (s *Service) GetResource(ctx context.Context,
 	id string,
 	options sqldb.FieldOptions,
 ) (*Resource, error) {
+	if err := options.ValidateFields(resourceFields); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.checkAccess(ctx, id, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	return s.getResource(ctx, nil, id, options)
+}
+
+// getResource retrieves a single resource by ID. If a transaction is provided,
+// the resource is read from the database, bypassing the cache, and its row is
+// locked until the transaction is closed.
+func (s *Service) getResource(ctx context.Context,
+	tx sqldb.SQLTX,
+	id string,
+	options sqldb.FieldOptions,
+) (*Resource, error) {
+	if err := options.ValidateFields(resourceFields); err != nil {
+		return nil, err
+	}
+
 	var r *Resource
 
-	if s.cache != nil {
+	if tx == nil {
+		tx = sqldb.ContextTx(ctx)
+	}
+
+	// Cached values do not contain any optional related objects.
+	useCache := s.cache != nil && len(options) == 0 && tx == nil
+
+	if useCache {
 		ck := cache.KeyResource(id)
 
 		ci, err := s.cache.Get(ctx, ck)
@@ -595,65 +890,93 @@ func (s *Service) GetResource(ctx context.Context,
 		}
 	}
 
-	if r == nil {
-		base := sqldb.SelectFields("resource", resourceFields, nil, options) +
-			`WHERE resource.resource_id = $1`
+	if r != nil {
+		return r, nil
+	}
 
-		q := sqldb.NewQuery(&sqldb.QueryOptions{
-			DB:     s.db,
-			Type:   sqldb.QuerySelect,
-			Base:   base,
-			Fields: resourceFields,
-			Params: []any{id},
+	if tx != nil {
+		return s.loadResource(ctx, tx, id, options, false)
+	}
+
+	// Concurrent loads of the same uncached resource are coalesced, so that
+	// the resource is only read from the database, and cached, once.
+	lk := cache.KeyResource(id)
+
+	for _, o := range options {
+		lk += "::" + string(o)
+	}
+
+	return cache.Load(ctx, &s.loads, lk,
+		func(ctx context.Context) (*Resource, error) {
+			return s.loadResource(ctx, nil, id, options, useCache)
 		})
+}
 
-		q.Limit = 1
+// loadResource reads a single resource by ID from the database, and caches it
+// if requested.
+func (s *Service) loadResource(ctx context.Context,
+	tx sqldb.SQLTX,
+	id string,
+	options sqldb.FieldOptions,
+	useCache bool,
+) (*Resource, error) {
+	base := sqldb.SelectFields("resource", resourceFields, nil, options) +
+		`WHERE resource.resource_id = $1`
 
-		row, err := q.QueryRow(ctx)
-		if err != nil {
-			return nil, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
-		}
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Tx:     tx,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: resourceFields,
+		Params: []any{id},
+		Lock:   tx != nil,
+	})
 
-		r = &Resource{}
+	q.Limit = 1
 
-		if err := row.Scan(r.ScanDest(options)...); err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				return nil, errors.New(errors.ErrNotFound,
-					"resource not found",
-					"id", id)
-			}
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
 
-			return nil, errors.Wrap(err, errors.ErrDatabase,
-				"unable to select resource row",
+	r := &Resource{}
+
+	if err := row.Scan(r.ScanDest(options)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"resource not found",
 				"id", id)
 		}
 
-		if s.cache != nil {
-			ck := cache.KeyResource(r.ResourceID.Value)
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource row",
+			"id", id)
+	}
 
-			buf, err := json.Marshal(r)
-			if err != nil {
-				s.log.Log(ctx, logger.LvlError,
-					"unable to encode resource cache value",
-					"error", err,
-					"cache_key", ck,
-					"cache_value", r,
-					"id", id)
-			} else if len(buf) < s.cfg.CacheMaxBytes() {
-				if err := s.cache.Set(ctx, &cache.Item{
-					Key:        ck,
-					Value:      buf,
-					Expiration: s.cfg.CacheExpiration(),
-				}); err != nil {
-					s.log.Log(ctx, logger.LvlError,
-						"unable to set resource cache value",
-						"error", err,
-						"cache_key", ck,
-						"cache_value", string(buf),
-						"expiration", s.cfg.CacheExpiration(),
-						"id", id)
-				}
-			}
+	if useCache {
+		ck := cache.KeyResource(r.ResourceID.Value)
+
+		buf, err := json.Marshal(r)
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to encode resource cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", r,
+				"id", id)
+		} else if err := s.cache.Set(ctx, &cache.Item{
+			Key:        ck,
+			Value:      buf,
+			Expiration: s.cfg.CacheExpiration(),
+		}); err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to set resource cache value",
+				"error", err,
+				"cache_key", ck,
+				"cache_value", string(buf),
+				"expiration", s.cfg.CacheExpiration(),
+				"id", id)
 		}
 	}
 
@@ -663,6 +986,15 @@ func (s *Service) GetResource(ctx context.Context,
 // CreateResource creates a new resource.
 func (s *Service) CreateResource(ctx context.Context,
 	v *Resource,
+) (*Resource, error) {
+	return s.createResource(ctx, v, nil)
+}
+
+// createResource creates a new resource, which may depend on resources not
+// created yet, if they are pending.
+func (s *Service) createResource(ctx context.Context,
+	v *Resource,
+	pending map[string]bool,
 ) (*Resource, error) {
 	userID, err := request.ContextUserID(ctx)
 	if err != nil {
@@ -691,6 +1023,10 @@ func (s *Service) CreateResource(ctx context.Context,
 		}
 	}
 
+	if !v.KeyField.Set {
+		v.KeyField = request.FieldString{Set: true, Valid: true}
+	}
+
 	base := `INSERT INTO resource () VALUES ()` +
 		sqldb.ReturningFields("resource", resourceFields, nil)
 
@@ -707,7 +1043,9 @@ func (s *Service) CreateResource(ctx context.Context,
 	request.SetField("clear_condition", v.ClearCondition, &sets, &params)
 	request.SetField("clear_after", v.ClearAfter, &sets, &params)
 	request.SetField("clear_delay", v.ClearDelay, &sets, &params)
-	request.SetField("data", v.Data, &sets, &params)
+	request.SetField("duplicate_policy", v.DuplicatePolicy, &sets, &params)
+	request.SetField("key_strategy", v.KeyStrategy, &sets, &params)
+	request.SetField("depends_on", v.DependsOn, &sets, &params)
 	request.SetField("source", v.Source, &sets, &params)
 	request.SetField("commit_hash", v.CommitHash, &sets, &params)
 	request.SetField("created_by", request.FieldString{
@@ -717,32 +1055,55 @@ func (s *Service) CreateResource(ctx context.Context,
 		Set: true, Valid: true, Value: userID,
 	}, &sets, &params)
 
-	q := sqldb.NewQuery(&sqldb.QueryOptions{
-		DB:     s.db,
-		Type:   sqldb.QueryInsert,
-		Base:   base,
-		Fields: resourceFields,
-		Sets:   sets,
-		Params: params,
-	})
+	r := &Resource{}
 
-	row, err := q.QueryRow(ctx)
-	if err != nil {
-		return nil, errors.Wrap(err, errors.ErrDatabase, "", "resource", v)
-	}
+	// The resource and its data are inserted as a unit of work, joining the
+	// transaction of any unit of work the resource is created in.
+	if err := sqldb.RunTx(ctx, s.db, func(ctx context.Context) error {
+		if err := s.checkDependencies(ctx, nil, v, pending); err != nil {
+			return err
+		}
 
-	r := &Resource{}
+		q := sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:     s.db,
+			Type:   sqldb.QueryInsert,
+			Base:   base,
+			Fields: resourceFields,
+			Sets:   sets,
+			Params: params,
+		})
 
-	if err := row.Scan(r.ScanDest(nil)...); err != nil {
-		if errors.ErrorHas(err, `"resource_account_id_resource_id_key"`) {
-			return nil, errors.New(errors.ErrConflict,
-				"invalid resource_id: already in use by another resource",
+		row, err := q.QueryRow(ctx)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrDatabase, "", "resource", v)
+		}
+
+		if err := row.Scan(r.ScanDest(nil)...); err != nil {
+			if errors.ErrorHas(err, `"resource_account_id_resource_id_key"`) {
+				return errors.New(errors.ErrConflict,
+					"invalid resource_id: already in use by another resource",
+					"resource", v)
+			}
+
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to insert resource row",
 				"resource", v)
 		}
 
-		return nil, errors.Wrap(err, errors.ErrDatabase,
-			"unable to insert resource row",
-			"resource", v)
+		if !v.Data.Set {
+			return nil
+		}
+
+		return s.setResourceData(ctx, nil, r.ResourceID.Value,
+			v.Data.Value, nil, nil, 0, 0, true)
+	}); err != nil {
+		return nil, err
+	}
+
+	if v.Data.Set {
+		r.Data = request.FieldJSON{
+			Set: true, Valid: len(v.Data.Value) > 0, Value: v.Data.Value,
+		}
 	}
 
 	if s.cache != nil {
@@ -761,9 +1122,32 @@ func (s *Service) CreateResource(ctx context.Context,
 	return r, nil
 }
 
-// UpdateResource updates an resource.
+// UpdateResource updates an resource. The current user must have write
+// permission on the resource.
 func (s *Service) UpdateResource(ctx context.Context,
 	v *Resource,
+) (*Resource, error) {
+	if v != nil && v.ResourceID.Valid {
+		if _, err := s.checkAccess(ctx, v.ResourceID.Value,
+			PermissionWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := s.updateResource(ctx, nil, v)
+	if err != nil {
+		return nil, err
+	}
+
+	s.deleteResourceCache(ctx, r.ResourceID.Value)
+
+	return r, nil
+}
+
+// updateResource updates an resource, optionally within a transaction.
+func (s *Service) updateResource(ctx context.Context,
+	tx sqldb.SQLTX,
+	v *Resource,
 ) (*Resource, error) {
 	userID, err := request.ContextUserID(ctx)
 	if err != nil {
@@ -786,6 +1170,17 @@ func (s *Service) UpdateResource(ctx context.Context,
 		return nil, err
 	}
 
+	if err := s.checkDependencies(ctx, tx, v, nil); err != nil {
+		return nil, err
+	}
+
+	if v.Data.Set {
+		if err := s.setResourceData(ctx, tx, v.ResourceID.Value, v.Data.Value,
+			nil, nil, 0, 0, true); err != nil {
+			return nil, err
+		}
+	}
+
 	base := `UPDATE resource SET
 		WHERE resource.resource_id = $1` +
 		sqldb.ReturningFields("resource", resourceFields, nil)
@@ -802,7 +1197,9 @@ func (s *Service) UpdateResource(ctx context.Context,
 	request.SetField("clear_condition", v.ClearCondition, &sets, &params)
 	request.SetField("clear_after", v.ClearAfter, &sets, &params)
 	request.SetField("clear_delay", v.ClearDelay, &sets, &params)
-	request.SetField("data", v.Data, &sets, &params)
+	request.SetField("duplicate_policy", v.DuplicatePolicy, &sets, &params)
+	request.SetField("key_strategy", v.KeyStrategy, &sets, &params)
+	request.SetField("depends_on", v.DependsOn, &sets, &params)
 	request.SetField("source", v.Source, &sets, &params)
 	request.SetField("commit_hash", v.CommitHash, &sets, &params)
 	request.SetField("updated_at", request.FieldTime{
@@ -821,6 +1218,7 @@ func (s *Service) UpdateResource(ctx context.Context,
 
 	q := sqldb.NewQuery(&sqldb.QueryOptions{
 		DB:     s.db,
+		Tx:     tx,
 		Type:   sqldb.QueryUpdate,
 		Base:   base,
 		Fields: resourceFields,
@@ -854,492 +1252,417 @@ func (s *Service) UpdateResource(ctx context.Context,
 			"resource", v)
 	}
 
-	if s.cache != nil {
-		ck := cache.KeyResource(r.ResourceID.Value)
-
-		if err := s.cache.Delete(ctx, ck); err != nil &&
-			!errors.Has(err, errors.ErrNotFound) {
-			s.log.Log(ctx, logger.LvlError,
-				"unable to delete resource cache key",
-				"error", err,
-				"cache_key", ck,
-				"resource", v)
+	if v.Status.Set {
+		if err := s.cascadeStatus(ctx, tx, r.ResourceID.Value,
+			r.Status.Value); err != nil {
+			return nil, err
 		}
 	}
 
 	return r, nil
 }
 
-// DeleteResource deletes an resource.
-func (s *Service) DeleteResource(ctx context.Context,
-	id string,
-) error {
-	if s.cache != nil {
-		defer func(ck string) {
-			if err := s.cache.Delete(ctx, ck); err != nil &&
-				!errors.Has(err, errors.ErrNotFound) {
-				s.log.Log(ctx, logger.LvlError,
-					"unable to delete resource cache key",
-					"error", err,
-					"cache_key", ck,
-					"id", id)
-			}
-		}(cache.KeyResource(id))
+// deleteResourceCache removes any cached value for a resource by ID.
+func (s *Service) deleteResourceCache(ctx context.Context, id string) {
+	if s.cache == nil {
+		return
 	}
 
-	base := `DELETE FROM resource
-		WHERE resource.resource_id = $1`
+	ck := cache.KeyResource(id)
 
-	q := sqldb.NewQuery(&sqldb.QueryOptions{
-		DB:     s.db,
-		Type:   sqldb.QueryDelete,
-		Base:   base,
-		Fields: resourceFields,
-		Params: []any{id},
-	})
-
-	res, err := q.Exec(ctx)
-	if err != nil {
-		return errors.Wrap(err, errors.ErrDatabase, "", "id", id)
-	}
-
-	if n := res.RowsAffected(); n == 0 {
-		return errors.New(errors.ErrNotFound, "resource not found",
+	if err := s.cache.Delete(ctx, ck); err != nil &&
+		!errors.Has(err, errors.ErrNotFound) {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to delete resource cache key",
+			"error", err,
+			"cache_key", ck,
 			"id", id)
 	}
-
-	return nil
 }
 
-// findResourceData is used to create a keyed map of resource data values from
-// an existing resource and an resource update payload.
-func findResourceData(payload map[string]any,
-	resource *Resource,
-) (map[string]any, []string, error) {
-	if resource.KeyField.Value == "" {
-		return nil, nil, errors.New(errors.ErrInvalidRequest,
-			"unable to extract resource data: missing key field",
-			"resource", resource,
-			"payload", payload)
-	}
-
-	resourceData := map[string]any{}
-
-	clears := []string{}
-
-	resources, ok := payload["resources"].([]any)
-	if !ok {
-		resources = []any{payload}
+// DeleteResource deletes an resource. The current user must have admin
+// permission on the resource.
+func (s *Service) DeleteResource(ctx context.Context,
+	id string,
+) error {
+	if _, err := s.checkAccess(ctx, id, PermissionAdmin); err != nil {
+		return err
 	}
 
-	for _, ad := range resources {
-		am, ok := ad.(map[string]any)
-		if !ok {
-			continue
-		}
-
-		kv, ok := am[resource.KeyField.Value]
-		if !ok {
-			continue
-		}
-
-		key := ""
-
-		switch kt := kv.(type) {
-		case string:
-			key = kt
-		default:
-			if b, err := json.Marshal(kt); err != nil {
-				continue
-			} else {
-				key = string(b)
-			}
-		}
-
-		if resource.KeyRegex.Value != "" {
-			re, err := regexp.Compile(resource.KeyRegex.Value)
-			if err != nil {
-				return nil, nil, errors.Wrap(err, errors.ErrInvalidRequest,
-					"invalid resource key_regex",
-					"resource", resource,
-					"payload", payload)
-			}
-
-			key = re.FindString(key)
-		}
-
-		if key != "" {
-			am["ts"] = time.Now().Unix()
-
-			cleared := false
-
-			if resource.ClearCondition.Value != "" {
-				p := search.NewParser(bytes.NewBufferString(
-					resource.ClearCondition.Value))
-
-				ast, err := p.Parse()
-				if err != nil {
-					return nil, nil, errors.Wrap(err, errors.ErrInvalidRequest,
-						"invalid resource clear_condition",
-						"resource", resource,
-						"payload", payload)
-				}
-
-				cleared, err = ast.Eval(
-					func(node *search.QueryNode) (bool, error) {
-						getValInt64 := func(cat, val string) (int64, error) {
-							r, err := strconv.ParseInt(val, 10, 64)
-							if err != nil {
-								return 0, errors.Wrap(err,
-									errors.ErrInvalidRequest,
-									"invalid condition value for category",
-									"category", cat,
-									"value", val)
-							}
-
-							return r, nil
-						}
-
-						getValFloat64 := func(cat string,
-							val string,
-						) (float64, error) {
-							r, err := strconv.ParseFloat(val, 64)
-							if err != nil {
-								return 0, errors.Wrap(err,
-									errors.ErrInvalidRequest,
-									"invalid condition value for category",
-									"category", cat,
-									"value", val)
-							}
-
-							return r, nil
-						}
-
-						op := node.Comp
+	if s.cache != nil {
+		defer func(ck string) {
+			if err := s.cache.Delete(ctx, ck); err != nil &&
+				!errors.Has(err, errors.ErrNotFound) {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to delete resource cache key",
+					"error", err,
+					"cache_key", ck,
+					"id", id)
+			}
+		}(cache.KeyResource(id))
+	}
 
-						cat := strings.TrimSpace(node.Cat)
+	base := `DELETE FROM resource
+		WHERE resource.resource_id = $1`
 
-						val := strings.TrimSpace(node.Val)
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryDelete,
+		Base:   base,
+		Fields: resourceFields,
+		Params: []any{id},
+	})
 
-						valRegExp := node.ValRE
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
 
-						var valRE *regexp.Regexp
+	if n := res.RowsAffected(); n == 0 {
+		return errors.New(errors.ErrNotFound, "resource not found",
+			"id", id)
+	}
 
-						if valRegExp == "" && strings.Contains(val, "*") {
-							valRegExp = strings.ReplaceAll(val, "*", ".*")
-						}
+	// Resources degraded by the deleted resource are restored, since it can
+	// no longer recover.
+	if err := s.cascadeStatus(ctx, nil, id, request.StatusActive); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to restore dependent resource status",
+			"error", err,
+			"id", id)
+	}
 
-						if valRegExp != "" {
-							val = valRegExp
+	return nil
+}
 
-							re, err := regexp.Compile(val)
-							if err != nil {
-								return false, errors.Wrap(err,
-									errors.ErrInvalidRequest,
-									"invalid condition value "+
-										"regular expression",
-									"value", val)
-							}
+// findResourceData is used to create a keyed map of resource data values from
+// an existing resource and an resource update payload. Item keys are extracted
+// using the key strategy of the resource. Payload items with duplicate keys are
+// resolved using the duplicate policy of the resource, and the number of
+// duplicates found is returned. Items matching the clear_condition of a
+// resource with a clear_delay are kept, and returned as deferred clears, rather
+// than being cleared immediately.
+func findResourceData(payload map[string]any,
+	resource *Resource,
+) (map[string]any, []string, []string, int, error) {
+	keyOf, err := newKeyFunc(resource)
+	if err != nil {
+		return nil, nil, nil, 0, err
+	}
 
-							valRE = re
-						}
+	resourceData := map[string]any{}
 
-						parts := strings.Split(cat, ".")
+	clears := []string{}
 
-						if parts[0] == "true" {
-							return true, nil
-						}
+	pending := map[string]bool{}
 
-						res := false
+	seen := map[string]bool{}
 
-						var v any = am
+	duplicates := 0
 
-						for i := 0; i < len(parts); i++ {
-							key := parts[i]
+	resources, ok := payload["resources"].([]any)
+	if !ok {
+		resources = []any{payload}
+	}
 
-							index := -1
+	var ast *search.QueryTree
 
-							if strings.HasSuffix(key, "]") {
-								startIdx := strings.LastIndex(key, "[")
+	if resource.ClearCondition.Value != "" {
+		ast, err = search.NewParser(bytes.NewBufferString(
+			resource.ClearCondition.Value)).Parse()
+		if err != nil {
+			return nil, nil, nil, 0, errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid resource clear_condition",
+				"resource", resource,
+				"payload", payload)
+		}
+	}
 
-								endIdx := strings.LastIndex(key, "]")
+	var set []map[string]any
 
-								if startIdx > 0 && endIdx > 0 &&
-									startIdx < endIdx {
-									idx := key[startIdx+1 : endIdx]
+	if ast != nil && search.HasAggregate(resource.ClearCondition.Value) {
+		set = resourceDataSet(resource, resources, keyOf)
+	}
 
-									iv, err := strconv.ParseInt(idx, 10, 64)
-									if err == nil {
-										index = int(iv)
+	for _, ad := range resources {
+		am, ok := ad.(map[string]any)
+		if !ok {
+			continue
+		}
 
-										key = key[:startIdx]
-									}
-								}
-							}
+		key := keyOf(am)
 
-							switch vt := v.(type) {
-							case map[string]any:
-								vv, ok := vt[key]
-								if !ok {
-									return false, nil
-								}
-
-								switch vvt := vv.(type) {
-								case []any:
-									if index < 0 || index >= len(vvt) {
-										return false, nil
-									}
-
-									v = vvt[index]
-								case []map[string]any:
-									if index < 0 || index >= len(vvt) {
-										return false, nil
-									}
-
-									v = vvt[index]
-								default:
-									v = vvt
-								}
-							default:
-								return false, nil
-							}
-						}
+		if key != "" {
+			if seen[key] {
+				if resource.DuplicatePolicy.Value == DuplicatePolicyReject {
+					return nil, nil, nil, 0, errors.New(
+						errors.ErrInvalidRequest,
+						"duplicate resource data key in payload",
+						"resource", resource,
+						"key", key)
+				}
 
-						switch vt := v.(type) {
-						case nil:
-							if val == "null" || val == "" {
-								res = true
-							}
-						case float64:
-							l := vt
+				duplicates++
+			}
 
-							r, err := getValFloat64(cat, val)
-							if err != nil {
-								return false, err
-							}
+			seen[key] = true
 
-							switch op {
-							case search.OpMatch:
-								if l == r {
-									res = true
-								}
-							case search.OpGT:
-								if l > r {
-									res = true
-								}
-							case search.OpGTE:
-								if l >= r {
-									res = true
-								}
-							case search.OpLT:
-								if l < r {
-									res = true
-								}
-							case search.OpLTE:
-								if l <= r {
-									res = true
-								}
-							default:
-								return false, errors.New(
-									errors.ErrInvalidRequest,
-									"invalid condition operator for category",
-									"category", cat,
-									"operator", op)
-							}
-						case int64:
-							l := vt
+			am["ts"] = time.Now().Unix()
 
-							r, err := getValInt64(cat, val)
-							if err != nil {
-								return false, err
-							}
+			// Items may carry a ttl, in seconds, which overrides the
+			// clear_after of the resource when stale items are pruned.
+			if v, ok := am["ttl"]; ok && v != nil {
+				ttl, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+				if err != nil || ttl < 0 {
+					return nil, nil, nil, 0, errors.New(
+						errors.ErrInvalidRequest,
+						"invalid resource data ttl",
+						"resource", resource,
+						"key", key,
+						"ttl", v)
+				}
+			}
 
-							switch op {
-							case search.OpMatch:
-								if l == r {
-									res = true
-								}
-							case search.OpGT:
-								if l > r {
-									res = true
-								}
-							case search.OpGTE:
-								if l >= r {
-									res = true
-								}
-							case search.OpLT:
-								if l < r {
-									res = true
-								}
-							case search.OpLTE:
-								if l <= r {
-									res = true
-								}
-							default:
-								return false, errors.New(
-									errors.ErrInvalidRequest,
-									"invalid condition operator for category",
-									"category", cat,
-									"operator", op)
-							}
-						case string:
-							if valRE != nil {
-								if valRE.MatchString(vt) {
-									res = true
-								}
-							} else {
-								res, err = filepath.Match(val, vt)
-								if err != nil {
-									return false, errors.Wrap(err,
-										errors.ErrInvalidRequest,
-										"invalid value pattern for category",
-										"category", cat,
-										"value", val,
-										"operator", op)
-								}
-							}
-						default:
-							return false, nil
-						}
+			cleared := false
 
-						return res, nil
-					})
+			if ast != nil {
+				cleared, err = ast.MatchItem(am, set)
 				if err != nil {
-					return nil, nil, errors.Wrap(err, errors.ErrInvalidRequest,
+					return nil, nil, nil, 0, errors.Wrap(err,
+						errors.ErrInvalidRequest,
 						"unable to evaluate resource clear_condition",
 						"resource", resource,
 						"payload", payload)
 				}
 
-				if cleared {
+				if cleared && resource.ClearDelay.Value <= 0 {
 					clears = append(clears, key)
+
+					continue
 				}
 			}
 
-			if !cleared {
+			prev, ok := resourceData[key].(map[string]any)
+
+			switch {
+			case !ok:
+				resourceData[key] = am
+			case resource.DuplicatePolicy.Value == DuplicatePolicyFirst:
+				continue
+			case resource.DuplicatePolicy.Value == DuplicatePolicyMerge:
+				m := maps.Clone(prev)
+
+				maps.Copy(m, am)
+
+				resourceData[key] = m
+			default:
 				resourceData[key] = am
 			}
+
+			pending[key] = cleared
+		}
+	}
+
+	deferred := []string{}
+
+	for _, key := range slices.Sorted(maps.Keys(pending)) {
+		if pending[key] {
+			deferred = append(deferred, key)
+		}
+	}
+
+	return resourceData, clears, deferred, duplicates, nil
+}
+
+// resourceDataSet returns the data set of a resource, as it will be after an
+// update is applied. This is the stored data of the resource, with the items of
+// the update payload replacing stored items with the same key.
+func resourceDataSet(resource *Resource,
+	resources []any,
+	keyOf keyFunc,
+) []map[string]any {
+	data := map[string]map[string]any{}
+
+	for k, v := range resource.Data.Value {
+		if am, ok := v.(map[string]any); ok {
+			data[k] = am
+		}
+	}
+
+	for _, ad := range resources {
+		if am, ok := ad.(map[string]any); ok {
+			if key := keyOf(am); key != "" {
+				data[key] = am
+			}
 		}
 	}
 
-	return resourceData, clears, nil
+	set := make([]map[string]any, 0, len(data))
+
+	for _, am := range data {
+		set = append(set, am)
+	}
+
+	return set
 }
 
-// UpdateResourceData allows external systems to update resource data.
+// UpdateResourceData allows external systems to update resource data. The
+// resource row is locked for the duration of the update, so that concurrent
+// updates of the same resource are applied one at a time.
 func (s *Service) UpdateResourceData(ctx context.Context,
 	payload map[string]any,
 	accountID, resourceID string,
 ) (*Resource, error) {
-	ctx = context.WithValue(ctx, request.CtxKeyUserID, request.SystemUser)
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, request.ScopeSuperuser)
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, accountID)
+	ctx = request.Elevate(ctx, accountID)
 
-	r, err := s.GetResource(ctx, resourceID, nil)
+	tx, err := sqldb.BeginTx(ctx, s.db, pgx.TxOptions{})
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to begin resource data transaction",
+			"resource_id", resourceID)
+	}
+
+	r, err := s.getResource(ctx, tx, resourceID, nil)
+	if err != nil {
+		return nil, s.closeTx(ctx, tx, err)
 	}
 
 	if r.Status.Value == request.StatusInactive {
-		return nil, errors.New(errors.ErrInvalidRequest,
+		return nil, s.closeTx(ctx, tx, errors.New(errors.ErrInvalidRequest,
 			"unable to update resource data for inactive resource",
 			"payload", payload,
-			"resource", r)
+			"resource", r))
 	}
 
-	resourceData, clears, err := findResourceData(payload, r)
+	resourceData, clears, deferred, duplicates, err := findResourceData(payload,
+		r)
 	if err != nil {
-		r.Status = request.FieldString{
-			Set: true, Valid: true, Value: request.StatusError,
-		}
-
-		r.StatusData = request.FieldJSON{
-			Set: true, Valid: true, Value: map[string]any{
-				"last_error": err.Error(),
+		_, uErr := s.updateResource(ctx, tx, &Resource{
+			ResourceID: r.ResourceID,
+			Status: request.FieldString{
+				Set: true, Valid: true, Value: request.StatusError,
 			},
-		}
+			StatusData: request.FieldJSON{
+				Set: true, Valid: true, Value: map[string]any{
+					"last_error": err.Error(),
+				},
+			},
+		})
 
-		if _, err := s.UpdateResource(ctx, r); err != nil {
+		if uErr = s.closeTx(ctx, tx, uErr); uErr != nil {
 			s.log.Log(ctx, logger.LvlError,
 				"unable to update resource error status",
-				"error", err,
+				"error", uErr,
 				"resource", r)
+		} else {
+			s.deleteResourceCache(ctx, r.ResourceID.Value)
 		}
 
 		return nil, err
 	}
 
-	if !r.Data.Set || !r.Data.Valid || len(r.Data.Value) == 0 {
-		r.Data = request.FieldJSON{
-			Set: true, Valid: true, Value: map[string]any{},
-		}
-	}
+	// Only the changed data items are written, any cleared items are removed,
+	// and any items older than their ttl, or the clear_after setting, are
+	// pruned.
+	oldTS := time.Now().Add(0 -
+		(time.Second * time.Duration(r.ClearAfter.Value))).Unix()
 
-	for k, v := range resourceData {
-		r.Data.Value[k] = v
+	if err := s.setResourceData(ctx, tx, r.ResourceID.Value, resourceData,
+		clears, deferred, r.ClearDelay.Value, oldTS, false); err != nil {
+		return nil, s.closeTx(ctx, tx, err)
 	}
 
-	// Remove any cleared resources.
-	for _, key := range clears {
-		delete(r.Data.Value, key)
+	// The numbers of stored and cleared items are reported in the status data,
+	// so that the cleared ratio of the update can be computed when read.
+	statusData := map[string]any{
+		"data_items":    len(resourceData),
+		"cleared_items": len(clears),
 	}
 
-	// Prune any data older than the clear_after setting.
-	newData := map[string]any{}
-
-	oldTS := time.Now().Add(0 -
-		(time.Second * time.Duration(r.ClearAfter.Value))).Unix()
+	// Items which will be cleared once the clear_delay has elapsed are also
+	// reported.
+	if len(deferred) > 0 {
+		statusData["deferred_items"] = len(deferred)
+	}
 
-	for k, v := range r.Data.Value {
-		if vv, ok := v.(map[string]any); ok {
-			if pts, ok := vv["ts"]; ok {
-				switch tv := pts.(type) {
-				case int64:
-					if tv >= oldTS {
-						newData[k] = v
-					}
-				case float64:
-					if int64(tv) >= oldTS {
-						newData[k] = v
-					}
-				case string:
-					if iv, err := strconv.ParseInt(tv, 10, 64); err == nil {
-						if iv >= oldTS {
-							newData[k] = v
-						}
-					}
-				}
-			}
+	// Duplicate keys found in the payload are reported in the status data.
+	if duplicates > 0 {
+		policy := r.DuplicatePolicy.Value
+		if policy == "" {
+			policy = DuplicatePolicyLast
 		}
+
+		statusData["duplicate_keys"] = duplicates
+		statusData["duplicate_policy"] = policy
 	}
 
-	r.Data.Value = newData
+	// Resources degraded by a failing dependency remain degraded until the
+	// dependency recovers.
+	status := request.StatusActive
+
+	if r.Status.Value == request.StatusDegraded {
+		status = request.StatusDegraded
 
-	if len(r.Data.Value) == 0 {
-		r.Data.Valid = false
+		statusData["degraded_by"] = r.StatusData.Value["degraded_by"]
 	}
 
-	r.Status = request.FieldString{
-		Set: true, Valid: true, Value: request.StatusActive,
+	ur := &Resource{
+		ResourceID: r.ResourceID,
+		Status: request.FieldString{
+			Set: true, Valid: true, Value: status,
+		},
+		StatusData: request.FieldJSON{
+			Set: true, Valid: true, Value: statusData,
+		},
 	}
 
-	res, err := s.UpdateResource(ctx, r)
-	if err != nil {
+	res, err := s.updateResource(ctx, tx, ur)
+
+	if err := s.closeTx(ctx, tx, err); err != nil {
 		return nil, err
 	}
 
+	s.deleteResourceCache(ctx, res.ResourceID.Value)
+
 	return res, nil
 }
 
+// Transact performs a function as a unit of work. Service operations performed
+// using the context passed to the function are performed in a single database
+// transaction, which is committed if the function succeeds, or rolled back if
+// it returns an error.
+func (s *Service) Transact(ctx context.Context,
+	f func(ctx context.Context) error,
+) error {
+	return sqldb.RunTx(ctx, s.db, f)
+}
+
+// closeTx commits the transaction if err is nil, otherwise it is rolled back.
+// The provided error, or any error committing the transaction, is returned.
+func (s *Service) closeTx(ctx context.Context, tx sqldb.SQLTX, err error,
+) error {
+	if cErr := tx.CloseTx(ctx, err); cErr != nil {
+		if err != nil {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to rollback transaction",
+				"error", cErr)
+
+			return err
+		}
+
+		return errors.Wrap(cErr, errors.ErrDatabase,
+			"unable to commit transaction")
+	}
+
+	return err
+}
+
 // UpdateResourceError allows external systems to update resource error status.
 func (s *Service) UpdateResourceError(ctx context.Context,
 	accountID, resourceID string,
 	resourceError error,
 ) error {
-	ctx = context.WithValue(ctx, request.CtxKeyUserID, request.SystemUser)
-
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, request.ScopeSuperuser)
-
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, accountID)
+	ctx = request.Elevate(ctx, accountID)
 
 	if resourceError != nil {
 		if _, err := s.UpdateResource(ctx, &Resource{
@@ -1372,8 +1695,7 @@ func (s *Service) ImportResource(ctx context.Context,
 	authSvc AuthService,
 	resourceID string,
 ) error {
-	ctx = context.WithValue(ctx, request.CtxKeyUserID, request.SystemUser)
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, request.ScopeSuperuser)
+	ctx = request.Elevate(ctx, "")
 
 	ar, err := authSvc.GetAccountRepo(ctx)
 	if err != nil {
@@ -1439,13 +1761,18 @@ func (s *Service) ImportResource(ctx context.Context,
 	return nil
 }
 
-// ImportResources loads and updates resource data.
+// ImportResources loads and updates resource data. Only the repository files
+// selected by the account repository filter are imported, unless a non-empty
+// filter is provided, which replaces it for a partial import. Resources for
+// files which are not selected are not changed, and a partial import does not
+// record the account commit hash, so the next import still processes the
+// whole commit.
 func (s *Service) ImportResources(ctx context.Context,
 	force bool,
+	filter *repo.Filter,
 	authSvc AuthService,
 ) error {
-	ctx = context.WithValue(ctx, request.CtxKeyUserID, request.SystemUser)
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, request.ScopeSuperuser)
+	ctx = request.Elevate(ctx, "")
 
 	ar, err := authSvc.GetAccountRepo(ctx)
 	if err != nil {
@@ -1453,6 +1780,16 @@ func (s *Service) ImportResources(ctx context.Context,
 			"unable to get account repository")
 	}
 
+	partial := !filter.Empty()
+
+	if !partial {
+		filter, err = repo.NewFilter(ar.RepoFilter.Value)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrImport,
+				"invalid account repository filter")
+		}
+	}
+
 	if !force && ar.RepoStatus.Value == request.StatusImporting {
 		if pli, ok := ar.RepoStatusData.Value["resources_last_imported"]; ok {
 			if i, ok := pli.(int64); ok && i > time.Now().Unix()-120 {
@@ -1483,7 +1820,16 @@ func (s *Service) ImportResources(ctx context.Context,
 			"unable to set account repository status")
 	}
 
-	updated, deleted, uErr := s.updateResources(ctx, ar, force)
+	var updated, deleted, quarantined int
+
+	cli, uErr := s.getRepoClient(ar.Repo.Value)
+	if uErr != nil {
+		uErr = errors.Wrap(uErr, errors.ErrImport,
+			"unable to create repository client")
+	} else {
+		updated, deleted, quarantined, uErr = s.updateResources(ctx, cli, force,
+			filter, partial)
+	}
 
 	ar, err = authSvc.GetAccountRepo(ctx)
 	if err != nil {
@@ -1505,6 +1851,14 @@ func (s *Service) ImportResources(ctx context.Context,
 
 	dm["resources_deleted"] = deleted
 
+	dm["resources_quarantined"] = quarantined
+
+	if fc, ok := cli.(repo.FetchStatsClient); ok {
+		if fs := fc.FetchStats(); fs != nil {
+			dm["resources_fetch"] = fs
+		}
+	}
+
 	if uErr != nil {
 		ar.RepoStatus.Value = request.StatusError
 
@@ -1523,6 +1877,8 @@ func (s *Service) ImportResources(ctx context.Context,
 	}
 
 	if uErr != nil {
+		s.notifyImportFailure(ctx, uErr)
+
 		return uErr
 	}
 
@@ -1530,30 +1886,30 @@ func (s *Service) ImportResources(ctx context.Context,
 }
 
 // updateResources updates the resources based on the contents of the account
-// import repository.
+// import repository, retrieved using the client, selected by the filter. Files
+// which can not be parsed, or which are not valid resources, are quarantined,
+// rather than failing the import, and the resources previously imported from
+// them are kept. It returns the numbers of resources updated and deleted, and
+// of files quarantined.
 func (s *Service) updateResources(ctx context.Context,
-	ar *auth.AccountRepo,
+	cli repo.Client,
 	force bool,
-) (int, int, error) {
+	filter *repo.Filter,
+	partial bool,
+) (int, int, int, error) {
 	ctx, cancel := request.ContextReplaceTimeout(ctx, s.cfg.ServerTimeout())
 
 	defer cancel()
 
-	cli, err := s.getRepoClient(ar.Repo.Value)
-	if err != nil {
-		return 0, 0, errors.Wrap(err, errors.ErrImport,
-			"unable to create repository client")
-	}
-
 	newHash, err := cli.Commit(ctx)
 	if err != nil {
-		return 0, 0, errors.Wrap(err, errors.ErrImport,
+		return 0, 0, 0, errors.Wrap(err, errors.ErrImport,
 			"unable to get repository commit hash")
 	}
 
 	ch, err := s.getAccountResourceCommitHash(ctx)
 	if err != nil {
-		return 0, 0, errors.Wrap(err, errors.ErrImport,
+		return 0, 0, 0, errors.Wrap(err, errors.ErrImport,
 			"unable to get account commit_hash")
 	}
 
@@ -1563,12 +1919,12 @@ func (s *Service) updateResources(ctx context.Context,
 			"updated", 0,
 			"deleted", 0)
 
-		return 0, 0, nil
+		return 0, 0, 0, nil
 	}
 
 	res, err := cli.ListAll(ctx, "resources/")
 	if err != nil {
-		return 0, 0, errors.Wrap(err, errors.ErrImport,
+		return 0, 0, 0, errors.Wrap(err, errors.ErrImport,
 			"unable to list repository path",
 			"path", "resources/")
 	}
@@ -1578,6 +1934,26 @@ func (s *Service) updateResources(ctx context.Context,
 	errs := errors.New(errors.ErrImport,
 		"unable to import resources")
 
+	// Imported resources may depend on resources imported after them.
+	pending := map[string]bool{}
+
+	// Resources for files not selected by the filter, or quarantined, are
+	// kept unchanged.
+	keep, skipped := []string{}, []string{}
+
+	quarantined := []*ImportError{}
+
+	for _, i := range res {
+		if i.Type == "file" || i.Type == "commit_file" {
+			id := strings.TrimPrefix(strings.TrimPrefix(i.Path, "/"),
+				"resources/")
+
+			if filter.MatchPath(id) {
+				pending[strings.TrimSuffix(id, filepath.Ext(id))] = true
+			}
+		}
+	}
+
 	for _, i := range res {
 		if i.Type == "file" || i.Type == "commit_file" {
 			ctx, cancel := request.ContextReplaceTimeout(ctx,
@@ -1585,13 +1961,55 @@ func (s *Service) updateResources(ctx context.Context,
 
 			defer cancel()
 
-			resourceID := strings.TrimPrefix(strings.TrimPrefix(i.Path, "/"),
-				"resources/")
+			filePath := strings.TrimPrefix(i.Path, "/")
+
+			resourceID := strings.TrimPrefix(filePath, "resources/")
 
 			ext := filepath.Ext(resourceID)
 
+			match := filter.MatchPath(resourceID)
+
 			resourceID = strings.TrimSuffix(resourceID, ext)
 
+			if !match {
+				keep = append(keep, resourceID)
+
+				skipped = append(skipped, filePath)
+
+				continue
+			}
+
+			var m map[string]any
+
+			if filter.HasSelector() {
+				m, err = s.getRepoResource(ctx, cli, resourceID, ext)
+				if errors.Has(err, errors.ErrInvalidRequest) {
+					quarantined = append(quarantined,
+						newImportError(filePath, newHash, err))
+
+					keep = append(keep, resourceID)
+
+					continue
+				}
+
+				if err != nil {
+					errs.Errors = append(errs.Errors, errors.Wrap(err,
+						errors.ErrImport,
+						"unable to read resource repository file",
+						"resource_id", resourceID))
+
+					continue
+				}
+
+				if !filter.Match(m) {
+					keep = append(keep, resourceID)
+
+					skipped = append(skipped, filePath)
+
+					continue
+				}
+			}
+
 			a, err := s.GetResource(ctx, resourceID, nil)
 			if err != nil && !errors.Has(err, errors.ErrNotFound) {
 				errs.Errors = append(errs.Errors, errors.Wrap(err,
@@ -1623,25 +2041,25 @@ func (s *Service) updateResources(ctx context.Context,
 				continue
 			}
 
-			vb, err := cli.Get(ctx, "resources/"+resourceID+ext)
-			if err != nil {
-				errs.Errors = append(errs.Errors, errors.Wrap(err,
-					errors.ErrImport,
-					"unable to get resource repository file",
-					"resource_id", resourceID))
+			if m == nil {
+				m, err = s.getRepoResource(ctx, cli, resourceID, ext)
+				if errors.Has(err, errors.ErrInvalidRequest) {
+					quarantined = append(quarantined,
+						newImportError(filePath, newHash, err))
 
-				continue
-			}
+					keep = append(keep, resourceID)
 
-			m := map[string]any{}
+					continue
+				}
 
-			if err := yaml.Unmarshal(vb, &m); err != nil {
-				errs.Errors = append(errs.Errors, errors.Wrap(err,
-					errors.ErrImport,
-					"unable to parse resource repository file",
-					"resource_id", resourceID))
+				if err != nil {
+					errs.Errors = append(errs.Errors, errors.Wrap(err,
+						errors.ErrImport,
+						"unable to read resource repository file",
+						"resource_id", resourceID))
 
-				continue
+					continue
+				}
 			}
 
 			vmb, err := json.Marshal(&m)
@@ -1655,11 +2073,12 @@ func (s *Service) updateResources(ctx context.Context,
 			}
 
 			if err := json.Unmarshal(vmb, &a); err != nil {
-				errs.Errors = append(errs.Errors, errors.Wrap(err,
-					errors.ErrImport,
-					"invalid repository resource contents",
-					"resource_id", resourceID,
-					"contents", string(vmb)))
+				quarantined = append(quarantined,
+					newImportError(filePath, newHash, errors.Wrap(err,
+						errors.ErrInvalidRequest,
+						"invalid repository resource contents")))
+
+				keep = append(keep, resourceID)
 
 				continue
 			}
@@ -1684,7 +2103,16 @@ func (s *Service) updateResources(ctx context.Context,
 				Set: true, Valid: true, Value: newHash,
 			}
 
-			if _, err := s.CreateResource(ctx, a); err != nil {
+			if _, err := s.createResource(ctx, a, pending); err != nil {
+				if errors.Has(err, errors.ErrInvalidRequest) {
+					quarantined = append(quarantined,
+						newImportError(filePath, newHash, err))
+
+					keep = append(keep, resourceID)
+
+					continue
+				}
+
 				errs.Errors = append(errs.Errors, errors.Wrap(err,
 					errors.ErrDatabase,
 					"unable to create imported resource",
@@ -1698,28 +2126,47 @@ func (s *Service) updateResources(ctx context.Context,
 	}
 
 	if len(errs.Errors) > 0 {
+		errs = errs.Group(importErrorSamples)
+
 		s.log.Log(ctx, logger.LvlWarn,
 			"unable to complete resource import",
 			"updated", updated,
-			"errors", errs.Errors)
+			"errors", errs.Groups)
 
-		return updated, 0, errs
+		return updated, 0, len(quarantined), errs
 	}
 
 	ctx, cancel = request.ContextReplaceTimeout(ctx, s.cfg.ServerTimeout())
 
 	defer cancel()
 
+	if err := s.setImportErrors(ctx, quarantined, skipped); err != nil {
+		errs.Errors = append(errs.Errors, errors.Wrap(err,
+			errors.ErrDatabase,
+			"unable to record quarantined repository files"))
+	}
+
+	if len(quarantined) > 0 {
+		s.log.Log(ctx, logger.LvlWarn,
+			"repository files quarantined by resource import",
+			"quarantined", len(quarantined))
+	}
+
 	deleted := 0
 
 	if newHash != "" {
-		err := s.setAccountResourceCommitHash(ctx, newHash)
+		var err error
+
+		if !partial {
+			err = s.setAccountResourceCommitHash(ctx, newHash)
+		}
+
 		if err != nil {
 			errs.Errors = append(errs.Errors, errors.Wrap(err,
 				errors.ErrDatabase,
 				"unable to set account resource_commit_hash"))
 		} else {
-			deleted, err = s.deleteResources(ctx, newHash)
+			deleted, err = s.deleteResources(ctx, newHash, keep)
 			if err != nil {
 				errs.Errors = append(errs.Errors, errors.Wrap(err,
 					errors.ErrDatabase,
@@ -1730,29 +2177,35 @@ func (s *Service) updateResources(ctx context.Context,
 	}
 
 	if len(errs.Errors) > 0 {
+		errs = errs.Group(importErrorSamples)
+
 		s.log.Log(ctx, logger.LvlWarn,
 			"unable to complete resource import",
 			"updated", updated,
 			"deleted", deleted,
-			"errors", errs.Errors)
+			"errors", errs.Groups)
 
-		return updated, deleted, errs
+		return updated, deleted, len(quarantined), errs
 	}
 
 	s.log.Log(ctx, logger.LvlInfo,
 		"resource import completed",
 		"updated", updated,
-		"deleted", deleted)
+		"deleted", deleted,
+		"quarantined", len(quarantined))
 
-	return updated, deleted, nil
+	return updated, deleted, len(quarantined), nil
 }
 
-// deleteResources deletes all resources not in the specified list.
+// deleteResources deletes all repository resources not imported from the
+// specified commit, except for the resources to keep.
 func (s *Service) deleteResources(ctx context.Context,
 	commit string,
+	keep []string,
 ) (int, error) {
 	base := `DELETE FROM resource
 		WHERE source = 'git' AND commit_hash <> $1::TEXT
+			AND NOT resource_id::TEXT = ANY($2::TEXT[])
 		RETURNING resource_id`
 
 	q := sqldb.NewQuery(&sqldb.QueryOptions{
@@ -1760,7 +2213,7 @@ func (s *Service) deleteResources(ctx context.Context,
 		Type:   sqldb.QueryDelete,
 		Base:   base,
 		Fields: resourceFields,
-		Params: []any{commit},
+		Params: []any{commit, keep},
 	})
 
 	rows, err := q.Query(ctx)
@@ -1811,6 +2264,26 @@ func (s *Service) deleteResources(ctx context.Context,
 	return count, nil
 }
 
+// getRepoResource retrieves and decodes a resource repository file.
+func (s *Service) getRepoResource(ctx context.Context,
+	cli repo.Client,
+	resourceID, ext string,
+) (map[string]any, error) {
+	vb, err := cli.Get(ctx, "resources/"+resourceID+ext)
+	if err != nil {
+		return nil, err
+	}
+
+	m := map[string]any{}
+
+	if err := yaml.Unmarshal(vb, &m); err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to parse resource repository file")
+	}
+
+	return m, nil
+}
+
 // getAccountResourceCommitHash retrieves the current account commit hash.
 func (s *Service) getAccountResourceCommitHash(ctx context.Context,
 ) (string, error) {
@@ -1885,7 +2358,10 @@ func (s *Service) setAccountResourceCommitHash(ctx context.Context,
 	return nil
 }
 
-// Update periodically imports resources data.
+// Update periodically imports resources data, updates the status of stale
+// agents, clears resource data items whose clear_delay has elapsed, evaluates
+// alert rules, and purges data older than the retention period of each
+// account.
 func (s *Service) Update(ctx context.Context,
 	authSvc AuthService,
 ) context.CancelFunc {
@@ -1918,19 +2394,11 @@ func (s *Service) Update(ctx context.Context,
 					wg.Add(1)
 
 					go func(ctx context.Context, accountID string) {
-						ctx = context.WithValue(ctx, request.CtxKeyAccountID,
-							accountID)
-						ctx = context.WithValue(ctx, request.CtxKeyUserID,
-							request.SystemUser)
-						ctx = context.WithValue(ctx, request.CtxKeyScopes,
-							request.ScopeSuperuser)
-
-						if tu, err := uuid.NewRandom(); err == nil {
-							ctx = context.WithValue(ctx, request.CtxKeyTraceID,
-								tu.String())
-						}
+						ctx = request.Elevate(ctx, accountID)
+
+						ctx = request.WithNewTraceID(ctx)
 
-						if err := s.ImportResources(ctx, false,
+						if err := s.ImportResources(ctx, false, nil,
 							authSvc); err != nil {
 							lvl := logger.LvlError
 
@@ -1970,12 +2438,20 @@ func (s *Service) Update(ctx context.Context,
 		}
 	}(ctx)
 
+	go s.updateAgents(ctx)
+
+	go s.clearAccounts(ctx)
+
+	go s.alertAccounts(ctx)
+
+	go s.purgeAccounts(ctx)
+
 	return cancel
 }
 
 // getAllAccounts retrieves a list of all active account ID's.
 func (s *Service) getAllAccounts(ctx context.Context) ([]string, error) {
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, request.SystemAccount)
+	ctx = request.WithAccountID(ctx, request.SystemAccount)
 
 	base := `SELECT account.account_id
 	FROM account
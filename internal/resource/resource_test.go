@@ -2,13 +2,22 @@ package resource_test
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
 	"net/url"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/dhaifley/apigo/internal/auth"
 	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/config"
 	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/dhaifley/apigo/internal/repo"
 	"github.com/dhaifley/apigo/internal/request"
@@ -113,11 +122,11 @@ var TestResource = resource.Resource{
 func mockAuthContext() context.Context {
 	ctx := context.Background()
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, TestID)
+	ctx = request.WithAccountID(ctx, TestID)
 
-	ctx = context.WithValue(ctx, request.CtxKeyUserID, TestID)
+	ctx = request.WithUserID(ctx, TestID)
 
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, strings.Join([]string{
+	ctx = request.WithScopes(ctx, strings.Join([]string{
 		request.ScopeAccountRead,
 		request.ScopeUserRead,
 		request.ScopeResourcesRead,
@@ -130,11 +139,11 @@ func mockAuthContext() context.Context {
 func mockAdminAuthContext() context.Context {
 	ctx := context.Background()
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, TestID)
+	ctx = request.WithAccountID(ctx, TestID)
 
-	ctx = context.WithValue(ctx, request.CtxKeyUserID, TestID)
+	ctx = request.WithUserID(ctx, TestID)
 
-	ctx = context.WithValue(ctx, request.CtxKeyScopes, request.ScopeSuperuser)
+	ctx = request.WithScopes(ctx, request.ScopeSuperuser)
 
 	return ctx
 }
@@ -160,6 +169,14 @@ func (m *mockRepoClient) Commit(ctx context.Context) (string, error) {
 	return "test", nil
 }
 
+func (m *mockRepoClient) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockRepoClient) FetchStats() *repo.FetchStats {
+	return &repo.FetchStats{Clone: true, Objects: 1, Bytes: 1}
+}
+
 type mockAuthSvc struct {
 	v *auth.AccountRepo
 }
@@ -193,7 +210,8 @@ func (m *mockAuthSvc) SetAccountRepo(ctx context.Context,
 func mockTransaction(mock pgxmock.PgxCommonIface) {
 	mock.ExpectBegin()
 
-	mock.ExpectExec("SET app.account_id").
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
 		WillReturnResult(pgxmock.NewResult("SET", 1))
 }
 
@@ -218,6 +236,12 @@ func mockResourceSummaryRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
 }
 
 func mockResourceRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mockResourceRowsFor(mock, TestResource)
+}
+
+func mockResourceRowsFor(mock pgxmock.PgxCommonIface,
+	r resource.Resource,
+) *pgxmock.Rows {
 	return mock.NewRows([]string{
 		"resource_id",
 		"name",
@@ -230,27 +254,78 @@ func mockResourceRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
 		"clear_condition",
 		"clear_after",
 		"clear_delay",
+		"duplicate_policy",
+		"key_strategy",
+		"depends_on",
 		"data",
 		"source",
 		"commit_hash",
 	}).AddRow(
-		TestResource.ResourceID.Value,
-		TestResource.Name.Value,
-		TestResource.Version.Value,
-		TestResource.Description.Value,
-		TestResource.Status.Value,
-		TestResource.StatusData.Value,
-		TestResource.KeyField.Value,
-		TestResource.KeyRegex.Value,
-		TestResource.ClearCondition.Value,
-		TestResource.ClearAfter.Value,
-		TestResource.ClearDelay.Value,
-		TestResource.Data.Value,
-		TestResource.Source.Value,
-		TestResource.CommitHash.Value,
+		r.ResourceID.Value,
+		r.Name.Value,
+		r.Version.Value,
+		r.Description.Value,
+		r.Status.Value,
+		r.StatusData.Value,
+		r.KeyField.Value,
+		r.KeyRegex.Value,
+		r.ClearCondition.Value,
+		r.ClearAfter.Value,
+		r.ClearDelay.Value,
+		r.DuplicatePolicy.Value,
+		r.KeyStrategy.Value,
+		nil,
+		r.Data.Value,
+		r.Source.Value,
+		r.CommitHash.Value,
 	)
 }
 
+func TestResourceValidateFields(t *testing.T) {
+	t.Parallel()
+
+	r := &resource.Resource{
+		ClearDelay: request.FieldInt64{Set: true, Valid: true, Value: -1},
+		Status:     request.FieldString{Set: true},
+	}
+
+	var e *errors.Error
+
+	if !errors.As(r.ValidateCreate(config.NewDefault()), &e) {
+		t.Fatal("Expected validation error, got: nil")
+	}
+
+	exp := []*errors.FieldError{
+		{Field: "name", Code: errors.FieldRequired, Message: "missing name"},
+		{
+			Field: "key_field", Code: errors.FieldRequired,
+			Message: "missing key_field",
+		},
+		{
+			Field: "clear_delay", Code: errors.FieldInvalid,
+			Message: "invalid clear_delay",
+		},
+		{
+			Field: "status", Code: errors.FieldNull,
+			Message: "status must not be null",
+		},
+	}
+
+	if len(e.Fields) != len(exp) {
+		t.Fatalf("Expected fields: %v, got: %v", len(exp), len(e.Fields))
+	}
+
+	for i, f := range exp {
+		if *e.Fields[i] != *f {
+			t.Errorf("Expected field: %+v, got: %+v", f, e.Fields[i])
+		}
+	}
+
+	if e.Msg != "missing name" {
+		t.Errorf("Expected message: missing name, got: %v", e.Msg)
+	}
+}
+
 func TestGetResources(t *testing.T) {
 	t.Parallel()
 
@@ -275,7 +350,8 @@ func TestGetResources(t *testing.T) {
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
-		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceKeyRows(mock))
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockResourceKeyRows(mock))
 
 	mockTransaction(mock)
 
@@ -312,7 +388,8 @@ func TestGetResources(t *testing.T) {
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
-		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceKeyRows(mock))
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockResourceKeyRows(mock))
 
 	res, _, err = svc.GetResources(ctx, &search.Query{
 		Search: "and(name:*)",
@@ -340,7 +417,8 @@ func TestGetResources(t *testing.T) {
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
-		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceKeyRows(mock))
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockResourceKeyRows(mock))
 
 	mockTransaction(mock)
 
@@ -367,6 +445,44 @@ func TestGetResources(t *testing.T) {
 	}
 }
 
+func TestCountResources(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery(
+		"SELECT COUNT\\(\\*\\) FROM \\(SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{"count"}).AddRow(int64(25)))
+
+	mock.ExpectCommit()
+
+	n, err := svc.CountResources(ctx, &search.Query{
+		Search: "and(name:*)",
+		Size:   10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 25 {
+		t.Errorf("Expected count: 25, got: %v", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
 func TestGetResource(t *testing.T) {
 	t.Parallel()
 
@@ -381,6 +497,8 @@ func TestGetResource(t *testing.T) {
 
 	svc := resource.NewService(nil, md, mc, nil, nil, nil)
 
+	mockResourceAccess(mock)
+
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
@@ -404,6 +522,8 @@ func TestGetResource(t *testing.T) {
 		t.Error("expected cache set")
 	}
 
+	mockResourceAccess(mock)
+
 	res, err = svc.GetResource(ctx, TestResource.ResourceID.Value, nil)
 	if err != nil {
 		t.Fatal(err)
@@ -423,10 +543,11 @@ func TestGetResource(t *testing.T) {
 	}
 }
 
-func TestCreateResource(t *testing.T) {
+func TestGetResourceCoalesced(t *testing.T) {
 	t.Parallel()
 
-	ctx := mockAuthContext()
+	// Access checks are not coalesced, so a context exempt from them is used.
+	ctx := mockAdminAuthContext()
 
 	mc := &cache.MockCache{}
 
@@ -439,27 +560,85 @@ func TestCreateResource(t *testing.T) {
 
 	mockTransaction(mock)
 
-	args := make([]any, 16)
+	mock.ExpectQuery("SELECT (.+) FROM resource").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockResourceRows(mock)).
+		WillDelayFor(250 * time.Millisecond)
 
-	for i := 0; i < 16; i++ {
-		args[i] = pgxmock.AnyArg()
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			res, err := svc.GetResource(ctx, TestResource.ResourceID.Value,
+				nil)
+			if err != nil {
+				t.Error(err)
+
+				return
+			}
+
+			if res.ResourceID.Value != TestResource.ResourceID.Value {
+				t.Errorf("Expected id: %v, got: %v",
+					TestResource.ResourceID.Value, res.ResourceID.Value)
+			}
+		}()
 	}
 
-	mock.ExpectQuery("INSERT INTO resource").
-		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+	wg.Wait()
 
-	res, err := svc.CreateResource(ctx, &TestResource)
+	if !mc.WasSet() {
+		t.Error("expected cache set")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestGetResourceFields(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if res.ResourceID.Value != TestResource.ResourceID.Value {
-		t.Errorf("Expected id: %v, got: %v",
-			TestResource.ResourceID.Value, res.ResourceID.Value)
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockResourceAccess(mock)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT\\s+resource.resource_id AS resource_resource_id," +
+		"\\s+resource.name AS resource_name\\s+FROM resource").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{"resource_id", "name"}).
+			AddRow(TestResource.ResourceID.Value, TestResource.Name.Value))
+
+	res, err := svc.GetResource(ctx, TestResource.ResourceID.Value,
+		sqldb.FieldOptions{sqldb.FieldSelect("name")})
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	if !mc.WasDeleted() {
-		t.Error("expected cache delete")
+	if res.Name.Value != TestResource.Name.Value {
+		t.Errorf("Expected name: %v, got: %v",
+			TestResource.Name.Value, res.Name.Value)
+	}
+
+	if res.Status.Set {
+		t.Errorf("Expected status not set, got: %v", res.Status)
+	}
+
+	if _, err := svc.GetResource(ctx, TestResource.ResourceID.Value,
+		sqldb.FieldOptions{sqldb.FieldSelect("resource_key")}); err == nil {
+		t.Error("Expected error for hidden field but got nil")
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -467,7 +646,7 @@ func TestCreateResource(t *testing.T) {
 	}
 }
 
-func TestUpdateResource(t *testing.T) {
+func TestGetResourceRevisions(t *testing.T) {
 	t.Parallel()
 
 	ctx := mockAuthContext()
@@ -481,29 +660,80 @@ func TestUpdateResource(t *testing.T) {
 
 	svc := resource.NewService(nil, md, mc, nil, nil, nil)
 
-	mockTransaction(mock)
+	opts, err := sqldb.ParseFieldOptions(url.Values{
+		"include": []string{"revisions"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	args := make([]any, 16)
+	mockResourceAccess(mock)
 
-	for i := 0; i < 16; i++ {
-		args[i] = pgxmock.AnyArg()
-	}
+	mockTransaction(mock)
 
-	mock.ExpectQuery("UPDATE resource").
-		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+	r := TestResource
 
-	res, err := svc.UpdateResource(ctx, &TestResource)
+	mock.ExpectQuery("SELECT (.+)\\(SELECT COUNT\\(\\*\\) FROM change\\s+" +
+		"WHERE change.entity_type = 'resource'\\s+" +
+		"AND change.entity_id = resource.resource_id(.+)" +
+		"AS revisions\\s+FROM resource").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{
+			"resource_id",
+			"name",
+			"version",
+			"description",
+			"status",
+			"status_data",
+			"key_field",
+			"key_regex",
+			"clear_condition",
+			"clear_after",
+			"clear_delay",
+			"duplicate_policy",
+			"key_strategy",
+			"depends_on",
+			"data",
+			"source",
+			"commit_hash",
+			"revisions",
+		}).AddRow(
+			r.ResourceID.Value,
+			r.Name.Value,
+			r.Version.Value,
+			r.Description.Value,
+			r.Status.Value,
+			r.StatusData.Value,
+			r.KeyField.Value,
+			r.KeyRegex.Value,
+			r.ClearCondition.Value,
+			r.ClearAfter.Value,
+			r.ClearDelay.Value,
+			r.DuplicatePolicy.Value,
+			r.KeyStrategy.Value,
+			nil,
+			r.Data.Value,
+			r.Source.Value,
+			r.CommitHash.Value,
+			int64(3),
+		))
+
+	res, err := svc.GetResource(ctx, TestResource.ResourceID.Value, opts)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if res.ResourceID.Value != TestResource.ResourceID.Value {
-		t.Errorf("Expected id: %v, got: %v",
-			TestResource.ResourceID.Value, res.ResourceID.Value)
+	if !res.Revisions.Valid || res.Revisions.Value != 3 {
+		t.Errorf("Expected revisions: 3, got: %v", res.Revisions)
 	}
 
-	if !mc.WasDeleted() {
-		t.Error("expected cache delete")
+	if mc.WasMissed() || mc.WasSet() {
+		t.Error("expected cache not used")
+	}
+
+	if _, err := svc.GetResource(ctx, TestResource.ResourceID.Value,
+		sqldb.FieldOptions{sqldb.OptGrants}); err == nil {
+		t.Error("Expected error for invalid include but got nil")
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -511,7 +741,7 @@ func TestUpdateResource(t *testing.T) {
 	}
 }
 
-func TestDeleteResource(t *testing.T) {
+func TestCreateResource(t *testing.T) {
 	t.Parallel()
 
 	ctx := mockAuthContext()
@@ -527,20 +757,37 @@ func TestDeleteResource(t *testing.T) {
 
 	mockTransaction(mock)
 
-	args := make([]any, 16)
+	args := make([]any, 15)
 
-	for i := 0; i < 16; i++ {
+	for i := 0; i < 15; i++ {
 		args[i] = pgxmock.AnyArg()
 	}
 
-	mock.ExpectExec("DELETE FROM resource").
+	mock.ExpectQuery("INSERT INTO resource").
+		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+
+	mock.ExpectExec("SELECT set_config").
 		WithArgs(pgxmock.AnyArg()).
-		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+		WillReturnResult(pgxmock.NewResult("SET", 1))
 
-	if err := svc.DeleteResource(ctx, TestUUID); err != nil {
+	mock.ExpectExec("INSERT INTO resource_data").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectCommit()
+
+	res, err := svc.CreateResource(ctx, &TestResource)
+	if err != nil {
 		t.Fatal(err)
 	}
 
+	if res.ResourceID.Value != TestResource.ResourceID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestResource.ResourceID.Value, res.ResourceID.Value)
+	}
+
 	if !mc.WasDeleted() {
 		t.Error("expected cache delete")
 	}
@@ -550,7 +797,7 @@ func TestDeleteResource(t *testing.T) {
 	}
 }
 
-func TestUpdateResourceData(t *testing.T) {
+func TestUpdateResource(t *testing.T) {
 	t.Parallel()
 
 	ctx := mockAuthContext()
@@ -564,43 +811,35 @@ func TestUpdateResourceData(t *testing.T) {
 
 	svc := resource.NewService(nil, md, mc, nil, nil, nil)
 
+	mockResourceAccess(mock)
+
 	mockTransaction(mock)
 
-	mock.ExpectQuery("SELECT (.+) FROM resource").
-		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceRows(mock))
+	mock.ExpectExec("INSERT INTO resource_data").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
 	mockTransaction(mock)
 
-	args := make([]any, 16)
+	args := make([]any, 15)
 
-	for i := 0; i < 16; i++ {
+	for i := 0; i < 15; i++ {
 		args[i] = pgxmock.AnyArg()
 	}
 
 	mock.ExpectQuery("UPDATE resource").
 		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
 
-	res, err := svc.UpdateResourceData(ctx, map[string]any{
-		"resources": []any{
-			map[string]any{
-				"resource_id": TestUUID,
-				"account_id":  TestUUID,
-				"cleared_on":  int64(1),
-			},
-		},
-	}, TestID, TestResource.ResourceID.Value)
+	res, err := svc.UpdateResource(ctx, &TestResource)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	if res.ResourceID.Value != TestResource.ResourceID.Value {
-		t.Errorf("Expected resource_id: %v, got: %v",
-			TestResource.ResourceID, res.ResourceID.Value)
-	}
-
-	if _, ok := res.Data.Value[TestUUID]; !ok {
-		t.Errorf("Expected resource data to contain key: %v, got: %v",
-			TestUUID, res.Data.Value)
+		t.Errorf("Expected id: %v, got: %v",
+			TestResource.ResourceID.Value, res.ResourceID.Value)
 	}
 
 	if !mc.WasDeleted() {
@@ -612,38 +851,46 @@ func TestUpdateResourceData(t *testing.T) {
 	}
 }
 
-func TestUpdateResourceError(t *testing.T) {
+func TestPreviewClearCondition(t *testing.T) {
 	t.Parallel()
 
 	ctx := mockAuthContext()
 
-	mc := &cache.MockCache{}
-
 	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockResourceAccess(mock)
 
 	mockTransaction(mock)
 
-	args := make([]any, 5)
+	mock.ExpectQuery("SELECT (.+) FROM resource_data").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mock.NewRows([]string{"data_key", "data"}).
+			AddRow("1", []byte(`{"id":1,"status":"ok","count":10}`)).
+			AddRow("2", []byte(`{"id":2,"status":"error","count":5}`)).
+			AddRow("3", []byte(`{"id":3,"status":"ok","count":1}`)))
 
-	for i := 0; i < 5; i++ {
-		args[i] = pgxmock.AnyArg()
+	res, err := svc.PreviewClearCondition(ctx, TestResource.ResourceID.Value,
+		"and(status:ok,gt(count:5))", 10)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	mock.ExpectQuery("UPDATE resource").
-		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+	if res.Evaluated != 3 {
+		t.Errorf("Expected evaluated: 3, got: %v", res.Evaluated)
+	}
 
-	if err := svc.UpdateResourceError(ctx, TestUUID, TestUUID,
-		errors.New(errors.ErrServer, "test error")); err != nil {
-		t.Fatal(err)
+	if res.Cleared != 1 || !slices.Equal(res.Keys, []string{"1"}) {
+		t.Errorf("Expected cleared keys: [1], got: %v", res.Keys)
 	}
 
-	if !mc.WasDeleted() {
-		t.Error("expected cache delete")
+	if _, err := svc.PreviewClearCondition(ctx, TestResource.ResourceID.Value,
+		"and(", 10); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -651,10 +898,10 @@ func TestUpdateResourceError(t *testing.T) {
 	}
 }
 
-func TestImportResources(t *testing.T) {
+func TestGetResourceData(t *testing.T) {
 	t.Parallel()
 
-	ctx := mockAdminAuthContext()
+	ctx := mockAuthContext()
 
 	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
 	if err != nil {
@@ -663,45 +910,30 @@ func TestImportResources(t *testing.T) {
 
 	svc := resource.NewService(nil, md, nil, nil, nil, nil)
 
-	svc.SetRepoClient(&mockRepoClient{})
-
-	ma := &mockAuthSvc{}
-
-	mockTransaction(mock)
-
-	mock.ExpectQuery("SELECT resource_commit_hash FROM account").
-		WillReturnRows(mockAccountCommitHashRows(mock))
-
-	mockTransaction(mock)
-
-	mock.ExpectQuery("UPDATE account SET resource_commit_hash").
-		WithArgs(pgxmock.AnyArg()).
-		WillReturnRows(mockAccountCommitHashRows(mock))
+	mockResourceAccess(mock)
 
 	mockTransaction(mock)
 
-	mock.ExpectQuery("DELETE FROM resource").WithArgs(pgxmock.AnyArg()).
-		WillReturnRows(mockResourceIDRows(mock))
+	mock.ExpectQuery("SELECT (.+) FROM resource_data (.+) ORDER BY "+
+		"resource_data.data_key ASC").
+		WithArgs(TestResource.ResourceID.Value, "error").
+		WillReturnRows(mock.NewRows([]string{"key", "data", "ts"}).
+			AddRow("2", []byte(`{"id":2,"status":"error"}`), int64(1)))
 
-	if err := svc.ImportResources(ctx, true, ma); err != nil {
+	res, err := svc.GetResourceData(ctx, TestResource.ResourceID.Value,
+		&search.Query{Search: "and(data.status:error)"})
+	if err != nil {
 		t.Fatal(err)
 	}
 
-	if ma.v.RepoStatus.Value != request.StatusActive {
-		t.Errorf("Expected repo status: %v, got: %v",
-			request.StatusActive, ma.v.RepoStatus.Value)
-	}
-
-	expData := []string{
-		"resources_last_imported",
-		"resources_deleted",
-		"resources_updated",
+	if len(res) != 1 || res[0].Key.Value != "2" ||
+		res[0].Data.Value["status"] != "error" {
+		t.Errorf("Expected data item: 2, got: %+v", res)
 	}
 
-	for _, expF := range expData {
-		if _, ok := ma.v.RepoStatusData.Value[expF]; !ok {
-			t.Errorf("Expected repo status data field: %v", expF)
-		}
+	if _, err := svc.GetResourceData(ctx, "invalid",
+		nil); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -709,10 +941,10 @@ func TestImportResources(t *testing.T) {
 	}
 }
 
-func TestImportResource(t *testing.T) {
+func TestGetResourceDataSummary(t *testing.T) {
 	t.Parallel()
 
-	ctx := mockAdminAuthContext()
+	ctx := mockAuthContext()
 
 	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
 	if err != nil {
@@ -721,21 +953,39 @@ func TestImportResource(t *testing.T) {
 
 	svc := resource.NewService(nil, md, nil, nil, nil, nil)
 
-	svc.SetRepoClient(&mockRepoClient{})
+	mockResourceAccess(mock)
 
 	mockTransaction(mock)
 
-	args := make([]any, 16)
+	mock.ExpectQuery("SELECT (.+) FROM resource_data (.+) GROUP BY").
+		WithArgs(TestResource.ResourceID.Value, []string{"host", "region"},
+			[]string{"load"}).
+		WillReturnRows(mock.NewRows([]string{
+			"value", "count", "sum", "min", "max", "avg",
+		}).AddRow("east", int64(2), 3.0, 1.0, 2.0, 1.5).
+			AddRow(nil, int64(1), nil, nil, nil, nil))
 
-	for i := 0; i < 16; i++ {
-		args[i] = pgxmock.AnyArg()
+	res, err := svc.GetResourceDataSummary(ctx, TestResource.ResourceID.Value,
+		"host.region", "load", nil)
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	mock.ExpectQuery("INSERT INTO resource").
-		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+	if len(res) != 2 || res[0].Value.Value != "east" || res[0].Count != 2 ||
+		res[0].Avg.Value != 1.5 {
+		t.Errorf("Expected summary for: east, got: %+v", res)
+	}
 
-	if err := svc.ImportResource(ctx, &mockAuthSvc{}, TestUUID); err != nil {
-		t.Fatal(err)
+	if len(res) == 2 && (res[1].Value.Valid || res[1].Sum.Valid) {
+		t.Errorf("Expected null summary values, got: %+v", res[1])
+	}
+
+	for _, group := range []string{"", "host..region"} {
+		if _, err := svc.GetResourceDataSummary(ctx,
+			TestResource.ResourceID.Value, group, "",
+			nil); !errors.Has(err, errors.ErrInvalidRequest) {
+			t.Errorf("Expected invalid request error, got: %v", err)
+		}
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -743,7 +993,7 @@ func TestImportResource(t *testing.T) {
 	}
 }
 
-func TestUpdate(t *testing.T) {
+func TestClearConditionExpressions(t *testing.T) {
 	t.Parallel()
 
 	ctx := mockAuthContext()
@@ -755,13 +1005,1158 @@ func TestUpdate(t *testing.T) {
 
 	svc := resource.NewService(nil, md, nil, nil, nil, nil)
 
-	cancel := svc.Update(ctx, &mockAuthSvc{})
+	item := `{"id":1,"name":"Host-A","tags":["a","b"],"delta":-5,` +
+		`"last_seen":` + strconv.FormatInt(time.Now().Unix()-600, 10) + `}`
+
+	tests := []struct {
+		condition string
+		cleared   int
+	}{
+		{"gt(age(last_seen):300)", 1},
+		{"lt(age(last_seen):300)", 0},
+		{"and(lower(name):host-a)", 1},
+		{"and(contains(tags,'b'):true)", 1},
+		{"and(contains(name,'z'):true)", 0},
+		{"gt(abs(delta):4)", 1},
+		{"and(len(tags):2)", 1},
+		{"and(id * 10 + 1:11)", 1},
+		{"gt(missing + 1:0)", 0},
+	}
+
+	for _, tt := range tests {
+		mockResourceAccess(mock)
+
+		mockTransaction(mock)
+
+		mock.ExpectQuery("SELECT (.+) FROM resource_data").
+			WithArgs(pgxmock.AnyArg()).
+			WillReturnRows(mock.NewRows([]string{"data_key", "data"}).
+				AddRow("1", []byte(item)))
+
+		res, err := svc.PreviewClearCondition(ctx,
+			TestResource.ResourceID.Value, tt.condition, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
 
-	time.Sleep(time.Second)
+		if res.Cleared != tt.cleared {
+			t.Errorf("Expected %v cleared: %v, got: %v", tt.condition,
+				tt.cleared, res.Cleared)
+		}
+	}
 
-	cancel()
+	r := TestResource
 
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Errorf("Unmet database expectations: %v", err)
+	r.ClearCondition = request.FieldString{
+		Set: true, Valid: true,
+		Value: "gt(unknown(last_seen):300)",
+	}
+
+	if err := r.Validate(config.NewDefault()); !errors.Has(err,
+		errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestClearConditionAggregates(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	tests := []struct {
+		condition string
+		cleared   int
+	}{
+		{"and(count(status = 'ok') = count():true)", 0},
+		{"gte(count():3)", 3},
+		{"and(count(status = 'ok'):2)", 3},
+		{"and(status:ok,gt(sum(value):20))", 2},
+		{"gt(value - avg(value):0)", 1},
+		{"and(max(value) = value:true)", 1},
+		{"and(min(value):5)", 3},
+		{"and(all(value > 0):true)", 3},
+		{"and(any(status = 'error'):true)", 3},
+		{"and(status != 'ok':true)", 1},
+	}
+
+	for _, tt := range tests {
+		mockResourceAccess(mock)
+
+		mockTransaction(mock)
+
+		mock.ExpectQuery("SELECT (.+) FROM resource_data").
+			WithArgs(pgxmock.AnyArg()).
+			WillReturnRows(mock.NewRows([]string{"data_key", "data"}).
+				AddRow("3", []byte(`{"id":3,"status":"error","value":15}`)).
+				AddRow("2", []byte(`{"id":2,"status":"ok","value":10}`)).
+				AddRow("1", []byte(`{"id":1,"status":"ok","value":5}`)))
+
+		res, err := svc.PreviewClearCondition(ctx,
+			TestResource.ResourceID.Value, tt.condition, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Cleared != tt.cleared {
+			t.Errorf("Expected %v cleared: %v, got: %v", tt.condition,
+				tt.cleared, res.Cleared)
+		}
+	}
+
+	r := TestResource
+
+	r.ClearCondition = request.FieldString{
+		Set: true, Valid: true,
+		Value: "and(count(count()):1)",
+	}
+
+	if err := r.Validate(config.NewDefault()); !errors.Has(err,
+		errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestDeleteResource(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+
+	mockResourceAccess(mock)
+
+	mockTransaction(mock)
+
+	args := make([]any, 16)
+
+	for i := 0; i < 16; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectExec("DELETE FROM resource").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	if err := svc.DeleteResource(ctx, TestUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestUpdateResourceData(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource (.+) FOR UPDATE OF resource").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceRows(mock))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectExec("INSERT INTO resource_data").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	args := make([]any, 5)
+
+	for i := 0; i < 5; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE resource").
+		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+
+	mock.ExpectCommit()
+
+	res, err := svc.UpdateResourceData(ctx, map[string]any{
+		"resources": []any{
+			map[string]any{
+				"resource_id": TestUUID,
+				"account_id":  TestUUID,
+				"cleared_on":  int64(1),
+			},
+		},
+	}, TestID, TestResource.ResourceID.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ResourceID.Value != TestResource.ResourceID.Value {
+		t.Errorf("Expected resource_id: %v, got: %v",
+			TestResource.ResourceID, res.ResourceID.Value)
+	}
+
+	if _, ok := res.Data.Value[TestUUID]; !ok {
+		t.Errorf("Expected resource data to contain key: %v, got: %v",
+			TestUUID, res.Data.Value)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+// clearsArg values match the data keys cleared by a resource data update.
+type clearsArg []string
+
+func (a clearsArg) Match(v any) bool {
+	clears, ok := v.([]string)
+
+	return ok && slices.Equal(clears, a)
+}
+
+func TestUpdateResourceDataLargeInteger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		value  json.Number
+		clears clearsArg
+	}{{
+		name:   "cleared",
+		value:  "9007199254740993",
+		clears: clearsArg{TestUUID},
+	}, {
+		name:   "not cleared",
+		value:  "9007199254740992",
+		clears: clearsArg{},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := mockAuthContext()
+
+			md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+			r := TestResource
+
+			r.ClearCondition = request.FieldString{
+				Set: true, Valid: true,
+				Value: "and(cleared_on:9007199254740993)",
+			}
+
+			mockTransaction(mock)
+
+			mock.ExpectQuery("SELECT (.+) FROM resource (.+) " +
+				"FOR UPDATE OF resource").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectExec("INSERT INTO resource_data").
+				WithArgs(pgxmock.AnyArg(), tt.clears, pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectQuery("UPDATE resource").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			mock.ExpectCommit()
+
+			if _, err := svc.UpdateResourceData(ctx, map[string]any{
+				"resource_id": TestUUID,
+				"cleared_on":  tt.value,
+			}, TestID, TestResource.ResourceID.Value); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet database expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateResourceDataClearDelay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    int64
+		deferred clearsArg
+	}{{
+		name:     "deferred",
+		value:    1,
+		deferred: clearsArg{TestUUID},
+	}, {
+		name:     "not matched",
+		value:    0,
+		deferred: clearsArg{},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := mockAuthContext()
+
+			md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+			r := TestResource
+
+			r.ClearDelay = request.FieldInt64{
+				Set: true, Valid: true, Value: 60,
+			}
+
+			mockTransaction(mock)
+
+			mock.ExpectQuery("SELECT (.+) FROM resource (.+) " +
+				"FOR UPDATE OF resource").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectExec("INSERT INTO resource_data").
+				WithArgs(pgxmock.AnyArg(), clearsArg{}, pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), keysArg{TestUUID},
+					tt.deferred, int64(60)).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectQuery("UPDATE resource").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			mock.ExpectCommit()
+
+			if _, err := svc.UpdateResourceData(ctx, map[string]any{
+				"resource_id": TestUUID,
+				"cleared_on":  tt.value,
+			}, TestID, TestResource.ResourceID.Value); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet database expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateResourceDataTTL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		ttl  any
+		err  bool
+	}{{
+		name: "valid",
+		ttl:  float64(60),
+	}, {
+		name: "null",
+		ttl:  nil,
+	}, {
+		name: "negative",
+		ttl:  -1,
+		err:  true,
+	}, {
+		name: "not a number",
+		ttl:  "1h",
+		err:  true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := mockAuthContext()
+
+			md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+			mockTransaction(mock)
+
+			mock.ExpectQuery("SELECT (.+) FROM resource (.+) " +
+				"FOR UPDATE OF resource").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRows(mock))
+
+			if !tt.err {
+				mock.ExpectExec("SELECT set_config").
+					WithArgs(pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("SET", 1))
+
+				mock.ExpectExec("INSERT INTO resource_data").
+					WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+						pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+						dataArg{"ttl": tt.ttl},
+						pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			}
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectQuery("UPDATE resource").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRows(mock))
+
+			mock.ExpectCommit()
+
+			_, err = svc.UpdateResourceData(ctx, map[string]any{
+				"resource_id": TestUUID,
+				"ttl":         tt.ttl,
+			}, TestID, TestResource.ResourceID.Value)
+			if tt.err && !errors.Has(err, errors.ErrInvalidRequest) {
+				t.Errorf("Expected invalid request error, got: %v", err)
+			} else if !tt.err && err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet database expectations: %v", err)
+			}
+		})
+	}
+}
+
+// dataArg values match the value of a field of the resource data item with
+// the test key written by a resource data update.
+type dataArg map[string]any
+
+func (a dataArg) Match(v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	var data map[string]map[string]any
+
+	if err := json.Unmarshal([]byte(s), &data); err != nil {
+		return false
+	}
+
+	item := data[TestUUID]
+
+	for k, v := range a {
+		if item[k] != v {
+			return false
+		}
+	}
+
+	return len(item) == len(a)+2
+}
+
+func TestUpdateResourceDataDuplicates(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		policy string
+		data   dataArg
+		err    bool
+	}{{
+		name:   "last",
+		policy: resource.DuplicatePolicyLast,
+		data:   dataArg{"a": float64(2)},
+	}, {
+		name:   "first",
+		policy: resource.DuplicatePolicyFirst,
+		data:   dataArg{"a": float64(1), "b": float64(1)},
+	}, {
+		name:   "merge",
+		policy: resource.DuplicatePolicyMerge,
+		data:   dataArg{"a": float64(2), "b": float64(1)},
+	}, {
+		name:   "reject",
+		policy: resource.DuplicatePolicyReject,
+		err:    true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := mockAuthContext()
+
+			md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+			r := TestResource
+
+			r.DuplicatePolicy = request.FieldString{
+				Set: true, Valid: true, Value: tt.policy,
+			}
+
+			mockTransaction(mock)
+
+			mock.ExpectQuery("SELECT (.+) FROM resource (.+) " +
+				"FOR UPDATE OF resource").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			if !tt.err {
+				mock.ExpectExec("SELECT set_config").
+					WithArgs(pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("SET", 1))
+
+				mock.ExpectExec("INSERT INTO resource_data").
+					WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+						pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+						tt.data, pgxmock.AnyArg(), pgxmock.AnyArg()).
+					WillReturnResult(pgxmock.NewResult("INSERT", 1))
+			}
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectQuery("UPDATE resource").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			mock.ExpectCommit()
+
+			_, err = svc.UpdateResourceData(ctx, map[string]any{
+				"resources": []any{
+					map[string]any{"resource_id": TestUUID, "a": 1, "b": 1},
+					map[string]any{"resource_id": TestUUID, "a": 2},
+				},
+			}, TestID, TestResource.ResourceID.Value)
+			if tt.err && !errors.Has(err, errors.ErrInvalidRequest) {
+				t.Errorf("Expected invalid request error, got: %v", err)
+			} else if !tt.err && err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet database expectations: %v", err)
+			}
+		})
+	}
+}
+
+// keysArg values match the keys of the resource data items written by a
+// resource data update.
+type keysArg []string
+
+func (a keysArg) Match(v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+
+	var data map[string]any
+
+	if err := json.Unmarshal([]byte(s), &data); err != nil {
+		return false
+	}
+
+	for _, k := range a {
+		if _, ok := data[k]; !ok {
+			return false
+		}
+	}
+
+	return len(data) == len(a)
+}
+
+func TestUpdateResourceDataKeyStrategy(t *testing.T) {
+	t.Parallel()
+
+	items := func() []any {
+		return []any{
+			map[string]any{"host": "h1", "check": "cpu", "value": 1},
+			map[string]any{"host": "h2", "check": "mem"},
+		}
+	}
+
+	hashes := keysArg{}
+
+	for _, item := range items() {
+		buf, err := request.CanonicalJSON(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := sha256.Sum256(buf)
+
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		field    string
+		regex    string
+		keys     keysArg
+	}{{
+		name:     "field",
+		strategy: resource.KeyStrategyField,
+		field:    "host",
+		keys:     keysArg{"h1", "h2"},
+	}, {
+		name:     "composite",
+		strategy: resource.KeyStrategyComposite,
+		field:    "host, check",
+		keys:     keysArg{"h1:cpu", "h2:mem"},
+	}, {
+		name:     "composite regex",
+		strategy: resource.KeyStrategyComposite,
+		field:    "host,check",
+		regex:    "[a-z]+$",
+		keys:     keysArg{"cpu", "mem"},
+	}, {
+		name:     "template",
+		strategy: resource.KeyStrategyTemplate,
+		field:    "{{.host}}/{{.check}}",
+		keys:     keysArg{"h1/cpu", "h2/mem"},
+	}, {
+		name:     "hash",
+		strategy: resource.KeyStrategyHash,
+		keys:     hashes,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := mockAuthContext()
+
+			md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+			r := TestResource
+
+			r.KeyStrategy = request.FieldString{
+				Set: true, Valid: true, Value: tt.strategy,
+			}
+
+			r.KeyField = request.FieldString{
+				Set: true, Valid: true, Value: tt.field,
+			}
+
+			r.KeyRegex = request.FieldString{
+				Set: true, Valid: true, Value: tt.regex,
+			}
+
+			mockTransaction(mock)
+
+			mock.ExpectQuery("SELECT (.+) FROM resource (.+) " +
+				"FOR UPDATE OF resource").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectExec("INSERT INTO resource_data").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+					tt.keys, pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+			mock.ExpectExec("SELECT set_config").
+				WithArgs(pgxmock.AnyArg()).
+				WillReturnResult(pgxmock.NewResult("SET", 1))
+
+			mock.ExpectQuery("UPDATE resource").
+				WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(),
+					pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+				WillReturnRows(mockResourceRowsFor(mock, r))
+
+			mock.ExpectCommit()
+
+			if _, err := svc.UpdateResourceData(ctx, map[string]any{
+				"resources": items(),
+			}, TestID, TestResource.ResourceID.Value); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("Unmet database expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateResourcesData(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockResourceAccess(mock)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource (.+) FOR UPDATE OF resource").
+		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceRows(mock))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectExec("INSERT INTO resource_data").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectQuery("UPDATE resource").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockResourceRows(mock))
+
+	mock.ExpectCommit()
+
+	res, err := svc.UpdateResourcesData(ctx, []*resource.ResourceDataEntry{{
+		ResourceID: TestResource.ResourceID,
+		Data: map[string]any{
+			"resource_id": TestUUID,
+		},
+	}, {
+		Data: map[string]any{
+			"resource_id": TestUUID,
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Succeeded != 1 || res.Failed != 1 {
+		t.Errorf("Expected succeeded: 1, failed: 1, got: %v, %v",
+			res.Succeeded, res.Failed)
+	}
+
+	if res.Items[1].Index != 1 || res.Items[1].Error == nil {
+		t.Errorf("Expected error for index 1, got: %v", res.Items[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestUpdateResourcesDataAccess(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockResourceAccessRows(mock, true, false, false)
+
+	mockResourceAccessRows(mock, true, true, false)
+
+	res, err := svc.UpdateResourcesData(ctx, []*resource.ResourceDataEntry{{
+		ResourceID: TestResource.ResourceID,
+		Data: map[string]any{
+			"resource_id": TestUUID,
+		},
+	}, {
+		ResourceID: TestResource.ResourceID,
+		Data: map[string]any{
+			"resource_id": TestUUID,
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Succeeded != 0 || res.Failed != 2 {
+		t.Errorf("Expected succeeded: 0, failed: 2, got: %v, %v",
+			res.Succeeded, res.Failed)
+	}
+
+	for i, status := range []int{http.StatusNotFound, http.StatusForbidden} {
+		if res.Items[i].Status != status || res.Items[i].Data != nil {
+			t.Errorf("Expected status: %v, without data, got: %v",
+				status, res.Items[i])
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestUpdateResourceError(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	args := make([]any, 5)
+
+	for i := 0; i < 5; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE resource").
+		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+
+	if err := svc.UpdateResourceError(ctx, TestUUID, TestUUID,
+		errors.New(errors.ErrServer, "test error")); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestImportResources(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	svc.SetRepoClient(&mockRepoClient{})
+
+	ma := &mockAuthSvc{}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT resource_commit_hash FROM account").
+		WillReturnRows(mockAccountCommitHashRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM import_error (.+) INSERT INTO import_error").
+		WithArgs([]string{}, []string{}, []string{}, []string{}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("UPDATE account SET resource_commit_hash").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockAccountCommitHashRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("DELETE FROM resource").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockResourceIDRows(mock))
+
+	if err := svc.ImportResources(ctx, true, nil, ma); err != nil {
+		t.Fatal(err)
+	}
+
+	if ma.v.RepoStatus.Value != request.StatusActive {
+		t.Errorf("Expected repo status: %v, got: %v",
+			request.StatusActive, ma.v.RepoStatus.Value)
+	}
+
+	expData := []string{
+		"resources_last_imported",
+		"resources_deleted",
+		"resources_updated",
+		"resources_fetch",
+	}
+
+	for _, expF := range expData {
+		if _, ok := ma.v.RepoStatusData.Value[expF]; !ok {
+			t.Errorf("Expected repo status data field: %v", expF)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+type mockFilterRepoClient struct {
+	mockRepoClient
+}
+
+func (m *mockFilterRepoClient) ListAll(ctx context.Context, dirPath string,
+) ([]repo.Item, error) {
+	return []repo.Item{{
+		Path: "resources/" + TestUUID + ".yaml",
+		Type: "file",
+	}, {
+		Path: "resources/" + TestDependencyUUID + ".yaml",
+		Type: "file",
+	}}, nil
+}
+
+func TestImportResourcesFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	svc.SetRepoClient(&mockFilterRepoClient{})
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT resource_commit_hash FROM account").
+		WillReturnRows(mockAccountCommitHashRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM import_error (.+) INSERT INTO import_error").
+		WithArgs([]string{}, pgxmock.AnyArg(), []string{}, []string{}).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("DELETE FROM resource").
+		WithArgs("test", []string{TestUUID, TestDependencyUUID}).
+		WillReturnRows(mock.NewRows([]string{"resource_id"}))
+
+	if err := svc.ImportResources(ctx, true, &repo.Filter{Path: "prod/*"},
+		&mockAuthSvc{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestImportResource(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	svc.SetRepoClient(&mockRepoClient{})
+
+	mockTransaction(mock)
+
+	args := make([]any, 15)
+
+	for i := 0; i < 15; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("INSERT INTO resource").
+		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectExec("INSERT INTO resource_data").
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg(),
+			pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectCommit()
+
+	if err := svc.ImportResource(ctx, &mockAuthSvc{}, TestUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	cancel := svc.Update(ctx, &mockAuthSvc{})
+
+	time.Sleep(time.Second)
+
+	cancel()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func BenchmarkGetResource(b *testing.B) {
+	if os.Getenv("POSTGRES_HOST") == "" {
+		b.Skip("skipping database benchmarks: POSTGRES_HOST not set")
+	}
+
+	// Resources are read without caching, in each query execution mode, to
+	// compare the latency of prepared and unprepared statements.
+	for _, mode := range []string{
+		config.DBQueryExecModeCacheStatement,
+		config.DBQueryExecModeCacheDescribe,
+		config.DBQueryExecModeExec,
+		config.DBQueryExecModeSimpleProtocol,
+	} {
+		b.Run(mode, func(b *testing.B) {
+			ctx := mockAuthContext()
+
+			dc := &config.DBConfig{QueryExecMode: mode}
+
+			dc.Load()
+
+			cfg := config.NewDefault()
+
+			cfg.SetDB(dc)
+
+			sc := sqldb.NewSQLConn(cfg, nil, nil, nil)
+
+			if err := sc.Connect(ctx); err != nil {
+				b.Fatal(err)
+			}
+
+			defer sc.Close()
+
+			svc := resource.NewService(cfg, sc, nil, nil, nil, nil)
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := svc.GetResource(ctx,
+					TestResource.ResourceID.Value, nil); err != nil &&
+					!errors.Has(err, errors.ErrNotFound) {
+					b.Fatal(err)
+				}
+			}
+		})
 	}
 }
@@ -0,0 +1,299 @@
+package resource
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// PurgeResult values contain the number of rows purged from each table.
+type PurgeResult struct {
+	Resources          int64 `json:"resources"`
+	ResourceData       int64 `json:"resource_data"`
+	Changes            int64 `json:"changes"`
+	Tokens             int64 `json:"tokens"`
+	RequestKeys        int64 `json:"request_keys"`
+	Sessions           int64 `json:"sessions"`
+	IdempotentRequests int64 `json:"idempotent_requests"`
+}
+
+// Purge deletes the data of the account older than the retention period.
+// Inactive resources not updated within the period are deleted, along with
+// their data, as are resource data items, change feed entries and request
+// idempotency keys recorded before it, and tokens and sessions which expired
+// before it. Retained responses of idempotent requests are deleted once they
+// expire, or if they were recorded before the period. The number of rows
+// purged from each table is recorded in the purged_rows metric.
+func (s *Service) Purge(ctx context.Context,
+	retention time.Duration,
+) (*PurgeResult, error) {
+	if retention <= 0 {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid retention",
+			"retention", retention)
+	}
+
+	before := time.Now().Add(0 - retention).Unix()
+
+	res := &PurgeResult{}
+
+	ids, err := s.purgeResources(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		s.deleteResourceCache(ctx, id)
+	}
+
+	res.Resources = int64(len(ids))
+
+	if res.ResourceData, err = s.purgeRows(ctx, `DELETE FROM resource_data
+		WHERE resource_data.ts < TO_TIMESTAMP($1)`,
+		before); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge resource data rows",
+			"before", before)
+	}
+
+	if res.Changes, err = s.purgeRows(ctx, `DELETE FROM change
+		WHERE change.ts < TO_TIMESTAMP($1)`,
+		before); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge change rows",
+			"before", before)
+	}
+
+	if res.Tokens, err = s.purgeRows(ctx, `DELETE FROM token
+		WHERE token.expires_at < TO_TIMESTAMP($1)`,
+		before); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge token rows",
+			"before", before)
+	}
+
+	if res.RequestKeys, err = s.purgeRows(ctx, `DELETE FROM request_key
+		WHERE request_key.created_at < TO_TIMESTAMP($1)`,
+		before); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge request key rows",
+			"before", before)
+	}
+
+	if res.Sessions, err = s.purgeRows(ctx, `DELETE FROM session
+		WHERE session.expires_at < TO_TIMESTAMP($1)`,
+		before); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge session rows",
+			"before", before)
+	}
+
+	if res.IdempotentRequests, err = s.purgeRows(ctx, `DELETE FROM idempotent_request
+		WHERE idempotent_request.expires_at < CURRENT_TIMESTAMP
+			OR idempotent_request.created_at < TO_TIMESTAMP($1)`,
+		before); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge idempotent request rows",
+			"before", before)
+	}
+
+	if s.metric != nil {
+		for table, n := range map[string]int64{
+			"resource":           res.Resources,
+			"resource_data":      res.ResourceData,
+			"change":             res.Changes,
+			"token":              res.Tokens,
+			"request_key":        res.RequestKeys,
+			"session":            res.Sessions,
+			"idempotent_request": res.IdempotentRequests,
+		} {
+			s.metric.Add(ctx, "purged_rows", n, "table:"+table)
+		}
+	}
+
+	return res, nil
+}
+
+// purgeResources deletes the inactive resources not updated since a time,
+// returning their IDs.
+func (s *Service) purgeResources(ctx context.Context,
+	before int64,
+) ([]string, error) {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryDelete,
+		Base: `DELETE FROM resource
+			WHERE resource.status = '` + request.StatusInactive + `'
+				AND resource.updated_at < TO_TIMESTAMP($1)
+			RETURNING resource_id`,
+		Fields: resourceFields,
+		Params: []any{before},
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge resource rows",
+			"before", before)
+	}
+
+	defer rows.Close()
+
+	res := []string{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		id := ""
+
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to purge resource row")
+		}
+
+		res = append(res, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to purge resource rows")
+	}
+
+	return res, nil
+}
+
+// purgeRows executes a delete statement for the rows recorded before a time,
+// returning the number of rows deleted.
+func (s *Service) purgeRows(ctx context.Context,
+	base string,
+	before int64,
+) (int64, error) {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QueryDelete,
+		Base:   base,
+		Params: []any{before},
+	})
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected(), nil
+}
+
+// purgeAccounts periodically purges the data older than the retention period
+// of each account.
+func (s *Service) purgeAccounts(ctx context.Context) {
+	tick := time.NewTimer(s.cfg.PurgeInterval())
+
+	for {
+		select {
+		case <-ctx.Done():
+			tick.Stop()
+
+			return
+		case <-tick.C:
+			retentions, err := s.getAccountRetentions(ctx)
+			if err != nil {
+				s.log.Log(ctx, logger.LvlError,
+					"unable to get accounts to purge",
+					"error", err)
+			}
+
+			for aID, retention := range retentions {
+				if retention <= 0 {
+					continue
+				}
+
+				actx := request.Elevate(ctx, aID)
+
+				res, err := s.Purge(actx, retention)
+				if err != nil {
+					s.log.Log(actx, logger.LvlError,
+						"unable to purge account data",
+						"error", err,
+						"retention", retention)
+
+					continue
+				}
+
+				s.log.Log(actx, logger.LvlDebug,
+					"account data purged",
+					"retention", retention,
+					"resources", res.Resources,
+					"resource_data", res.ResourceData,
+					"changes", res.Changes,
+					"tokens", res.Tokens,
+					"request_keys", res.RequestKeys,
+					"sessions", res.Sessions,
+					"idempotent_requests",
+					res.IdempotentRequests)
+			}
+		}
+
+		tick = time.NewTimer(s.cfg.PurgeInterval())
+	}
+}
+
+// getAccountRetentions retrieves the data retention period of all active
+// accounts, keyed by account ID. Accounts without a retention period in their
+// account data use the configured resource data retention period.
+func (s *Service) getAccountRetentions(ctx context.Context,
+) (map[string]time.Duration, error) {
+	ctx = request.WithAccountID(ctx, request.SystemAccount)
+
+	// The accounts are selected without a limit, so that every account is
+	// purged, however many there are.
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QueryExec,
+		Base: `SELECT account.account_id, account.data
+			FROM account
+			WHERE status = '` + request.StatusActive + `'`,
+	})
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select account rows")
+	}
+
+	defer rows.Close()
+
+	res := map[string]time.Duration{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		a := &auth.Account{}
+
+		if err = rows.Scan(&a.AccountID, &a.Data); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select account row")
+		}
+
+		res[a.AccountID.Value] = a.Retention(s.cfg.ResourceDataRetention())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select account rows")
+	}
+
+	return res, nil
+}
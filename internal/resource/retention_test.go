@@ -0,0 +1,116 @@
+package resource_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestPurge(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("DELETE FROM resource (.+) RETURNING resource_id").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnRows(mockResourceIDRows(mock))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM resource_data").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 3))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM change").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 2))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM token").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 4))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM request_key").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 5))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM session").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 6))
+
+	mockTransaction(mock)
+
+	mock.ExpectExec("DELETE FROM idempotent_request").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 7))
+
+	res, err := svc.Purge(ctx, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Resources != 1 {
+		t.Errorf("Expected resources: 1, got: %v", res.Resources)
+	}
+
+	if res.ResourceData != 3 {
+		t.Errorf("Expected resource data: 3, got: %v", res.ResourceData)
+	}
+
+	if res.Changes != 2 {
+		t.Errorf("Expected changes: 2, got: %v", res.Changes)
+	}
+
+	if res.Tokens != 4 {
+		t.Errorf("Expected tokens: 4, got: %v", res.Tokens)
+	}
+
+	if res.RequestKeys != 5 {
+		t.Errorf("Expected request keys: 5, got: %v", res.RequestKeys)
+	}
+
+	if res.Sessions != 6 {
+		t.Errorf("Expected sessions: 6, got: %v", res.Sessions)
+	}
+
+	if res.IdempotentRequests != 7 {
+		t.Errorf("Expected idempotent requests: 7, got: %v",
+			res.IdempotentRequests)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+
+	if _, err := svc.Purge(ctx, 0); !errors.Has(err,
+		errors.ErrInvalidRequest) {
+		t.Errorf("Expected error: %v, got: %v", errors.ErrInvalidRequest, err)
+	}
+}
@@ -0,0 +1,313 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+// Revision values contain an immutable copy of the definition of a resource,
+// recorded by the database whenever a resource is created, or its definition
+// is changed by an update or import. The definition does not include the
+// resource status or data.
+type Revision struct {
+	ResourceID request.FieldString `json:"resource_id" yaml:"resource_id"`
+	Revision   request.FieldInt64  `json:"revision"    yaml:"revision"`
+	Definition request.FieldJSON   `json:"definition"  yaml:"definition"`
+	Version    request.FieldString `json:"version"     yaml:"version"`
+	Source     request.FieldString `json:"source"      yaml:"source"`
+	CommitHash request.FieldString `json:"commit_hash" yaml:"commit_hash"`
+	CreatedAt  request.FieldTime   `json:"created_at"  yaml:"created_at"`
+	CreatedBy  request.FieldString `json:"created_by"  yaml:"created_by"`
+}
+
+// definitionFields are the fields of a resource contained in its definition.
+// They must match the fields recorded by the resource_definition database
+// function.
+var definitionFields = []string{
+	"name",
+	"description",
+	"key_field",
+	"key_regex",
+	"clear_condition",
+	"clear_after",
+	"clear_delay",
+	"duplicate_policy",
+	"key_strategy",
+	"depends_on",
+}
+
+// nullableFields are the fields of a resource definition which may be null.
+var nullableFields = map[string]bool{
+	"description":     true,
+	"key_regex":       true,
+	"clear_condition": true,
+}
+
+// Definition returns the definition of the resource, as it is recorded in
+// its revisions.
+func (r *Resource) Definition() (map[string]any, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to encode resource",
+			"resource", r)
+	}
+
+	m := map[string]any{}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to decode resource",
+			"resource", r)
+	}
+
+	res := make(map[string]any, len(definitionFields))
+
+	for _, k := range definitionFields {
+		res[k] = m[k]
+	}
+
+	return res, nil
+}
+
+// ScanDest returns the destination fields for a SQL row scan.
+func (r *Revision) ScanDest() []any {
+	return sqldb.ScanFields("resource_revision", revisionFields, nil,
+		map[string]any{
+			"resource_id": &r.ResourceID,
+			"revision":    &r.Revision,
+			"definition":  &r.Definition,
+			"version":     &r.Version,
+			"source":      &r.Source,
+			"commit_hash": &r.CommitHash,
+			"created_at":  &r.CreatedAt,
+			"created_by":  &r.CreatedBy,
+		})
+}
+
+// Resource returns a resource update which restores the definition of the
+// revision. Fields which are null in the definition, but which cannot be null,
+// are left unchanged.
+func (r *Revision) Resource() (*Resource, error) {
+	res := &Resource{}
+
+	def := make(map[string]any, len(r.Definition.Value))
+
+	for k, v := range r.Definition.Value {
+		if v != nil || nullableFields[k] {
+			def[k] = v
+		}
+	}
+
+	b, err := json.Marshal(def)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to encode resource revision definition",
+			"revision", r)
+	}
+
+	if err := json.Unmarshal(b, res); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to decode resource revision definition",
+			"revision", r)
+	}
+
+	res.ResourceID = r.ResourceID
+
+	return res, nil
+}
+
+// revisionFields contain the fields for resource revisions.
+var revisionFields = []*sqldb.Field{{
+	Name:  "resource_id",
+	Type:  sqldb.FieldString,
+	Table: "resource_revision",
+}, {
+	Name:    "revision",
+	Type:    sqldb.FieldInt,
+	Table:   "resource_revision",
+	Primary: true,
+}, {
+	Name:  "definition",
+	Type:  sqldb.FieldJSON,
+	Table: "resource_revision",
+}, {
+	Name:  "version",
+	Type:  sqldb.FieldString,
+	Table: "resource_revision",
+}, {
+	Name:  "source",
+	Type:  sqldb.FieldString,
+	Table: "resource_revision",
+}, {
+	Name:  "commit_hash",
+	Type:  sqldb.FieldString,
+	Table: "resource_revision",
+}, {
+	Name:  "created_at",
+	Type:  sqldb.FieldTime,
+	Table: "resource_revision",
+}, {
+	Name:  "created_by",
+	Type:  sqldb.FieldString,
+	Table: "created_by_user",
+	From:  `"user"`,
+	Key:   "user_key",
+	Join:  "created_by",
+	Expr:  "created_by_user.user_id",
+}}
+
+// GetResourceRevisions retrieves the revisions of the definition of a resource
+// by ID, most recent first.
+func (s *Service) GetResourceRevisions(ctx context.Context,
+	id string,
+) ([]*Revision, error) {
+	if _, err := s.checkAccess(ctx, id, PermissionRead); err != nil {
+		return nil, err
+	}
+
+	base := sqldb.SelectFields("resource_revision", revisionFields, nil,
+		nil) + `WHERE resource_revision.resource_id = $1
+		ORDER BY resource_revision.revision DESC`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: revisionFields,
+		Params: []any{id},
+	})
+
+	q.Limit = q.Config.DBMaxSize()
+
+	rows, err := q.Query(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "", "id", id)
+	}
+
+	defer rows.Close()
+
+	res := []*Revision{}
+
+	for rows.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Context(ctx)
+		default:
+		}
+
+		r := &Revision{}
+
+		if err := rows.Scan(r.ScanDest()...); err != nil {
+			return nil, errors.Wrap(err, errors.ErrDatabase,
+				"unable to select resource revision row",
+				"id", id)
+		}
+
+		res = append(res, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource revision rows",
+			"id", id)
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New(errors.ErrNotFound,
+			"resource not found",
+			"id", id)
+	}
+
+	return res, nil
+}
+
+// getResourceRevision retrieves a single revision of a resource.
+func (s *Service) getResourceRevision(ctx context.Context,
+	id string,
+	revision int64,
+) (*Revision, error) {
+	base := sqldb.SelectFields("resource_revision", revisionFields, nil,
+		nil) + `WHERE resource_revision.resource_id = $1
+		AND resource_revision.revision = $2`
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     s.db,
+		Type:   sqldb.QuerySelect,
+		Base:   base,
+		Fields: revisionFields,
+		Params: []any{id, revision},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase, "",
+			"id", id,
+			"revision", revision)
+	}
+
+	res := &Revision{}
+
+	if err := row.Scan(res.ScanDest()...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New(errors.ErrNotFound,
+				"resource revision not found",
+				"id", id,
+				"revision", revision)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select resource revision row",
+			"id", id,
+			"revision", revision)
+	}
+
+	return res, nil
+}
+
+// RollbackResource restores the definition of a resource by ID to that of one
+// of its revisions. The rollback is recorded as a new revision. The version,
+// source and commit hash of the resource are not changed, so a resource
+// imported from a repository is only imported again once its repository file
+// changes. The current user must have write permission on the resource.
+func (s *Service) RollbackResource(ctx context.Context,
+	id string,
+	revision int64,
+) (*Resource, error) {
+	if revision < 1 {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid revision",
+			"id", id,
+			"revision", revision)
+	}
+
+	if _, err := s.checkAccess(ctx, id, PermissionWrite); err != nil {
+		return nil, err
+	}
+
+	rev, err := s.getResourceRevision(ctx, id, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := rev.Resource()
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.updateResource(ctx, nil, v)
+	if err != nil {
+		return nil, err
+	}
+
+	s.deleteResourceCache(ctx, r.ResourceID.Value)
+
+	return r, nil
+}
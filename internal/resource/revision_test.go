@@ -0,0 +1,151 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/cache"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func mockRevisionRows(mock pgxmock.PgxCommonIface) *pgxmock.Rows {
+	return mock.NewRows([]string{
+		"resource_id",
+		"revision",
+		"definition",
+		"version",
+		"source",
+		"commit_hash",
+		"created_at",
+		"created_by",
+	}).AddRow(
+		TestResource.ResourceID.Value,
+		int64(1),
+		map[string]any{
+			"name":        "oldName",
+			"description": nil,
+			"key_field":   TestResource.KeyField.Value,
+			"clear_after": float64(3600),
+			"depends_on":  []any{},
+		},
+		TestResource.Version.Value,
+		"git",
+		"test",
+		nil,
+		nil,
+	)
+}
+
+func TestResourceDefinition(t *testing.T) {
+	t.Parallel()
+
+	def, err := TestResource.Definition()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if def["name"] != TestResource.Name.Value {
+		t.Errorf("Expected name: %v, got: %v", TestResource.Name.Value,
+			def["name"])
+	}
+
+	for _, k := range []string{"resource_id", "status", "data", "version"} {
+		if _, ok := def[k]; ok {
+			t.Errorf("Expected definition without: %v, got: %v", k, def)
+		}
+	}
+}
+
+func TestListResourceRevisions(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, nil, nil, nil, nil)
+
+	mockResourceAccess(mock)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource_revision").
+		WithArgs(TestResource.ResourceID.Value).
+		WillReturnRows(mockRevisionRows(mock))
+
+	res, err := svc.GetResourceRevisions(ctx, TestResource.ResourceID.Value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 || res[0].Revision.Value != 1 ||
+		res[0].Definition.Value["name"] != "oldName" {
+		t.Errorf("Expected revision: 1, got: %+v", res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestRollbackResource(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	mc := &cache.MockCache{}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(nil, md, mc, nil, nil, nil)
+
+	mockResourceAccess(mock)
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT (.+) FROM resource_revision").
+		WithArgs(TestResource.ResourceID.Value, int64(1)).
+		WillReturnRows(mockRevisionRows(mock))
+
+	mockTransaction(mock)
+
+	args := make([]any, 8)
+
+	for i := 0; i < 8; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("UPDATE resource").
+		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+
+	res, err := svc.RollbackResource(ctx, TestResource.ResourceID.Value, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ResourceID.Value != TestResource.ResourceID.Value {
+		t.Errorf("Expected id: %v, got: %v",
+			TestResource.ResourceID.Value, res.ResourceID.Value)
+	}
+
+	if !mc.WasDeleted() {
+		t.Error("expected cache delete")
+	}
+
+	if _, err := svc.RollbackResource(ctx, TestResource.ResourceID.Value,
+		0); !errors.Has(err, errors.ErrInvalidRequest) {
+		t.Errorf("Expected invalid request error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
@@ -80,6 +80,10 @@ func (s *Service) GetTags(ctx context.Context,
 func (s *Service) GetResourceTags(ctx context.Context,
 	resourceID string,
 ) ([]string, error) {
+	if _, err := s.checkAccess(ctx, resourceID, PermissionRead); err != nil {
+		return nil, err
+	}
+
 	base := `SELECT tag_obj.tag_key || ':' || tag_obj.tag_val AS tag
 		FROM tag_obj
 		WHERE tag_obj.status = '` + request.StatusActive + `'
@@ -138,6 +142,10 @@ func (s *Service) AddResourceTags(ctx context.Context,
 		return nil, err
 	}
 
+	if _, err := s.checkAccess(ctx, resourceID, PermissionWrite); err != nil {
+		return nil, err
+	}
+
 	res := []string{}
 
 	tagsMap := map[string]struct{}{}
@@ -243,6 +251,10 @@ func (s *Service) DeleteResourceTags(ctx context.Context,
 	resourceID string,
 	tags []string,
 ) error {
+	if _, err := s.checkAccess(ctx, resourceID, PermissionWrite); err != nil {
+		return err
+	}
+
 	res := []string{}
 
 	for _, tag := range tags {
@@ -365,8 +377,8 @@ func (s *Service) DeleteResourceTags(ctx context.Context,
 // TagsMultiAssignment values represent assignment, or removal, of tags to
 // multiple resources using an resource selector.
 type TagsMultiAssignment struct {
-	Tags             request.FieldStringArray `json:"tags"`
-	ResourceSelector request.FieldString      `json:"resource_selector"`
+	Tags             request.FieldStringArray `json:"tags"              yaml:"tags"`
+	ResourceSelector request.FieldString      `json:"resource_selector" yaml:"resource_selector"`
 }
 
 // Validate checks that the value contains valid data.
@@ -64,12 +64,14 @@ func TestGetResourceTags(t *testing.T) {
 
 	svc := resource.NewService(nil, md, nil, nil, nil, nil)
 
+	mockResourceAccess(mock)
+
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM tag").
 		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockTagRows(mock))
 
-	res, err := svc.GetResourceTags(ctx, TestID)
+	res, err := svc.GetResourceTags(ctx, TestUUID)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -99,6 +101,8 @@ func TestAddResourceTags(t *testing.T) {
 
 	svc := resource.NewService(nil, md, nil, nil, nil, nil)
 
+	mockResourceAccess(mock)
+
 	mockTransaction(mock)
 
 	args := make([]any, 4)
@@ -110,7 +114,7 @@ func TestAddResourceTags(t *testing.T) {
 	mock.ExpectQuery("INSERT INTO tag_obj").
 		WithArgs(args...).WillReturnRows(mockTagRows(mock))
 
-	res, err := svc.AddResourceTags(ctx, TestID, []string{"test:test"})
+	res, err := svc.AddResourceTags(ctx, TestUUID, []string{"test:test"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -140,6 +144,8 @@ func TestDeleteResourceTags(t *testing.T) {
 
 	svc := resource.NewService(nil, md, nil, nil, nil, nil)
 
+	mockResourceAccess(mock)
+
 	mockTransaction(mock)
 
 	args := make([]any, 3)
@@ -158,7 +164,7 @@ func TestDeleteResourceTags(t *testing.T) {
 	mock.ExpectQuery("DELETE FROM tag").
 		WithArgs(args...).WillReturnRows(mockTagRows(mock))
 
-	err = svc.DeleteResourceTags(ctx, TestID, []string{"test:test"})
+	err = svc.DeleteResourceTags(ctx, TestUUID, []string{"test:test"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -196,13 +202,16 @@ func TestCreateTagsMultiAssignment(t *testing.T) {
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
-		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceKeyRows(mock))
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockResourceKeyRows(mock))
 
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
 		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceRows(mock))
 
+	mockResourceAccess(mock)
+
 	mockTransaction(mock)
 
 	args := make([]any, 4)
@@ -252,13 +261,16 @@ func TestDeleteTagsMultiAssignment(t *testing.T) {
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
-		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceKeyRows(mock))
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(mockResourceKeyRows(mock))
 
 	mockTransaction(mock)
 
 	mock.ExpectQuery("SELECT (.+) FROM resource").
 		WithArgs(pgxmock.AnyArg()).WillReturnRows(mockResourceRows(mock))
 
+	mockResourceAccess(mock)
+
 	mockTransaction(mock)
 
 	args := make([]any, 3)
@@ -0,0 +1,203 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// SourceTemplate is the source of resources copied from the template
+// repository. Unlike resources imported from the account repository, they are
+// owned by the account once copied, and are not removed by later imports.
+const SourceTemplate = "template"
+
+// BootstrapResources copies the resources of the configured template
+// repository into the account of the context. It is used to populate new
+// accounts, so nothing is copied into an account which already has resources.
+// The number of resources copied is returned.
+func (s *Service) BootstrapResources(ctx context.Context) (int, error) {
+	repoURL := s.cfg.AccountTemplateRepo()
+	if repoURL == "" {
+		return 0, nil
+	}
+
+	ctx = request.Elevate(ctx, "")
+
+	ctx, cancel := request.ContextReplaceTimeout(ctx, s.cfg.ServerTimeout())
+
+	defer cancel()
+
+	if ok, err := s.hasResources(ctx); err != nil || ok {
+		return 0, err
+	}
+
+	cli, err := s.getRepoClient(repoURL)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrImport,
+			"unable to create template repository client")
+	}
+
+	hash, err := cli.Commit(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, errors.ErrImport,
+			"unable to get template repository commit hash")
+	}
+
+	res, err := cli.ListAll(ctx, "resources/")
+	if err != nil {
+		if errors.Has(err, errors.ErrNotFound) {
+			return 0, nil
+		}
+
+		return 0, errors.Wrap(err, errors.ErrImport,
+			"unable to list template repository path",
+			"path", "resources/")
+	}
+
+	created := 0
+
+	errs := errors.New(errors.ErrImport,
+		"unable to copy template resources")
+
+	for _, i := range res {
+		if i.Type != "file" && i.Type != "commit_file" {
+			continue
+		}
+
+		resourceID := strings.TrimPrefix(strings.TrimPrefix(i.Path, "/"),
+			"resources/")
+
+		ext := filepath.Ext(resourceID)
+
+		resourceID = strings.TrimSuffix(resourceID, ext)
+
+		vb, err := cli.Get(ctx, "resources/"+resourceID+ext)
+		if err != nil {
+			errs.Errors = append(errs.Errors, errors.Wrap(err,
+				errors.ErrImport,
+				"unable to get template resource file",
+				"resource_id", resourceID))
+
+			continue
+		}
+
+		m := map[string]any{}
+
+		if err := yaml.Unmarshal(vb, &m); err != nil {
+			errs.Errors = append(errs.Errors, errors.Wrap(err,
+				errors.ErrImport,
+				"unable to parse template resource file",
+				"resource_id", resourceID))
+
+			continue
+		}
+
+		vmb, err := json.Marshal(&m)
+		if err != nil {
+			errs.Errors = append(errs.Errors, errors.Wrap(err,
+				errors.ErrImport,
+				"unable to format template resource file map",
+				"resource_id", resourceID))
+
+			continue
+		}
+
+		a := &Resource{}
+
+		if err := json.Unmarshal(vmb, a); err != nil {
+			errs.Errors = append(errs.Errors, errors.Wrap(err,
+				errors.ErrImport,
+				"invalid template resource contents",
+				"resource_id", resourceID,
+				"contents", string(vmb)))
+
+			continue
+		}
+
+		a.ResourceID = request.FieldString{
+			Set: true, Valid: true, Value: resourceID,
+		}
+
+		a.Version = request.FieldString{
+			Set: true, Valid: true, Value: hash,
+		}
+
+		a.Status = request.FieldString{
+			Set: true, Valid: true, Value: request.StatusActive,
+		}
+
+		a.Source = request.FieldString{
+			Set: true, Valid: true, Value: SourceTemplate,
+		}
+
+		if _, err := s.CreateResource(ctx, a); err != nil {
+			errs.Errors = append(errs.Errors, errors.Wrap(err,
+				errors.ErrDatabase,
+				"unable to create template resource",
+				"resource", a))
+
+			continue
+		}
+
+		created++
+	}
+
+	if len(errs.Errors) > 0 {
+		errs = errs.Group(importErrorSamples)
+
+		s.log.Log(ctx, logger.LvlWarn,
+			"unable to copy all template resources",
+			"created", created,
+			"errors", errs.Groups)
+
+		return created, errs
+	}
+
+	s.log.Log(ctx, logger.LvlInfo,
+		"template resources copied",
+		"created", created)
+
+	return created, nil
+}
+
+// hasResources checks whether the account of the context has any resources.
+func (s *Service) hasResources(ctx context.Context) (bool, error) {
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   s.db,
+		Type: sqldb.QuerySelect,
+		Base: `SELECT resource.resource_id FROM resource`,
+		Fields: []*sqldb.Field{{
+			Name:  "resource_id",
+			Type:  sqldb.FieldString,
+			Table: "resource",
+		}},
+	})
+
+	q.Limit = 1
+
+	row, err := q.QueryRow(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, errors.ErrDatabase, "")
+	}
+
+	var id string
+
+	if err := row.Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+
+		return false, errors.Wrap(err, errors.ErrDatabase,
+			"unable to select account resources")
+	}
+
+	return true, nil
+}
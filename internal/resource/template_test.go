@@ -0,0 +1,99 @@
+package resource_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/repo"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+type mockTemplateClient struct {
+	mockRepoClient
+}
+
+func (m *mockTemplateClient) ListAll(ctx context.Context, dirPath string,
+) ([]repo.Item, error) {
+	return []repo.Item{{
+		Path:   "resources/" + TestUUID + ".yaml",
+		Type:   "file",
+		Commit: "test",
+	}}, nil
+}
+
+func (m *mockTemplateClient) Get(ctx context.Context, filePath string,
+) ([]byte, error) {
+	return []byte("name: testName\n" +
+		"description: testDescription\n" +
+		"key_field: id\n"), nil
+}
+
+func TestBootstrapResources(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAdminAuthContext()
+
+	cfg := config.NewDefault()
+
+	cfg.SetService(&config.ServiceConfig{
+		AccountTemplateRepo: repo.StarterURL,
+	})
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := resource.NewService(cfg, md, nil, nil, nil, nil)
+
+	svc.SetRepoClient(&mockTemplateClient{})
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT resource.resource_id FROM resource").
+		WillReturnRows(mock.NewRows([]string{"resource_id"}))
+
+	mockTransaction(mock)
+
+	args := make([]any, 9)
+
+	for i := 0; i < 9; i++ {
+		args[i] = pgxmock.AnyArg()
+	}
+
+	mock.ExpectQuery("INSERT INTO resource").
+		WithArgs(args...).WillReturnRows(mockResourceRows(mock))
+
+	mock.ExpectCommit()
+
+	n, err := svc.BootstrapResources(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Errorf("Expected resources created: 1, got: %v", n)
+	}
+
+	mockTransaction(mock)
+
+	mock.ExpectQuery("SELECT resource.resource_id FROM resource").
+		WillReturnRows(mock.NewRows([]string{"resource_id"}).
+			AddRow(TestUUID))
+
+	if n, err = svc.BootstrapResources(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Errorf("Expected no resources created for populated account, "+
+			"got: %v", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
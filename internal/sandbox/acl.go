@@ -0,0 +1,134 @@
+package sandbox
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+)
+
+// findACL returns the index of a resource ACL entry, or -1 if it is not found.
+func (s *ResourceService) findACL(v *resource.ACL) int {
+	return slices.IndexFunc(s.acls, func(a *resource.ACL) bool {
+		return a.ResourceID.Value == v.ResourceID.Value &&
+			a.PrincipalType.Value == v.PrincipalType.Value &&
+			a.PrincipalID.Value == v.PrincipalID.Value
+	})
+}
+
+// GetResourceACL retrieves the ACL entries of a resource by ID. Resource ACLs
+// are not enforced in the sandbox, since the sandbox user holds all scopes.
+func (s *ResourceService) GetResourceACL(ctx context.Context,
+	id string,
+) ([]*resource.ACL, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if _, err := s.get(id); err != nil {
+		return nil, err
+	}
+
+	res := []*resource.ACL{}
+
+	for _, a := range s.acls {
+		if a.ResourceID.Value == id {
+			res = append(res, clone(a))
+		}
+	}
+
+	slices.SortStableFunc(res, func(a, b *resource.ACL) int {
+		return cmp.Or(
+			strings.Compare(a.PrincipalType.Value, b.PrincipalType.Value),
+			strings.Compare(a.PrincipalID.Value, b.PrincipalID.Value))
+	})
+
+	return res, nil
+}
+
+// SetResourceACL grants a permission on a resource to a principal, replacing
+// any permission previously granted to it.
+func (s *ResourceService) SetResourceACL(ctx context.Context,
+	v *resource.ACL,
+) (*resource.ACL, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing acl",
+			"acl", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, err := s.get(v.ResourceID.Value); err != nil {
+		return nil, err
+	}
+
+	now := request.FieldTime{Set: true, Valid: true, Value: time.Now().Unix()}
+	by := request.FieldString{Set: true, Valid: true, Value: userID}
+
+	if i := s.findACL(v); i >= 0 {
+		a := s.acls[i]
+
+		a.Permission = v.Permission
+		a.UpdatedAt = now
+		a.UpdatedBy = by
+
+		return clone(a), nil
+	}
+
+	a := &resource.ACL{
+		ResourceID:    v.ResourceID,
+		PrincipalType: v.PrincipalType,
+		PrincipalID:   v.PrincipalID,
+		Permission:    v.Permission,
+		CreatedAt:     now,
+		CreatedBy:     by,
+		UpdatedAt:     now,
+		UpdatedBy:     by,
+	}
+
+	s.acls = append(s.acls, a)
+
+	return clone(a), nil
+}
+
+// DeleteResourceACL removes the permission granted on a resource to a
+// principal.
+func (s *ResourceService) DeleteResourceACL(ctx context.Context,
+	v *resource.ACL,
+) error {
+	if v == nil {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing acl",
+			"acl", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	i := s.findACL(v)
+	if i < 0 {
+		return errors.New(errors.ErrNotFound,
+			"resource acl not found",
+			"acl", v)
+	}
+
+	s.acls = slices.Delete(s.acls, i, i+1)
+
+	return nil
+}
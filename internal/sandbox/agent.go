@@ -0,0 +1,426 @@
+package sandbox
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// findAgent returns the index of an agent by ID, or -1 if it is not found.
+func (s *ResourceService) findAgent(id string) int {
+	return slices.IndexFunc(s.agents, func(a *resource.Agent) bool {
+		return a.AgentID.Value == id
+	})
+}
+
+// getAgent retrieves an agent by ID.
+func (s *ResourceService) getAgent(id string) (*resource.Agent, error) {
+	i := s.findAgent(id)
+	if i < 0 {
+		return nil, errors.New(errors.ErrNotFound,
+			"agent not found",
+			"id", id)
+	}
+
+	return s.agents[i], nil
+}
+
+// outputAgent returns a copy of an agent. Active agents which have missed their
+// heartbeats are reported as disconnected.
+func (s *ResourceService) outputAgent(a *resource.Agent,
+	options sqldb.FieldOptions,
+) *resource.Agent {
+	res := clone(a)
+
+	lastSeen := time.Unix(res.LastSeenAt.Value, 0)
+
+	if res.Status.Value == request.StatusActive &&
+		time.Since(lastSeen) > s.cfg.AgentStaleAfter() {
+		res.Status.Value = request.StatusDisconnected
+	}
+
+	if !options.Contains(sqldb.OptUserDetails) {
+		res.CreatedAt = request.FieldTime{}
+		res.CreatedBy = request.FieldString{}
+		res.UpdatedAt = request.FieldTime{}
+		res.UpdatedBy = request.FieldString{}
+	}
+
+	return res
+}
+
+// GetAgents retrieves agents, sorted by name. Search and summary queries are
+// not evaluated for agents in the sandbox. As with the database, one more
+// agent than the query size is returned, when available.
+func (s *ResourceService) GetAgents(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.Agent, []*sqldb.SummaryData, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	list := slices.Clone(s.agents)
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name.Value < list[j].Name.Value
+	})
+
+	size := query.Size
+	if size == 0 {
+		size = s.cfg.DBDefaultSize()
+	}
+
+	if query.Skip >= int64(len(list)) {
+		list = nil
+	} else {
+		list = list[query.Skip:]
+	}
+
+	if int64(len(list)) > size+1 {
+		list = list[:size+1]
+	}
+
+	res := make([]*resource.Agent, 0, len(list))
+
+	for _, a := range list {
+		res = append(res, s.outputAgent(a, options))
+	}
+
+	return res, nil, nil
+}
+
+// GetAgent retrieves a single agent by ID.
+func (s *ResourceService) GetAgent(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*resource.Agent, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	a, err := s.getAgent(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.outputAgent(a, options), nil
+}
+
+// RegisterAgent registers an agent, or updates the registration of an existing
+// agent with the same ID.
+func (s *ResourceService) RegisterAgent(ctx context.Context,
+	v *resource.Agent,
+) (*resource.Agent, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing agent",
+			"agent", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	a := clone(v)
+
+	now := time.Now().Unix()
+
+	if i := s.findAgent(a.AgentID.Value); i >= 0 {
+		if s.agents[i].Status.Value == request.StatusInactive {
+			return nil, errors.New(errors.ErrForbidden,
+				"agent is disabled",
+				"agent", v)
+		}
+
+		a.CreatedAt = s.agents[i].CreatedAt
+		a.CreatedBy = s.agents[i].CreatedBy
+
+		s.agents = slices.Delete(s.agents, i, i+1)
+	} else {
+		a.CreatedAt = request.FieldTime{Set: true, Valid: true, Value: now}
+		a.CreatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+	}
+
+	if !a.Resources.Set {
+		a.Resources = request.FieldStringArray{
+			Set: true, Valid: true, Value: []string{},
+		}
+	}
+
+	a.Status = request.FieldString{
+		Set: true, Valid: true, Value: request.StatusActive,
+	}
+	a.LastSeenAt = request.FieldTime{Set: true, Valid: true, Value: now}
+	a.UpdatedAt = request.FieldTime{Set: true, Valid: true, Value: now}
+	a.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	s.agents = append(s.agents, a)
+
+	return s.outputAgent(a, nil), nil
+}
+
+// HeartbeatAgent records a heartbeat from an agent.
+func (s *ResourceService) HeartbeatAgent(ctx context.Context,
+	v *resource.Agent,
+) (*resource.Agent, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing agent",
+			"agent", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	v = clone(v)
+
+	s.Lock()
+	defer s.Unlock()
+
+	a, err := s.getAgent(v.AgentID.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Status.Value == request.StatusInactive {
+		return nil, errors.New(errors.ErrForbidden,
+			"agent is disabled",
+			"agent", v)
+	}
+
+	if v.Version.Set {
+		a.Version = v.Version
+	}
+
+	if v.StatusData.Set {
+		a.StatusData = v.StatusData
+	}
+
+	if v.Resources.Set {
+		a.Resources = v.Resources
+	}
+
+	a.Status = request.FieldString{
+		Set: true, Valid: true, Value: request.StatusActive,
+	}
+	a.LastSeenAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+
+	return s.outputAgent(a, nil), nil
+}
+
+// DisableAgent disables an agent.
+func (s *ResourceService) DisableAgent(ctx context.Context,
+	id string,
+) (*resource.Agent, error) {
+	return s.setAgentStatus(ctx, id, request.StatusInactive)
+}
+
+// EnableAgent enables a disabled agent.
+func (s *ResourceService) EnableAgent(ctx context.Context,
+	id string,
+) (*resource.Agent, error) {
+	return s.setAgentStatus(ctx, id, request.StatusDisconnected)
+}
+
+// setAgentStatus updates the status of an agent.
+func (s *ResourceService) setAgentStatus(ctx context.Context,
+	id, status string,
+) (*resource.Agent, error) {
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	a, err := s.getAgent(id)
+	if err != nil {
+		return nil, err
+	}
+
+	a.Status = request.FieldString{Set: true, Valid: true, Value: status}
+	a.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	a.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	return s.outputAgent(a, nil), nil
+}
+
+// DeleteAgent deletes an agent by ID.
+func (s *ResourceService) DeleteAgent(ctx context.Context,
+	id string,
+) error {
+	s.Lock()
+	defer s.Unlock()
+
+	i := s.findAgent(id)
+	if i < 0 {
+		return errors.New(errors.ErrNotFound,
+			"agent not found",
+			"id", id)
+	}
+
+	s.agents = slices.Delete(s.agents, i, i+1)
+
+	delete(s.pins, id)
+
+	return nil
+}
+
+// currentAgentConfig builds the current configuration for the resources
+// reported by an agent.
+func (s *ResourceService) currentAgentConfig(a *resource.Agent,
+) (*resource.AgentConfig, error) {
+	ids := slices.Clone(a.Resources.Value)
+
+	slices.Sort(ids)
+
+	list := []*resource.AgentResource{}
+
+	for _, id := range ids {
+		i := s.find(id)
+		if i < 0 || s.resources[i].Status.Value == request.StatusInactive {
+			continue
+		}
+
+		r := s.resources[i]
+
+		list = append(list, &resource.AgentResource{
+			ResourceID:      r.ResourceID,
+			Name:            r.Name,
+			Version:         r.Version,
+			KeyField:        r.KeyField,
+			KeyRegex:        r.KeyRegex,
+			ClearCondition:  r.ClearCondition,
+			ClearAfter:      r.ClearAfter,
+			ClearDelay:      r.ClearDelay,
+			DuplicatePolicy: r.DuplicatePolicy,
+			KeyStrategy:     r.KeyStrategy,
+		})
+	}
+
+	return resource.NewAgentConfig(a.AgentID.Value, list)
+}
+
+// getConfigAgent retrieves an agent by ID, which must not be disabled.
+func (s *ResourceService) getConfigAgent(id string) (*resource.Agent, error) {
+	a, err := s.getAgent(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Status.Value == request.StatusInactive {
+		return nil, errors.New(errors.ErrForbidden,
+			"agent is disabled",
+			"id", id)
+	}
+
+	return a, nil
+}
+
+// GetAgentConfig retrieves the configuration an agent should act on.
+func (s *ResourceService) GetAgentConfig(ctx context.Context,
+	id string,
+) (*resource.AgentConfig, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	a, err := s.getConfigAgent(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, ok := s.pins[id]; ok {
+		return clone(p), nil
+	}
+
+	return s.currentAgentConfig(a)
+}
+
+// PinAgentConfig pins an agent to the current version of its configuration.
+func (s *ResourceService) PinAgentConfig(ctx context.Context,
+	id, version string,
+) (*resource.Agent, error) {
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	a, err := s.getConfigAgent(id)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.currentAgentConfig(a)
+	if err != nil {
+		return nil, err
+	}
+
+	if version != "" && version != cfg.Version {
+		return nil, errors.New(errors.ErrConflict,
+			"agent config version has changed",
+			"id", id,
+			"version", version,
+			"current_version", cfg.Version)
+	}
+
+	cfg.Pinned = true
+
+	if s.pins == nil {
+		s.pins = map[string]*resource.AgentConfig{}
+	}
+
+	s.pins[id] = cfg
+
+	a.ConfigVersion = request.FieldString{
+		Set: true, Valid: true, Value: cfg.Version,
+	}
+	a.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	a.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	return s.outputAgent(a, nil), nil
+}
+
+// UnpinAgentConfig unpins the configuration of an agent.
+func (s *ResourceService) UnpinAgentConfig(ctx context.Context,
+	id string,
+) (*resource.Agent, error) {
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	a, err := s.getAgent(id)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(s.pins, id)
+
+	a.ConfigVersion = request.FieldString{Set: true, Valid: false}
+	a.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	a.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	return s.outputAgent(a, nil), nil
+}
@@ -0,0 +1,260 @@
+package sandbox
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// findAlertRule returns the index of an alert rule by ID, or -1 if it is not
+// found.
+func (s *ResourceService) findAlertRule(id string) int {
+	return slices.IndexFunc(s.alertRules, func(a *resource.AlertRule) bool {
+		return a.AlertRuleID.Value == id
+	})
+}
+
+// getAlertRule retrieves an alert rule by ID.
+func (s *ResourceService) getAlertRule(id string) (*resource.AlertRule, error) {
+	i := s.findAlertRule(id)
+	if i < 0 {
+		return nil, errors.New(errors.ErrNotFound,
+			"alert rule not found",
+			"id", id)
+	}
+
+	return s.alertRules[i], nil
+}
+
+// outputAlertRule returns a copy of an alert rule.
+func outputAlertRule(a *resource.AlertRule,
+	options sqldb.FieldOptions,
+) *resource.AlertRule {
+	res := clone(a)
+
+	if !options.Contains(sqldb.OptUserDetails) {
+		res.CreatedAt = request.FieldTime{}
+		res.CreatedBy = request.FieldString{}
+		res.UpdatedAt = request.FieldTime{}
+		res.UpdatedBy = request.FieldString{}
+	}
+
+	return res
+}
+
+// GetAlertRules retrieves alert rules, sorted by name. Search and summary
+// queries are not evaluated for alert rules in the sandbox. As with the
+// database, one more alert rule than the query size is returned, when
+// available.
+func (s *ResourceService) GetAlertRules(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.AlertRule, []*sqldb.SummaryData, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	list := slices.Clone(s.alertRules)
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name.Value < list[j].Name.Value
+	})
+
+	size := query.Size
+	if size == 0 {
+		size = s.cfg.DBDefaultSize()
+	}
+
+	if query.Skip >= int64(len(list)) {
+		list = nil
+	} else {
+		list = list[query.Skip:]
+	}
+
+	if int64(len(list)) > size+1 {
+		list = list[:size+1]
+	}
+
+	res := make([]*resource.AlertRule, 0, len(list))
+
+	for _, a := range list {
+		res = append(res, outputAlertRule(a, options))
+	}
+
+	return res, nil, nil
+}
+
+// GetAlertRule retrieves a single alert rule by ID.
+func (s *ResourceService) GetAlertRule(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*resource.AlertRule, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	a, err := s.getAlertRule(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return outputAlertRule(a, options), nil
+}
+
+// CreateAlertRule creates an alert rule.
+func (s *ResourceService) CreateAlertRule(ctx context.Context,
+	v *resource.AlertRule,
+) (*resource.AlertRule, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing alert rule",
+			"alert_rule", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	a := clone(v)
+
+	if !a.AlertRuleID.Set || a.AlertRuleID.Value == "" {
+		a.AlertRuleID = request.FieldString{
+			Set: true, Valid: true, Value: fixtureID(s.next),
+		}
+
+		s.next++
+	}
+
+	if s.findAlertRule(a.AlertRuleID.Value) >= 0 {
+		return nil, errors.New(errors.ErrConflict,
+			"invalid alert_rule_id: already in use by another alert rule",
+			"alert_rule_id", a.AlertRuleID.Value)
+	}
+
+	if !a.Duration.Set {
+		a.Duration = request.FieldInt64{Set: true, Valid: true}
+	}
+
+	if !a.Channels.Set {
+		a.Channels = request.FieldStringArray{
+			Set: true, Valid: true, Value: []string{},
+		}
+	}
+
+	if !a.Status.Set {
+		a.Status = request.FieldString{
+			Set: true, Valid: true, Value: request.StatusActive,
+		}
+	}
+
+	now := time.Now().Unix()
+
+	a.CreatedAt = request.FieldTime{Set: true, Valid: true, Value: now}
+	a.CreatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+	a.UpdatedAt = a.CreatedAt
+	a.UpdatedBy = a.CreatedBy
+
+	s.alertRules = append(s.alertRules, a)
+
+	return outputAlertRule(a, sqldb.FieldOptions{sqldb.OptUserDetails}), nil
+}
+
+// UpdateAlertRule updates an alert rule.
+func (s *ResourceService) UpdateAlertRule(ctx context.Context,
+	v *resource.AlertRule,
+) (*resource.AlertRule, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing alert rule",
+			"alert_rule", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	a, err := s.getAlertRule(v.AlertRuleID.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Name.Set {
+		a.Name = v.Name
+	}
+
+	if v.Description.Set {
+		a.Description = v.Description
+	}
+
+	if v.Search.Set {
+		a.Search = v.Search
+	}
+
+	if v.Duration.Set {
+		a.Duration = v.Duration
+	}
+
+	if v.Channels.Set {
+		a.Channels = request.FieldStringArray{
+			Set: true, Valid: true, Value: slices.Clone(v.Channels.Value),
+		}
+	}
+
+	if v.Status.Set {
+		a.Status = v.Status
+	}
+
+	a.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	a.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	return outputAlertRule(a, sqldb.FieldOptions{sqldb.OptUserDetails}), nil
+}
+
+// DeleteAlertRule deletes an alert rule.
+func (s *ResourceService) DeleteAlertRule(ctx context.Context,
+	id string,
+) error {
+	s.Lock()
+	defer s.Unlock()
+
+	i := s.findAlertRule(id)
+	if i < 0 {
+		return errors.New(errors.ErrNotFound,
+			"alert rule not found",
+			"id", id)
+	}
+
+	s.alertRules = slices.Delete(s.alertRules, i, i+1)
+
+	return nil
+}
+
+// GetAlerts retrieves alerts. Alert rules are not evaluated in the sandbox, so
+// no alerts are ever raised.
+func (s *ResourceService) GetAlerts(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.Alert, []*sqldb.SummaryData, error) {
+	return []*resource.Alert{}, nil, nil
+}
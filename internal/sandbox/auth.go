@@ -0,0 +1,672 @@
+package sandbox
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// AuthService values provide in-memory authentication services. The Token
+// fixture authenticates as the sandbox user, with all scopes, and the sandbox
+// user may log in using the Password fixture.
+type AuthService struct {
+	sync.RWMutex
+	accounts  map[string]*auth.Account
+	repos     map[string]*auth.AccountRepo
+	users     map[string]*auth.User
+	passwords map[string]string
+	tokens    map[string]*auth.Claims
+	issued    map[string]*auth.IssuedToken
+	requests  map[string]map[auth.RequestCount]int64
+	ingest    map[string]map[auth.RequestCount]int64
+	keys      map[string]map[string]bool
+	sessions  map[string]*auth.Session
+	retained  map[string]map[string]*idempotentRequest
+	signing   map[string][]*auth.SigningKey
+	groups    []*auth.Group
+}
+
+// NewAuthService creates a new sandbox authentication service, seeded with
+// the sandbox fixtures.
+func NewAuthService() *AuthService {
+	s := &AuthService{
+		accounts:  map[string]*auth.Account{AccountID: fixtureAccount()},
+		repos:     map[string]*auth.AccountRepo{},
+		users:     map[string]*auth.User{UserID: fixtureUser()},
+		passwords: map[string]string{UserID: Password},
+		tokens: map[string]*auth.Claims{Token: {
+			AccountID:   AccountID,
+			AccountName: AccountID,
+			UserID:      UserID,
+			Scopes:      request.ScopeSuperuser,
+		}},
+		issued:   map[string]*auth.IssuedToken{},
+		requests: map[string]map[auth.RequestCount]int64{},
+		ingest:   map[string]map[auth.RequestCount]int64{},
+		keys:     map[string]map[string]bool{},
+		sessions: map[string]*auth.Session{},
+		retained: map[string]map[string]*idempotentRequest{},
+		signing:  map[string][]*auth.SigningKey{},
+	}
+
+	return s
+}
+
+// AuthJWT authenticates using a sandbox token. Uses of created tokens are
+// recorded immediately.
+func (s *AuthService) AuthJWT(ctx context.Context,
+	token, tenant string,
+) (*auth.Claims, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	c, ok := s.tokens[token]
+	if !ok {
+		return nil, errors.New(errors.ErrUnauthorized,
+			"invalid token")
+	}
+
+	if it, ok := s.issued[token]; ok {
+		it.UseCount.Value++
+		it.LastUsedAt = request.FieldTime{
+			Set: true, Valid: true, Value: time.Now().Unix(),
+		}
+	}
+
+	res := *c
+
+	if tenant != "" {
+		a, ok := s.accounts[tenant]
+		if !ok || a.Status.Value == request.StatusInactive {
+			return nil, errors.New(errors.ErrUnauthorized,
+				"invalid tenant",
+				"tenant", tenant)
+		}
+
+		res.AccountID = a.AccountID.Value
+		res.AccountName = a.Name.Value
+	}
+
+	return &res, nil
+}
+
+// AuthPassword authenticates using a user ID and password.
+func (s *AuthService) AuthPassword(ctx context.Context,
+	userID, password, tenant string,
+) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	if p, ok := s.passwords[userID]; !ok || p != password {
+		return errors.New(errors.ErrUnauthorized,
+			"invalid user_id or password",
+			"user_id", userID)
+	}
+
+	return nil
+}
+
+// ChangePassword changes the password of the sandbox user of the context.
+func (s *AuthService) ChangePassword(ctx context.Context,
+	current, password string,
+) error {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if p, ok := s.passwords[userID]; !ok || p != current {
+		return errors.New(errors.ErrUnauthorized,
+			"invalid user_id or password",
+			"user_id", userID)
+	}
+
+	if len(password) < 8 {
+		return errors.New(errors.ErrInvalidRequest,
+			"password must contain at least 8 characters",
+			"user_id", userID)
+	}
+
+	s.passwords[userID] = password
+
+	return nil
+}
+
+// CreateToken creates a new sandbox token. Tokens are numbered sequentially,
+// so the tokens created by a sequence of requests are deterministic. Tokens
+// created without scopes are granted the scopes of the user.
+func (s *AuthService) CreateToken(ctx context.Context,
+	userID string,
+	expiration int64,
+	scopes, tenant string,
+) (string, error) {
+	if !request.ValidUserID(userID) {
+		return "", errors.New(errors.ErrInvalidParameter,
+			"invalid user_id",
+			"user_id", userID)
+	}
+
+	if scopes != "" && !request.ValidScopes(scopes) {
+		return "", errors.New(errors.ErrInvalidParameter,
+			"invalid scopes",
+			"scopes", scopes)
+	}
+
+	if time.Now().Unix() >= expiration {
+		return "", errors.New(errors.ErrInvalidParameter,
+			"invalid expiration",
+			"expiration", expiration)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", errors.New(errors.ErrNotFound,
+			"user not found",
+			"user_id", userID)
+	}
+
+	restricted := scopes != ""
+
+	if !restricted {
+		scopes = u.Scopes.Value
+	}
+
+	accountID := AccountID
+
+	if tenant != "" {
+		if _, ok := s.accounts[tenant]; !ok {
+			return "", errors.New(errors.ErrUnauthorized,
+				"invalid tenant",
+				"tenant", tenant)
+		}
+
+		accountID = tenant
+	}
+
+	tok := Token + "-" + strconv.Itoa(len(s.tokens))
+
+	s.tokens[tok] = &auth.Claims{
+		AccountID:   accountID,
+		AccountName: accountID,
+		UserID:      userID,
+		Scopes:      scopes,
+	}
+
+	s.issued[tok] = &auth.IssuedToken{
+		TokenID: request.FieldString{
+			Set: true, Valid: true, Value: fixtureID(len(s.tokens) - 1),
+		},
+		UserID: request.FieldString{
+			Set: true, Valid: true, Value: userID,
+		},
+		Scopes: request.FieldString{
+			Set: true, Valid: true, Value: scopes,
+		},
+		Restricted: request.FieldBool{
+			Set: true, Valid: true, Value: restricted,
+		},
+		ExpiresAt: request.FieldTime{
+			Set: true, Valid: true, Value: expiration,
+		},
+		LastUsedAt: request.FieldTime{Set: true},
+		UseCount:   request.FieldInt64{Set: true, Valid: true},
+		CreatedAt: request.FieldTime{
+			Set: true, Valid: true, Value: time.Now().Unix(),
+		},
+	}
+
+	return tok, nil
+}
+
+// GetAccount retrieves an account.
+func (s *AuthService) GetAccount(ctx context.Context,
+	id string,
+) (*auth.Account, error) {
+	if id == "" || id == "current" {
+		accountID, err := request.ContextAccountID(ctx)
+		if err != nil {
+			return nil, errors.New(errors.ErrForbidden,
+				"unable to retrieve account id",
+				"id", id)
+		}
+
+		id = accountID
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	a, ok := s.accounts[id]
+	if !ok {
+		return nil, errors.New(errors.ErrNotFound,
+			"account not found",
+			"id", id)
+	}
+
+	return clone(a), nil
+}
+
+// CreateAccount creates, or updates, an account.
+func (s *AuthService) CreateAccount(ctx context.Context,
+	v *auth.Account,
+) (*auth.Account, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing account",
+			"account", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	a, ok := s.accounts[v.AccountID.Value]
+	if !ok {
+		a = &auth.Account{
+			AccountID: v.AccountID,
+			Status: request.FieldString{
+				Set: true, Valid: true, Value: request.StatusActive,
+			},
+			CreatedAt: request.FieldTime{
+				Set: true, Valid: true, Value: time.Now().Unix(),
+			},
+		}
+	}
+
+	if v.Name.Set {
+		a.Name = v.Name
+	}
+
+	if v.Status.Set {
+		a.Status = v.Status
+	}
+
+	if v.StatusData.Set {
+		a.StatusData = v.StatusData
+	}
+
+	if v.Data.Set {
+		a.Data = v.Data
+	}
+
+	a.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+
+	s.accounts[a.AccountID.Value] = a
+
+	return clone(a), nil
+}
+
+// GetAccounts retrieves all accounts, sorted by ID. Search queries are not
+// evaluated for accounts in the sandbox. As with the database, one more
+// account than the query size is returned, when available.
+func (s *AuthService) GetAccounts(ctx context.Context,
+	query *search.Query,
+) ([]*auth.Account, error) {
+	if !request.ContextHasScope(ctx, request.ScopeSuperuser) {
+		return nil, errors.New(errors.ErrForbidden,
+			"unable to get accounts",
+			"search", query)
+	}
+
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	ids := slices.Sorted(maps.Keys(s.accounts))
+
+	size := query.Size
+	if size == 0 {
+		size = config.DefaultDBDefaultSize
+	}
+
+	if query.Skip >= int64(len(ids)) {
+		ids = nil
+	} else {
+		ids = ids[query.Skip:]
+	}
+
+	if int64(len(ids)) > size+1 {
+		ids = ids[:size+1]
+	}
+
+	res := make([]*auth.Account, 0, len(ids))
+
+	for _, id := range ids {
+		res = append(res, clone(s.accounts[id]))
+	}
+
+	return res, nil
+}
+
+// UpdateAccount updates any account. When an account is made inactive, the
+// tokens issued for the account are removed.
+func (s *AuthService) UpdateAccount(ctx context.Context,
+	v *auth.Account,
+) (*auth.Account, error) {
+	if !request.ContextHasScope(ctx, request.ScopeSuperuser) {
+		return nil, errors.New(errors.ErrForbidden,
+			"unable to update account",
+			"account", v)
+	}
+
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing account",
+			"account", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	a, ok := s.accounts[v.AccountID.Value]
+	if !ok {
+		return nil, errors.New(errors.ErrNotFound,
+			"account not found",
+			"account", v)
+	}
+
+	if v.Name.Set {
+		a.Name = v.Name
+	}
+
+	if v.Status.Set {
+		a.Status = v.Status
+	}
+
+	if v.StatusData.Set {
+		a.StatusData = v.StatusData
+	}
+
+	if v.Data.Set {
+		a.Data = v.Data
+	}
+
+	a.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+
+	if a.Status.Value == request.StatusInactive {
+		maps.DeleteFunc(s.tokens, func(_ string, c *auth.Claims) bool {
+			return c.AccountID == a.AccountID.Value
+		})
+	}
+
+	return clone(a), nil
+}
+
+// DeactivateAccount makes any account inactive, removing the tokens issued
+// for the account.
+func (s *AuthService) DeactivateAccount(ctx context.Context,
+	id string,
+) (*auth.Account, error) {
+	return s.UpdateAccount(ctx, &auth.Account{
+		AccountID: request.FieldString{Set: true, Valid: true, Value: id},
+		Status: request.FieldString{
+			Set: true, Valid: true, Value: request.StatusInactive,
+		},
+	})
+}
+
+// GetAccountRepo retrieves the repository of the current account.
+func (s *AuthService) GetAccountRepo(ctx context.Context,
+) (*auth.AccountRepo, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	if r, ok := s.repos[accountID]; ok {
+		return clone(r), nil
+	}
+
+	return &auth.AccountRepo{
+		RepoStatus: request.FieldString{
+			Set: true, Valid: true, Value: request.StatusInactive,
+		},
+	}, nil
+}
+
+// SetAccountRepo sets the repository of the current account.
+func (s *AuthService) SetAccountRepo(ctx context.Context,
+	v *auth.AccountRepo,
+) error {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if v == nil {
+		return errors.New(errors.ErrInvalidRequest,
+			"missing account repo")
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.repos[accountID] = clone(v)
+
+	return nil
+}
+
+// GetChanges returns an empty change feed, since sandbox changes are not
+// recorded.
+func (s *AuthService) GetChanges(ctx context.Context,
+	since string,
+	size int64,
+) (*auth.ChangeFeed, error) {
+	if _, err := request.ContextAccountID(ctx); err != nil {
+		return nil, err
+	}
+
+	return &auth.ChangeFeed{Changes: []*auth.Change{}, Cursor: since}, nil
+}
+
+// GetUser retrieves a user.
+func (s *AuthService) GetUser(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*auth.User, error) {
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" || id == "current" {
+		id = userID
+	} else if id != userID &&
+		!request.ContextHasScope(ctx, request.ScopeSuperuser) {
+		return nil, errors.New(errors.ErrNotFound, "user not found")
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, errors.New(errors.ErrNotFound,
+			"user not found",
+			"id", id)
+	}
+
+	r := clone(u)
+
+	if !options.Contains(sqldb.OptUserDetails) && len(options.Fields()) == 0 {
+		r.CreatedAt = request.FieldTime{}
+		r.CreatedBy = request.FieldString{}
+		r.UpdatedAt = request.FieldTime{}
+		r.UpdatedBy = request.FieldString{}
+	}
+
+	if options.Contains(sqldb.OptGrants) {
+		r.Grants = request.FieldInt64{
+			Set: true, Valid: true,
+			Value: int64(len(strings.Fields(r.Scopes.Value))),
+		}
+	}
+
+	return r, nil
+}
+
+// CreateUser creates a new user.
+func (s *AuthService) CreateUser(ctx context.Context,
+	v *auth.User,
+) (*auth.User, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing user",
+			"user", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.users[v.UserID.Value]; ok {
+		return nil, errors.New(errors.ErrConflict,
+			"user already exists",
+			"user_id", v.UserID.Value)
+	}
+
+	r := clone(v)
+
+	now := time.Now().Unix()
+
+	if !r.Status.Set {
+		r.Status = request.FieldString{
+			Set: true, Valid: true, Value: request.StatusActive,
+		}
+	}
+
+	r.CreatedAt = request.FieldTime{Set: true, Valid: true, Value: now}
+	r.CreatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+	r.UpdatedAt = r.CreatedAt
+	r.UpdatedBy = r.CreatedBy
+	r.Password = nil
+
+	if v.Password != nil {
+		s.passwords[r.UserID.Value] = *v.Password
+	}
+
+	s.users[r.UserID.Value] = r
+
+	return clone(r), nil
+}
+
+// UpdateUser updates the current user.
+func (s *AuthService) UpdateUser(ctx context.Context,
+	v *auth.User,
+) (*auth.User, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing user",
+			"user", v)
+	}
+
+	userID, err := request.ContextUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !v.UserID.Set {
+		v.UserID = request.FieldString{Set: true, Valid: true, Value: userID}
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	r, ok := s.users[v.UserID.Value]
+	if !ok || (v.UserID.Value != userID &&
+		!request.ContextHasScope(ctx, request.ScopeSuperuser)) {
+		return nil, errors.New(errors.ErrNotFound,
+			"user not found",
+			"id", v.UserID.Value)
+	}
+
+	if v.Email.Set {
+		r.Email = v.Email
+	}
+
+	if v.FirstName.Set {
+		r.FirstName = v.FirstName
+	}
+
+	if v.LastName.Set {
+		r.LastName = v.LastName
+	}
+
+	if v.Status.Set {
+		r.Status = v.Status
+	}
+
+	if v.Scopes.Set {
+		r.Scopes = v.Scopes
+	}
+
+	if v.Data.Set {
+		r.Data = v.Data
+	}
+
+	if v.Password != nil {
+		s.passwords[r.UserID.Value] = *v.Password
+	}
+
+	r.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	r.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	return clone(r), nil
+}
+
+// Update does nothing, since sandbox authentication configuration is fixed.
+func (s *AuthService) Update(ctx context.Context) context.CancelFunc {
+	return func() {}
+}
+
+// UpdateTokenUsage does nothing, since sandbox token uses are recorded
+// immediately.
+func (s *AuthService) UpdateTokenUsage(ctx context.Context,
+) context.CancelFunc {
+	return func() {}
+}
@@ -0,0 +1,294 @@
+package sandbox
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// findGroup returns the index of a group by ID, or -1 if it is not found.
+func (s *AuthService) findGroup(id string) int {
+	return slices.IndexFunc(s.groups, func(g *auth.Group) bool {
+		return g.GroupID.Value == id
+	})
+}
+
+// getGroup retrieves a group by ID.
+func (s *AuthService) getGroup(id string) (*auth.Group, error) {
+	i := s.findGroup(id)
+	if i < 0 {
+		return nil, errors.New(errors.ErrNotFound,
+			"group not found",
+			"id", id)
+	}
+
+	return s.groups[i], nil
+}
+
+// outputGroup returns a copy of a group.
+func outputGroup(g *auth.Group, options sqldb.FieldOptions) *auth.Group {
+	res := clone(g)
+
+	if !options.Contains(sqldb.OptUserDetails) {
+		res.CreatedAt = request.FieldTime{}
+		res.CreatedBy = request.FieldString{}
+		res.UpdatedAt = request.FieldTime{}
+		res.UpdatedBy = request.FieldString{}
+	}
+
+	return res
+}
+
+// GetGroups retrieves groups, sorted by name. Search queries are not evaluated
+// for groups in the sandbox. As with the database, one more group than the
+// query size is returned, when available.
+func (s *AuthService) GetGroups(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*auth.Group, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	list := slices.Clone(s.groups)
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name.Value < list[j].Name.Value
+	})
+
+	size := query.Size
+	if size == 0 {
+		size = config.DefaultDBDefaultSize
+	}
+
+	if query.Skip >= int64(len(list)) {
+		list = nil
+	} else {
+		list = list[query.Skip:]
+	}
+
+	if int64(len(list)) > size+1 {
+		list = list[:size+1]
+	}
+
+	res := make([]*auth.Group, 0, len(list))
+
+	for _, g := range list {
+		res = append(res, outputGroup(g, options))
+	}
+
+	return res, nil
+}
+
+// GetGroup retrieves a single group by ID.
+func (s *AuthService) GetGroup(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*auth.Group, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	g, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return outputGroup(g, options), nil
+}
+
+// CreateGroup creates a new group. Grants are not checked in the sandbox,
+// since the sandbox user holds all scopes.
+func (s *AuthService) CreateGroup(ctx context.Context,
+	v *auth.Group,
+) (*auth.Group, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing group",
+			"group", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.findGroup(v.GroupID.Value) >= 0 {
+		return nil, errors.New(errors.ErrConflict,
+			"group already exists",
+			"group_id", v.GroupID.Value)
+	}
+
+	g := clone(v)
+
+	now := time.Now().Unix()
+
+	if !g.Status.Set {
+		g.Status = request.FieldString{
+			Set: true, Valid: true, Value: request.StatusActive,
+		}
+	}
+
+	if !g.Scopes.Set {
+		g.Scopes = request.FieldString{Set: true, Valid: true}
+	}
+
+	if !g.Users.Set {
+		g.Users = request.FieldStringArray{
+			Set: true, Valid: true, Value: []string{},
+		}
+	}
+
+	g.CreatedAt = request.FieldTime{Set: true, Valid: true, Value: now}
+	g.CreatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+	g.UpdatedAt = g.CreatedAt
+	g.UpdatedBy = g.CreatedBy
+
+	s.groups = append(s.groups, g)
+
+	return outputGroup(g, nil), nil
+}
+
+// UpdateGroup updates a group.
+func (s *AuthService) UpdateGroup(ctx context.Context,
+	v *auth.Group,
+) (*auth.Group, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing group",
+			"group", v)
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	g, err := s.getGroup(v.GroupID.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.Name.Set {
+		g.Name = v.Name
+	}
+
+	if v.Description.Set {
+		g.Description = v.Description
+	}
+
+	if v.Status.Set {
+		g.Status = v.Status
+	}
+
+	if v.Scopes.Set {
+		g.Scopes = v.Scopes
+	}
+
+	if v.Users.Set {
+		g.Users = request.FieldStringArray{
+			Set: true, Valid: true, Value: slices.Clone(v.Users.Value),
+		}
+	}
+
+	if v.Data.Set {
+		g.Data = v.Data
+	}
+
+	g.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	g.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	return outputGroup(g, nil), nil
+}
+
+// DeleteGroup deletes a group.
+func (s *AuthService) DeleteGroup(ctx context.Context,
+	id string,
+) error {
+	s.Lock()
+	defer s.Unlock()
+
+	i := s.findGroup(id)
+	if i < 0 {
+		return errors.New(errors.ErrNotFound,
+			"group not found",
+			"id", id)
+	}
+
+	s.groups = slices.Delete(s.groups, i, i+1)
+
+	return nil
+}
+
+// AddGroupUser adds a user to a group.
+func (s *AuthService) AddGroupUser(ctx context.Context,
+	id, userID string,
+) (*auth.Group, error) {
+	return s.updateGroupUsers(ctx, id, userID, true)
+}
+
+// RemoveGroupUser removes a user from a group.
+func (s *AuthService) RemoveGroupUser(ctx context.Context,
+	id, userID string,
+) (*auth.Group, error) {
+	return s.updateGroupUsers(ctx, id, userID, false)
+}
+
+// updateGroupUsers adds or removes a user from the users of a group.
+func (s *AuthService) updateGroupUsers(ctx context.Context,
+	id, userID string,
+	add bool,
+) (*auth.Group, error) {
+	if !request.ValidUserID(userID) {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid user_id",
+			"user_id", userID)
+	}
+
+	ctxUserID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	g, err := s.getGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	users := slices.DeleteFunc(slices.Clone(g.Users.Value),
+		func(u string) bool {
+			return u == userID
+		})
+
+	if add {
+		users = append(users, userID)
+	}
+
+	g.Users = request.FieldStringArray{Set: true, Valid: true, Value: users}
+	g.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	g.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: ctxUserID}
+
+	return outputGroup(g, nil), nil
+}
@@ -0,0 +1,130 @@
+package sandbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// idempotentRequest values contain a request made with an idempotency key, and
+// its response, once it has been completed.
+type idempotentRequest struct {
+	hash      string
+	response  *auth.IdempotentResponse
+	expiresAt time.Time
+}
+
+// TokenAccountID returns the ID of the account issuing tokens for a tenant,
+// which is the sandbox account if no tenant is specified.
+func (s *AuthService) TokenAccountID(ctx context.Context,
+	tenant string,
+) (string, error) {
+	if tenant == "" {
+		return AccountID, nil
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	if _, ok := s.accounts[tenant]; !ok {
+		return "", errors.New(errors.ErrUnauthorized,
+			"invalid tenant",
+			"tenant", tenant)
+	}
+
+	return tenant, nil
+}
+
+// ClaimIdempotencyKey records that the account is processing a request made
+// with an idempotency key. If the key has been used for the same request, the
+// retained response is returned. Responses are retained for an hour.
+func (s *AuthService) ClaimIdempotencyKey(ctx context.Context,
+	key, operation, hash string,
+) (*auth.IdempotentResponse, error) {
+	if key == "" || len(key) > auth.MaxIdempotencyKey {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid idempotency key",
+			"idempotency_key", key)
+	}
+
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.retained[accountID] == nil {
+		s.retained[accountID] = map[string]*idempotentRequest{}
+	}
+
+	ir, ok := s.retained[accountID][key]
+	if !ok || time.Now().After(ir.expiresAt) {
+		s.retained[accountID][key] = &idempotentRequest{
+			hash:      hash,
+			expiresAt: time.Now().Add(time.Hour),
+		}
+
+		return nil, nil
+	}
+
+	if ir.hash != hash {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"idempotency key already used for a different request",
+			"idempotency_key", key)
+	}
+
+	if ir.response == nil {
+		return nil, errors.New(errors.ErrConflict,
+			"request with idempotency key in progress",
+			"idempotency_key", key).Transient(true)
+	}
+
+	return ir.response, nil
+}
+
+// SaveIdempotentResponse retains the response to a request made with a claimed
+// idempotency key.
+func (s *AuthService) SaveIdempotentResponse(ctx context.Context,
+	key, hash string,
+	res *auth.IdempotentResponse,
+) error {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if ir, ok := s.retained[accountID][key]; ok && ir.hash == hash {
+		ir.response = res
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey releases a claimed idempotency key without retaining a
+// response.
+func (s *AuthService) ReleaseIdempotencyKey(ctx context.Context,
+	key, hash string,
+) error {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	if ir, ok := s.retained[accountID][key]; ok && ir.hash == hash &&
+		ir.response == nil {
+		delete(s.retained[accountID], key)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package sandbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// GetSigningKeys retrieves the signing keys of the account, starting with the
+// active key. Sandbox tokens are not signed, so the keys are only recorded.
+func (s *AuthService) GetSigningKeys(ctx context.Context,
+) ([]*auth.SigningKey, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	res := []*auth.SigningKey{}
+
+	for _, k := range s.signing[accountID] {
+		v := *k
+
+		res = append(res, &v)
+	}
+
+	if len(res) == 0 {
+		res = append(res, &auth.SigningKey{KeyID: accountID, Active: true})
+	}
+
+	return res, nil
+}
+
+// RotateSigningKey replaces the active signing key of the account. Replaced
+// keys never expire in the sandbox.
+func (s *AuthService) RotateSigningKey(ctx context.Context,
+) (*auth.SigningKey, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, k := range s.signing[accountID] {
+		k.Active = false
+	}
+
+	k := &auth.SigningKey{
+		KeyID:     accountID + "#" + fixtureID(len(s.signing[accountID])),
+		Active:    true,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	s.signing[accountID] = append([]*auth.SigningKey{k},
+		s.signing[accountID]...)
+
+	v := *k
+
+	return &v, nil
+}
+
+// RotateSigningKeys does nothing, since sandbox signing keys are only rotated
+// on request.
+func (s *AuthService) RotateSigningKeys(ctx context.Context,
+) context.CancelFunc {
+	return func() {}
+}
+
+// GetAuthFailures retrieves the recent failed authentication attempts, which
+// are not recorded in the sandbox.
+func (s *AuthService) GetAuthFailures(ctx context.Context,
+) ([]*auth.AuthFailure, error) {
+	return []*auth.AuthFailure{}, nil
+}
@@ -0,0 +1,1715 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/repo"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// ResourceService values provide in-memory resource services, seeded with the
+// sandbox resource fixtures. Resources created in the sandbox receive
+// sequential IDs, so the results of a sequence of requests are deterministic.
+type ResourceService struct {
+	sync.RWMutex
+	cfg        *config.Config
+	resources  []*resource.Resource
+	agents     []*resource.Agent
+	acls       []*resource.ACL
+	pins       map[string]*resource.AgentConfig
+	alertRules []*resource.AlertRule
+	changes    []resourceChange
+	revs       []*resource.Revision
+	next       int
+}
+
+// resourceChange values record a change made to a sandbox resource. The
+// revision of each change is its position in the change log.
+type resourceChange struct {
+	id        string
+	operation string
+}
+
+// NewResourceService creates a new sandbox resource service.
+func NewResourceService(cfg *config.Config) *ResourceService {
+	if cfg == nil {
+		cfg = config.NewDefault()
+	}
+
+	res := fixtureResources()
+
+	svc := &ResourceService{
+		cfg:       cfg,
+		resources: res,
+		next:      len(res) + 1,
+	}
+
+	for _, r := range res {
+		svc.revise(r)
+	}
+
+	return svc
+}
+
+// find returns the index of a resource by ID, or -1 if it is not found.
+func (s *ResourceService) find(id string) int {
+	return slices.IndexFunc(s.resources, func(r *resource.Resource) bool {
+		return r.ResourceID.Value == id
+	})
+}
+
+// record adds a resource change to the change log.
+func (s *ResourceService) record(id, operation string) {
+	s.changes = append(s.changes, resourceChange{id: id, operation: operation})
+}
+
+// revise records a revision of the definition of a resource, if it differs
+// from the definition of its latest revision, as the database does.
+func (s *ResourceService) revise(r *resource.Resource) {
+	def, err := r.Definition()
+	if err != nil {
+		return
+	}
+
+	n := int64(1)
+
+	for i := len(s.revs) - 1; i >= 0; i-- {
+		if s.revs[i].ResourceID.Value != r.ResourceID.Value {
+			continue
+		}
+
+		if reflect.DeepEqual(s.revs[i].Definition.Value, def) {
+			return
+		}
+
+		n = s.revs[i].Revision.Value + 1
+
+		break
+	}
+
+	s.revs = append(s.revs, &resource.Revision{
+		ResourceID: r.ResourceID,
+		Revision:   request.FieldInt64{Set: true, Valid: true, Value: n},
+		Definition: request.FieldJSON{Set: true, Valid: true, Value: def},
+		Version:    r.Version,
+		Source:     r.Source,
+		CommitHash: r.CommitHash,
+		CreatedAt:  r.UpdatedAt,
+		CreatedBy:  r.UpdatedBy,
+	})
+}
+
+// revisions returns the number of recorded revisions of a resource, counting
+// each creation and update.
+func (s *ResourceService) revisions(id string) int64 {
+	n := int64(0)
+
+	for _, c := range s.changes {
+		if c.id == id && c.operation != auth.ChangeOperationDelete {
+			n++
+		}
+	}
+
+	return n
+}
+
+// compute sets the computed fields of a resource output value, derived from
+// the stored resource, as they are computed by the database.
+func compute(v, r *resource.Resource) {
+	v.DataCount = request.FieldInt64{
+		Set: true, Valid: true, Value: int64(len(r.Data.Value)),
+	}
+
+	v.AgeSeconds = request.FieldInt64{
+		Set: true, Valid: true, Value: time.Now().Unix() - r.UpdatedAt.Value,
+	}
+
+	v.ClearedRatio = request.FieldFloat64{Set: true}
+
+	data, _ := r.StatusData.Value["data_items"].(int)
+	cleared, _ := r.StatusData.Value["cleared_items"].(int)
+
+	if data+cleared > 0 {
+		v.ClearedRatio.Valid = true
+		v.ClearedRatio.Value = float64(cleared) / float64(data+cleared)
+	}
+}
+
+// get retrieves a resource by ID.
+func (s *ResourceService) get(id string) (*resource.Resource, error) {
+	i := s.find(id)
+	if i < 0 {
+		return nil, errors.New(errors.ErrNotFound,
+			"resource not found",
+			"id", id)
+	}
+
+	return s.resources[i], nil
+}
+
+// output returns a copy of a resource containing only the requested fields.
+func output(r *resource.Resource,
+	options sqldb.FieldOptions,
+) *resource.Resource {
+	res := clone(r)
+
+	if fields := options.Fields(); len(fields) > 0 {
+		m := resourceMap(res)
+
+		for k := range m {
+			if k != "resource_id" && !slices.Contains(fields, k) {
+				delete(m, k)
+			}
+		}
+
+		res = &resource.Resource{}
+
+		if b, err := json.Marshal(m); err == nil {
+			_ = json.Unmarshal(b, res)
+		}
+
+		return res
+	}
+
+	if !options.Contains(sqldb.OptUserDetails) {
+		res.CreatedAt = request.FieldTime{}
+		res.CreatedBy = request.FieldString{}
+		res.UpdatedAt = request.FieldTime{}
+		res.UpdatedBy = request.FieldString{}
+	}
+
+	if !options.Contains(sqldb.OptTags) {
+		res.Tags = request.FieldStringArray{}
+	}
+
+	return res
+}
+
+// resourceMap returns the JSON object representation of a resource.
+func resourceMap(r *resource.Resource) map[string]any {
+	m := map[string]any{}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return m
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+
+	d.UseNumber()
+
+	_ = d.Decode(&m)
+
+	return m
+}
+
+// lookup retrieves the value of a, possibly nested, category from a resource
+// object.
+func lookup(m map[string]any, cat string) (any, bool) {
+	var v any = m
+
+	for _, p := range strings.Split(cat, ".") {
+		o, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if v, ok = o[p]; !ok {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// matchValue determines whether a value matches a search node.
+func matchValue(v any, node *search.QueryNode) (bool, error) {
+	if a, ok := v.([]any); ok {
+		for _, av := range a {
+			if m, err := matchValue(av, node); err != nil || m {
+				return m, err
+			}
+		}
+
+		return false, nil
+	}
+
+	s := fmt.Sprint(v)
+
+	if v == nil {
+		s = ""
+	}
+
+	if node.ValRE != "" {
+		pattern := node.ValRE
+
+		if node.CI {
+			pattern = "(?i)" + pattern
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return false, errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid search value pattern",
+				"value", node.ValRE)
+		}
+
+		return re.MatchString(s), nil
+	}
+
+	val := strings.NewReplacer("÷", "?", "°", "*").Replace(node.Val)
+
+	// Relative times, such as now-24h, are compared as Unix timestamps, which
+	// is how resource times are represented.
+	if request.IsRelativeTime(val) {
+		if i, err := request.ParseRelativeTime(val, time.Now()); err == nil {
+			val = strconv.FormatInt(i, 10)
+		}
+	}
+
+	switch node.Comp {
+	case search.OpGT, search.OpGTE, search.OpLT, search.OpLTE:
+		c := strings.Compare(s, val)
+
+		l, lErr := strconv.ParseFloat(s, 64)
+		r, rErr := strconv.ParseFloat(val, 64)
+
+		if lErr == nil && rErr == nil {
+			c = 0
+
+			if l < r {
+				c = -1
+			} else if l > r {
+				c = 1
+			}
+		}
+
+		switch node.Comp {
+		case search.OpGT:
+			return c > 0, nil
+		case search.OpGTE:
+			return c >= 0, nil
+		case search.OpLT:
+			return c < 0, nil
+		default:
+			return c <= 0, nil
+		}
+	}
+
+	if val == "" {
+		return true, nil
+	}
+
+	pattern := node.Val
+
+	if node.CI {
+		s, val, pattern = search.Fold(s), search.Fold(val),
+			search.Fold(pattern)
+	}
+
+	if strings.ContainsAny(node.Val, "*?") {
+		m, err := filepath.Match(pattern, s)
+		if err != nil {
+			return false, errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid search value pattern",
+				"value", node.Val)
+		}
+
+		return m, nil
+	}
+
+	return s == val, nil
+}
+
+// match determines whether a resource matches a search query. Categories which
+// are not resource fields are matched against the resource tags.
+func match(r *resource.Resource, ast *search.QueryTree) (bool, error) {
+	if ast.Root == nil || len(ast.Root.Nodes) == 0 {
+		return true, nil
+	}
+
+	m := resourceMap(r)
+
+	return ast.Eval(func(node *search.QueryNode) (bool, error) {
+		if node.Cat == "" {
+			return true, nil
+		}
+
+		if v, ok := lookup(m, node.Cat); ok ||
+			strings.Contains(node.Cat, ".") {
+			return matchValue(v, node)
+		}
+
+		tag := &search.QueryNode{
+			Op:    node.Op,
+			Comp:  node.Comp,
+			Val:   node.Cat + ":" + node.Val,
+			ValRE: node.ValRE,
+			CI:    node.CI,
+		}
+
+		if node.Val == "" {
+			tag.Val = node.Cat + ":*"
+		}
+
+		tags := make([]any, 0, len(r.Tags.Value))
+
+		for _, t := range r.Tags.Value {
+			tags = append(tags, t)
+		}
+
+		return matchValue(tags, tag)
+	})
+}
+
+// less compares two resources using a sort specification, in the same format
+// used by search queries.
+func less(a, b *resource.Resource, spec string) (bool, error) {
+	return lessMap(resourceMap(a), resourceMap(b), spec, "created_at",
+		"created_by", "updated_at", "updated_by", "tags")
+}
+
+// lessMap compares two values, represented as maps, using a sort
+// specification. The known fields may be sorted by even when neither value
+// contains them.
+func lessMap(am, bm map[string]any,
+	spec string,
+	known ...string,
+) (bool, error) {
+	for _, sv := range strings.Split(spec, ",") {
+		desc := strings.HasPrefix(sv, "-")
+
+		sv = strings.TrimPrefix(sv, "-")
+
+		av, ok := lookup(am, sv)
+		if !ok {
+			if _, ok := lookup(bm, sv); !ok && !slices.Contains(known, sv) {
+				return false, errors.New(errors.ErrInvalidRequest,
+					"invalid query order value: "+sv)
+			}
+		}
+
+		bv, _ := lookup(bm, sv)
+
+		as, bs := fmt.Sprint(av), fmt.Sprint(bv)
+		if as == bs {
+			continue
+		}
+
+		c := as < bs
+
+		if af, err := strconv.ParseFloat(as, 64); err == nil {
+			if bf, err := strconv.ParseFloat(bs, 64); err == nil {
+				c = af < bf
+			}
+		}
+
+		return c != desc, nil
+	}
+
+	return false, nil
+}
+
+// search returns the resources matching a search query string, ignoring case
+// and accents if ci is true. The caller must hold the lock.
+func (s *ResourceService) search(query string,
+	ci bool,
+) ([]*resource.Resource, error) {
+	qp := search.NewParser(bytes.NewBufferString(query))
+
+	qp.Primary = "name"
+
+	ast, err := qp.Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid search query",
+			"search", query)
+	}
+
+	if ci {
+		ast.Root.SetCaseInsensitive()
+	}
+
+	list := []*resource.Resource{}
+
+	for _, r := range s.resources {
+		ok, err := match(r, ast)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			list = append(list, r)
+		}
+	}
+
+	return list, nil
+}
+
+// GetResources retrieves resources based on a search query. As with the
+// database, one more resource than the query size is returned, when available,
+// so that the server can report whether there are more results.
+func (s *ResourceService) GetResources(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.Resource, []*sqldb.SummaryData, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	list, err := s.search(query.Search, query.CaseInsensitive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if query.Summary != "" {
+		return nil, summarize(list, query.Summary), nil
+	}
+
+	spec := query.Sort
+	if spec == "" {
+		spec = "name"
+	}
+
+	var sErr error
+
+	sort.SliceStable(list, func(i, j int) bool {
+		l, err := less(list[i], list[j], spec)
+		if err != nil {
+			sErr = err
+		}
+
+		return l
+	})
+
+	if sErr != nil {
+		return nil, nil, sErr
+	}
+
+	size := query.Size
+	if size == 0 {
+		size = s.cfg.DBDefaultSize()
+	}
+
+	if query.Skip >= int64(len(list)) {
+		list = nil
+	} else {
+		list = list[query.Skip:]
+	}
+
+	if int64(len(list)) > size+1 {
+		list = list[:size+1]
+	}
+
+	res := make([]*resource.Resource, 0, len(list))
+
+	for _, r := range list {
+		v := output(r, options)
+
+		if options.Contains(sqldb.OptRevisions) {
+			v.Revisions = request.FieldInt64{
+				Set: true, Valid: true, Value: s.revisions(r.ResourceID.Value),
+			}
+		}
+
+		if options.Contains(sqldb.OptComputed) {
+			compute(v, r)
+		}
+
+		res = append(res, v)
+	}
+
+	return res, nil, nil
+}
+
+// CountResources retrieves the total number of resources matching a search
+// query, ignoring the size and skip values of the query.
+func (s *ResourceService) CountResources(ctx context.Context,
+	query *search.Query,
+) (int64, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	list, err := s.search(query.Search, query.CaseInsensitive)
+	if err != nil {
+		return 0, err
+	}
+
+	return int64(len(list)), nil
+}
+
+// Transact performs a function as a unit of work. If the function returns an
+// error, any changes made to the sandbox resources are discarded.
+func (s *ResourceService) Transact(ctx context.Context,
+	f func(ctx context.Context) error,
+) error {
+	s.RLock()
+
+	resources := make([]*resource.Resource, len(s.resources))
+
+	for i, r := range s.resources {
+		resources[i] = clone(r)
+	}
+
+	changes, next := len(s.changes), s.next
+
+	s.RUnlock()
+
+	if err := f(ctx); err != nil {
+		s.Lock()
+		s.resources, s.changes, s.next = resources, s.changes[:changes], next
+		s.Unlock()
+
+		return err
+	}
+
+	return nil
+}
+
+// ExplainResources returns the cost of searching for resources using a search
+// query. Since the sandbox has no database, the plan only estimates the number
+// of resources found.
+func (s *ResourceService) ExplainResources(ctx context.Context,
+	query *search.Query,
+) (*sqldb.QueryPlan, error) {
+	n, err := s.CountResources(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqldb.QueryPlan{
+		Rows: n,
+		Plan: map[string]any{"Node Type": "Sandbox Scan", "Plan Rows": n},
+	}, nil
+}
+
+// summarize groups resources by the summary fields and counts each group.
+func summarize(list []*resource.Resource,
+	summary string,
+) []*sqldb.SummaryData {
+	fields := strings.Split(summary, ",")
+
+	groups := map[string]*sqldb.SummaryData{}
+
+	keys := []string{}
+
+	for _, r := range list {
+		m := resourceMap(r)
+
+		sd := sqldb.SummaryData{}
+
+		for _, f := range fields {
+			v, _ := lookup(m, f)
+
+			sd[f] = v
+		}
+
+		b, _ := json.Marshal(sd)
+
+		k := string(b)
+
+		if g, ok := groups[k]; ok {
+			(*g)["count"] = (*g)["count"].(int64) + 1
+
+			continue
+		}
+
+		sd["count"] = int64(1)
+
+		groups[k] = &sd
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	res := make([]*sqldb.SummaryData, 0, len(keys))
+
+	for _, k := range keys {
+		res = append(res, groups[k])
+	}
+
+	return res
+}
+
+// GetResource retrieves a single resource by ID.
+func (s *ResourceService) GetResource(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*resource.Resource, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := output(r, options)
+
+	if options.Contains(sqldb.OptRevisions) {
+		res.Revisions = request.FieldInt64{
+			Set: true, Valid: true, Value: s.revisions(id),
+		}
+	}
+
+	if options.Contains(sqldb.OptComputed) {
+		compute(res, r)
+	}
+
+	return res, nil
+}
+
+// CreateResource creates a new resource.
+func (s *ResourceService) CreateResource(ctx context.Context,
+	v *resource.Resource,
+) (*resource.Resource, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing resource",
+			"resource", v)
+	}
+
+	if err := v.ValidateCreate(s.cfg); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	r := clone(v)
+
+	if !r.ResourceID.Set || r.ResourceID.Value == "" {
+		r.ResourceID = request.FieldString{
+			Set: true, Valid: true, Value: fixtureID(s.next),
+		}
+
+		s.next++
+	}
+
+	if s.find(r.ResourceID.Value) >= 0 {
+		return nil, errors.New(errors.ErrConflict,
+			"resource already exists",
+			"resource_id", r.ResourceID.Value)
+	}
+
+	if err := s.checkDependencies(r); err != nil {
+		return nil, err
+	}
+
+	if !r.Status.Set {
+		r.Status = request.FieldString{
+			Set: true, Valid: true, Value: request.StatusActive,
+		}
+	}
+
+	if !r.Data.Set {
+		r.Data = request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{},
+		}
+	}
+
+	// As with the database, tags are assigned separately from creation.
+	r.Tags = request.FieldStringArray{}
+
+	now := time.Now().Unix()
+
+	r.CreatedAt = request.FieldTime{Set: true, Valid: true, Value: now}
+	r.CreatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+	r.UpdatedAt = r.CreatedAt
+	r.UpdatedBy = r.CreatedBy
+
+	s.resources = append(s.resources, r)
+
+	s.record(r.ResourceID.Value, auth.ChangeOperationCreate)
+
+	s.revise(r)
+
+	return output(r, sqldb.FieldOptions{sqldb.OptUserDetails}), nil
+}
+
+// UpdateResource updates a resource.
+func (s *ResourceService) UpdateResource(ctx context.Context,
+	v *resource.Resource,
+) (*resource.Resource, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing resource",
+			"resource", v)
+	}
+
+	if err := v.Validate(s.cfg); err != nil {
+		return nil, err
+	}
+
+	userID, _ := request.ContextUserID(ctx)
+
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.get(v.ResourceID.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkDependencies(v); err != nil {
+		return nil, err
+	}
+
+	// Only the fields present in the request are updated, so the request is
+	// merged into the existing resource using their JSON representations.
+	m := resourceMap(r)
+
+	for k, val := range resourceMap(v) {
+		m[k] = val
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to encode resource",
+			"resource", v)
+	}
+
+	res := &resource.Resource{}
+
+	if err := json.Unmarshal(b, res); err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"unable to decode resource",
+			"resource", v)
+	}
+
+	res.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+	res.UpdatedBy = request.FieldString{Set: true, Valid: true, Value: userID}
+
+	s.resources[s.find(r.ResourceID.Value)] = res
+
+	s.record(res.ResourceID.Value, auth.ChangeOperationUpdate)
+
+	s.revise(res)
+
+	return output(res, sqldb.FieldOptions{sqldb.OptUserDetails}), nil
+}
+
+// PreviewClearCondition evaluates a clear_condition against the most recently
+// stored data items of a resource, using the same matching as the service.
+func (s *ResourceService) PreviewClearCondition(ctx context.Context,
+	id, condition string,
+	size int64,
+) (*resource.ClearPreview, error) {
+	ast, err := search.NewParser(bytes.NewBufferString(condition)).Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid clear_condition",
+			"id", id,
+			"clear_condition", condition)
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := func(key string) float64 {
+		am, _ := r.Data.Value[key].(map[string]any)
+
+		f, _ := strconv.ParseFloat(fmt.Sprint(am["ts"]), 64)
+
+		return f
+	}
+
+	keys := make([]string, 0, len(r.Data.Value))
+
+	for k := range r.Data.Value {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if ti, tj := ts(keys[i]), ts(keys[j]); ti != tj {
+			return ti > tj
+		}
+
+		return keys[i] < keys[j]
+	})
+
+	if int64(len(keys)) > size {
+		keys = keys[:size]
+	}
+
+	res := &resource.ClearPreview{
+		ClearCondition: condition,
+		Keys:           []string{},
+	}
+
+	set := make([]map[string]any, 0, len(keys))
+
+	for _, k := range keys {
+		am, _ := r.Data.Value[k].(map[string]any)
+
+		set = append(set, am)
+	}
+
+	for i, k := range keys {
+		cleared, err := ast.MatchItem(set[i], set)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to evaluate resource clear_condition",
+				"id", id,
+				"clear_condition", condition,
+				"key", k)
+		}
+
+		res.Evaluated++
+
+		if cleared {
+			res.Cleared++
+			res.Keys = append(res.Keys, k)
+		}
+	}
+
+	return res, nil
+}
+
+// DeleteResource deletes a resource by ID.
+func (s *ResourceService) DeleteResource(ctx context.Context,
+	id string,
+) error {
+	s.Lock()
+	defer s.Unlock()
+
+	i := s.find(id)
+	if i < 0 {
+		return errors.New(errors.ErrNotFound,
+			"resource not found",
+			"id", id)
+	}
+
+	s.resources = slices.Delete(s.resources, i, i+1)
+
+	s.acls = slices.DeleteFunc(s.acls, func(a *resource.ACL) bool {
+		return a.ResourceID.Value == id
+	})
+
+	s.revs = slices.DeleteFunc(s.revs, func(r *resource.Revision) bool {
+		return r.ResourceID.Value == id
+	})
+
+	s.record(id, auth.ChangeOperationDelete)
+
+	return nil
+}
+
+// checkDependencies returns an error if any of the resources a resource depends
+// on do not exist. Unlike the service, dependency cycles are not detected.
+func (s *ResourceService) checkDependencies(v *resource.Resource) error {
+	f := errors.FieldErrors{}
+
+	for _, id := range v.DependsOn.Value {
+		if s.find(id) < 0 {
+			f.Add("depends_on", errors.FieldInvalid,
+				"invalid depends_on: resource not found: "+id)
+		}
+	}
+
+	return f.Err("resource", v)
+}
+
+// GetResourceDependents retrieves the resources which depend on a resource,
+// and which also match the search query, if it has one.
+func (s *ResourceService) GetResourceDependents(ctx context.Context,
+	id string,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.Resource, error) {
+	s.RLock()
+
+	_, err := s.get(id)
+
+	s.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := &search.Query{}
+
+	if query != nil {
+		*q = *query
+	}
+
+	if q.Search == "" {
+		q.Search = "and(depends_on:" + id + ")"
+	} else {
+		q.Search = "and(depends_on:" + id + "," + q.Search + ")"
+	}
+
+	q.Summary = ""
+
+	res, _, err := s.GetResources(ctx, q, options)
+
+	return res, err
+}
+
+// dataItems returns the data items of a resource by ID which match a search
+// query, as maps containing the key, data, and ts of each item, in no
+// particular order.
+func (s *ResourceService) dataItems(id string,
+	query *search.Query,
+) ([]map[string]any, error) {
+	qp := search.NewParser(bytes.NewBufferString(query.Search))
+
+	qp.Primary = "key"
+
+	ast, err := qp.Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid search query",
+			"search", query.Search)
+	}
+
+	if query.CaseInsensitive {
+		ast.Root.SetCaseInsensitive()
+	}
+
+	s.RLock()
+
+	r, err := s.get(id)
+	if err != nil {
+		s.RUnlock()
+
+		return nil, err
+	}
+
+	items := make([]map[string]any, 0, len(r.Data.Value))
+
+	for k, v := range r.Data.Value {
+		am, _ := v.(map[string]any)
+
+		ts, err := strconv.ParseFloat(fmt.Sprint(am["ts"]), 64)
+		if err != nil {
+			ts = float64(r.UpdatedAt.Value)
+		}
+
+		items = append(items, map[string]any{
+			"key":  k,
+			"data": *clone(&am),
+			"ts":   int64(ts),
+		})
+	}
+
+	s.RUnlock()
+
+	if ast.Root == nil || len(ast.Root.Nodes) == 0 {
+		return items, nil
+	}
+
+	list := []map[string]any{}
+
+	for _, m := range items {
+		ok, err := ast.Eval(func(node *search.QueryNode) (bool, error) {
+			if node.Cat == "" {
+				return true, nil
+			}
+
+			v, _ := lookup(m, node.Cat)
+
+			return matchValue(v, node)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			list = append(list, m)
+		}
+	}
+
+	return list, nil
+}
+
+// GetResourceData retrieves the data items of a resource by ID which match the
+// search query, ordered by key unless the query is sorted. As with the
+// database, one more item than the query size is returned, when available.
+func (s *ResourceService) GetResourceData(ctx context.Context,
+	id string,
+	query *search.Query,
+) ([]*resource.DataItem, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	list, err := s.dataItems(id, query)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := query.Sort
+	if spec == "" {
+		spec = "key"
+	}
+
+	var sErr error
+
+	sort.SliceStable(list, func(i, j int) bool {
+		l, err := lessMap(list[i], list[j], spec)
+		if err != nil {
+			sErr = err
+		}
+
+		return l
+	})
+
+	if sErr != nil {
+		return nil, sErr
+	}
+
+	size := query.Size
+	if size == 0 {
+		size = s.cfg.DBDefaultSize()
+	}
+
+	if query.Skip >= int64(len(list)) {
+		list = nil
+	} else {
+		list = list[query.Skip:]
+	}
+
+	if int64(len(list)) > size+1 {
+		list = list[:size+1]
+	}
+
+	res := make([]*resource.DataItem, 0, len(list))
+
+	for _, m := range list {
+		data, _ := m["data"].(map[string]any)
+
+		res = append(res, &resource.DataItem{
+			Key: request.FieldString{
+				Set: true, Valid: true, Value: m["key"].(string),
+			},
+			Data: request.FieldJSON{
+				Set: true, Valid: data != nil, Value: data,
+			},
+			TS: request.FieldTime{
+				Set: true, Valid: true, Value: m["ts"].(int64),
+			},
+		})
+	}
+
+	return res, nil
+}
+
+// GetResourceDataSummary summarizes the data items of a resource by ID which
+// match the search query, grouped by the value of the group data field, most
+// items first, aggregating the numeric values of the value data field.
+func (s *ResourceService) GetResourceDataSummary(ctx context.Context,
+	id, group, value string,
+	query *search.Query,
+) ([]*resource.DataSummary, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	if group == "" || slices.Contains(strings.Split(group, "."), "") {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource data summary group",
+			"id", id,
+			"group", group)
+	}
+
+	if value != "" && slices.Contains(strings.Split(value, "."), "") {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource data summary value",
+			"id", id,
+			"value", value)
+	}
+
+	list, err := s.dataItems(id, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Items without the group field are summarized in a single group with a
+	// null value, using a NUL key, which stored text values can not contain.
+	groups, counts := map[string]*resource.DataSummary{},
+		map[*resource.DataSummary]float64{}
+
+	res := []*resource.DataSummary{}
+
+	for _, m := range list {
+		gk, gv := "\x00", request.FieldString{Set: true}
+
+		if v, ok := lookup(m, "data."+group); ok && v != nil {
+			gk, ok = v.(string)
+			if !ok {
+				b, _ := json.Marshal(v)
+
+				gk = string(b)
+			}
+
+			gv.Valid, gv.Value = true, gk
+		}
+
+		sum, ok := groups[gk]
+		if !ok {
+			sum = &resource.DataSummary{
+				Value: gv,
+				Sum:   request.FieldFloat64{Set: true},
+				Min:   request.FieldFloat64{Set: true},
+				Max:   request.FieldFloat64{Set: true},
+				Avg:   request.FieldFloat64{Set: true},
+			}
+
+			groups[gk] = sum
+
+			res = append(res, sum)
+		}
+
+		sum.Count++
+
+		if value == "" {
+			continue
+		}
+
+		v, _ := lookup(m, "data."+value)
+
+		n, ok := v.(float64)
+		if !ok {
+			continue
+		}
+
+		if !sum.Min.Valid || n < sum.Min.Value {
+			sum.Min.Valid, sum.Min.Value = true, n
+		}
+
+		if !sum.Max.Valid || n > sum.Max.Value {
+			sum.Max.Valid, sum.Max.Value = true, n
+		}
+
+		sum.Sum.Valid, sum.Sum.Value = true, sum.Sum.Value+n
+
+		counts[sum]++
+
+		sum.Avg.Valid, sum.Avg.Value = true, sum.Sum.Value/counts[sum]
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+
+		if res[i].Value.Valid != res[j].Value.Valid {
+			return res[i].Value.Valid
+		}
+
+		return res[i].Value.Value < res[j].Value.Value
+	})
+
+	size := query.Size
+	if size == 0 {
+		size = s.cfg.DBDefaultSize()
+	}
+
+	if query.Skip >= int64(len(res)) {
+		res = nil
+	} else {
+		res = res[query.Skip:]
+	}
+
+	if int64(len(res)) > size+1 {
+		res = res[:size+1]
+	}
+
+	return res, nil
+}
+
+// GetResourceRevisions retrieves the revisions of the definition of a resource
+// by ID, most recent first.
+func (s *ResourceService) GetResourceRevisions(ctx context.Context,
+	id string,
+) ([]*resource.Revision, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if _, err := s.get(id); err != nil {
+		return nil, err
+	}
+
+	res := []*resource.Revision{}
+
+	for i := len(s.revs) - 1; i >= 0; i-- {
+		if s.revs[i].ResourceID.Value == id {
+			res = append(res, clone(s.revs[i]))
+		}
+	}
+
+	return res, nil
+}
+
+// RollbackResource restores the definition of a resource by ID to that of one
+// of its revisions.
+func (s *ResourceService) RollbackResource(ctx context.Context,
+	id string,
+	revision int64,
+) (*resource.Resource, error) {
+	s.RLock()
+
+	i := slices.IndexFunc(s.revs, func(r *resource.Revision) bool {
+		return r.ResourceID.Value == id && r.Revision.Value == revision
+	})
+
+	var rev *resource.Revision
+
+	if i >= 0 {
+		rev = clone(s.revs[i])
+	}
+
+	s.RUnlock()
+
+	if rev == nil {
+		return nil, errors.New(errors.ErrNotFound,
+			"resource revision not found",
+			"id", id,
+			"revision", revision)
+	}
+
+	v, err := rev.Resource()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.UpdateResource(ctx, v)
+}
+
+// DiffResource reports that the sandbox resource is not in an import
+// repository, since the sandbox does not import resources.
+func (s *ResourceService) DiffResource(ctx context.Context,
+	authSvc resource.AuthService,
+	id string,
+) (*resource.ResourceDiff, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resource.ResourceDiff{
+		ResourceID: r.ResourceID.Value,
+		CommitHash: r.CommitHash.Value,
+		Fields:     []*resource.FieldDiff{},
+	}, nil
+}
+
+// GetResourcesDelta retrieves the IDs of the resources changed at, or after,
+// the specified revision.
+func (s *ResourceService) GetResourcesDelta(ctx context.Context,
+	sinceRevision int64,
+) (*resource.ResourceDelta, error) {
+	if sinceRevision < 0 {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid since_revision",
+			"since_revision", sinceRevision)
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	res := resource.NewResourceDelta(int64(len(s.changes)))
+
+	ids, first, last := []string{}, map[string]string{}, map[string]string{}
+
+	for _, c := range s.changes[min(sinceRevision, res.Revision):] {
+		if _, ok := first[c.id]; !ok {
+			ids = append(ids, c.id)
+			first[c.id] = c.operation
+		}
+
+		last[c.id] = c.operation
+	}
+
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		res.Add(id, first[id], last[id])
+	}
+
+	return res, nil
+}
+
+// UpdateResourceData stores a resource data payload under the value of its
+// key field. Clear conditions are not evaluated in the sandbox.
+func (s *ResourceService) UpdateResourceData(ctx context.Context,
+	payload map[string]any,
+	accountID, resourceID string,
+) (*resource.Resource, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.get(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Status.Value == request.StatusInactive {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"unable to update resource data for inactive resource",
+			"payload", payload,
+			"resource", r)
+	}
+
+	key, ok := payload[r.KeyField.Value]
+	if !ok {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"resource data payload missing key field",
+			"key_field", r.KeyField.Value,
+			"payload", payload)
+	}
+
+	if r.Data.Value == nil {
+		r.Data = request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{},
+		}
+	}
+
+	r.Data.Value[fmt.Sprint(key)] = payload
+	r.Status = request.FieldString{
+		Set: true, Valid: true, Value: request.StatusActive,
+	}
+	r.StatusData = request.FieldJSON{
+		Set: true, Valid: true, Value: map[string]any{
+			"data_items":    1,
+			"cleared_items": 0,
+		},
+	}
+	r.UpdatedAt = request.FieldTime{
+		Set: true, Valid: true, Value: time.Now().Unix(),
+	}
+
+	s.record(r.ResourceID.Value, auth.ChangeOperationUpdate)
+
+	return output(r, sqldb.FieldOptions{sqldb.OptUserDetails}), nil
+}
+
+// UpdateResourcesData updates the resource data of multiple resources.
+func (s *ResourceService) UpdateResourcesData(ctx context.Context,
+	entries []*resource.ResourceDataEntry,
+) (*request.MultiStatus, error) {
+	accountID, _ := request.ContextAccountID(ctx)
+
+	ms := request.NewMultiStatus()
+
+	for i, e := range entries {
+		if e == nil || !e.ResourceID.Valid || e.ResourceID.Value == "" {
+			ms.Add(i, "", http.StatusOK, nil,
+				errors.New(errors.ErrInvalidRequest,
+					"missing resource_id",
+					"index", i))
+
+			continue
+		}
+
+		r, err := s.UpdateResourceData(ctx, e.Data, accountID,
+			e.ResourceID.Value)
+
+		ms.Add(i, e.ResourceID.Value, http.StatusOK, r, err)
+	}
+
+	return ms, nil
+}
+
+// UpdateResourceError sets the error status of a resource.
+func (s *ResourceService) UpdateResourceError(ctx context.Context,
+	accountID, resourceID string,
+	resourceError error,
+) error {
+	if resourceError == nil {
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	r, err := s.get(resourceID)
+	if err != nil {
+		return err
+	}
+
+	r.Status = request.FieldString{
+		Set: true, Valid: true, Value: request.StatusError,
+	}
+	r.StatusData = request.FieldJSON{
+		Set: true, Valid: true, Value: map[string]any{
+			"last_error": resourceError.Error(),
+		},
+	}
+
+	s.record(r.ResourceID.Value, auth.ChangeOperationUpdate)
+
+	return nil
+}
+
+// ImportResources does nothing, since the sandbox has no import repository.
+func (s *ResourceService) ImportResources(ctx context.Context,
+	force bool,
+	filter *repo.Filter,
+	authSvc resource.AuthService,
+) error {
+	return nil
+}
+
+// ImportResource does nothing, since the sandbox has no import repository,
+// but fails if the resource does not exist.
+func (s *ResourceService) ImportResource(ctx context.Context,
+	authSvc resource.AuthService,
+	resourceID string,
+) error {
+	s.RLock()
+	defer s.RUnlock()
+
+	_, err := s.get(resourceID)
+
+	return err
+}
+
+// GetImportErrors returns no import errors, since the sandbox has no import
+// repository.
+func (s *ResourceService) GetImportErrors(ctx context.Context,
+) ([]*resource.ImportError, error) {
+	return []*resource.ImportError{}, nil
+}
+
+// BootstrapResources does nothing, since the sandbox has no template
+// repository.
+func (s *ResourceService) BootstrapResources(ctx context.Context,
+) (int, error) {
+	return 0, nil
+}
+
+// Update does nothing, since the sandbox has no import repository.
+func (s *ResourceService) Update(ctx context.Context,
+	authSvc resource.AuthService,
+) context.CancelFunc {
+	return func() {}
+}
+
+// GetTags retrieves all resource tags and tag values.
+func (s *ResourceService) GetTags(ctx context.Context,
+) (resource.TagMap, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	res := resource.TagMap{}
+
+	for _, r := range s.resources {
+		for _, t := range r.Tags.Value {
+			parts := strings.SplitN(t, ":", 2)
+
+			if _, ok := res[parts[0]]; !ok {
+				res[parts[0]] = []string{}
+			}
+
+			if len(parts) > 1 && parts[1] != "" &&
+				!slices.Contains(res[parts[0]], parts[1]) {
+				res[parts[0]] = append(res[parts[0]], parts[1])
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// GetResourceTags retrieves all tags assigned to a resource by ID.
+func (s *ResourceService) GetResourceTags(ctx context.Context,
+	resourceID string,
+) ([]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	r, err := s.get(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Clone(r.Tags.Value), nil
+}
+
+// AddResourceTags adds tags to a resource by ID.
+func (s *ResourceService) AddResourceTags(ctx context.Context,
+	resourceID string,
+	tags []string,
+) ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.addTags(resourceID, tags)
+}
+
+// addTags adds tags to a resource by ID, the lock must be held.
+func (s *ResourceService) addTags(resourceID string,
+	tags []string,
+) ([]string, error) {
+	r, err := s.get(resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tags {
+		if !slices.Contains(r.Tags.Value, t) {
+			r.Tags.Value = append(r.Tags.Value, t)
+		}
+	}
+
+	r.Tags.Set, r.Tags.Valid = true, true
+
+	return slices.Clone(r.Tags.Value), nil
+}
+
+// DeleteResourceTags deletes tags from a resource by ID.
+func (s *ResourceService) DeleteResourceTags(ctx context.Context,
+	resourceID string,
+	tags []string,
+) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.deleteTags(resourceID, tags)
+}
+
+// deleteTags deletes tags from a resource by ID, the lock must be held.
+func (s *ResourceService) deleteTags(resourceID string,
+	tags []string,
+) error {
+	r, err := s.get(resourceID)
+	if err != nil {
+		return err
+	}
+
+	r.Tags.Value = slices.DeleteFunc(r.Tags.Value, func(t string) bool {
+		return slices.Contains(tags, t)
+	})
+
+	return nil
+}
+
+// selectResources returns the IDs of the resources matching a selector.
+func (s *ResourceService) selectResources(selector string,
+) ([]string, error) {
+	ast, err := search.NewParser(bytes.NewBufferString(selector)).Parse()
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid resource_selector",
+			"resource_selector", selector)
+	}
+
+	res := []string{}
+
+	for _, r := range s.resources {
+		ok, err := match(r, ast)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			res = append(res, r.ResourceID.Value)
+		}
+	}
+
+	return res, nil
+}
+
+// CreateTagsMultiAssignment creates resource tags using a resource selector.
+func (s *ResourceService) CreateTagsMultiAssignment(ctx context.Context,
+	v *resource.TagsMultiAssignment,
+) (*resource.TagsMultiAssignment, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing tags_multi_assignment",
+			"tags_multi_assignment", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	ids, err := s.selectResources(v.ResourceSelector.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if _, err := s.addTags(id, v.Tags.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// DeleteTagsMultiAssignment deletes resource tags using a resource selector.
+func (s *ResourceService) DeleteTagsMultiAssignment(ctx context.Context,
+	v *resource.TagsMultiAssignment,
+) (*resource.TagsMultiAssignment, error) {
+	if v == nil {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"missing tags_multi_assignment",
+			"tags_multi_assignment", v)
+	}
+
+	if err := v.ValidateCreate(); err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	ids, err := s.selectResources(v.ResourceSelector.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := s.deleteTags(id, v.Tags.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
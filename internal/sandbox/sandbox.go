@@ -0,0 +1,280 @@
+// Package sandbox provides in-memory implementations of the service
+// dependencies of the API server, seeded with deterministic fixtures, so that
+// the full API can be served without a database or cache.
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sandbox fixture values.
+const (
+	AccountID = "sandbox"
+	UserID    = "sandbox@apigo.io"
+	Password  = "sandbox"
+	Token     = "sandbox"
+
+	// FixtureTime is the Unix timestamp used for all fixture timestamps.
+	FixtureTime = int64(1704067200)
+)
+
+// fixtureID returns the deterministic UUID of the nth sandbox object.
+func fixtureID(n int) string {
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", n)
+}
+
+// fixtureAccount returns the sandbox account fixture.
+func fixtureAccount() *auth.Account {
+	return &auth.Account{
+		AccountID: request.FieldString{
+			Set: true, Valid: true, Value: AccountID,
+		},
+		Name: request.FieldString{
+			Set: true, Valid: true, Value: AccountID,
+		},
+		Status: request.FieldString{
+			Set: true, Valid: true, Value: request.StatusActive,
+		},
+		StatusData: request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{},
+		},
+		RepoStatus: request.FieldString{
+			Set: true, Valid: true, Value: request.StatusInactive,
+		},
+		RepoStatusData: request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{},
+		},
+		Data: request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{},
+		},
+		CreatedAt: request.FieldTime{
+			Set: true, Valid: true, Value: FixtureTime,
+		},
+		UpdatedAt: request.FieldTime{
+			Set: true, Valid: true, Value: FixtureTime,
+		},
+	}
+}
+
+// fixtureUser returns the sandbox user fixture.
+func fixtureUser() *auth.User {
+	return &auth.User{
+		UserID: request.FieldString{
+			Set: true, Valid: true, Value: UserID,
+		},
+		Email: request.FieldString{
+			Set: true, Valid: true, Value: UserID,
+		},
+		FirstName: request.FieldString{
+			Set: true, Valid: true, Value: "Sandbox",
+		},
+		LastName: request.FieldString{
+			Set: true, Valid: true, Value: "User",
+		},
+		Status: request.FieldString{
+			Set: true, Valid: true, Value: request.StatusActive,
+		},
+		Scopes: request.FieldString{
+			Set: true, Valid: true, Value: request.ScopeSuperuser,
+		},
+		Data: request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{},
+		},
+		CreatedAt: request.FieldTime{
+			Set: true, Valid: true, Value: FixtureTime,
+		},
+		CreatedBy: request.FieldString{
+			Set: true, Valid: true, Value: UserID,
+		},
+		UpdatedAt: request.FieldTime{
+			Set: true, Valid: true, Value: FixtureTime,
+		},
+		UpdatedBy: request.FieldString{
+			Set: true, Valid: true, Value: UserID,
+		},
+	}
+}
+
+// fixtureResources returns the sandbox resource fixtures.
+func fixtureResources() []*resource.Resource {
+	res := []*resource.Resource{}
+
+	for i, v := range []struct {
+		name, description, status string
+		tags                      []string
+	}{{
+		name:        "api-latency",
+		description: "Latency of the public API.",
+		status:      request.StatusActive,
+		tags:        []string{"env:production", "team:platform"},
+	}, {
+		name:        "disk-usage",
+		description: "Disk usage of the database hosts.",
+		status:      request.StatusActive,
+		tags:        []string{"env:production", "team:storage"},
+	}, {
+		name:        "queue-depth",
+		description: "Depth of the background job queue.",
+		status:      request.StatusInactive,
+		tags:        []string{"env:staging", "team:platform"},
+	}} {
+		res = append(res, &resource.Resource{
+			ResourceID: request.FieldString{
+				Set: true, Valid: true, Value: fixtureID(i + 1),
+			},
+			Name: request.FieldString{
+				Set: true, Valid: true, Value: v.name,
+			},
+			Version: request.FieldString{
+				Set: true, Valid: true, Value: "1",
+			},
+			Description: request.FieldString{
+				Set: true, Valid: true, Value: v.description,
+			},
+			Status: request.FieldString{
+				Set: true, Valid: true, Value: v.status,
+			},
+			StatusData: request.FieldJSON{
+				Set: true, Valid: true, Value: map[string]any{},
+			},
+			KeyField: request.FieldString{
+				Set: true, Valid: true, Value: "id",
+			},
+			KeyRegex: request.FieldString{
+				Set: true, Valid: true, Value: ".*",
+			},
+			ClearCondition: request.FieldString{
+				Set: true, Valid: true, Value: "eq(cleared:true)",
+			},
+			ClearAfter: request.FieldInt64{
+				Set: true, Valid: true, Value: 3600,
+			},
+			ClearDelay: request.FieldInt64{
+				Set: true, Valid: true, Value: 0,
+			},
+			Data: request.FieldJSON{
+				Set: true, Valid: true, Value: map[string]any{},
+			},
+			Source: request.FieldString{
+				Set: true, Valid: true, Value: "sandbox",
+			},
+			CommitHash: request.FieldString{
+				Set: true, Valid: true, Value: "",
+			},
+			CreatedAt: request.FieldTime{
+				Set: true, Valid: true, Value: FixtureTime,
+			},
+			CreatedBy: request.FieldString{
+				Set: true, Valid: true, Value: UserID,
+			},
+			UpdatedAt: request.FieldTime{
+				Set: true, Valid: true, Value: FixtureTime,
+			},
+			UpdatedBy: request.FieldString{
+				Set: true, Valid: true, Value: UserID,
+			},
+			Tags: request.FieldStringArray{
+				Set: true, Valid: true, Value: v.tags,
+			},
+		})
+	}
+
+	return res
+}
+
+// clone returns a deep copy of a value, so that values held by the sandbox
+// stores are never shared with callers.
+func clone[T any](v *T) *T {
+	if v == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+
+	r := new(T)
+
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil
+	}
+
+	return r
+}
+
+// DB values implement the sqldb.SQLDB interface without a database. They
+// report the database as available, but fail any attempted queries, since
+// all sandbox data is served by the sandbox services.
+type DB struct{}
+
+// NewDB creates a new sandbox database.
+func NewDB() *DB {
+	return &DB{}
+}
+
+// errDB returns the error reported for any attempted database operation.
+func errDB() error {
+	return errors.New(errors.ErrUnimplemented,
+		"database operations are not available in sandbox mode")
+}
+
+// BeginTx fails, since the sandbox database does not support transactions.
+func (d *DB) BeginTx(ctx context.Context,
+	opts pgx.TxOptions,
+) (sqldb.SQLTX, error) {
+	return nil, errDB()
+}
+
+// Exec fails, since the sandbox database does not support queries.
+func (d *DB) Exec(ctx context.Context,
+	query string, args ...any,
+) (sqldb.SQLResult, error) {
+	return nil, errDB()
+}
+
+// Query fails, since the sandbox database does not support queries.
+func (d *DB) Query(ctx context.Context,
+	query string, args ...any,
+) (sqldb.SQLRows, error) {
+	return nil, errDB()
+}
+
+// QueryRow returns a row which fails to scan, since the sandbox database does
+// not support queries.
+func (d *DB) QueryRow(ctx context.Context,
+	query string, args ...any,
+) sqldb.SQLRow {
+	return errRow{}
+}
+
+// Close does nothing.
+func (d *DB) Close() {}
+
+// Ping always succeeds.
+func (d *DB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Stat returns nil, since the sandbox database has no connection pool.
+func (d *DB) Stat() *pgxpool.Stat {
+	return nil
+}
+
+// errRow values implement the sqldb.SQLRow interface, always failing to scan.
+type errRow struct{}
+
+// Scan always fails.
+func (errRow) Scan(dest ...any) error {
+	return errDB()
+}
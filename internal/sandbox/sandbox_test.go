@@ -0,0 +1,1030 @@
+package sandbox_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/sandbox"
+	"github.com/dhaifley/apigo/internal/server"
+)
+
+const basePath = config.DefaultServerPathPrefix
+
+func newServer(t *testing.T) *server.Server {
+	t.Helper()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(sandbox.NewDB())
+
+	svr.SetAuthService(sandbox.NewAuthService())
+
+	svr.SetResourceService(sandbox.NewResourceService(nil))
+
+	return svr
+}
+
+func serve(t *testing.T, svr *server.Server,
+	method, u, token string,
+	body io.Reader,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r, err := http.NewRequest(method, u, body)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	if token != "" {
+		r.Header.Set("Authorization", token)
+	}
+
+	w := httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	return w
+}
+
+func TestSearchResources(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	tests := []struct {
+		name  string
+		query string
+		token string
+		code  int
+		resp  string
+	}{{
+		name:  "invalid token",
+		token: "invalid",
+		code:  http.StatusUnauthorized,
+		resp:  `invalid token`,
+	}, {
+		name:  "all",
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `"name":"api-latency"`,
+	}, {
+		name:  "search",
+		query: `?search=and(status:inactive)`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `[{"resource_id":"00000000-0000-4000-8000-000000000003"`,
+	}, {
+		name:  "relative time search",
+		query: `?search=and(updated_at:%3Cnow-1h,status:inactive)`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `[{"resource_id":"00000000-0000-4000-8000-000000000003"`,
+	}, {
+		name:  "search too deeply nested",
+		query: `?search=and(or(and(or(and(or(and(or(status:active))))))))`,
+		token: sandbox.Token,
+		code:  http.StatusBadRequest,
+		resp:  `search query too deeply nested`,
+	}, {
+		name:  "explain search",
+		query: `?search=and(status:inactive)&explain=true`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `"rows":1`,
+	}, {
+		name:  "case insensitive search",
+		query: `?search=ci(name:DISK*)`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `[{"resource_id":"00000000-0000-4000-8000-000000000002"`,
+	}, {
+		name:  "tag search",
+		query: `?search=and(team:storage)`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `"name":"disk-usage"`,
+	}, {
+		name:  "sort",
+		query: `?sort=-name&size=1`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `"name":"queue-depth"`,
+	}, {
+		name:  "summary",
+		query: `?summary=status`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp:  `{"count":2,"status":"active"}`,
+	}, {
+		name:  "fields",
+		query: `?fields=name&size=1`,
+		token: sandbox.Token,
+		code:  http.StatusOK,
+		resp: `[{"name":"api-latency",` +
+			`"resource_id":"00000000-0000-4000-8000-000000000001"}]`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := serve(t, svr, http.MethodGet,
+				basePath+"/resources"+tt.query, tt.token, nil)
+
+			if w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, w.Code)
+			}
+
+			res := w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestSearchResourcesCount(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodGet,
+		basePath+"/resources?search=and(status:active)&size=1&count=true",
+		sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	if v := w.Header().Get("X-Total-Count"); v != "2" {
+		t.Errorf("Expected total count: 2, got: %v", v)
+	}
+
+	if v := w.Header().Get("X-Has-More"); v != "true" {
+		t.Errorf("Expected has more: true, got: %v", v)
+	}
+
+	if n := strings.Count(w.Body.String(), `"resource_id"`); n != 1 {
+		t.Errorf("Expected resources: 1, got: %v", n)
+	}
+}
+
+func TestSearchResourcesEnvelope(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	r, err := http.NewRequest(http.MethodGet,
+		basePath+"/resources?search=and(status:active)&size=1&skip=1"+
+			"&count=true&fields=name", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", sandbox.Token)
+	r.Header.Set("X-Envelope", "true")
+
+	w := httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	res := &server.Envelope{Paging: &server.Paging{}}
+
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `[{"name":"disk-usage",` +
+		`"resource_id":"00000000-0000-4000-8000-000000000002"}]`
+
+	if b, _ := json.Marshal(res.Data); string(b) != exp {
+		t.Errorf("Expected data: %v, got: %v", exp, string(b))
+	}
+
+	p := res.Paging
+
+	if p.From != 1 || p.Size != 1 || p.Total == nil || *p.Total != 2 {
+		t.Errorf("Expected from: 1, size: 1, total: 2, got: %+v", p)
+	}
+
+	if p.Next != "" {
+		t.Errorf("Expected no next link, got: %v", p.Next)
+	}
+
+	expPrev := basePath + "/resources?count=true&fields=name" +
+		"&search=and%28status%3Aactive%29&size=1&skip=0"
+
+	if p.Prev != expPrev {
+		t.Errorf("Expected prev link: %v, got: %v", expPrev, p.Prev)
+	}
+}
+
+func TestCreateResource(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodPost, basePath+"/resources", sandbox.Token,
+		bytes.NewBufferString(`{"name":"test","key_field":"id"}`))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+			w.Code, w.Body.String())
+	}
+
+	exp := `"resource_id":"00000000-0000-4000-8000-000000000004"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodGet,
+		basePath+"/resources/00000000-0000-4000-8000-000000000004"+
+			"?include=revisions", sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	exp = `"revisions":1`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodDelete,
+		basePath+"/resources/00000000-0000-4000-8000-000000000004",
+		sandbox.Token, nil)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Code expected: %v, got: %v", http.StatusNoContent, w.Code)
+	}
+
+	w = serve(t, svr, http.MethodGet,
+		basePath+"/resources/00000000-0000-4000-8000-000000000004",
+		sandbox.Token, nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Code expected: %v, got: %v", http.StatusNotFound, w.Code)
+	}
+
+	w = serve(t, svr, http.MethodGet, basePath+"/resources:delta",
+		sandbox.Token, nil)
+
+	exp = `{"revision":2,"created":[],"updated":[],` +
+		`"deleted":["00000000-0000-4000-8000-000000000004"]}`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodGet,
+		basePath+"/resources:delta?since_revision=2", sandbox.Token, nil)
+
+	exp = `{"revision":2,"created":[],"updated":[],"deleted":[]}`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+}
+
+func TestRollbackResource(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodPost, basePath+"/resources", sandbox.Token,
+		bytes.NewBufferString(`{"name":"test","key_field":"id"}`))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+			w.Code, w.Body.String())
+	}
+
+	u := basePath + "/resources/00000000-0000-4000-8000-000000000004"
+
+	w = serve(t, svr, http.MethodPatch, u, sandbox.Token,
+		bytes.NewBufferString(`{"name":"changed"}`))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK, w.Code,
+			w.Body.String())
+	}
+
+	w = serve(t, svr, http.MethodPost, u+"/rollback/1", sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK, w.Code,
+			w.Body.String())
+	}
+
+	exp := `"name":"test"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodGet, u+"/revisions", sandbox.Token, nil)
+
+	revs := []map[string]any{}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &revs); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revs) != 3 || revs[0]["revision"] != float64(3) {
+		t.Errorf("Expected revisions: 3, got: %v", revs)
+	}
+
+	w = serve(t, svr, http.MethodPost, u+"/rollback/9", sandbox.Token, nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Code expected: %v, got: %v", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCreateResourceTags(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodPost, basePath+"/resources", sandbox.Token,
+		bytes.NewBufferString(`{"name":"test","key_field":"id",`+
+			`"tags":["env:test","env:test","team:ops"]}`))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+			w.Code, w.Body.String())
+	}
+
+	exp := `"tags":["env:test","team:ops"]`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodGet,
+		basePath+"/resources/00000000-0000-4000-8000-000000000004/tags",
+		sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	if res := w.Body.String(); !strings.Contains(res, "team:ops") {
+		t.Errorf("Expected body to contain: team:ops, got: %v", res)
+	}
+}
+
+func TestCreateResourceYAML(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	r, err := http.NewRequest(http.MethodPost, basePath+"/resources",
+		strings.NewReader("name: test\nkey_field: id\ndata:\n  a: 1\n"))
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", sandbox.Token)
+	r.Header.Set("Content-Type", "application/yaml")
+	r.Header.Set("Accept", "application/yaml")
+
+	w := httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+			w.Code, w.Body.String())
+	}
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct,
+		"application/yaml") {
+		t.Errorf("Expected YAML content type, got: %v", ct)
+	}
+
+	for _, exp := range []string{
+		"resource_id: 00000000-0000-4000-8000-000000000004\n",
+		"key_field: id\n",
+		"data:\n  a: 1\n",
+	} {
+		if res := w.Body.String(); !strings.Contains(res, exp) {
+			t.Errorf("Expected body to contain: %q, got: %v", exp, res)
+		}
+	}
+}
+
+func TestResourceComputed(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodPost, basePath+"/resources", sandbox.Token,
+		bytes.NewBufferString(`{"name":"test","key_field":"id"}`))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+			w.Code, w.Body.String())
+	}
+
+	id := "00000000-0000-4000-8000-000000000004"
+
+	w = serve(t, svr, http.MethodPost, basePath+"/resources/data",
+		sandbox.Token, bytes.NewBufferString(
+			`[{"resource_id":"`+id+`","data":{"id":"a","value":1}}]`))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK, w.Code,
+			w.Body.String())
+	}
+
+	w = serve(t, svr, http.MethodGet, basePath+"/resources/"+id,
+		sandbox.Token, nil)
+
+	if res := w.Body.String(); strings.Contains(res, `"data_count":1`) {
+		t.Errorf("Expected no computed fields, got: %v", res)
+	}
+
+	w = serve(t, svr, http.MethodGet,
+		basePath+"/resources/"+id+"?include=computed", sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	for _, exp := range []string{
+		`"data_count":1`,
+		`"cleared_ratio":0`,
+	} {
+		if res := w.Body.String(); !strings.Contains(res, exp) {
+			t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+		}
+	}
+
+	if res := w.Body.String(); strings.Contains(res, `"age_seconds":null`) {
+		t.Errorf("Expected age_seconds, got: %v", res)
+	}
+}
+
+func TestCreateResourceIdempotent(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(http.MethodPost, basePath+"/resources",
+			strings.NewReader(body))
+		if err != nil {
+			t.Fatal("Failed to initialize request", err)
+		}
+
+		r.Header.Set("Authorization", sandbox.Token)
+		r.Header.Set("Idempotency-Key", "create")
+
+		w := httptest.NewRecorder()
+
+		svr.Mux(w, r)
+
+		return w
+	}
+
+	exp := `"resource_id":"00000000-0000-4000-8000-000000000004"`
+
+	for i, replayed := range []string{"", "true"} {
+		w := post(`{"name":"test","key_field":"id"}`)
+
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+				w.Code, w.Body.String())
+		}
+
+		if res := w.Body.String(); !strings.Contains(res, exp) {
+			t.Errorf("Expected body %d to contain: %v, got: %v", i, exp,
+				res)
+		}
+
+		if v := w.Header().Get("Idempotent-Replayed"); v != replayed {
+			t.Errorf("Expected replayed %d: %q, got: %q", i, replayed, v)
+		}
+	}
+
+	if w := post(`{"name":"other"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Code expected: %v, got: %v", http.StatusBadRequest,
+			w.Code)
+	}
+}
+
+func TestAgents(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodPost, basePath+"/agents", sandbox.Token,
+		bytes.NewBufferString(`{"agent_id":"agent-1","name":"test",`+
+			`"resources":["00000000-0000-4000-8000-000000000001"]}`))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+			w.Code, w.Body.String())
+	}
+
+	exp := `"status":"active"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodPost, basePath+"/agents/agent-1/heartbeat",
+		sandbox.Token, bytes.NewBufferString(`{"version":"2"}`))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	exp = `"version":"2"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodPost, basePath+"/agents/agent-1/disable",
+		sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	w = serve(t, svr, http.MethodPost, basePath+"/agents/agent-1/heartbeat",
+		sandbox.Token, bytes.NewBufferString(""))
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Code expected: %v, got: %v", http.StatusForbidden, w.Code)
+	}
+
+	w = serve(t, svr, http.MethodGet, basePath+"/agents", sandbox.Token, nil)
+
+	exp = `"status":"inactive"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodDelete, basePath+"/agents/agent-1",
+		sandbox.Token, nil)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Code expected: %v, got: %v", http.StatusNoContent, w.Code)
+	}
+}
+
+func TestAgentConfig(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodPost, basePath+"/agents", sandbox.Token,
+		bytes.NewBufferString(`{"agent_id":"agent-1","name":"test",`+
+			`"resources":["00000000-0000-4000-8000-000000000001",`+
+			`"00000000-0000-4000-8000-000000000003"]}`))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated,
+			w.Code, w.Body.String())
+	}
+
+	getConfig := func() map[string]any {
+		t.Helper()
+
+		w := serve(t, svr, http.MethodGet, basePath+"/agents/agent-1/config",
+			sandbox.Token, nil)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK,
+				w.Code, w.Body.String())
+		}
+
+		res := map[string]any{}
+
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		return res
+	}
+
+	cfg := getConfig()
+
+	// The inactive resource is not included in the configuration.
+	if rs, ok := cfg["resources"].([]any); !ok || len(rs) != 1 {
+		t.Errorf("Expected 1 resource, got: %v", cfg["resources"])
+	}
+
+	version := cfg["version"]
+
+	w = serve(t, svr, http.MethodPost, basePath+"/agents/agent-1/config/pin",
+		sandbox.Token, bytes.NewBufferString(`{"version":"invalid"}`))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Code expected: %v, got: %v", http.StatusConflict, w.Code)
+	}
+
+	w = serve(t, svr, http.MethodPost, basePath+"/agents/agent-1/config/pin",
+		sandbox.Token, bytes.NewBufferString(""))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	w = serve(t, svr, http.MethodPatch,
+		basePath+"/resources/00000000-0000-4000-8000-000000000001",
+		sandbox.Token, bytes.NewBufferString(`{"key_field":"host"}`))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK,
+			w.Code, w.Body.String())
+	}
+
+	if cfg = getConfig(); cfg["version"] != version || cfg["pinned"] != true {
+		t.Errorf("Expected pinned version: %v, got: %v", version, cfg)
+	}
+
+	w = serve(t, svr, http.MethodDelete,
+		basePath+"/agents/agent-1/config/pin", sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	if cfg = getConfig(); cfg["version"] == version || cfg["pinned"] != false {
+		t.Errorf("Expected new unpinned version, got: %v", cfg)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodPost, basePath+"/login/token", "", nil)
+
+	if w.Code != http.StatusBadRequest ||
+		!strings.Contains(w.Body.String(), `"field":"username"`) {
+		t.Errorf("Code expected: %v, got: %v: %v", http.StatusBadRequest,
+			w.Code, w.Body.String())
+	}
+
+	r, err := http.NewRequest(http.MethodPost, basePath+"/login/token",
+		strings.NewReader(url.Values{
+			"username": {sandbox.UserID},
+			"password": {"invalid"},
+		}.Encode()))
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Code expected: %v, got: %v", http.StatusUnauthorized, w.Code)
+	}
+
+	r, err = http.NewRequest(http.MethodPost, basePath+"/login/token",
+		strings.NewReader(url.Values{
+			"username": {sandbox.UserID},
+			"password": {sandbox.Password},
+			"scope":    {"superuser"},
+		}.Encode()))
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK, w.Code,
+			w.Body.String())
+	}
+
+	res := map[string]any{}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, _ := res["access_token"].(string)
+	if tok != sandbox.Token+"-1" {
+		t.Fatalf("Expected token: %v, got: %v", sandbox.Token+"-1", tok)
+	}
+
+	w = serve(t, svr, http.MethodGet, basePath+"/user", tok, nil)
+
+	exp := `"user_id":"` + sandbox.UserID + `"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	w = serve(t, svr, http.MethodGet, basePath+"/tokens", tok, nil)
+
+	exp = `"use_count":2`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+}
+
+func TestSession(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	r, err := http.NewRequest(http.MethodPost, basePath+"/auth/session",
+		strings.NewReader(url.Values{
+			"username": {sandbox.UserID},
+			"password": {sandbox.Password},
+		}.Encode()))
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusCreated, w.Code,
+			w.Body.String())
+	}
+
+	cookies := w.Result().Cookies()
+
+	if len(cookies) != 2 || !cookies[0].HttpOnly || cookies[1].HttpOnly {
+		t.Fatalf("Expected session and CSRF cookies, got: %v", cookies)
+	}
+
+	exp := `"csrf_token":"` + cookies[1].Value + `"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	send := func(method, path, csrf string) int {
+		r, err := http.NewRequest(method, basePath+path, nil)
+		if err != nil {
+			t.Fatal("Failed to initialize request", err)
+		}
+
+		r.AddCookie(cookies[0])
+
+		if csrf != "" {
+			r.Header.Set("X-CSRF-Token", csrf)
+		}
+
+		w := httptest.NewRecorder()
+
+		svr.Mux(w, r)
+
+		return w.Code
+	}
+
+	for _, tt := range []struct {
+		method, path, csrf string
+		code               int
+	}{
+		{http.MethodGet, "/user", "", http.StatusOK},
+		{http.MethodPost, "/auth/logout", "", http.StatusForbidden},
+		{http.MethodPost, "/auth/logout", "invalid", http.StatusForbidden},
+		{http.MethodPost, "/auth/logout", cookies[1].Value,
+			http.StatusNoContent},
+		{http.MethodGet, "/user", "", http.StatusUnauthorized},
+	} {
+		if code := send(tt.method, tt.path, tt.csrf); code != tt.code {
+			t.Errorf("%v %v code expected: %v, got: %v",
+				tt.method, tt.path, tt.code, code)
+		}
+	}
+}
+
+func TestLocalLogin(t *testing.T) {
+	t.Parallel()
+
+	login := func(svr *server.Server,
+		password string,
+	) *httptest.ResponseRecorder {
+		r, err := http.NewRequest(http.MethodPost, basePath+"/auth/login",
+			strings.NewReader(url.Values{
+				"username": {sandbox.UserID},
+				"password": {password},
+			}.Encode()))
+		if err != nil {
+			t.Fatal("Failed to initialize request", err)
+		}
+
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+
+		svr.Mux(w, r)
+
+		return w
+	}
+
+	w := login(newServer(t), sandbox.Password)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Disabled code expected: %v, got: %v", http.StatusNotFound,
+			w.Code)
+	}
+
+	ac := &config.AuthConfig{LocalEnabled: true, LocalRateLimit: 4}
+
+	ac.Load()
+
+	cfg := config.NewDefault()
+
+	cfg.SetAuth(ac)
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(sandbox.NewDB())
+
+	svr.SetAuthService(sandbox.NewAuthService())
+
+	if w := login(svr, "invalid"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Code expected: %v, got: %v", http.StatusUnauthorized, w.Code)
+	}
+
+	w = login(svr, sandbox.Password)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK, w.Code,
+			w.Body.String())
+	}
+
+	res := map[string]any{}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, _ := res["access_token"].(string)
+
+	w = serve(t, svr, http.MethodPost, basePath+"/auth/password", tok,
+		strings.NewReader(`{"current_password":"`+sandbox.Password+
+			`","password":"new-password"}`))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusNoContent,
+			w.Code, w.Body.String())
+	}
+
+	if w := login(svr, "new-password"); w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	w = login(svr, "new-password")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Code expected: %v, got: %v", http.StatusTooManyRequests,
+			w.Code)
+	}
+
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Expected Retry-After header")
+	}
+}
+
+func TestAccountUsage(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	for _, key := range []string{"", "retried", "retried"} {
+		r, err := http.NewRequest(http.MethodGet, basePath+"/user", nil)
+		if err != nil {
+			t.Fatal("Failed to initialize request", err)
+		}
+
+		r.Header.Set("Authorization", sandbox.Token)
+
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+
+		svr.Mux(httptest.NewRecorder(), r)
+	}
+
+	w := serve(t, svr, http.MethodGet, basePath+"/account/usage",
+		sandbox.Token, nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	exp := `"operation":"GET /user","requests":2`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+}
+
+func TestDeactivateAccount(t *testing.T) {
+	t.Parallel()
+
+	svr := newServer(t)
+
+	w := serve(t, svr, http.MethodGet, basePath+"/accounts", sandbox.Token,
+		nil)
+
+	exp := `"account_id":"` + sandbox.AccountID + `"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	r, err := http.NewRequest(http.MethodPost, basePath+"/login/token",
+		strings.NewReader(url.Values{
+			"username": {sandbox.UserID},
+			"password": {sandbox.Password},
+			"scope":    {"user:read"},
+		}.Encode()))
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w = httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK, w.Code,
+			w.Body.String())
+	}
+
+	res := map[string]any{}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	tok, _ := res["access_token"].(string)
+
+	w = serve(t, svr, http.MethodPost,
+		basePath+"/accounts/"+sandbox.AccountID+"/deactivate", tok, nil)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Code expected: %v, got: %v", http.StatusForbidden, w.Code)
+	}
+
+	w = serve(t, svr, http.MethodPost,
+		basePath+"/accounts/"+sandbox.AccountID+"/deactivate", sandbox.Token,
+		nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v: %v", http.StatusOK, w.Code,
+			w.Body.String())
+	}
+
+	exp = `"status":"inactive"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	for _, tok := range []string{tok, sandbox.Token} {
+		w = serve(t, svr, http.MethodGet, basePath+"/user", tok, nil)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Code expected: %v, got: %v", http.StatusUnauthorized,
+				w.Code)
+		}
+	}
+}
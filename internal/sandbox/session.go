@@ -0,0 +1,71 @@
+package sandbox
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// CreateSession creates a new sandbox session, with a token created as for
+// CreateToken. Sessions are numbered sequentially, so the sessions created by
+// a sequence of requests are deterministic.
+func (s *AuthService) CreateSession(ctx context.Context,
+	userID, scopes, tenant string,
+) (*auth.Session, error) {
+	expiresAt := time.Now().Add(time.Hour * 24).Unix()
+
+	tok, err := s.CreateToken(ctx, userID, expiresAt, scopes, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	n := strconv.Itoa(len(s.sessions) + 1)
+
+	res := &auth.Session{
+		SessionID: "session-" + n,
+		UserID:    userID,
+		Tenant:    tenant,
+		Token:     tok,
+		CSRFToken: "csrf-" + n,
+		ExpiresAt: expiresAt,
+	}
+
+	s.sessions[res.SessionID] = res
+
+	c := *res
+
+	return &c, nil
+}
+
+// AuthSession retrieves an unexpired sandbox session.
+func (s *AuthService) AuthSession(ctx context.Context,
+	id string,
+) (*auth.Session, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	res, ok := s.sessions[id]
+	if !ok || time.Now().Unix() >= res.ExpiresAt {
+		return nil, errors.New(errors.ErrUnauthorized, "invalid session")
+	}
+
+	c := *res
+
+	return &c, nil
+}
+
+// DeleteSession deletes a sandbox session.
+func (s *AuthService) DeleteSession(ctx context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.sessions, id)
+
+	return nil
+}
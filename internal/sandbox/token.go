@@ -0,0 +1,74 @@
+package sandbox
+
+import (
+	"context"
+	"sort"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// GetTokens retrieves the tokens created for the account, in the order they
+// were created. Users holding the user:admin scope retrieve the tokens of
+// every user, and other users only their own. Search queries are not
+// evaluated for tokens in the sandbox. As with the database, one more token
+// than the query size is returned, when available.
+func (s *AuthService) GetTokens(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*auth.IssuedToken, error) {
+	if query == nil {
+		query = &search.Query{}
+	}
+
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := ""
+
+	if !request.ContextHasScope(ctx, request.ScopeUserAdmin) {
+		if userID, err = request.ContextUserID(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	list := []*auth.IssuedToken{}
+
+	for tok, it := range s.issued {
+		if s.tokens[tok].AccountID != accountID ||
+			(userID != "" && it.UserID.Value != userID) {
+			continue
+		}
+
+		list = append(list, clone(it))
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].TokenID.Value < list[j].TokenID.Value
+	})
+
+	size := query.Size
+	if size == 0 {
+		size = config.DefaultDBDefaultSize
+	}
+
+	if query.Skip >= int64(len(list)) {
+		list = nil
+	} else {
+		list = list[query.Skip:]
+	}
+
+	if int64(len(list)) > size+1 {
+		list = list[:size+1]
+	}
+
+	return list, nil
+}
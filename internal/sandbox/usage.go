@@ -0,0 +1,138 @@
+package sandbox
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// RecordRequest counts a request made by the account of the context to an API
+// operation, and the bytes of its body. Requests with the same idempotency key
+// are counted once, but the bytes of every request are counted.
+func (s *AuthService) RecordRequest(ctx context.Context,
+	operation, key string,
+	bytes int64,
+) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil || accountID == "" {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	c := auth.RequestCount{
+		Day:       time.Now().UTC().Format(time.DateOnly),
+		Operation: operation,
+	}
+
+	if bytes > 0 {
+		if s.ingest[accountID] == nil {
+			s.ingest[accountID] = map[auth.RequestCount]int64{}
+		}
+
+		s.ingest[accountID][c] += bytes
+	}
+
+	if key != "" {
+		if s.keys[accountID] == nil {
+			s.keys[accountID] = map[string]bool{}
+		}
+
+		if s.keys[accountID][key] {
+			return
+		}
+
+		s.keys[accountID][key] = true
+	}
+
+	if s.requests[accountID] == nil {
+		s.requests[accountID] = map[auth.RequestCount]int64{}
+	}
+
+	s.requests[accountID][c]++
+}
+
+// GetRequestUsage retrieves the requests made by the account between two UTC
+// days, ordered by day and operation. Quotas are not enforced in the sandbox,
+// and its resource data is not counted as storage.
+func (s *AuthService) GetRequestUsage(ctx context.Context,
+	from, to string,
+) (*auth.RequestUsage, error) {
+	accountID, err := request.ContextAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if from, to, err = auth.ParseUsageRange(from, to); err != nil {
+		return nil, err
+	}
+
+	s.RLock()
+	defer s.RUnlock()
+
+	res := &auth.RequestUsage{
+		From:   from,
+		To:     to,
+		Counts: []*auth.RequestCount{},
+	}
+
+	counts := map[auth.RequestCount]*auth.RequestCount{}
+
+	for c, n := range s.requests[accountID] {
+		if c.Day < from || c.Day > to {
+			continue
+		}
+
+		counts[c] = &auth.RequestCount{
+			Day:       c.Day,
+			Operation: c.Operation,
+			Requests:  n,
+		}
+
+		res.Requests += n
+	}
+
+	for c, n := range s.ingest[accountID] {
+		if c.Day < from || c.Day > to {
+			continue
+		}
+
+		if counts[c] == nil {
+			counts[c] = &auth.RequestCount{Day: c.Day, Operation: c.Operation}
+		}
+
+		counts[c].IngestBytes = n
+
+		res.IngestBytes += n
+	}
+
+	for _, c := range counts {
+		res.Counts = append(res.Counts, c)
+	}
+
+	sort.Slice(res.Counts, func(i, j int) bool {
+		if res.Counts[i].Day != res.Counts[j].Day {
+			return res.Counts[i].Day < res.Counts[j].Day
+		}
+
+		return res.Counts[i].Operation < res.Counts[j].Operation
+	})
+
+	return res, nil
+}
+
+// UpdateRequestUsage does nothing, since sandbox requests are recorded
+// immediately.
+func (s *AuthService) UpdateRequestUsage(ctx context.Context,
+) context.CancelFunc {
+	return func() {}
+}
+
+// CheckQuota does nothing, since quotas are not enforced in the sandbox.
+func (s *AuthService) CheckQuota(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,736 @@
+package search
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// LookupPath retrieves the value of a, possibly nested, field of a data item.
+// Path elements are separated by periods, and array elements are selected
+// using an index suffix, such as items[0].name. Arrays are returned whole when
+// no index is given.
+func LookupPath(am map[string]any, path string) (any, bool) {
+	var v any = am
+
+	for _, key := range strings.Split(path, ".") {
+		index := -1
+
+		if strings.HasSuffix(key, "]") {
+			startIdx := strings.LastIndex(key, "[")
+
+			endIdx := strings.LastIndex(key, "]")
+
+			if startIdx > 0 && endIdx > 0 && startIdx < endIdx {
+				idx := key[startIdx+1 : endIdx]
+
+				iv, err := strconv.ParseInt(idx, 10, 64)
+				if err == nil {
+					index = int(iv)
+
+					key = key[:startIdx]
+				}
+			}
+		}
+
+		vt, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		vv, ok := vt[key]
+		if !ok {
+			return nil, false
+		}
+
+		switch vvt := vv.(type) {
+		case []any:
+			if index >= len(vvt) {
+				return nil, false
+			}
+
+			if index < 0 {
+				v = vvt
+
+				continue
+			}
+
+			v = vvt[index]
+		case []map[string]any:
+			if index >= len(vvt) {
+				return nil, false
+			}
+
+			if index < 0 {
+				v = vvt
+
+				continue
+			}
+
+			v = vvt[index]
+		default:
+			v = vvt
+		}
+	}
+
+	return v, true
+}
+
+// IsExpression determines whether a condition category is an expression,
+// containing function calls, arithmetic, comparisons or string literals, rather
+// than the path of a field. Subtraction requires spaces around the operator,
+// since field names may contain hyphens.
+func IsExpression(cat string) bool {
+	return strings.ContainsAny(cat, "()+*/'=<>") ||
+		strings.Contains(cat, " - ")
+}
+
+// aggregateRE matches calls to the aggregate functions of condition
+// expressions.
+var aggregateRE = regexp.MustCompile(`\b(count|sum|avg|min|max|all|any)\s*\(`)
+
+// HasAggregate determines whether a condition contains calls to aggregate
+// functions, which are evaluated over the whole data set of a resource.
+func HasAggregate(condition string) bool {
+	return aggregateRE.MatchString(condition)
+}
+
+// EvalExpression evaluates a condition category expression against a data
+// item. Expressions may contain field paths, numbers, single quoted strings,
+// the arithmetic operators +, -, * and /, the comparison operators =, !=, <,
+// <=, > and >=, parentheses, and calls to the functions abs, len, lower,
+// contains and age. Missing fields, and arithmetic on values which are not
+// numbers, evaluate to null. The aggregate functions count, sum, avg, min, max,
+// all and any evaluate their argument against each item of the data set, and
+// combine the results.
+func EvalExpression(am map[string]any,
+	set []map[string]any,
+	expr string,
+) (any, error) {
+	p := &exprParser{expr: expr, am: am, set: set}
+
+	v, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.skipSpace(); p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected %q", p.expr[p.pos:])
+	}
+
+	return v, nil
+}
+
+// exprParser values are used to evaluate condition category expressions.
+// Aggregate function arguments are parsed without a data set, so that nested
+// aggregates are reported as errors.
+type exprParser struct {
+	expr      string
+	pos       int
+	am        map[string]any
+	set       []map[string]any
+	aggregate bool
+}
+
+// errorf returns an invalid expression error.
+func (p *exprParser) errorf(format string, args ...any) error {
+	return errors.New(errors.ErrInvalidRequest,
+		"invalid condition expression: "+fmt.Sprintf(format, args...),
+		"expression", p.expr,
+		"position", p.pos)
+}
+
+// peek returns the next rune of the expression, without consuming it.
+func (p *exprParser) peek() rune {
+	if p.pos >= len(p.expr) {
+		return 0
+	}
+
+	r, _ := utf8.DecodeRuneInString(p.expr[p.pos:])
+
+	return r
+}
+
+// skipSpace consumes any whitespace at the current position.
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.expr) && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// parseComparison parses and evaluates comparisons.
+func (p *exprParser) parseComparison() (any, error) {
+	v, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpace()
+
+	op := ""
+
+	for _, o := range []string{"!=", "<=", ">=", "=", "<", ">"} {
+		if strings.HasPrefix(p.expr[p.pos:], o) {
+			op = o
+
+			break
+		}
+	}
+
+	if op == "" {
+		return v, nil
+	}
+
+	p.pos += len(op)
+
+	r, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	return compare(op, v, r), nil
+}
+
+// parseSum parses and evaluates addition and subtraction.
+func (p *exprParser) parseSum() (any, error) {
+	v, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return v, nil
+		}
+
+		p.pos++
+
+		r, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+
+		v = arithmetic(op, v, r)
+	}
+}
+
+// parseProduct parses and evaluates multiplication and division.
+func (p *exprParser) parseProduct() (any, error) {
+	v, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpace()
+
+		op := p.peek()
+		if op != '*' && op != '/' {
+			return v, nil
+		}
+
+		p.pos++
+
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		v = arithmetic(op, v, r)
+	}
+}
+
+// parseUnary parses and evaluates negation.
+func (p *exprParser) parseUnary() (any, error) {
+	p.skipSpace()
+
+	if p.peek() != '-' {
+		return p.parseOperand()
+	}
+
+	p.pos++
+
+	v, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	return arithmetic('-', int64(0), v), nil
+}
+
+// parseOperand parses and evaluates a parenthesized expression, literal, field
+// path or function call.
+func (p *exprParser) parseOperand() (any, error) {
+	p.skipSpace()
+
+	switch ch := p.peek(); {
+	case ch == 0:
+		return nil, p.errorf("unexpected end of expression")
+	case ch == '(':
+		p.pos++
+
+		v, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		if p.skipSpace(); p.peek() != ')' {
+			return nil, p.errorf("expecting )")
+		}
+
+		p.pos++
+
+		return v, nil
+	case ch == '\'':
+		end := strings.IndexRune(p.expr[p.pos+1:], '\'')
+		if end < 0 {
+			return nil, p.errorf("unterminated string")
+		}
+
+		v := p.expr[p.pos+1 : p.pos+1+end]
+
+		p.pos += end + 2
+
+		return v, nil
+	case ch >= '0' && ch <= '9' || ch == '.':
+		start := p.pos
+
+		for p.pos < len(p.expr) && strings.ContainsRune("0123456789.eE",
+			p.peek()) {
+			p.pos++
+		}
+
+		lit := p.expr[start:p.pos]
+
+		if i, err := strconv.ParseInt(lit, 10, 64); err == nil {
+			return i, nil
+		}
+
+		f, err := strconv.ParseFloat(lit, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", lit)
+		}
+
+		return f, nil
+	}
+
+	start := p.pos
+
+	for p.pos < len(p.expr) && !strings.ContainsRune(" \t+*/(),'=<>!",
+		p.peek()) {
+		_, n := utf8.DecodeRuneInString(p.expr[p.pos:])
+
+		p.pos += n
+	}
+
+	name := p.expr[start:p.pos]
+
+	if name == "" {
+		return nil, p.errorf("unexpected %q", string(p.peek()))
+	}
+
+	if p.skipSpace(); p.peek() != '(' {
+		v, _ := LookupPath(p.am, name)
+
+		return v, nil
+	}
+
+	p.pos++
+
+	if aggregateRE.MatchString(name + "(") {
+		return p.callAggregate(name)
+	}
+
+	args := []any{}
+
+	if p.skipSpace(); p.peek() == ')' {
+		p.pos++
+	} else {
+		for {
+			v, err := p.parseComparison()
+			if err != nil {
+				return nil, err
+			}
+
+			args = append(args, v)
+
+			p.skipSpace()
+
+			if p.peek() == ')' {
+				p.pos++
+
+				break
+			}
+
+			if p.peek() != ',' {
+				return nil, p.errorf("expecting , or ) in call to %s", name)
+			}
+
+			p.pos++
+		}
+	}
+
+	return p.call(name, args)
+}
+
+// call evaluates a call to a condition expression function.
+func (p *exprParser) call(name string, args []any) (any, error) {
+	n := 1
+
+	if name == "contains" {
+		n = 2
+	}
+
+	switch name {
+	case "abs", "len", "lower", "contains", "age":
+		if len(args) != n {
+			return nil, p.errorf("%s requires %d argument(s), got: %d",
+				name, n, len(args))
+		}
+	default:
+		return nil, p.errorf("unknown function %s", name)
+	}
+
+	switch name {
+	case "abs":
+		switch v := number(args[0]).(type) {
+		case int64:
+			if v < 0 {
+				return -v, nil
+			}
+
+			return v, nil
+		case float64:
+			return math.Abs(v), nil
+		}
+
+		return nil, nil
+	case "len":
+		switch v := args[0].(type) {
+		case string:
+			return int64(utf8.RuneCountInString(v)), nil
+		case []any:
+			return int64(len(v)), nil
+		case map[string]any:
+			return int64(len(v)), nil
+		case nil:
+			return int64(0), nil
+		}
+
+		return nil, nil
+	case "lower":
+		if v, ok := args[0].(string); ok {
+			return strings.ToLower(v), nil
+		}
+
+		return nil, nil
+	case "contains":
+		sub := fmt.Sprint(number(args[1]))
+
+		switch v := args[0].(type) {
+		case string:
+			return strings.Contains(v, sub), nil
+		case []any:
+			for _, e := range v {
+				if fmt.Sprint(number(e)) == sub {
+					return true, nil
+				}
+			}
+		}
+
+		return false, nil
+	default:
+		ts, ok := timestamp(args[0])
+		if !ok {
+			return nil, nil
+		}
+
+		return time.Now().Unix() - ts, nil
+	}
+}
+
+// callAggregate evaluates a call to a condition expression aggregate function.
+// The argument is parsed once, without a data item, to find its extent, and is
+// then evaluated against each item of the data set.
+func (p *exprParser) callAggregate(name string) (any, error) {
+	if p.aggregate {
+		return nil, p.errorf("nested aggregate function %s", name)
+	}
+
+	start := p.pos
+
+	sp := &exprParser{expr: p.expr, pos: p.pos, am: map[string]any{},
+		aggregate: true}
+
+	arg := ""
+
+	if sp.skipSpace(); sp.peek() != ')' {
+		if _, err := sp.parseComparison(); err != nil {
+			return nil, err
+		}
+
+		arg = p.expr[start:sp.pos]
+	}
+
+	if sp.skipSpace(); sp.peek() != ')' {
+		return nil, sp.errorf("%s requires a single argument", name)
+	}
+
+	p.pos = sp.pos + 1
+
+	if arg == "" && name != "count" {
+		return nil, p.errorf("%s requires 1 argument(s), got: 0", name)
+	}
+
+	vals := make([]any, 0, len(p.set))
+
+	for _, am := range p.set {
+		if arg == "" {
+			vals = append(vals, true)
+
+			continue
+		}
+
+		ip := &exprParser{expr: arg, am: am, aggregate: true}
+
+		v, err := ip.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		vals = append(vals, v)
+	}
+
+	switch name {
+	case "count", "all", "any":
+		n := int64(0)
+
+		for _, v := range vals {
+			if b, ok := v.(bool); ok && b {
+				n++
+			}
+		}
+
+		switch name {
+		case "all":
+			return n == int64(len(vals)), nil
+		case "any":
+			return n > 0, nil
+		}
+
+		return n, nil
+	}
+
+	var res any
+
+	n := 0
+
+	for _, v := range vals {
+		v = number(v)
+
+		switch v.(type) {
+		case int64, float64:
+		default:
+			continue
+		}
+
+		n++
+
+		switch {
+		case res == nil:
+			res = v
+		case name == "min":
+			if compare("<", v, res) == true {
+				res = v
+			}
+		case name == "max":
+			if compare(">", v, res) == true {
+				res = v
+			}
+		default:
+			res = arithmetic('+', res, v)
+		}
+	}
+
+	if name == "avg" && res != nil {
+		return arithmetic('/', arithmetic('*', res, 1.0), int64(n)), nil
+	}
+
+	return res, nil
+}
+
+// compare applies a comparison operator to two values. Numbers are compared
+// numerically, and strings lexically. Values of other types may only be
+// compared for equality. The result is null if the values can not be ordered.
+func compare(op string, l, r any) any {
+	l, r = number(l), number(r)
+
+	c, ok := 0, false
+
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+
+	lf, lNum := float(l)
+	rf, rNum := float(r)
+
+	ls, lStr := l.(string)
+	rs, rStr := r.(string)
+
+	switch {
+	case lInt && rInt:
+		c, ok = cmp.Compare(li, ri), true
+	case lNum && rNum:
+		c, ok = cmp.Compare(lf, rf), true
+	case lStr && rStr:
+		c, ok = strings.Compare(ls, rs), true
+	}
+
+	if !ok {
+		eq := fmt.Sprint(l) == fmt.Sprint(r)
+
+		switch op {
+		case "=":
+			return eq
+		case "!=":
+			return !eq
+		}
+
+		return nil
+	}
+
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// float converts an integer or floating point number into a float64.
+func float(v any) (float64, bool) {
+	switch vt := v.(type) {
+	case int64:
+		return float64(vt), true
+	case float64:
+		return vt, true
+	}
+
+	return 0, false
+}
+
+// number converts a JSON number into an int64, where it is an integer, or a
+// float64. Other values are returned unchanged.
+func number(v any) any {
+	switch vt := v.(type) {
+	case json.Number:
+		if i, err := vt.Int64(); err == nil {
+			return i
+		}
+
+		if f, err := vt.Float64(); err == nil {
+			return f
+		}
+	case int:
+		return int64(vt)
+	}
+
+	return v
+}
+
+// timestamp converts a Unix timestamp, or an RFC3339 formatted time, into a
+// Unix timestamp.
+func timestamp(v any) (int64, bool) {
+	switch vt := number(v).(type) {
+	case int64:
+		return vt, true
+	case float64:
+		return int64(vt), true
+	case string:
+		t, err := time.Parse(time.RFC3339, vt)
+		if err != nil {
+			return 0, false
+		}
+
+		return t.Unix(), true
+	}
+
+	return 0, false
+}
+
+// arithmetic applies an arithmetic operator to two values. Integers are used
+// where both values are integers, except for division, which is exact. The
+// result is null if either value is not a number, or on division by zero.
+func arithmetic(op rune, l, r any) any {
+	l, r = number(l), number(r)
+
+	li, lInt := l.(int64)
+	ri, rInt := r.(int64)
+
+	if lInt && rInt && op != '/' {
+		switch op {
+		case '+':
+			return li + ri
+		case '-':
+			return li - ri
+		default:
+			return li * ri
+		}
+	}
+
+	lf, ok := l.(float64)
+	if lInt {
+		lf, ok = float64(li), true
+	}
+
+	if !ok {
+		return nil
+	}
+
+	rf, ok := r.(float64)
+	if rInt {
+		rf, ok = float64(ri), true
+	}
+
+	if !ok {
+		return nil
+	}
+
+	switch op {
+	case '+':
+		return lf + rf
+	case '-':
+		return lf - rf
+	case '*':
+		return lf * rf
+	default:
+		if rf == 0 {
+			return nil
+		}
+
+		if lInt && rInt && li%ri == 0 {
+			return li / ri
+		}
+
+		return lf / rf
+	}
+}
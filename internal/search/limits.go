@@ -0,0 +1,119 @@
+package search
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// Limits values contain the limits on the complexity of search queries, which
+// are used to reject queries that would be too costly to perform. Zero values
+// do not limit the query.
+type Limits struct {
+	MaxDepth        int  `json:"max_depth,omitempty"`
+	MaxTerms        int  `json:"max_terms,omitempty"`
+	DenyLeadingWild bool `json:"deny_leading_wildcards,omitempty"`
+}
+
+// Depth returns the number of levels of nested operations in the query node.
+// Match nodes have no depth.
+func (qn *QueryNode) Depth() int {
+	if qn == nil || qn.Op == OpMatch {
+		return 0
+	}
+
+	d := 0
+
+	for _, n := range qn.Nodes {
+		d = max(d, n.Depth())
+	}
+
+	return d + 1
+}
+
+// Terms returns the number of match nodes in the query node.
+func (qn *QueryNode) Terms() int {
+	if qn == nil {
+		return 0
+	}
+
+	if qn.Op == OpMatch {
+		return 1
+	}
+
+	t := 0
+
+	for _, n := range qn.Nodes {
+		t += n.Terms()
+	}
+
+	return t
+}
+
+// LeadingWildcard determines whether the query node, or any of its children,
+// matches a value beginning with an unescaped wildcard character. Such values
+// can not be matched using an index, and require every row to be scanned.
+func (qn *QueryNode) LeadingWildcard() bool {
+	if qn == nil {
+		return false
+	}
+
+	if qn.Op == OpMatch {
+		return strings.HasPrefix(qn.Val, "*") || strings.HasPrefix(qn.Val, "?")
+	}
+
+	for _, n := range qn.Nodes {
+		if n.LeadingWildcard() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check returns an invalid request error if the query tree exceeds any of the
+// limits.
+func (qt *QueryTree) Check(l *Limits) error {
+	if qt == nil || l == nil {
+		return nil
+	}
+
+	if d := qt.Root.Depth(); l.MaxDepth > 0 && d > l.MaxDepth {
+		return errors.New(errors.ErrInvalidRequest,
+			"search query too deeply nested",
+			"depth", d,
+			"max_depth", l.MaxDepth)
+	}
+
+	if t := qt.Root.Terms(); l.MaxTerms > 0 && t > l.MaxTerms {
+		return errors.New(errors.ErrInvalidRequest,
+			"search query has too many terms",
+			"terms", t,
+			"max_terms", l.MaxTerms)
+	}
+
+	if l.DenyLeadingWild && qt.Root.LeadingWildcard() {
+		return errors.New(errors.ErrInvalidRequest,
+			"search query values must not begin with a wildcard")
+	}
+
+	return nil
+}
+
+// Check parses the search of the query, and returns an invalid request error
+// if it can not be parsed or exceeds any of the limits.
+func (q *Query) Check(l *Limits) error {
+	if q == nil || q.Search == "" || l == nil {
+		return nil
+	}
+
+	ast, err := NewParser(bytes.NewBufferString(q.Search)).Parse()
+	if err != nil {
+		return errors.Wrap(err, errors.ErrInvalidRequest,
+			"invalid search query",
+			"search", q.Search)
+	}
+
+	return ast.Check(l)
+}
@@ -0,0 +1,74 @@
+package search_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/search"
+)
+
+func TestQueryCheck(t *testing.T) {
+	t.Parallel()
+
+	limits := &search.Limits{
+		MaxDepth:        3,
+		MaxTerms:        4,
+		DenyLeadingWild: true,
+	}
+
+	tests := []struct {
+		name   string
+		search string
+		err    bool
+	}{{
+		name:   "within limits",
+		search: "and(name:test*,or(status:active,status:error))",
+	}, {
+		name:   "quoted wildcard",
+		search: `name:"*test"`,
+	}, {
+		name:   "too deep",
+		search: "and(or(and(not(name:test))))",
+		err:    true,
+	}, {
+		name:   "too many terms",
+		search: "in(status:a,b,c,d,e)",
+		err:    true,
+	}, {
+		name:   "leading wildcard",
+		search: "and(name:*test)",
+		err:    true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := (&search.Query{Search: tt.search}).Check(limits)
+			if tt.err && !errors.Has(err, errors.ErrInvalidRequest) {
+				t.Errorf("Expected invalid request error, got: %v", err)
+			} else if !tt.err && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestQueryNodeDepth(t *testing.T) {
+	t.Parallel()
+
+	qt, err := search.NewParser(bytes.NewBufferString(
+		"and(name:test,or(status:active,not(status:error)))")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := qt.Root.Depth(); d != 4 {
+		t.Errorf("Expected depth: 4, got: %v", d)
+	}
+
+	if n := qt.Root.Terms(); n != 3 {
+		t.Errorf("Expected terms: 3, got: %v", n)
+	}
+}
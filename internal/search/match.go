@@ -0,0 +1,237 @@
+package search
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MatchItem evaluates the query tree against a data item, such as a resource
+// data item being tested by a clear_condition. Aggregate functions in the
+// query are evaluated over the items of the data set.
+func (qt *QueryTree) MatchItem(am map[string]any,
+	set []map[string]any,
+) (bool, error) {
+	return qt.Eval(func(node *QueryNode) (bool, error) {
+		return node.MatchItem(am, set)
+	})
+}
+
+// MatchItem evaluates a single match node against a data item. The category
+// is either the path of a field of the item, or an expression, which is
+// evaluated using EvalExpression. Numbers are compared numerically, using the
+// comparison of the node, strings are matched using wildcard patterns or
+// regular expressions, and booleans and nulls are compared for equality.
+// Numbers compared with values which are not numbers are compared with the
+// value of the field, or expression, of the item named by the value, so that
+// gt(used:limit) compares two fields of the item. Nodes within exists() match
+// items containing the field, even if its value is null.
+func (qn *QueryNode) MatchItem(am map[string]any,
+	set []map[string]any,
+) (bool, error) {
+	getValInt64 := func(cat, val string) (int64, error) {
+		r, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, errors.Wrap(err,
+				errors.ErrInvalidRequest,
+				"invalid condition value for category",
+				"category", cat,
+				"value", val)
+		}
+
+		return r, nil
+	}
+
+	getValFloat64 := func(cat string,
+		val string,
+	) (float64, error) {
+		r, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return 0, errors.Wrap(err,
+				errors.ErrInvalidRequest,
+				"invalid condition value for category",
+				"category", cat,
+				"value", val)
+		}
+
+		return r, nil
+	}
+
+	op := qn.Comp
+
+	cat := strings.TrimSpace(qn.Cat)
+
+	val := strings.TrimSpace(qn.Val)
+
+	valExpr := val
+
+	valRegExp := qn.ValRE
+
+	if qn.CI {
+		val = Fold(val)
+
+		if valRegExp != "" {
+			valRegExp = "(?i)" + valRegExp
+		}
+	}
+
+	var valRE *regexp.Regexp
+
+	if valRegExp == "" && strings.Contains(val, "*") {
+		valRegExp = strings.ReplaceAll(val, "*", ".*")
+	}
+
+	if valRegExp != "" {
+		val = valRegExp
+
+		re, err := regexp.Compile(val)
+		if err != nil {
+			return false, errors.Wrap(err,
+				errors.ErrInvalidRequest,
+				"invalid condition value "+
+					"regular expression",
+				"value", val)
+		}
+
+		valRE = re
+	}
+
+	if strings.Split(cat, ".")[0] == "true" {
+		return true, nil
+	}
+
+	if op == OpExists {
+		if IsExpression(cat) {
+			ev, err := EvalExpression(am, set, cat)
+
+			return ev != nil, err
+		}
+
+		_, ok := LookupPath(am, cat)
+
+		return ok, nil
+	}
+
+	var v any
+
+	if IsExpression(cat) {
+		ev, err := EvalExpression(am, set, cat)
+		if err != nil {
+			return false, err
+		}
+
+		v = ev
+	} else {
+		lv, ok := LookupPath(am, cat)
+		if !ok {
+			return false, nil
+		}
+
+		v = lv
+	}
+
+	// Numbers are compared as integers where both
+	// values are integers, so that large integers are
+	// compared exactly.
+	v = number(v)
+
+	if i, ok := v.(int64); ok {
+		if _, err := strconv.ParseInt(val, 10,
+			64); err != nil {
+			v = float64(i)
+		}
+	}
+
+	switch vt := v.(type) {
+	case nil:
+		return val == "null" || val == "", nil
+	case bool:
+		if op != OpMatch {
+			return false, errors.New(
+				errors.ErrInvalidRequest,
+				"invalid condition operator for category",
+				"category", cat,
+				"operator", op)
+		}
+
+		return val == strconv.FormatBool(vt), nil
+	case float64, int64:
+		var r any
+
+		var err error
+
+		if _, ok := vt.(int64); ok {
+			r, err = getValInt64(cat, val)
+		} else {
+			r, err = getValFloat64(cat, val)
+		}
+
+		if err != nil {
+			ev, eErr := EvalExpression(am, set, valExpr)
+			if eErr != nil {
+				return false, err
+			}
+
+			switch ev := number(ev).(type) {
+			case int64, float64:
+				r = ev
+			default:
+				return false, err
+			}
+		}
+
+		o := op.Operator()
+		if o == "" {
+			return false, errors.New(
+				errors.ErrInvalidRequest,
+				"invalid condition operator for category",
+				"category", cat,
+				"operator", op)
+		}
+
+		return compare(o, vt, r) == true, nil
+	case string:
+		if qn.CI {
+			vt = Fold(vt)
+		}
+
+		if valRE != nil {
+			return valRE.MatchString(vt), nil
+		}
+
+		m, err := filepath.Match(val, vt)
+		if err != nil {
+			return false, errors.Wrap(err,
+				errors.ErrInvalidRequest,
+				"invalid value pattern for category",
+				"category", cat,
+				"value", val,
+				"operator", op)
+		}
+
+		return m, nil
+	}
+
+	return false, nil
+}
+
+// Fold returns a string with its case and accents folded, so that strings which
+// differ only by case or accents, such as "Café" and "cafe", are equal. String
+// values within ci() are compared after folding.
+func Fold(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)),
+		norm.NFC)
+
+	if r, _, err := transform.String(t, s); err == nil {
+		s = r
+	}
+
+	return strings.ToLower(s)
+}
@@ -0,0 +1,200 @@
+package search_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/search"
+)
+
+func TestMatchItem(t *testing.T) {
+	t.Parallel()
+
+	am := map[string]any{
+		"name":  "Test Device",
+		"city":  "Café",
+		"count": json.Number("5"),
+		"temp":  json.Number("21.5"),
+		"ok":    true,
+		"items": []any{"a", "b"},
+		"limit": json.Number("4"),
+		"none":  nil,
+	}
+
+	set := []map[string]any{
+		am,
+		{"count": json.Number("2")},
+		{"count": json.Number("8")},
+	}
+
+	tests := []struct {
+		name      string
+		condition string
+		exp       bool
+		err       bool
+	}{{
+		name:      "match",
+		condition: "and(name:Test*)",
+		exp:       true,
+	}, {
+		name:      "regular expression",
+		condition: "and(name:/^test/)",
+		exp:       false,
+	}, {
+		name:      "integer comparison",
+		condition: "gt(count:4)",
+		exp:       true,
+	}, {
+		name:      "float comparison",
+		condition: "lte(temp:21)",
+		exp:       false,
+	}, {
+		name:      "boolean",
+		condition: "and(ok:true)",
+		exp:       true,
+	}, {
+		name:      "missing field",
+		condition: "and(missing:1)",
+		exp:       false,
+	}, {
+		name:      "expression",
+		condition: "and(len(items) + count:7)",
+		exp:       true,
+	}, {
+		name:      "aggregate",
+		condition: "gte(sum(count):15)",
+		exp:       true,
+	}, {
+		name:      "logical operations",
+		condition: "and(ok:true,not(lt(count:3)))",
+		exp:       true,
+	}, {
+		name:      "in list",
+		condition: "in(count:3,5,7)",
+		exp:       true,
+	}, {
+		name:      "range",
+		condition: "range(temp:20..21)",
+		exp:       false,
+	}, {
+		name:      "case insensitive",
+		condition: "ci(name:TEST*,city:cafe)",
+		exp:       true,
+	}, {
+		name:      "case sensitive",
+		condition: "and(name:TEST*)",
+		exp:       false,
+	}, {
+		name:      "exists",
+		condition: "exists(count,none,items[1])",
+		exp:       true,
+	}, {
+		name:      "not exists",
+		condition: "exists(count,missing)",
+		exp:       false,
+	}, {
+		name:      "exists expression",
+		condition: "exists(len(name))",
+		exp:       true,
+	}, {
+		name:      "in strings",
+		condition: "in(name:Other,Test*)",
+		exp:       true,
+	}, {
+		name:      "case insensitive regular expression",
+		condition: "and(name:/^test/i)",
+		exp:       true,
+	}, {
+		name:      "case insensitive regular expression in list",
+		condition: "in(name:other,/^TEST DEV/i)",
+		exp:       true,
+	}, {
+		name:      "field comparison",
+		condition: "gt(count:limit)",
+		exp:       true,
+	}, {
+		name:      "field comparison prefix",
+		condition: "and(temp:<limit)",
+		exp:       false,
+	}, {
+		name:      "field arithmetic comparison",
+		condition: "lt(count:limit * 2 - 3)",
+		exp:       false,
+	}, {
+		name:      "field expression comparison",
+		condition: "and(count - limit > 0:true)",
+		exp:       true,
+	}, {
+		name:      "missing comparison field",
+		condition: "and(exists(missing),gt(count:missing))",
+		exp:       false,
+	}, {
+		name:      "invalid value",
+		condition: "gt(count:test)",
+		err:       true,
+	}, {
+		name:      "invalid operator",
+		condition: "gt(ok:true)",
+		err:       true,
+	}, {
+		name:      "unknown function",
+		condition: "and(test(count):1)",
+		err:       true,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ast, err := search.NewParser(
+				bytes.NewBufferString(tt.condition)).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := ast.MatchItem(am, set)
+			if tt.err {
+				if err == nil {
+					t.Error("Expected error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res != tt.exp {
+				t.Errorf("Expected result: %v, got: %v", tt.exp, res)
+			}
+		})
+	}
+}
+
+func TestQueryOpOperator(t *testing.T) {
+	t.Parallel()
+
+	for op, exp := range map[search.QueryOp]string{
+		search.OpMatch: "=",
+		search.OpGT:    ">",
+		search.OpGTE:   ">=",
+		search.OpLT:    "<",
+		search.OpLTE:   "<=",
+		search.OpAnd:   "",
+		search.OpNot:   "",
+	} {
+		if res := op.Operator(); res != exp {
+			t.Errorf("Expected operator for %v: %q, got: %q", op, exp, res)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	t.Parallel()
+
+	if res := search.Fold("Crème Brûlée"); res != "creme brulee" {
+		t.Errorf("Expected folded string: creme brulee, got: %v", res)
+	}
+}
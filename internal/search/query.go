@@ -39,14 +39,18 @@ type QueryOp string
 
 // Query operation types.
 const (
-	OpMatch QueryOp = QueryOp("match")
-	OpAnd   QueryOp = QueryOp("and")
-	OpOr    QueryOp = QueryOp("or")
-	OpNot   QueryOp = QueryOp("not")
-	OpGT    QueryOp = QueryOp("gt")
-	OpGTE   QueryOp = QueryOp("gte")
-	OpLT    QueryOp = QueryOp("lt")
-	OpLTE   QueryOp = QueryOp("lte")
+	OpMatch  QueryOp = QueryOp("match")
+	OpAnd    QueryOp = QueryOp("and")
+	OpOr     QueryOp = QueryOp("or")
+	OpNot    QueryOp = QueryOp("not")
+	OpGT     QueryOp = QueryOp("gt")
+	OpGTE    QueryOp = QueryOp("gte")
+	OpLT     QueryOp = QueryOp("lt")
+	OpLTE    QueryOp = QueryOp("lte")
+	OpIn     QueryOp = QueryOp("in")
+	OpRange  QueryOp = QueryOp("range")
+	OpCI     QueryOp = QueryOp("ci")
+	OpExists QueryOp = QueryOp("exists")
 )
 
 // String returns the value of a query operator as a string.
@@ -54,6 +58,26 @@ func (qo QueryOp) String() string {
 	return string(qo)
 }
 
+// Operator returns the comparison operator applied by a match or comparison
+// query operation, as used by condition expressions and SQL. Logical
+// operations have no comparison operator, and return an empty string.
+func (qo QueryOp) Operator() string {
+	switch qo {
+	case OpMatch:
+		return "="
+	case OpGT:
+		return ">"
+	case OpGTE:
+		return ">="
+	case OpLT:
+		return "<"
+	case OpLTE:
+		return "<="
+	}
+
+	return ""
+}
+
 // QueryOpFromString returns a QueryOp value from its string name.
 func QueryOpFromString(s string) QueryOp {
 	for _, op := range []QueryOp{
@@ -64,6 +88,10 @@ func QueryOpFromString(s string) QueryOp {
 		OpGTE,
 		OpLT,
 		OpLTE,
+		OpIn,
+		OpRange,
+		OpCI,
+		OpExists,
 	} {
 		if strings.TrimSpace(strings.ToLower(s)) == op.String() {
 			return op
@@ -81,6 +109,7 @@ type QueryNode struct {
 	CatRE string       `json:"cat_re,omitempty"`
 	Val   string       `json:"val,omitempty"`
 	ValRE string       `json:"val_re,omitempty"`
+	CI    bool         `json:"ci,omitempty"`
 	Nodes []*QueryNode `json:"args,omitempty"`
 }
 
@@ -98,9 +127,8 @@ func NewQueryNode(op, comp QueryOp,
 		node.Cat = cat
 	}
 
-	if strings.HasPrefix(val, "/") && strings.HasSuffix(val, "/") &&
-		len(val) > 2 {
-		node.ValRE = strings.TrimSuffix(strings.TrimPrefix(val, "/"), "/")
+	if re, ok := regexpValue(val); ok {
+		node.ValRE = re
 	} else {
 		node.Val = val
 	}
@@ -108,6 +136,34 @@ func NewQueryNode(op, comp QueryOp,
 	return node
 }
 
+// regexpValue returns the regular expression of a value formatted as /re/, or
+// as /re/i, for a regular expression matched without regard to case.
+func regexpValue(val string) (string, bool) {
+	prefix := ""
+
+	if v, ok := strings.CutSuffix(val, "/i"); ok && len(v) > 1 &&
+		strings.HasPrefix(v, "/") {
+		prefix, val = "(?i)", v+"/"
+	}
+
+	if !strings.HasPrefix(val, "/") || !strings.HasSuffix(val, "/") ||
+		len(val) <= 2 {
+		return "", false
+	}
+
+	return prefix + strings.TrimSuffix(strings.TrimPrefix(val, "/"), "/"), true
+}
+
+// SetCaseInsensitive marks the node, and all of its children, as matching
+// string values without regard to case or accents.
+func (qn *QueryNode) SetCaseInsensitive() {
+	qn.CI = true
+
+	for _, n := range qn.Nodes {
+		n.SetCaseInsensitive()
+	}
+}
+
 // String returns a representation of the query node as a string.
 func (qn *QueryNode) String() string {
 	str, err := json.Marshal(qn)
@@ -138,13 +194,14 @@ func (qn *QueryNode) Eval(f func(node *QueryNode) (bool, error)) (bool, error) {
 		}
 
 		switch qn.Op {
-		case OpAnd, OpGT, OpGTE, OpLT, OpLTE, OpMatch:
+		case OpAnd, OpGT, OpGTE, OpLT, OpLTE, OpMatch, OpRange, OpCI,
+			OpExists:
 			if !res {
 				return false, nil
 			}
 
 			def = true
-		case OpOr:
+		case OpOr, OpIn:
 			if res {
 				return true, nil
 			}
@@ -188,11 +245,14 @@ func (qt *QueryTree) Eval(f func(node *QueryNode) (bool, error)) (bool, error) {
 
 // Query messages represent query string search requests.
 type Query struct {
-	Search  string `json:"search,omitempty"`
-	Size    int64  `json:"size,omitempty"`
-	Skip    int64  `json:"skip,omitempty"`
-	Sort    string `json:"sort,omitempty"`
-	Summary string `json:"summary,omitempty"`
+	Search          string `json:"search,omitempty"`
+	Size            int64  `json:"size,omitempty"`
+	Skip            int64  `json:"skip,omitempty"`
+	Sort            string `json:"sort,omitempty"`
+	Summary         string `json:"summary,omitempty"`
+	Count           bool   `json:"count,omitempty"`
+	CaseInsensitive bool   `json:"case_insensitive,omitempty"`
+	Explain         bool   `json:"explain,omitempty"`
 }
 
 // NoSummary returns a copy of the query without the summary component.
@@ -202,10 +262,11 @@ func (q *Query) NoSummary() *Query {
 	}
 
 	return &Query{
-		Search: q.Search,
-		Size:   q.Size,
-		Skip:   q.Skip,
-		Sort:   q.Sort,
+		Search:          q.Search,
+		Size:            q.Size,
+		Skip:            q.Skip,
+		Sort:            q.Sort,
+		CaseInsensitive: q.CaseInsensitive,
 	}
 }
 
@@ -250,6 +311,39 @@ func ParseQuery(values url.Values) (*Query, error) {
 			req.Sort = strings.Join(qv, ",")
 		case "summary":
 			req.Summary = strings.Join(qv, ",")
+		case "count":
+			if strings.TrimSpace(qv[0]) != "" {
+				b, err := strconv.ParseBool(strings.TrimSpace(qv[0]))
+				if err != nil {
+					return nil, errors.New(errors.ErrInvalidRequest,
+						"invalid query count value",
+						"query", values)
+				}
+
+				req.Count = b
+			}
+		case "case_insensitive":
+			if strings.TrimSpace(qv[0]) != "" {
+				b, err := strconv.ParseBool(strings.TrimSpace(qv[0]))
+				if err != nil {
+					return nil, errors.New(errors.ErrInvalidRequest,
+						"invalid query case_insensitive value",
+						"query", values)
+				}
+
+				req.CaseInsensitive = b
+			}
+		case "explain":
+			if strings.TrimSpace(qv[0]) != "" {
+				b, err := strconv.ParseBool(strings.TrimSpace(qv[0]))
+				if err != nil {
+					return nil, errors.New(errors.ErrInvalidRequest,
+						"invalid query explain value",
+						"query", values)
+				}
+
+				req.Explain = b
+			}
 		}
 	}
 
@@ -89,7 +89,8 @@ func TestParseQuery(t *testing.T) {
 	t.Parallel()
 
 	q := "search=test%20(test:test)&skip=10&size=10&sort=test" +
-		"&ver=v2&search=(test1:test1)&sort=-test1&summary=test,test1"
+		"&ver=v2&search=(test1:test1)&sort=-test1&summary=test,test1" +
+		"&count=true&case_insensitive=true&explain=true"
 
 	values, err := url.ParseQuery(q)
 	if err != nil {
@@ -130,4 +131,16 @@ func TestParseQuery(t *testing.T) {
 	if req.Summary != expS {
 		t.Errorf("Expected summary: %v, got: %v", expS, req.Summary)
 	}
+
+	if !req.Count {
+		t.Error("Expected count")
+	}
+
+	if !req.CaseInsensitive {
+		t.Error("Expected case insensitive")
+	}
+
+	if !req.Explain {
+		t.Error("Expected explain")
+	}
 }
@@ -174,6 +174,72 @@ func (qs *Scanner) scanKeyword() (ScanToken, string, error) {
 			return TokenKeyword, buf.String(), nil
 		}
 
+		return TokenIllegal, "", nil
+	} else if ch == 'c' {
+		if err := qs.unread(); err != nil {
+			return TokenIllegal, "", errors.Wrap(err, errors.ErrSearch,
+				"unable to unread to scan buffer")
+		}
+
+		if chN, err := qs.r.Peek(3); err == nil && string(chN) == "ci(" {
+			for i := 0; i < 2; i++ {
+				_, err := buf.WriteRune(qs.read())
+				if err != nil {
+					return TokenIllegal, "", errors.Wrap(err, errors.ErrSearch,
+						"unable to write to token buffer")
+				}
+			}
+
+			return TokenKeyword, buf.String(), nil
+		}
+
+		return TokenIllegal, "", nil
+	} else if ch == 'e' {
+		if err := qs.unread(); err != nil {
+			return TokenIllegal, "", errors.Wrap(err, errors.ErrSearch,
+				"unable to unread to scan buffer")
+		}
+
+		if chN, err := qs.r.Peek(7); err == nil && string(chN) == "exists(" {
+			for i := 0; i < 6; i++ {
+				_, err := buf.WriteRune(qs.read())
+				if err != nil {
+					return TokenIllegal, "", errors.Wrap(err, errors.ErrSearch,
+						"unable to write to token buffer")
+				}
+			}
+
+			return TokenKeyword, buf.String(), nil
+		}
+
+		return TokenIllegal, "", nil
+	} else if ch == 'i' || ch == 'r' {
+		if err := qs.unread(); err != nil {
+			return TokenIllegal, "", errors.Wrap(err, errors.ErrSearch,
+				"unable to unread to scan buffer")
+		}
+
+		n := 0
+
+		if chN, err := qs.r.Peek(3); err == nil && string(chN) == "in(" {
+			n = 2
+		} else if chN, err := qs.r.Peek(6); err == nil &&
+			string(chN) == "range(" {
+			n = 5
+		}
+
+		for i := 0; i < n; i++ {
+			_, err := buf.WriteRune(qs.read())
+			if err != nil {
+				return TokenIllegal, "", errors.Wrap(err, errors.ErrSearch,
+					"unable to write to token buffer")
+			}
+		}
+
+		if n > 0 {
+			return TokenKeyword, buf.String(), nil
+		}
+
 		return TokenIllegal, "", nil
 	} else if ch == 'm' {
 		if err := qs.unread(); err != nil {
@@ -253,6 +319,10 @@ func (qs *Scanner) scanTagToken() (ScanToken, string, error) {
 
 	lastCh := rune(0)
 
+	// Parentheses within a token, such as those of function calls in
+	// condition expressions, are part of the token.
+	depth := 0
+
 loop:
 	for {
 		ch := qs.read()
@@ -386,11 +456,25 @@ loop:
 			}
 
 			lastCh = ch
-		case qs.name && ch == ':':
+		case qs.name && depth == 0 && ch == ':':
 			qs.name = false
 			lastCh = ch
 
 			break loop
+		case qs.kw && (ch == '(' || depth > 0 && (ch == ')' || ch == ',')):
+			lastCh = ch
+
+			switch ch {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+
+			if _, err := buf.WriteRune(ch); err != nil {
+				return TokenIllegal, "", errors.Wrap(err, errors.ErrSearch,
+					"unable to write to tag token buffer")
+			}
 		case qs.kw && (ch == ',' || ch == ')'):
 			lastCh = ch
 			qs.name = true
@@ -437,6 +521,30 @@ loop:
 	return TokenTagVal, buf.String(), nil
 }
 
+// comparisonPrefixes are the comparison operators which may prefix a search
+// value, such as updated_at:>now-24h, in place of a comparison keyword.
+var comparisonPrefixes = []struct {
+	prefix string
+	op     QueryOp
+}{
+	{">=", OpGTE},
+	{"<=", OpLTE},
+	{">", OpGT},
+	{"<", OpLT},
+}
+
+// comparison returns the comparison operation and the remaining value of a
+// search value prefixed with a comparison operator.
+func comparison(val string) (QueryOp, string, bool) {
+	for _, c := range comparisonPrefixes {
+		if v, ok := strings.CutPrefix(val, c.prefix); ok && v != "" {
+			return c.op, v, true
+		}
+	}
+
+	return "", val, false
+}
+
 // Parser values are used to parse query AST values.
 type Parser struct {
 	s       *Scanner
@@ -491,13 +599,20 @@ func (qp *Parser) parse(node *QueryNode) error {
 		newComp := newOp
 
 		switch newOp {
-		case OpAnd, OpOr, OpNot:
+		case OpAnd, OpOr, OpNot, OpCI:
 			newComp = node.Comp
 		}
 
 		newNode := NewQueryNode(newOp, newComp, "", "")
 
-		if err := qp.parse(newNode); err != nil {
+		parse := qp.parse
+
+		switch newOp {
+		case OpIn, OpRange:
+			parse = qp.parseList
+		}
+
+		if err := parse(newNode); err != nil {
 			return errors.Wrap(err, errors.ErrInvalidRequest,
 				"unable to parse child node")
 		}
@@ -507,6 +622,21 @@ func (qp *Parser) parse(node *QueryNode) error {
 				"unable to parse empty keyword")
 		}
 
+		if newOp == OpCI {
+			newNode.SetCaseInsensitive()
+		}
+
+		if newOp == OpExists {
+			for _, n := range newNode.Nodes {
+				if n.Op != OpMatch || n.Cat == "" || n.Val != "" ||
+					n.ValRE != "" {
+					return errors.New(errors.ErrInvalidRequest,
+						"invalid exists, expecting field names",
+						"node", n.String())
+				}
+			}
+		}
+
 		node.Nodes = append(node.Nodes, newNode)
 
 		t, l, err := qp.s.Scan()
@@ -556,7 +686,13 @@ func (qp *Parser) parse(node *QueryNode) error {
 			l = ""
 		}
 
-		newNode := NewQueryNode(OpMatch, node.Comp, lit, l)
+		comp := node.Comp
+
+		if op, v, ok := comparison(l); ok {
+			comp, l = op, v
+		}
+
+		newNode := NewQueryNode(OpMatch, comp, lit, l)
 
 		node.Nodes = append(node.Nodes, newNode)
 
@@ -586,6 +722,95 @@ func (qp *Parser) parse(node *QueryNode) error {
 	}
 }
 
+// parseList scans and parses the category and values of an in or range
+// keyword, up to its closing parenthesis. The values of in(status:a,b,c) are
+// parsed into match nodes for each value, and the value of
+// range(clear_after:10..100) into greater than or equal and less than or equal
+// match nodes for the values before and after the "..", either of which may
+// be omitted.
+func (qp *Parser) parseList(node *QueryNode) error {
+	tok, cat, err := qp.s.Scan()
+	if err != nil {
+		return errors.Wrap(err, errors.ErrSearch,
+			"unable to scan next token from query")
+	}
+
+	if tok != TokenTagCat || cat == "" {
+		return errors.New(errors.ErrInvalidRequest,
+			"parse failure, expecting category for "+node.Op.String()+
+				" got: "+cat)
+	}
+
+	vals := []string{}
+
+	// Values containing a colon are scanned as separate tokens, which are
+	// rejoined if they are not separated by a comma.
+	sep := true
+
+loop:
+	for {
+		tok, lit, err := qp.s.Scan()
+		if err != nil {
+			return errors.Wrap(err, errors.ErrSearch,
+				"unable to scan next token from query")
+		}
+
+		switch tok {
+		case TokenWS:
+		case TokenComma:
+			sep = true
+		case TokenTagCat, TokenTagVal:
+			if !sep {
+				vals[len(vals)-1] += ":" + lit
+
+				continue
+			}
+
+			vals = append(vals, lit)
+			sep = false
+		case TokenCP, TokenEOF:
+			break loop
+		default:
+			return errors.New(errors.ErrInvalidRequest,
+				"parse failure, expecting value or ) got: "+lit)
+		}
+	}
+
+	if node.Op == OpIn {
+		for _, v := range vals {
+			node.Nodes = append(node.Nodes,
+				NewQueryNode(OpMatch, OpMatch, cat, v))
+		}
+
+		return nil
+	}
+
+	lo, hi, ok := "", "", false
+
+	if len(vals) == 1 {
+		lo, hi, ok = strings.Cut(vals[0], "..")
+	}
+
+	lo, hi = strings.TrimSpace(lo), strings.TrimSpace(hi)
+
+	if !ok || (lo == "" && hi == "") {
+		return errors.New(errors.ErrInvalidRequest,
+			"invalid range, expecting from..to values",
+			"category", cat,
+			"values", vals)
+	}
+
+	if lo != "" {
+		node.Nodes = append(node.Nodes, NewQueryNode(OpMatch, OpGTE, cat, lo))
+	}
+
+	if hi != "" {
+		node.Nodes = append(node.Nodes, NewQueryNode(OpMatch, OpLTE, cat, hi))
+	}
+
+	return nil
+}
+
 // Parse scans and parses a query.
 func (qp *Parser) Parse() (*QueryTree, error) {
 	qt := NewQueryTree()
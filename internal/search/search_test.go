@@ -594,6 +594,149 @@ func TestParser(t *testing.T) {
 				}
 			},
 		},
+		{
+			input: "and(gt(age(last_seen):300),contains(tags,'a,b'):true)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				if node.Cat == "age(last_seen)" && node.Val == "300" ||
+					node.Cat == "contains(tags,'a,b')" && node.Val == "true" {
+					return true, nil
+				}
+
+				return false, nil
+			},
+			res: func(ast *search.QueryTree) {
+				if ast.Root.Nodes[0].Nodes[0].Nodes[0].Cat !=
+					"age(last_seen)" {
+					t.Errorf("Expected node category: age(last_seen), got: %v",
+						ast.Root.Nodes[0].Nodes[0].Nodes[0].Cat)
+				}
+
+				if ast.Root.Nodes[0].Nodes[1].Cat != "contains(tags,'a,b')" {
+					t.Errorf("Expected node category: contains(tags,'a,b'), "+
+						"got: %v", ast.Root.Nodes[0].Nodes[1].Cat)
+				}
+			},
+		},
+		{
+			input: "and(in(status:active,error),name:test)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				if node.Cat == "status" && node.Val == "error" ||
+					node.Cat == "name" && node.Val == "test" {
+					return true, nil
+				}
+
+				return false, nil
+			},
+			res: func(ast *search.QueryTree) {
+				in := ast.Root.Nodes[0].Nodes[0]
+
+				if in.Op != search.OpIn || len(in.Nodes) != 2 ||
+					in.Nodes[0].Val != "active" || in.Nodes[1].Val != "error" {
+					t.Errorf("Expected in node, got: %v", in)
+				}
+
+				if ast.Root.Nodes[0].Nodes[1].Cat != "name" {
+					t.Errorf("Expected node category: name, got: %v",
+						ast.Root.Nodes[0].Nodes[1].Cat)
+				}
+			},
+		},
+		{
+			input: "in(tags:env:prod, env:dev)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				return node.Val == "env:dev", nil
+			},
+			res: func(ast *search.QueryTree) {
+				in := ast.Root.Nodes[0]
+
+				if len(in.Nodes) != 2 || in.Nodes[0].Cat != "tags" ||
+					in.Nodes[0].Val != "env:prod" ||
+					in.Nodes[1].Val != "env:dev" {
+					t.Errorf("Expected in node values, got: %v", in)
+				}
+			},
+		},
+		{
+			input: "range(clear_after:10..100)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				return node.Cat == "clear_after", nil
+			},
+			res: func(ast *search.QueryTree) {
+				r := ast.Root.Nodes[0]
+
+				if r.Op != search.OpRange || len(r.Nodes) != 2 ||
+					r.Nodes[0].Comp != search.OpGTE ||
+					r.Nodes[0].Val != "10" ||
+					r.Nodes[1].Comp != search.OpLTE ||
+					r.Nodes[1].Val != "100" {
+					t.Errorf("Expected range node, got: %v", r)
+				}
+			},
+		},
+		{
+			input: "range(updated_at:2024-01-01..)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				return node.Comp == search.OpGTE, nil
+			},
+			res: func(ast *search.QueryTree) {
+				r := ast.Root.Nodes[0]
+
+				if len(r.Nodes) != 1 || r.Nodes[0].Val != "2024-01-01" {
+					t.Errorf("Expected open range node, got: %v", r)
+				}
+			},
+		},
+		{
+			input: "and(updated_at:>now-24h,count:<=5)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				return node.Comp == search.OpGT && node.Val == "now-24h" ||
+					node.Comp == search.OpLTE && node.Val == "5", nil
+			},
+			res: func(ast *search.QueryTree) {
+				if n := ast.Root.Nodes[0].Nodes[0]; n.Comp != search.OpGT ||
+					n.Val != "now-24h" {
+					t.Errorf("Expected gt now-24h node, got: %v", n)
+				}
+
+				if n := ast.Root.Nodes[0].Nodes[1]; n.Comp != search.OpLTE ||
+					n.Val != "5" {
+					t.Errorf("Expected lte 5 node, got: %v", n)
+				}
+			},
+		},
+		{
+			input: "and(ci(name:test,or(status:active)),version:1)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				return node.CI == (node.Cat != "version"), nil
+			},
+			res: func(ast *search.QueryTree) {
+				ci := ast.Root.Nodes[0].Nodes[0]
+
+				if ci.Op != search.OpCI || !ci.Nodes[1].Nodes[0].CI {
+					t.Errorf("Expected case insensitive node, got: %v", ci)
+				}
+
+				if ast.Root.Nodes[0].Nodes[1].CI {
+					t.Errorf("Expected case sensitive node, got: %v",
+						ast.Root.Nodes[0].Nodes[1])
+				}
+			},
+		},
+		{
+			input: "and(exists(cpu,items[0].name),status:/^err/i)",
+			eval: func(node *search.QueryNode) (bool, error) {
+				return node.Comp == search.OpExists && node.Val == "" ||
+					node.ValRE == "(?i)^err", nil
+			},
+			res: func(ast *search.QueryTree) {
+				e := ast.Root.Nodes[0].Nodes[0]
+
+				if e.Op != search.OpExists || len(e.Nodes) != 2 ||
+					e.Nodes[1].Cat != "items[0].name" {
+					t.Errorf("Expected exists node, got: %v", e)
+				}
+			},
+		},
 		{
 			input: "and(apple)",
 			eval: func(node *search.QueryNode) (bool, error) {
@@ -655,3 +798,34 @@ func TestParseErrors(t *testing.T) {
 		t.Fatalf("Expecting whitespace error, got: %v", err.Error())
 	}
 }
+
+func TestParseListErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{
+		"in(status:)",
+		"range(clear_after:10)",
+		"range(clear_after:..)",
+		"range(clear_after:1..2,3..4)",
+	} {
+		if _, err := search.NewParser(
+			bytes.NewBufferString(input)).Parse(); err == nil {
+			t.Errorf("Expected error parsing: %v", input)
+		}
+	}
+}
+
+func TestParseExistsErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{
+		"exists()",
+		"exists(status:error)",
+		"exists(and(status))",
+	} {
+		if _, err := search.NewParser(
+			bytes.NewBufferString(input)).Parse(); err == nil {
+			t.Errorf("Expected error parsing: %v", input)
+		}
+	}
+}
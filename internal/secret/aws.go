@@ -0,0 +1,189 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+)
+
+// AWSProvider values read secrets from AWS Secrets Manager. Secret names are
+// the names, or ARNs, of the secrets, and may end with #key to select a single
+// value of a secret containing a JSON object. Requests are signed using the
+// credentials in the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables.
+type AWSProvider struct {
+	cfg    *config.Config
+	client *httpclient.Client
+}
+
+// Get returns the current version of the secret.
+func (p *AWSProvider) Get(ctx context.Context, name string) ([]byte, error) {
+	n, key := splitName(name)
+
+	if n == "" {
+		return nil, errors.New(errors.ErrInvalidParameter,
+			"invalid AWS secret name",
+			"name", name)
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": n})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to create AWS request body",
+			"name", name)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.cfg.SecretAWSEndpoint(), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to create AWS request",
+			"name", name)
+	}
+
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+
+	signV4(req, body, p.cfg.SecretAWSRegion(), "secretsmanager",
+		time.Now().UTC())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to read AWS response",
+			"name", name)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		code := errors.ErrClient
+
+		if strings.Contains(string(b), "ResourceNotFoundException") {
+			code = errors.ErrNotFound
+		}
+
+		return nil, errors.New(code,
+			"unable to read AWS secret",
+			"name", name,
+			"status", resp.StatusCode,
+			"response", string(b))
+	}
+
+	res := struct {
+		SecretString *string `json:"SecretString"`
+		SecretBinary []byte  `json:"SecretBinary"`
+	}{}
+
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to parse AWS response",
+			"name", name)
+	}
+
+	v := res.SecretBinary
+
+	if res.SecretString != nil {
+		v = []byte(*res.SecretString)
+	}
+
+	return selectKey(v, name, key)
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data using the key.
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
+
+// sha256Hex returns the hex encoded SHA256 hash of the data.
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+
+	return hex.EncodeToString(h[:])
+}
+
+// signV4 signs a request to an AWS service with Signature Version 4, using the
+// credentials in the environment.
+func signV4(req *http.Request, body []byte, region, service string,
+	now time.Time,
+) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	names := []string{"content-type", "host", "x-amz-date"}
+
+	for _, n := range []string{"x-amz-security-token", "x-amz-target"} {
+		if req.Header.Get(n) != "" {
+			names = append(names, n)
+		}
+	}
+
+	headers := ""
+
+	for _, n := range names {
+		v := req.Header.Get(n)
+
+		if n == "host" {
+			v = req.URL.Host
+		}
+
+		headers += n + ":" + strings.TrimSpace(v) + "\n"
+	}
+
+	signed := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		headers,
+		signed,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" +
+		sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+os.Getenv("AWS_SECRET_ACCESS_KEY")), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+
+		os.Getenv("AWS_ACCESS_KEY_ID")+"/"+scope+", SignedHeaders="+signed+
+		", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+}
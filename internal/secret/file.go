@@ -0,0 +1,43 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/dhaifley/apigo/internal/errors"
+)
+
+// FileProvider values read secrets from files in a directory, such as a
+// mounted secrets volume. Secret names are the paths of the files, relative to
+// the directory.
+type FileProvider struct {
+	Dir string
+}
+
+// Get returns the contents of the secret file, without any trailing newline.
+func (p *FileProvider) Get(ctx context.Context, name string) ([]byte, error) {
+	n, key := splitName(name)
+
+	if !filepath.IsLocal(n) {
+		return nil, errors.New(errors.ErrInvalidParameter,
+			"invalid secret file name",
+			"name", name)
+	}
+
+	b, err := os.ReadFile(filepath.Join(p.Dir, n))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New(errors.ErrNotFound,
+				"secret file not found",
+				"name", name)
+		}
+
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to read secret file",
+			"name", name)
+	}
+
+	return selectKey(bytes.TrimRight(b, "\r\n"), name, key)
+}
@@ -0,0 +1,160 @@
+// Package secret provides loading of configuration secrets, such as database
+// credentials and token keys, from external secret managers.
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/metric"
+)
+
+// Provider is the interface implemented by external secret managers.
+type Provider interface {
+	// Get returns the value of the secret with the specified name. Names may
+	// end with #key, to select a single value of a secret containing a JSON
+	// object.
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// NewProvider returns the secret provider selected by the configuration, or
+// nil, if none is configured.
+func NewProvider(cfg *config.Config, client *httpclient.Client) Provider {
+	switch cfg.SecretProvider() {
+	case config.SecretProviderFile:
+		return &FileProvider{Dir: cfg.SecretFileDir()}
+	case config.SecretProviderVault:
+		return &VaultProvider{cfg: cfg, client: client}
+	case config.SecretProviderAWS:
+		return &AWSProvider{cfg: cfg, client: client}
+	}
+
+	return nil
+}
+
+// splitName splits a secret name into the name of the secret in the provider,
+// and the key of the value selected from it, if any.
+func splitName(name string) (string, string) {
+	if i := strings.LastIndex(name, "#"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+
+	return name, ""
+}
+
+// selectKey returns the value of the key in a secret containing a JSON object.
+// Strings are returned without quotes, other values are returned as JSON. If
+// no key is provided, the whole secret is returned.
+func selectKey(b []byte, name, key string) ([]byte, error) {
+	if key == "" {
+		return b, nil
+	}
+
+	m := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to parse secret",
+			"name", name)
+	}
+
+	v, ok := m[key]
+	if !ok {
+		return nil, errors.New(errors.ErrNotFound,
+			"secret key not found",
+			"name", name,
+			"key", key)
+	}
+
+	var s string
+
+	if err := json.Unmarshal(v, &s); err == nil {
+		return []byte(s), nil
+	}
+
+	return v, nil
+}
+
+// Refresher values load secrets from a provider into the configuration, when
+// started, and periodically after, so that rotated secrets are used.
+type Refresher struct {
+	cfg    *config.Config
+	p      Provider
+	log    logger.Logger
+	metric metric.Recorder
+}
+
+// NewRefresher initializes a new secret refresher, or returns nil, if no
+// secret provider is configured.
+func NewRefresher(cfg *config.Config,
+	log logger.Logger,
+	metric metric.Recorder,
+	client *httpclient.Client,
+) *Refresher {
+	p := NewProvider(cfg, client)
+	if p == nil {
+		return nil
+	}
+
+	if log == nil || (reflect.ValueOf(log).Kind() == reflect.Ptr &&
+		reflect.ValueOf(log).IsNil()) {
+		log = logger.NullLog
+	}
+
+	if metric == nil || (reflect.ValueOf(metric).Kind() == reflect.Ptr &&
+		reflect.ValueOf(metric).IsNil()) {
+		metric = nil
+	}
+
+	return &Refresher{cfg: cfg, p: p, log: log, metric: metric}
+}
+
+// Refresh loads each secret named by the configuration from the provider, and
+// applies it to the configuration. Secrets which cannot be loaded keep their
+// current values, and the last error is returned.
+func (r *Refresher) Refresh(ctx context.Context) error {
+	var res error
+
+	for key, name := range r.cfg.SecretNames() {
+		v, err := r.p.Get(ctx, name)
+		if err == nil {
+			err = r.cfg.SetSecretValue(key, v)
+		}
+
+		if err != nil {
+			r.log.Log(ctx, logger.LvlError,
+				"unable to load secret",
+				"error", err,
+				"key", key,
+				"name", name)
+
+			if r.metric != nil {
+				r.metric.Increment(ctx, "secret_errors", "key:"+key)
+			}
+
+			res = err
+		}
+	}
+
+	return res
+}
+
+// Run refreshes the secrets at the configured interval, until the context is
+// done.
+func (r *Refresher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.SecretRefresh()):
+			_ = r.Refresh(ctx)
+		}
+	}
+}
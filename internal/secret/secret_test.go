@@ -0,0 +1,144 @@
+package secret_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+	"github.com/dhaifley/apigo/internal/secret"
+)
+
+func TestFileProvider(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, "db"),
+		[]byte(`{"dsn":"postgres://test"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &secret.FileProvider{Dir: dir}
+
+	v, err := p.Get(context.Background(), "db#dsn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(v) != "postgres://test" {
+		t.Errorf("Expected secret: postgres://test, got: %s", v)
+	}
+
+	if _, err := p.Get(context.Background(), "missing"); !errors.Has(err,
+		errors.ErrNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+
+	if _, err := p.Get(context.Background(), "../db"); err == nil {
+		t.Error("Expected error for non-local name, got: nil")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request,
+	) {
+		if r.Header.Get("X-Vault-Token") != "test" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		if r.URL.Path != "/v1/kv/data/apigo" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Write([]byte(`{"data":{"data":{"pepper":"salt"}}}`))
+	}))
+
+	defer ts.Close()
+
+	cfg := config.NewDefault()
+
+	cfg.SetSecret(&config.SecretConfig{
+		Provider:     config.SecretProviderVault,
+		Names:        map[string]string{config.KeyAuthAccountPepper: "apigo#pepper"},
+		VaultAddress: ts.URL,
+		VaultToken:   "test",
+		VaultMount:   "kv",
+	})
+
+	r := secret.NewRefresher(cfg, nil, nil,
+		httpclient.NewClient(cfg, nil, nil, nil))
+	if r == nil {
+		t.Fatal("Expected refresher, got: nil")
+	}
+
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.AuthAccountPepper(); string(v) != "salt" {
+		t.Errorf("Expected pepper: salt, got: %s", v)
+	}
+
+	p := secret.NewProvider(cfg, httpclient.NewClient(cfg, nil, nil, nil))
+
+	if _, err := p.Get(context.Background(), "missing#key"); !errors.Has(err,
+		errors.ErrNotFound) {
+		t.Errorf("Expected not found error, got: %v", err)
+	}
+}
+
+func TestAWSProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request,
+	) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			w.WriteHeader(http.StatusForbidden)
+
+			return
+		}
+
+		w.Write([]byte(`{"Name":"apigo","SecretString":"{\"dsn\":\"pg\"}"}`))
+	}))
+
+	defer ts.Close()
+
+	cfg := config.NewDefault()
+
+	cfg.SetSecret(&config.SecretConfig{
+		Provider:    config.SecretProviderAWS,
+		Names:       map[string]string{config.KeyDBConn: "apigo#dsn"},
+		AWSRegion:   "us-east-1",
+		AWSEndpoint: ts.URL,
+	})
+
+	r := secret.NewRefresher(cfg, nil, nil,
+		httpclient.NewClient(cfg, nil, nil, nil))
+
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if v := cfg.DBConn(config.DBModeNormal); v != "pg" {
+		t.Errorf("Expected connection: pg, got: %v", v)
+	}
+}
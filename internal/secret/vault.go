@@ -0,0 +1,91 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
+)
+
+// VaultProvider values read secrets from the KV version 2 secrets engine of a
+// HashiCorp Vault server. Secret names are the paths of the secrets in the
+// engine, and must end with #key to select a single value of the secret.
+type VaultProvider struct {
+	cfg    *config.Config
+	client *httpclient.Client
+}
+
+// Get returns the value of the key in the latest version of the secret.
+func (p *VaultProvider) Get(ctx context.Context, name string) ([]byte, error) {
+	n, key := splitName(name)
+
+	if n == "" || key == "" {
+		return nil, errors.New(errors.ErrInvalidParameter,
+			"invalid vault secret name, expected path#key",
+			"name", name)
+	}
+
+	u, err := url.JoinPath(p.cfg.SecretVaultAddress(), "v1",
+		p.cfg.SecretVaultMount(), "data", strings.TrimPrefix(n, "/"))
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"invalid vault address",
+			"address", p.cfg.SecretVaultAddress())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to create vault request",
+			"name", name)
+	}
+
+	req.Header.Set("X-Vault-Token", p.cfg.SecretVaultToken())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to read vault response",
+			"name", name)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, errors.New(errors.ErrNotFound,
+			"vault secret not found",
+			"name", name)
+	case resp.StatusCode >= http.StatusBadRequest:
+		return nil, errors.New(errors.ErrClient,
+			"unable to read vault secret",
+			"name", name,
+			"status", resp.StatusCode,
+			"response", string(b))
+	}
+
+	res := struct {
+		Data struct {
+			Data json.RawMessage `json:"data"`
+		} `json:"data"`
+	}{}
+
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, errors.Wrap(err, errors.ErrClient,
+			"unable to parse vault response",
+			"name", name)
+	}
+
+	return selectKey(res.Data.Data, name, key)
+}
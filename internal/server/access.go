@@ -0,0 +1,86 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+// newAccessLog creates the access log configured for the server, or returns
+// nil if the access log is disabled.
+func newAccessLog(cfg *config.Config) (*logger.AccessLog, error) {
+	var w io.Writer
+
+	switch cfg.LogAccess() {
+	case config.LogAccessStdout:
+		w = os.Stdout
+	case config.LogAccessStderr:
+		w = os.Stderr
+	case config.LogAccessFile:
+		f, err := logger.NewRotatingFile(cfg.LogAccessFile(),
+			int64(cfg.LogAccessMaxSize())*1024*1024, cfg.LogAccessMaxFiles())
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrLog,
+				"unable to open access log file",
+				"file", cfg.LogAccessFile())
+		}
+
+		w = f
+	case config.LogAccessSyslog:
+		sw, err := logger.NewSyslogWriter(cfg.LogAccessSyslog(),
+			cfg.ServiceName())
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrLog,
+				"unable to connect to access log syslog server",
+				"address", cfg.LogAccessSyslog())
+		}
+
+		w = sw
+	default:
+		return nil, nil
+	}
+
+	return logger.NewAccessLog(w, cfg.LogAccessFields(),
+		cfg.LogAccessSample()), nil
+}
+
+// accessWriter values capture the status and size of a response as it is
+// written.
+type accessWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader captures the response status code.
+func (w *accessWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write captures the response size.
+func (w *accessWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+
+	w.bytes += int64(n)
+
+	return n, err
+}
+
+// Flush implements http.Flusher, if the wrapped writer supports it.
+func (w *accessWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
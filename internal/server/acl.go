@@ -0,0 +1,177 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/go-chi/chi/v5"
+)
+
+// aclOperations documents the resource ACL routes.
+var aclOperations = map[string]*Operation{
+	"GET /resources/{id}/acl": {
+		ID:      "get_resource_acl",
+		Tag:     "resources",
+		Summary: "Get resource ACL",
+		Description: "Retrieves the access control list of a specific " +
+			"resource. The admin permission on the resource is required.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "resource_acls",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"PUT /resources/{id}/acl": {
+		ID:      "set_resource_acl",
+		Tag:     "resources",
+		Summary: "Set resource ACL entry",
+		Description: "Grants a permission on a specific resource to a user " +
+			"or group, replacing any permission previously granted to it. " +
+			"Once a resource has an ACL entry, only the principals granted a " +
+			"permission are able to access it. Restricting a resource " +
+			"requires the resources:admin scope.",
+		Scopes: []string{"resource:write"},
+		Params: []*Parameter{{Name: "id"}},
+		Body:   "resource_acl",
+		Responses: map[int]string{
+			200: "resource_acl",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /resources/{id}/acl/{principal_type}/{principal_id}": {
+		ID:      "delete_resource_acl",
+		Tag:     "resources",
+		Summary: "Delete resource ACL entry",
+		Description: "Removes the permission granted on a specific resource " +
+			"to a user or group.",
+		Scopes: []string{"resource:write"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{
+				Name:        "principal_type",
+				In:          "path",
+				Type:        "string",
+				Enum:        []string{"user", "group"},
+				Required:    true,
+				Description: "The type of the principal.",
+			},
+			{
+				Name:        "principal_id",
+				In:          "path",
+				Type:        "string",
+				Required:    true,
+				Description: "The ID of the user or group.",
+			},
+		},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// GetResourceACL is the get handler function for resource ACLs.
+func (s *Server) GetResourceACL(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetResourceACL(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PutResourceACL is the put handler function for resource ACL entries.
+func (s *Server) PutResourceACL(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesWrite); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &resource.ACL{}
+
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req.ResourceID = request.FieldString{
+		Set: true, Valid: true,
+		Value: chi.URLParam(r, "id"),
+	}
+
+	res, err := svc.SetResourceACL(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// DeleteResourceACL is the delete handler function for resource ACL entries.
+func (s *Server) DeleteResourceACL(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesWrite); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := svc.DeleteResourceACL(ctx, &resource.ACL{
+		ResourceID: request.FieldString{
+			Set: true, Valid: true,
+			Value: chi.URLParam(r, "id"),
+		},
+		PrincipalType: request.FieldString{
+			Set: true, Valid: true,
+			Value: chi.URLParam(r, "principal_type"),
+		},
+		PrincipalID: request.FieldString{
+			Set: true, Valid: true,
+			Value: chi.URLParam(r, "principal_id"),
+		},
+	}); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,146 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestACL = resource.ACL{
+	ResourceID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestUUID,
+	},
+	PrincipalType: request.FieldString{
+		Set: true, Valid: true,
+		Value: resource.PrincipalGroup,
+	},
+	PrincipalID: request.FieldString{
+		Set: true, Valid: true,
+		Value: "test-group",
+	},
+	Permission: request.FieldString{
+		Set: true, Valid: true,
+		Value: resource.PermissionWrite,
+	},
+}
+
+func (m *mockResourceService) GetResourceACL(ctx context.Context,
+	id string,
+) ([]*resource.ACL, error) {
+	return []*resource.ACL{&TestACL}, nil
+}
+
+func (m *mockResourceService) SetResourceACL(ctx context.Context,
+	v *resource.ACL,
+) (*resource.ACL, error) {
+	return v, nil
+}
+
+func (m *mockResourceService) DeleteResourceACL(ctx context.Context,
+	v *resource.ACL,
+) error {
+	return nil
+}
+
+func TestResourceACL(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		method string
+		url    string
+		body   string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "get",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/resources/" + TestUUID + "/acl",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"principal_id":"` + TestACL.PrincipalID.Value + `"`,
+	}, {
+		name:   "set",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPut,
+		url:    basePath + "/resources/" + TestUUID + "/acl",
+		body: `{"principal_type":"user","principal_id":"` + TestUUID +
+			`","permission":"read"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"resource_id":"` + TestUUID + `"`,
+	}, {
+		name:   "invalid set",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPut,
+		url:    basePath + "/resources/" + TestUUID + "/acl",
+		body:   `{"principal_type":`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `unable to decode request`,
+	}, {
+		name:   "delete",
+		w:      httptest.NewRecorder(),
+		method: http.MethodDelete,
+		url: basePath + "/resources/" + TestUUID + "/acl/group/" +
+			TestACL.PrincipalID.Value,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusNoContent,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.NewBufferString(tt.body)
+
+			r, err := http.NewRequest(tt.method, tt.url, buf)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,545 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/go-chi/chi/v5"
+)
+
+// AgentHandler performs routing for agent requests.
+func (s *Server) AgentHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.Auth).Post("/{id}/heartbeat",
+		s.PostAgentHeartbeat)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/{id}/disable", s.PostDisableAgent)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/{id}/enable", s.PostEnableAgent)
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}/config", s.GetAgentConfig)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/{id}/config/pin",
+		s.PostAgentConfigPin)
+	r.With(s.Stat, s.Trace, s.Auth).Delete("/{id}/config/pin",
+		s.DeleteAgentConfigPin)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.SearchAgent)
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}", s.GetAgent)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/", s.PostAgent)
+	r.With(s.Stat, s.Trace, s.Auth).Delete("/{id}", s.DeleteAgent)
+
+	return r
+}
+
+// agentOperations documents the agent routes.
+var agentOperations = map[string]*Operation{
+	"GET /agents": {
+		ID:          "search_agents",
+		Tag:         "agents",
+		Summary:     "Search agents",
+		Description: "Retrieves agents based on a search query.",
+		Scopes:      []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "case_insensitive"},
+			{Name: "summary"},
+			{Name: "envelope"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "agents",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /agents": {
+		ID:      "register_agent",
+		Tag:     "agents",
+		Summary: "Register agent",
+		Description: "Registers an agent, or updates the registration of an " +
+			"existing agent with the same agent_id. Registration marks the " +
+			"agent as active. Disabled agents can not register.",
+		Scopes: []string{"resource:write"},
+		Body:   "agent",
+		Responses: map[int]string{
+			201: "agent",
+			400: "user_error",
+			403: "user_error",
+			500: "error",
+		},
+	},
+	"GET /agents/{id}": {
+		ID:          "get_agent",
+		Tag:         "agents",
+		Summary:     "Get agent",
+		Description: "Retrieves details for a specific agent.",
+		Scopes:      []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "agent",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /agents/{id}": {
+		ID:          "delete_agent",
+		Tag:         "agents",
+		Summary:     "Delete agent",
+		Description: "Deletes a specific agent.",
+		Scopes:      []string{"resource:admin"},
+		Params:      []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /agents/{id}/heartbeat": {
+		ID:      "create_agent_heartbeat",
+		Tag:     "agents",
+		Summary: "Send agent heartbeat",
+		Description: "Records a heartbeat from a registered agent, marking " +
+			"it as active. The request body is optional, and may update the " +
+			"version, status_data and resources of the agent. Disabled " +
+			"agents can not send heartbeats.",
+		Scopes:       []string{"resource:write"},
+		Params:       []*Parameter{{Name: "id"}},
+		Body:         "agent",
+		BodyOptional: true,
+		Responses: map[int]string{
+			200: "agent",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"POST /agents/{id}/disable": {
+		ID:      "disable_agent",
+		Tag:     "agents",
+		Summary: "Disable agent",
+		Description: "Disables a specific agent. Disabled agents can not " +
+			"register or send heartbeats until they are enabled again.",
+		Scopes: []string{"resource:admin"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "agent",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /agents/{id}/enable": {
+		ID:      "enable_agent",
+		Tag:     "agents",
+		Summary: "Enable agent",
+		Description: "Enables a specific disabled agent. The agent is " +
+			"disconnected until it registers or sends a heartbeat.",
+		Scopes: []string{"resource:admin"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "agent",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /agents/{id}/config": {
+		ID:      "get_agent_config",
+		Tag:     "agents",
+		Summary: "Get agent configuration",
+		Description: "Retrieves the configuration the agent should act on. " +
+			"This is the configuration the agent is pinned to, if it has " +
+			"been pinned, or otherwise the current rules of the active " +
+			"resources it reports for. Disabled agents can not retrieve " +
+			"their configuration.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{
+				Name: "If-None-Match",
+				In:   "header",
+				Type: "string",
+				Description: "The ETag of a previously retrieved " +
+					"configuration. If the configuration has not changed, a " +
+					"304 response is returned without a body.",
+			},
+		},
+		Responses: map[int]string{
+			200: "agent_config",
+			304: "The configuration has not changed.",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"POST /agents/{id}/config/pin": {
+		ID:      "pin_agent_config",
+		Tag:     "agents",
+		Summary: "Pin agent configuration",
+		Description: "Pins the agent to the current version of its " +
+			"configuration, so that later changes to the rules of its " +
+			"resources are not distributed to it until it is unpinned. If a " +
+			"version is given in the request body, it must match the current " +
+			"version, otherwise a 409 response is returned.",
+		Scopes:       []string{"resource:admin"},
+		Params:       []*Parameter{{Name: "id"}},
+		Body:         "agent_config_pin",
+		BodyOptional: true,
+		Responses: map[int]string{
+			200: "agent",
+			400: "user_error",
+			409: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /agents/{id}/config/pin": {
+		ID:      "unpin_agent_config",
+		Tag:     "agents",
+		Summary: "Unpin agent configuration",
+		Description: "Unpins the configuration of the agent, so that the " +
+			"current rules of its resources are distributed to it again.",
+		Scopes: []string{"resource:admin"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "agent",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// SearchAgent is the search handler function for agents.
+func (s *Server) SearchAgent(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, sum, err := svc.GetAgents(ctx, q, opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if q.Summary != "" {
+		env := s.newEnvelope(r, q, []*resource.Agent{}, false)
+
+		env.Summary = sum
+
+		s.encodeList(env, opts, "agent_id", w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), opts, "agent_id", w, r)
+}
+
+// GetAgent is the get handler function for agents.
+func (s *Server) GetAgent(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetAgent(ctx, id, opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	s.encodeFields(res, opts, "agent_id", w, r)
+}
+
+// PostAgent is the post handler function used to register agents.
+func (s *Server) PostAgent(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesWrite); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &resource.Agent{}
+
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.RegisterAgent(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	scheme := "https"
+	if strings.Contains(r.Host, "localhost") {
+		scheme = "http"
+	}
+
+	loc := &url.URL{
+		Scheme: scheme,
+		Host:   r.Host,
+		Path:   strings.TrimSuffix(r.URL.Path, "/") + "/" + res.AgentID.Value,
+	}
+
+	w.Header().Set("Location", loc.String())
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostAgentHeartbeat is the post handler function for agent heartbeats. The
+// request body is optional, and may contain the current version, status data
+// and reported resources of the agent.
+func (s *Server) PostAgentHeartbeat(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesWrite); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &resource.Agent{}
+
+	if err := decodeRequest(r, &req); err != nil &&
+		!errors.Is(err, io.EOF) {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req.AgentID = request.FieldString{
+		Set: true, Valid: true,
+		Value: chi.URLParam(r, "id"),
+	}
+
+	res, err := svc.HeartbeatAgent(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostDisableAgent is the post handler function used to disable agents.
+func (s *Server) PostDisableAgent(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.DisableAgent(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostEnableAgent is the post handler function used to enable agents.
+func (s *Server) PostEnableAgent(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.EnableAgent(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// DeleteAgent is the delete handler function for agents.
+func (s *Server) DeleteAgent(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := svc.DeleteAgent(ctx, chi.URLParam(r, "id")); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAgentConfig is the get handler function for agent configuration. The
+// response includes an ETag header, so that agents can poll for changes using
+// If-None-Match requests.
+func (s *Server) GetAgentConfig(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetAgentConfig(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	s.encodeFields(res, nil, "agent_id", w, r)
+}
+
+// PostAgentConfigPin is the post handler function used to pin agents to the
+// current version of their configuration. The request body is optional, and
+// may contain the version expected to be pinned.
+func (s *Server) PostAgentConfigPin(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &resource.AgentConfig{}
+
+	if err := decodeRequest(r, &req); err != nil &&
+		!errors.Is(err, io.EOF) {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.PinAgentConfig(ctx, chi.URLParam(r, "id"), req.Version)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// DeleteAgentConfigPin is the delete handler function used to unpin the
+// configuration of agents.
+func (s *Server) DeleteAgentConfigPin(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.UnpinAgentConfig(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
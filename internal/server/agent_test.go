@@ -0,0 +1,347 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestAgent = resource.Agent{
+	AgentID: request.FieldString{
+		Set: true, Valid: true,
+		Value: "test-agent",
+	},
+	Name: request.FieldString{
+		Set: true, Valid: true,
+		Value: "testName",
+	},
+	Version: request.FieldString{
+		Set: true, Valid: true,
+		Value: "1",
+	},
+	Status: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.StatusActive,
+	},
+	Resources: request.FieldStringArray{
+		Set: true, Valid: true,
+		Value: []string{TestUUID},
+	},
+	LastSeenAt: request.FieldTime{
+		Set: true, Valid: true,
+		Value: 1,
+	},
+}
+
+func (m *mockResourceService) GetAgents(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.Agent, []*sqldb.SummaryData, error) {
+	return []*resource.Agent{&TestAgent}, []*sqldb.SummaryData{{
+		"status": TestAgent.Status.Value,
+		"count":  1,
+	}}, nil
+}
+
+func (m *mockResourceService) GetAgent(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*resource.Agent, error) {
+	return &TestAgent, nil
+}
+
+func (m *mockResourceService) RegisterAgent(ctx context.Context,
+	v *resource.Agent,
+) (*resource.Agent, error) {
+	return &TestAgent, nil
+}
+
+func (m *mockResourceService) HeartbeatAgent(ctx context.Context,
+	v *resource.Agent,
+) (*resource.Agent, error) {
+	return &TestAgent, nil
+}
+
+func (m *mockResourceService) DisableAgent(ctx context.Context,
+	id string,
+) (*resource.Agent, error) {
+	return &TestAgent, nil
+}
+
+func (m *mockResourceService) EnableAgent(ctx context.Context,
+	id string,
+) (*resource.Agent, error) {
+	return &TestAgent, nil
+}
+
+func (m *mockResourceService) DeleteAgent(ctx context.Context,
+	id string,
+) error {
+	return nil
+}
+
+func (m *mockResourceService) GetAgentConfig(ctx context.Context,
+	id string,
+) (*resource.AgentConfig, error) {
+	return resource.NewAgentConfig(id, []*resource.AgentResource{{
+		ResourceID: TestResource.ResourceID,
+		Name:       TestResource.Name,
+		KeyField:   TestResource.KeyField,
+	}})
+}
+
+func (m *mockResourceService) PinAgentConfig(ctx context.Context,
+	id, version string,
+) (*resource.Agent, error) {
+	return &TestAgent, nil
+}
+
+func (m *mockResourceService) UnpinAgentConfig(ctx context.Context,
+	id string,
+) (*resource.Agent, error) {
+	return &TestAgent, nil
+}
+
+func TestAgents(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		method string
+		url    string
+		body   string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "search",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/agents",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"agent_id":"` + TestAgent.AgentID.Value + `"`,
+	}, {
+		name:   "summary",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/agents?summary=status",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"count":1`,
+	}, {
+		name:   "get",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/agents/" + TestAgent.AgentID.Value,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"last_seen_at":`,
+	}, {
+		name:   "register",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/agents",
+		body: `{
+			"agent_id":"` + TestAgent.AgentID.Value + `",
+			"name":"test",
+			"resources":["` + TestUUID + `"]
+		}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusCreated,
+		resp:   `"agent_id":"` + TestAgent.AgentID.Value + `"`,
+	}, {
+		name:   "heartbeat",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url: basePath + "/agents/" + TestAgent.AgentID.Value +
+			"/heartbeat",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"status":"` + request.StatusActive + `"`,
+	}, {
+		name:   "invalid heartbeat",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url: basePath + "/agents/" + TestAgent.AgentID.Value +
+			"/heartbeat",
+		body:   `{"version":`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `unable to decode request`,
+	}, {
+		name:   "disable forbidden",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/agents/" + TestAgent.AgentID.Value + "/disable",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+	}, {
+		name:   "disable",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/agents/" + TestAgent.AgentID.Value + "/disable",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"agent_id":"` + TestAgent.AgentID.Value + `"`,
+	}, {
+		name:   "enable",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/agents/" + TestAgent.AgentID.Value + "/enable",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"agent_id":"` + TestAgent.AgentID.Value + `"`,
+	}, {
+		name:   "get config",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/agents/" + TestAgent.AgentID.Value + "/config",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"resource_id":"` + TestResource.ResourceID.Value + `"`,
+	}, {
+		name:   "pin config forbidden",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url: basePath + "/agents/" + TestAgent.AgentID.Value +
+			"/config/pin",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+	}, {
+		name:   "pin config",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url: basePath + "/agents/" + TestAgent.AgentID.Value +
+			"/config/pin",
+		body:   `{"version":"test"}`,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"agent_id":"` + TestAgent.AgentID.Value + `"`,
+	}, {
+		name:   "unpin config",
+		w:      httptest.NewRecorder(),
+		method: http.MethodDelete,
+		url: basePath + "/agents/" + TestAgent.AgentID.Value +
+			"/config/pin",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"agent_id":"` + TestAgent.AgentID.Value + `"`,
+	}, {
+		name:   "delete",
+		w:      httptest.NewRecorder(),
+		method: http.MethodDelete,
+		url:    basePath + "/agents/" + TestAgent.AgentID.Value,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusNoContent,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.NewBufferString(tt.body)
+
+			r, err := http.NewRequest(tt.method, tt.url, buf)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestAgentConfigNotModified(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	u := basePath + "/agents/" + TestAgent.AgentID.Value + "/config"
+
+	r, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", "test")
+
+	w := httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected code: %v with ETag, got: %v, %v",
+			http.StatusOK, w.Code, etag)
+	}
+
+	r, err = http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", "test")
+	r.Header.Set("If-None-Match", etag)
+
+	w = httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Code expected: %v, got: %v", http.StatusNotModified, w.Code)
+	}
+}
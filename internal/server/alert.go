@@ -0,0 +1,382 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/go-chi/chi/v5"
+)
+
+// AlertHandler performs routing for alert requests.
+func (s *Server) AlertHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/rules", s.SearchAlertRule)
+	r.With(s.Stat, s.Trace, s.Auth).Get("/rules/{id}", s.GetAlertRule)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/rules", s.PostAlertRule)
+	r.With(s.Stat, s.Trace, s.Auth).Patch("/rules/{id}", s.PatchAlertRule)
+	r.With(s.Stat, s.Trace, s.Auth).Delete("/rules/{id}",
+		s.DeleteAlertRule)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.SearchAlert)
+
+	return r
+}
+
+// alertOperations documents the alert routes.
+var alertOperations = map[string]*Operation{
+	"GET /alerts": {
+		ID:      "search_alerts",
+		Tag:     "alerts",
+		Summary: "Search alerts",
+		Description: "Retrieves the alerts raised by alert rules for " +
+			"resources, based on a search query. Bare search terms match " +
+			"the state of the alerts.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "case_insensitive"},
+			{Name: "summary"},
+			{Name: "envelope"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "alerts",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /alerts/rules": {
+		ID:          "search_alert_rules",
+		Tag:         "alerts",
+		Summary:     "Search alert rules",
+		Description: "Retrieves alert rules based on a search query.",
+		Scopes:      []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "case_insensitive"},
+			{Name: "summary"},
+			{Name: "envelope"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "alert_rules",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /alerts/rules": {
+		ID:      "create_alert_rule",
+		Tag:     "alerts",
+		Summary: "Create alert rule",
+		Description: "Creates an alert rule. The search of the rule is " +
+			"evaluated periodically against the resources of the account.",
+		Scopes: []string{"resource:admin"},
+		Body:   "alert_rule",
+		Responses: map[int]string{
+			201: "alert_rule",
+			400: "user_error",
+			409: "user_error",
+			500: "error",
+		},
+	},
+	"GET /alerts/rules/{id}": {
+		ID:          "get_alert_rule",
+		Tag:         "alerts",
+		Summary:     "Get alert rule",
+		Description: "Retrieves details for a specific alert rule.",
+		Scopes:      []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "alert_rule",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"PATCH /alerts/rules/{id}": {
+		ID:      "update_alert_rule",
+		Tag:     "alerts",
+		Summary: "Update alert rule",
+		Description: "Updates details for a specific alert rule. Alerts " +
+			"already raised by the rule are updated the next time it is " +
+			"evaluated.",
+		Scopes: []string{"resource:admin"},
+		Params: []*Parameter{{Name: "id"}},
+		Body:   "alert_rule",
+		Responses: map[int]string{
+			200: "alert_rule",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /alerts/rules/{id}": {
+		ID:      "delete_alert_rule",
+		Tag:     "alerts",
+		Summary: "Delete alert rule",
+		Description: "Deletes a specific alert rule, and the alerts raised " +
+			"by it.",
+		Scopes: []string{"resource:admin"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// SearchAlert is the search handler function for alerts.
+func (s *Server) SearchAlert(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, sum, err := svc.GetAlerts(ctx, q, opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if q.Summary != "" {
+		env := s.newEnvelope(r, q, []*resource.Alert{}, false)
+
+		env.Summary = sum
+
+		s.encodeList(env, opts, "resource_id", w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), opts, "resource_id", w, r)
+}
+
+// SearchAlertRule is the search handler function for alert rules.
+func (s *Server) SearchAlertRule(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, sum, err := svc.GetAlertRules(ctx, q, opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if q.Summary != "" {
+		env := s.newEnvelope(r, q, []*resource.AlertRule{}, false)
+
+		env.Summary = sum
+
+		s.encodeList(env, opts, "alert_rule_id", w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), opts, "alert_rule_id", w, r)
+}
+
+// GetAlertRule is the get handler function for alert rules.
+func (s *Server) GetAlertRule(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetAlertRule(ctx, chi.URLParam(r, "id"), opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	s.encodeFields(res, opts, "alert_rule_id", w, r)
+}
+
+// PostAlertRule is the post handler function used to create alert rules.
+func (s *Server) PostAlertRule(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &resource.AlertRule{}
+
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.CreateAlertRule(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	scheme := "https"
+	if strings.Contains(r.Host, "localhost") {
+		scheme = "http"
+	}
+
+	loc := &url.URL{
+		Scheme: scheme,
+		Host:   r.Host,
+		Path: strings.TrimSuffix(r.URL.Path, "/") + "/" +
+			res.AlertRuleID.Value,
+	}
+
+	w.Header().Set("Location", loc.String())
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PatchAlertRule is the patch handler function for alert rules.
+func (s *Server) PatchAlertRule(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &resource.AlertRule{}
+
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req.AlertRuleID = request.FieldString{
+		Set: true, Valid: true,
+		Value: chi.URLParam(r, "id"),
+	}
+
+	res, err := svc.UpdateAlertRule(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// DeleteAlertRule is the delete handler function for alert rules.
+func (s *Server) DeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := svc.DeleteAlertRule(ctx, chi.URLParam(r, "id")); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,244 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestAlertRule = resource.AlertRule{
+	AlertRuleID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestUUID,
+	},
+	Name: request.FieldString{
+		Set: true, Valid: true,
+		Value: "testName",
+	},
+	Search: request.FieldString{
+		Set: true, Valid: true,
+		Value: "and(status:error)",
+	},
+	Duration: request.FieldInt64{
+		Set: true, Valid: true,
+		Value: 300,
+	},
+	Channels: request.FieldStringArray{
+		Set: true, Valid: true,
+		Value: []string{"https://hooks.example.com/alerts"},
+	},
+	Status: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.StatusActive,
+	},
+}
+
+var TestAlert = resource.Alert{
+	AlertRuleID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestUUID,
+	},
+	ResourceID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestResource.ResourceID.Value,
+	},
+	State: request.FieldString{
+		Set: true, Valid: true,
+		Value: resource.AlertStateFiring,
+	},
+	StartedAt: request.FieldTime{
+		Set: true, Valid: true,
+		Value: 1,
+	},
+	FiredAt: request.FieldTime{
+		Set: true, Valid: true,
+		Value: 301,
+	},
+}
+
+func (m *mockResourceService) GetAlertRules(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.AlertRule, []*sqldb.SummaryData, error) {
+	return []*resource.AlertRule{&TestAlertRule}, []*sqldb.SummaryData{{
+		"status": TestAlertRule.Status.Value,
+		"count":  1,
+	}}, nil
+}
+
+func (m *mockResourceService) GetAlertRule(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*resource.AlertRule, error) {
+	return &TestAlertRule, nil
+}
+
+func (m *mockResourceService) CreateAlertRule(ctx context.Context,
+	v *resource.AlertRule,
+) (*resource.AlertRule, error) {
+	return &TestAlertRule, nil
+}
+
+func (m *mockResourceService) UpdateAlertRule(ctx context.Context,
+	v *resource.AlertRule,
+) (*resource.AlertRule, error) {
+	return &TestAlertRule, nil
+}
+
+func (m *mockResourceService) DeleteAlertRule(ctx context.Context,
+	id string,
+) error {
+	return nil
+}
+
+func (m *mockResourceService) GetAlerts(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.Alert, []*sqldb.SummaryData, error) {
+	return []*resource.Alert{&TestAlert}, []*sqldb.SummaryData{{
+		"state": TestAlert.State.Value,
+		"count": 1,
+	}}, nil
+}
+
+func TestAlerts(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		method string
+		url    string
+		body   string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "search",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/alerts",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"state":"` + resource.AlertStateFiring + `"`,
+	}, {
+		name:   "summary",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/alerts?summary=state",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"count":1`,
+	}, {
+		name:   "search rules",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/alerts/rules",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"alert_rule_id":"` + TestAlertRule.AlertRuleID.Value + `"`,
+	}, {
+		name:   "get rule",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/alerts/rules/" + TestAlertRule.AlertRuleID.Value,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"search":"` + TestAlertRule.Search.Value + `"`,
+	}, {
+		name:   "create rule forbidden",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/alerts/rules",
+		body:   `{"name":"test","search":"and(status:error)"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+	}, {
+		name:   "create rule",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/alerts/rules",
+		body:   `{"name":"test","search":"and(status:error)"}`,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusCreated,
+		resp:   `"alert_rule_id":"` + TestAlertRule.AlertRuleID.Value + `"`,
+	}, {
+		name:   "invalid create rule",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/alerts/rules",
+		body:   `{"name":`,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusBadRequest,
+		resp:   `unable to decode request`,
+	}, {
+		name:   "update rule",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPatch,
+		url:    basePath + "/alerts/rules/" + TestAlertRule.AlertRuleID.Value,
+		body:   `{"duration":60}`,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"alert_rule_id":"` + TestAlertRule.AlertRuleID.Value + `"`,
+	}, {
+		name:   "delete rule",
+		w:      httptest.NewRecorder(),
+		method: http.MethodDelete,
+		url:    basePath + "/alerts/rules/" + TestAlertRule.AlertRuleID.Value,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusNoContent,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.NewBufferString(tt.body)
+
+			r, err := http.NewRequest(tt.method, tt.url, buf)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
@@ -2,10 +2,10 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +13,7 @@ import (
 	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/dhaifley/apigo/internal/logger"
 	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
 	"github.com/dhaifley/apigo/internal/sqldb"
 	"github.com/go-chi/chi/v5"
 )
@@ -36,10 +37,23 @@ type AuthService interface {
 	CreateAccount(ctx context.Context,
 		v *auth.Account,
 	) (*auth.Account, error)
+	GetAccounts(ctx context.Context,
+		query *search.Query,
+	) ([]*auth.Account, error)
+	UpdateAccount(ctx context.Context,
+		v *auth.Account,
+	) (*auth.Account, error)
+	DeactivateAccount(ctx context.Context,
+		id string,
+	) (*auth.Account, error)
 	GetAccountRepo(ctx context.Context) (*auth.AccountRepo, error)
 	SetAccountRepo(ctx context.Context,
 		v *auth.AccountRepo,
 	) error
+	GetChanges(ctx context.Context,
+		since string,
+		size int64,
+	) (*auth.ChangeFeed, error)
 	GetUser(ctx context.Context,
 		id string,
 		options sqldb.FieldOptions,
@@ -50,12 +64,88 @@ type AuthService interface {
 	UpdateUser(ctx context.Context,
 		v *auth.User,
 	) (*auth.User, error)
+	GetGroups(ctx context.Context,
+		query *search.Query,
+		options sqldb.FieldOptions,
+	) ([]*auth.Group, error)
+	GetGroup(ctx context.Context,
+		id string,
+		options sqldb.FieldOptions,
+	) (*auth.Group, error)
+	CreateGroup(ctx context.Context,
+		v *auth.Group,
+	) (*auth.Group, error)
+	UpdateGroup(ctx context.Context,
+		v *auth.Group,
+	) (*auth.Group, error)
+	DeleteGroup(ctx context.Context,
+		id string,
+	) error
+	AddGroupUser(ctx context.Context,
+		id, userID string,
+	) (*auth.Group, error)
+	RemoveGroupUser(ctx context.Context,
+		id, userID string,
+	) (*auth.Group, error)
+	GetTokens(ctx context.Context,
+		query *search.Query,
+		options sqldb.FieldOptions,
+	) ([]*auth.IssuedToken, error)
 	Update(ctx context.Context,
 	) context.CancelFunc
+	UpdateTokenUsage(ctx context.Context,
+	) context.CancelFunc
+	RecordRequest(ctx context.Context,
+		operation, key string,
+		bytes int64,
+	)
+	CheckQuota(ctx context.Context) error
+	GetRequestUsage(ctx context.Context,
+		from, to string,
+	) (*auth.RequestUsage, error)
+	UpdateRequestUsage(ctx context.Context,
+	) context.CancelFunc
+	CreateSession(ctx context.Context,
+		userID, scopes, tenant string,
+	) (*auth.Session, error)
+	AuthSession(ctx context.Context,
+		id string,
+	) (*auth.Session, error)
+	DeleteSession(ctx context.Context,
+		id string,
+	) error
+	ChangePassword(ctx context.Context,
+		current, password string,
+	) error
+	TokenAccountID(ctx context.Context,
+		tenant string,
+	) (string, error)
+	GetSigningKeys(ctx context.Context,
+	) ([]*auth.SigningKey, error)
+	RotateSigningKey(ctx context.Context,
+	) (*auth.SigningKey, error)
+	RotateSigningKeys(ctx context.Context,
+	) context.CancelFunc
+	GetAuthFailures(ctx context.Context,
+	) ([]*auth.AuthFailure, error)
+	ClaimIdempotencyKey(ctx context.Context,
+		key, operation, hash string,
+	) (*auth.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context,
+		key, hash string,
+		res *auth.IdempotentResponse,
+	) error
+	ReleaseIdempotencyKey(ctx context.Context,
+		key, hash string,
+	) error
 }
 
-// Auth wraps an http handler with authentication verification.
+// Auth wraps an http handler with authentication verification. Requests
+// without an authentication token may be authenticated using a session
+// cookie, in which case they are also subject to CSRF verification.
 func (s *Server) Auth(next http.Handler) http.Handler {
+	next = s.CSRF(next)
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		svc := s.getAuthService(r)
 
@@ -84,9 +174,27 @@ func (s *Server) Auth(next http.Handler) http.Handler {
 
 		tenant := r.Header.Get("securitytenant")
 
+		csrf := ""
+
+		if token == "" {
+			if c, err := r.Cookie(sessionCookie); err == nil &&
+				c.Value != "" {
+				sess, err := svc.AuthSession(ctx, c.Value)
+				if err != nil {
+					s.error(err, w, r)
+
+					return
+				}
+
+				token, tenant, csrf = sess.Token, sess.Tenant, sess.CSRFToken
+			}
+		}
+
 		claims, err := svc.AuthJWT(ctx, token, tenant)
 		if err != nil {
 			if e, ok := err.(*errors.Error); ok {
+				setRetryAfter(w, e)
+
 				s.error(e, w, r)
 
 				return
@@ -111,38 +219,336 @@ func (s *Server) Auth(next http.Handler) http.Handler {
 				"request_remote", r.RemoteAddr)
 		}
 
+		// Tokens signed using a replaced signing key must be issued again
+		// before the key expires.
+		if claims.KeyExpiresAt != 0 {
+			w.Header().Set("X-Token-Reissue-By", time.Unix(claims.KeyExpiresAt,
+				0).UTC().Format(http.TimeFormat))
+		}
+
 		ctx = context.WithValue(ctx, request.CtxKeyJWT, token)
 
-		ctx = context.WithValue(ctx, request.CtxKeyAccountID, claims.AccountID)
+		ctx = request.WithAccountID(ctx, claims.AccountID)
 
-		ctx = context.WithValue(ctx, request.CtxKeyAccountName,
-			claims.AccountName)
+		ctx = request.WithAccountName(ctx, claims.AccountName)
 
-		ctx = context.WithValue(ctx, request.CtxKeyScopes, claims.Scopes)
+		ctx = request.WithScopes(ctx, claims.Scopes)
 
 		if claims.UserID != "" {
-			ctx = context.WithValue(ctx, request.CtxKeyUserID, claims.UserID)
+			ctx = request.WithUserID(ctx, claims.UserID)
+		}
+
+		if csrf != "" {
+			ctx = context.WithValue(ctx, request.CtxKeyCSRFToken, csrf)
+		}
+
+		if err := s.accountMaintenance(ctx, svc); err != nil {
+			s.error(err, w, r)
+
+			return
+		}
+
+		if loc := s.accountTimeZone(ctx, r, svc); loc != nil {
+			ctx = context.WithValue(ctx, request.CtxKeyTimeZone, loc)
+		}
+
+		if err := svc.CheckQuota(ctx); err != nil {
+			setRetryAfter(w, err)
+
+			s.error(err, w, r)
+
+			return
+		}
+
+		operation := s.requestOperation(r)
+
+		// The request body is counted as it is read, so that the bytes
+		// ingested by the account are recorded.
+		body := &countReader{ReadCloser: r.Body}
+
+		if r.Body != nil {
+			r.Body = body
 		}
 
 		next.ServeHTTP(w, r.WithContext(ctx))
+
+		svc.RecordRequest(ctx, operation, r.Header.Get("Idempotency-Key"),
+			body.n)
 	})
 }
 
+// accountMaintenance returns an error if the authenticated account has been
+// placed into maintenance by a system administrator, so that the account can be
+// frozen, such as while its data is migrated, without placing the whole service
+// into maintenance. System administrators can still make requests for the
+// account.
+func (s *Server) accountMaintenance(ctx context.Context,
+	svc AuthService,
+) error {
+	if request.ContextHasScope(ctx, request.ScopeSuperuser) {
+		return nil
+	}
+
+	aID, err := request.ContextAccountID(ctx)
+	if err != nil || aID == "" || aID == request.SystemAccount {
+		return nil
+	}
+
+	a, err := svc.GetAccount(ctx, aID)
+	if err != nil {
+		s.log.Log(ctx, logger.LvlWarn,
+			"unable to retrieve account status",
+			"error", err,
+			"account_id", aID)
+
+		return nil
+	}
+
+	if a.Status.Value == request.StatusMaintenance {
+		return errors.New(errors.ErrMaintenance,
+			"The account is currently undergoing maintenance, "+
+				"please try back later",
+			"account_id", aID)
+	}
+
+	return nil
+}
+
+// accountTimeZone retrieves the default time zone of the authenticated account,
+// if the request did not specify a time zone and contains search parameters,
+// which are the only request time inputs interpreted using a time zone.
+func (s *Server) accountTimeZone(ctx context.Context,
+	r *http.Request,
+	svc AuthService,
+) *time.Location {
+	if _, err := request.ContextTimeZone(ctx); err == nil ||
+		!r.URL.Query().Has("search") {
+		return nil
+	}
+
+	aID, err := request.ContextAccountID(ctx)
+	if err != nil || aID == "" {
+		return nil
+	}
+
+	a, err := svc.GetAccount(ctx, aID)
+	if err != nil {
+		s.log.Log(ctx, logger.LvlWarn,
+			"unable to retrieve account time zone",
+			"error", err,
+			"account_id", aID)
+
+		return nil
+	}
+
+	return a.TimeZone()
+}
+
 // AccountHandler performs routing for account requests.
 func (s *Server) AccountHandler() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(s.dbAvail)
 
+	r.With(s.Stat, s.Trace, s.Auth).Get("/usage", s.GetAccountUsage)
+
 	r.With(s.Stat, s.Trace, s.Auth).Get("/repo", s.GetAccountRepo)
 	r.With(s.Stat, s.Trace, s.Auth).Post("/repo", s.PostAccountRepo)
 
+	r.With(s.Stat, s.Trace, s.Auth).Get("/keys", s.GetAccountKeys)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/keys/rotate",
+		s.PostAccountKeysRotate)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/failures", s.GetAccountFailures)
+
 	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.GetAccount)
 	r.With(s.Stat, s.Trace, s.Auth).Post("/", s.PostAccount)
 
 	return r
 }
 
+// accountOperations documents the account routes.
+var accountOperations = map[string]*Operation{
+	"GET /account": {
+		ID:          "get_account",
+		Tag:         "account",
+		Summary:     "Get account",
+		Description: "Retrieves details for the current account.",
+		Scopes:      []string{"account:read"},
+		Responses: map[int]string{
+			200: "account",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /account": {
+		ID:      "create_account",
+		Tag:     "accounts",
+		Summary: "Create account",
+		Description: "Creates a new account, or re-creates an existing one. " +
+			"If a template repository is configured, new accounts are " +
+			"populated with its settings and resources.",
+		Scopes: []string{"account:admin"},
+		Body:   "account",
+		Responses: map[int]string{
+			201: "account",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /account/repo": {
+		ID:          "get_account_repo",
+		Tag:         "account",
+		Summary:     "Get account import repository",
+		Description: "Retrieves details for the account import repository.",
+		Scopes:      []string{"account:read"},
+		Responses: map[int]string{
+			200: "account_repo",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /account/repo": {
+		ID:      "create_account_repo",
+		Tag:     "account",
+		Summary: "Create account import repository",
+		Description: "Creates or updates details for the account import " +
+			"repository. Admin access is required to perform this operation.",
+		Scopes: []string{"account:write"},
+		Body:   "account_repo",
+		Responses: map[int]string{
+			201: "account_repo",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /account/keys": {
+		ID:      "get_account_keys",
+		Tag:     "account",
+		Summary: "Get account signing keys",
+		Description: "Retrieves the unexpired keys used to sign the tokens " +
+			"issued by the account, starting with the active key. Key IDs " +
+			"are the kid headers of the tokens signed using the keys.",
+		Scopes: []string{"account:read"},
+		Responses: map[int]string{
+			200: "signing_keys",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /account/keys/rotate": {
+		ID:      "rotate_account_keys",
+		Tag:     "account",
+		Summary: "Rotate account signing key",
+		Description: "Replaces the active key used to sign the tokens " +
+			"issued by the account. Tokens signed using the replaced key " +
+			"remain valid for a grace period, during which responses to " +
+			"requests using them contain an X-Token-Reissue-By header, " +
+			"giving the time by which they must be issued again.",
+		Scopes: []string{"account:admin"},
+		Responses: map[int]string{
+			201: "signing_key",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /account/failures": {
+		ID:      "get_account_failures",
+		Tag:     "account",
+		Summary: "Get failed authentication attempts",
+		Description: "Retrieves the recent failed authentication attempts, " +
+			"newest first, made using tokens issued by the account, as " +
+			"seen by the service instance handling the request. Failures " +
+			"for all accounts, and for tokens not issued by an account, " +
+			"are retrieved by the system account. Client addresses with " +
+			"too many failed attempts are locked out for a time, which " +
+			"doubles with each lockout.",
+		Scopes: []string{"account:admin"},
+		Responses: map[int]string{
+			200: "auth_failures",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// AccountsHandler performs routing for the administration of all accounts.
+func (s *Server) AccountsHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.Auth).Post("/{id}/deactivate",
+		s.PostDeactivateAccount)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.SearchAccount)
+	r.With(s.Stat, s.Trace, s.Auth).Put("/{id}", s.PutAccountByID)
+
+	return r
+}
+
+// accountsOperations documents the account administration routes.
+var accountsOperations = map[string]*Operation{
+	"GET /accounts": {
+		ID:      "search_accounts",
+		Tag:     "accounts",
+		Summary: "Search accounts",
+		Description: "Retrieves accounts, across all tenants, based on a " +
+			"search query. System administrator access is required.",
+		Scopes: []string{"superuser"},
+		Params: []*Parameter{
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "case_insensitive"},
+			{Name: "envelope"},
+		},
+		Responses: map[int]string{
+			200: "accounts",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"PUT /accounts/{id}": {
+		ID:      "update_accounts",
+		Tag:     "accounts",
+		Summary: "Update any account",
+		Description: "Updates details for a specific account. Setting the " +
+			"status to inactive also invalidates all of the tokens issued " +
+			"for the account. Setting the status to maintenance rejects " +
+			"requests for the account with a 503 response, without " +
+			"invalidating its tokens, until the status is set to active " +
+			"again. System administrator access is required.",
+		Scopes: []string{"superuser"},
+		Params: []*Parameter{{Name: "id"}},
+		Body:   "account",
+		Responses: map[int]string{
+			200: "account",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"POST /accounts/{id}/deactivate": {
+		ID:      "deactivate_account",
+		Tag:     "accounts",
+		Summary: "Deactivate account",
+		Description: "Makes a specific account inactive, and invalidates " +
+			"all of the tokens issued for the account. Requests for " +
+			"inactive accounts are rejected. System administrator access " +
+			"is required.",
+		Scopes: []string{"superuser"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "account",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+}
+
 // checkScope verifies the request has the specified scope. It returns false
 // following an error response if the required scope is missing.
 func (s *Server) checkScope(ctx context.Context, scope string) error {
@@ -173,7 +579,7 @@ func (s *Server) GetAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -192,14 +598,8 @@ func (s *Server) PostAccount(w http.ResponseWriter, r *http.Request) {
 
 	req := &auth.Account{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -211,6 +611,20 @@ func (s *Server) PostAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// New accounts are populated with the resources of the template
+	// repository. A failure to do so does not prevent the account from being
+	// created, since the resources can still be imported afterwards.
+	rSvc := s.getResourceService(r)
+
+	aCtx := request.WithAccountID(ctx, res.AccountID.Value)
+
+	if _, err := rSvc.BootstrapResources(aCtx); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to copy template resources to account",
+			"error", err,
+			"account_id", res.AccountID.Value)
+	}
+
 	scheme := "https"
 	if strings.Contains(r.Host, "localhost") {
 		scheme = "http"
@@ -226,7 +640,7 @@ func (s *Server) PostAccount(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusCreated)
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -250,7 +664,182 @@ func (s *Server) GetAccountRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// GetAccountKeys is the get handler function for account signing keys.
+func (s *Server) GetAccountKeys(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeAccountRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetSigningKeys(ctx)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostAccountKeysRotate is the post handler function for the rotation of
+// account signing keys.
+func (s *Server) PostAccountKeysRotate(w http.ResponseWriter,
+	r *http.Request,
+) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeAccountAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.RotateSigningKey(ctx)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// GetAccountFailures is the get handler function for the recent failed
+// authentication attempts using tokens of the account.
+func (s *Server) GetAccountFailures(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeAccountAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetAuthFailures(ctx)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// SearchAccount is the search handler function for the accounts of all
+// tenants.
+func (s *Server) SearchAccount(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeSuperuser); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetAccounts(ctx, q)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), nil, "account_id", w, r)
+}
+
+// PutAccountByID is the put handler function for the accounts of all
+// tenants.
+func (s *Server) PutAccountByID(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeSuperuser); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &auth.Account{}
+
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req.AccountID = request.FieldString{
+		Set: true, Valid: true,
+		Value: chi.URLParam(r, "id"),
+	}
+
+	res, err := svc.UpdateAccount(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostDeactivateAccount is the post handler function used to deactivate
+// accounts.
+func (s *Server) PostDeactivateAccount(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeSuperuser); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.DeactivateAccount(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -269,14 +858,8 @@ func (s *Server) PostAccountRepo(w http.ResponseWriter, r *http.Request) {
 
 	req := &auth.AccountRepo{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -302,7 +885,7 @@ func (s *Server) PostAccountRepo(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusCreated)
 
-	if err := json.NewEncoder(w).Encode(req); err != nil {
+	if err := encodeResponse(w, r, req); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -320,6 +903,48 @@ func (s *Server) UserHandler() http.Handler {
 	return r
 }
 
+// userOperations documents the user routes.
+var userOperations = map[string]*Operation{
+	"GET /user": {
+		ID:          "get_user",
+		Tag:         "user",
+		Summary:     "Get user",
+		Description: "Retrieves details for the current user.",
+		Scopes:      []string{"user:read"},
+		Responses: map[int]string{
+			200: "user",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"PATCH /user": {
+		ID:          "update_user",
+		Tag:         "user",
+		Summary:     "Update user",
+		Description: "Updates details for the current user.",
+		Scopes:      []string{"user:write"},
+		Body:        "user",
+		Responses: map[int]string{
+			200: "user",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"PUT /user": {
+		ID:          "replace_user",
+		Tag:         "user",
+		Summary:     "Replace user",
+		Description: "Updates details for the current user.",
+		Scopes:      []string{"resource:write"},
+		Body:        "user",
+		Responses: map[int]string{
+			200: "user",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
 // GetUser is the get handler function for users.
 func (s *Server) GetUser(w http.ResponseWriter, r *http.Request) {
 	svc := s.getAuthService(r)
@@ -346,9 +971,7 @@ func (s *Server) GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
-		s.error(err, w, r)
-	}
+	s.encodeFields(res, opts, "user_id", w, r)
 }
 
 // PutUser is the put handler function for users.
@@ -365,14 +988,8 @@ func (s *Server) PutUser(w http.ResponseWriter, r *http.Request) {
 
 	req := &auth.User{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -388,7 +1005,7 @@ func (s *Server) PutUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -399,11 +1016,51 @@ func (s *Server) LoginHandler() http.Handler {
 
 	r.Use(s.dbAvail)
 
-	r.With(s.Stat, s.Trace).Post("/token", s.PostLoginToken)
+	r.With(s.Stat, s.Trace, s.LoginLimit).Post("/token", s.PostLoginToken)
 
 	return r
 }
 
+// loginOperations documents the login routes.
+var loginOperations = map[string]*Operation{
+	"POST /login/token": {
+		ID:      "create_login_token",
+		Tag:     "user",
+		Summary: "Create access token",
+		Description: "Authenticates a user with a password, and creates an " +
+			"API access token with the requested scopes, which must be held " +
+			"by the user.",
+		Public:   true,
+		Params:   []*Parameter{idempotencyParam},
+		Body:     "token_request",
+		BodyType: "application/x-www-form-urlencoded",
+		Responses: map[int]string{
+			200: "token",
+			400: "user_error",
+			401: "user_error",
+			409: "user_error",
+			429: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// tokenRequest parses and validates the password authentication request form
+// of a login or session request.
+func tokenRequest(r *http.Request) (*auth.TokenRequest, error) {
+	req := &auth.TokenRequest{
+		Username: r.FormValue("username"),
+		Password: r.FormValue("password"),
+		Scope:    r.FormValue("scope"),
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
 // PostLoginToken is the post handler for password authentication to obtain an
 // API access token.
 func (s *Server) PostLoginToken(w http.ResponseWriter, r *http.Request) {
@@ -413,30 +1070,60 @@ func (s *Server) PostLoginToken(w http.ResponseWriter, r *http.Request) {
 
 	tenant := r.Header.Get("securitytenant")
 
-	if err := svc.AuthPassword(ctx,
-		r.FormValue("username"),
-		r.FormValue("password"),
-		tenant); err != nil {
+	req, err := tokenRequest(r)
+	if err != nil {
 		s.error(err, w, r)
 
 		return
 	}
 
-	tok, err := svc.CreateToken(ctx, r.FormValue("username"),
-		time.Now().Add(s.cfg.AuthTokenExpiresIn()).Unix(),
-		r.FormValue("scope"), tenant)
-	if err != nil {
+	if err := svc.AuthPassword(ctx, req.Username, req.Password,
+		tenant); err != nil {
 		s.error(err, w, r)
 
 		return
 	}
 
-	res := map[string]any{
-		"access_token": tok,
-		"token_type":   "bearer",
+	create := func(w http.ResponseWriter, r *http.Request) {
+		tok, err := svc.CreateToken(ctx, req.Username,
+			time.Now().Add(s.cfg.AuthTokenExpiresIn()).Unix(),
+			req.Scope, tenant)
+		if err != nil {
+			s.error(err, w, r)
+
+			return
+		}
+
+		res := &auth.Token{
+			AccessToken: tok,
+			TokenType:   "bearer",
+		}
+
+		if err := encodeResponse(w, r, res); err != nil {
+			s.error(err, w, r)
+		}
+	}
+
+	if !s.idempotencyRequested(r) {
+		create(w, r)
+
+		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	// Retries are identified by the authenticated user and requested scope,
+	// so that passwords are not included in the retained request hash.
+	accountID, err := svc.TokenAccountID(ctx, tenant)
+	if err != nil {
 		s.error(err, w, r)
+
+		return
 	}
+
+	iCtx := request.WithAccountID(ctx, accountID)
+
+	iCtx = request.WithUserID(iCtx, req.Username)
+
+	r = r.WithContext(iCtx)
+
+	s.idempotent(w, r, requestHash(r, []byte(req.Scope)), create)
 }
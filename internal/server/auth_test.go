@@ -7,10 +7,13 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
 	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
 	"github.com/dhaifley/apigo/internal/server"
 	"github.com/dhaifley/apigo/internal/sqldb"
 )
@@ -56,6 +59,11 @@ var TestAccount = auth.Account{
 		Set: true, Valid: true,
 		Value: map[string]any{
 			"test": "test",
+			"branding": map[string]any{
+				"logo_url":      "https://apigo.io/logo.png",
+				"product_name":  "Test Product",
+				"contact_email": "test@apigo.io",
+			},
 		},
 	},
 }
@@ -119,6 +127,30 @@ func (m *mockAuthService) AuthJWT(ctx context.Context,
 			UserID:      TestUser.UserID.Value,
 			Scopes:      request.ScopeSuperuser,
 		}, nil
+	case "rotated":
+		return &auth.Claims{
+			AccountID:    TestAccount.AccountID.Value,
+			AccountName:  TestAccount.Name.Value,
+			UserID:       TestUser.UserID.Value,
+			Scopes:       request.ScopeAccountRead,
+			KeyExpiresAt: 1704067200,
+		}, nil
+	case "exhausted":
+		return &auth.Claims{
+			AccountID: "exhausted",
+			UserID:    TestUser.UserID.Value,
+			Scopes:    request.ScopeAccountRead,
+		}, nil
+	case "frozen":
+		return &auth.Claims{
+			AccountID: "frozen",
+			UserID:    TestUser.UserID.Value,
+			Scopes:    request.ScopeAccountRead,
+		}, nil
+	case "locked":
+		return nil, errors.New(errors.ErrorRateLimit,
+			"too many failed authentication attempts",
+			"retry_after", int64(60))
 	default:
 		return nil, errors.New(errors.ErrForbidden, "invalid auth token")
 	}
@@ -140,6 +172,16 @@ func (m *mockAuthService) CreateToken(ctx context.Context,
 
 func (m *mockAuthService) GetAccount(ctx context.Context, id string,
 ) (*auth.Account, error) {
+	if id == "frozen" {
+		a := TestAccount
+
+		a.Status = request.FieldString{
+			Set: true, Valid: true, Value: request.StatusMaintenance,
+		}
+
+		return &a, nil
+	}
+
 	return &TestAccount, nil
 }
 
@@ -149,6 +191,31 @@ func (m *mockAuthService) CreateAccount(ctx context.Context,
 	return &TestAccount, nil
 }
 
+func (m *mockAuthService) GetAccounts(ctx context.Context,
+	query *search.Query,
+) ([]*auth.Account, error) {
+	return []*auth.Account{&TestAccount}, nil
+}
+
+func (m *mockAuthService) UpdateAccount(ctx context.Context,
+	v *auth.Account,
+) (*auth.Account, error) {
+	return &TestAccount, nil
+}
+
+func (m *mockAuthService) DeactivateAccount(ctx context.Context,
+	id string,
+) (*auth.Account, error) {
+	res := TestAccount
+
+	res.Status = request.FieldString{
+		Set: true, Valid: true,
+		Value: request.StatusInactive,
+	}
+
+	return &res, nil
+}
+
 func (m *mockAuthService) GetAccountRepo(ctx context.Context,
 ) (*auth.AccountRepo, error) {
 	return &auth.AccountRepo{
@@ -163,12 +230,63 @@ func (m *mockAuthService) GetAccountRepo(ctx context.Context,
 	}, nil
 }
 
+func (m *mockAuthService) GetSigningKeys(ctx context.Context,
+) ([]*auth.SigningKey, error) {
+	return []*auth.SigningKey{{
+		KeyID:     TestAccount.AccountID.Value + "#" + TestUUID,
+		Active:    true,
+		CreatedAt: 1704067200,
+	}}, nil
+}
+
+func (m *mockAuthService) RotateSigningKey(ctx context.Context,
+) (*auth.SigningKey, error) {
+	return &auth.SigningKey{
+		KeyID:     TestAccount.AccountID.Value + "#" + TestUUID,
+		Active:    true,
+		CreatedAt: 1704067200,
+	}, nil
+}
+
+func (m *mockAuthService) RotateSigningKeys(ctx context.Context,
+) context.CancelFunc {
+	_, cancel := context.WithCancel(ctx)
+
+	return cancel
+}
+
+func (m *mockAuthService) GetAuthFailures(ctx context.Context,
+) ([]*auth.AuthFailure, error) {
+	return []*auth.AuthFailure{{
+		Remote:    "192.0.2.1",
+		AccountID: TestAccount.AccountID.Value,
+		Reason:    "invalid authentication token",
+		Time:      1704067200,
+	}}, nil
+}
+
 func (m *mockAuthService) SetAccountRepo(ctx context.Context,
 	v *auth.AccountRepo,
 ) error {
 	return nil
 }
 
+func (m *mockAuthService) GetChanges(ctx context.Context,
+	since string,
+	size int64,
+) (*auth.ChangeFeed, error) {
+	return &auth.ChangeFeed{
+		Changes: []*auth.Change{{
+			Cursor:     "100-1",
+			EntityType: "account",
+			EntityID:   TestAccount.AccountID.Value,
+			Operation:  auth.ChangeOperationUpdate,
+			Timestamp:  1,
+		}},
+		Cursor: "100-1",
+	}, nil
+}
+
 func (m *mockAuthService) GetUser(ctx context.Context,
 	id string,
 	options sqldb.FieldOptions,
@@ -223,6 +341,13 @@ func TestAuth(t *testing.T) {
 		header: map[string]string{"Authorization": "test"},
 		code:   http.StatusOK,
 		resp:   `"account_id":"` + TestID + `"`,
+	}, {
+		name:   "maintenance",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/account",
+		header: map[string]string{"Authorization": "frozen"},
+		code:   http.StatusServiceUnavailable,
+		resp:   `account is currently undergoing maintenance`,
 	}}
 
 	for _, tt := range tests {
@@ -252,6 +377,104 @@ func TestAuth(t *testing.T) {
 	}
 }
 
+func TestAuthLockoutForwarded(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	cfg.SetServer(&config.ServerConfig{
+		PathPrefix:     basePath,
+		TrustedProxies: []string{"203.0.113.0/24"},
+	})
+
+	cfg.SetAuth(&config.AuthConfig{
+		FailureThreshold: 2,
+		FailureWindow:    time.Minute,
+		FailureLockout:   time.Minute,
+	})
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(auth.NewService(cfg, md, nil, nil, nil, nil))
+
+	authenticate := func(remote, forwarded string) int {
+		r, err := http.NewRequest(http.MethodGet, basePath+"/account", nil)
+		if err != nil {
+			t.Fatal("Failed to initialize request", err)
+		}
+
+		r.RemoteAddr = remote + ":1234"
+
+		r.Header.Set("Authorization", "invalid")
+
+		if forwarded != "" {
+			r.Header.Set("X-Forwarded-For", forwarded)
+		}
+
+		w := httptest.NewRecorder()
+
+		svr.Mux(w, r)
+
+		return w.Code
+	}
+
+	// Changing a spoofed forwarded address does not avoid a lockout.
+	for _, forwarded := range []string{"192.0.2.1", "192.0.2.2"} {
+		if code := authenticate("198.51.100.1",
+			forwarded); code != http.StatusUnauthorized {
+			t.Errorf("Code expected: %v, got: %v", http.StatusUnauthorized,
+				code)
+		}
+	}
+
+	if code := authenticate("198.51.100.1",
+		"192.0.2.3"); code != http.StatusTooManyRequests {
+		t.Errorf("Code expected: %v, got: %v", http.StatusTooManyRequests,
+			code)
+	}
+
+	// Spoofing the address of another client does not lock it out.
+	for range 2 {
+		authenticate("198.51.100.3", "198.51.100.4")
+	}
+
+	if code := authenticate("198.51.100.4", ""); code != http.StatusUnauthorized {
+		t.Errorf("Code expected: %v, got: %v", http.StatusUnauthorized, code)
+	}
+
+	// Clients of a trusted proxy are identified by the address it added.
+	for _, forwarded := range []string{
+		"192.0.2.1, 198.51.100.5", "192.0.2.2, 198.51.100.5, 203.0.113.2",
+	} {
+		if code := authenticate("203.0.113.1",
+			forwarded); code != http.StatusUnauthorized {
+			t.Errorf("Code expected: %v, got: %v", http.StatusUnauthorized,
+				code)
+		}
+	}
+
+	if code := authenticate("203.0.113.1",
+		"192.0.2.3, 198.51.100.5"); code != http.StatusTooManyRequests {
+		t.Errorf("Code expected: %v, got: %v", http.StatusTooManyRequests,
+			code)
+	}
+
+	if code := authenticate("203.0.113.1",
+		"198.51.100.6"); code != http.StatusUnauthorized {
+		t.Errorf("Code expected: %v, got: %v", http.StatusUnauthorized, code)
+	}
+}
+
 func TestGetAccount(t *testing.T) {
 	t.Parallel()
 
@@ -376,6 +599,104 @@ func TestPostAccount(t *testing.T) {
 	}
 }
 
+func TestAccounts(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		method string
+		url    string
+		header map[string]string
+		body   string
+		code   int
+		resp   string
+	}{{
+		name:   "search",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/accounts",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `[{"account_id":"` + TestID + `"`,
+	}, {
+		name:   "search forbidden",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/accounts",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+		resp:   `"code":"Forbidden"`,
+	}, {
+		name:   "update",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPut,
+		url:    basePath + "/accounts/" + TestID,
+		header: map[string]string{"Authorization": "admin"},
+		body:   `{"name":"testAccount"}`,
+		code:   http.StatusOK,
+		resp:   `"account_id":"` + TestID + `"`,
+	}, {
+		name:   "deactivate",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/accounts/" + TestID + "/deactivate",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"status":"inactive"`,
+	}, {
+		name:   "deactivate forbidden",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/accounts/" + TestID + "/deactivate",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+		resp:   `"code":"Forbidden"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.NewBufferString(tt.body)
+
+			r, err := http.NewRequest(tt.method, tt.url, buf)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
 func TestGetAccountRepo(t *testing.T) {
 	t.Parallel()
 
@@ -436,6 +757,177 @@ func TestGetAccountRepo(t *testing.T) {
 	}
 }
 
+func TestAccountKeys(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name    string
+		w       *httptest.ResponseRecorder
+		method  string
+		url     string
+		header  map[string]string
+		code    int
+		resp    string
+		reissue string
+	}{{
+		name:   "get",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/account/keys",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"active":true`,
+	}, {
+		name:    "get with rotated key",
+		w:       httptest.NewRecorder(),
+		method:  http.MethodGet,
+		url:     basePath + "/account/keys",
+		header:  map[string]string{"Authorization": "rotated"},
+		code:    http.StatusOK,
+		resp:    `"key_id":"`,
+		reissue: "Mon, 01 Jan 2024 00:00:00 GMT",
+	}, {
+		name:   "rotate",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/account/keys/rotate",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusCreated,
+		resp:   `"key_id":"` + TestAccount.AccountID.Value + "#",
+	}, {
+		name:   "rotate forbidden",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/account/keys/rotate",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+		resp:   `"code":"Forbidden"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			if v := tt.w.Header().Get("X-Token-Reissue-By"); v != tt.reissue {
+				t.Errorf("Expected reissue header: %v, got: %v",
+					tt.reissue, v)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestAccountFailures(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name       string
+		w          *httptest.ResponseRecorder
+		header     map[string]string
+		code       int
+		resp       string
+		retryAfter string
+	}{{
+		name:   "get",
+		w:      httptest.NewRecorder(),
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"remote":"192.0.2.1"`,
+	}, {
+		name:   "forbidden",
+		w:      httptest.NewRecorder(),
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+		resp:   `"code":"Forbidden"`,
+	}, {
+		name:       "locked out",
+		w:          httptest.NewRecorder(),
+		header:     map[string]string{"Authorization": "locked"},
+		code:       http.StatusTooManyRequests,
+		resp:       `"code":"RateLimit"`,
+		retryAfter: "60",
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet,
+				basePath+"/account/failures", nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			if v := tt.w.Header().Get("Retry-After"); v != tt.retryAfter {
+				t.Errorf("Expected retry after header: %v, got: %v",
+					tt.retryAfter, v)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
 func TestPostAccountRepo(t *testing.T) {
 	t.Parallel()
 
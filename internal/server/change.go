@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/go-chi/chi/v5"
+)
+
+// ChangeHandler performs routing for account change feed requests.
+func (s *Server) ChangeHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.GetChanges)
+
+	return r
+}
+
+// changeOperations documents the change feed routes.
+var changeOperations = map[string]*Operation{
+	"GET /changes": {
+		ID:      "get_changes",
+		Tag:     "account",
+		Summary: "Get account changes",
+		Description: "Retrieves an ordered feed of the changes made to the " +
+			"account and its resources following the since cursor. Changes " +
+			"are delivered at least once, and the cursor returned with each " +
+			"page may be used to resume the feed.",
+		Scopes: []string{"account:read"},
+		Params: []*Parameter{{Name: "since"}, {Name: "size"}},
+		Responses: map[int]string{
+			200: "change_feed",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// GetChanges is the get handler function for the account change feed.
+func (s *Server) GetChanges(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeAccountRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	size := int64(0)
+
+	if v := r.URL.Query().Get("size"); v != "" {
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid size",
+				"size", v), w, r)
+
+			return
+		}
+
+		size = i
+	}
+
+	res, err := svc.GetChanges(ctx, r.URL.Query().Get("since"), size)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
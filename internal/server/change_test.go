@@ -0,0 +1,85 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+func TestGetChanges(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		url    string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "success",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/changes?since=99-1&size=10",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"cursor":"100-1"`,
+	}, {
+		name:   "invalid size",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/changes?size=x",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `invalid size`,
+	}, {
+		name:   "forbidden",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/changes",
+		header: map[string]string{"Authorization": "invalid"},
+		code:   http.StatusForbidden,
+		resp:   `"Forbidden"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// contentType returns the Content-Type of the responses to a request, which
+// are encoded in YAML if the Accept header of the request prefers it, or JSON
+// otherwise.
+func contentType(r *http.Request) string {
+	if request.AcceptsYAML(r.Header.Get("Accept")) {
+		return request.ContentTypeYAML + "; charset=utf-8"
+	}
+
+	return "application/json; charset=utf-8"
+}
+
+// decodeRequest decodes the body of a request into a value. Bodies with a YAML
+// Content-Type are decoded using the YAML decoding methods of the value, so
+// that resources can be submitted in the format they are stored in
+// repositories. Other bodies are decoded as JSON.
+func decodeRequest(r *http.Request, v any) error {
+	if request.IsYAML(r.Header.Get("Content-Type")) {
+		return request.DecodeYAML(r.Body, v)
+	}
+
+	return request.DecodeJSON(r.Body, v)
+}
+
+// decodeRequestNumber decodes the body of a request into a value, as
+// decodeRequest, preserving JSON numbers as json.Number values.
+func decodeRequestNumber(r *http.Request, v any) error {
+	if request.IsYAML(r.Header.Get("Content-Type")) {
+		return request.DecodeYAML(r.Body, v)
+	}
+
+	return request.DecodeJSONNumber(r.Body, v)
+}
+
+// encodeResponse writes the encoding of a value to the response to a request.
+// The value is encoded in YAML if the Accept header of the request prefers it,
+// using the same field names as its JSON encoding, or JSON otherwise.
+func encodeResponse(w http.ResponseWriter, r *http.Request, v any) error {
+	if !request.AcceptsYAML(r.Header.Get("Accept")) {
+		return json.NewEncoder(w).Encode(v)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if b, err = request.YAMLFromJSON(b); err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+// drainInterval is the interval at which drain progress is logged during a
+// graceful shutdown.
+const drainInterval = time.Second
+
+// drainFunc values are named functions run during a graceful shutdown to flush
+// queued background work.
+type drainFunc struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+// AddDrainFunc registers a function run during a graceful shutdown, after all
+// in-flight requests have completed, to flush queued background work. The
+// context passed to the function expires at the end of the drain timeout.
+func (s *Server) AddDrainFunc(name string, f func(ctx context.Context) error) {
+	if f == nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.drains = append(s.drains, drainFunc{name: name, f: f})
+}
+
+// InFlight returns the number of requests currently being processed by the
+// server.
+func (s *Server) InFlight() int64 {
+	return s.inFlight.Load()
+}
+
+// track wraps request handlers to count in-flight requests.
+func (s *Server) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+
+		defer s.inFlight.Add(-1)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drain stops the server from accepting new connections and waits for
+// in-flight requests and queued background work to complete, logging progress
+// until the context expires. Connections still active when the context
+// expires are closed.
+func (s *Server) drain(ctx context.Context) {
+	start := time.Now()
+
+	done := make(chan struct{})
+
+	go func() {
+		tick := time.NewTicker(drainInterval)
+
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+				s.log.Log(ctx, logger.LvlInfo, "server draining",
+					"in_flight", s.InFlight(),
+					"elapsed", time.Since(start).String())
+			}
+		}
+	}()
+
+	err := s.Server.Shutdown(ctx)
+
+	close(done)
+
+	if err != nil {
+		s.log.Log(ctx, logger.LvlError, "server drain incomplete",
+			"error", err,
+			"in_flight", s.InFlight(),
+			"elapsed", time.Since(start).String())
+
+		if err := s.Server.Close(); err != nil {
+			s.log.Log(ctx, logger.LvlError, "error during server close",
+				"error", err)
+		}
+	}
+
+	s.RLock()
+
+	drains := s.drains
+
+	s.RUnlock()
+
+	for _, d := range drains {
+		if err := d.f(ctx); err != nil {
+			s.log.Log(ctx, logger.LvlError, "unable to drain background work",
+				"name", d.name,
+				"error", err)
+
+			continue
+		}
+
+		s.log.Log(ctx, logger.LvlDebug, "background work drained",
+			"name", d.name)
+	}
+
+	s.log.Log(ctx, logger.LvlInfo, "server drained",
+		"elapsed", time.Since(start).String())
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+// envelopeHeader is the request header used by clients to select whether list
+// responses are wrapped in an envelope, overriding the server configuration.
+const envelopeHeader = "X-Envelope"
+
+// Envelope values wrap list responses with paging links, and the summary of
+// summary queries.
+type Envelope struct {
+	Data    any                  `json:"data"`
+	Paging  *Paging              `json:"paging,omitempty"`
+	Summary []*sqldb.SummaryData `json:"summary,omitempty"`
+}
+
+// Paging values describe the page of search results contained in a list
+// response, and link to the adjacent pages.
+type Paging struct {
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+	Size  int64  `json:"size"`
+	From  int64  `json:"from"`
+	Total *int64 `json:"total,omitempty"`
+}
+
+// envelope determines whether the list response to a request should be wrapped
+// in an envelope, using the X-Envelope request header, if it is set, or the
+// server configuration otherwise.
+func (s *Server) envelope(r *http.Request) bool {
+	if v := r.Header.Get(envelopeHeader); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+
+	return s.cfg.ServerEnvelope()
+}
+
+// newEnvelope creates an envelope for a page of search results. The paging
+// links repeat the request, with the skip value of the previous and next
+// pages. The next link is only included if there are more results.
+func (s *Server) newEnvelope(r *http.Request,
+	q *search.Query,
+	data any,
+	more bool,
+) *Envelope {
+	size := s.querySize(q)
+
+	from := int64(0)
+
+	if q != nil {
+		from = q.Skip
+	}
+
+	link := func(skip int64) string {
+		values := r.URL.Query()
+
+		values.Set("skip", strconv.FormatInt(skip, 10))
+		values.Set("size", strconv.FormatInt(size, 10))
+
+		return r.URL.Path + "?" + values.Encode()
+	}
+
+	p := &Paging{Size: size, From: from}
+
+	if more {
+		p.Next = link(from + size)
+	}
+
+	if from > 0 {
+		p.Prev = link(max(from-size, 0))
+	}
+
+	return &Envelope{Data: data, Paging: p}
+}
+
+// encodeList responds to the current request with a list response. If the
+// request selects an envelope, the envelope is encoded, with the fields of its
+// data selected using the options. Otherwise, the summary, for summary
+// queries, or the data, is encoded as a bare array.
+func (s *Server) encodeList(env *Envelope,
+	options sqldb.FieldOptions,
+	idField string,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	if !s.envelope(r) {
+		if env.Summary != nil {
+			if err := encodeResponse(w, r, env.Summary); err != nil {
+				s.error(err, w, r)
+			}
+
+			return
+		}
+
+		s.encodeFields(env.Data, options, idField, w, r)
+
+		return
+	}
+
+	data, err := selectFields(env.Data, options, idField)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	env.Data = data
+
+	s.encodeFields(env, nil, idField, w, r)
+}
@@ -0,0 +1,618 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/go-chi/chi/v5"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// GraphQLRequest values contain a GraphQL query request.
+type GraphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// graphQLError values wrap errors returned by GraphQL resolvers, so that the
+// error code and status are included in the response error extensions.
+type graphQLError struct {
+	err *errors.Error
+}
+
+// Error returns the message of the error.
+func (e *graphQLError) Error() string {
+	return e.err.Msg
+}
+
+// Extensions returns the additional error information included in GraphQL
+// responses.
+func (e *graphQLError) Extensions() map[string]any {
+	return map[string]any{
+		"code":   e.err.Code.Name,
+		"status": e.err.Code.Status,
+	}
+}
+
+// graphQLJSON is a scalar type used for arbitrary JSON data fields.
+var graphQLJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Arbitrary JSON data.",
+	Serialize:   func(value any) any { return value },
+	ParseValue:  func(value any) any { return value },
+	ParseLiteral: func(valueAST ast.Value) any {
+		return valueAST.GetValue()
+	},
+})
+
+// graphQLTimestamp is a scalar type used for time fields, which are
+// represented as the number of seconds since the Unix epoch.
+var graphQLTimestamp = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Timestamp",
+	Description: "A time, in seconds since the Unix epoch.",
+	Serialize: func(value any) any {
+		switch v := value.(type) {
+		case float64:
+			return int64(math.Round(v))
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+
+			if f, err := v.Float64(); err == nil {
+				return int64(math.Round(f))
+			}
+		case int64:
+			return v
+		case int:
+			return int64(v)
+		}
+
+		return nil
+	},
+	ParseValue: func(value any) any { return value },
+	ParseLiteral: func(valueAST ast.Value) any {
+		return valueAST.GetValue()
+	},
+})
+
+// newGraphQLSchema creates the GraphQL schema used to query the service.
+func (s *Server) newGraphQLSchema() (graphql.Schema, error) {
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"user_id":    &graphql.Field{Type: graphql.String},
+			"email":      &graphql.Field{Type: graphql.String},
+			"last_name":  &graphql.Field{Type: graphql.String},
+			"first_name": &graphql.Field{Type: graphql.String},
+			"status":     &graphql.Field{Type: graphql.String},
+			"scopes":     &graphql.Field{Type: graphql.String},
+			"data":       &graphql.Field{Type: graphQLJSON},
+			"created_at": &graphql.Field{Type: graphQLTimestamp},
+			"created_by": &graphql.Field{Type: graphql.String},
+			"updated_at": &graphql.Field{Type: graphQLTimestamp},
+			"updated_by": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	resourceType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Resource",
+		Fields: graphql.Fields{
+			"resource_id":      &graphql.Field{Type: graphql.String},
+			"name":             &graphql.Field{Type: graphql.String},
+			"version":          &graphql.Field{Type: graphql.String},
+			"description":      &graphql.Field{Type: graphql.String},
+			"status":           &graphql.Field{Type: graphql.String},
+			"status_data":      &graphql.Field{Type: graphQLJSON},
+			"key_field":        &graphql.Field{Type: graphql.String},
+			"key_regex":        &graphql.Field{Type: graphql.String},
+			"clear_condition":  &graphql.Field{Type: graphql.String},
+			"clear_after":      &graphql.Field{Type: graphql.Float},
+			"clear_delay":      &graphql.Field{Type: graphql.Float},
+			"duplicate_policy": &graphql.Field{Type: graphql.String},
+			"key_strategy":     &graphql.Field{Type: graphql.String},
+			"data":             &graphql.Field{Type: graphQLJSON},
+			"source":           &graphql.Field{Type: graphql.String},
+			"commit_hash":      &graphql.Field{Type: graphql.String},
+			"created_at":       &graphql.Field{Type: graphQLTimestamp},
+			"created_by":       &graphql.Field{Type: graphql.String},
+			"updated_at":       &graphql.Field{Type: graphQLTimestamp},
+			"updated_by":       &graphql.Field{Type: graphql.String},
+			"created_by_user":  &graphql.Field{Type: userType},
+			"updated_by_user":  &graphql.Field{Type: userType},
+			"tags": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+			},
+			"depends_on": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+			},
+			"revisions":     &graphql.Field{Type: graphql.Float},
+			"data_count":    &graphql.Field{Type: graphql.Float},
+			"age_seconds":   &graphql.Field{Type: graphql.Float},
+			"cleared_ratio": &graphql.Field{Type: graphql.Float},
+		},
+	})
+
+	accountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Account",
+		Fields: graphql.Fields{
+			"account_id":       &graphql.Field{Type: graphql.String},
+			"name":             &graphql.Field{Type: graphql.String},
+			"status":           &graphql.Field{Type: graphql.String},
+			"status_data":      &graphql.Field{Type: graphQLJSON},
+			"repo_status":      &graphql.Field{Type: graphql.String},
+			"repo_status_data": &graphql.Field{Type: graphQLJSON},
+			"data":             &graphql.Field{Type: graphQLJSON},
+			"created_at":       &graphql.Field{Type: graphQLTimestamp},
+			"updated_at":       &graphql.Field{Type: graphQLTimestamp},
+		},
+	})
+
+	tokenType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Token",
+		Fields: graphql.Fields{
+			"account_id":   &graphql.Field{Type: graphql.String},
+			"account_name": &graphql.Field{Type: graphql.String},
+			"user_id":      &graphql.Field{Type: graphql.String},
+			"scopes":       &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"resources": &graphql.Field{
+				Type:        graphql.NewList(resourceType),
+				Description: "Search for resources.",
+				Args: graphql.FieldConfigArgument{
+					"search": &graphql.ArgumentConfig{Type: graphql.String},
+					"size":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"skip":   &graphql.ArgumentConfig{Type: graphql.Int},
+					"sort":   &graphql.ArgumentConfig{Type: graphql.String},
+					"case_insensitive": &graphql.ArgumentConfig{
+						Type: graphql.Boolean,
+					},
+				},
+				Resolve: s.resolveResources,
+			},
+			"resource": &graphql.Field{
+				Type:        resourceType,
+				Description: "Get a resource by ID.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: s.resolveResource,
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Description: "Get a user by ID, or the current user if no " +
+					"ID is specified.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: s.resolveUser,
+			},
+			"account": &graphql.Field{
+				Type:        accountType,
+				Description: "Get the current account.",
+				Resolve:     s.resolveAccount,
+			},
+			"token": &graphql.Field{
+				Type:        tokenType,
+				Description: "Get the claims of the current token.",
+				Resolve:     s.resolveToken,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// GraphQLHandler performs routing for GraphQL requests.
+func (s *Server) GraphQLHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.GetGraphQL)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/", s.PostGraphQL)
+
+	return r
+}
+
+// graphQLOperations documents the GraphQL routes.
+var graphQLOperations = map[string]*Operation{
+	"GET /graphql": {
+		ID:      "get_graphql",
+		Tag:     "graphql",
+		Summary: "GraphQL query",
+		Description: "Executes a read only GraphQL query against resources, " +
+			"users, the current account and the current token. Each query " +
+			"field requires the same scope as the equivalent REST operation.",
+		Scopes: []string{"resources:read"},
+		Params: []*Parameter{
+			{
+				Name:        "query",
+				In:          "query",
+				Type:        "string",
+				Required:    true,
+				Description: "The GraphQL query document.",
+			},
+			{
+				Name:        "operationName",
+				In:          "query",
+				Type:        "string",
+				Description: "The name of the operation to execute.",
+			},
+			{
+				Name: "variables",
+				In:   "query",
+				Type: "string",
+				Description: "A JSON encoded object containing the query " +
+					"variables.",
+			},
+		},
+		Responses: map[int]string{
+			200: "graphql",
+			400: "graphql",
+			500: "error",
+		},
+	},
+	"POST /graphql": {
+		ID:      "create_graphql",
+		Tag:     "graphql",
+		Summary: "GraphQL query",
+		Description: "Executes a read only GraphQL query against resources, " +
+			"users, the current account and the current token. Each query " +
+			"field requires the same scope as the equivalent REST operation.",
+		Scopes:   []string{"resources:read"},
+		Body:     "graphql_request",
+		BodyType: "application/json",
+		Responses: map[int]string{
+			200: "graphql",
+			400: "graphql",
+			500: "error",
+		},
+	},
+}
+
+// GetGraphQL is the get handler function for GraphQL queries.
+func (s *Server) GetGraphQL(w http.ResponseWriter, r *http.Request) {
+	req := &GraphQLRequest{
+		Query:         r.URL.Query().Get("query"),
+		OperationName: r.URL.Query().Get("operationName"),
+	}
+
+	if v := r.URL.Query().Get("variables"); v != "" {
+		if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to decode variables"), w, r)
+
+			return
+		}
+	}
+
+	s.graphQL(req, w, r)
+}
+
+// PostGraphQL is the post handler function for GraphQL queries.
+func (s *Server) PostGraphQL(w http.ResponseWriter, r *http.Request) {
+	req := &GraphQLRequest{}
+
+	if err := request.DecodeJSON(r.Body, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	s.graphQL(req, w, r)
+}
+
+// graphQL executes a GraphQL query and writes the result.
+func (s *Server) graphQL(req *GraphQLRequest,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+
+	if req.Query == "" {
+		s.error(errors.New(errors.ErrInvalidRequest,
+			"missing query"), w, r)
+
+		return
+	}
+
+	schema, err := s.graphQLSchema()
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res := graphql.Do(graphql.Params{
+		Schema:         *schema,
+		RequestString:  req.Query,
+		OperationName:  req.OperationName,
+		VariableValues: req.Variables,
+		RootObject:     map[string]any{"request": r},
+		Context:        ctx,
+	})
+
+	// GraphQL results are always encoded in JSON.
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if res.Data == nil && res.HasErrors() {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// graphQLSchema returns the GraphQL schema, creating it if necessary.
+func (s *Server) graphQLSchema() (*graphql.Schema, error) {
+	s.gqlOnce.Do(func() {
+		schema, err := s.newGraphQLSchema()
+		if err != nil {
+			s.gqlErr = errors.Wrap(err, errors.ErrServer,
+				"unable to create GraphQL schema")
+
+			return
+		}
+
+		s.gqlSchema = &schema
+	})
+
+	return s.gqlSchema, s.gqlErr
+}
+
+// graphQLResolveError converts an error returned by a service into a GraphQL
+// resolver error, logging any server errors.
+func (s *Server) graphQLResolveError(ctx context.Context, err error) error {
+	e, ok := err.(*errors.Error)
+	if !ok {
+		e = errors.Wrap(err, errors.ErrServer, err.Error())
+	}
+
+	if e.Code.Status >= http.StatusInternalServerError {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to resolve GraphQL query",
+			"error", e)
+	}
+
+	return &graphQLError{err: e}
+}
+
+// graphQLRequest returns the HTTP request of a GraphQL query.
+func graphQLRequest(p graphql.ResolveParams) *http.Request {
+	if rv, ok := p.Info.RootValue.(map[string]any); ok {
+		if r, ok := rv["request"].(*http.Request); ok {
+			return r
+		}
+	}
+
+	return (&http.Request{}).WithContext(p.Context)
+}
+
+// graphQLValue converts a service result into a value which can be resolved
+// using the GraphQL field names, which match the JSON field names.
+func graphQLValue(v any) (any, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to encode GraphQL result")
+	}
+
+	var res any
+
+	if err := json.Unmarshal(buf, &res); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to decode GraphQL result")
+	}
+
+	return res, nil
+}
+
+// graphQLFieldOptions returns the field options needed to expand the related
+// objects selected in a GraphQL query.
+func graphQLFieldOptions(p graphql.ResolveParams) sqldb.FieldOptions {
+	opts := sqldb.FieldOptions{}
+
+	for _, name := range graphQLSelections(p) {
+		o := sqldb.FieldOption(name)
+
+		switch name {
+		case "data_count", "age_seconds", "cleared_ratio":
+			o = sqldb.OptComputed
+		}
+
+		switch o {
+		case sqldb.OptCreatedByUser, sqldb.OptUpdatedByUser, sqldb.OptTags,
+			sqldb.OptRevisions, sqldb.OptComputed:
+			if !opts.Contains(o) {
+				opts = append(opts, o)
+			}
+		}
+	}
+
+	return opts
+}
+
+// graphQLSelections returns the names of the fields selected for the field
+// being resolved, including those selected using fragments.
+func graphQLSelections(p graphql.ResolveParams) []string {
+	res := []string{}
+
+	var walk func(ss *ast.SelectionSet)
+
+	walk = func(ss *ast.SelectionSet) {
+		if ss == nil {
+			return
+		}
+
+		for _, sel := range ss.Selections {
+			switch v := sel.(type) {
+			case *ast.Field:
+				res = append(res, v.Name.Value)
+			case *ast.InlineFragment:
+				walk(v.SelectionSet)
+			case *ast.FragmentSpread:
+				f, ok := p.Info.Fragments[v.Name.Value].(*ast.FragmentDefinition)
+				if ok {
+					walk(f.SelectionSet)
+				}
+			}
+		}
+	}
+
+	for _, f := range p.Info.FieldASTs {
+		walk(f.SelectionSet)
+	}
+
+	return res
+}
+
+// resolveResources resolves GraphQL resource search queries.
+func (s *Server) resolveResources(p graphql.ResolveParams) (any, error) {
+	r := graphQLRequest(p)
+
+	ctx := p.Context
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	q := &search.Query{}
+
+	if v, ok := p.Args["search"].(string); ok {
+		q.Search = v
+	}
+
+	if v, ok := p.Args["size"].(int); ok {
+		q.Size = int64(v)
+	}
+
+	if v, ok := p.Args["skip"].(int); ok {
+		q.Skip = int64(v)
+	}
+
+	if v, ok := p.Args["sort"].(string); ok {
+		q.Sort = strings.TrimSpace(v)
+	}
+
+	if v, ok := p.Args["case_insensitive"].(bool); ok {
+		q.CaseInsensitive = v
+	}
+
+	if err := s.checkQuery(q); err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	res, _, err := s.getResourceService(r).GetResources(ctx, q,
+		graphQLFieldOptions(p))
+	if err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	res, _ = page(res, s.querySize(q))
+
+	return graphQLValue(res)
+}
+
+// resolveResource resolves GraphQL resource queries.
+func (s *Server) resolveResource(p graphql.ResolveParams) (any, error) {
+	r := graphQLRequest(p)
+
+	ctx := p.Context
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	id, _ := p.Args["id"].(string)
+
+	res, err := s.getResourceService(r).GetResource(ctx, id,
+		graphQLFieldOptions(p))
+	if err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	return graphQLValue(res)
+}
+
+// resolveUser resolves GraphQL user queries.
+func (s *Server) resolveUser(p graphql.ResolveParams) (any, error) {
+	r := graphQLRequest(p)
+
+	ctx := p.Context
+
+	if err := s.checkScope(ctx, request.ScopeUserRead); err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	id, _ := p.Args["id"].(string)
+
+	res, err := s.getAuthService(r).GetUser(ctx, id, nil)
+	if err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	return graphQLValue(res)
+}
+
+// resolveAccount resolves GraphQL account queries.
+func (s *Server) resolveAccount(p graphql.ResolveParams) (any, error) {
+	r := graphQLRequest(p)
+
+	ctx := p.Context
+
+	if err := s.checkScope(ctx, request.ScopeAccountRead); err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	res, err := s.getAuthService(r).GetAccount(ctx, "")
+	if err != nil {
+		return nil, s.graphQLResolveError(ctx, err)
+	}
+
+	return graphQLValue(res)
+}
+
+// resolveToken resolves GraphQL token queries using the claims of the token
+// used to authenticate the request.
+func (s *Server) resolveToken(p graphql.ResolveParams) (any, error) {
+	ctx := p.Context
+
+	res := map[string]any{}
+
+	if v, err := request.ContextAccountID(ctx); err == nil {
+		res["account_id"] = v
+	}
+
+	if v, err := request.ContextAccountName(ctx); err == nil {
+		res["account_name"] = v
+	}
+
+	if v, err := request.ContextUserID(ctx); err == nil {
+		res["user_id"] = v
+	}
+
+	if v, err := request.ContextScopes(ctx); err == nil {
+		res["scopes"] = v
+	}
+
+	return res, nil
+}
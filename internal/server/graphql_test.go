@@ -0,0 +1,127 @@
+package server_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+func TestGraphQL(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		method string
+		url    string
+		body   string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "resources",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/graphql",
+		body: `{"query":"{ resources(search: \"and(name:test)\", size: 10) ` +
+			`{ resource_id name created_by_user { email } tags } }"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"resource_id":"` + TestResource.ResourceID.Value + `"`,
+	}, {
+		name:   "resource",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/graphql",
+		body: `{"query":"query Get($id: String!) { resource(id: $id) ` +
+			`{ ...fields } } fragment fields on Resource { name }",` +
+			`"variables":{"id":"` + TestResource.ResourceID.Value + `"}}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"name":"` + TestResource.Name.Value + `"`,
+	}, {
+		name:   "user account token",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url: basePath + "/graphql?query=" + url.QueryEscape(
+			"{ user { user_id } account { name } token { scopes } }"),
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"user_id":"` + TestUser.UserID.Value + `"`,
+	}, {
+		name:   "invalid query",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/graphql",
+		body:   `{"query":"{ invalid }"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `"errors"`,
+	}, {
+		name:   "missing query",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/graphql",
+		body:   `{}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `"InvalidRequest"`,
+	}, {
+		name:   "unauthorized",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/graphql",
+		body:   `{"query":"{ account { name } }"}`,
+		code:   http.StatusForbidden,
+		resp:   `"Forbidden"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(tt.method, tt.url,
+				bytes.NewBufferString(tt.body))
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,398 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/go-chi/chi/v5"
+)
+
+// GroupHandler performs routing for group requests.
+func (s *Server) GroupHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.Auth).Put("/{id}/users/{user_id}",
+		s.PutGroupUser)
+	r.With(s.Stat, s.Trace, s.Auth).Delete("/{id}/users/{user_id}",
+		s.DeleteGroupUser)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.SearchGroup)
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}", s.GetGroup)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/", s.PostGroup)
+	r.With(s.Stat, s.Trace, s.Auth).Patch("/{id}", s.PatchGroup)
+	r.With(s.Stat, s.Trace, s.Auth).Delete("/{id}", s.DeleteGroup)
+
+	return r
+}
+
+// groupOperations documents the group routes.
+var groupOperations = map[string]*Operation{
+	"GET /groups": {
+		ID:      "search_groups",
+		Tag:     "groups",
+		Summary: "Search groups",
+		Description: "Retrieves the groups of the account based on a search " +
+			"query.",
+		Scopes: []string{"account:read"},
+		Params: []*Parameter{
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "case_insensitive"},
+			{Name: "envelope"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "groups",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /groups": {
+		ID:      "create_group",
+		Tag:     "groups",
+		Summary: "Create group",
+		Description: "Creates a group. Only scopes held by the current user " +
+			"can be granted by the group.",
+		Scopes: []string{"user:admin"},
+		Body:   "group",
+		Responses: map[int]string{
+			201: "group",
+			400: "user_error",
+			403: "user_error",
+			409: "user_error",
+			500: "error",
+		},
+	},
+	"GET /groups/{id}": {
+		ID:          "get_group",
+		Tag:         "groups",
+		Summary:     "Get group",
+		Description: "Retrieves details for a specific group.",
+		Scopes:      []string{"account:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "group",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"PATCH /groups/{id}": {
+		ID:      "update_group",
+		Tag:     "groups",
+		Summary: "Update group",
+		Description: "Updates details for a specific group. Only groups " +
+			"granting scopes held by the current user can be updated.",
+		Scopes: []string{"user:admin"},
+		Params: []*Parameter{{Name: "id"}},
+		Body:   "group",
+		Responses: map[int]string{
+			200: "group",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /groups/{id}": {
+		ID:          "delete_group",
+		Tag:         "groups",
+		Summary:     "Delete group",
+		Description: "Deletes a specific group.",
+		Scopes:      []string{"user:admin"},
+		Params:      []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"PUT /groups/{id}/users/{user_id}": {
+		ID:      "add_group_user",
+		Tag:     "groups",
+		Summary: "Add group user",
+		Description: "Adds a user to a specific group. Only users holding " +
+			"the scopes granted by the group can add users to it.",
+		Scopes: []string{"user:admin"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{
+				Name:        "user_id",
+				In:          "path",
+				Type:        "string",
+				Required:    true,
+				Description: "The ID of the user.",
+			},
+		},
+		Responses: map[int]string{
+			200: "group",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /groups/{id}/users/{user_id}": {
+		ID:          "remove_group_user",
+		Tag:         "groups",
+		Summary:     "Remove group user",
+		Description: "Removes a user from a specific group.",
+		Scopes:      []string{"user:admin"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{
+				Name:        "user_id",
+				In:          "path",
+				Type:        "string",
+				Required:    true,
+				Description: "The ID of the user.",
+			},
+		},
+		Responses: map[int]string{
+			200: "group",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// SearchGroup is the search handler function for groups.
+func (s *Server) SearchGroup(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeAccountRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetGroups(ctx, q, opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), opts, "group_id", w, r)
+}
+
+// GetGroup is the get handler function for groups.
+func (s *Server) GetGroup(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeAccountRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetGroup(ctx, chi.URLParam(r, "id"), opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	s.encodeFields(res, opts, "group_id", w, r)
+}
+
+// PostGroup is the post handler function used to create groups.
+func (s *Server) PostGroup(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeUserAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &auth.Group{}
+
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.CreateGroup(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	scheme := "https"
+	if strings.Contains(r.Host, "localhost") {
+		scheme = "http"
+	}
+
+	loc := &url.URL{
+		Scheme: scheme,
+		Host:   r.Host,
+		Path:   strings.TrimSuffix(r.URL.Path, "/") + "/" + res.GroupID.Value,
+	}
+
+	w.Header().Set("Location", loc.String())
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PatchGroup is the patch handler function for groups.
+func (s *Server) PatchGroup(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeUserAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := &auth.Group{}
+
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req.GroupID = request.FieldString{
+		Set: true, Valid: true,
+		Value: chi.URLParam(r, "id"),
+	}
+
+	res, err := svc.UpdateGroup(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// DeleteGroup is the delete handler function for groups.
+func (s *Server) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeUserAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := svc.DeleteGroup(ctx, chi.URLParam(r, "id")); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PutGroupUser is the put handler function used to add users to groups.
+func (s *Server) PutGroupUser(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeUserAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.AddGroupUser(ctx, chi.URLParam(r, "id"),
+		chi.URLParam(r, "user_id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// DeleteGroupUser is the delete handler function used to remove users from
+// groups.
+func (s *Server) DeleteGroupUser(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeUserAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.RemoveGroupUser(ctx, chi.URLParam(r, "id"),
+		chi.URLParam(r, "user_id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
@@ -0,0 +1,223 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestGroup = auth.Group{
+	GroupID: request.FieldString{
+		Set: true, Valid: true,
+		Value: "test-group",
+	},
+	Name: request.FieldString{
+		Set: true, Valid: true,
+		Value: "testName",
+	},
+	Status: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.StatusActive,
+	},
+	Scopes: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.ScopeResourcesWrite,
+	},
+	Users: request.FieldStringArray{
+		Set: true, Valid: true,
+		Value: []string{TestUUID},
+	},
+}
+
+func (m *mockAuthService) GetGroups(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*auth.Group, error) {
+	return []*auth.Group{&TestGroup}, nil
+}
+
+func (m *mockAuthService) GetGroup(ctx context.Context,
+	id string,
+	options sqldb.FieldOptions,
+) (*auth.Group, error) {
+	return &TestGroup, nil
+}
+
+func (m *mockAuthService) CreateGroup(ctx context.Context,
+	v *auth.Group,
+) (*auth.Group, error) {
+	return &TestGroup, nil
+}
+
+func (m *mockAuthService) UpdateGroup(ctx context.Context,
+	v *auth.Group,
+) (*auth.Group, error) {
+	return &TestGroup, nil
+}
+
+func (m *mockAuthService) DeleteGroup(ctx context.Context,
+	id string,
+) error {
+	return nil
+}
+
+func (m *mockAuthService) AddGroupUser(ctx context.Context,
+	id, userID string,
+) (*auth.Group, error) {
+	return &TestGroup, nil
+}
+
+func (m *mockAuthService) RemoveGroupUser(ctx context.Context,
+	id, userID string,
+) (*auth.Group, error) {
+	res := TestGroup
+
+	res.Users = request.FieldStringArray{
+		Set: true, Valid: true,
+		Value: []string{},
+	}
+
+	return &res, nil
+}
+
+func TestGroups(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		method string
+		url    string
+		body   string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "search",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/groups",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"group_id":"` + TestGroup.GroupID.Value + `"`,
+	}, {
+		name:   "get",
+		w:      httptest.NewRecorder(),
+		method: http.MethodGet,
+		url:    basePath + "/groups/" + TestGroup.GroupID.Value,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"scopes":"` + TestGroup.Scopes.Value + `"`,
+	}, {
+		name:   "create forbidden",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/groups",
+		body:   `{"group_id":"test-group","name":"test"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusForbidden,
+	}, {
+		name:   "create",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/groups",
+		body:   `{"group_id":"test-group","name":"test"}`,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusCreated,
+		resp:   `"group_id":"` + TestGroup.GroupID.Value + `"`,
+	}, {
+		name:   "invalid create",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		url:    basePath + "/groups",
+		body:   `{"group_id":`,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusBadRequest,
+		resp:   `unable to decode request`,
+	}, {
+		name:   "update",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPatch,
+		url:    basePath + "/groups/" + TestGroup.GroupID.Value,
+		body:   `{"name":"test"}`,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"group_id":"` + TestGroup.GroupID.Value + `"`,
+	}, {
+		name:   "add user",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPut,
+		url: basePath + "/groups/" + TestGroup.GroupID.Value +
+			"/users/" + TestUUID,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"users":["` + TestUUID + `"]`,
+	}, {
+		name:   "remove user",
+		w:      httptest.NewRecorder(),
+		method: http.MethodDelete,
+		url: basePath + "/groups/" + TestGroup.GroupID.Value +
+			"/users/" + TestUUID,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusOK,
+		resp:   `"users":[]`,
+	}, {
+		name:   "delete",
+		w:      httptest.NewRecorder(),
+		method: http.MethodDelete,
+		url:    basePath + "/groups/" + TestGroup.GroupID.Value,
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusNoContent,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.NewBufferString(tt.body)
+
+			r, err := http.NewRequest(tt.method, tt.url, buf)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// idempotencyHeader is the request header used by clients to identify retries
+// of a request, so that the response to the original request is replayed.
+const idempotencyHeader = "Idempotency-Key"
+
+// replayedHeader is the response header set when a retained response is
+// replayed.
+const replayedHeader = "Idempotent-Replayed"
+
+// idempotentHeaders are the response headers retained and replayed with the
+// responses of idempotent requests.
+var idempotentHeaders = []string{"Content-Type", "Location"}
+
+// idempotencyParam documents the idempotency key header of the operations
+// which replay the responses of retried requests.
+var idempotencyParam = &Parameter{
+	Name: idempotencyHeader,
+	In:   "header",
+	Type: "string",
+	Description: "A unique key, of up to 255 characters, identifying the " +
+		"request. If the request is retried with the same key, the " +
+		"original response is replayed, with an Idempotent-Replayed " +
+		"header, instead of the request being processed again.",
+}
+
+// idempotencyWriter values capture a response as it is written, so that it
+// can be retained and replayed.
+type idempotencyWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader captures the response status code.
+func (w *idempotencyWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write captures the response body.
+func (w *idempotencyWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.body.Write(b)
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher, if the wrapped writer supports it.
+func (w *idempotencyWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Idempotent wraps an authenticated http handler so that requests made with an
+// Idempotency-Key header are only processed once. The response is retained for
+// the configured idempotency window, and replayed when the request is retried
+// with the same key. Requests are identified by the user, method, path and
+// body of the request.
+func (s *Server) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.idempotencyRequested(r) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
+				"unable to read request body"), w, r)
+
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.idempotent(w, r, requestHash(r, body), next.ServeHTTP)
+	})
+}
+
+// idempotencyRequested determines whether a request was made with an
+// idempotency key, and responses are configured to be retained.
+func (s *Server) idempotencyRequested(r *http.Request) bool {
+	return r.Header.Get(idempotencyHeader) != "" &&
+		s.cfg.ServerIdempotency() >= 0
+}
+
+// requestHash returns a hash identifying a request made with an idempotency
+// key, by the user, method and path of the request, and its body.
+func requestHash(r *http.Request, body []byte) string {
+	userID, _ := request.ContextUserID(r.Context())
+
+	h := sha256.New()
+
+	for _, v := range []string{userID, r.Method, r.URL.Path} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// idempotent processes a request made with an idempotency key, identified by a
+// hash of the request, for the account of the request context. If the key was
+// already used for the same request, the retained response is replayed.
+// Otherwise, the request is processed, and its response is retained, unless it
+// failed in a way which may succeed if the request is retried, in which case
+// the key is released.
+func (s *Server) idempotent(w http.ResponseWriter,
+	r *http.Request,
+	hash string,
+	next http.HandlerFunc,
+) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	key := r.Header.Get(idempotencyHeader)
+
+	if len(key) > auth.MaxIdempotencyKey {
+		s.error(errors.New(errors.ErrInvalidHeader,
+			"invalid idempotency key",
+			"idempotency_key", key), w, r)
+
+		return
+	}
+
+	res, err := svc.ClaimIdempotencyKey(ctx, key, s.requestOperation(r),
+		hash)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if res != nil {
+		for k, v := range res.Headers {
+			w.Header().Set(k, v)
+		}
+
+		w.Header().Set(replayedHeader, "true")
+
+		w.WriteHeader(res.Status)
+
+		if _, err := w.Write(res.Body); err != nil {
+			s.log.Log(ctx, slog.LevelWarn,
+				"unable to write idempotent response",
+				"error", err,
+				"idempotency_key", key)
+		}
+
+		return
+	}
+
+	iw := &idempotencyWriter{ResponseWriter: w}
+
+	next(iw, r)
+
+	// The response is retained even if the client disconnected, since that
+	// is when the request is most likely to be retried.
+	ctx = context.WithoutCancel(ctx)
+
+	if iw.status == 0 {
+		iw.status = http.StatusOK
+	}
+
+	if iw.status >= http.StatusInternalServerError ||
+		iw.status == http.StatusTooManyRequests {
+		if err := svc.ReleaseIdempotencyKey(ctx, key, hash); err != nil {
+			s.log.Log(ctx, slog.LevelWarn,
+				"unable to release idempotency key",
+				"error", err,
+				"idempotency_key", key)
+		}
+
+		return
+	}
+
+	res = &auth.IdempotentResponse{
+		Status:  iw.status,
+		Headers: map[string]string{},
+		Body:    iw.body.Bytes(),
+	}
+
+	for _, k := range idempotentHeaders {
+		if v := w.Header().Get(k); v != "" {
+			res.Headers[k] = v
+		}
+	}
+
+	if err := svc.SaveIdempotentResponse(ctx, key, hash, res); err != nil {
+		s.log.Log(ctx, slog.LevelWarn,
+			"unable to retain idempotent response",
+			"error", err,
+			"idempotency_key", key)
+	}
+}
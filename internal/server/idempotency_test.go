@@ -0,0 +1,161 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestIdempotentResponse = auth.IdempotentResponse{
+	Status:  http.StatusCreated,
+	Headers: map[string]string{"Content-Type": "application/json"},
+	Body:    []byte(`{"resource_id":"replayed"}`),
+}
+
+func (m *mockAuthService) TokenAccountID(ctx context.Context,
+	tenant string,
+) (string, error) {
+	return "test", nil
+}
+
+func (m *mockAuthService) ClaimIdempotencyKey(ctx context.Context,
+	key, operation, hash string,
+) (*auth.IdempotentResponse, error) {
+	switch key {
+	case "replay":
+		return &TestIdempotentResponse, nil
+	case "in-progress":
+		return nil, errors.New(errors.ErrConflict,
+			"request with idempotency key in progress")
+	}
+
+	return nil, nil
+}
+
+func (m *mockAuthService) SaveIdempotentResponse(ctx context.Context,
+	key, hash string,
+	res *auth.IdempotentResponse,
+) error {
+	return nil
+}
+
+func (m *mockAuthService) ReleaseIdempotencyKey(ctx context.Context,
+	key, hash string,
+) error {
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	login := url.Values{
+		"username": {TestUser.UserID.Value},
+		"password": {"test"},
+	}.Encode()
+
+	tests := []struct {
+		name     string
+		w        *httptest.ResponseRecorder
+		url      string
+		body     string
+		form     bool
+		key      string
+		code     int
+		resp     string
+		replayed bool
+	}{{
+		name:     "replay resource",
+		w:        httptest.NewRecorder(),
+		url:      basePath + "/resources",
+		body:     `{"name":"test"}`,
+		key:      "replay",
+		code:     http.StatusCreated,
+		resp:     `"resource_id":"replayed"`,
+		replayed: true,
+	}, {
+		name:     "replay token",
+		w:        httptest.NewRecorder(),
+		url:      basePath + "/login/token",
+		body:     login,
+		form:     true,
+		key:      "replay",
+		code:     http.StatusCreated,
+		resp:     `"resource_id":"replayed"`,
+		replayed: true,
+	}, {
+		name: "in progress",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/resources/data",
+		body: `[]`,
+		key:  "in-progress",
+		code: http.StatusConflict,
+		resp: `"code":"Conflict"`,
+	}, {
+		name: "invalid key",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/resources",
+		body: `{"name":"test"}`,
+		key:  strings.Repeat("x", auth.MaxIdempotencyKey+1),
+		code: http.StatusBadRequest,
+		resp: `"invalid idempotency key"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodPost, tt.url,
+				strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			r.Header.Set("Authorization", "test")
+			r.Header.Set("Idempotency-Key", tt.key)
+
+			if tt.form {
+				r.Header.Set("Content-Type",
+					"application/x-www-form-urlencoded")
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+
+			replayed := tt.w.Header().Get("Idempotent-Replayed") == "true"
+			if replayed != tt.replayed {
+				t.Errorf("Expected replayed: %v, got: %v", tt.replayed,
+					replayed)
+			}
+		})
+	}
+}
@@ -0,0 +1,374 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/static"
+	"github.com/go-chi/chi/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Operation values document an API route, for the OpenAPI document generated
+// when the server is created. Parameters, request bodies and responses refer
+// to the components of the embedded OpenAPI document by name. Request bodies
+// without a BodyType may be sent in JSON or YAML.
+type Operation struct {
+	ID           string
+	Tag          string
+	Summary      string
+	Description  string
+	Public       bool
+	Scopes       []string
+	Params       []*Parameter
+	Body         string
+	BodyType     string
+	BodyOptional bool
+
+	// Responses contains the name of the response component for each status
+	// code, or, for responses without a body, a description, which contains
+	// spaces to distinguish it from a name.
+	Responses map[int]string
+}
+
+// Parameter values document the parameters of an API route. Parameters with
+// only a name refer to the parameter component with that name.
+type Parameter struct {
+	Name        string
+	In          string
+	Type        string
+	Enum        []string
+	Required    bool
+	Description string
+}
+
+// operations returns the documentation of the API routes, keyed by the method
+// and route pattern, relative to the path prefix.
+func operations() map[string]*Operation {
+	ops := map[string]*Operation{}
+
+	for _, m := range []map[string]*Operation{
+		accountOperations,
+		usageOperations,
+		accountsOperations,
+		userOperations,
+		groupOperations,
+		tokenOperations,
+		loginOperations,
+		sessionOperations,
+		passwordOperations,
+		changeOperations,
+		resourceOperations,
+		aclOperations,
+		agentOperations,
+		alertOperations,
+		graphQLOperations,
+		schemaOperations,
+	} {
+		maps.Copy(ops, m)
+	}
+
+	return ops
+}
+
+// undocumentedRoutes contains the patterns of routes deliberately left out of
+// the OpenAPI document. These are the debugging, health check and static
+// routes, and aliases kept for compatibility.
+var undocumentedRoutes = []string{
+	"/debug/*",
+	"/health",
+	"/health/*",
+	"/healthz",
+	"/healthz/*",
+	"/openapi.json",
+	"/openapi.yaml",
+	"/docs",
+	"/status",
+	"/ui",
+	"/ui/*",
+	"/resources/tags_multi_assignment",
+}
+
+// undocumented determines whether a route is deliberately left out of the
+// OpenAPI document.
+func undocumented(route string) bool {
+	for _, p := range undocumentedRoutes {
+		if m, _ := path.Match(p, route); m {
+			return true
+		}
+	}
+
+	return false
+}
+
+// routes returns the method and pattern of each route of the server, relative
+// to the path prefix, in the same format as the keys of the operations.
+func (s *Server) routes() ([]string, error) {
+	prefix := strings.TrimSuffix(s.cfg.ServerPathPrefix(), "/")
+
+	res := []string{}
+
+	if err := chi.Walk(s.r, func(method, route string,
+		_ http.Handler,
+		_ ...func(http.Handler) http.Handler,
+	) error {
+		route = strings.TrimPrefix(route, prefix)
+
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+
+		res = append(res, method+" "+route)
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to walk server routes")
+	}
+
+	slices.Sort(res)
+
+	return slices.Compact(res), nil
+}
+
+// initOpenAPI generates the OpenAPI document of the server, from the
+// components of the embedded OpenAPI document and the documentation of the
+// routes. Routes which are not documented are logged as warnings.
+func (s *Server) initOpenAPI() error {
+	b, err := static.FS.ReadFile("openapi.json")
+	if err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to read embedded OpenAPI document")
+	}
+
+	doc := map[string]any{}
+
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to decode embedded OpenAPI document")
+	}
+
+	components, _ := doc["components"].(map[string]any)
+
+	has := func(kind, name string) bool {
+		c, _ := components[kind].(map[string]any)
+
+		_, ok := c[name]
+
+		return ok
+	}
+
+	prefix := strings.TrimSuffix(s.cfg.ServerPathPrefix(), "/")
+
+	paths := map[string]any{}
+
+	ids := map[string]string{}
+
+	ops := operations()
+
+	for route, op := range ops {
+		method, p, _ := strings.Cut(route, " ")
+
+		if r, ok := ids[op.ID]; ok {
+			return errors.New(errors.ErrServer,
+				"duplicate operation ID",
+				"operation_id", op.ID,
+				"route", route,
+				"duplicate_route", r)
+		}
+
+		ids[op.ID] = route
+
+		v, err := op.document(has)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrServer,
+				"invalid route documentation",
+				"route", route)
+		}
+
+		item, ok := paths[prefix+p].(map[string]any)
+		if !ok {
+			item = map[string]any{}
+
+			paths[prefix+p] = item
+		}
+
+		item[strings.ToLower(method)] = v
+	}
+
+	doc["paths"] = paths
+
+	js, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to encode OpenAPI document")
+	}
+
+	ys, err := yaml.Marshal(doc)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrServer,
+			"unable to encode OpenAPI document")
+	}
+
+	routes, err := s.routes()
+	if err != nil {
+		return err
+	}
+
+	s.openAPIJSON, s.openAPIYAML, s.undocumented = js, ys, nil
+
+	for _, route := range routes {
+		_, p, _ := strings.Cut(route, " ")
+
+		if _, ok := ops[route]; ok || undocumented(p) {
+			continue
+		}
+
+		s.undocumented = append(s.undocumented, route)
+
+		s.log.Log(context.Background(), logger.LvlWarn,
+			"undocumented route",
+			"route", route)
+	}
+
+	return nil
+}
+
+// UndocumentedRoutes returns the routes of the server which are missing from
+// the generated OpenAPI document.
+func (s *Server) UndocumentedRoutes() []string {
+	return slices.Clone(s.undocumented)
+}
+
+// document returns the OpenAPI operation object for the operation, verifying
+// that the components it refers to exist.
+func (op *Operation) document(has func(kind, name string) bool,
+) (map[string]any, error) {
+	ref := func(kind, name string) (map[string]any, error) {
+		if !has(kind, name) {
+			return nil, errors.New(errors.ErrServer,
+				"unknown OpenAPI component",
+				"kind", kind,
+				"name", name)
+		}
+
+		return map[string]any{"$ref": "#/components/" + kind + "/" + name}, nil
+	}
+
+	res := map[string]any{
+		"operationId": op.ID,
+		"tags":        []string{op.Tag},
+		"summary":     op.Summary,
+		"description": op.Description,
+	}
+
+	switch {
+	case op.Public:
+		res["security"] = []any{}
+	case len(op.Scopes) > 0:
+		res["security"] = []any{map[string]any{
+			"OAuth2PasswordBearer": op.Scopes,
+		}}
+	}
+
+	params := []any{}
+
+	for _, p := range op.Params {
+		if p.In == "" {
+			v, err := ref("parameters", p.Name)
+			if err != nil {
+				return nil, err
+			}
+
+			params = append(params, v)
+
+			continue
+		}
+
+		schema := map[string]any{"type": p.Type}
+
+		if len(p.Enum) > 0 {
+			schema["enum"] = p.Enum
+		}
+
+		params = append(params, map[string]any{
+			"name":        p.Name,
+			"in":          p.In,
+			"required":    p.Required || p.In == "path",
+			"description": p.Description,
+			"schema":      schema,
+		})
+	}
+
+	if len(params) > 0 {
+		res["parameters"] = params
+	}
+
+	if op.Body != "" {
+		v, err := ref("schemas", op.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		content := map[string]any{op.BodyType: map[string]any{"schema": v}}
+
+		// Bodies without a specific type may be sent in JSON or YAML.
+		if op.BodyType == "" {
+			content = map[string]any{
+				"application/json":      map[string]any{"schema": v},
+				request.ContentTypeYAML: map[string]any{"schema": v},
+			}
+		}
+
+		res["requestBody"] = map[string]any{
+			"required": !op.BodyOptional,
+			"content":  content,
+		}
+	}
+
+	responses := map[string]any{}
+
+	for code, name := range op.Responses {
+		if strings.Contains(name, " ") {
+			responses[strconv.Itoa(code)] = map[string]any{
+				"description": name,
+			}
+
+			continue
+		}
+
+		v, err := ref("responses", name)
+		if err != nil {
+			return nil, err
+		}
+
+		responses[strconv.Itoa(code)] = v
+	}
+
+	res["responses"] = responses
+
+	return res, nil
+}
+
+// openAPIFile returns a handler function responding with the generated
+// OpenAPI document, in JSON or YAML format.
+func (s *Server) openAPIFile(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		v, ct := s.openAPIJSON, "application/json; charset=UTF-8"
+
+		if format == "yaml" {
+			v, ct = s.openAPIYAML, "text/html; charset=UTF-8"
+		}
+
+		s.writeStatic(v, ct, s.staticCacheControl(), w, r)
+	}
+}
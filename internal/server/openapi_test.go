@@ -0,0 +1,75 @@
+package server_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/server"
+)
+
+func TestUndocumentedRoutes(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routes := svr.UndocumentedRoutes(); len(routes) > 0 {
+		t.Errorf("Expected all routes to be documented, undocumented: %v",
+			routes)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, basePath+"/openapi.json", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	var res struct {
+		Paths map[string]map[string]struct {
+			OperationID string           `json:"operationId"`
+			Parameters  []map[string]any `json:"parameters"`
+		} `json:"paths"`
+	}
+
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	op, ok := res.Paths[basePath+"/resources/{id}"]["get"]
+	if !ok {
+		t.Fatalf("Expected get resource operation, got: %v", res.Paths)
+	}
+
+	if exp := "get_resource"; op.OperationID != exp {
+		t.Errorf("Expected operation ID: %v, got: %v", exp, op.OperationID)
+	}
+
+	if exp := "#/components/parameters/id"; len(op.Parameters) == 0 ||
+		op.Parameters[0]["$ref"] != exp {
+		t.Errorf("Expected parameter: %v, got: %v", exp, op.Parameters)
+	}
+
+	if _, ok := res.Paths[basePath+"/debug/pprof"]; ok {
+		t.Errorf("Expected debug routes to be undocumented")
+	}
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+)
+
+// loginWindow is the period over which password authentication requests from
+// a client address are limited.
+const loginWindow = time.Minute
+
+// maxLoginClients is the number of client addresses for which password
+// authentication requests are counted before expired counts are removed.
+const maxLoginClients = 10000
+
+// loginCount values contain the number of password authentication requests
+// received from a client address in the current window.
+type loginCount struct {
+	start    time.Time
+	requests int
+}
+
+// loginLimiter values limit the rate of password authentication requests by
+// client address.
+type loginLimiter struct {
+	sync.Mutex
+	clients map[string]*loginCount
+}
+
+// allow counts a request from a client address. If the limit has been reached
+// in the current window, the request is not allowed, and the time until the
+// next window is returned.
+func (l *loginLimiter) allow(remote string,
+	limit int,
+	now time.Time,
+) (bool, time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.clients == nil {
+		l.clients = map[string]*loginCount{}
+	}
+
+	if len(l.clients) >= maxLoginClients {
+		for k, c := range l.clients {
+			if now.Sub(c.start) >= loginWindow {
+				delete(l.clients, k)
+			}
+		}
+	}
+
+	c, ok := l.clients[remote]
+	if !ok || now.Sub(c.start) >= loginWindow {
+		c = &loginCount{start: now}
+
+		l.clients[remote] = c
+	}
+
+	if c.requests >= limit {
+		return false, c.start.Add(loginWindow).Sub(now)
+	}
+
+	c.requests++
+
+	return true, 0
+}
+
+// passwordOperations documents the local authentication routes.
+var passwordOperations = map[string]*Operation{
+	"POST /auth/login": {
+		ID:      "login",
+		Tag:     "user",
+		Summary: "Log in",
+		Description: "Authenticates a user with a password stored by the " +
+			"service, and creates an API access token with the requested " +
+			"scopes, which must be held by the user. Users are locked out " +
+			"for a time after too many consecutive failed attempts. This " +
+			"route is only available when local authentication is enabled.",
+		Public:   true,
+		Body:     "token_request",
+		BodyType: "application/x-www-form-urlencoded",
+		Responses: map[int]string{
+			200: "token",
+			400: "user_error",
+			401: "user_error",
+			404: "user_error",
+			429: "user_error",
+			500: "error",
+		},
+	},
+	"POST /auth/password": {
+		ID:      "change_password",
+		Tag:     "user",
+		Summary: "Change password",
+		Description: "Changes the password of the authenticated user, " +
+			"after verifying the current password of the user. This route " +
+			"is only available when local authentication is enabled.",
+		Body: "password_change",
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			401: "user_error",
+			404: "user_error",
+			429: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// LocalAuth wraps an http handler so that it is only available when local
+// authentication is enabled.
+func (s *Server) LocalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.cfg.AuthLocalEnabled() {
+			s.error(errors.New(errors.ErrNotFound,
+				"local authentication not enabled"), w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LoginLimit wraps an http handler with rate limiting of password
+// authentication requests by client address.
+func (s *Server) LoginLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.cfg.AuthLocalRateLimit()
+		if limit < 0 {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		remote, err := request.ContextRemote(r.Context())
+		if err != nil || remote == "" {
+			remote = r.RemoteAddr
+
+			if host, _, err := net.SplitHostPort(remote); err == nil {
+				remote = host
+			}
+		}
+
+		ok, wait := s.logins.allow(remote, limit, time.Now())
+		if !ok {
+			w.Header().Set("Retry-After",
+				strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+
+			s.error(errors.New(errors.ErrorRateLimit,
+				"too many login attempts",
+				"remote", remote), w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// PostPassword is the post handler used by a user to change their password.
+func (s *Server) PostPassword(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	req := &auth.PasswordChange{}
+
+	if err := decodeRequest(r, req); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := svc.ChangePassword(ctx, req.CurrentPassword,
+		req.Password); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
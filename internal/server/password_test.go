@@ -0,0 +1,77 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+func (m *mockAuthService) ChangePassword(ctx context.Context,
+	current, password string,
+) error {
+	if current != "test" {
+		return errors.New(errors.ErrUnauthorized,
+			"invalid user id or password")
+	}
+
+	return nil
+}
+
+func TestPostPassword(t *testing.T) {
+	t.Parallel()
+
+	ac := &config.AuthConfig{LocalEnabled: true, LocalRateLimit: 2}
+
+	ac.Load()
+
+	cfg := config.NewDefault()
+
+	cfg.SetAuth(ac)
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	for _, tt := range []struct {
+		current string
+		code    int
+	}{
+		{"invalid", http.StatusUnauthorized},
+		{"test", http.StatusNoContent},
+		{"test", http.StatusTooManyRequests},
+	} {
+		r, err := http.NewRequest(http.MethodPost, basePath+"/auth/password",
+			strings.NewReader(`{"current_password":"`+tt.current+
+				`","password":"new-password"}`))
+		if err != nil {
+			t.Fatal("Failed to initialize request", err)
+		}
+
+		r.Header.Set("Authorization", "test")
+
+		w := httptest.NewRecorder()
+
+		svr.Mux(w, r)
+
+		if w.Code != tt.code {
+			t.Errorf("Code expected: %v, got: %v", tt.code, w.Code)
+		}
+	}
+}
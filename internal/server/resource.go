@@ -2,13 +2,14 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/repo"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/resource"
 	"github.com/dhaifley/apigo/internal/search"
@@ -18,10 +19,19 @@ import (
 
 // ResourceService values are used to perform resource management.
 type ResourceService interface {
+	Transact(ctx context.Context,
+		f func(ctx context.Context) error,
+	) error
 	GetResources(ctx context.Context,
 		query *search.Query,
 		options sqldb.FieldOptions,
 	) ([]*resource.Resource, []*sqldb.SummaryData, error)
+	CountResources(ctx context.Context,
+		query *search.Query,
+	) (int64, error)
+	ExplainResources(ctx context.Context,
+		query *search.Query,
+	) (*sqldb.QueryPlan, error)
 	GetResource(ctx context.Context,
 		id string,
 		options sqldb.FieldOptions,
@@ -32,25 +42,62 @@ type ResourceService interface {
 	UpdateResource(ctx context.Context,
 		v *resource.Resource,
 	) (*resource.Resource, error)
+	PreviewClearCondition(ctx context.Context,
+		id, condition string,
+		size int64,
+	) (*resource.ClearPreview, error)
 	DeleteResource(ctx context.Context,
 		id string,
 	) error
+	GetResourceDependents(ctx context.Context,
+		id string,
+		query *search.Query,
+		options sqldb.FieldOptions,
+	) ([]*resource.Resource, error)
+	GetResourceData(ctx context.Context,
+		id string,
+		query *search.Query,
+	) ([]*resource.DataItem, error)
+	GetResourceDataSummary(ctx context.Context,
+		id, group, value string,
+		query *search.Query,
+	) ([]*resource.DataSummary, error)
+	GetResourceRevisions(ctx context.Context,
+		id string,
+	) ([]*resource.Revision, error)
+	RollbackResource(ctx context.Context,
+		id string,
+		revision int64,
+	) (*resource.Resource, error)
+	DiffResource(ctx context.Context,
+		authSvc resource.AuthService,
+		id string,
+	) (*resource.ResourceDiff, error)
+	GetResourcesDelta(ctx context.Context,
+		sinceRevision int64,
+	) (*resource.ResourceDelta, error)
 	UpdateResourceData(ctx context.Context,
 		payload map[string]any,
 		accountID, resourceID string,
 	) (*resource.Resource, error)
+	UpdateResourcesData(ctx context.Context,
+		entries []*resource.ResourceDataEntry,
+	) (*request.MultiStatus, error)
 	UpdateResourceError(ctx context.Context,
 		accountID, resourceID string,
 		resourceError error,
 	) error
 	ImportResources(ctx context.Context,
 		force bool,
+		filter *repo.Filter,
 		authSvc resource.AuthService,
 	) error
 	ImportResource(ctx context.Context,
 		authSvc resource.AuthService,
 		resourceID string,
 	) error
+	GetImportErrors(ctx context.Context) ([]*resource.ImportError, error)
+	BootstrapResources(ctx context.Context) (int, error)
 	Update(ctx context.Context,
 		authSvc resource.AuthService,
 	) context.CancelFunc
@@ -72,6 +119,68 @@ type ResourceService interface {
 	DeleteTagsMultiAssignment(ctx context.Context,
 		v *resource.TagsMultiAssignment,
 	) (*resource.TagsMultiAssignment, error)
+	GetAgents(ctx context.Context,
+		query *search.Query,
+		options sqldb.FieldOptions,
+	) ([]*resource.Agent, []*sqldb.SummaryData, error)
+	GetAgent(ctx context.Context,
+		id string,
+		options sqldb.FieldOptions,
+	) (*resource.Agent, error)
+	RegisterAgent(ctx context.Context,
+		v *resource.Agent,
+	) (*resource.Agent, error)
+	HeartbeatAgent(ctx context.Context,
+		v *resource.Agent,
+	) (*resource.Agent, error)
+	DisableAgent(ctx context.Context,
+		id string,
+	) (*resource.Agent, error)
+	EnableAgent(ctx context.Context,
+		id string,
+	) (*resource.Agent, error)
+	DeleteAgent(ctx context.Context,
+		id string,
+	) error
+	GetAgentConfig(ctx context.Context,
+		id string,
+	) (*resource.AgentConfig, error)
+	PinAgentConfig(ctx context.Context,
+		id, version string,
+	) (*resource.Agent, error)
+	UnpinAgentConfig(ctx context.Context,
+		id string,
+	) (*resource.Agent, error)
+	GetAlertRules(ctx context.Context,
+		query *search.Query,
+		options sqldb.FieldOptions,
+	) ([]*resource.AlertRule, []*sqldb.SummaryData, error)
+	GetAlertRule(ctx context.Context,
+		id string,
+		options sqldb.FieldOptions,
+	) (*resource.AlertRule, error)
+	CreateAlertRule(ctx context.Context,
+		v *resource.AlertRule,
+	) (*resource.AlertRule, error)
+	UpdateAlertRule(ctx context.Context,
+		v *resource.AlertRule,
+	) (*resource.AlertRule, error)
+	DeleteAlertRule(ctx context.Context,
+		id string,
+	) error
+	GetAlerts(ctx context.Context,
+		query *search.Query,
+		options sqldb.FieldOptions,
+	) ([]*resource.Alert, []*sqldb.SummaryData, error)
+	GetResourceACL(ctx context.Context,
+		id string,
+	) ([]*resource.ACL, error)
+	SetResourceACL(ctx context.Context,
+		v *resource.ACL,
+	) (*resource.ACL, error)
+	DeleteResourceACL(ctx context.Context,
+		v *resource.ACL,
+	) error
 }
 
 // SetResourceService sets the get resource service function.
@@ -92,11 +201,15 @@ func (s *Server) ResourceHandler() http.Handler {
 
 	r.With(s.Stat, s.Trace, s.Auth).Post("/{id}/import", s.PostImportResource)
 	r.With(s.Stat, s.Trace, s.Auth).Post("/import", s.PostImportResources)
+	r.With(s.Stat, s.Trace, s.Auth).Get("/import/errors", s.GetImportErrors)
 
 	r.With(s.Stat, s.Trace).Post(
 		"/update/{account_id}/{id}",
 		s.PostUpdateResource)
 
+	r.With(s.Stat, s.Trace, s.Auth, s.Idempotent).Post("/data",
+		s.PostResourcesData)
+
 	r.With(s.Stat, s.Trace, s.Auth).Get("/tags", s.GetAllResourceTags)
 
 	r.With(s.Stat, s.Trace, s.Auth).Post("/tags_multi_assignments",
@@ -115,9 +228,29 @@ func (s *Server) ResourceHandler() http.Handler {
 	r.With(s.Stat, s.Trace, s.Auth).Delete("/{id}/tags",
 		s.DeleteResourceTags)
 
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}/dependents",
+		s.GetResourceDependents)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}/data", s.GetResourceData)
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}/data/summary",
+		s.GetResourceDataSummary)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}/revisions",
+		s.GetResourceRevisions)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/{id}/rollback/{revision}",
+		s.PostRollbackResource)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}/diff", s.GetResourceDiff)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}/acl", s.GetResourceACL)
+	r.With(s.Stat, s.Trace, s.Auth).Put("/{id}/acl", s.PutResourceACL)
+	r.With(s.Stat, s.Trace, s.Auth).Delete(
+		"/{id}/acl/{principal_type}/{principal_id}",
+		s.DeleteResourceACL)
+
 	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.SearchResource)
 	r.With(s.Stat, s.Trace, s.Auth).Get("/{id}", s.GetResource)
-	r.With(s.Stat, s.Trace, s.Auth).Post("/", s.PostResource)
+	r.With(s.Stat, s.Trace, s.Auth, s.Idempotent).Post("/", s.PostResource)
 	r.With(s.Stat, s.Trace, s.Auth).Patch("/{id}", s.PutResource)
 	r.With(s.Stat, s.Trace, s.Auth).Put("/{id}", s.PutResource)
 	r.With(s.Stat, s.Trace, s.Auth).Delete("/{id}", s.DeleteResource)
@@ -125,6 +258,469 @@ func (s *Server) ResourceHandler() http.Handler {
 	return r
 }
 
+// resourceOperations documents the resource routes.
+var resourceOperations = map[string]*Operation{
+	"GET /resources": {
+		ID:          "search_resources",
+		Tag:         "resources",
+		Summary:     "Search resources",
+		Description: "Retrieves resources based on a search query.",
+		Scopes:      []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "summary"},
+			{Name: "count"},
+			{Name: "case_insensitive"},
+			{Name: "explain"},
+			{Name: "envelope"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "resources",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources": {
+		ID:          "create_resource",
+		Tag:         "resources",
+		Summary:     "Create resource",
+		Description: "Creates a new resource and associated external access.",
+		Scopes:      []string{"resource:write"},
+		Params:      []*Parameter{idempotencyParam},
+		Body:        "resource",
+		Responses: map[int]string{
+			201: "resource",
+			400: "user_error",
+			409: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources:delta": {
+		ID:      "get_resources_delta",
+		Tag:     "resources",
+		Summary: "Get changed resources",
+		Description: "Retrieves the IDs of the resources created, updated " +
+			"and deleted since a revision, so that clients can synchronize " +
+			"only the resources which have changed.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{
+			{
+				Name: "since_revision",
+				In:   "query",
+				Type: "integer",
+				Description: "The revision, returned by a previous request, " +
+					"at or after which changes should be returned. If " +
+					"omitted, all changes are returned.",
+			},
+		},
+		Responses: map[int]string{
+			200: "resource_delta",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/{id}": {
+		ID:          "get_resource",
+		Tag:         "resources",
+		Summary:     "Get resource",
+		Description: "Retrieves details for a specific resource.",
+		Scopes:      []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "resource",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"PATCH /resources/{id}": {
+		ID:          "update_resource",
+		Tag:         "resources",
+		Summary:     "Update resource",
+		Description: "Updates details for a specific resource.",
+		Scopes:      []string{"resource:write"},
+		Params:      []*Parameter{{Name: "id"}},
+		Body:        "resource",
+		Responses: map[int]string{
+			200: "resource",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"PUT /resources/{id}": {
+		ID:          "replace_resource",
+		Tag:         "resources",
+		Summary:     "Replace resource",
+		Description: "Updates details for a specific resource.",
+		Scopes:      []string{"resource:write"},
+		Params:      []*Parameter{{Name: "id"}, {Name: "clear_preview"}},
+		Body:        "resource",
+		Responses: map[int]string{
+			200: "resource",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /resources/{id}": {
+		ID:          "delete_resource",
+		Tag:         "resources",
+		Summary:     "Delete resource",
+		Description: "Deletes a specific resource.",
+		Scopes:      []string{"resource:write"},
+		Params:      []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/{id}/dependents": {
+		ID:      "get_resource_dependents",
+		Tag:     "resources",
+		Summary: "Get resource dependents",
+		Description: "Retrieves the resources which list a specific " +
+			"resource in their depends_on field, optionally filtered by a " +
+			"search query.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "envelope"},
+			{Name: "include"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "resources",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/{id}/data": {
+		ID:      "get_resource_data",
+		Tag:     "resources",
+		Summary: "Get resource data items",
+		Description: "Retrieves the keyed data items of a specific " +
+			"resource, ordered by key, optionally filtered by a search " +
+			"query. The contents of the items are searched using fields " +
+			"such as data.status:error, so that clients are able to " +
+			"retrieve only the items they need, rather than all of the " +
+			"resource data.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{Name: "search"},
+			{Name: "case_insensitive"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "envelope"},
+		},
+		Responses: map[int]string{
+			200: "resource_data_items",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/{id}/data/summary": {
+		ID:      "get_resource_data_summary",
+		Tag:     "resources",
+		Summary: "Get resource data summary",
+		Description: "Summarizes the keyed data items of a specific " +
+			"resource, optionally filtered by a search query, grouped by " +
+			"the value of a data field. Each group reports the number of " +
+			"items, and the sum, minimum, maximum, and average of the " +
+			"numeric values of another data field, if one is requested. " +
+			"Groups are ordered by the number of items, most first.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{
+				Name:     "group",
+				In:       "query",
+				Type:     "string",
+				Required: true,
+				Description: "The data field to group the items by, such " +
+					"as status, or host.region for nested fields.",
+			},
+			{
+				Name: "value",
+				In:   "query",
+				Type: "string",
+				Description: "The data field whose numeric values are " +
+					"aggregated for each group.",
+			},
+			{Name: "search"},
+			{Name: "case_insensitive"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "envelope"},
+		},
+		Responses: map[int]string{
+			200: "resource_data_summary",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/{id}/revisions": {
+		ID:      "get_resource_revisions",
+		Tag:     "resources",
+		Summary: "Get resource revisions",
+		Description: "Retrieves the revisions of the definition of a " +
+			"specific resource, most recent first. A revision is recorded " +
+			"whenever the resource is created, or its definition is changed " +
+			"by an update, import or rollback.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "resource_revisions",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources/{id}/rollback/{revision}": {
+		ID:      "rollback_resource",
+		Tag:     "resources",
+		Summary: "Roll back resource",
+		Description: "Restores the definition of a specific resource to " +
+			"that of one of its revisions, recording a new revision. The " +
+			"version, source and commit hash of the resource are not " +
+			"changed, so a resource imported from the repository is not " +
+			"imported again until its repository file changes.",
+		Scopes: []string{"resource:write"},
+		Params: []*Parameter{
+			{Name: "id"},
+			{
+				Name:        "revision",
+				In:          "path",
+				Type:        "integer",
+				Required:    true,
+				Description: "The number of the revision to restore.",
+			},
+		},
+		Responses: map[int]string{
+			200: "resource",
+			400: "user_error",
+			403: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/{id}/diff": {
+		ID:      "get_resource_diff",
+		Tag:     "resources",
+		Summary: "Get resource diff",
+		Description: "Compares the definition of a specific resource with " +
+			"its definition in the current import repository file, so " +
+			"that drift can be reviewed before the resource is imported. " +
+			"Only the definition fields present in the repository file " +
+			"are compared.",
+		Scopes: []string{"resource:read"},
+		Params: []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "resource_diff",
+			400: "user_error",
+			404: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/import/errors": {
+		ID:      "get_resources_import_errors",
+		Tag:     "resources",
+		Summary: "Get resource import errors",
+		Description: "Retrieves the import repository files quarantined " +
+			"by the most recent import which read them, because they could " +
+			"not be parsed or were not valid resources. Quarantined files " +
+			"do not stop the rest of the repository from being imported, " +
+			"and the resources previously imported from them are kept.",
+		Scopes: []string{"resource:admin"},
+		Responses: map[int]string{
+			200: "import_errors",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources/import": {
+		ID:      "create_resources_import",
+		Tag:     "resources",
+		Summary: "Import resources",
+		Description: "Imports resources from the import repository. " +
+			"Only the files selected by the account repository filter are " +
+			"imported, unless any filter parameters are provided, which " +
+			"replace it for a partial import. Resources for files which " +
+			"are not selected are not changed.",
+		Scopes: []string{"resource:admin"},
+		Params: []*Parameter{{
+			Name: "path",
+			In:   "query",
+			Type: "string",
+			Description: "A glob matching the paths of the files to import, " +
+				"relative to the resources directory.",
+		}, {
+			Name:        "name_prefix",
+			In:          "query",
+			Type:        "string",
+			Description: "A prefix of the names of the resources to import.",
+		}, {
+			Name: "labels",
+			In:   "query",
+			Type: "string",
+			Description: "A comma separated list of key=value pairs, all of " +
+				"which must be present in the metadata labels of the " +
+				"resources to import.",
+		}},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources/{id}/import": {
+		ID:          "create_resource_import",
+		Tag:         "resources",
+		Summary:     "Import resource",
+		Description: "Imports a single resource from the import repository.",
+		Scopes:      []string{"resource:admin"},
+		Params:      []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources/data": {
+		ID:      "create_resources_data",
+		Tag:     "resources",
+		Summary: "Update resources data",
+		Description: "Updates the data of multiple resources. Each resource " +
+			"is updated independently and the result of each update is " +
+			"reported in the response.",
+		Scopes: []string{"resource:write"},
+		Params: []*Parameter{idempotencyParam},
+		Body:   "resource_data",
+		Responses: map[int]string{
+			200: "multi_status",
+			207: "multi_status",
+			400: "user_error",
+			409: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/{id}/tags": {
+		ID:          "get_tags",
+		Tag:         "tags",
+		Summary:     "Get tags",
+		Description: "Retrieves tags for a resource.",
+		Scopes:      []string{"resource:read"},
+		Params:      []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			200: "tags",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources/{id}/tags": {
+		ID:          "create_tags",
+		Tag:         "tags",
+		Summary:     "Create tags",
+		Description: "Adds tags to a resource.",
+		Scopes:      []string{"resource:write"},
+		Params:      []*Parameter{{Name: "id"}},
+		Body:        "tags",
+		Responses: map[int]string{
+			200: "tags",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /resources/{id}/tags": {
+		ID:          "delete_tags",
+		Tag:         "tags",
+		Summary:     "Delete tags",
+		Description: "Deletes tags for a specific resource.",
+		Scopes:      []string{"resource:write"},
+		Params:      []*Parameter{{Name: "id"}},
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources/tags_multi_assignments": {
+		ID:          "create_tags_multi_assignment",
+		Tag:         "tags",
+		Summary:     "Create tags_multi_assignment",
+		Description: "Creates tags across multiple resources.",
+		Scopes:      []string{"resource:write"},
+		Body:        "tags_multi_assignment",
+		Responses: map[int]string{
+			201: "tags_multi_assignment",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"DELETE /resources/tags_multi_assignments": {
+		ID:          "delete_tags_multi_assignment",
+		Tag:         "tags",
+		Summary:     "Delete tags_multi_assignment",
+		Description: "Deletes tags across multiple resources.",
+		Scopes:      []string{"resource:write"},
+		Body:        "tags_multi_assignment",
+		Responses: map[int]string{
+			200: "tags_multi_assignment",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"GET /resources/tags": {
+		ID:          "get_all_tags",
+		Tag:         "tags",
+		Summary:     "Get all tags",
+		Description: "Retrieves the tags set for any resource.",
+		Scopes:      []string{"resource:read"},
+		Responses: map[int]string{
+			200: "tags",
+			400: "user_error",
+			500: "error",
+		},
+	},
+	"POST /resources/update/{account_id}/{id}": {
+		ID:      "update_resource_data",
+		Tag:     "resources",
+		Summary: "Update resource data",
+		Description: "Updates the data of a specific resource, for external " +
+			"systems. The request body is the resource data update payload. " +
+			"If it can not be decoded, the error is recorded as the status " +
+			"of the resource.",
+		Public: true,
+		Params: []*Parameter{{
+			Name:        "account_id",
+			In:          "path",
+			Type:        "string",
+			Description: "The ID of the account of the resource.",
+		}, {Name: "id"}},
+		Responses: map[int]string{
+			200: "resource",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
 // SearchResource is the search handler function for resource types.
 func (s *Server) SearchResource(w http.ResponseWriter, r *http.Request) {
 	svc := s.getResourceService(r)
@@ -137,13 +733,19 @@ func (s *Server) SearchResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	q, err := search.ParseQuery(r.URL.Query())
+	q, err := s.parseQuery(r)
 	if err != nil {
 		s.error(err, w, r)
 
 		return
 	}
 
+	if q.Explain {
+		s.explainResource(w, r, q)
+
+		return
+	}
+
 	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
 	if err != nil {
 		s.error(err, w, r)
@@ -159,14 +761,59 @@ func (s *Server) SearchResource(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if q.Summary != "" {
-		if err := json.NewEncoder(w).Encode(sum); err != nil {
+		env := s.newEnvelope(r, q, []*resource.Resource{}, false)
+
+		env.Summary = sum
+
+		s.encodeList(env, opts, "resource_id", w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	env := s.newEnvelope(r, q, res, more)
+
+	if q.Count {
+		n, err := svc.CountResources(ctx, q)
+		if err != nil {
 			s.error(err, w, r)
+
+			return
 		}
 
+		w.Header().Set("X-Total-Count", strconv.FormatInt(n, 10))
+
+		env.Paging.Total = &n
+	}
+
+	s.encodeList(env, opts, "resource_id", w, r)
+}
+
+// explainResource writes the estimated cost of a resource search, instead of
+// its results, in response to a search request. Only resource administrators
+// may request the cost of searches.
+func (s *Server) explainResource(w http.ResponseWriter, r *http.Request,
+	q *search.Query,
+) {
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	res, err := s.getResourceService(r).ExplainResources(ctx, q)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -199,7 +846,244 @@ func (s *Server) GetResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	s.encodeFields(res, opts, "resource_id", w, r)
+}
+
+// GetResourceDependents is the get handler function for the resources which
+// depend on a resource.
+func (s *Server) GetResourceDependents(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetResourceDependents(ctx, chi.URLParam(r, "id"), q,
+		opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), opts, "resource_id", w, r)
+}
+
+// GetResourceData is the get handler function for the data items of a
+// resource.
+func (s *Server) GetResourceData(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetResourceData(ctx, chi.URLParam(r, "id"), q)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), nil, "key", w, r)
+}
+
+// GetResourceDataSummary is the get handler function for the summary of the
+// data items of a resource.
+func (s *Server) GetResourceDataSummary(w http.ResponseWriter,
+	r *http.Request,
+) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetResourceDataSummary(ctx, chi.URLParam(r, "id"),
+		r.URL.Query().Get("group"), r.URL.Query().Get("value"), q)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), nil, "value", w, r)
+}
+
+// GetResourceRevisions is the get handler function for the revisions of a
+// resource.
+func (s *Server) GetResourceRevisions(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetResourceRevisions(ctx, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostRollbackResource is the post handler function for restoring a revision
+// of a resource.
+func (s *Server) PostRollbackResource(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesWrite); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	rev, err := strconv.ParseInt(chi.URLParam(r, "revision"), 10, 64)
+	if err != nil {
+		s.error(errors.New(errors.ErrInvalidRequest,
+			"invalid revision",
+			"revision", chi.URLParam(r, "revision")), w, r)
+
+		return
+	}
+
+	res, err := svc.RollbackResource(ctx, chi.URLParam(r, "id"), rev)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// GetResourceDiff is the get handler function for the differences between a
+// resource and its import repository file.
+func (s *Server) GetResourceDiff(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	aSvc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.DiffResource(ctx, aSvc, chi.URLParam(r, "id"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// GetResourcesDelta is the get handler function for the IDs of the resources
+// changed since a revision.
+func (s *Server) GetResourcesDelta(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	since := int64(0)
+
+	if v := r.URL.Query().Get("since_revision"); v != "" {
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid since_revision",
+				"since_revision", v), w, r)
+
+			return
+		}
+
+		since = i
+	}
+
+	res, err := svc.GetResourcesDelta(ctx, since)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -218,20 +1102,36 @@ func (s *Server) PostResource(w http.ResponseWriter, r *http.Request) {
 
 	req := &resource.Resource{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
 
-	res, err := svc.CreateResource(ctx, req)
-	if err != nil {
+	var res *resource.Resource
+
+	// The resource and its tags are created as a single unit of work, so that
+	// the resource is not created unless its tags can also be assigned.
+	if err := svc.Transact(ctx, func(ctx context.Context) error {
+		var err error
+
+		res, err = svc.CreateResource(ctx, req)
+		if err != nil || len(req.Tags.Value) == 0 {
+			return err
+		}
+
+		tags, err := svc.AddResourceTags(ctx, res.ResourceID.Value,
+			req.Tags.Value)
+		if err != nil {
+			return err
+		}
+
+		res.Tags = request.FieldStringArray{
+			Set: true, Valid: true, Value: tags,
+		}
+
+		return nil
+	}); err != nil {
 		s.error(err, w, r)
 
 		return
@@ -252,7 +1152,7 @@ func (s *Server) PostResource(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Location", loc.String())
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -273,14 +1173,8 @@ func (s *Server) PutResource(w http.ResponseWriter, r *http.Request) {
 
 	req := &resource.Resource{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -290,6 +1184,31 @@ func (s *Server) PutResource(w http.ResponseWriter, r *http.Request) {
 		Value: id,
 	}
 
+	// A changed clear_condition can optionally be evaluated against the most
+	// recently stored data items of the resource, before it is applied.
+	var preview *resource.ClearPreview
+
+	if v := r.URL.Query().Get("clear_preview"); v != "" &&
+		req.ClearCondition.Set && req.ClearCondition.Valid &&
+		req.ClearCondition.Value != "" {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size < 1 {
+			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
+				"invalid clear_preview",
+				"clear_preview", v), w, r)
+
+			return
+		}
+
+		preview, err = svc.PreviewClearCondition(ctx, id,
+			req.ClearCondition.Value, size)
+		if err != nil {
+			s.error(err, w, r)
+
+			return
+		}
+	}
+
 	res, err := svc.UpdateResource(ctx, req)
 	if err != nil {
 		s.error(err, w, r)
@@ -297,7 +1216,18 @@ func (s *Server) PutResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if preview != nil {
+		if err := encodeResponse(w, r, struct {
+			*resource.Resource
+			ClearPreview *resource.ClearPreview `json:"clear_preview"`
+		}{res, preview}); err != nil {
+			s.error(err, w, r)
+		}
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -338,15 +1268,10 @@ func (s *Server) PostUpdateResource(w http.ResponseWriter, r *http.Request) {
 
 	req := map[string]any{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		var dErr *errors.Error
-
-		switch e := err.(type) {
-		case *errors.Error:
-			dErr = e
-		default:
-			dErr = errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request")
+	if err := decodeRequestNumber(r, &req); err != nil {
+		dErr, ok := err.(*errors.Error)
+		if !ok {
+			dErr = errors.Wrap(err, errors.ErrInvalidRequest, "")
 		}
 
 		aErr := svc.UpdateResourceError(ctx, accountID, resourceID, dErr)
@@ -371,9 +1296,40 @@ func (s *Server) PostUpdateResource(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostResourcesData is the post handler function for external systems to
+// update the resource data of multiple resources.
+func (s *Server) PostResourcesData(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesWrite); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	req := []*resource.ResourceDataEntry{}
+
+	if err := decodeRequestNumber(r, &req); err != nil {
 		s.error(err, w, r)
+
+		return
 	}
+
+	res, err := svc.UpdateResourcesData(ctx, req)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	s.multiStatus(res, w, r)
 }
 
 // PostImportResources is the post handler used to import resources.
@@ -397,7 +1353,29 @@ func (s *Server) PostImportResources(w http.ResponseWriter, r *http.Request) {
 		force = true
 	}
 
-	if err := svc.ImportResources(ctx, force, aSvc); err != nil {
+	filter := &repo.Filter{
+		Path:       r.URL.Query().Get("path"),
+		NamePrefix: r.URL.Query().Get("name_prefix"),
+	}
+
+	if ls := r.URL.Query().Get("labels"); ls != "" {
+		labels, err := repo.ParseLabels(ls)
+		if err != nil {
+			s.error(err, w, r)
+
+			return
+		}
+
+		filter.Labels = labels
+	}
+
+	if err := filter.Validate(); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := svc.ImportResources(ctx, force, filter, aSvc); err != nil {
 		s.error(err, w, r)
 
 		return
@@ -406,6 +1384,31 @@ func (s *Server) PostImportResources(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// GetImportErrors is the get handler function for the repository files
+// quarantined by resource imports.
+func (s *Server) GetImportErrors(w http.ResponseWriter, r *http.Request) {
+	svc := s.getResourceService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeResourcesAdmin); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetImportErrors(ctx)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
 // PostImportResource is the post handler used to import a single resource.
 func (s *Server) PostImportResource(w http.ResponseWriter, r *http.Request) {
 	svc := s.getResourceService(r)
@@ -450,7 +1453,7 @@ func (s *Server) GetAllResourceTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -476,7 +1479,7 @@ func (s *Server) GetResourceTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -497,14 +1500,8 @@ func (s *Server) PostResourceTags(w http.ResponseWriter, r *http.Request) {
 
 	tags := []string{}
 
-	if err := json.NewDecoder(r.Body).Decode(&tags); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &tags); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -520,7 +1517,7 @@ func (s *Server) PostResourceTags(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusCreated)
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -541,14 +1538,8 @@ func (s *Server) DeleteResourceTags(w http.ResponseWriter, r *http.Request) {
 
 	tags := []string{}
 
-	if err := json.NewDecoder(r.Body).Decode(&tags); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &tags); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -579,14 +1570,8 @@ func (s *Server) PostTagsMultiAssignment(w http.ResponseWriter,
 
 	req := &resource.TagsMultiAssignment{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -613,7 +1598,7 @@ func (s *Server) PostTagsMultiAssignment(w http.ResponseWriter,
 
 	w.WriteHeader(http.StatusCreated)
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -635,14 +1620,8 @@ func (s *Server) DeleteTagsMultiAssignment(w http.ResponseWriter,
 
 	req := &resource.TagsMultiAssignment{}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, &req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -654,7 +1633,7 @@ func (s *Server) DeleteTagsMultiAssignment(w http.ResponseWriter,
 		return
 	}
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
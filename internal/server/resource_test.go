@@ -9,6 +9,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/repo"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/resource"
 	"github.com/dhaifley/apigo/internal/search"
@@ -113,6 +115,24 @@ func (m *mockResourceService) GetResources(ctx context.Context,
 	}}, nil
 }
 
+func (m *mockResourceService) CountResources(ctx context.Context,
+	query *search.Query,
+) (int64, error) {
+	return 1, nil
+}
+
+func (m *mockResourceService) ExplainResources(ctx context.Context,
+	query *search.Query,
+) (*sqldb.QueryPlan, error) {
+	return &sqldb.QueryPlan{TotalCost: 10.5, Rows: 1}, nil
+}
+
+func (m *mockResourceService) Transact(ctx context.Context,
+	f func(ctx context.Context) error,
+) error {
+	return f(ctx)
+}
+
 func (m *mockResourceService) GetResource(ctx context.Context,
 	id string,
 	options sqldb.FieldOptions,
@@ -132,12 +152,119 @@ func (m *mockResourceService) UpdateResource(ctx context.Context,
 	return &TestResource, nil
 }
 
+func (m *mockResourceService) PreviewClearCondition(ctx context.Context,
+	id, condition string,
+	size int64,
+) (*resource.ClearPreview, error) {
+	return &resource.ClearPreview{
+		ClearCondition: condition,
+		Evaluated:      1,
+		Cleared:        1,
+		Keys:           []string{"test"},
+	}, nil
+}
+
 func (m *mockResourceService) DeleteResource(ctx context.Context,
 	id string,
 ) error {
 	return nil
 }
 
+func (m *mockResourceService) GetResourceDependents(ctx context.Context,
+	id string,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*resource.Resource, error) {
+	return []*resource.Resource{&TestResource}, nil
+}
+
+func (m *mockResourceService) GetResourceData(ctx context.Context,
+	id string,
+	query *search.Query,
+) ([]*resource.DataItem, error) {
+	return []*resource.DataItem{{
+		Key: request.FieldString{Set: true, Valid: true, Value: "1"},
+		Data: request.FieldJSON{
+			Set: true, Valid: true, Value: map[string]any{"status": "error"},
+		},
+		TS: request.FieldTime{Set: true, Valid: true, Value: 1},
+	}}, nil
+}
+
+func (m *mockResourceService) GetResourceDataSummary(ctx context.Context,
+	id, group, value string,
+	query *search.Query,
+) ([]*resource.DataSummary, error) {
+	if group == "" {
+		return nil, errors.New(errors.ErrInvalidRequest,
+			"invalid resource data summary group")
+	}
+
+	return []*resource.DataSummary{{
+		Value: request.FieldString{Set: true, Valid: true, Value: "error"},
+		Count: 2,
+		Sum:   request.FieldFloat64{Set: true, Valid: true, Value: 3},
+		Min:   request.FieldFloat64{Set: true, Valid: true, Value: 1},
+		Max:   request.FieldFloat64{Set: true, Valid: true, Value: 2},
+		Avg:   request.FieldFloat64{Set: true, Valid: true, Value: 1.5},
+	}}, nil
+}
+
+func (m *mockResourceService) GetResourceRevisions(ctx context.Context,
+	id string,
+) ([]*resource.Revision, error) {
+	return []*resource.Revision{{
+		ResourceID: TestResource.ResourceID,
+		Revision:   request.FieldInt64{Set: true, Valid: true, Value: 2},
+		Definition: request.FieldJSON{
+			Set: true, Valid: true,
+			Value: map[string]any{"name": TestResource.Name.Value},
+		},
+	}}, nil
+}
+
+func (m *mockResourceService) RollbackResource(ctx context.Context,
+	id string,
+	revision int64,
+) (*resource.Resource, error) {
+	if revision != 1 {
+		return nil, errors.New(errors.ErrNotFound,
+			"resource revision not found",
+			"id", id,
+			"revision", revision)
+	}
+
+	return &TestResource, nil
+}
+
+func (m *mockResourceService) DiffResource(ctx context.Context,
+	authSvc resource.AuthService,
+	id string,
+) (*resource.ResourceDiff, error) {
+	return &resource.ResourceDiff{
+		ResourceID:     TestResource.ResourceID.Value,
+		CommitHash:     TestResource.CommitHash.Value,
+		RepoCommitHash: "test",
+		InRepo:         true,
+		Changed:        true,
+		Fields: []*resource.FieldDiff{{
+			Field: "name",
+			Live:  TestResource.Name.Value,
+			Repo:  "test",
+		}},
+	}, nil
+}
+
+func (m *mockResourceService) GetResourcesDelta(ctx context.Context,
+	sinceRevision int64,
+) (*resource.ResourceDelta, error) {
+	res := resource.NewResourceDelta(sinceRevision + 10)
+
+	res.Add(TestResource.ResourceID.Value, "create", "update")
+
+	return res, nil
+}
+
 func (m *mockResourceService) UpdateResourceData(ctx context.Context,
 	payload map[string]any,
 	accountID, resourceID string,
@@ -145,6 +272,25 @@ func (m *mockResourceService) UpdateResourceData(ctx context.Context,
 	return &TestResource, nil
 }
 
+func (m *mockResourceService) UpdateResourcesData(ctx context.Context,
+	entries []*resource.ResourceDataEntry,
+) (*request.MultiStatus, error) {
+	res := request.NewMultiStatus()
+
+	for i, e := range entries {
+		if e.ResourceID.Value != TestUUID {
+			res.Add(i, e.ResourceID.Value, 0, nil,
+				errors.New(errors.ErrNotFound, "resource not found"))
+
+			continue
+		}
+
+		res.Add(i, e.ResourceID.Value, http.StatusOK, &TestResource, nil)
+	}
+
+	return res, nil
+}
+
 func (m *mockResourceService) UpdateResourceError(ctx context.Context,
 	accountID, resourceID string,
 	resourceError error,
@@ -154,66 +300,596 @@ func (m *mockResourceService) UpdateResourceError(ctx context.Context,
 
 func (m *mockResourceService) ImportResources(ctx context.Context,
 	force bool,
+	filter *repo.Filter,
+	authSvc resource.AuthService,
+) error {
+	return nil
+}
+
+func (m *mockResourceService) ImportResource(ctx context.Context,
+	authSvc resource.AuthService,
+	resourceID string,
+) error {
+	return nil
+}
+
+func (m *mockResourceService) GetImportErrors(ctx context.Context,
+) ([]*resource.ImportError, error) {
+	return []*resource.ImportError{{
+		Path: request.FieldString{
+			Set: true, Valid: true, Value: "resources/test.yaml",
+		},
+		Error: request.FieldString{
+			Set: true, Valid: true, Value: "invalid resource",
+		},
+	}}, nil
+}
+
+func (m *mockResourceService) BootstrapResources(ctx context.Context,
+) (int, error) {
+	return 0, nil
+}
+
+func (m *mockResourceService) Update(ctx context.Context,
 	authSvc resource.AuthService,
+) context.CancelFunc {
+	_, cancel := context.WithCancel(ctx)
+
+	return cancel
+}
+
+func (m *mockResourceService) GetTags(ctx context.Context,
+) (resource.TagMap, error) {
+	return resource.TagMap{"test": []string{"test"}}, nil
+}
+
+func (m *mockResourceService) GetResourceTags(ctx context.Context,
+	resourceID string,
+) ([]string, error) {
+	return []string{"test:test"}, nil
+}
+
+func (m *mockResourceService) AddResourceTags(ctx context.Context,
+	resourceID string,
+	tags []string,
+) ([]string, error) {
+	return tags, nil
+}
+
+func (m *mockResourceService) DeleteResourceTags(ctx context.Context,
+	resourceID string,
+	tags []string,
 ) error {
 	return nil
 }
 
-func (m *mockResourceService) ImportResource(ctx context.Context,
-	authSvc resource.AuthService,
-	resourceID string,
-) error {
-	return nil
+func (m *mockResourceService) CreateTagsMultiAssignment(
+	ctx context.Context,
+	v *resource.TagsMultiAssignment,
+) (*resource.TagsMultiAssignment, error) {
+	return v, nil
+}
+
+func (m *mockResourceService) DeleteTagsMultiAssignment(
+	ctx context.Context,
+	v *resource.TagsMultiAssignment,
+) (*resource.TagsMultiAssignment, error) {
+	return v, nil
+}
+
+func TestSearchResource(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		url    string
+		query  string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "success",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp: `"resource_id":"` +
+			TestResource.ResourceID.Value + `"`,
+	}, {
+		name:   "summary",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources",
+		query:  `?search=and(name:*)&summary=status`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"count":1`,
+	}, {
+		name:   "fields",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources",
+		query:  `?fields=name`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp: `[{"name":"testName","resource_id":"` +
+			TestResource.ResourceID.Value + `"}]`,
+	}, {
+		name:   "invalid fields",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources",
+		query:  `?fields=name.status`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `invalid fields value`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			u := tt.url + tt.query
+
+			r, err := http.NewRequest(http.MethodGet, u, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestSearchResourceCount(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet,
+		basePath+"/resources?count=true", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", "test")
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	if v := w.Header().Get("X-Total-Count"); v != "1" {
+		t.Errorf("Expected total count: 1, got: %v", v)
+	}
+
+	if v := w.Header().Get("X-Has-More"); v != "false" {
+		t.Errorf("Expected has more: false, got: %v", v)
+	}
+}
+
+func TestGetResource(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		url    string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "success",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources/" + TestResource.ResourceID.Value,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp: `"resource_id":"` +
+			TestResource.ResourceID.Value + `"`,
+	}, {
+		name: "fields",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"?fields=name,status",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp: `{"name":"testName","resource_id":"` +
+			TestResource.ResourceID.Value + `","status":"` +
+			TestResource.Status.Value + `"}`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestGetResourceDependents(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		url    string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name: "success",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"/dependents",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp: `"resource_id":"` +
+			TestResource.ResourceID.Value + `"`,
+	}, {
+		name: "envelope",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"/dependents?search=status:active",
+		header: map[string]string{
+			"Authorization": "test",
+			"X-Envelope":    "true",
+		},
+		code: http.StatusOK,
+		resp: `"paging":{`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestGetResourceData(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		url    string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name: "success",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"/data?search=and(data.status:error)",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"key":"1","data":{"status":"error"}`,
+	}, {
+		name: "envelope",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"/data?size=1",
+		header: map[string]string{
+			"Authorization": "test",
+			"X-Envelope":    "true",
+		},
+		code: http.StatusOK,
+		resp: `"paging":{`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestGetResourceDataSummary(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		url    string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name: "success",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"/data/summary?group=status&value=count",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"value":"error","count":2,"sum":3,"min":1,"max":2,"avg":1.5`,
+	}, {
+		name: "missing group",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"/data/summary",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `invalid resource data summary group`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
+func TestGetResourceRevisions(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, basePath+"/resources/"+
+		TestResource.ResourceID.Value+"/revisions", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", "test")
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	exp := `"revision":2`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
 }
 
-func (m *mockResourceService) Update(ctx context.Context,
-	authSvc resource.AuthService,
-) context.CancelFunc {
-	_, cancel := context.WithCancel(ctx)
+func TestGetResourceDiff(t *testing.T) {
+	t.Parallel()
 
-	return cancel
-}
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func (m *mockResourceService) GetTags(ctx context.Context,
-) (resource.TagMap, error) {
-	return resource.TagMap{"test": []string{"test"}}, nil
-}
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-func (m *mockResourceService) GetResourceTags(ctx context.Context,
-	resourceID string,
-) ([]string, error) {
-	return []string{"test:test"}, nil
-}
+	svr.SetDB(md)
 
-func (m *mockResourceService) AddResourceTags(ctx context.Context,
-	resourceID string,
-	tags []string,
-) ([]string, error) {
-	return tags, nil
-}
+	svr.SetAuthService(&mockAuthService{})
 
-func (m *mockResourceService) DeleteResourceTags(ctx context.Context,
-	resourceID string,
-	tags []string,
-) error {
-	return nil
-}
+	svr.SetResourceService(&mockResourceService{})
 
-func (m *mockResourceService) CreateTagsMultiAssignment(
-	ctx context.Context,
-	v *resource.TagsMultiAssignment,
-) (*resource.TagsMultiAssignment, error) {
-	return v, nil
-}
+	w := httptest.NewRecorder()
 
-func (m *mockResourceService) DeleteTagsMultiAssignment(
-	ctx context.Context,
-	v *resource.TagsMultiAssignment,
-) (*resource.TagsMultiAssignment, error) {
-	return v, nil
+	r, err := http.NewRequest(http.MethodGet, basePath+"/resources/"+
+		TestResource.ResourceID.Value+"/diff", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", "test")
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	exp := `"fields":[{"field":"name"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
 }
 
-func TestSearchResource(t *testing.T) {
+func TestPostRollbackResource(t *testing.T) {
 	t.Parallel()
 
 	svr, err := server.NewServer(nil, nil, nil, nil)
@@ -233,45 +909,43 @@ func TestSearchResource(t *testing.T) {
 	svr.SetResourceService(&mockResourceService{})
 
 	tests := []struct {
-		name   string
-		w      *httptest.ResponseRecorder
-		url    string
-		query  string
-		header map[string]string
-		code   int
-		resp   string
+		name string
+		w    *httptest.ResponseRecorder
+		rev  string
+		code int
+		resp string
 	}{{
-		name:   "success",
-		w:      httptest.NewRecorder(),
-		url:    basePath + "/resources",
-		header: map[string]string{"Authorization": "test"},
-		code:   http.StatusOK,
-		resp: `"resource_id":"` +
-			TestResource.ResourceID.Value + `"`,
+		name: "success",
+		w:    httptest.NewRecorder(),
+		rev:  "1",
+		code: http.StatusOK,
+		resp: `"resource_id":"` + TestResource.ResourceID.Value + `"`,
 	}, {
-		name:   "summary",
-		w:      httptest.NewRecorder(),
-		url:    basePath + "/resources",
-		query:  `?search=and(name:*)&summary=status`,
-		header: map[string]string{"Authorization": "test"},
-		code:   http.StatusOK,
-		resp:   `"count":1`,
+		name: "not found",
+		w:    httptest.NewRecorder(),
+		rev:  "5",
+		code: http.StatusNotFound,
+		resp: `"code":"NotFound"`,
+	}, {
+		name: "invalid",
+		w:    httptest.NewRecorder(),
+		rev:  "invalid",
+		code: http.StatusBadRequest,
+		resp: `"code":"InvalidRequest"`,
 	}}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			u := tt.url + tt.query
-
-			r, err := http.NewRequest(http.MethodGet, u, nil)
+			r, err := http.NewRequest(http.MethodPost, basePath+
+				"/resources/"+TestResource.ResourceID.Value+"/rollback/"+
+				tt.rev, nil)
 			if err != nil {
 				t.Fatal("Failed to initialize request", err)
 			}
 
-			for th, tv := range tt.header {
-				r.Header.Set(th, tv)
-			}
+			r.Header.Set("Authorization", "test")
 
 			svr.Mux(tt.w, r)
 
@@ -287,7 +961,7 @@ func TestSearchResource(t *testing.T) {
 	}
 }
 
-func TestGetResource(t *testing.T) {
+func TestGetResourcesDelta(t *testing.T) {
 	t.Parallel()
 
 	svr, err := server.NewServer(nil, nil, nil, nil)
@@ -316,11 +990,18 @@ func TestGetResource(t *testing.T) {
 	}{{
 		name:   "success",
 		w:      httptest.NewRecorder(),
-		url:    basePath + "/resources/" + TestResource.ResourceID.Value,
+		url:    basePath + "/resources:delta?since_revision=5",
 		header: map[string]string{"Authorization": "test"},
 		code:   http.StatusOK,
-		resp: `"resource_id":"` +
-			TestResource.ResourceID.Value + `"`,
+		resp: `{"revision":15,"created":["` +
+			TestResource.ResourceID.Value + `"],"updated":[],"deleted":[]}`,
+	}, {
+		name:   "invalid revision",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources:delta?since_revision=x",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `invalid since_revision`,
 	}}
 
 	for _, tt := range tests {
@@ -350,6 +1031,66 @@ func TestGetResource(t *testing.T) {
 	}
 }
 
+func TestGetResourceETag(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+
+		r, err := http.NewRequest(http.MethodGet, basePath+"/resources/"+
+			TestResource.ResourceID.Value, nil)
+		if err != nil {
+			t.Fatal("Failed to initialize request", err)
+		}
+
+		r.Header.Set("Authorization", "test")
+
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+
+		svr.Mux(w, r)
+
+		return w
+	}
+
+	w := get("")
+
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected ETag with code: %v, got: %v, %v",
+			http.StatusOK, w.Code, etag)
+	}
+
+	if w = get(""); w.Header().Get("ETag") != etag {
+		t.Errorf("Expected ETag: %v, got: %v", etag, w.Header().Get("ETag"))
+	}
+
+	if w = get(etag); w.Code != http.StatusNotModified {
+		t.Errorf("Code expected: %v, got: %v", http.StatusNotModified, w.Code)
+	}
+
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected empty body, got: %v", w.Body.String())
+	}
+}
+
 func TestPostResource(t *testing.T) {
 	t.Parallel()
 
@@ -460,6 +1201,24 @@ func TestPutResource(t *testing.T) {
 		code:   http.StatusOK,
 		resp: `"resource_id":"` +
 			TestResource.ResourceID.Value + `"`,
+	}, {
+		name: "clear preview",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"?clear_preview=10",
+		body:   `{"clear_condition": "status:ok"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"clear_preview":{"clear_condition":"status:ok","evaluated":1`,
+	}, {
+		name: "invalid clear preview",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/" + TestResource.ResourceID.Value +
+			"?clear_preview=none",
+		body:   `{"clear_condition": "status:ok"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `invalid clear_preview`,
 	}}
 
 	for _, tt := range tests {
@@ -622,6 +1381,97 @@ func TestPostUpdateResources(t *testing.T) {
 	}
 }
 
+func TestPostResourcesData(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		url    string
+		body   string
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name: "success",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/resources/data",
+		body: `[{
+			"resource_id": "` + TestUUID + `",
+			"data": {"resource_id": "` + TestUUID + `"}
+		}]`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"succeeded":1`,
+	}, {
+		name: "partial failure",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/resources/data",
+		body: `[{
+			"resource_id": "` + TestUUID + `",
+			"data": {"resource_id": "` + TestUUID + `"}
+		}, {
+			"resource_id": "` + TestID + `",
+			"data": {"resource_id": "` + TestID + `"}
+		}]`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusMultiStatus,
+		resp:   `"failed":1`,
+	}, {
+		name:   "invalid request",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources/data",
+		body:   `{"resource_id": "` + TestUUID + `"}`,
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusBadRequest,
+		resp:   `"code":"InvalidRequest"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := bytes.NewBufferString(tt.body)
+
+			r, err := http.NewRequest(http.MethodPost, tt.url, buf)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
+
 func TestPostImportResources(t *testing.T) {
 	t.Parallel()
 
@@ -653,6 +1503,25 @@ func TestPostImportResources(t *testing.T) {
 		url:    basePath + "/resources/import",
 		header: map[string]string{"Authorization": "admin"},
 		code:   http.StatusNoContent,
+	}, {
+		name: "filter",
+		w:    httptest.NewRecorder(),
+		url: basePath + "/resources/import?path=prod/*&name_prefix=prod-" +
+			"&labels=env=prod,team=a",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusNoContent,
+	}, {
+		name:   "invalid labels",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources/import?labels=env",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusBadRequest,
+	}, {
+		name:   "invalid path",
+		w:      httptest.NewRecorder(),
+		url:    basePath + "/resources/import?path=%5B",
+		header: map[string]string{"Authorization": "admin"},
+		code:   http.StatusBadRequest,
 	}}
 
 	for _, tt := range tests {
@@ -731,3 +1600,45 @@ func TestPostImportResource(t *testing.T) {
 		})
 	}
 }
+
+func TestGetImportErrors(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	svr.SetResourceService(&mockResourceService{})
+
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet,
+		basePath+"/resources/import/errors", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Authorization", "admin")
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	exp := `"path":"resources/test.yaml"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/go-chi/chi/v5"
+)
+
+// schemaEntities contains the entities for which JSON Schemas are served,
+// keyed by entity name.
+var schemaEntities = map[string]any{
+	"account":  auth.Account{},
+	"agent":    resource.Agent{},
+	"resource": resource.Resource{},
+	"token":    auth.Token{},
+	"user":     auth.User{},
+}
+
+// SchemaHandler performs routing for JSON Schema requests.
+func (s *Server) SchemaHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.With(s.Stat, s.Trace).Get("/", s.GetSchemas)
+	r.With(s.Stat, s.Trace).Get("/{entity}", s.GetSchema)
+
+	return r
+}
+
+// schemaOperations documents the JSON Schema routes.
+var schemaOperations = map[string]*Operation{
+	"GET /schemas": {
+		ID:      "get_schemas",
+		Tag:     "schemas",
+		Summary: "List JSON Schemas",
+		Description: "Lists the names of the entities for which JSON Schemas " +
+			"describing their wire formats are available.",
+		Public: true,
+		Responses: map[int]string{
+			200: "schemas",
+			500: "error",
+		},
+	},
+	"GET /schemas/{entity}": {
+		ID:      "get_schema",
+		Tag:     "schemas",
+		Summary: "Get JSON Schema",
+		Description: "Retrieves the JSON Schema describing the wire format " +
+			"of an entity, which can be used to validate client models.",
+		Public: true,
+		Params: []*Parameter{
+			{
+				Name:        "entity",
+				In:          "path",
+				Type:        "string",
+				Enum:        []string{"account", "resource", "token", "user"},
+				Required:    true,
+				Description: "The name of the entity.",
+			},
+		},
+		Responses: map[int]string{
+			200: "schema",
+			500: "error",
+		},
+	},
+}
+
+// schemaID returns the canonical URI identifying the JSON Schema of an
+// entity.
+func (s *Server) schemaID(entity string) string {
+	return "https://" + s.cfg.ServerHost() + s.cfg.ServerPathPrefix() +
+		"/schemas/" + entity
+}
+
+// GetSchemas is the get handler function listing the entities with JSON
+// Schemas.
+func (s *Server) GetSchemas(w http.ResponseWriter, r *http.Request) {
+	res := make([]string, 0, len(schemaEntities))
+
+	for k := range schemaEntities {
+		res = append(res, k)
+	}
+
+	slices.Sort(res)
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// GetSchema is the get handler function for the JSON Schema of an entity.
+func (s *Server) GetSchema(w http.ResponseWriter, r *http.Request) {
+	entity := strings.TrimSuffix(chi.URLParam(r, "entity"), ".json")
+
+	v, ok := schemaEntities[entity]
+	if !ok {
+		s.error(errors.New(errors.ErrNotFound,
+			"schema not found",
+			"entity", entity), w, r)
+
+		return
+	}
+
+	res := request.JSONSchema(s.schemaID(entity), entity, v)
+
+	w.Header().Set("Content-Type", "application/schema+json")
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.error(err, w, r)
+	}
+}
@@ -0,0 +1,86 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/server"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		w    *httptest.ResponseRecorder
+		url  string
+		code int
+		resp string
+	}{{
+		name: "list",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/schemas",
+		code: http.StatusOK,
+		resp: `["account","agent","resource","token","user"]`,
+	}, {
+		name: "resource",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/schemas/resource",
+		code: http.StatusOK,
+		resp: `"resource_id":{"type":["string","null"]}`,
+	}, {
+		name: "account",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/schemas/account.json",
+		code: http.StatusOK,
+		resp: `"$id":"https://apigo.io/api/v1/schemas/account"`,
+	}, {
+		name: "token",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/schemas/token",
+		code: http.StatusOK,
+		resp: `"access_token":{"type":"string"}`,
+	}, {
+		name: "user",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/schemas/user",
+		code: http.StatusOK,
+		resp: `"created_at":{"description":"A Unix timestamp, in seconds.",` +
+			`"type":["integer","null"]}`,
+	}, {
+		name: "not found",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/schemas/invalid",
+		code: http.StatusNotFound,
+		resp: `schema not found`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
@@ -2,33 +2,43 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"net"
 	"net/http"
 	"net/http/pprof"
+	"net/netip"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dhaifley/apigo/internal/auth"
 	"github.com/dhaifley/apigo/internal/cache"
 	"github.com/dhaifley/apigo/internal/config"
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/fixture"
 	"github.com/dhaifley/apigo/internal/logger"
 	"github.com/dhaifley/apigo/internal/metric"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/resource"
+	"github.com/dhaifley/apigo/internal/search"
 	"github.com/dhaifley/apigo/internal/sqldb"
-	"github.com/dhaifley/apigo/internal/static"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // The server version.
@@ -41,8 +51,12 @@ type Server struct {
 	health             uint32
 	addr               []string
 	cancels            []context.CancelFunc
+	drains             []drainFunc
+	inFlight           atomic.Int64
+	tls                atomic.Pointer[tls.Config]
 	cfg                *config.Config
 	log                logger.Logger
+	access             *logger.AccessLog
 	metric             metric.Recorder
 	tracer             trace.Tracer
 	r                  chi.Router
@@ -52,6 +66,13 @@ type Server struct {
 	authOnce           sync.Once
 	getAuthService     func(r *http.Request) AuthService
 	getResourceService func(r *http.Request) ResourceService
+	gqlOnce            sync.Once
+	gqlSchema          *graphql.Schema
+	gqlErr             error
+	openAPIJSON        []byte
+	openAPIYAML        []byte
+	undocumented       []string
+	logins             loginLimiter
 }
 
 // NewServer creates a new HTTP server.
@@ -88,6 +109,13 @@ func NewServer(cfg *config.Config,
 		metric: metric,
 	}
 
+	access, err := newAccessLog(s.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.access = access
+
 	s.Server.IdleTimeout = 30 * time.Second
 	s.Server.ReadHeaderTimeout = 30 * time.Second
 
@@ -96,6 +124,10 @@ func NewServer(cfg *config.Config,
 		s.Server.ReadHeaderTimeout = s.cfg.ServerIdleTimeout()
 	}
 
+	s.Server.ReadTimeout = s.cfg.ServerReadTimeout()
+	s.Server.WriteTimeout = s.cfg.ServerWriteTimeout()
+	s.Server.MaxHeaderBytes = s.cfg.ServerMaxHeaderBytes()
+
 	if len(s.cfg.CacheServers()) > 0 {
 		s.cache = cache.NewClient(s.cfg, s.log, s.metric, s.tracer)
 
@@ -116,7 +148,43 @@ func NewServer(cfg *config.Config,
 
 	s.initRouter()
 
-	s.Server.Handler = s.r
+	if err := s.initOpenAPI(); err != nil {
+		return nil, err
+	}
+
+	if dir := s.cfg.ServerReplayDir(); dir != "" {
+		rp, err := fixture.NewReplayer(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		s.log.Log(context.Background(), logger.LvlInfo,
+			"replaying recorded fixtures",
+			"dir", dir,
+			"fixtures", rp.Len())
+
+		r := chi.NewRouter()
+
+		r.Handle("/*", rp)
+
+		s.r = r
+	}
+
+	h2s := &http2.Server{
+		MaxConcurrentStreams: s.cfg.ServerMaxStreams(),
+		IdleTimeout:          s.Server.IdleTimeout,
+	}
+
+	if err := http2.ConfigureServer(&s.Server, h2s); err != nil {
+		return nil, errors.Wrap(err, errors.ErrServer,
+			"unable to configure HTTP/2 server")
+	}
+
+	s.Server.Handler = s.track(s.r)
+
+	if s.cfg.ServerH2C() {
+		s.Server.Handler = h2c.NewHandler(s.Server.Handler, h2s)
+	}
 
 	return s, nil
 }
@@ -146,7 +214,9 @@ func (s *Server) addCancelFunc(cf context.CancelFunc) {
 	s.cancels = append(s.cancels, cf)
 }
 
-// Cache gets the server cache for a specific request.
+// Cache gets the server cache for a specific request. The keys of the values
+// cached for the account of a request are namespaced, so that they can be
+// flushed when the account changes.
 func (s *Server) Cache(r *http.Request) cache.Accessor {
 	s.RLock()
 	defer s.RUnlock()
@@ -156,7 +226,7 @@ func (s *Server) Cache(r *http.Request) cache.Accessor {
 	}
 
 	if r == nil {
-		return s.cache
+		return cache.NewNamespace(s.cache)
 	}
 
 	if v := r.URL.Query().Get("no_cache"); v != "" && v != "0" &&
@@ -169,7 +239,7 @@ func (s *Server) Cache(r *http.Request) cache.Accessor {
 		return nil
 	}
 
-	return s.cache
+	return cache.NewNamespace(s.cache)
 }
 
 // DB gets the database connection pool for the server.
@@ -242,16 +312,25 @@ func (s *Server) ConnectSQL() {
 					continue
 				}
 
+				// Account isolation relies on row level security, so the
+				// database is not used unless it is enforced.
+				if err := sc.CheckRowSecurity(ctx); err != nil {
+					s.log.Log(ctx, logger.LvlError,
+						"unable to verify SQL database row level security",
+						"error", err)
+
+					sc.Close()
+
+					continue
+				}
+
 				s.Lock()
 
 				s.db = sc
 
 				s.Unlock()
 
-				ctx = context.WithValue(ctx, request.CtxKeyScopes,
-					request.ScopeSuperuser)
-				ctx = context.WithValue(ctx, request.CtxKeyAccountID,
-					request.SystemAccount)
+				ctx = request.Elevate(ctx, request.SystemAccount)
 
 				aSvc := s.getAuthService(nil)
 
@@ -307,6 +386,10 @@ func (s *Server) initRouter() {
 		s.logger,
 	)
 
+	if dir := s.cfg.ServerRecordDir(); dir != "" {
+		r.Use(fixture.NewRecorder(dir, s.log).Handler)
+	}
+
 	r.NotFound(s.notFound)
 	r.MethodNotAllowed(s.methodNotAllowed)
 
@@ -325,9 +408,20 @@ func (s *Server) initRouter() {
 	r.Mount("/healthz", s.HealthHandler())
 	r.Mount("/health", s.HealthHandler())
 	r.Mount("/account", s.AccountHandler())
+	r.Mount("/accounts", s.AccountsHandler())
+	r.Mount("/changes", s.ChangeHandler())
 	r.Mount("/user", s.UserHandler())
+	r.Mount("/groups", s.GroupHandler())
+	r.Mount("/tokens", s.TokenHandler())
 	r.Mount("/login", s.LoginHandler())
+	r.Mount("/auth", s.SessionHandler())
+	r.With(s.dbAvail, s.Stat, s.Trace, s.Auth).Get("/resources:delta",
+		s.GetResourcesDelta)
 	r.Mount("/resources", s.ResourceHandler())
+	r.Mount("/agents", s.AgentHandler())
+	r.Mount("/alerts", s.AlertHandler())
+	r.Mount("/graphql", s.GraphQLHandler())
+	r.Mount("/schemas", s.SchemaHandler())
 
 	s.initStaticRoutes(r)
 
@@ -338,76 +432,47 @@ func (s *Server) initRouter() {
 	s.Unlock()
 }
 
-// initStaticRoutes initializes routing for embedded static resources.
-func (s *Server) initStaticRoutes(r chi.Router) {
-	r.Get("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
-		v, err := static.FS.ReadFile("openapi.json")
-		if err != nil {
-			s.error(err, w, r)
-
-			return
-		}
+// UpdateAuthConfig begins periodic recording of token and request usage, and
+// rotation of signing keys, and retrieves and begins periodic update of
+// authentication configuration data, if configured to do so.
+func (s *Server) UpdateAuthConfig() {
+	s.authOnce.Do(func() {
+		go func() {
+			for s.db == nil {
+				time.Sleep(100 * time.Millisecond)
+			}
 
-		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+			svc := s.getAuthService(nil)
 
-		if _, err := w.Write(v); err != nil {
-			s.error(err, w, r)
+			s.addCancelFunc(svc.UpdateTokenUsage(context.Background()))
 
-			return
-		}
-	})
+			s.addCancelFunc(svc.UpdateRequestUsage(context.Background()))
 
-	r.Get("/openapi.yaml", func(w http.ResponseWriter, r *http.Request) {
-		v, err := static.FS.ReadFile("openapi.yaml")
-		if err != nil {
-			s.error(err, w, r)
+			s.addCancelFunc(svc.RotateSigningKeys(context.Background()))
 
-			return
-		}
-
-		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
-
-		if _, err := w.Write(v); err != nil {
-			s.error(err, w, r)
+			if s.cfg.AuthTokenWellKnown() == "" {
+				return
+			}
 
-			return
-		}
+			s.addCancelFunc(svc.Update(context.Background()))
+		}()
 	})
+}
 
-	r.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
-		v, err := static.FS.ReadFile("index.html")
-		if err != nil {
-			s.error(err, w, r)
-
-			return
-		}
-
-		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
-
-		if _, err := w.Write(v); err != nil {
-			s.error(err, w, r)
+// ListenCache begins receiving the cache keys deleted by other service
+// instances, so that they are dropped from the in-memory cache tier.
+func (s *Server) ListenCache() {
+	s.RLock()
 
-			return
-		}
-	})
-}
+	c, ok := s.cache.(*cache.Client)
 
-// UpdateAuthConfig retrieves and begins periodic update of authentication
-// configuration data, if configured to do so.
-func (s *Server) UpdateAuthConfig() {
-	s.authOnce.Do(func() {
-		go func() {
-			if s.cfg.AuthTokenWellKnown() == "" {
-				return
-			}
+	s.RUnlock()
 
-			for s.db == nil {
-				time.Sleep(100 * time.Millisecond)
-			}
+	if !ok || c == nil {
+		return
+	}
 
-			s.addCancelFunc(s.getAuthService(nil).Update(context.Background()))
-		}()
-	})
+	s.addCancelFunc(c.Listen(context.Background()))
 }
 
 // Serve listens for and processes HTTP requests.
@@ -428,6 +493,12 @@ func (s *Server) Serve() error {
 			"no servers configured")
 	}
 
+	if s.TLSEnabled() {
+		if err := s.initTLS(); err != nil {
+			return err
+		}
+	}
+
 	ech := make(chan error, len(addr))
 
 	var wg sync.WaitGroup
@@ -447,9 +518,16 @@ func (s *Server) Serve() error {
 			}
 
 			s.log.Log(ctx, logger.LvlInfo, "server listening",
-				"address", addr)
+				"address", addr,
+				"tls", s.TLSEnabled())
+
+			if s.TLSEnabled() {
+				err = s.Server.ServeTLS(lis, "", "")
+			} else {
+				err = s.Server.Serve(lis)
+			}
 
-			if err = s.Server.Serve(lis); err != nil {
+			if err != nil {
 				if err != http.ErrServerClosed {
 					ech <- errors.Wrap(err, errors.ErrServer,
 						"server error")
@@ -509,37 +587,38 @@ func (s *Server) Close() {
 	if s.db != nil {
 		s.db.Close()
 	}
+
+	if s.access != nil {
+		if err := s.access.Close(); err != nil {
+			s.log.Log(ctx, logger.LvlError, "error closing access log",
+				"error", err)
+		}
+	}
 }
 
-// Shutdown releases all server resources gracefully.
+// Shutdown releases all server resources gracefully. New connections are
+// refused while in-flight requests and queued background work are drained, up
+// to the configured drain timeout.
 func (s *Server) Shutdown(ctx context.Context) {
 	s.Lock()
 
-	s.log.Log(ctx, logger.LvlInfo, "server shutting down")
+	s.log.Log(ctx, logger.LvlInfo, "server shutting down",
+		"in_flight", s.InFlight(),
+		"drain_timeout", s.cfg.ServerDrainTimeout().String())
 
 	s.health = http.StatusServiceUnavailable
 
 	s.Unlock()
 
-	s.RLock()
-
-	defer s.RUnlock()
-
-	ctx, cancel := context.WithTimeout(ctx, s.cfg.ServerTimeout())
+	dctx, cancel := context.WithTimeout(ctx, s.cfg.ServerDrainTimeout())
 
 	defer cancel()
 
-	if err := s.Server.Shutdown(ctx); err != nil {
-		s.log.Log(ctx, logger.LvlError, "error during server shutdown",
-			"error", err)
+	s.drain(dctx)
 
-		if err := s.Server.Close(); err != nil {
-			s.log.Log(ctx, logger.LvlError, "error during server close",
-				"error", err)
-		}
+	s.RLock()
 
-		return
-	}
+	defer s.RUnlock()
 
 	for _, canc := range s.cancels {
 		if canc != nil {
@@ -550,6 +629,13 @@ func (s *Server) Shutdown(ctx context.Context) {
 	if s.db != nil {
 		s.db.Close()
 	}
+
+	if s.access != nil {
+		if err := s.access.Close(); err != nil {
+			s.log.Log(ctx, logger.LvlError, "error closing access log",
+				"error", err)
+		}
+	}
 }
 
 // context wraps request handlers to setup the request context.
@@ -567,6 +653,8 @@ func (s *Server) context(next http.Handler) http.Handler {
 		ctx = context.WithValue(ctx, request.CtxKeyService,
 			s.cfg.ServiceName())
 
+		ctx = cache.WithMemo(ctx)
+
 		if tID, err := request.ContextTraceID(ctx); err != nil || tID == "" {
 			if tu, err := uuid.NewRandom(); err != nil {
 				s.log.Log(ctx, logger.LvlError,
@@ -576,12 +664,27 @@ func (s *Server) context(next http.Handler) http.Handler {
 			} else {
 				tID = tu.String()
 
-				ctx = context.WithValue(ctx, request.CtxKeyTraceID, tID)
+				ctx = request.WithTraceID(ctx, tID)
 			}
 		}
 
 		if aID := r.Header.Get("X-Account-ID"); aID != "" {
-			ctx = context.WithValue(ctx, request.CtxKeyAccountID, aID)
+			ctx = request.WithAccountID(ctx, aID)
+		}
+
+		if cID := clientID(r); cID != "" {
+			ctx = context.WithValue(ctx, request.CtxKeyClientID, cID)
+		}
+
+		if tz := r.Header.Get(request.HeaderTimeZone); tz != "" {
+			loc, err := request.ParseTimeZone(tz)
+			if err != nil {
+				s.error(err, w, r.WithContext(ctx))
+
+				return
+			}
+
+			ctx = context.WithValue(ctx, request.CtxKeyTimeZone, loc)
 		}
 
 		if r.Body != nil {
@@ -608,7 +711,8 @@ func (s *Server) header(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Allow-Headers",
 				"Origin, X-Requested-With, X-HTTP-Method-Override, "+
-					"Content-Type, Accept, Referer, User-Agent")
+					"Content-Type, Accept, Referer, User-Agent, "+
+					csrfHeader+", "+request.HeaderTimeZone)
 			w.Header().Set("Access-Control-Allow-Methods",
 				"GET, PUT, POST, OPTIONS")
 		}
@@ -620,8 +724,8 @@ func (s *Server) header(next http.Handler) http.Handler {
 
 		w.Header().Set("X-Server", host)
 		w.Header().Set("X-Version", Version)
-		w.Header().Set("Vary", "Accept-Encoding, Origin")
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Vary", "Accept, Accept-Encoding, Origin")
+		w.Header().Set("Content-Type", contentType(r))
 
 		if s.cfg.ServiceMaintenance() {
 			s.error(errors.New(errors.ErrMaintenance,
@@ -648,10 +752,7 @@ func (s *Server) logger(next http.Handler) http.Handler {
 
 		r.Header.Set("X-Status-Code", "200")
 
-		remote := r.RemoteAddr
-		if r.Header.Get("X-Forwarded-For") != "" {
-			remote = r.Header.Get("X-Forwarded-For")
-		}
+		remote := s.clientAddress(r)
 
 		ctx := context.WithValue(r.Context(), request.CtxKeyRemote, remote)
 
@@ -664,7 +765,9 @@ func (s *Server) logger(next http.Handler) http.Handler {
 
 		s.log.Log(ctx, logger.LvlDebug, "request received", logData...)
 
-		next.ServeHTTP(w, r.WithContext(ctx))
+		aw := &accessWriter{ResponseWriter: w}
+
+		next.ServeHTTP(aw, r.WithContext(ctx))
 
 		sc, err := strconv.ParseInt(r.Header.Get("X-Status-Code"),
 			10, 64)
@@ -696,9 +799,110 @@ func (s *Server) logger(next http.Handler) http.Handler {
 		}
 
 		s.log.Log(ctx, lvl, "request processed", logData...)
+
+		if s.access != nil {
+			s.writeAccess(ctx, aw, r, start, remote)
+		}
 	})
 }
 
+// clientAddress returns the address of the client making a request, without
+// any port. This is the address of the peer, unless it is a trusted proxy, in
+// which case it is the rightmost address in the X-Forwarded-For header which is
+// not also a trusted proxy. The entries to the left of it are supplied by the
+// client, so they are never used, and the header is ignored when no proxies are
+// trusted.
+func (s *Server) clientAddress(r *http.Request) string {
+	remote := r.RemoteAddr
+
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	trusted := make([]netip.Prefix, 0, len(s.cfg.ServerTrustedProxies()))
+
+	for _, n := range s.cfg.ServerTrustedProxies() {
+		if p, err := netip.ParsePrefix(n); err == nil {
+			trusted = append(trusted, p.Masked())
+		}
+	}
+
+	isTrusted := func(addr string) bool {
+		a, err := netip.ParseAddr(addr)
+		if err != nil {
+			return false
+		}
+
+		return slices.ContainsFunc(trusted, func(p netip.Prefix) bool {
+			return p.Contains(a.Unmap())
+		})
+	}
+
+	if !isTrusted(remote) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"),
+		","), ",")
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+
+		remote = hop
+
+		if !isTrusted(hop) {
+			break
+		}
+	}
+
+	return remote
+}
+
+// writeAccess writes the access log entry for a processed request.
+func (s *Server) writeAccess(ctx context.Context,
+	w *accessWriter,
+	r *http.Request,
+	start time.Time,
+	remote string,
+) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	e := &logger.AccessEntry{
+		Time:      start,
+		Method:    r.Method,
+		URI:       r.RequestURI,
+		Path:      r.URL.Path,
+		Route:     chi.RouteContext(ctx).RoutePattern(),
+		Protocol:  r.Proto,
+		Status:    status,
+		Bytes:     w.bytes,
+		Latency:   time.Since(start),
+		Remote:    remote,
+		UserAgent: r.UserAgent(),
+		Referer:   r.Referer(),
+	}
+
+	// The trace context is returned to the caller in the response headers.
+	tc := request.Propagator.Extract(ctx,
+		propagation.HeaderCarrier(w.Header()))
+
+	if sc := trace.SpanContextFromContext(tc); sc.IsValid() {
+		e.TraceID = sc.TraceID().String()
+	}
+
+	if err := s.access.Write(e); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to write access log entry",
+			"error", err)
+	}
+}
+
 // dbAvail wraps request handlers with a check to ensure the database is up.
 func (s *Server) dbAvail(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -729,15 +933,24 @@ func (s *Server) error(err error, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Indicate to clients whether the request may be attempted again.
+	e.Retryable = errors.IsRetryable(e)
+
 	// Store the status code in context
 	r.Header.Set("X-Status-Code", strconv.FormatInt(int64(e.Code.Status), 10))
 
 	// Send information to the user if the service is under maintenance.
 	if e.Code.Name == "Maintenance" {
+		status := "The service is currently undergoing maintenance"
+
+		if _, ok := e.Data["account_id"]; ok {
+			status = "The account is currently undergoing maintenance"
+		}
+
 		w.WriteHeader(e.Code.Status)
 
-		if err := json.NewEncoder(w).Encode(map[string]string{
-			"status": "The service is currently undergoing maintenance",
+		if err := encodeResponse(w, r, map[string]string{
+			"status": status,
 		}); err != nil {
 			s.log.Log(ctx, logger.LvlError,
 				"unable to encode error into JSON",
@@ -806,13 +1019,203 @@ func (s *Server) error(err error, w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(e.Code.Status)
 
-	if err := json.NewEncoder(w).Encode(e); err != nil {
+	if err := encodeResponse(w, r, e); err != nil {
 		s.log.Log(ctx, logger.LvlError,
 			"unable to encode error into JSON",
 			"error", err)
 	}
 }
 
+// multiStatus responds to the current batch request with a standard batch
+// response, reporting the result of each item. Failed items are logged, and
+// the response status is 207 Multi-Status when the item results differ.
+func (s *Server) multiStatus(res *request.MultiStatus,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	ctx := r.Context()
+
+	for _, item := range res.Items {
+		if item.Error == nil {
+			continue
+		}
+
+		lvl := logger.LvlError
+		if item.Status < http.StatusInternalServerError {
+			lvl = logger.LvlWarn
+		}
+
+		s.log.Log(ctx, lvl, item.Error.Msg,
+			"error", item.Error,
+			"index", item.Index,
+			"id", item.ID,
+			"kind", r.Method,
+			"uri", r.RequestURI)
+	}
+
+	status := res.Status()
+
+	r.Header.Set("X-Status-Code", strconv.FormatInt(int64(status), 10))
+
+	w.WriteHeader(status)
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.log.Log(ctx, logger.LvlError,
+			"unable to encode batch response into JSON",
+			"error", err)
+	}
+}
+
+// parseQuery parses the search query of a request, rejecting queries which
+// exceed the configured search complexity limits.
+func (s *Server) parseQuery(r *http.Request) (*search.Query, error) {
+	q, err := search.ParseQuery(r.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkQuery(q); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// checkQuery returns an invalid request error if a search query exceeds the
+// configured search complexity limits.
+func (s *Server) checkQuery(q *search.Query) error {
+	return q.Check(&search.Limits{
+		MaxDepth:        s.cfg.ServerSearchDepth(),
+		MaxTerms:        s.cfg.ServerSearchTerms(),
+		DenyLeadingWild: s.cfg.ServerSearchWildcard(),
+	})
+}
+
+// querySize returns the number of results requested by a search query, or the
+// configured default size if the query does not specify one.
+func (s *Server) querySize(q *search.Query) int64 {
+	if q != nil && q.Size > 0 {
+		return q.Size
+	}
+
+	return s.cfg.DBDefaultSize()
+}
+
+// page trims search results, which include one more item than the requested
+// size when more results are available, to the requested size, reporting
+// whether there are more results.
+func page[T any](list []T, size int64) ([]T, bool) {
+	if size <= 0 || int64(len(list)) <= size {
+		return list, false
+	}
+
+	return list[:size], true
+}
+
+// selectFields returns a value, or slice of values, containing only the fields
+// selected by the options, and the ID field. If the options do not restrict the
+// fields selected, the value is returned unchanged.
+func selectFields(v any,
+	options sqldb.FieldOptions,
+	idField string,
+) (any, error) {
+	fields := options.Fields()
+
+	if len(fields) == 0 {
+		return v, nil
+	}
+
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+
+	dec.UseNumber()
+
+	var res any
+
+	if err := dec.Decode(&res); err != nil {
+		return nil, err
+	}
+
+	keep := func(m map[string]any) {
+		for k := range m {
+			if k != idField && !slices.Contains(fields, k) {
+				delete(m, k)
+			}
+		}
+	}
+
+	switch rv := res.(type) {
+	case map[string]any:
+		keep(rv)
+	case []any:
+		for _, item := range rv {
+			if m, ok := item.(map[string]any); ok {
+				keep(m)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// encodeFields responds to the current request with the JSON, or YAML,
+// encoding of a value, or slice of values. If the options restrict the fields
+// selected, only those fields, and the ID field, are included in the response.
+// The response is encoded in canonical form, so that its ETag is stable.
+func (s *Server) encodeFields(v any,
+	options sqldb.FieldOptions,
+	idField string,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	v, err := selectFields(v, options, idField)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	buf, err := request.CanonicalJSON(v)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	yml := request.AcceptsYAML(r.Header.Get("Accept"))
+
+	if yml {
+		if buf, err = request.YAMLFromJSON(buf); err != nil {
+			s.error(err, w, r)
+
+			return
+		}
+	}
+
+	etag := contentETag(buf)
+
+	w.Header().Set("ETag", etag)
+
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	if !yml {
+		buf = append(buf, '\n')
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		s.error(err, w, r)
+	}
+}
+
 // noContent is the handler function for empty responses.
 func (s *Server) noContent(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Del("Content-Type")
@@ -3,9 +3,12 @@ package server_test
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -14,6 +17,7 @@ import (
 	"github.com/dhaifley/apigo/internal/logger"
 	"github.com/dhaifley/apigo/internal/server"
 	"github.com/dhaifley/apigo/internal/sqldb"
+	"golang.org/x/net/http2"
 )
 
 const (
@@ -48,6 +52,246 @@ func TestShutdown(t *testing.T) {
 	svr.Shutdown(context.Background())
 }
 
+func TestShutdownDrain(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	cfg.SetServer(&config.ServerConfig{
+		PathPrefix:   basePath,
+		DrainTimeout: time.Second * 5,
+	})
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectPing().WillDelayFor(time.Millisecond * 200)
+
+	svr.SetDB(md)
+
+	drained := false
+
+	svr.AddDrainFunc("test", func(ctx context.Context) error {
+		drained = svr.InFlight() == 0
+
+		return nil
+	})
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = svr.Server.Serve(lis)
+	}()
+
+	url := "http://" + lis.Addr().String() + basePath + "/health/ready"
+
+	ch := make(chan int, 1)
+
+	go func() {
+		res, err := http.Get(url)
+		if err != nil {
+			ch <- 0
+
+			return
+		}
+
+		_ = res.Body.Close()
+
+		ch <- res.StatusCode
+	}()
+
+	for svr.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	svr.Shutdown(context.Background())
+
+	if code := <-ch; code != http.StatusOK {
+		t.Errorf("Expected in-flight request code: %v, got: %v",
+			http.StatusOK, code)
+	}
+
+	if !drained {
+		t.Error("Expected drain function called after in-flight requests")
+	}
+
+	if _, err := http.Get(url); err == nil {
+		t.Error("Expected new connections refused after shutdown")
+	}
+}
+
+func TestServerTuning(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	cfg.SetServer(&config.ServerConfig{
+		PathPrefix:     basePath,
+		ReadTimeout:    time.Second * 20,
+		WriteTimeout:   time.Second * 40,
+		MaxHeaderBytes: 4096,
+		H2C:            true,
+	})
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if svr.Server.ReadTimeout != time.Second*20 {
+		t.Errorf("Expected read timeout: 20s, got: %v", svr.Server.ReadTimeout)
+	}
+
+	if svr.Server.WriteTimeout != time.Second*40 {
+		t.Errorf("Expected write timeout: 40s, got: %v",
+			svr.Server.WriteTimeout)
+	}
+
+	if svr.Server.MaxHeaderBytes != 4096 {
+		t.Errorf("Expected max header bytes: 4096, got: %v",
+			svr.Server.MaxHeaderBytes)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = svr.Server.Serve(lis)
+	}()
+
+	t.Cleanup(func() {
+		svr.Shutdown(context.Background())
+	})
+
+	c := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context,
+				network, addr string,
+				_ *tls.Config,
+			) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	res, err := c.Get("http://" + lis.Addr().String() + basePath +
+		"/health/live")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := res.Body.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ProtoMajor != 2 {
+		t.Errorf("Expected protocol: HTTP/2, got: %v", res.Proto)
+	}
+}
+
+func TestTimeZoneHeader(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		timeZone string
+		code     int
+	}{{
+		name:     "valid",
+		timeZone: "America/New_York",
+		code:     http.StatusOK,
+	}, {
+		name:     "invalid",
+		timeZone: "Mars/Olympus_Mons",
+		code:     http.StatusBadRequest,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, basePath+"/schemas", nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			r.Header.Set("Time-Zone", tt.timeZone)
+
+			svr.Mux(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "access.log")
+
+	cfg := config.NewDefault()
+
+	cfg.SetLog(&config.LogConfig{
+		Access:       config.LogAccessFile,
+		AccessFile:   file,
+		AccessFields: "method,path,route,status,trace_id",
+	})
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, basePath+"/schemas/invalid", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("traceparent",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+
+	svr.Mux(w, r)
+
+	svr.Close()
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `{"method":"GET","path":"` + basePath + `/schemas/invalid",` +
+		`"route":"` + basePath + `/schemas/{entity}","status":404,` +
+		`"trace_id":"0af7651916cd43dd8448eb211c80319c"}` + "\n"
+
+	if string(b) != exp {
+		t.Errorf("Expected access log: %v, got: %v", exp, string(b))
+	}
+}
+
 func TestServe(t *testing.T) {
 	t.Parallel()
 
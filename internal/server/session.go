@@ -0,0 +1,198 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/go-chi/chi/v5"
+)
+
+// Session cookie and header names.
+const (
+	sessionCookie = "session"
+	csrfCookie    = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+)
+
+// SessionHandler performs routing for browser session and local
+// authentication requests.
+func (s *Server) SessionHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.LoginLimit).Post("/session", s.PostSession)
+	r.With(s.Stat, s.Trace, s.Auth).Post("/logout", s.PostLogout)
+	r.With(s.Stat, s.Trace, s.LocalAuth, s.LoginLimit).
+		Post("/login", s.PostLoginToken)
+	r.With(s.Stat, s.Trace, s.LocalAuth, s.LoginLimit, s.Auth).
+		Post("/password", s.PostPassword)
+
+	return r
+}
+
+// sessionOperations documents the browser session routes.
+var sessionOperations = map[string]*Operation{
+	"POST /auth/session": {
+		ID:      "create_session",
+		Tag:     "user",
+		Summary: "Create session",
+		Description: "Authenticates a user with a password, and creates a " +
+			"browser session, as an alternative to holding an access token " +
+			"in the browser. The session is held in an HttpOnly session " +
+			"cookie, and requests using it which modify data must contain " +
+			"the returned CSRF token, which is also set in the csrf_token " +
+			"cookie, in the X-CSRF-Token header.",
+		Public:   true,
+		Body:     "token_request",
+		BodyType: "application/x-www-form-urlencoded",
+		Responses: map[int]string{
+			201: "session",
+			400: "user_error",
+			401: "user_error",
+			429: "user_error",
+			500: "error",
+		},
+	},
+	"POST /auth/logout": {
+		ID:      "delete_session",
+		Tag:     "user",
+		Summary: "Delete session",
+		Description: "Ends the browser session used to authenticate the " +
+			"request, and clears the session cookies.",
+		Responses: map[int]string{
+			204: "No response body.",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// setSessionCookies sets the cookies of a browser session, which expire with
+// the session. Empty values clear the cookies.
+func (s *Server) setSessionCookies(w http.ResponseWriter,
+	id, csrf string,
+	expiresAt int64,
+) {
+	p := s.cfg.ServerPathPrefix()
+	if p == "" {
+		p = "/"
+	}
+
+	maxAge := int(time.Until(time.Unix(expiresAt, 0)).Seconds())
+	if id == "" || maxAge <= 0 {
+		maxAge = -1
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    id,
+		Path:     p,
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    csrf,
+		Path:     p,
+		MaxAge:   maxAge,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// PostSession is the post handler for password authentication to create a
+// browser session.
+func (s *Server) PostSession(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	tenant := r.Header.Get("securitytenant")
+
+	req, err := tokenRequest(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := svc.AuthPassword(ctx, req.Username, req.Password,
+		tenant); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.CreateSession(ctx, req.Username, req.Scope, tenant)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	s.setSessionCookies(w, res.SessionID, res.CSRFToken, res.ExpiresAt)
+
+	w.WriteHeader(http.StatusCreated)
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// PostLogout is the post handler used to end a browser session.
+func (s *Server) PostLogout(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if c, err := r.Cookie(sessionCookie); err == nil && c.Value != "" {
+		if err := svc.DeleteSession(ctx, c.Value); err != nil {
+			s.error(err, w, r)
+
+			return
+		}
+	}
+
+	s.setSessionCookies(w, "", "", 0)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CSRF wraps an http handler with verification of the CSRF token of requests
+// authenticated using a session cookie. Such requests must contain the CSRF
+// token of the session in the X-CSRF-Token header, unless they use a method
+// which does not modify data.
+func (s *Server) CSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := request.ContextCSRFToken(r.Context())
+		if err != nil || token == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)),
+			[]byte(token)) != 1 {
+			s.error(errors.New(errors.ErrForbidden,
+				"invalid CSRF token"), w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
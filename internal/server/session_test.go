@@ -0,0 +1,185 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestSession = auth.Session{
+	SessionID: "session",
+	UserID:    TestUser.UserID.Value,
+	Token:     "test",
+	CSRFToken: "csrf",
+	ExpiresAt: 4102444800,
+}
+
+func (m *mockAuthService) CreateSession(ctx context.Context,
+	userID, scopes, tenant string,
+) (*auth.Session, error) {
+	return &TestSession, nil
+}
+
+func (m *mockAuthService) AuthSession(ctx context.Context,
+	id string,
+) (*auth.Session, error) {
+	if id != TestSession.SessionID {
+		return nil, errors.New(errors.ErrUnauthorized, "invalid session")
+	}
+
+	return &TestSession, nil
+}
+
+func (m *mockAuthService) DeleteSession(ctx context.Context,
+	id string,
+) error {
+	return nil
+}
+
+func TestPostSession(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	r, err := http.NewRequest(http.MethodPost, basePath+"/auth/session",
+		strings.NewReader(url.Values{
+			"username": {TestUser.UserID.Value},
+			"password": {"test"},
+		}.Encode()))
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("Code expected: %v, got: %v", http.StatusCreated, w.Code)
+	}
+
+	exp := `"csrf_token":"csrf"`
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	cookies := w.Result().Cookies()
+
+	if len(cookies) != 2 || cookies[0].Value != TestSession.SessionID ||
+		!cookies[0].HttpOnly || !cookies[0].Secure ||
+		cookies[0].SameSite != http.SameSiteStrictMode {
+		t.Errorf("Expected session cookie, got: %v", cookies)
+	}
+}
+
+func TestSessionCSRF(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name    string
+		w       *httptest.ResponseRecorder
+		method  string
+		path    string
+		session string
+		header  map[string]string
+		code    int
+	}{{
+		name:    "read",
+		w:       httptest.NewRecorder(),
+		method:  http.MethodGet,
+		path:    "/user",
+		session: TestSession.SessionID,
+		code:    http.StatusOK,
+	}, {
+		name:    "missing csrf",
+		w:       httptest.NewRecorder(),
+		method:  http.MethodPost,
+		path:    "/auth/logout",
+		session: TestSession.SessionID,
+		code:    http.StatusForbidden,
+	}, {
+		name:    "logout",
+		w:       httptest.NewRecorder(),
+		method:  http.MethodPost,
+		path:    "/auth/logout",
+		session: TestSession.SessionID,
+		header:  map[string]string{"X-CSRF-Token": "csrf"},
+		code:    http.StatusNoContent,
+	}, {
+		name:   "bearer token",
+		w:      httptest.NewRecorder(),
+		method: http.MethodPost,
+		path:   "/auth/logout",
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusNoContent,
+	}, {
+		name:    "invalid session",
+		w:       httptest.NewRecorder(),
+		method:  http.MethodGet,
+		path:    "/user",
+		session: "invalid",
+		code:    http.StatusUnauthorized,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(tt.method, basePath+tt.path, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			if tt.session != "" {
+				r.AddCookie(&http.Cookie{Name: "session", Value: tt.session})
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+		})
+	}
+}
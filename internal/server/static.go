@@ -0,0 +1,329 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/static"
+	"github.com/go-chi/chi/v5"
+)
+
+// initStaticRoutes initializes routing for embedded static resources.
+func (s *Server) initStaticRoutes(r chi.Router) {
+	r.With(s.cdnSigned).Get("/openapi.json", s.openAPIFile("json"))
+	r.With(s.cdnSigned).Get("/openapi.yaml", s.openAPIFile("yaml"))
+
+	r.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
+		s.renderPage("index.html", &pageData{
+			Branding: s.branding(r),
+			SpecURL:  s.staticURL("openapi.json"),
+		}, w, r)
+	})
+
+	r.Get("/status", func(w http.ResponseWriter, r *http.Request) {
+		h := s.Health()
+
+		s.renderPage("status.html", &pageData{
+			Branding: s.branding(r),
+			Service:  s.cfg.ServiceName(),
+			Version:  Version,
+			Status:   http.StatusText(int(h)),
+			OK:       h == http.StatusOK,
+		}, w, r)
+	})
+
+	if !s.cfg.ServerNoUI() {
+		r.Get("/ui", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+		})
+
+		r.Get("/ui/*", s.uiFile)
+	}
+}
+
+// pages contains the templates for the embedded HTML pages.
+var pages = template.Must(template.ParseFS(static.FS, "*.html"))
+
+const (
+	// uiDir is the directory containing the embedded admin UI files.
+	uiDir = "ui"
+
+	// uiIndex is the index page of the embedded admin UI, which is served for
+	// every path of the UI which is not a file, so that the UI can route them
+	// on the client.
+	uiIndex = "index.html"
+
+	// uiCSP is the content security policy of the embedded admin UI, which
+	// only loads scripts and styles from, and connects to, the service.
+	uiCSP = "default-src 'self'; img-src 'self' data:; " +
+		"frame-ancestors 'none'; base-uri 'none'; form-action 'self'"
+
+	// uiImmutable is the Cache-Control header value of admin UI files
+	// requested for the current version of the service, which never change.
+	uiImmutable = "public, max-age=31536000, immutable"
+)
+
+// uiPage contains the template for the index page of the embedded admin UI.
+var uiPage = template.Must(template.ParseFS(static.FS, uiDir+"/"+uiIndex))
+
+// pageData values contain the data used to render embedded HTML pages.
+type pageData struct {
+	Branding *auth.Branding
+	SpecURL  string
+	Service  string
+	Version  string
+	Status   string
+	OK       bool
+	BasePath string
+	APIPath  string
+}
+
+// uiFile responds to the current request with a file of the embedded admin
+// UI. The index page is served for any path without a file extension, and must
+// be revalidated before use. Other files are referenced by the index page with
+// the service version, and may be cached indefinitely when requested for it.
+func (s *Server) uiFile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Security-Policy", uiCSP)
+
+	name := chi.URLParam(r, "*")
+
+	if name == "" || name == uiIndex || path.Ext(name) == "" {
+		buf := &bytes.Buffer{}
+
+		if err := uiPage.Execute(buf, &pageData{
+			Version:  Version,
+			BasePath: s.cfg.ServerPathPrefix() + "/" + uiDir,
+			APIPath:  s.cfg.ServerPathPrefix(),
+		}); err != nil {
+			s.error(errors.Wrap(err, errors.ErrServer,
+				"unable to render page",
+				"page", uiIndex), w, r)
+
+			return
+		}
+
+		s.writeStatic(buf.Bytes(), "text/html; charset=UTF-8", "no-cache",
+			w, r)
+
+		return
+	}
+
+	b, err := fs.ReadFile(static.FS, uiDir+"/"+name)
+	if err != nil {
+		s.notFound(w, r)
+
+		return
+	}
+
+	ct := mime.TypeByExtension(path.Ext(name))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+
+	cc := s.staticCacheControl()
+	if v := r.URL.Query().Get("v"); v != "" && v == Version {
+		cc = uiImmutable
+	}
+
+	s.writeStatic(b, ct, cc, w, r)
+}
+
+// branding retrieves the branding customizations for the account specified
+// by the account query parameter. If no account is specified, or the account
+// cannot be retrieved, no customizations are applied. Pages are served without
+// authentication, so the account is retrieved using a system context scoped to
+// the requested account.
+func (s *Server) branding(r *http.Request) *auth.Branding {
+	id := r.URL.Query().Get("account")
+	if id == "" || !request.ValidAccountID(id) || s.DB() == nil {
+		return &auth.Branding{}
+	}
+
+	ctx := r.Context()
+
+	aCtx := request.Elevate(ctx, id)
+
+	a, err := s.getAuthService(r).GetAccount(aCtx, id)
+	if err != nil {
+		if !errors.Has(err, errors.ErrNotFound) {
+			s.log.Log(ctx, logger.LvlError,
+				"unable to retrieve account branding",
+				"error", err,
+				"account_id", id)
+		}
+
+		return &auth.Branding{}
+	}
+
+	return a.Branding()
+}
+
+// renderPage responds to the current request with an embedded HTML page.
+// Rendered pages may be cached, but must be revalidated before use.
+func (s *Server) renderPage(name string,
+	data *pageData,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	buf := &bytes.Buffer{}
+
+	if err := pages.ExecuteTemplate(buf, name, data); err != nil {
+		s.error(errors.Wrap(err, errors.ErrServer,
+			"unable to render page",
+			"page", name), w, r)
+
+		return
+	}
+
+	s.writeStatic(buf.Bytes(), "text/html; charset=UTF-8", "no-cache", w, r)
+}
+
+// staticCacheControl returns the Cache-Control header value for static files,
+// which may be cached by clients for the configured static max age.
+func (s *Server) staticCacheControl() string {
+	if ma := s.cfg.ServerStaticMaxAge(); ma > 0 {
+		return "public, max-age=" + strconv.FormatInt(int64(ma.Seconds()), 10)
+	}
+
+	return "no-cache"
+}
+
+// writeStatic responds to the current request with static content, setting
+// the ETag and Cache-Control headers. If the request contains a matching
+// If-None-Match header, a 304 Not Modified response is sent without content.
+func (s *Server) writeStatic(v []byte,
+	contentType, cacheControl string,
+	w http.ResponseWriter,
+	r *http.Request,
+) {
+	etag := contentETag(v)
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+
+	if _, err := w.Write(v); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// contentETag returns a strong entity tag for content, computed from a hash of
+// the content.
+func contentETag(v []byte) string {
+	sum := sha256.Sum256(v)
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatch returns whether an If-None-Match header value matches an ETag,
+// using weak comparison.
+func etagMatch(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staticURL returns the URL of an embedded static file. If a CDN is
+// configured, the URL refers to the CDN, and is signed if a CDN key is
+// configured.
+func (s *Server) staticURL(name string) string {
+	base := s.cfg.ServerCDNURL()
+	if base == "" {
+		return s.cfg.ServerPathPrefix() + "/" + name
+	}
+
+	u := strings.TrimSuffix(base, "/") + "/" + name
+
+	key := s.cfg.ServerCDNKey()
+	if key == "" {
+		return u
+	}
+
+	// Expiration is rounded to the TTL so that URLs, and the responses cached
+	// for them, are shared by all requests within the same period.
+	ttl := s.cfg.ServerCDNTTL()
+
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+
+	exp := time.Now().Truncate(ttl).Add(ttl * 2).Unix()
+
+	q := url.Values{}
+
+	q.Set("expires", strconv.FormatInt(exp, 10))
+	q.Set("signature", cdnSignature(key, name, exp))
+
+	return u + "?" + q.Encode()
+}
+
+// cdnSignature returns the signature of a static file URL.
+func cdnSignature(key, name string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(key))
+
+	mac.Write([]byte(name + "\n" + strconv.FormatInt(expires, 10)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// cdnSigned wraps a static file handler with verification of any signed CDN
+// URL parameters. Requests without a signature are not rejected, so that
+// static files remain available directly from the service.
+func (s *Server) cdnSigned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := s.cfg.ServerCDNKey()
+
+		q := r.URL.Query()
+
+		sig := q.Get("signature")
+
+		if key == "" || sig == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		exp, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+		if err != nil || time.Now().Unix() > exp ||
+			!hmac.Equal([]byte(sig), []byte(cdnSignature(key, name, exp))) {
+			s.error(errors.New(errors.ErrForbidden,
+				"invalid or expired signature",
+				"path", r.URL.Path), w, r)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,433 @@
+package server_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestPages(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name  string
+		w     *httptest.ResponseRecorder
+		url   string
+		code  int
+		resp  []string
+		nresp []string
+	}{{
+		name:  "docs",
+		w:     httptest.NewRecorder(),
+		url:   basePath + "/docs",
+		code:  http.StatusOK,
+		resp:  []string{"<title>API Documentation</title>", "swagger-ui"},
+		nresp: []string{"Test Product", "mailto:"},
+	}, {
+		name: "docs branded",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/docs?account=" + TestID,
+		code: http.StatusOK,
+		resp: []string{
+			"<title>Test Product API Documentation</title>",
+			`src="https://apigo.io/logo.png"`,
+			`href="mailto:test@apigo.io"`,
+		},
+	}, {
+		name:  "status",
+		w:     httptest.NewRecorder(),
+		url:   basePath + "/status",
+		code:  http.StatusOK,
+		resp:  []string{"<title>Service Status</title>", "operational"},
+		nresp: []string{"Test Product"},
+	}, {
+		name: "status branded",
+		w:    httptest.NewRecorder(),
+		url:  basePath + "/status?account=" + TestID,
+		code: http.StatusOK,
+		resp: []string{
+			"<title>Test Product Service Status</title>",
+			"<h1>Test Product Status</h1>",
+			`href="mailto:test@apigo.io"`,
+		},
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+
+			for _, v := range tt.resp {
+				if !strings.Contains(res, v) {
+					t.Errorf("Expected body to contain: %v, got: %v", v, res)
+				}
+			}
+
+			for _, v := range tt.nresp {
+				if strings.Contains(res, v) {
+					t.Errorf("Expected body not to contain: %v, got: %v",
+						v, res)
+				}
+			}
+		})
+	}
+}
+
+func TestPagesBrandingAccount(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(auth.NewService(nil, md, nil, nil, nil, nil))
+
+	mock.ExpectBegin()
+
+	// The unauthenticated request must be scoped to the requested account for
+	// the account row to be visible through row level security.
+	mock.ExpectExec(regexp.QuoteMeta(
+		"SELECT set_config('app.account_id', $1, true)")).
+		WithArgs(TestID).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectQuery("SELECT (.+) FROM account").
+		WithArgs(TestID).
+		WillReturnRows(mock.NewRows([]string{
+			"account_id",
+			"name",
+			"status",
+			"status_data",
+			"repo",
+			"repo_status",
+			"repo_status_data",
+			"secret",
+			"data",
+			"created_at",
+			"updated_at",
+		}).AddRow(
+			TestAccount.AccountID.Value,
+			TestAccount.Name.Value,
+			TestAccount.Status.Value,
+			TestAccount.StatusData.Value,
+			TestAccount.Repo.Value,
+			TestAccount.RepoStatus.Value,
+			TestAccount.RepoStatusData.Value,
+			TestAccount.Secret.Value,
+			TestAccount.Data.Value,
+			TestAccount.CreatedAt.Value,
+			TestAccount.UpdatedAt.Value,
+		))
+
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet,
+		basePath+"/docs?account="+TestID, nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	svr.Mux(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+	}
+
+	exp := "<title>Test Product API Documentation</title>"
+
+	if res := w.Body.String(); !strings.Contains(res, exp) {
+		t.Errorf("Expected body to contain: %v, got: %v", exp, res)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestStaticCache(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/openapi.json", "/openapi.yaml", "/docs"} {
+		t.Run(path, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, basePath+path, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			svr.Mux(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Code expected: %v, got: %v", http.StatusOK, w.Code)
+			}
+
+			etag := w.Header().Get("ETag")
+			if etag == "" {
+				t.Fatal("Expected ETag header")
+			}
+
+			exp := "public, max-age=3600"
+			if path == "/docs" {
+				exp = "no-cache"
+			}
+
+			if cc := w.Header().Get("Cache-Control"); cc != exp {
+				t.Errorf("Expected Cache-Control: %v, got: %v", exp, cc)
+			}
+
+			w = httptest.NewRecorder()
+
+			r.Header.Set("If-None-Match", `"other", W/`+etag)
+
+			svr.Mux(w, r)
+
+			if w.Code != http.StatusNotModified {
+				t.Errorf("Code expected: %v, got: %v",
+					http.StatusNotModified, w.Code)
+			}
+
+			if w.Body.Len() != 0 {
+				t.Errorf("Expected empty body, got: %v", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestStaticCDN(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+
+	sCfg := &config.ServerConfig{
+		CDNURL: "https://cdn.apigo.io/api/v1",
+		CDNKey: "test",
+	}
+
+	sCfg.Load()
+
+	cfg.SetServer(sCfg)
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+
+	r, err := http.NewRequest(http.MethodGet, basePath+"/docs", nil)
+	if err != nil {
+		t.Fatal("Failed to initialize request", err)
+	}
+
+	svr.Mux(w, r)
+
+	res := w.Body.String()
+
+	i := strings.Index(res, "https://cdn.apigo.io/api/v1/openapi.json?")
+	if i < 0 {
+		t.Fatalf("Expected signed CDN URL, got: %v", res)
+	}
+
+	su := res[i:]
+	su = su[:strings.Index(su, `"`)]
+	su = strings.ReplaceAll(su, `\u0026`, "&")
+
+	u, err := url.Parse(su)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := u.Query().Get("expires"); exp == "" {
+		t.Fatalf("Expected expires parameter, got: %v", su)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		code  int
+	}{{
+		name:  "signed",
+		query: u.RawQuery,
+		code:  http.StatusOK,
+	}, {
+		name:  "unsigned",
+		query: "",
+		code:  http.StatusOK,
+	}, {
+		name: "invalid signature",
+		query: "expires=" + u.Query().Get("expires") +
+			"&signature=invalid",
+		code: http.StatusForbidden,
+	}, {
+		name: "expired",
+		query: "expires=" +
+			strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10) +
+			"&signature=" + u.Query().Get("signature"),
+		code: http.StatusForbidden,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet,
+				basePath+"/openapi.json?"+tt.query, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			svr.Mux(w, r)
+
+			if w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, w.Code)
+			}
+		})
+	}
+}
+
+func TestUI(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files are only cached indefinitely when requested for a release version.
+	versioned := "public, max-age=3600"
+	if server.Version != "" {
+		versioned = "public, max-age=31536000, immutable"
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		code int
+		ct   string
+		cc   string
+		resp string
+	}{{
+		name: "redirect",
+		url:  basePath + "/ui",
+		code: http.StatusMovedPermanently,
+	}, {
+		name: "index",
+		url:  basePath + "/ui/",
+		code: http.StatusOK,
+		ct:   "text/html; charset=UTF-8",
+		cc:   "no-cache",
+		resp: `src="` + basePath + `/ui/app.js?v=` + server.Version + `"`,
+	}, {
+		name: "index fallback",
+		url:  basePath + "/ui/resources/" + TestID,
+		code: http.StatusOK,
+		ct:   "text/html; charset=UTF-8",
+		cc:   "no-cache",
+		resp: `data-api="` + basePath + `"`,
+	}, {
+		name: "asset",
+		url:  basePath + "/ui/app.css",
+		code: http.StatusOK,
+		ct:   "text/css; charset=utf-8",
+		cc:   "public, max-age=3600",
+	}, {
+		name: "versioned asset",
+		url:  basePath + "/ui/app.js?v=" + server.Version,
+		code: http.StatusOK,
+		ct:   "text/javascript; charset=utf-8",
+		cc:   versioned,
+	}, {
+		name: "missing asset",
+		url:  basePath + "/ui/missing.js",
+		code: http.StatusNotFound,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := httptest.NewRecorder()
+
+			r, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			svr.Mux(w, r)
+
+			if w.Code != tt.code {
+				t.Fatalf("Code expected: %v, got: %v", tt.code, w.Code)
+			}
+
+			ct := w.Header().Get("Content-Type")
+			if tt.ct != "" && ct != tt.ct {
+				t.Errorf("Expected Content-Type: %v, got: %v", tt.ct, ct)
+			}
+
+			cc := w.Header().Get("Cache-Control")
+			if tt.cc != "" && cc != tt.cc {
+				t.Errorf("Expected Cache-Control: %v, got: %v", tt.cc, cc)
+			}
+
+			if tt.resp != "" && !strings.Contains(w.Body.String(), tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v",
+					tt.resp, w.Body.String())
+			}
+		})
+	}
+}
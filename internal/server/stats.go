@@ -2,15 +2,18 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/dhaifley/apigo/internal/cache"
 	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/httpclient"
 	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/repo"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -24,6 +27,8 @@ func (s *Server) HealthHandler() http.Handler {
 	r := chi.NewRouter()
 
 	r.With(s.Stat, s.Trace).Get("/", s.GetHealthCheck)
+	r.With(s.Stat, s.Trace).Get("/live", s.GetLiveness)
+	r.With(s.Stat, s.Trace).Get("/ready", s.GetReadiness)
 	r.With(s.Stat, s.Trace, s.Auth).Post("/", s.PutHealthCheck)
 	r.With(s.Stat, s.Trace, s.Auth).Patch("/", s.PutHealthCheck)
 	r.With(s.Stat, s.Trace, s.Auth).Put("/", s.PutHealthCheck)
@@ -31,13 +36,29 @@ func (s *Server) HealthHandler() http.Handler {
 	return r
 }
 
+// Health check dependency statuses.
+const (
+	HealthStatusOK          = "ok"
+	HealthStatusDegraded    = "degraded"
+	HealthStatusUnavailable = "unavailable"
+)
+
 // HealthCheck values represent return information from health checks.
 type HealthCheck struct {
-	Service   string `json:"service,omitempty"`
-	Version   string `json:"version,omitempty"`
-	CommitID  string `json:"commit_id,omitempty"`
-	BuildTime string `json:"build_time,omitempty"`
-	Health    uint32 `json:"health,omitempty"`
+	Service   string                       `json:"service,omitempty"    yaml:"service,omitempty"`
+	Version   string                       `json:"version,omitempty"    yaml:"version,omitempty"`
+	CommitID  string                       `json:"commit_id,omitempty"  yaml:"commit_id,omitempty"`
+	BuildTime string                       `json:"build_time,omitempty" yaml:"build_time,omitempty"`
+	Health    uint32                       `json:"health,omitempty"     yaml:"health,omitempty"`
+	Checks    map[string]*HealthDependency `json:"checks,omitempty"     yaml:"checks,omitempty"`
+}
+
+// HealthDependency values represent the result of checking the connectivity
+// of a dependency of the service.
+type HealthDependency struct {
+	Status  string  `json:"status"          yaml:"status"`
+	Latency float64 `json:"latency_ms"      yaml:"latency_ms"`
+	Error   string  `json:"error,omitempty" yaml:"error,omitempty"`
 }
 
 // GetHealthCheck is the handler function for the health check path.
@@ -50,11 +71,168 @@ func (s *Server) GetHealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(int(res.Health))
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
 
+// GetLiveness is the handler function for the liveness check path. It
+// succeeds whenever the server is able to respond to requests.
+func (s *Server) GetLiveness(w http.ResponseWriter, r *http.Request) {
+	res := &HealthCheck{
+		Service: s.cfg.ServiceName(),
+		Health:  http.StatusOK,
+		Version: Version,
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// GetReadiness is the handler function for the readiness check path. It
+// succeeds only if the server health is OK, and the database, cache and
+// repository dependencies, where configured, are reachable. A degraded cache,
+// which is not used until it recovers, does not prevent readiness.
+func (s *Server) GetReadiness(w http.ResponseWriter, r *http.Request) {
+	res := &HealthCheck{
+		Service: s.cfg.ServiceName(),
+		Health:  s.Health(),
+		Version: Version,
+		Checks:  s.checkDependencies(r.Context()),
+	}
+
+	for _, c := range res.Checks {
+		if c.Status == HealthStatusUnavailable {
+			res.Health = http.StatusServiceUnavailable
+		}
+	}
+
+	w.WriteHeader(int(res.Health))
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
+
+// checkDependencies concurrently checks the connectivity of each configured
+// dependency of the server, each within the health check timeout.
+func (s *Server) checkDependencies(ctx context.Context,
+) map[string]*HealthDependency {
+	checks := map[string]func(ctx context.Context) error{
+		"database": func(ctx context.Context) error {
+			db := s.DB()
+			if db == nil {
+				return errors.New(errors.ErrUnavailable,
+					"database not connected")
+			}
+
+			return db.Ping(ctx)
+		},
+	}
+
+	if len(s.cfg.CacheServers()) > 0 {
+		checks["cache"] = func(ctx context.Context) error {
+			c := s.Cache(nil)
+			if c == nil {
+				return errors.New(errors.ErrUnavailable,
+					"cache not connected")
+			}
+
+			if cc, ok := c.(*cache.Client); ok {
+				if degraded, until := cc.Degraded(); degraded {
+					return &degradedError{until: until}
+				}
+			}
+
+			// A cache miss still indicates the cache is reachable.
+			if _, err := c.Get(ctx, "health"); err != nil &&
+				!errors.Has(err, errors.ErrNotFound) {
+				return err
+			}
+
+			return nil
+		}
+	}
+
+	if u := s.cfg.ServerHealthRepo(); u != "" {
+		checks["repo"] = func(ctx context.Context) error {
+			rc, err := repo.NewClient(u, httpclient.NewClient(s.cfg, s.log,
+				s.metric, s.tracer), s.metric, s.tracer)
+			if err != nil {
+				return err
+			}
+
+			return rc.Ping(ctx)
+		}
+	}
+
+	res := make(map[string]*HealthDependency, len(checks))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, s.cfg.ServerHealthTimeout())
+
+			defer cancel()
+
+			start := time.Now()
+
+			err := check(ctx)
+
+			d := &HealthDependency{
+				Status:  HealthStatusOK,
+				Latency: float64(time.Since(start).Microseconds()) / 1000,
+			}
+
+			var de *degradedError
+
+			switch {
+			case errors.As(err, &de):
+				d.Status = HealthStatusDegraded
+				d.Error = err.Error()
+			case err != nil:
+				d.Status = HealthStatusUnavailable
+				d.Error = err.Error()
+
+				s.log.Log(ctx, logger.LvlWarn,
+					"health check dependency unavailable",
+					"dependency", name,
+					"error", err)
+			}
+
+			mu.Lock()
+
+			res[name] = d
+
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return res
+}
+
+// degradedError values are returned by dependency checks for dependencies
+// which are unreachable, but which the server is able to operate without.
+type degradedError struct {
+	until time.Time
+}
+
+// Error returns the error message of a degraded dependency.
+func (e *degradedError) Error() string {
+	return "degraded, retrying after " + e.until.UTC().Format(time.RFC3339)
+}
+
 // PutHealthCheck is the handler function for setting the server health code.
 func (s *Server) PutHealthCheck(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -67,14 +245,8 @@ func (s *Server) PutHealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	req := &HealthCheck{}
 
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
-		switch e := err.(type) {
-		case *errors.Error:
-			s.error(e, w, r)
-		default:
-			s.error(errors.Wrap(err, errors.ErrInvalidRequest,
-				"unable to decode request"), w, r)
-		}
+	if err := decodeRequest(r, req); err != nil {
+		s.error(err, w, r)
 
 		return
 	}
@@ -88,7 +260,7 @@ func (s *Server) PutHealthCheck(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(int(res.Health))
 
-	if err := json.NewEncoder(w).Encode(res); err != nil {
+	if err := encodeResponse(w, r, res); err != nil {
 		s.error(err, w, r)
 	}
 }
@@ -124,8 +296,9 @@ func (s *Server) UpdateMetrics(ctx context.Context,
 				s.metric.Set(ctx, "alloc", int64(ms.Alloc))
 				s.metric.Set(ctx, "total_alloc", int64(ms.TotalAlloc))
 				s.metric.Set(ctx, "goroutines", int64(runtime.NumGoroutine()))
+				s.metric.Set(ctx, "requests_in_flight", s.InFlight())
 
-				if s.db != nil {
+				if s.db != nil && s.db.Stat() != nil {
 					dbStat := s.db.Stat()
 
 					s.metric.Set(ctx, "db_open", int64(dbStat.TotalConns()))
@@ -148,11 +321,10 @@ func (s *Server) Trace(next http.Handler) http.Handler {
 
 		tID := ""
 
-		if s.tracer != nil {
-			tc := propagation.TraceContext{}
-
-			ctx = tc.Extract(ctx, propagation.HeaderCarrier(r.Header))
+		ctx = request.Propagator.Extract(ctx,
+			propagation.HeaderCarrier(r.Header))
 
+		if s.tracer != nil {
 			peer := r.RemoteAddr
 
 			remote := r.Header.Get("X-Forwarded-For")
@@ -168,7 +340,9 @@ func (s *Server) Trace(next http.Handler) http.Handler {
 				scheme = "http"
 			}
 
-			ctx, span := s.tracer.Start(ctx, r.Method+" "+route,
+			var span trace.Span
+
+			ctx, span = s.tracer.Start(ctx, r.Method+" "+route,
 				trace.WithSpanKind(trace.SpanKindServer),
 				trace.WithAttributes(
 					attribute.String("http.route", route),
@@ -191,11 +365,19 @@ func (s *Server) Trace(next http.Handler) http.Handler {
 			}()
 
 			// Ensure the request and context contains tracing information.
-			tc.Inject(ctx, propagation.HeaderCarrier(r.Header))
+			request.Propagator.Inject(ctx, propagation.HeaderCarrier(r.Header))
 
 			tID = span.SpanContext().TraceID().String()
 		}
 
+		// Without a tracer, the trace of the caller, if any, is continued.
+		if sc := trace.SpanContextFromContext(ctx); tID == "" && sc.IsValid() {
+			tID = sc.TraceID().String()
+		}
+
+		// Return the trace context to the caller.
+		request.Propagator.Inject(ctx, propagation.HeaderCarrier(w.Header()))
+
 		if tID == "" {
 			if t, err := request.ContextTraceID(ctx); err == nil && t != "" {
 				tID = t
@@ -211,7 +393,7 @@ func (s *Server) Trace(next http.Handler) http.Handler {
 			}
 		}
 
-		ctx = context.WithValue(ctx, request.CtxKeyTraceID, tID)
+		ctx = request.WithTraceID(ctx, tID)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
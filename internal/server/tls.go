@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+// TLSEnabled returns whether the server is configured to serve requests using
+// TLS.
+func (s *Server) TLSEnabled() bool {
+	return s.cfg.ServerCert() != "" && s.cfg.ServerKey() != ""
+}
+
+// loadTLSConfig loads the TLS certificate, private key and client certificate
+// authorities configured for the server.
+func (s *Server) loadTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(s.cfg.ServerCert(), s.cfg.ServerKey())
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrConfiguration,
+			"unable to load server TLS certificate",
+			"cert", s.cfg.ServerCert(),
+			"key", s.cfg.ServerKey())
+	}
+
+	tc := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if ca := s.cfg.ServerClientCA(); ca != "" {
+		b, err := os.ReadFile(ca)
+		if err != nil {
+			return nil, errors.Wrap(err, errors.ErrConfiguration,
+				"unable to read server client certificate authorities",
+				"client_ca", ca)
+		}
+
+		pool := x509.NewCertPool()
+
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.New(errors.ErrConfiguration,
+				"invalid server client certificate authorities",
+				"client_ca", ca)
+		}
+
+		tc.ClientCAs = pool
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+
+		if s.cfg.ServerClientAuth() == config.ServerClientAuthOptional {
+			tc.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return tc, nil
+}
+
+// initTLS loads the TLS configuration of the server, which is selected for
+// each new connection, so that it can be replaced by ReloadTLS.
+func (s *Server) initTLS() error {
+	tc, err := s.loadTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	s.tls.Store(tc)
+
+	s.Server.TLSConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return &s.tls.Load().Certificates[0], nil
+		},
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return s.tls.Load(), nil
+		},
+	}
+
+	return nil
+}
+
+// ReloadTLS reloads the TLS certificate, private key and client certificate
+// authorities from the configured files. Connections established after the
+// reload use the new configuration. If the files cannot be loaded, the current
+// configuration remains in use.
+func (s *Server) ReloadTLS() error {
+	if !s.TLSEnabled() || s.tls.Load() == nil {
+		return nil
+	}
+
+	tc, err := s.loadTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	s.tls.Store(tc)
+
+	s.log.Log(context.Background(), logger.LvlInfo, "server TLS reloaded",
+		"cert", s.cfg.ServerCert())
+
+	return nil
+}
+
+// clientID returns the identity of a client authenticated using a verified TLS
+// client certificate, or an empty string if there is none.
+func clientID(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 ||
+		len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+
+	return cert.Subject.String()
+}
@@ -0,0 +1,233 @@
+package server_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/server"
+)
+
+// testCert creates a certificate with the given common name, signed by the
+// parent certificate, or self-signed if parent is nil.
+func testCert(t *testing.T,
+	name string,
+	parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey,
+) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{
+			x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth,
+		},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent,
+		&key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+// writeTestCert writes a certificate and its private key to PEM files.
+func writeTestCert(t *testing.T,
+	cert *x509.Certificate,
+	key *ecdsa.PrivateKey,
+	certFile, keyFile string,
+) {
+	t.Helper()
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: cert.Raw,
+	}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if keyFile == "" {
+		return
+	}
+
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{
+		Type: "EC PRIVATE KEY", Bytes: b,
+	}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	ca, caKey := testCert(t, "ca", nil, nil)
+
+	writeTestCert(t, ca, caKey, caFile, "")
+
+	cert, key := testCert(t, "server", ca, caKey)
+
+	writeTestCert(t, cert, key, certFile, keyFile)
+
+	client, clientKey := testCert(t, "client", ca, caKey)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := lis.Addr().String()
+
+	if err := lis.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.NewDefault()
+
+	cfg.SetServer(&config.ServerConfig{
+		Address:    addr,
+		Cert:       certFile,
+		Key:        keyFile,
+		ClientCA:   caFile,
+		PathPrefix: basePath,
+	})
+
+	svr, err := server.NewServer(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = svr.Serve()
+	}()
+
+	t.Cleanup(func() {
+		svr.Shutdown(context.Background())
+	})
+
+	pool := x509.NewCertPool()
+
+	pool.AddCert(ca)
+
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		c := &http.Client{
+			Timeout: time.Second * 5,
+			Transport: &http.Transport{
+				DisableKeepAlives: true,
+				TLSClientConfig: &tls.Config{
+					RootCAs:      pool,
+					Certificates: certs,
+				},
+			},
+		}
+
+		res, err := c.Get("https://" + addr + basePath + "/health/live")
+		if err != nil {
+			return nil, err
+		}
+
+		return res, res.Body.Close()
+	}
+
+	clientCert := tls.Certificate{
+		Certificate: [][]byte{client.Raw},
+		PrivateKey:  clientKey,
+	}
+
+	for i := 0; ; i++ {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			_ = conn.Close()
+
+			break
+		} else if i > 100 {
+			t.Fatal(err)
+		}
+
+		time.Sleep(time.Millisecond * 10)
+	}
+
+	if _, err := get(); err == nil {
+		t.Error("Expected error without client certificate")
+	}
+
+	res, err := get(clientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Code expected: %v, got: %v", http.StatusOK, res.StatusCode)
+	}
+
+	if cn := res.TLS.PeerCertificates[0].Subject.CommonName; cn != "server" {
+		t.Errorf("Expected server certificate: server, got: %v", cn)
+	}
+
+	cert, key = testCert(t, "reloaded", ca, caKey)
+
+	writeTestCert(t, cert, key, certFile, keyFile)
+
+	if err := svr.ReloadTLS(); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = get(clientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cn := res.TLS.PeerCertificates[0].Subject.CommonName; cn != "reloaded" {
+		t.Errorf("Expected server certificate: reloaded, got: %v", cn)
+	}
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/go-chi/chi/v5"
+)
+
+// TokenHandler performs routing for issued token requests.
+func (s *Server) TokenHandler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Use(s.dbAvail)
+
+	r.With(s.Stat, s.Trace, s.Auth).Get("/", s.SearchToken)
+
+	return r
+}
+
+// tokenOperations documents the issued token routes.
+var tokenOperations = map[string]*Operation{
+	"GET /tokens": {
+		ID:      "search_tokens",
+		Tag:     "tokens",
+		Summary: "Search tokens",
+		Description: "Retrieves the tokens issued for the account based on " +
+			"a search query, including when and how often each has been " +
+			"used. Users holding the user:admin scope retrieve the tokens " +
+			"of every user, and other users only their own. Uses are " +
+			"recorded periodically, so recent uses of a token may not be " +
+			"reported immediately.",
+		Scopes: []string{"user:read"},
+		Params: []*Parameter{
+			{Name: "search"},
+			{Name: "size"},
+			{Name: "skip"},
+			{Name: "sort"},
+			{Name: "case_insensitive"},
+			{Name: "envelope"},
+			{Name: "fields"},
+		},
+		Responses: map[int]string{
+			200: "issued_tokens",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// SearchToken is the search handler function for issued tokens.
+func (s *Server) SearchToken(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeUserRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	q, err := s.parseQuery(r)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	opts, err := sqldb.ParseFieldOptions(r.URL.Query())
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetTokens(ctx, q, opts)
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, more := page(res, s.querySize(q))
+
+	w.Header().Set("X-Has-More", strconv.FormatBool(more))
+
+	s.encodeList(s.newEnvelope(r, q, res, more), opts, "token_id", w, r)
+}
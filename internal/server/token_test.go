@@ -0,0 +1,116 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/search"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestIssuedToken = auth.IssuedToken{
+	TokenID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestUUID,
+	},
+	UserID: request.FieldString{
+		Set: true, Valid: true,
+		Value: TestUser.UserID.Value,
+	},
+	Scopes: request.FieldString{
+		Set: true, Valid: true,
+		Value: request.ScopeUserRead,
+	},
+	LastUsedAt: request.FieldTime{
+		Set: true, Valid: true,
+		Value: 1704067200,
+	},
+	UseCount: request.FieldInt64{
+		Set: true, Valid: true,
+		Value: 3,
+	},
+}
+
+func (m *mockAuthService) GetTokens(ctx context.Context,
+	query *search.Query,
+	options sqldb.FieldOptions,
+) ([]*auth.IssuedToken, error) {
+	return []*auth.IssuedToken{&TestIssuedToken}, nil
+}
+
+func (m *mockAuthService) UpdateTokenUsage(ctx context.Context,
+) context.CancelFunc {
+	_, cancel := context.WithCancel(ctx)
+
+	return cancel
+}
+
+func TestTokens(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		header map[string]string
+		code   int
+		resp   string
+	}{{
+		name:   "search",
+		w:      httptest.NewRecorder(),
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"use_count":3`,
+	}, {
+		name: "unauthorized",
+		w:    httptest.NewRecorder(),
+		code: http.StatusForbidden,
+		resp: `"Forbidden"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet, basePath+"/tokens",
+				nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
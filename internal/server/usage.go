@@ -0,0 +1,131 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/go-chi/chi/v5"
+)
+
+// usageOperations documents the account usage routes.
+var usageOperations = map[string]*Operation{
+	"GET /account/usage": {
+		ID:      "get_account_usage",
+		Tag:     "account",
+		Summary: "Get account usage",
+		Description: "Retrieves the number of requests made by the account " +
+			"to each operation on each UTC day of a range of days, and the " +
+			"request body bytes sent with them. Requests made with the " +
+			"same Idempotency-Key header value are counted once. Requests " +
+			"are recorded periodically, so recent requests handled by " +
+			"other service instances may not be reported immediately. The " +
+			"response also contains the size of the resource data stored " +
+			"by the account, and its usage of its daily and monthly " +
+			"quotas. Requests made once a quota is used receive a 429 " +
+			"response until the quota resets.",
+		Scopes: []string{"account:read"},
+		Params: []*Parameter{
+			{
+				Name: "from",
+				In:   "query",
+				Type: "string",
+				Description: "The first day, formatted as YYYY-MM-DD, for " +
+					"which requests are retrieved. If omitted, requests " +
+					"are retrieved for the 30 days before the last day.",
+			},
+			{
+				Name: "to",
+				In:   "query",
+				Type: "string",
+				Description: "The last day, formatted as YYYY-MM-DD, for " +
+					"which requests are retrieved. If omitted, requests " +
+					"are retrieved up to the current day.",
+			},
+		},
+		Responses: map[int]string{
+			200: "request_usage",
+			400: "user_error",
+			500: "error",
+		},
+	},
+}
+
+// countReader values count the bytes read from a request body.
+type countReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read reads from the request body, counting the bytes read.
+func (c *countReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+
+	c.n += int64(n)
+
+	return n, err
+}
+
+// setRetryAfter sets the Retry-After header of a response to an error which
+// specifies how many seconds the client must wait before trying again, such as
+// when it is locked out or has used a quota.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	e, ok := err.(*errors.Error)
+	if !ok {
+		return
+	}
+
+	if v, ok := e.Data["retry_after"].(int64); ok {
+		w.Header().Set("Retry-After", strconv.FormatInt(v, 10))
+	}
+}
+
+// requestOperation returns the method and route pattern of a request,
+// relative to the path prefix, in the same format as the keys of the
+// operations.
+func (s *Server) requestOperation(r *http.Request) string {
+	route := r.URL.Path
+
+	if rc := chi.RouteContext(r.Context()); rc != nil {
+		if p := rc.RoutePattern(); p != "" {
+			route = p
+		}
+	}
+
+	route = strings.TrimPrefix(route,
+		strings.TrimSuffix(s.cfg.ServerPathPrefix(), "/"))
+
+	if len(route) > 1 {
+		route = strings.TrimSuffix(route, "/")
+	}
+
+	return r.Method + " " + route
+}
+
+// GetAccountUsage is the get handler function for account usage.
+func (s *Server) GetAccountUsage(w http.ResponseWriter, r *http.Request) {
+	svc := s.getAuthService(r)
+
+	ctx := r.Context()
+
+	if err := s.checkScope(ctx, request.ScopeAccountRead); err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	res, err := svc.GetRequestUsage(ctx, r.URL.Query().Get("from"),
+		r.URL.Query().Get("to"))
+	if err != nil {
+		s.error(err, w, r)
+
+		return
+	}
+
+	if err := encodeResponse(w, r, res); err != nil {
+		s.error(err, w, r)
+	}
+}
@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/auth"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/dhaifley/apigo/internal/server"
+	"github.com/dhaifley/apigo/internal/sqldb"
+)
+
+var TestRequestUsage = auth.RequestUsage{
+	From:     "2024-01-01",
+	To:       "2024-01-31",
+	Requests: 3,
+	Counts: []*auth.RequestCount{{
+		Day:       "2024-01-01",
+		Operation: "GET /resources",
+		Requests:  3,
+	}},
+}
+
+func (m *mockAuthService) RecordRequest(ctx context.Context,
+	operation, key string,
+	bytes int64,
+) {
+}
+
+func (m *mockAuthService) CheckQuota(ctx context.Context) error {
+	if id, _ := request.ContextAccountID(ctx); id == "exhausted" {
+		return errors.New(errors.ErrorRateLimit,
+			"account quota exceeded: daily_requests",
+			"retry_after", int64(3600))
+	}
+
+	return nil
+}
+
+func (m *mockAuthService) GetRequestUsage(ctx context.Context,
+	from, to string,
+) (*auth.RequestUsage, error) {
+	return &TestRequestUsage, nil
+}
+
+func (m *mockAuthService) UpdateRequestUsage(ctx context.Context,
+) context.CancelFunc {
+	_, cancel := context.WithCancel(ctx)
+
+	return cancel
+}
+
+func TestAccountUsage(t *testing.T) {
+	t.Parallel()
+
+	svr, err := server.NewServer(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	md, _, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svr.SetDB(md)
+
+	svr.SetAuthService(&mockAuthService{})
+
+	tests := []struct {
+		name       string
+		w          *httptest.ResponseRecorder
+		header     map[string]string
+		code       int
+		resp       string
+		retryAfter string
+	}{{
+		name:   "get",
+		w:      httptest.NewRecorder(),
+		header: map[string]string{"Authorization": "test"},
+		code:   http.StatusOK,
+		resp:   `"operation":"GET /resources"`,
+	}, {
+		name:       "quota exceeded",
+		w:          httptest.NewRecorder(),
+		header:     map[string]string{"Authorization": "exhausted"},
+		code:       http.StatusTooManyRequests,
+		resp:       `daily_requests`,
+		retryAfter: "3600",
+	}, {
+		name: "unauthorized",
+		w:    httptest.NewRecorder(),
+		code: http.StatusForbidden,
+		resp: `"Forbidden"`,
+	}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := http.NewRequest(http.MethodGet,
+				basePath+"/account/usage", nil)
+			if err != nil {
+				t.Fatal("Failed to initialize request", err)
+			}
+
+			for th, tv := range tt.header {
+				r.Header.Set(th, tv)
+			}
+
+			svr.Mux(tt.w, r)
+
+			if tt.w.Code != tt.code {
+				t.Errorf("Code expected: %v, got: %v", tt.code, tt.w.Code)
+			}
+
+			if v := tt.w.Header().Get("Retry-After"); v != tt.retryAfter {
+				t.Errorf("Expected retry after header: %v, got: %v",
+					tt.retryAfter, v)
+			}
+
+			res := tt.w.Body.String()
+			if !strings.Contains(res, tt.resp) {
+				t.Errorf("Expected body to contain: %v, got: %v", tt.resp, res)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package sqldb
 import (
 	"encoding/json"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/search"
 )
@@ -38,17 +40,36 @@ const (
 	OpAny   = FieldOperator("ANY")
 	OpLike  = FieldOperator("LIKE")
 	OpRE    = FieldOperator("~")
+	OpIRE   = FieldOperator("~*")
 )
 
 // FieldOption type values are used to specify options for field selection for
 // queries.
 type FieldOption string
 
-// Supported field selection query options.
+// Supported field selection query options. The options which may be requested
+// for a table are those required by its fields, so an option is only valid
+// for tables which have related objects of that kind.
 const (
-	OptUserDetails = FieldOption("user_details")
+	OptUserDetails   = FieldOption("user_details")
+	OptCreatedByUser = FieldOption("created_by_user")
+	OptUpdatedByUser = FieldOption("updated_by_user")
+	OptTags          = FieldOption("tags")
+	OptGrants        = FieldOption("grants")
+	OptRevisions     = FieldOption("revisions")
+	OptComputed      = FieldOption("computed")
 )
 
+// fieldSelectPrefix prefixes options restricting the fields selected.
+const fieldSelectPrefix = "field:"
+
+// FieldSelect returns an option restricting the fields selected by a query to
+// those specified by options of this type. The ID field of the queried table
+// is always selected.
+func FieldSelect(name string) FieldOption {
+	return FieldOption(fieldSelectPrefix + name)
+}
+
 // FieldOptions represent a collection of query options for field selection.
 type FieldOptions []FieldOption
 
@@ -67,7 +88,88 @@ func (fo *FieldOptions) Contains(option FieldOption) bool {
 	return false
 }
 
-// ParseFieldOptions parses options from query string values.
+// Fields returns the names of the fields selected by the collection. If no
+// fields are specified, nil is returned and all fields are selected.
+func (fo *FieldOptions) Fields() []string {
+	if fo == nil {
+		return nil
+	}
+
+	var r []string
+
+	for _, v := range *fo {
+		if name, ok := strings.CutPrefix(string(v), fieldSelectPrefix); ok {
+			r = append(r, name)
+		}
+	}
+
+	return r
+}
+
+// Selects returns whether a field is selected by the collection for a query
+// of the specified table. If fields are specified, only those fields, and the
+// ID field of the table, are selected. Otherwise, fields requiring an option
+// are selected only if the collection contains that option.
+func (fo *FieldOptions) Selects(table string, f *Field) bool {
+	if fields := fo.Fields(); len(fields) > 0 {
+		if f.Table == table && f.Name == strings.Trim(table, `"`)+"_id" {
+			return true
+		}
+
+		return slices.Contains(fields, f.Name)
+	}
+
+	if f.Option != "" {
+		return fo.Contains(f.Option)
+	}
+
+	return true
+}
+
+// ValidateFields checks that any fields selected by the collection are
+// available for selection from the specified fields, and that any related
+// objects requested are available for inclusion by those fields.
+func (fo *FieldOptions) ValidateFields(fields []*Field) error {
+	if fo != nil {
+		for _, o := range *fo {
+			if strings.HasPrefix(string(o), fieldSelectPrefix) {
+				continue
+			}
+
+			if !slices.ContainsFunc(fields, func(f *Field) bool {
+				return f.Option == o
+			}) {
+				return errors.New(errors.ErrInvalidParameter,
+					"invalid include value: "+string(o))
+			}
+		}
+	}
+
+	for _, name := range fo.Fields() {
+		found := false
+
+		for _, f := range fields {
+			if f.Name == name && !f.Hidden {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return errors.New(errors.ErrInvalidParameter,
+				"invalid fields value: "+name)
+		}
+	}
+
+	return nil
+}
+
+// ParseFieldOptions parses options from query string values. Related objects
+// are requested using a comma separated list in the include parameter, the
+// user_details boolean parameter is supported for compatibility. The fields
+// selected are restricted using a comma separated list in the fields
+// parameter.
 func ParseFieldOptions(values url.Values) (FieldOptions, error) {
 	r := FieldOptions{}
 
@@ -78,10 +180,49 @@ func ParseFieldOptions(values url.Values) (FieldOptions, error) {
 			continue
 		}
 
-		switch FieldOption(qk) {
-		case OptUserDetails:
+		switch {
+		case qk == "include":
+			for _, v := range qv {
+				for _, iv := range strings.Split(v, ",") {
+					o := FieldOption(strings.ToLower(strings.TrimSpace(iv)))
+					if o == "" {
+						continue
+					}
+
+					if strings.Trim(string(o),
+						"abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+						return nil, errors.New(errors.ErrInvalidParameter,
+							"invalid include value: "+iv)
+					}
+
+					if !r.Contains(o) {
+						r = append(r, o)
+					}
+				}
+			}
+		case qk == "fields":
+			for _, v := range qv {
+				for _, fv := range strings.Split(v, ",") {
+					name := strings.ToLower(strings.TrimSpace(fv))
+					if name == "" {
+						continue
+					}
+
+					if strings.Trim(name,
+						"abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+						return nil, errors.New(errors.ErrInvalidParameter,
+							"invalid fields value: "+fv)
+					}
+
+					if o := FieldSelect(name); !r.Contains(o) {
+						r = append(r, o)
+					}
+				}
+			}
+		case FieldOption(qk) == OptUserDetails:
 			b := strings.ToLower(strings.TrimSpace(qv[0]))
-			if b != "0" && b != "f" && b != "false" {
+			if b != "0" && b != "f" && b != "false" &&
+				!r.Contains(OptUserDetails) {
 				r = append(r, OptUserDetails)
 			}
 		}
@@ -109,6 +250,19 @@ type Field struct {
 	Tags     bool          `json:"tags,omitempty"`
 }
 
+// Column returns the SQL expression used to reference the field in WHERE,
+// GROUP BY, and ORDER BY clauses.
+func (f *Field) Column() string {
+	switch {
+	case f.Expr != "":
+		return f.Expr
+	case f.Table == "":
+		return f.Name
+	default:
+		return f.Table + "." + f.Name
+	}
+}
+
 // String formats a field value as a JSON format string.
 func (f *Field) String() string {
 	str, err := json.Marshal(f)
@@ -141,7 +295,7 @@ func SelectFields(
 			found := false
 
 			for _, sf := range sumFields {
-				if (f.Name == sf && f.Table == table) ||
+				if (f.Name == sf && (f.Table == table || f.Expr != "")) ||
 					((f.Table + "." + f.Name) == sf) {
 					found = true
 
@@ -159,26 +313,22 @@ func SelectFields(
 				first = true
 			}
 
-			res += "\t" + f.Table + "." + f.Name +
+			res += "\t" + f.Column() +
 				" AS " + strings.Trim(f.Table, `"`) + "_" + f.Name
 
-			continue
-		}
-
-		if f.Option != "" {
-			found := false
-
-			for _, o := range options {
-				if f.Option == o {
-					found = true
-
-					break
+			if jq := joinClause(table, f); jq != "" {
+				if f.Require {
+					joins = appendJoin(joins, jq)
+				} else {
+					leftJoins = appendJoin(leftJoins, jq)
 				}
 			}
 
-			if !found {
-				continue
-			}
+			continue
+		}
+
+		if !options.Selects(table, f) {
+			continue
 		}
 
 		if !f.Hidden {
@@ -207,42 +357,11 @@ func SelectFields(
 			}
 		}
 
-		if f.Table != table && (f.Key != "" || f.Join != "") {
-			jq := "JOIN "
-
-			if f.From != "" {
-				jq += f.From + " "
-			}
-
-			key := f.Key
-
-			if key == "" {
-				key = f.Table + "_key"
-			}
-
-			jq += f.Table + " ON (" + f.Table + "." + key + " = "
-
-			joinFrom := table
-
-			if f.JoinFrom != "" {
-				joinFrom = f.JoinFrom
-			}
-
-			jq += joinFrom + "."
-
-			join := f.Join
-
-			if join == "" {
-				join = key
-			}
-
-			jq += join + ")"
-
+		if jq := joinClause(table, f); jq != "" {
 			if f.Require {
-				joins = append(joins, jq)
+				joins = appendJoin(joins, jq)
 			} else {
-				jq = "LEFT " + jq
-				leftJoins = append(leftJoins, jq)
+				leftJoins = appendJoin(leftJoins, jq)
 			}
 		}
 	}
@@ -264,6 +383,87 @@ func SelectFields(
 	return res + "\n"
 }
 
+// joinClause returns the JOIN clause needed to select a field from a joined
+// table, or an empty string if the field is selected from the queried table.
+// Joins are LEFT joins unless the field requires the joined row.
+func joinClause(table string, f *Field) string {
+	if f.Table == table || (f.Key == "" && f.Join == "") {
+		return ""
+	}
+
+	jq := "JOIN "
+
+	if f.From != "" {
+		jq += f.From + " "
+	}
+
+	key := f.Key
+
+	if key == "" {
+		key = f.Table + "_key"
+	}
+
+	jq += f.Table + " ON (" + f.Table + "." + key + " = "
+
+	joinFrom := table
+
+	if f.JoinFrom != "" {
+		joinFrom = f.JoinFrom
+	}
+
+	jq += joinFrom + "."
+
+	join := f.Join
+
+	if join == "" {
+		join = key
+	}
+
+	jq += join + ")"
+
+	if !f.Require {
+		jq = "LEFT " + jq
+	}
+
+	return jq
+}
+
+// ScanFields returns the destinations for a SQL row scan of the fields
+// selected by SelectFields. The dest map contains the destination for each
+// field by name.
+func ScanFields(
+	table string,
+	fields []*Field,
+	options FieldOptions,
+	dest map[string]any,
+) []any {
+	res := []any{}
+
+	for _, f := range fields {
+		if f.Hidden || !options.Selects(table, f) {
+			continue
+		}
+
+		if d, ok := dest[f.Name]; ok {
+			res = append(res, d)
+		}
+	}
+
+	return res
+}
+
+// appendJoin appends a join clause to a list of joins, unless the list already
+// contains it. Multiple fields may be selected through the same join.
+func appendJoin(joins []string, join string) []string {
+	for _, j := range joins {
+		if j == join {
+			return joins
+		}
+	}
+
+	return append(joins, join)
+}
+
 // SearchFields returns a SQL query SELECT stub for the specified table key
 // field, joining for other fields as needed for search.
 func SearchFields(
@@ -325,10 +525,10 @@ func SearchFields(
 			jq += join + ")"
 
 			if f.Require {
-				joins = append(joins, jq)
+				joins = appendJoin(joins, jq)
 			} else {
 				jq = "LEFT " + jq
-				leftJoins = append(leftJoins, jq)
+				leftJoins = appendJoin(leftJoins, jq)
 			}
 		}
 	}
@@ -366,20 +566,8 @@ func ReturningFields(
 			continue
 		}
 
-		if f.Option != "" {
-			found := false
-
-			for _, o := range options {
-				if f.Option == o {
-					found = true
-
-					break
-				}
-			}
-
-			if !found {
-				continue
-			}
+		if !options.Selects(table, f) {
+			continue
 		}
 
 		if first {
@@ -461,7 +649,8 @@ func (sd *SummaryData) ScanDest(
 		found := ""
 
 		for _, sf := range sumFields {
-			if (f.Name == sf && f.Table == fields[0].Table) ||
+			if (f.Name == sf &&
+				(f.Table == fields[0].Table || f.Expr != "")) ||
 				((f.Table + "." + f.Name) == sf) {
 				found = sf
 
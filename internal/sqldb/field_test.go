@@ -2,6 +2,7 @@ package sqldb_test
 
 import (
 	"net/url"
+	"slices"
 	"strings"
 	"testing"
 
@@ -22,6 +23,139 @@ func TestParseFieldOptions(t *testing.T) {
 	if !options.Contains(sqldb.OptUserDetails) {
 		t.Errorf("Expected: %v, got: %v", sqldb.OptUserDetails, options)
 	}
+
+	options, err = sqldb.ParseFieldOptions(url.Values{
+		"include":      []string{"created_by_user, tags,user_details"},
+		"user_details": []string{"true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(options) != 3 {
+		t.Errorf("Expected length: 3, got: %v", len(options))
+	}
+
+	for _, o := range []sqldb.FieldOption{
+		sqldb.OptCreatedByUser,
+		sqldb.OptTags,
+		sqldb.OptUserDetails,
+	} {
+		if !options.Contains(o) {
+			t.Errorf("Expected: %v, got: %v", o, options)
+		}
+	}
+
+	if _, err := sqldb.ParseFieldOptions(url.Values{
+		"include": []string{"in-valid"},
+	}); err == nil {
+		t.Error("Expected error for invalid include value but got nil")
+	}
+
+	options, err = sqldb.ParseFieldOptions(url.Values{
+		"fields": []string{"name, status,name"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := []string{"name", "status"}; !slices.Equal(options.Fields(),
+		exp) {
+		t.Errorf("Expected: %v, got: %v", exp, options.Fields())
+	}
+
+	if _, err := sqldb.ParseFieldOptions(url.Values{
+		"fields": []string{"name.status"},
+	}); err == nil {
+		t.Error("Expected error for invalid fields value but got nil")
+	}
+}
+
+func TestFieldSelection(t *testing.T) {
+	t.Parallel()
+
+	fields := []*sqldb.Field{{
+		Name:   "test_key",
+		Table:  "test",
+		Type:   sqldb.FieldInt,
+		Hidden: true,
+	}, {
+		Name:  "test_id",
+		Table: "test",
+		Type:  sqldb.FieldString,
+	}, {
+		Name:  "name",
+		Table: "test",
+		Type:  sqldb.FieldString,
+	}, {
+		Name:  "status",
+		Table: "test",
+		Type:  sqldb.FieldString,
+	}, {
+		Name:   "created_at",
+		Table:  "test",
+		Type:   sqldb.FieldTime,
+		Option: sqldb.OptUserDetails,
+	}}
+
+	options := sqldb.FieldOptions{
+		sqldb.FieldSelect("status"),
+		sqldb.FieldSelect("created_at"),
+	}
+
+	if err := options.ValidateFields(fields); err != nil {
+		t.Fatal(err)
+	}
+
+	v := sqldb.SelectFields("test", fields, nil, options)
+
+	exp := `SELECT
+	test.test_id AS test_test_id,
+	test.status AS test_status,
+	EXTRACT(epoch FROM test.created_at)::BIGINT AS test_created_at
+FROM test
+`
+
+	if v != exp {
+		t.Errorf("Expected: %v, got: %v", exp, v)
+	}
+
+	id, name, status, createdAt := "", "", "", ""
+
+	dest := sqldb.ScanFields("test", fields, options, map[string]any{
+		"test_id":    &id,
+		"name":       &name,
+		"status":     &status,
+		"created_at": &createdAt,
+	})
+
+	if len(dest) != 3 || dest[0] != &id || dest[1] != &status ||
+		dest[2] != &createdAt {
+		t.Errorf("Unexpected scan destinations: %v", dest)
+	}
+
+	for _, name := range []string{"test_key", "invalid"} {
+		options = sqldb.FieldOptions{sqldb.FieldSelect(name)}
+
+		if err := options.ValidateFields(fields); err == nil {
+			t.Errorf("Expected error for field %v but got nil", name)
+		}
+	}
+
+	options = sqldb.FieldOptions{sqldb.OptUserDetails}
+
+	if err := options.ValidateFields(fields); err != nil {
+		t.Errorf("Unexpected error for include %v: %v",
+			sqldb.OptUserDetails, err)
+	}
+
+	for _, o := range []sqldb.FieldOption{sqldb.OptTags, "invalid"} {
+		options = sqldb.FieldOptions{o}
+
+		if err := options.ValidateFields(fields); err == nil {
+			t.Errorf("Expected error for include %v but got nil", o)
+		}
+	}
 }
 
 func TestSelectFields(t *testing.T) {
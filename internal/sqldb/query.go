@@ -3,9 +3,11 @@ package sqldb
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dhaifley/apigo/internal/config"
 	"github.com/dhaifley/apigo/internal/errors"
@@ -26,6 +28,8 @@ type Query struct {
 	Sets     []string       `json:"set_fields,omitempty"`
 	Params   []any          `json:"params,omitempty"`
 	Limit    int64          `json:"limit"`
+	Lock     bool           `json:"lock,omitempty"`
+	Location *time.Location `json:"-"`
 	count    int64          `json:"-"`
 	setStart int64          `json:"-"`
 }
@@ -40,19 +44,22 @@ const (
 	QueryUpdate = QueryType("UPDATE")
 	QueryDelete = QueryType("DELETE")
 	QueryExec   = QueryType("EXEC")
+	QueryCount  = QueryType("COUNT")
 )
 
 // QueryOptions values contain options when creating a new query.
 type QueryOptions struct {
-	Config *config.Config `json:"-"`
-	DB     SQLDB          `json:"db"`
-	Tx     SQLTX          `json:"tx,omitempty"`
-	Type   QueryType      `json:"type"`
-	Base   string         `json:"base"`
-	Search *search.Query  `json:"search,omitempty"`
-	Fields []*Field       `json:"fields,omitempty"`
-	Sets   []string       `json:"set,omitempty"`
-	Params []any          `json:"params,omitempty"`
+	Config   *config.Config `json:"-"`
+	DB       SQLDB          `json:"db"`
+	Tx       SQLTX          `json:"tx,omitempty"`
+	Type     QueryType      `json:"type"`
+	Base     string         `json:"base"`
+	Search   *search.Query  `json:"search,omitempty"`
+	Fields   []*Field       `json:"fields,omitempty"`
+	Sets     []string       `json:"set,omitempty"`
+	Params   []any          `json:"params,omitempty"`
+	Lock     bool           `json:"lock,omitempty"`
+	Location *time.Location `json:"-"`
 }
 
 // NewQuery creates an initializes a new query value.
@@ -78,12 +85,16 @@ func NewQuery(opts *QueryOptions) *Query {
 		Params:   opts.Params,
 		SQL:      "",
 		Limit:    0,
+		Lock:     opts.Lock,
+		Location: opts.Location,
 		count:    int64(len(opts.Params)),
 		setStart: int64(len(opts.Params)-len(opts.Sets)) + 1,
 	}
 }
 
-// Field retrieves a query search field value by name.
+// Field retrieves a query search field value by name. Fields may also be
+// retrieved by their table qualified name or by the alias used for them in
+// SELECT statements, allowing joined fields to be referenced.
 func (q *Query) Field(name string) *Field {
 	for _, f := range q.Fields {
 		if name == f.Name {
@@ -97,6 +108,19 @@ func (q *Query) Field(name string) *Field {
 		}
 	}
 
+	for _, f := range q.Fields {
+		if f.Table == "" {
+			continue
+		}
+
+		table := strings.Trim(f.Table, `"`)
+
+		if name == table+"."+f.Name || name == f.Table+"."+f.Name ||
+			name == table+"_"+f.Name {
+			return f
+		}
+	}
+
 	return nil
 }
 
@@ -197,7 +221,14 @@ func (q *Query) addParam(f *Field, value string) error {
 			}
 		}
 	case FieldTime:
-		i, err := strconv.ParseInt(value, 10, 64)
+		var i int64
+
+		if request.IsRelativeTime(value) {
+			i, err = request.ParseRelativeTime(value, time.Now())
+		} else {
+			i, err = request.ParseTime(value, q.Location)
+		}
+
 		if err != nil {
 			return errors.Wrap(err, errors.ErrInvalidRequest,
 				"unable to parse time param",
@@ -248,9 +279,10 @@ func (q *Query) formatParam(f *Field,
 	jsonExpr string,
 	op FieldOperator,
 	value string,
+	ci bool,
 ) (string, error) {
 	if strings.ToLower(value) == "null" {
-		return fmt.Sprintf("(%s.%s IS NULL)", f.Table, f.Name), nil
+		return fmt.Sprintf("(%s IS NULL)", f.Column()), nil
 	}
 
 	name := f.Name
@@ -388,15 +420,164 @@ func (q *Query) formatParam(f *Field,
 		op = f.Op
 	}
 
-	if expr != "" {
-		return fmt.Sprintf("(%s %s %s)", expr, op, param), nil
+	col := expr
+
+	if col == "" {
+		col = name
+
+		if f.Table != "" {
+			col = f.Table + "." + name
+		}
+	}
+
+	// Case insensitive string matches compare values folded by app_fold(),
+	// which removes their case and accents.
+	if ci && (f.Type == FieldString || f.Type == FieldJSON && jsonExpr != "") {
+		switch op {
+		case OpEq, OpLike:
+			return fmt.Sprintf("(app_fold(%s) %s app_fold(%s))",
+				col, op, param), nil
+		case OpRE:
+			op = OpIRE
+		}
 	}
 
-	if f.Table == "" {
-		return fmt.Sprintf("(%s %s %s)", name, op, param), nil
+	return fmt.Sprintf("(%s %s %s)", col, op, param), nil
+}
+
+// searchField returns the search field for a search category, and the JSON
+// path expression for categories referencing values within JSON fields, such
+// as data.array[1].test. If the category is not a search field, nil is
+// returned.
+func (q *Query) searchField(cat string) (*Field, string) {
+	if !strings.Contains(cat, ".") {
+		return q.Field(cat), ""
 	}
 
-	return fmt.Sprintf("(%s.%s %s %s)", f.Table, name, op, param), nil
+	parts := strings.Split(cat, ".")
+
+	jsonExpr := "'" + strings.ReplaceAll(
+		strings.Join(parts[1:], "."), ".", "'->'")
+
+	jsonExpr = strings.ReplaceAll(strings.ReplaceAll(
+		strings.ReplaceAll(jsonExpr,
+			"[", "'->"), "]'->'", "->'"), "]", "") + "'"
+
+	if i := strings.LastIndex(jsonExpr, "->"); i >= 0 {
+		jsonExpr = jsonExpr[:i] + "->>" + jsonExpr[i+2:]
+	}
+
+	return q.Field(parts[0]), jsonExpr
+}
+
+// parseListNode returns a SQL where clause expression for an in list or range
+// search node, comparing the field with all of the values of the node using
+// = ANY or BETWEEN. An empty expression is returned for nodes which can not be
+// compared as a whole, which are those with values containing wildcards,
+// regular expressions or nulls, those of fields which are not string, number,
+// time, or JSON path fields, and open ranges.
+func (q *Query) parseListNode(node *search.QueryNode) (string, error) {
+	if len(node.Nodes) == 0 ||
+		(node.Op == search.OpRange && len(node.Nodes) != 2) {
+		return "", nil
+	}
+
+	cat := node.Nodes[0].Cat
+
+	field, jsonExpr := q.searchField(cat)
+	if field == nil || field.Op != "" {
+		return "", nil
+	}
+
+	switch field.Type {
+	case FieldString, FieldInt, FieldFloat:
+	case FieldTime:
+		if node.Op == search.OpIn {
+			return "", nil
+		}
+	case FieldJSON:
+		if jsonExpr == "" {
+			return "", nil
+		}
+	default:
+		return "", nil
+	}
+
+	for _, n := range node.Nodes {
+		if n.CI && (field.Type == FieldString || field.Type == FieldJSON) {
+			return "", nil
+		}
+
+		if n.Cat != cat || n.ValRE != "" || n.Val == "" ||
+			q.containsWildcards(n.Val) ||
+			strings.ToLower(strings.TrimSpace(n.Val)) == "null" {
+			return "", nil
+		}
+	}
+
+	col := field.Column()
+
+	if jsonExpr != "" {
+		jop := "->"
+
+		if !strings.Contains(jsonExpr, "->") {
+			jop += ">"
+		}
+
+		col += jop + jsonExpr
+	}
+
+	start := len(q.Params)
+
+	for _, n := range node.Nodes {
+		if err := q.addParam(field, n.Val); err != nil {
+			return "", err
+		}
+	}
+
+	if node.Op == search.OpRange {
+		lo, hi := fmt.Sprintf("$%d", q.count-1), fmt.Sprintf("$%d", q.count)
+
+		if field.Type == FieldTime {
+			lo, hi = "to_timestamp("+lo+")", "to_timestamp("+hi+")"
+		}
+
+		return fmt.Sprintf("(%s BETWEEN %s AND %s)", col, lo, hi), nil
+	}
+
+	var vals any
+
+	switch field.Type {
+	case FieldInt:
+		v := make([]int64, 0, len(node.Nodes))
+
+		for _, p := range q.Params[start:] {
+			v = append(v, p.(int64))
+		}
+
+		vals = v
+	case FieldFloat:
+		v := make([]float64, 0, len(node.Nodes))
+
+		for _, p := range q.Params[start:] {
+			v = append(v, p.(float64))
+		}
+
+		vals = v
+	default:
+		v := make([]string, 0, len(node.Nodes))
+
+		for _, p := range q.Params[start:] {
+			v = append(v, p.(string))
+		}
+
+		vals = v
+	}
+
+	q.count -= int64(len(q.Params) - start - 1)
+	q.Params = append(q.Params[:start], vals)
+
+	return fmt.Sprintf("(%s = ANY($%d))", col, q.count), nil
 }
 
 // parseSearchNode returns a SQL where clause expression for a single search
@@ -413,39 +594,24 @@ func (q *Query) parseSearchNode(node *search.QueryNode,
 
 		op := OpEq
 
+		ci := node.CI
+
 		if node.ValRE != "" {
 			val = node.ValRE
 			op = OpRE
+
+			// Regular expression case insensitive flags, from /re/i
+			// values, are applied using a case insensitive match.
+			if v, ok := strings.CutPrefix(val, "(?i)"); ok {
+				val, ci = v, true
+			}
 		} else if q.containsWildcards(val) || val == "" {
 			op = OpLike
+		} else if o := node.Comp.Operator(); o != "" {
+			op = FieldOperator(o)
 		}
 
-		var field *Field
-
-		jsonExpr := ""
-
-		if strings.Contains(node.Cat, ".") {
-			parts := strings.Split(node.Cat, ".")
-
-			if len(parts) > 1 {
-				jsonExpr = "'" + strings.ReplaceAll(
-					strings.Join(parts[1:], "."), ".", "'->'")
-
-				jsonExpr = strings.ReplaceAll(strings.ReplaceAll(
-					strings.ReplaceAll(jsonExpr,
-						"[", "'->"), "]'->'", "->'"), "]", "") + "'"
-
-				if i := strings.LastIndex(jsonExpr, "->"); i >= 0 {
-					jsonExpr = jsonExpr[:i] + "->>" + jsonExpr[i+2:]
-				}
-
-				field = q.Field(parts[0])
-			} else {
-				field = q.Field(node.Cat)
-			}
-		} else {
-			field = q.Field(node.Cat)
-		}
+		field, jsonExpr := q.searchField(node.Cat)
 
 		if field == nil {
 			// Attempt to use the term as a tag search.
@@ -468,8 +634,24 @@ func (q *Query) parseSearchNode(node *search.QueryNode,
 			return "", err
 		}
 
-		return q.formatParam(field, jsonExpr, op, val)
-	case search.OpAnd, search.OpOr, search.OpNot:
+		return q.formatParam(field, jsonExpr, op, val, ci)
+	case search.OpIn, search.OpRange:
+		if sql, err := q.parseListNode(node); err != nil || sql != "" {
+			return sql, err
+		}
+
+		// Lists which can not be compared as a whole, such as those
+		// containing wildcards, are searched for as each of their values.
+		op := search.OpOr
+
+		if node.Op == search.OpRange {
+			op = search.OpAnd
+		}
+
+		return q.parseSearchNode(search.NewQueryNode(op, node.Comp, "", "",
+			node.Nodes...))
+	case search.OpAnd, search.OpOr, search.OpNot, search.OpCI,
+		search.OpExists:
 		nodes := []string{}
 
 		for _, n := range node.Nodes {
@@ -485,8 +667,14 @@ func (q *Query) parseSearchNode(node *search.QueryNode,
 				return "(NOT " + nodes[0] + ")", nil
 			}
 
+			op := node.Op
+
+			if op == search.OpCI || op == search.OpExists {
+				op = search.OpAnd
+			}
+
 			return "(" + strings.Join(nodes, " "+
-				strings.ToUpper(node.Op.String())+" ") + ")", nil
+				strings.ToUpper(op.String())+" ") + ")", nil
 		}
 	}
 
@@ -510,6 +698,10 @@ func (q *Query) parseSearch() error {
 			"search", q.Search.Search)
 	}
 
+	if q.Search.CaseInsensitive {
+		ast.Root.SetCaseInsensitive()
+	}
+
 	if sql, err := q.parseSearchNode(ast.Root); err != nil {
 		return err
 	} else if sql != "" {
@@ -584,11 +776,7 @@ func (q *Query) Parse() error {
 					groupBy += ","
 				}
 
-				if qf.Table == "" {
-					groupBy += " " + qf.Name
-				} else {
-					groupBy += " " + qf.Table + "." + qf.Name
-				}
+				groupBy += " " + qf.Column()
 			}
 		}
 
@@ -615,11 +803,7 @@ func (q *Query) Parse() error {
 					order += ","
 				}
 
-				if qf.Table == "" {
-					order += " " + qf.Name + dir
-				} else {
-					order += " " + qf.Table + "." + qf.Name + dir
-				}
+				order += " " + qf.Column() + dir
 			}
 		}
 	}
@@ -650,7 +834,7 @@ func (q *Query) Parse() error {
 		}
 
 		if !strings.Contains(q.Base, "LIMIT") {
-			if q.Limit > 1 {
+			if q.Limit > 1 || q.Search != nil {
 				// Fetch one more than limit rows to test for more results.
 				q.SQL += fmt.Sprintf(" LIMIT %d", q.Limit+1)
 			} else {
@@ -661,6 +845,19 @@ func (q *Query) Parse() error {
 		if offset != "" && !strings.Contains(q.Base, "OFFSET") {
 			q.SQL += offset
 		}
+
+		// Lock the selected rows of the primary table until the end of the
+		// current transaction.
+		if q.Lock && !strings.Contains(q.Base, "FOR UPDATE") {
+			q.SQL += " FOR UPDATE"
+
+			if len(q.Fields) > 0 && q.Fields[0].Table != "" {
+				q.SQL += " OF " + q.Fields[0].Table
+			}
+		}
+	case QueryCount:
+		// Count all rows matching the search, ignoring any limit or offset.
+		q.SQL = "SELECT COUNT(*) FROM (" + q.SQL + ") AS count_query"
 	case QueryUpdate:
 		sets := ""
 
@@ -815,16 +1012,48 @@ func (q *Query) Parse() error {
 	return nil
 }
 
+// setLocation sets the query time zone from the request context, if it has not
+// been set already.
+func (q *Query) setLocation(ctx context.Context) {
+	if q.Location != nil {
+		return
+	}
+
+	if loc, err := request.ContextTimeZone(ctx); err == nil {
+		q.Location = loc
+	}
+}
+
+// readOnly determines whether the query may be routed to a read only replica
+// database. Only select and count queries which do not lock rows are read only.
+func (q *Query) readOnly() bool {
+	return (q.Type == QuerySelect || q.Type == QueryCount) && !q.Lock &&
+		!strings.Contains(q.Base, "FOR UPDATE")
+}
+
+// tx returns the transaction the query is performed in. This is the
+// transaction of the query, if it has one, or else the transaction of the unit
+// of work contained in the context, if any.
+func (q *Query) tx(ctx context.Context) SQLTX {
+	if q.Tx != nil {
+		return q.Tx
+	}
+
+	return ContextTx(ctx)
+}
+
 // Exec executes a SQL statement that does not return rows.
 func (q *Query) Exec(ctx context.Context) (SQLResult, error) {
 	if q.SQL == "" {
+		q.setLocation(ctx)
+
 		if err := q.Parse(); err != nil {
 			return nil, err
 		}
 	}
 
-	if q.Tx != nil {
-		return q.Tx.Exec(ctx, q.SQL, q.Params...)
+	if tx := q.tx(ctx); tx != nil {
+		return tx.Exec(ctx, q.SQL, q.Params...)
 	}
 
 	return q.DB.Exec(ctx, q.SQL, q.Params...)
@@ -833,13 +1062,19 @@ func (q *Query) Exec(ctx context.Context) (SQLResult, error) {
 // Query executes the query and returns the sql rows.
 func (q *Query) Query(ctx context.Context) (SQLRows, error) {
 	if q.SQL == "" {
+		q.setLocation(ctx)
+
 		if err := q.Parse(); err != nil {
 			return nil, err
 		}
 	}
 
-	if q.Tx != nil {
-		return q.Tx.Query(ctx, q.SQL, q.Params...)
+	if tx := q.tx(ctx); tx != nil {
+		return tx.Query(ctx, q.SQL, q.Params...)
+	}
+
+	if rdb, ok := q.DB.(ReadDB); ok && q.readOnly() {
+		return rdb.ReadQuery(ctx, q.SQL, q.Params...)
 	}
 
 	return q.DB.Query(ctx, q.SQL, q.Params...)
@@ -848,14 +1083,103 @@ func (q *Query) Query(ctx context.Context) (SQLRows, error) {
 // QueryRow executes the query and returns a single row.
 func (q *Query) QueryRow(ctx context.Context) (SQLRow, error) {
 	if q.SQL == "" {
+		q.setLocation(ctx)
+
 		if err := q.Parse(); err != nil {
 			return nil, err
 		}
 	}
 
-	if q.Tx != nil {
-		return q.Tx.QueryRow(ctx, q.SQL, q.Params...), nil
+	if tx := q.tx(ctx); tx != nil {
+		return tx.QueryRow(ctx, q.SQL, q.Params...), nil
+	}
+
+	if rdb, ok := q.DB.(ReadDB); ok && q.readOnly() {
+		return rdb.ReadQueryRow(ctx, q.SQL, q.Params...), nil
 	}
 
 	return q.DB.QueryRow(ctx, q.SQL, q.Params...), nil
 }
+
+// QueryPlan values contain the estimated cost of performing a query, as
+// planned by the database.
+type QueryPlan struct {
+	SQL         string         `json:"sql"          yaml:"sql"`
+	StartupCost float64        `json:"startup_cost" yaml:"startup_cost"`
+	TotalCost   float64        `json:"total_cost"   yaml:"total_cost"`
+	Rows        int64          `json:"rows"         yaml:"rows"`
+	Plan        map[string]any `json:"plan"         yaml:"plan"`
+}
+
+// explainQuery is the prefix used to plan statements, without performing
+// them, returning the plans in JSON format.
+const explainQuery = "EXPLAIN (FORMAT JSON) "
+
+// parsePlan parses a query plan returned in JSON format by EXPLAIN.
+func parsePlan(b []byte) (*QueryPlan, error) {
+	plans := []struct {
+		Plan map[string]any `json:"Plan"`
+	}{}
+
+	if err := json.Unmarshal(b, &plans); err != nil || len(plans) == 0 {
+		return nil, errors.New(errors.ErrDatabase,
+			"invalid query plan",
+			"plan", string(b))
+	}
+
+	res := &QueryPlan{Plan: plans[0].Plan}
+
+	res.StartupCost, _ = res.Plan["Startup Cost"].(float64)
+	res.TotalCost, _ = res.Plan["Total Cost"].(float64)
+
+	if n, ok := res.Plan["Plan Rows"].(float64); ok {
+		res.Rows = int64(n)
+	}
+
+	return res, nil
+}
+
+// Explain plans the query, using EXPLAIN, and returns the estimated cost of
+// performing it. The query itself is not performed.
+func (q *Query) Explain(ctx context.Context) (*QueryPlan, error) {
+	if q.SQL == "" {
+		q.setLocation(ctx)
+
+		if err := q.Parse(); err != nil {
+			return nil, err
+		}
+	}
+
+	sql := explainQuery + q.SQL
+
+	var row SQLRow
+
+	tx := q.tx(ctx)
+
+	rdb, ok := q.DB.(ReadDB)
+
+	switch {
+	case tx != nil:
+		row = tx.QueryRow(ctx, sql, q.Params...)
+	case ok && q.readOnly():
+		row = rdb.ReadQueryRow(ctx, sql, q.Params...)
+	default:
+		row = q.DB.QueryRow(ctx, sql, q.Params...)
+	}
+
+	b := []byte{}
+
+	if err := row.Scan(&b); err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to explain query")
+	}
+
+	res, err := parsePlan(b)
+	if err != nil {
+		return nil, err
+	}
+
+	res.SQL = q.SQL
+
+	return res, nil
+}
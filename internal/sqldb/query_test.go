@@ -2,9 +2,12 @@ package sqldb_test
 
 import (
 	"context"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/search"
 	"github.com/dhaifley/apigo/internal/sqldb"
 )
@@ -168,6 +171,189 @@ func TestQueryParse(t *testing.T) {
 	}
 }
 
+func TestQueryParseLists(t *testing.T) {
+	t.Parallel()
+
+	fields := []*sqldb.Field{
+		{
+			Name:  "status",
+			Type:  sqldb.FieldString,
+			Table: "resource",
+		},
+		{
+			Name:  "clear_after",
+			Type:  sqldb.FieldInt,
+			Table: "resource",
+		},
+		{
+			Name:  "updated_at",
+			Type:  sqldb.FieldTime,
+			Table: "resource",
+		},
+		{
+			Name:  "data",
+			Type:  sqldb.FieldJSON,
+			Table: "resource",
+		},
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QuerySelect,
+		Base: "SELECT * FROM resource",
+		Search: &search.Query{
+			Search: "and(in(status:active,error),in(clear_after:10,20)," +
+				"range(clear_after:10..100),range(updated_at:0..60)," +
+				"in(data.host:a,b),in(status:act*,new),range(clear_after:5..))",
+		},
+		Fields: fields,
+	})
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "SELECT * FROM resource WHERE ((" +
+		"(resource.status = ANY($1)) AND " +
+		"(resource.clear_after = ANY($2)) AND " +
+		"(resource.clear_after BETWEEN $3 AND $4) AND " +
+		"(resource.updated_at BETWEEN to_timestamp($5) " +
+		"AND to_timestamp($6)) AND " +
+		"(resource.data->>'host' = ANY($7)) AND " +
+		"((resource.status LIKE $8) OR (resource.status = $9)) AND " +
+		"((resource.clear_after >= $10)))) LIMIT 101 OFFSET 0"
+
+	if q.SQL != exp {
+		t.Errorf("Expecting query: %v, got: %v", exp, q.SQL)
+	}
+
+	expParams := []any{
+		[]string{"active", "error"},
+		[]int64{10, 20},
+		int64(10), int64(100),
+		int64(0), int64(60),
+		[]string{"a", "b"},
+		"act%", "new",
+		int64(5),
+	}
+
+	if !reflect.DeepEqual(q.Params, expParams) {
+		t.Errorf("Expecting params: %v, got: %v", expParams, q.Params)
+	}
+}
+
+func TestQueryParseExists(t *testing.T) {
+	t.Parallel()
+
+	fields := []*sqldb.Field{
+		{
+			Name:  "status",
+			Type:  sqldb.FieldString,
+			Table: "resource",
+		},
+		{
+			Name:  "data",
+			Type:  sqldb.FieldJSON,
+			Table: "resource",
+		},
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QuerySelect,
+		Base: "SELECT * FROM resource",
+		Search: &search.Query{
+			Search: "and(exists(data.host),status:/^act/i)",
+		},
+		Fields: fields,
+	})
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "SELECT * FROM resource WHERE ((((" +
+		"resource.data->>'host' LIKE $1)) AND " +
+		"(resource.status ~* $2))) LIMIT 101 OFFSET 0"
+
+	if q.SQL != exp {
+		t.Errorf("Expecting query: %v, got: %v", exp, q.SQL)
+	}
+
+	expParams := []any{"%", "^act"}
+
+	if !reflect.DeepEqual(q.Params, expParams) {
+		t.Errorf("Expecting params: %v, got: %v", expParams, q.Params)
+	}
+}
+
+func TestQueryParseCaseInsensitive(t *testing.T) {
+	t.Parallel()
+
+	fields := []*sqldb.Field{
+		{
+			Name:  "name",
+			Type:  sqldb.FieldString,
+			Table: "resource",
+		},
+		{
+			Name:  "version",
+			Type:  sqldb.FieldString,
+			Table: "resource",
+		},
+		{
+			Name:  "data",
+			Type:  sqldb.FieldJSON,
+			Table: "resource",
+		},
+	}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QuerySelect,
+		Base: "SELECT * FROM resource",
+		Search: &search.Query{
+			Search: "and(ci(name:Café*,data.host:/XndlYg==/),version:V1)",
+		},
+		Fields: fields,
+	})
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "SELECT * FROM resource WHERE (((" +
+		"(app_fold(resource.name) LIKE app_fold($1)) AND " +
+		"(resource.data->>'host' ~* $2)) AND " +
+		"(resource.version = $3))) LIMIT 101 OFFSET 0"
+
+	if q.SQL != exp {
+		t.Errorf("Expecting query: %v, got: %v", exp, q.SQL)
+	}
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QuerySelect,
+		Base: "SELECT * FROM resource",
+		Search: &search.Query{
+			Search:          "and(version:V1)",
+			CaseInsensitive: true,
+		},
+		Fields: fields,
+	})
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp = "SELECT * FROM resource WHERE " +
+		"(((app_fold(resource.version) = app_fold($1)))) LIMIT 101 OFFSET 0"
+
+	if q.SQL != exp {
+		t.Errorf("Expecting query: %v, got: %v", exp, q.SQL)
+	}
+}
+
 func TestQueryNoParse(t *testing.T) {
 	base := "SELECT account_url FROM accounts WHERE account_id = $1"
 
@@ -309,6 +495,74 @@ func TestQueryQueryRow(t *testing.T) {
 	}
 }
 
+func TestQueryTimeZone(t *testing.T) {
+	t.Parallel()
+
+	fields := []*sqldb.Field{
+		{
+			Name:  "created_at",
+			Type:  sqldb.FieldTime,
+			Table: `"user"`,
+		},
+	}
+
+	loc := time.FixedZone("test", -5*60*60)
+
+	ctx := context.WithValue(context.Background(), request.CtxKeyTimeZone, loc)
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     &mockSQLConn{},
+		Type:   sqldb.QuerySelect,
+		Base:   "SELECT * FROM user",
+		Search: &search.Query{Search: "and(created_at:2024-01-01)"},
+		Fields: fields,
+	})
+
+	if _, err := q.QueryRow(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Location != loc {
+		t.Errorf("Expected location: %v, got: %v", loc, q.Location)
+	}
+
+	if len(q.Params) != 1 || q.Params[0] != int64(1704085200) {
+		t.Errorf("Expected params: [1704085200], got: %v", q.Params)
+	}
+}
+
+func TestQueryRelativeTime(t *testing.T) {
+	t.Parallel()
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:     &mockSQLConn{},
+		Type:   sqldb.QuerySelect,
+		Base:   "SELECT * FROM user",
+		Search: &search.Query{Search: "and(created_at:>now-24h)"},
+		Fields: []*sqldb.Field{{
+			Name:  "created_at",
+			Type:  sqldb.FieldTime,
+			Table: `"user"`,
+		}},
+	})
+
+	before := time.Now().Add(-24 * time.Hour).Unix()
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(q.SQL,
+		`("user".created_at > to_timestamp($1))`) {
+		t.Errorf("Expected greater than comparison, got: %v", q.SQL)
+	}
+
+	if v, ok := q.Params[0].(int64); !ok || v < before ||
+		v > time.Now().Add(-24*time.Hour).Unix() {
+		t.Errorf("Expected time 24 hours ago, got: %v", q.Params[0])
+	}
+}
+
 func TestQueryInsert(t *testing.T) {
 	base := "INSERT INTO user () VALUES () " +
 		"ON CONFLICT DO UPDATE SET RETURNING id"
@@ -502,3 +756,208 @@ func TestQueryFieldOperations(t *testing.T) {
 		t.Error("Expected nil for nonexistent field")
 	}
 }
+
+func TestQueryParseJoinedSort(t *testing.T) {
+	t.Parallel()
+
+	fields := []*sqldb.Field{{
+		Name:  "test_key",
+		Type:  sqldb.FieldInt,
+		Table: "test",
+	}, {
+		Name:  "test_id",
+		Type:  sqldb.FieldString,
+		Table: "test",
+	}, {
+		Name:  "created_by",
+		Type:  sqldb.FieldString,
+		Table: "created_by_user",
+		From:  `"user"`,
+		Key:   "user_key",
+		Join:  "created_by",
+		Expr:  "created_by_user.user_id",
+	}, {
+		Name:  "created_by_email",
+		Type:  sqldb.FieldString,
+		Table: "created_by_user",
+		From:  `"user"`,
+		Key:   "user_key",
+		Join:  "created_by",
+		Expr:  "created_by_user.email",
+	}, {
+		Name:  "last_name",
+		Type:  sqldb.FieldString,
+		Table: "created_by_user",
+		From:  `"user"`,
+		Key:   "user_key",
+		Join:  "created_by",
+	}}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QuerySelect,
+		Base: sqldb.SearchFields("test", fields),
+		Search: &search.Query{
+			Search: "and(created_by_email:test)",
+			Sort: "-created_by_email,test.test_id,created_by," +
+				"created_by_user_last_name",
+		},
+		Fields: fields,
+	})
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "SELECT\n\ttest.test_key AS test_test_key,\n" +
+		"\ttest.test_id AS test_test_id\nFROM test\n" +
+		"LEFT JOIN \"user\" created_by_user ON " +
+		"(created_by_user.user_key = test.created_by)\n " +
+		"WHERE (((created_by_user.email = $1))) ORDER BY " +
+		"created_by_user.email DESC, test.test_id ASC, " +
+		"created_by_user.user_id ASC, created_by_user.last_name ASC " +
+		"LIMIT 101 OFFSET 0"
+
+	if q.SQL != exp {
+		t.Errorf("Expecting query: %v, got: %v", exp, q.SQL)
+	}
+
+	sel := sqldb.SelectFields("test", fields,
+		&search.Query{Summary: "created_by_email"}, nil)
+
+	exp = "SELECT\n\tcreated_by_user.email AS " +
+		"created_by_user_created_by_email,\n\tCOUNT(*) AS count\n" +
+		"FROM test\nLEFT JOIN \"user\" created_by_user ON " +
+		"(created_by_user.user_key = test.created_by)\n"
+
+	if sel != exp {
+		t.Errorf("Expecting summary select: %v, got: %v", exp, sel)
+	}
+
+	q = sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QuerySelect,
+		Base: "SELECT * FROM test",
+		Search: &search.Query{
+			Sort: "user.invalid",
+		},
+		Fields: fields,
+	})
+
+	if err := q.Parse(); err == nil {
+		t.Error("Expected error for invalid sort field but got nil")
+	}
+}
+
+func TestQueryParseLock(t *testing.T) {
+	t.Parallel()
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QuerySelect,
+		Base: "SELECT test.test_id FROM test WHERE test.test_id = $1",
+		Fields: []*sqldb.Field{{
+			Name:  "test_id",
+			Type:  sqldb.FieldString,
+			Table: "test",
+		}},
+		Params: []any{"1"},
+		Lock:   true,
+	})
+
+	q.Limit = 1
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "SELECT test.test_id FROM test WHERE test.test_id = $1" +
+		" LIMIT 1 OFFSET 0 FOR UPDATE OF test"
+
+	if q.SQL != exp {
+		t.Errorf("Expecting query: %v, got: %v", exp, q.SQL)
+	}
+}
+
+func TestQueryParseCount(t *testing.T) {
+	t.Parallel()
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   &mockSQLConn{},
+		Type: sqldb.QueryCount,
+		Base: "SELECT test.test_id FROM test",
+		Fields: []*sqldb.Field{{
+			Name:    "test_id",
+			Type:    sqldb.FieldString,
+			Table:   "test",
+			Primary: true,
+		}},
+		Search: &search.Query{Search: "and(test_id:1)", Size: 10},
+	})
+
+	if err := q.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := "SELECT COUNT(*) FROM (SELECT test.test_id FROM test " +
+		"WHERE (((test.test_id = $1)))) AS count_query"
+
+	if q.SQL != exp {
+		t.Errorf("Expecting query: %v, got: %v", exp, q.SQL)
+	}
+}
+
+type mockPlanConn struct {
+	mockSQLConn
+	sql string
+}
+
+type mockPlanRow struct{}
+
+func (m *mockPlanRow) Scan(dest ...any) error {
+	*dest[0].(*[]byte) = []byte(`[{"Plan":{"Node Type":"Seq Scan",` +
+		`"Startup Cost":0.5,"Total Cost":125.25,"Plan Rows":42}}]`)
+
+	return nil
+}
+
+func (m *mockPlanConn) QueryRow(ctx context.Context,
+	q string, args ...any,
+) sqldb.SQLRow {
+	m.sql = q
+
+	return &mockPlanRow{}
+}
+
+func TestQueryExplain(t *testing.T) {
+	t.Parallel()
+
+	db := &mockPlanConn{}
+
+	q := sqldb.NewQuery(&sqldb.QueryOptions{
+		DB:   db,
+		Type: sqldb.QuerySelect,
+		Base: "SELECT test.test_id FROM test",
+		Fields: []*sqldb.Field{{
+			Name:    "test_id",
+			Type:    sqldb.FieldString,
+			Table:   "test",
+			Primary: true,
+		}},
+		Search: &search.Query{Search: "test_id:1"},
+	})
+
+	res, err := q.Explain(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.sql != "EXPLAIN (FORMAT JSON) "+q.SQL {
+		t.Errorf("Expected explain query, got: %v", db.sql)
+	}
+
+	if res.TotalCost != 125.25 || res.StartupCost != 0.5 || res.Rows != 42 ||
+		res.Plan["Node Type"] != "Seq Scan" || res.SQL != q.SQL {
+		t.Errorf("Unexpected query plan: %+v", res)
+	}
+}
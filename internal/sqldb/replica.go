@@ -0,0 +1,303 @@
+package sqldb
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ReadDB types represent SQL database connection pools able to route read only
+// queries to replica databases.
+type ReadDB interface {
+	ReadQuery(ctx context.Context,
+		query string, args ...any) (SQLRows, error)
+	ReadQueryRow(ctx context.Context,
+		query string, args ...any) SQLRow
+}
+
+// replica values represent connection pools to read only replica databases.
+// Replicas which fail are marked down, and are not used until the connection
+// pool monitor finds them available again.
+type replica struct {
+	db   PGXDB
+	name string
+	down atomic.Bool
+}
+
+// connectReplicas opens the connection pools to the configured replica
+// databases. Replicas are only used in the normal connection mode. It must be
+// called with the connection pool lock held.
+func (sc *SQLConn) connectReplicas(ctx context.Context) error {
+	sc.closeReplicas()
+
+	if sc.mode != config.DBModeNormal {
+		return nil
+	}
+
+	for i, conn := range sc.cfg.DBReplicas() {
+		pc, err := sc.poolConfig(conn)
+		if err != nil {
+			return err
+		}
+
+		pool, err := pgxpool.NewWithConfig(ctx, pc)
+		if err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to open replica database",
+				"service", sc.svc,
+				"replica", i)
+		}
+
+		sc.replicas = append(sc.replicas, &replica{
+			db:   pool,
+			name: "replica-" + strconv.Itoa(i),
+		})
+	}
+
+	return nil
+}
+
+// closeReplicas closes the connection pools to the replica databases. It must
+// be called with the connection pool lock held.
+func (sc *SQLConn) closeReplicas() {
+	for _, r := range sc.replicas {
+		r.db.Close()
+	}
+
+	sc.replicas = nil
+}
+
+// Replicas returns the number of replica databases available for read only
+// queries.
+func (sc *SQLConn) Replicas() int {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	n := 0
+
+	for _, r := range sc.replicas {
+		if !r.down.Load() {
+			n++
+		}
+	}
+
+	return n
+}
+
+// readReplicas returns the available replica databases, in the order they
+// should be tried. The first replica tried rotates with each call, so that
+// queries are balanced across the replicas.
+func (sc *SQLConn) readReplicas() []*replica {
+	sc.RLock()
+	defer sc.RUnlock()
+
+	n := len(sc.replicas)
+	if n == 0 {
+		return nil
+	}
+
+	start := int(sc.next.Add(1) % uint64(n))
+
+	res := make([]*replica, 0, n)
+
+	for i := 0; i < n; i++ {
+		if r := sc.replicas[(start+i)%n]; !r.down.Load() {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+// replicaFailed marks a replica database down after a connection error.
+func (sc *SQLConn) replicaFailed(ctx context.Context, r *replica, err error) {
+	if r.down.CompareAndSwap(false, true) {
+		sc.LogErrorf(ctx, "ReadQuery", err,
+			"unable to connect to %s database, failing back to primary",
+			r.name)
+	}
+}
+
+// checkReplicas pings the replica databases, marking them up or down.
+func (sc *SQLConn) checkReplicas(ctx context.Context) {
+	sc.RLock()
+
+	replicas := sc.replicas
+
+	sc.RUnlock()
+
+	for _, r := range replicas {
+		pctx, cancel := context.WithTimeout(ctx, time.Second)
+
+		err := r.db.Ping(pctx)
+
+		cancel()
+
+		if err != nil {
+			sc.replicaFailed(ctx, r, err)
+
+			continue
+		}
+
+		if r.down.CompareAndSwap(true, false) {
+			sc.LogInfof(ctx, "Monitor", "%s database connection restored",
+				r.name)
+		}
+	}
+}
+
+// isConnError determines whether an error is caused by a failed database
+// connection, such as a refused or reset connection, rather than by the query
+// itself.
+func isConnError(err error) bool {
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) || pgconn.SafeToRetry(err) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// beginReplicaTx starts a read only sql transaction on a replica database.
+func (sc *SQLConn) beginReplicaTx(ctx context.Context,
+	r *replica,
+) (*SQLTrans, error) {
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"unable to begin replica transaction",
+			"replica", r.name).Transient(isTransient(err))
+	}
+
+	newTx := &SQLTrans{
+		tx:      tx,
+		sc:      sc,
+		replica: r,
+	}
+
+	if err := newTx.setStatementTimeout(ctx); err != nil {
+		if rErr := tx.Rollback(ctx); rErr != nil {
+			sc.log.Log(ctx, logger.LvlError,
+				"unable to rollback replica database transaction",
+				"error", rErr,
+				"replica", r.name)
+		}
+
+		return nil, err
+	}
+
+	_, newTx.finish = sc.startDBSpan(ctx, "replica_transaction", "")
+
+	return newTx, nil
+}
+
+// ReadQuery executes the provided read only SQL query, on a replica database if
+// any are available, returning a set of rows. If the replica databases fail,
+// the query is executed on the primary database instead.
+func (sc *SQLConn) ReadQuery(ctx context.Context,
+	query string, args ...any,
+) (SQLRows, error) {
+	for _, r := range sc.readReplicas() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		tx, err := sc.beginReplicaTx(ctx, r)
+		if err != nil {
+			if ctx.Err() == nil {
+				sc.replicaFailed(ctx, r, err)
+			}
+
+			continue
+		}
+
+		rows, err := tx.Query(ctx, query, args...)
+		if err != nil {
+			if err := tx.CloseTx(ctx, err); err != nil {
+				sc.log.Log(ctx, logger.LvlError,
+					"unable to rollback replica database transaction",
+					"error", err,
+					"replica", r.name)
+			}
+
+			if isConnError(err) {
+				sc.replicaFailed(ctx, r, err)
+
+				continue
+			}
+
+			return nil, err
+		}
+
+		if rv, ok := rows.(*sqlRows); ok {
+			rv.tx = tx
+		}
+
+		return rows, nil
+	}
+
+	return sc.Query(ctx, query, args...)
+}
+
+// ReadQueryRow executes the provided read only SQL query, on a replica database
+// if any are available, returning a single row. If the replica databases fail,
+// the query is executed on the primary database instead.
+func (sc *SQLConn) ReadQueryRow(ctx context.Context,
+	query string, args ...any,
+) SQLRow {
+	for _, r := range sc.readReplicas() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		tx, err := sc.beginReplicaTx(ctx, r)
+		if err != nil {
+			if ctx.Err() == nil {
+				sc.replicaFailed(ctx, r, err)
+			}
+
+			continue
+		}
+
+		row := tx.QueryRow(ctx, query, args...)
+
+		rv, ok := row.(*sqlRow)
+		if !ok {
+			return row
+		}
+
+		if rv.err != nil && isConnError(rv.err) {
+			if err := tx.CloseTx(ctx, rv.err); err != nil {
+				sc.log.Log(ctx, logger.LvlError,
+					"unable to rollback replica database transaction",
+					"error", err,
+					"replica", r.name)
+			}
+
+			if rv.finish != nil {
+				rv.finish(rv.err)
+			}
+
+			sc.replicaFailed(ctx, r, rv.err)
+
+			continue
+		}
+
+		rv.tx = tx
+
+		return rv
+	}
+
+	return sc.QueryRow(ctx, query, args...)
+}
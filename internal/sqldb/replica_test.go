@@ -0,0 +1,120 @@
+package sqldb_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestReadReplica(t *testing.T) {
+	t.Parallel()
+
+	ctx := mockAuthContext()
+
+	md, mock, rmock, err := sqldb.NewMockReplicaSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newQuery := func(lock bool) *sqldb.Query {
+		q := sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   md,
+			Type: sqldb.QuerySelect,
+			Base: "SELECT test.test_id FROM test WHERE test.test_id = $1",
+			Fields: []*sqldb.Field{{
+				Name:  "test_id",
+				Type:  sqldb.FieldString,
+				Table: "test",
+			}},
+			Params: []any{testID},
+			Lock:   lock,
+		})
+
+		q.Limit = 1
+
+		return q
+	}
+
+	scan := func(q *sqldb.Query) {
+		row, err := q.QueryRow(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id := ""
+
+		if err := row.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+
+		if id != testID {
+			t.Errorf("Expected id: %v, got: %v", testID, id)
+		}
+	}
+
+	// Select queries are performed on the replica.
+	rmock.ExpectBeginTx(pgx.TxOptions{AccessMode: pgx.ReadOnly})
+
+	rmock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	rmock.ExpectQuery("SELECT (.+) FROM test").
+		WithArgs(testID).
+		WillReturnRows(rmock.NewRows([]string{"test_id"}).AddRow(testID))
+
+	rmock.ExpectCommit()
+
+	scan(newQuery(false))
+
+	// Locking select queries are performed on the primary.
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectQuery("SELECT (.+) FROM test").
+		WithArgs(testID).
+		WillReturnRows(mock.NewRows([]string{"test_id"}).AddRow(testID))
+
+	mock.ExpectCommit()
+
+	scan(newQuery(true))
+
+	// Queries fail back to the primary when the replica is unavailable.
+	rmock.ExpectBeginTx(pgx.TxOptions{AccessMode: pgx.ReadOnly}).
+		WillReturnError(&net.OpError{
+			Op:  "dial",
+			Err: net.UnknownNetworkError("test"),
+		})
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT set_config").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SET", 1))
+
+	mock.ExpectQuery("SELECT (.+) FROM test").
+		WithArgs(testID).
+		WillReturnRows(mock.NewRows([]string{"test_id"}).AddRow(testID))
+
+	mock.ExpectCommit()
+
+	scan(newQuery(false))
+
+	if sc, ok := md.(*sqldb.SQLConn); !ok || sc.Replicas() != 0 {
+		t.Error("Expected replica to be marked down")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+
+	if err := rmock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet replica database expectations: %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package sqldb
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+// maxRetryBackoff is the longest time waited before retrying a failed database
+// operation, however many times it has been attempted.
+const maxRetryBackoff = time.Second * 5
+
+// breaker values implement a circuit breaker for the primary database. After
+// a number of consecutive connection failures the breaker trips, and database
+// operations fail immediately, rather than waiting on a database which is down,
+// until the breaker timeout has passed.
+type breaker struct {
+	sync.Mutex
+	failures int64
+	open     time.Time
+}
+
+// allow returns an unavailable error if the circuit breaker has tripped. Once
+// the breaker timeout has passed, a single operation is allowed, to test
+// whether the database is available again, and the timeout is restarted.
+func (sc *SQLConn) allow() error {
+	if sc.cfg.DBBreakerFailures() <= 0 {
+		return nil
+	}
+
+	sc.breaker.Lock()
+	defer sc.breaker.Unlock()
+
+	if sc.breaker.open.IsZero() {
+		return nil
+	}
+
+	if time.Now().Before(sc.breaker.open) {
+		return errors.New(errors.ErrUnavailable,
+			"database unavailable",
+			"service", sc.Svc(),
+			"retry_at", sc.breaker.open.Unix())
+	}
+
+	sc.breaker.open = time.Now().Add(sc.cfg.DBBreakerTimeout())
+
+	return nil
+}
+
+// report records the result of a database operation with the circuit breaker.
+// Connection failures are counted, and trip the breaker once the configured
+// number of consecutive failures is reached. Any other result closes it.
+func (sc *SQLConn) report(ctx context.Context, err error) {
+	n := sc.cfg.DBBreakerFailures()
+	if n <= 0 {
+		return
+	}
+
+	sc.breaker.Lock()
+	defer sc.breaker.Unlock()
+
+	if err == nil || !isConnError(err) {
+		if !sc.breaker.open.IsZero() {
+			sc.log.Log(ctx, logger.LvlInfo,
+				"database circuit breaker closed",
+				"service", sc.Svc())
+		}
+
+		sc.breaker.failures, sc.breaker.open = 0, time.Time{}
+
+		return
+	}
+
+	sc.breaker.failures++
+
+	if sc.breaker.failures < n {
+		return
+	}
+
+	if sc.breaker.open.IsZero() {
+		sc.RLock()
+		mr := sc.metric
+		sc.RUnlock()
+
+		if mr != nil {
+			mr.Increment(ctx, "db_breaker_trips")
+		}
+
+		sc.log.Log(ctx, logger.LvlError,
+			"database circuit breaker open",
+			"error", err,
+			"service", sc.Svc(),
+			"failures", sc.breaker.failures)
+	}
+
+	sc.breaker.open = time.Now().Add(sc.cfg.DBBreakerTimeout())
+}
+
+// backoff returns the time waited before a retry of a failed database
+// operation. The configured backoff is doubled for each previous retry, up to
+// the maximum, and jittered, so that operations which failed together are not
+// all retried at once.
+func (sc *SQLConn) backoff(attempt int64) time.Duration {
+	d := sc.cfg.DBRetryBackoff()
+
+	for i := int64(0); i < attempt && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+
+	d = min(d, maxRetryBackoff)
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// retry performs a database operation, attempting it again, after a backoff,
+// while it fails with a transient error, such as a lost connection or a
+// serialization failure, up to the configured number of retries. Operations
+// refused by the circuit breaker, or whose context is done, are not retried.
+func (sc *SQLConn) retry(ctx context.Context,
+	name string,
+	f func() error,
+) error {
+	retries := sc.cfg.DBRetries()
+
+	for attempt := int64(0); ; attempt++ {
+		err := f()
+		if err == nil || attempt >= retries || !isTransient(err) ||
+			errors.Has(err, errors.ErrUnavailable) {
+			return err
+		}
+
+		sc.RLock()
+		mr := sc.metric
+		sc.RUnlock()
+
+		if mr != nil {
+			mr.Increment(ctx, "db_retries", "operation:"+name)
+		}
+
+		d := sc.backoff(attempt)
+
+		sc.log.Log(ctx, logger.LvlDebug,
+			"retrying database operation",
+			"error", err,
+			"operation", name,
+			"attempt", attempt+1,
+			"backoff", d.String())
+
+		t := time.NewTimer(d)
+
+		select {
+		case <-ctx.Done():
+			t.Stop()
+
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// retryRow values are used to represent SQLRow values which are queried again,
+// when scanning them fails with a transient error.
+type retryRow struct {
+	sc    *SQLConn
+	ctx   context.Context
+	query string
+	args  []any
+	row   SQLRow
+}
+
+// Scan reads the values in a SQL row into variables, performing the query
+// again if it fails with a transient error.
+func (rr *retryRow) Scan(dest ...any) error {
+	row := rr.row
+
+	return rr.sc.retry(rr.ctx, "query_row", func() error {
+		if row == nil {
+			row = rr.sc.queryRow(rr.ctx, rr.query, rr.args...)
+		}
+
+		err := row.Scan(dest...)
+
+		row = nil
+
+		return err
+	})
+}
@@ -0,0 +1,151 @@
+package sqldb_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+	cfg.SetDB(&config.DBConfig{
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	})
+
+	md, mock, err := sqldb.NewMockSQLDB(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Serialization failures are retried.
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT 1").
+		WillReturnError(&pgconn.PgError{Code: "40001"})
+
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT 1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectCommit()
+
+	r, err := md.Exec(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RowsAffected()
+
+	// Permanent errors are not retried.
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT 1").
+		WillReturnError(&pgconn.PgError{Code: "23505"})
+
+	mock.ExpectRollback()
+
+	if _, err := md.Exec(context.Background(), "SELECT 1"); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	// Retries are limited.
+	for range 3 {
+		mock.ExpectBegin()
+
+		mock.ExpectExec("SELECT 1").
+			WillReturnError(&pgconn.PgError{Code: "40P01"})
+
+		mock.ExpectRollback()
+	}
+
+	_, err = md.Exec(context.Background(), "SELECT 1")
+	if !errors.IsRetryable(err) {
+		t.Errorf("Expected retryable error, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+	cfg.SetDB(&config.DBConfig{
+		Retries:         -1,
+		BreakerFailures: 2,
+		BreakerTimeout:  time.Millisecond * 100,
+	})
+
+	md, mock, err := sqldb.NewMockSQLDB(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		mock.ExpectBegin().WillReturnError(&net.OpError{
+			Op:  "dial",
+			Err: net.UnknownNetworkError("test"),
+		})
+
+		if _, err := md.Exec(context.Background(), "SELECT 1"); err == nil {
+			t.Fatal("Expected error")
+		}
+	}
+
+	// The breaker is open, so the database is not used.
+	_, err = md.Exec(context.Background(), "SELECT 1")
+	if !errors.Has(err, errors.ErrUnavailable) {
+		t.Errorf("Expected unavailable error, got: %v", err)
+	}
+
+	// Once the timeout has passed, an operation is allowed, and closes the
+	// breaker if it succeeds.
+	time.Sleep(time.Millisecond * 150)
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT 1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectCommit()
+
+	r, err := md.Exec(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RowsAffected()
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT 1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectCommit()
+
+	r, err = md.Exec(context.Background(), "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RowsAffected()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
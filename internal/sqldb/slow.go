@@ -0,0 +1,107 @@
+package sqldb
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/logger"
+)
+
+// explainStatements are the statements which may be planned using EXPLAIN.
+var explainStatements = []string{
+	"SELECT",
+	"INSERT",
+	"UPDATE",
+	"DELETE",
+	"WITH",
+	"VALUES",
+}
+
+// explainable determines whether a statement may be planned using EXPLAIN.
+func explainable(query string) bool {
+	q := strings.ToUpper(strings.TrimSpace(query))
+
+	for _, s := range explainStatements {
+		if strings.HasPrefix(q, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// startQuery starts a database tracing span for a statement, as startDBSpan
+// does. The returned span closing function also reports the statement as a
+// slow query, if it took longer than the configured slow query duration.
+func (sc *SQLConn) startQuery(ctx context.Context,
+	name, query string,
+	args []any,
+) (context.Context, func(err error)) {
+	ctx, finish := sc.startDBSpan(ctx, name, query)
+
+	d := sc.cfg.DBSlowQuery()
+	if d <= 0 {
+		return ctx, finish
+	}
+
+	start := time.Now()
+
+	return ctx, func(err error) {
+		finish(err)
+
+		if elapsed := time.Since(start); elapsed >= d {
+			sc.slowQuery(ctx, name, query, args, elapsed, err)
+		}
+	}
+}
+
+// slowQuery counts a slow query, in the db_slow_queries metric, and logs it
+// with its parameters. If configured, the statement is also planned, using
+// EXPLAIN without ANALYZE, so that the plan is logged without the statement
+// being performed again.
+func (sc *SQLConn) slowQuery(ctx context.Context,
+	name, query string,
+	args []any,
+	elapsed time.Duration,
+	err error,
+) {
+	sc.RLock()
+	mr := sc.metric
+	sc.RUnlock()
+
+	if mr != nil {
+		mr.Increment(ctx, "db_slow_queries", "operation:"+name)
+	}
+
+	attrs := []any{
+		"operation", name,
+		"query", query,
+		"args", args,
+		"duration", elapsed.String(),
+	}
+
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+
+	if sc.cfg.DBExplainSlow() && explainable(query) {
+		row := sc.QueryRow(context.WithoutCancel(ctx),
+			explainQuery+query, args...)
+
+		b := []byte{}
+
+		if err := row.Scan(&b); err != nil {
+			attrs = append(attrs, "explain_error", err)
+		} else if plan, err := parsePlan(b); err != nil {
+			attrs = append(attrs, "explain_error", err)
+		} else {
+			attrs = append(attrs,
+				"total_cost", plan.TotalCost,
+				"plan_rows", plan.Rows,
+				"plan", plan.Plan)
+		}
+	}
+
+	sc.log.Log(ctx, logger.LvlWarn, "slow database query", attrs...)
+}
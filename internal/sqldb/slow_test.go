@@ -0,0 +1,97 @@
+package sqldb_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/logger"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/pashagolub/pgxmock/v4"
+)
+
+func TestSlowQuery(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+	cfg.SetDB(&config.DBConfig{
+		SlowQuery:   time.Nanosecond,
+		ExplainSlow: true,
+	})
+
+	buf := &bytes.Buffer{}
+
+	log := logger.NewWriter(buf, logger.LogBackendSlog, logger.LogFmtJSON,
+		logger.LvlInfo)
+
+	md, mock, err := sqldb.NewMockSQLDB(cfg, log, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+
+	mock.ExpectQuery("SELECT test_id FROM test").
+		WithArgs(testID).
+		WillReturnRows(mock.NewRows([]string{"test_id"}).AddRow(testID))
+
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+
+	mock.ExpectQuery("EXPLAIN \\(FORMAT JSON\\) SELECT test_id FROM test").
+		WithArgs(testID).
+		WillReturnRows(mock.NewRows([]string{"QUERY PLAN"}).
+			AddRow([]byte(`[{"Plan":{"Node Type":"Seq Scan",` +
+				`"Total Cost":35.5,"Plan Rows":7}}]`)))
+
+	mock.ExpectCommit()
+
+	id := ""
+
+	if err := md.QueryRow(context.Background(),
+		"SELECT test_id FROM test WHERE test_id = $1",
+		testID).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+
+	res := buf.String()
+
+	for _, exp := range []string{
+		`"msg":"slow database query"`,
+		`"operation":"query_row"`,
+		`"args":["1"]`,
+		`"total_cost":35.5`,
+		`"Node Type":"Seq Scan"`,
+	} {
+		if !strings.Contains(res, exp) {
+			t.Errorf("Expected log to contain: %v, got: %v", exp, res)
+		}
+	}
+
+	// Statements which can not be planned are logged without a plan.
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SET LOCAL").
+		WillReturnResult(pgxmock.NewResult("SET", 0))
+
+	mock.ExpectCommit()
+
+	r, err := md.Exec(context.Background(), "SET LOCAL work_mem = '64MB'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RowsAffected()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
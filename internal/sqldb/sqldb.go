@@ -4,10 +4,11 @@ package sqldb
 import (
 	"context"
 	"fmt"
-	"net"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dhaifley/apigo/internal/config"
@@ -108,9 +109,15 @@ func (sr *sqlRow) Scan(dest ...any) error {
 			sr.finish(err)
 		}
 
-		if errors.ErrorHas(sr.err, `"app.account_id"`) {
+		if errors.ErrorHas(err, `"app.account_id"`) {
 			err = errors.Wrap(err, errors.ErrForbidden,
 				"unable to access database: missing account_id")
+		} else if isStatementTimeout(err) {
+			err = errors.Wrap(err, errors.ErrContextTimeout,
+				"database statement timeout exceeded")
+		} else if isTransient(err) {
+			err = errors.Wrap(err, errors.ErrDatabase,
+				"unable to perform query").Transient(true)
 		}
 	} else {
 		if sr.tx != nil {
@@ -219,9 +226,10 @@ type SQLTX interface {
 
 // SQLTrans values implement the SQLTX interface.
 type SQLTrans struct {
-	tx     pgx.Tx
-	sc     *SQLConn
-	finish func(err error)
+	tx      pgx.Tx
+	sc      *SQLConn
+	replica *replica
+	finish  func(err error)
 }
 
 // Commit completes a sql transaction.
@@ -234,30 +242,124 @@ func (tx *SQLTrans) Commit(ctx context.Context) error {
 	return nil
 }
 
-// setAccount is the command used to set the account ID in the database.
-func setAccount(accountID string) string {
-	return "SET app.account_id = '" + accountID + "'"
+// setAccountQuery is the statement used to set the account ID in the database.
+// The account ID is passed as a parameter, and the setting is local to the
+// transaction, so that it is never carried over to later transactions using
+// the same connection. Row level security policies fail closed, with an error,
+// when it is not set.
+const setAccountQuery = "SELECT set_config('app.account_id', $1, true)"
+
+// statementTimeoutQuery is the statement used to set the statement timeout, in
+// milliseconds, of a transaction, so that statements still running after the
+// request which started them has expired are canceled by the database.
+const statementTimeoutQuery = "SELECT set_config('statement_timeout', $1, true)"
+
+// statementTimeout returns the statement timeout for a transaction started
+// using a context. It is the configured statement timeout, or the time
+// remaining before the context deadline, if that is sooner. The result is zero
+// when no statement timeout is configured.
+func (sc *SQLConn) statementTimeout(ctx context.Context) time.Duration {
+	d := sc.cfg.DBStatementTimeout()
+	if d <= 0 {
+		return 0
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		d = min(d, time.Until(dl))
+	}
+
+	return max(d, time.Millisecond)
+}
+
+// setStatementTimeout sets the statement timeout of a new transaction, when
+// one is configured.
+func (tx *SQLTrans) setStatementTimeout(ctx context.Context) error {
+	d := tx.sc.statementTimeout(ctx)
+	if d <= 0 {
+		return nil
+	}
+
+	if _, err := tx.tx.Exec(ctx, statementTimeoutQuery,
+		strconv.FormatInt(d.Milliseconds(), 10)); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to set transaction statement timeout",
+			"statement_timeout", d.String())
+	}
+
+	return nil
+}
+
+// queryCanceledCode is the SQLSTATE code returned by the database for
+// statements canceled by the statement timeout.
+const queryCanceledCode = "57014"
+
+// isStatementTimeout determines whether an error was caused by the database
+// canceling a statement which exceeded its statement timeout.
+func isStatementTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode
+}
+
+// transientCodes are the SQLSTATE codes returned by the database for errors
+// which do not depend on the statement, and which may not recur if the
+// transaction is attempted again.
+var transientCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"53300": true, // too_many_connections
+	"57P01": true, // admin_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isTransient determines whether a database error is transient, such as a lost
+// connection, a serialization failure or a deadlock, so that the operation
+// which failed may succeed if it is attempted again.
+func isTransient(err error) bool {
+	if isConnError(err) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && transientCodes[pgErr.Code]
+}
+
+// queryExecMode returns the pgx query execution mode for a configured database
+// query execution mode.
+func queryExecMode(mode string) pgx.QueryExecMode {
+	switch mode {
+	case config.DBQueryExecModeCacheDescribe:
+		return pgx.QueryExecModeCacheDescribe
+	case config.DBQueryExecModeDescribeExec:
+		return pgx.QueryExecModeDescribeExec
+	case config.DBQueryExecModeExec:
+		return pgx.QueryExecModeExec
+	case config.DBQueryExecModeSimpleProtocol:
+		return pgx.QueryExecModeSimpleProtocol
+	default:
+		return pgx.QueryExecModeCacheStatement
+	}
 }
 
 // Exec abstracts the sql database driver exec context function.
 func (tx *SQLTrans) Exec(ctx context.Context,
 	query string, args ...any,
 ) (SQLResult, error) {
-	ctx, finish := tx.sc.startDBSpan(ctx, "exec", query)
-
-	var opErr *net.OpError
+	ctx, finish := tx.sc.startQuery(ctx, "exec", query, args)
 
 	if accountID, err := request.ContextAccountID(ctx); err == nil {
-		if _, err := tx.tx.Exec(ctx, setAccount(accountID)); err != nil {
-			if errors.As(err, &opErr) {
-				if e := tx.sc.Reconnect(ctx); e != nil {
+		if _, err := tx.tx.Exec(ctx, setAccountQuery,
+			accountID); err != nil {
+			if isConnError(err) {
+				if e := tx.reconnect(ctx, err); e != nil {
 					finish(err)
 
 					return nil, err
 				}
 
 				if _, err := tx.tx.Exec(ctx,
-					setAccount(accountID)); err != nil {
+					setAccountQuery, accountID); err != nil {
 					finish(err)
 
 					return nil, errors.Wrap(err, errors.ErrDatabase,
@@ -270,8 +372,8 @@ func (tx *SQLTrans) Exec(ctx context.Context,
 	}
 
 	res, err := tx.tx.Exec(ctx, query, args...)
-	if err != nil && errors.As(err, &opErr) {
-		if e := tx.sc.Reconnect(ctx); e != nil {
+	if err != nil && isConnError(err) {
+		if e := tx.reconnect(ctx, err); e != nil {
 			finish(err)
 
 			return nil, err
@@ -286,10 +388,13 @@ func (tx *SQLTrans) Exec(ctx context.Context,
 		if errors.ErrorHas(err, `"app.account_id"`) {
 			err = errors.Wrap(err, errors.ErrForbidden,
 				"unable to access database: missing account_id")
+		} else if isStatementTimeout(err) {
+			err = errors.Wrap(err, errors.ErrContextTimeout,
+				"database statement timeout exceeded")
 		}
 
 		return nil, errors.Wrap(err, errors.ErrDatabase,
-			"unable to execute statement")
+			"unable to execute statement").Transient(isTransient(err))
 	}
 
 	return &sqlResult{
@@ -302,21 +407,20 @@ func (tx *SQLTrans) Exec(ctx context.Context,
 func (tx *SQLTrans) Query(ctx context.Context,
 	query string, args ...any,
 ) (SQLRows, error) {
-	ctx, finish := tx.sc.startDBSpan(ctx, "query", query)
-
-	var opErr *net.OpError
+	ctx, finish := tx.sc.startQuery(ctx, "query", query, args)
 
 	if accountID, err := request.ContextAccountID(ctx); err == nil {
-		if _, err := tx.tx.Exec(ctx, setAccount(accountID)); err != nil {
-			if errors.As(err, &opErr) {
-				if e := tx.sc.Reconnect(ctx); e != nil {
+		if _, err := tx.tx.Exec(ctx, setAccountQuery,
+			accountID); err != nil {
+			if isConnError(err) {
+				if e := tx.reconnect(ctx, err); e != nil {
 					finish(err)
 
 					return nil, err
 				}
 
 				if _, err := tx.tx.Exec(ctx,
-					setAccount(accountID)); err != nil {
+					setAccountQuery, accountID); err != nil {
 					finish(err)
 
 					return nil, errors.Wrap(err, errors.ErrDatabase,
@@ -329,8 +433,8 @@ func (tx *SQLTrans) Query(ctx context.Context,
 	}
 
 	rows, err := tx.tx.Query(ctx, query, args...)
-	if err != nil && errors.As(err, &opErr) {
-		if e := tx.sc.Reconnect(ctx); e != nil {
+	if err != nil && isConnError(err) {
+		if e := tx.reconnect(ctx, err); e != nil {
 			finish(err)
 
 			return nil, err
@@ -345,10 +449,13 @@ func (tx *SQLTrans) Query(ctx context.Context,
 		if errors.ErrorHas(err, `"app.account_id"`) {
 			err = errors.Wrap(err, errors.ErrForbidden,
 				"unable to access database: missing account_id")
+		} else if isStatementTimeout(err) {
+			err = errors.Wrap(err, errors.ErrContextTimeout,
+				"database statement timeout exceeded")
 		}
 
 		return nil, errors.Wrap(err, errors.ErrDatabase,
-			"unable to perform query")
+			"unable to perform query").Transient(isTransient(err))
 	}
 
 	return &sqlRows{
@@ -361,14 +468,13 @@ func (tx *SQLTrans) Query(ctx context.Context,
 func (tx *SQLTrans) QueryRow(ctx context.Context,
 	query string, args ...any,
 ) SQLRow {
-	ctx, finish := tx.sc.startDBSpan(ctx, "query_row", query)
-
-	var opErr *net.OpError
+	ctx, finish := tx.sc.startQuery(ctx, "query_row", query, args)
 
 	if accountID, err := request.ContextAccountID(ctx); err == nil {
-		if _, err := tx.tx.Exec(ctx, setAccount(accountID)); err != nil {
-			if errors.As(err, &opErr) {
-				if e := tx.sc.Reconnect(ctx); e != nil {
+		if _, err := tx.tx.Exec(ctx, setAccountQuery,
+			accountID); err != nil {
+			if isConnError(err) {
+				if e := tx.reconnect(ctx, err); e != nil {
 					return &sqlRow{
 						err:    err,
 						finish: finish,
@@ -376,7 +482,7 @@ func (tx *SQLTrans) QueryRow(ctx context.Context,
 				}
 
 				if _, err := tx.tx.Exec(ctx,
-					setAccount(accountID)); err != nil {
+					setAccountQuery, accountID); err != nil {
 					return &sqlRow{
 						err: errors.Wrap(err, errors.ErrDatabase,
 							"unable to set account for query row"),
@@ -399,6 +505,27 @@ func (tx *SQLTrans) QueryRow(ctx context.Context,
 	}
 }
 
+// reconnect attempts to reestablish the database connection used by the
+// transaction, after a connection error. Replica transactions are not retried,
+// the replica is marked unavailable instead, so that the operation can fail back
+// to the primary database. The connection is not reestablished while the
+// circuit breaker is open.
+func (tx *SQLTrans) reconnect(ctx context.Context, err error) error {
+	if tx.replica != nil {
+		tx.sc.replicaFailed(ctx, tx.replica, err)
+
+		return err
+	}
+
+	tx.sc.report(ctx, err)
+
+	if err := tx.sc.allow(); err != nil {
+		return err
+	}
+
+	return tx.sc.Reconnect(ctx)
+}
+
 // Rollback cancels and reverses a sql transaction.
 func (tx *SQLTrans) Rollback(ctx context.Context) error {
 	if err := tx.tx.Rollback(ctx); err != nil {
@@ -457,17 +584,20 @@ type PGXDB interface {
 // SQLConn values implement the SQLDB interface.
 type SQLConn struct {
 	*sync.RWMutex
-	cfg    *config.Config
-	pool   *pgxpool.Pool
-	mock   PGXDB
-	log    logger.Logger
-	metric metric.Recorder
-	tracer trace.Tracer
-	cancel context.CancelFunc
-	inst   string
-	user   string
-	svc    string
-	mode   int
+	cfg      *config.Config
+	pool     *pgxpool.Pool
+	mock     PGXDB
+	replicas []*replica
+	next     atomic.Uint64
+	breaker  breaker
+	log      logger.Logger
+	metric   metric.Recorder
+	tracer   trace.Tracer
+	cancel   context.CancelFunc
+	inst     string
+	user     string
+	svc      string
+	mode     int
 }
 
 // NewSQLConn initializes and returns a new sql connection pool.
@@ -661,13 +791,22 @@ func (sc *SQLConn) Connect(ctx context.Context) error {
 
 	conn := sc.cfg.DBConn(sc.mode)
 
-	sc.pool, err = pgxpool.New(ctx, conn)
+	pc, err := sc.poolConfig(conn)
+	if err != nil {
+		return err
+	}
+
+	sc.pool, err = pgxpool.NewWithConfig(ctx, pc)
 	if err != nil {
 		return errors.Wrap(err, errors.ErrDatabase,
 			"unable to open database",
 			"service", sc.svc)
 	}
 
+	if err := sc.connectReplicas(ctx); err != nil {
+		return err
+	}
+
 	// Extract the user and instance information from the connection string.
 	dsi := strings.Index(conn, "//")
 	ai := strings.Index(conn, "@")
@@ -689,6 +828,30 @@ func (sc *SQLConn) Connect(ctx context.Context) error {
 	return nil
 }
 
+// poolConfig parses a database connection string into the configuration of a
+// connection pool.
+func (sc *SQLConn) poolConfig(conn string) (*pgxpool.Config, error) {
+	pc, err := pgxpool.ParseConfig(conn)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ErrDatabase,
+			"invalid database connection string",
+			"service", sc.svc)
+	}
+
+	// Statements are prepared, and cached, on each connection keyed by their
+	// SQL, so that the plans of frequently executed queries are reused.
+	cc := pc.ConnConfig
+
+	cc.DefaultQueryExecMode = queryExecMode(sc.cfg.DBQueryExecMode())
+
+	if n := sc.cfg.DBStatementCache(); n > 0 {
+		cc.StatementCacheCapacity = int(n)
+		cc.DescriptionCacheCapacity = int(n)
+	}
+
+	return pc, nil
+}
+
 // Test checks the connectivity of the database connection.
 func (sc *SQLConn) Test() error {
 	if sc.DB() == nil {
@@ -727,6 +890,61 @@ func (sc *SQLConn) Test() error {
 	return nil
 }
 
+// CheckRowSecurity verifies that row level security is enabled, with at least
+// one policy, on every tenant table, which are the tables in the public schema
+// with an account_id column. An error naming any unprotected tables is returned,
+// so that the service does not start without account isolation.
+func (sc *SQLConn) CheckRowSecurity(ctx context.Context) error {
+	rows, err := sc.Query(ctx, `SELECT c.relname
+		FROM pg_catalog.pg_class c
+		INNER JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+		INNER JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid
+			AND a.attname = 'account_id'
+			AND NOT a.attisdropped
+		WHERE n.nspname = 'public'
+			AND c.relkind IN ('r', 'p')
+			AND (NOT c.relrowsecurity OR NOT EXISTS (
+				SELECT 1 FROM pg_catalog.pg_policy p
+				WHERE p.polrelid = c.oid))
+		ORDER BY c.relname`)
+	if err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to check row level security",
+			"service", sc.Svc())
+	}
+
+	defer rows.Close()
+
+	tables := []string{}
+
+	for rows.Next() {
+		table := ""
+
+		if err := rows.Scan(&table); err != nil {
+			return errors.Wrap(err, errors.ErrDatabase,
+				"unable to select row level security table",
+				"service", sc.Svc())
+		}
+
+		tables = append(tables, table)
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, errors.ErrDatabase,
+			"unable to select row level security tables",
+			"service", sc.Svc())
+	}
+
+	if len(tables) > 0 {
+		return errors.New(errors.ErrDatabase,
+			"row level security is not enabled on tenant tables",
+			"service", sc.Svc(),
+			"tables", tables)
+	}
+
+	return nil
+}
+
 // Reconnect tests the database connection and attempts to reconnect if
 // the connection is not functional.
 func (sc *SQLConn) Reconnect(ctx context.Context) error {
@@ -781,6 +999,8 @@ func (sc *SQLConn) Monitor(ctx context.Context) {
 			case <-tick.C:
 				db := sc.DB()
 
+				sc.checkReplicas(ctx)
+
 				if db != nil {
 					if err := sc.Test(); err != nil {
 						sc.LogWarnf(ctx, "Monitor", "unable to connect to "+
@@ -815,10 +1035,17 @@ func (sc *SQLConn) BeginTx(ctx context.Context,
 			"database connection pool is not started")
 	}
 
+	if err := sc.allow(); err != nil {
+		return nil, err
+	}
+
 	tx, err := sc.DB().BeginTx(ctx, opts)
+
+	sc.report(ctx, err)
+
 	if err != nil {
 		return nil, errors.Wrap(err, errors.ErrDatabase,
-			"unable to begin transaction")
+			"unable to begin transaction").Transient(isTransient(err))
 	}
 
 	newTx := &SQLTrans{
@@ -826,6 +1053,16 @@ func (sc *SQLConn) BeginTx(ctx context.Context,
 		sc: sc,
 	}
 
+	if err := newTx.setStatementTimeout(ctx); err != nil {
+		if rErr := tx.Rollback(ctx); rErr != nil {
+			sc.log.Log(ctx, logger.LvlError,
+				"unable to rollback database transaction",
+				"error", rErr)
+		}
+
+		return nil, err
+	}
+
 	_, newTx.finish = sc.startDBSpan(ctx, "transaction", "")
 
 	return newTx, nil
@@ -845,15 +1082,34 @@ func (sc *SQLConn) ExecNoTx(ctx context.Context,
 	r, err := db.Exec(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, errors.ErrDatabase,
-			"unable to execute statement")
+			"unable to execute statement").Transient(isTransient(err))
 	}
 
 	return r, nil
 }
 
-// Exec executes the provided SQL query returning a result value.
+// Exec executes the provided SQL query returning a result value. The statement
+// is executed again if it fails with a transient error.
 func (sc *SQLConn) Exec(ctx context.Context,
 	query string, args ...any,
+) (SQLResult, error) {
+	var res SQLResult
+
+	err := sc.retry(ctx, "exec", func() error {
+		var err error
+
+		res, err = sc.exec(ctx, query, args...)
+
+		return err
+	})
+
+	return res, err
+}
+
+// exec executes the provided SQL query, in a new transaction, returning a
+// result value.
+func (sc *SQLConn) exec(ctx context.Context,
+	query string, args ...any,
 ) (SQLResult, error) {
 	if sc.DB() == nil {
 		return nil, errors.New(errors.ErrDatabase,
@@ -895,9 +1151,29 @@ func (sc *SQLConn) Exec(ctx context.Context,
 	return r, nil
 }
 
-// Query executes the provided SQL query returning a set of rows.
+// Query executes the provided SQL query returning a set of rows. The query is
+// performed again if it fails with a transient error before any rows are
+// returned.
 func (sc *SQLConn) Query(ctx context.Context,
 	query string, args ...any,
+) (SQLRows, error) {
+	var res SQLRows
+
+	err := sc.retry(ctx, "query", func() error {
+		var err error
+
+		res, err = sc.query(ctx, query, args...)
+
+		return err
+	})
+
+	return res, err
+}
+
+// query executes the provided SQL query, in a new transaction, returning a set
+// of rows.
+func (sc *SQLConn) query(ctx context.Context,
+	query string, args ...any,
 ) (SQLRows, error) {
 	if sc.DB() == nil {
 		return nil, errors.New(errors.ErrDatabase,
@@ -939,9 +1215,30 @@ func (sc *SQLConn) Query(ctx context.Context,
 	return r, nil
 }
 
-// QueryRow executes the provided SQL query returning a single row.
+// QueryRow executes the provided SQL query returning a single row. The query is
+// performed again if scanning the row fails with a transient error.
 func (sc *SQLConn) QueryRow(ctx context.Context,
 	query string, args ...any,
+) SQLRow {
+	row := sc.queryRow(ctx, query, args...)
+
+	if sc.cfg.DBRetries() <= 0 {
+		return row
+	}
+
+	return &retryRow{
+		sc:    sc,
+		ctx:   ctx,
+		query: query,
+		args:  args,
+		row:   row,
+	}
+}
+
+// queryRow executes the provided SQL query, in a new transaction, returning a
+// single row.
+func (sc *SQLConn) queryRow(ctx context.Context,
+	query string, args ...any,
 ) SQLRow {
 	if sc.DB() == nil {
 		return &sqlRow{
@@ -985,6 +1282,8 @@ func (sc *SQLConn) Close() {
 		sc.cancel()
 	}
 
+	sc.closeReplicas()
+
 	if sc.pool == nil {
 		return
 	}
@@ -1116,6 +1415,30 @@ func NewMockSQLDB(cfg *config.Config,
 	return mdb, mock, nil
 }
 
+// NewMockReplicaSQLDB initializes and returns a new mock sql connection pool,
+// with a mock read only replica database, for use in tests.
+func NewMockReplicaSQLDB(cfg *config.Config,
+	log logger.Logger,
+	metric metric.Recorder,
+	tracer trace.Tracer,
+) (SQLDB, pgxmock.PgxCommonIface, pgxmock.PgxCommonIface, error) {
+	mdb, mock, err := NewMockSQLDB(cfg, log, metric, tracer)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	rmock, err := pgxmock.NewPool()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if sc, ok := mdb.(*SQLConn); ok {
+		sc.replicas = []*replica{{db: rmock, name: "replica-0"}}
+	}
+
+	return mdb, mock, rmock, nil
+}
+
 // BeginTx starts a sql transaction.
 func (m *MockSQLDB) BeginTx(ctx context.Context,
 	opts pgx.TxOptions,
@@ -2,14 +2,18 @@ package sqldb_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dhaifley/apigo/internal/config"
+	"github.com/dhaifley/apigo/internal/errors"
 	"github.com/dhaifley/apigo/internal/request"
 	"github.com/dhaifley/apigo/internal/sqldb"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pashagolub/pgxmock/v4"
 )
 
 const (
@@ -20,7 +24,7 @@ const (
 func mockAuthContext() context.Context {
 	ctx := context.Background()
 
-	ctx = context.WithValue(ctx, request.CtxKeyAccountID, testID)
+	ctx = request.WithAccountID(ctx, testID)
 
 	return ctx
 }
@@ -116,7 +120,9 @@ func TestConnect(t *testing.T) {
 	cfg.SetDB(&config.DBConfig{
 		Conn: "postgres://test@test:5432" +
 			"/test?sslmode=disable&binary_parameters=yes",
-		Type: "postgres",
+		Type:           "postgres",
+		StatementCache: 100,
+		QueryExecMode:  config.DBQueryExecModeExec,
 	})
 
 	sc := sqldb.NewSQLConn(cfg, nil, nil, nil)
@@ -130,6 +136,18 @@ func TestConnect(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	cc := sc.Pool().Config().ConnConfig
+
+	if cc.DefaultQueryExecMode != pgx.QueryExecModeExec {
+		t.Errorf("Expected query exec mode: %v, got: %v",
+			pgx.QueryExecModeExec, cc.DefaultQueryExecMode)
+	}
+
+	if cc.StatementCacheCapacity != 100 {
+		t.Errorf("Expected statement cache capacity: 100, got: %v",
+			cc.StatementCacheCapacity)
+	}
+
 	err = sc.Test()
 	if err == nil {
 		t.Fatal("Expected connection error, got: nil")
@@ -347,3 +365,139 @@ func TestSQLConnAccessors(t *testing.T) {
 		t.Error("Expected nil Tracer")
 	}
 }
+
+func TestCheckRowSecurity(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	md, mock, err := sqldb.NewMockSQLDB(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sc, ok := md.(*sqldb.SQLConn)
+	if !ok {
+		t.Fatal("Expected SQL connection")
+	}
+
+	mock.ExpectBegin()
+
+	mock.ExpectQuery("SELECT (.+) FROM pg_catalog.pg_class").
+		WillReturnRows(mock.NewRows([]string{"relname"}).AddRow("tag"))
+
+	mock.ExpectCommit()
+
+	if err := sc.CheckRowSecurity(ctx); !errors.Has(err,
+		errors.ErrDatabase) || !strings.Contains(err.Error(), "tenant") {
+		t.Errorf("Expected row level security error, got: %v", err)
+	}
+
+	mock.ExpectBegin()
+
+	mock.ExpectQuery("SELECT (.+) FROM pg_catalog.pg_class").
+		WillReturnRows(mock.NewRows([]string{"relname"}))
+
+	mock.ExpectCommit()
+
+	if err := sc.CheckRowSecurity(ctx); err != nil {
+		t.Error(err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+	cfg.SetDB(&config.DBConfig{StatementTimeout: time.Second})
+
+	md, mock, err := sqldb.NewMockSQLDB(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT set_config\\('statement_timeout'").
+		WithArgs("1000").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectQuery("SELECT 1").
+		WillReturnError(&pgconn.PgError{Code: "57014"})
+
+	mock.ExpectRollback()
+
+	_, err = md.Query(context.Background(), "SELECT 1")
+	if !errors.Has(err, errors.ErrContextTimeout) {
+		t.Errorf("Expected timeout error, got: %v", err)
+	}
+
+	// The statement timeout is reduced to the time remaining before the
+	// context deadline.
+	ctx, cancel := context.WithTimeout(context.Background(),
+		500*time.Millisecond)
+	defer cancel()
+
+	mock.ExpectBegin()
+
+	mock.ExpectExec("SELECT set_config\\('statement_timeout'").
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectExec("SELECT 1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+
+	mock.ExpectCommit()
+
+	r, err := md.Exec(ctx, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.RowsAffected()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
+
+func TestTransientError(t *testing.T) {
+	t.Parallel()
+
+	cfg := config.NewDefault()
+	cfg.SetDB(&config.DBConfig{Retries: -1})
+
+	md, mock, err := sqldb.NewMockSQLDB(cfg, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for code, exp := range map[string]bool{
+		"40P01": true,
+		"23505": false,
+	} {
+		mock.ExpectBegin()
+
+		mock.ExpectExec("SELECT 1").
+			WillReturnError(&pgconn.PgError{Code: code})
+
+		mock.ExpectRollback()
+
+		_, err := md.Exec(context.Background(), "SELECT 1")
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+
+		if res := errors.IsRetryable(err); res != exp {
+			t.Errorf("Expected retryable for %v: %v, got: %v", code, exp, res)
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unmet database expectations: %v", err)
+	}
+}
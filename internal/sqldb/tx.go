@@ -0,0 +1,117 @@
+package sqldb
+
+import (
+	"context"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/request"
+	"github.com/jackc/pgx/v5"
+)
+
+// WithTx returns a copy of a context containing the transaction of a unit of
+// work. Queries performed using the context, which do not specify their own
+// transaction, are performed in it, so that the writes of several service
+// operations can be composed and committed atomically. The transaction must
+// not be used concurrently.
+func WithTx(ctx context.Context, tx SQLTX) context.Context {
+	return context.WithValue(ctx, request.CtxKeyTx, tx)
+}
+
+// ContextTx extracts the transaction of a unit of work from a context. It
+// returns nil if the context does not contain one.
+func ContextTx(ctx context.Context) SQLTX {
+	tx, _ := ctx.Value(request.CtxKeyTx).(SQLTX)
+
+	return tx
+}
+
+// joinedTx values represent a transaction of a unit of work, joined by an
+// operation which would otherwise begin its own transaction. Closing them has
+// no effect, since the transaction is committed, or rolled back, when the unit
+// of work is complete.
+type joinedTx struct {
+	SQLTX
+}
+
+// Commit does nothing, the transaction is committed by the unit of work.
+func (tx *joinedTx) Commit(ctx context.Context) error {
+	return nil
+}
+
+// Rollback does nothing, the transaction is rolled back by the unit of work
+// when the error which caused the rollback is returned to it.
+func (tx *joinedTx) Rollback(ctx context.Context) error {
+	return nil
+}
+
+// CloseTx does nothing, the transaction is closed by the unit of work.
+func (tx *joinedTx) CloseTx(ctx context.Context, err error) error {
+	return nil
+}
+
+// BeginTx begins a transaction using a database. If the context contains the
+// transaction of a unit of work, it is joined instead, and closing the returned
+// transaction has no effect.
+func BeginTx(ctx context.Context,
+	db SQLDB,
+	opts pgx.TxOptions,
+) (SQLTX, error) {
+	if tx := ContextTx(ctx); tx != nil {
+		return &joinedTx{SQLTX: tx}, nil
+	}
+
+	return db.BeginTx(ctx, opts)
+}
+
+// RunTx performs a function as a unit of work, passing it a context containing
+// a new transaction, which is committed if the function succeeds, or rolled
+// back if it returns an error or panics. If the context already contains the
+// transaction of a unit of work, the function is performed in it instead.
+// Otherwise, if the transaction fails with a transient error, such as a
+// serialization failure, the whole unit of work is performed again, so the
+// function must not have side effects outside of the transaction which can not
+// be repeated.
+func RunTx(ctx context.Context,
+	db SQLDB,
+	f func(ctx context.Context) error,
+) error {
+	if ContextTx(ctx) != nil {
+		return f(ctx)
+	}
+
+	if sc, ok := db.(*SQLConn); ok {
+		return sc.retry(ctx, "transaction", func() error {
+			return runTx(ctx, db, f)
+		})
+	}
+
+	return runTx(ctx, db, f)
+}
+
+// runTx performs a function as a unit of work in a new transaction.
+func runTx(ctx context.Context,
+	db SQLDB,
+	f func(ctx context.Context) error,
+) (err error) {
+	tx, err := db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback(ctx)
+
+			panic(p)
+		}
+	}()
+
+	err = f(WithTx(ctx, tx))
+
+	if cErr := tx.CloseTx(ctx, err); cErr != nil && err == nil {
+		return errors.Wrap(cErr, errors.ErrDatabase,
+			"unable to commit unit of work transaction")
+	}
+
+	return err
+}
@@ -0,0 +1,122 @@
+package sqldb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dhaifley/apigo/internal/errors"
+	"github.com/dhaifley/apigo/internal/sqldb"
+	"github.com/jackc/pgx/v5"
+)
+
+type mockUnitTx struct {
+	mockSQLTrans
+	stmts  []string
+	closed int
+	err    error
+}
+
+func (m *mockUnitTx) Exec(ctx context.Context,
+	q string, args ...any,
+) (sqldb.SQLResult, error) {
+	m.stmts = append(m.stmts, q)
+
+	return nil, nil
+}
+
+func (m *mockUnitTx) CloseTx(ctx context.Context, err error) error {
+	m.closed++
+	m.err = err
+
+	return nil
+}
+
+type mockUnitConn struct {
+	mockSQLConn
+	tx    *mockUnitTx
+	begun int
+}
+
+func (m *mockUnitConn) BeginTx(ctx context.Context,
+	opts pgx.TxOptions,
+) (sqldb.SQLTX, error) {
+	m.begun++
+
+	return m.tx, nil
+}
+
+func TestRunTx(t *testing.T) {
+	t.Parallel()
+
+	db := &mockUnitConn{tx: &mockUnitTx{}}
+
+	exec := func(ctx context.Context) error {
+		_, err := sqldb.NewQuery(&sqldb.QueryOptions{
+			DB:   db,
+			Type: sqldb.QueryUpdate,
+			Base: "UPDATE test SET test_id = 1",
+		}).Exec(ctx)
+
+		return err
+	}
+
+	if err := sqldb.RunTx(context.Background(), db,
+		func(ctx context.Context) error {
+			if err := exec(ctx); err != nil {
+				return err
+			}
+
+			// Nested units of work join the outer transaction.
+			return sqldb.RunTx(ctx, db, func(ctx context.Context) error {
+				tx, err := sqldb.BeginTx(ctx, db, pgx.TxOptions{})
+				if err != nil {
+					return err
+				}
+
+				if err := exec(ctx); err != nil {
+					return err
+				}
+
+				return tx.CloseTx(ctx, nil)
+			})
+		}); err != nil {
+		t.Fatal(err)
+	}
+
+	if db.begun != 1 {
+		t.Errorf("Expected transactions begun: 1, got: %v", db.begun)
+	}
+
+	if db.tx.closed != 1 || db.tx.err != nil {
+		t.Errorf("Expected transaction committed once, got: %v, %v",
+			db.tx.closed, db.tx.err)
+	}
+
+	if len(db.tx.stmts) != 2 {
+		t.Errorf("Expected statements in transaction: 2, got: %v",
+			len(db.tx.stmts))
+	}
+}
+
+func TestRunTxError(t *testing.T) {
+	t.Parallel()
+
+	db := &mockUnitConn{tx: &mockUnitTx{}}
+
+	err := sqldb.RunTx(context.Background(), db,
+		func(ctx context.Context) error {
+			if sqldb.ContextTx(ctx) == nil {
+				t.Error("Expected context transaction")
+			}
+
+			return errors.New(errors.ErrConflict, "test")
+		})
+	if !errors.Has(err, errors.ErrConflict) {
+		t.Errorf("Expected conflict error, got: %v", err)
+	}
+
+	if db.tx.closed != 1 || !errors.Has(db.tx.err, errors.ErrConflict) {
+		t.Errorf("Expected transaction rolled back, got: %v, %v",
+			db.tx.closed, db.tx.err)
+	}
+}
@@ -0,0 +1,295 @@
+"use strict";
+
+(() => {
+    const script = document.currentScript;
+    const base = script.dataset.base;
+    const api = script.dataset.api;
+    const main = document.getElementById("main");
+    const nav = document.getElementById("nav");
+    const tokenKey = "apigo.token";
+
+    const token = () => sessionStorage.getItem(tokenKey);
+
+    const el = (tag, attrs = {}, ...children) => {
+        const e = document.createElement(tag);
+
+        for (const [k, v] of Object.entries(attrs)) {
+            if (k.startsWith("on")) {
+                e.addEventListener(k.slice(2), v);
+            } else {
+                e.setAttribute(k, v);
+            }
+        }
+
+        e.append(...children.filter((c) => c !== null && c !== undefined));
+
+        return e;
+    };
+
+    const request = async (method, path, body) => {
+        const headers = { Accept: "application/json" };
+
+        if (token()) {
+            headers.Authorization = "Bearer " + token();
+        }
+
+        if (body !== undefined) {
+            headers["Content-Type"] = "application/json";
+            body = JSON.stringify(body);
+        }
+
+        const res = await fetch(api + path, { method, headers, body });
+
+        if (res.status === 401) {
+            sessionStorage.removeItem(tokenKey);
+            render();
+
+            throw new Error("Your session has expired, please sign in.");
+        }
+
+        if (res.status === 204) {
+            return null;
+        }
+
+        const data = await res.json().catch(() => null);
+
+        if (!res.ok) {
+            throw new Error((data && data.message) || res.statusText);
+        }
+
+        return data;
+    };
+
+    const list = (data) =>
+        Array.isArray(data) ? data : (data && data.data) || [];
+
+    const date = (v) => (v ? new Date(v * 1000).toLocaleString() : "");
+
+    const failure = (err) => el("p", { class: "error" }, err.message);
+
+    const table = (rows, columns, link) =>
+        el(
+            "table",
+            {},
+            el("thead", {}, el("tr", {}, ...columns.map((c) => el("th", {}, c.title)))),
+            el(
+                "tbody",
+                {},
+                ...rows.map((row) =>
+                    el(
+                        "tr",
+                        {},
+                        ...columns.map((c, i) => {
+                            const v = c.value(row);
+
+                            if (i === 0 && link) {
+                                return el("td", {}, el("a", { href: link(row), "data-route": "" }, v));
+                            }
+
+                            return el("td", {}, v);
+                        }),
+                    ),
+                ),
+            ),
+        );
+
+    const json = (v) => el("pre", {}, JSON.stringify(v, null, 2));
+
+    const resources = async () => {
+        const query = new URLSearchParams(location.search);
+        const search = query.get("search") || "";
+
+        const form = el(
+            "form",
+            {
+                class: "search",
+                onsubmit: (e) => {
+                    e.preventDefault();
+
+                    const q = new FormData(e.target).get("search");
+
+                    go(base + "/resources" + (q ? "?search=" + encodeURIComponent(q) : ""));
+                },
+            },
+            el("input", { name: "search", placeholder: "status:active", value: search }),
+            el("button", { type: "submit" }, "Search"),
+        );
+
+        const params = new URLSearchParams({ size: "100", sort: "-updated_at" });
+
+        if (search) {
+            params.set("search", search);
+        }
+
+        const data = await request("GET", "/resources?" + params);
+
+        return [
+            el("h2", {}, "Resources"),
+            form,
+            table(
+                list(data),
+                [
+                    { title: "Name", value: (r) => r.name || r.resource_id },
+                    { title: "Status", value: (r) => r.status || "" },
+                    { title: "Version", value: (r) => r.version || "" },
+                    { title: "Updated", value: (r) => date(r.updated_at) },
+                ],
+                (r) => base + "/resources/" + encodeURIComponent(r.resource_id),
+            ),
+        ];
+    };
+
+    const resource = async (id) => {
+        const path = "/resources/" + encodeURIComponent(id);
+        const data = await request("GET", path + "?include=tags,revisions");
+
+        const remove = el(
+            "button",
+            {
+                type: "button",
+                class: "danger",
+                onclick: async () => {
+                    if (!confirm("Delete resource " + (data.name || id) + "?")) {
+                        return;
+                    }
+
+                    try {
+                        await request("DELETE", path);
+                        go(base + "/resources");
+                    } catch (err) {
+                        remove.after(failure(err));
+                    }
+                },
+            },
+            "Delete",
+        );
+
+        return [el("h2", {}, data.name || id), json(data), remove];
+    };
+
+    const agents = async () => {
+        const data = await request("GET", "/agents?size=100");
+
+        return [
+            el("h2", {}, "Agents"),
+            table(list(data), [
+                { title: "Name", value: (a) => a.name || a.agent_id },
+                { title: "Status", value: (a) => a.status || "" },
+                { title: "Version", value: (a) => a.version || "" },
+                { title: "Last Seen", value: (a) => date(a.last_seen_at) },
+            ]),
+        ];
+    };
+
+    const tokens = async () => {
+        const data = await request("GET", "/tokens?size=100");
+
+        return [
+            el("h2", {}, "Tokens"),
+            table(list(data), [
+                { title: "Token", value: (t) => t.token_id },
+                { title: "Last Used", value: (t) => date(t.last_used_at) },
+                { title: "Scopes", value: (t) => t.scopes || "" },
+                { title: "Expires", value: (t) => date(t.expires_at) },
+            ]),
+        ];
+    };
+
+    const account = async () => {
+        const data = await request("GET", "/account");
+
+        return [el("h2", {}, data.name || "Account"), json(data)];
+    };
+
+    const routes = [
+        [/^\/?$/, resources],
+        [/^\/resources\/?$/, resources],
+        [/^\/resources\/([^/]+)$/, resource],
+        [/^\/agents\/?$/, agents],
+        [/^\/tokens\/?$/, tokens],
+        [/^\/account\/?$/, account],
+    ];
+
+    const login = () => {
+        const form = document.getElementById("login").content.cloneNode(true);
+
+        main.replaceChildren(form);
+
+        document.getElementById("login-form").addEventListener("submit", async (e) => {
+            e.preventDefault();
+
+            const res = await fetch(api + "/login/token", {
+                method: "POST",
+                headers: { Accept: "application/json" },
+                body: new URLSearchParams(new FormData(e.target)),
+            });
+
+            const data = await res.json().catch(() => null);
+
+            if (!res.ok || !data || !data.access_token) {
+                document.getElementById("login-error").textContent =
+                    (data && data.message) || "Unable to sign in.";
+
+                return;
+            }
+
+            sessionStorage.setItem(tokenKey, data.access_token);
+            render();
+        });
+    };
+
+    const render = async () => {
+        nav.hidden = !token();
+
+        if (!token()) {
+            login();
+
+            return;
+        }
+
+        const path = decodeURIComponent(location.pathname.slice(base.length));
+
+        for (const [pattern, view] of routes) {
+            const m = path.match(pattern);
+
+            if (!m) {
+                continue;
+            }
+
+            main.replaceChildren(el("p", { class: "loading" }, "Loading..."));
+
+            try {
+                main.replaceChildren(...(await view(...m.slice(1))));
+            } catch (err) {
+                main.replaceChildren(failure(err));
+            }
+
+            return;
+        }
+
+        main.replaceChildren(el("h2", {}, "Not Found"));
+    };
+
+    const go = (url) => {
+        history.pushState(null, "", url);
+        render();
+    };
+
+    document.addEventListener("click", (e) => {
+        const a = e.target.closest("a[data-route]");
+
+        if (a && !e.metaKey && !e.ctrlKey) {
+            e.preventDefault();
+            go(a.href);
+        }
+    });
+
+    document.getElementById("logout").addEventListener("click", () => {
+        sessionStorage.removeItem(tokenKey);
+        go(base + "/");
+    });
+
+    window.addEventListener("popstate", render);
+
+    render();
+})();